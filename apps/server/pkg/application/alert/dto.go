@@ -0,0 +1,16 @@
+package alert
+
+import "time"
+
+// Alert is the webhook payload sent when a project's error rate crosses its threshold
+type Alert struct {
+	Text          string    `json:"text"`
+	Event         string    `json:"event"`
+	ProjectID     string    `json:"projectId"`
+	ProjectName   string    `json:"projectName"`
+	ErrorRate     float64   `json:"errorRate"`
+	Threshold     float64   `json:"threshold"`
+	WindowMinutes int       `json:"windowMinutes"`
+	Traces        int       `json:"traces"`
+	FiredAt       time.Time `json:"firedAt"`
+}
@@ -0,0 +1,156 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/webhook"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+const (
+	// DefaultEvalInterval is how often every project's error rate is checked.
+	DefaultEvalInterval = time.Minute
+
+	defaultWindow   = 15 * time.Minute
+	defaultCooldown = time.Hour
+)
+
+// Evaluator periodically computes each project's error rate over its
+// configured sliding window and calls the project's webhook when it reaches
+// the threshold. After firing, a project stays silent for its cooldown even if
+// the rate keeps crossing the threshold, so a flapping rate alerts once.
+//
+// Cooldown state is kept in memory: a restart (or a second replica) may
+// re-alert for an ongoing incident.
+type Evaluator struct {
+	projects  repository.ProjectStore
+	analytics repository.AnalyticsStore
+	sender    *webhook.Sender
+	now       func() time.Time
+
+	mu        sync.Mutex
+	lastFired map[string]time.Time // project ID -> last alert sent
+}
+
+// NewEvaluator creates an error-rate alert evaluator.
+// Projects are read from projects; error rates from analytics.
+func NewEvaluator(projects repository.ProjectStore, analytics repository.AnalyticsStore) *Evaluator {
+	return &Evaluator{
+		projects:  projects,
+		analytics: analytics,
+		sender:    webhook.NewSender(),
+		now:       time.Now,
+		lastFired: make(map[string]time.Time),
+	}
+}
+
+// Start runs Evaluate every interval in the background until ctx is cancelled.
+func (e *Evaluator) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultEvalInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := e.Evaluate(ctx); err != nil {
+					slog.Warn("error alert evaluation failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Evaluate checks every project with an error alert configured, once.
+// Per-project failures are logged and do not stop the pass.
+func (e *Evaluator) Evaluate(ctx context.Context) error {
+	projects, err := e.projects.ListProjects(ctx)
+	if err != nil {
+		return fmt.Errorf("list projects: %w", err)
+	}
+
+	for _, p := range projects {
+		cfg := p.Settings.ErrorAlert
+		if cfg == nil || cfg.Threshold <= 0 {
+			continue
+		}
+		if err := e.evaluateProject(ctx, &p, cfg); err != nil {
+			slog.Warn("error alert failed", "projectID", p.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (e *Evaluator) evaluateProject(ctx context.Context, p *entity.Project, cfg *entity.ErrorAlertSettings) error {
+	webhookURL := cfg.WebhookURL
+	if webhookURL == nil || *webhookURL == "" {
+		webhookURL = p.Settings.WebhookURL
+	}
+	if webhookURL == nil || *webhookURL == "" {
+		return nil
+	}
+
+	window := minutesOr(cfg.WindowMinutes, defaultWindow)
+	cooldown := minutesOr(cfg.CooldownMinutes, defaultCooldown)
+	now := e.now()
+
+	e.mu.Lock()
+	last, fired := e.lastFired[p.ID]
+	e.mu.Unlock()
+	if fired && now.Sub(last) < cooldown {
+		return nil
+	}
+
+	stats, err := e.analytics.GetStats(ctx, p.ID, entity.AnalyticsQuery{
+		Period: entity.Period{From: now.Add(-window), To: now},
+	})
+	if err != nil {
+		return err
+	}
+	if stats.TotalTraces == 0 || stats.ErrorRate < cfg.Threshold {
+		return nil
+	}
+
+	alert := Alert{
+		Event:         "error_rate_alert",
+		ProjectID:     p.ID,
+		ProjectName:   p.Name,
+		ErrorRate:     stats.ErrorRate,
+		Threshold:     cfg.Threshold,
+		WindowMinutes: int(window / time.Minute),
+		Traces:        stats.TotalTraces,
+		FiredAt:       now,
+	}
+	alert.Text = fmt.Sprintf("Lelemon: %s error rate is %.1f%% over the last %d min (threshold %.1f%%, %d traces)",
+		p.Name, alert.ErrorRate, alert.WindowMinutes, alert.Threshold, alert.Traces)
+
+	// Text is included so Slack incoming webhooks render it as-is; generic
+	// receivers can use the structured fields
+	if err := e.sender.Send(ctx, *webhookURL, p.Settings.WebhookSecret, &alert); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.lastFired[p.ID] = now
+	e.mu.Unlock()
+
+	slog.Info("error alert fired", "projectID", p.ID, "errorRate", stats.ErrorRate, "threshold", cfg.Threshold)
+	return nil
+}
+
+func minutesOr(minutes int, fallback time.Duration) time.Duration {
+	if minutes <= 0 {
+		return fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,161 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/webhook"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+func TestEvaluator_FiresOncePerCooldown(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir() + "/alert.db"
+	store := newTestStoreAt(t, dbPath)
+
+	var mu sync.Mutex
+	var received []Alert
+	failWebhook := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failWebhook {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		received = append(received, a)
+	}))
+	defer receiver.Close()
+	alerts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	webhookURL := receiver.URL
+	project := newTestProject(t, store, "alerts", entity.ProjectSettings{
+		WebhookURL: &webhookURL,
+		ErrorAlert: &entity.ErrorAlertSettings{Threshold: 50, WindowMinutes: 10, CooldownMinutes: 30},
+	})
+	quiet := newTestProject(t, store, "quiet", entity.ProjectSettings{WebhookURL: &webhookURL})
+
+	base := time.Now().UTC().Truncate(time.Minute)
+	clock := base
+	e := NewEvaluator(store, store)
+	e.sender = webhook.NewSenderWithOptions(webhook.SenderOptions{AllowLoopback: true})
+	e.now = func() time.Time { return clock }
+
+	addTraces := func(projectID string, status entity.TraceStatus, n int, at time.Time) {
+		for i := 0; i < n; i++ {
+			tr := &entity.Trace{ProjectID: projectID, Status: status}
+			if err := store.CreateTrace(ctx, tr); err != nil {
+				t.Fatalf("failed to create trace: %v", err)
+			}
+			backdateTrace(t, dbPath, tr.ID, at)
+		}
+	}
+	evaluate := func(at time.Time, want int) {
+		t.Helper()
+		clock = at
+		if err := e.Evaluate(ctx); err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		if got := alerts(); got != want {
+			t.Fatalf("at +%s: expected %d alerts, got %d", at.Sub(base), want, got)
+		}
+	}
+
+	// Healthy traffic: no alert. Errors in the unconfigured project never alert.
+	addTraces(project.ID, entity.TraceStatusCompleted, 2, base.Add(-2*time.Minute))
+	addTraces(quiet.ID, entity.TraceStatusError, 5, base.Add(-2*time.Minute))
+	evaluate(base, 0)
+
+	// Spike: 6 errors / 8 traces = 75% >= 50%.
+	addTraces(project.ID, entity.TraceStatusError, 6, base.Add(-time.Minute))
+	evaluate(base, 1)
+
+	mu.Lock()
+	a := received[0]
+	mu.Unlock()
+	if a.ProjectID != project.ID || a.Threshold != 50 || a.WindowMinutes != 10 || a.Traces != 8 || a.ErrorRate != 75 {
+		t.Errorf("unexpected alert payload: %+v", a)
+	}
+	if a.Text == "" {
+		t.Error("expected a human-readable text for Slack")
+	}
+
+	// Still above threshold inside the cooldown: silent.
+	evaluate(base.Add(time.Minute), 1)
+	addTraces(project.ID, entity.TraceStatusError, 3, base.Add(15*time.Minute))
+	evaluate(base.Add(20*time.Minute), 1)
+
+	// Spike after the cooldown has elapsed, but the webhook is down: retried next pass.
+	addTraces(project.ID, entity.TraceStatusError, 3, base.Add(30*time.Minute))
+	mu.Lock()
+	failWebhook = true
+	mu.Unlock()
+	evaluate(base.Add(31*time.Minute), 1)
+	mu.Lock()
+	failWebhook = false
+	mu.Unlock()
+	evaluate(base.Add(32*time.Minute), 2)
+
+	// Recovered (below threshold) after cooldown: no alert.
+	addTraces(project.ID, entity.TraceStatusCompleted, 10, base.Add(70*time.Minute))
+	addTraces(project.ID, entity.TraceStatusError, 1, base.Add(70*time.Minute))
+	evaluate(base.Add(75*time.Minute), 2)
+}
+
+// backdateTrace sets a stored trace's created_at; CreateTrace always stamps the current time
+func backdateTrace(t *testing.T, dbPath, traceID string, createdAt time.Time) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE traces SET created_at = ? WHERE id = ?`, createdAt, traceID); err != nil {
+		t.Fatalf("failed to backdate trace: %v", err)
+	}
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	return newTestStoreAt(t, t.TempDir()+"/test.db")
+}
+
+// newTestStoreAt is newTestStore with the database file at path, for setup
+// that has to reach the database directly
+func newTestStoreAt(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// RateSource provides exchange rates from USD, e.g. a static table from
+// config or a client of a rates API
+type RateSource interface {
+	// Rate returns how many units of currency one USD is worth
+	Rate(ctx context.Context, currency string) (float64, error)
+}
+
+// StaticRates is a fixed rate table: currency code -> units per USD
+type StaticRates map[string]float64
+
+// Rate implements RateSource
+func (r StaticRates) Rate(_ context.Context, currency string) (float64, error) {
+	rate, ok := r[currency]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return rate, nil
+}
+
+// SetRateSource sets the exchange rates costs are converted at for projects
+// with a display currency. Without one, every project sees USD.
+func (s *Service) SetRateSource(rates RateSource) {
+	s.rates = rates
+}
+
+// displayCurrency returns the project's display currency and its rate from
+// USD. Projects without one, or whose currency has no rate, get USD.
+func (s *Service) displayCurrency(ctx context.Context, projectID string) (string, float64) {
+	if s.rates == nil {
+		return "USD", 1
+	}
+	project, err := s.projects.GetProjectByID(ctx, projectID)
+	if err != nil || project.Settings.Currency == "" || project.Settings.Currency == "USD" {
+		return "USD", 1
+	}
+
+	currency := project.Settings.Currency
+	rate, err := s.rates.Rate(ctx, currency)
+	if err != nil {
+		slog.Warn("showing costs in USD", "project_id", projectID, "currency", currency, "error", err)
+		return "USD", 1
+	}
+	return currency, rate
+}
+
+// convertCost converts a USD cost at rate, rounded to 6 decimals like stored costs
+func convertCost(usd, rate float64) float64 {
+	return math.Round(usd*rate*1e6) / 1e6
+}
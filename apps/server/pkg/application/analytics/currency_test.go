@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestDisplayCurrency(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	// One project per currency, each with $2.50 of LLM spend
+	newProject := func(currency string) string {
+		t.Helper()
+		project := newTestProject(t, store, "currency_"+currency, entity.ProjectSettings{Currency: currency})
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		if err := store.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		model, cost := "gpt-4o", 2.5
+		if err := store.CreateSpan(ctx, project.ID, &entity.Span{
+			TraceID: trace.ID, Type: entity.SpanTypeLLM, Name: "chat", Model: &model,
+			CostUSD: &cost, Status: entity.SpanStatusSuccess, StartedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+		return project.ID
+	}
+
+	svc := NewService(store)
+	svc.SetRateSource(StaticRates{"EUR": 0.92})
+
+	tests := []struct {
+		currency     string
+		wantCurrency string
+		wantCost     float64
+	}{
+		{"EUR", "EUR", 2.3},
+		{"", "USD", 2.5},
+		{"USD", "USD", 2.5},
+		{"GBP", "USD", 2.5}, // no rate configured
+	}
+	for _, tt := range tests {
+		projectID := newProject(tt.currency)
+
+		stats, err := svc.GetSummary(ctx, projectID, &SummaryRequest{})
+		if err != nil {
+			t.Fatalf("GetSummary failed: %v", err)
+		}
+		if stats.TotalCostUSD != 2.5 || stats.TotalCost != tt.wantCost || stats.Currency != tt.wantCurrency {
+			t.Errorf("%q summary: expected $2.5 = %v %s, got $%v = %v %s",
+				tt.currency, tt.wantCost, tt.wantCurrency, stats.TotalCostUSD, stats.TotalCost, stats.Currency)
+		}
+
+		models, err := svc.GetModelStats(ctx, projectID, &PeriodRequest{})
+		if err != nil {
+			t.Fatalf("GetModelStats failed: %v", err)
+		}
+		if len(models) != 1 || models[0].TotalCost != tt.wantCost || models[0].Currency != tt.wantCurrency {
+			t.Errorf("%q models: expected %v %s, got %+v", tt.currency, tt.wantCost, tt.wantCurrency, models)
+		}
+	}
+
+	t.Run("no rate source", func(t *testing.T) {
+		stats, err := NewService(store).GetSummary(ctx, newProject("JPY"), &SummaryRequest{})
+		if err != nil {
+			t.Fatalf("GetSummary failed: %v", err)
+		}
+		if stats.TotalCost != 2.5 || stats.Currency != "USD" {
+			t.Errorf("expected USD passthrough, got %v %s", stats.TotalCost, stats.Currency)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// DefaultMaxTimeSeriesPoints caps the points of a usage or latency time
+// series, so a long range at a fine granularity can't flood a chart
+const DefaultMaxTimeSeriesPoints = 1000
+
+// granularitySteps are the time series granularities, finest first, with
+// their bucket length
+var granularitySteps = []struct {
+	granularity string
+	step        time.Duration
+}{
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+}
+
+// TimeSeriesGranularity returns the granularity the time series of req are
+// computed at: the requested one (day by default), coarsened (hour → day →
+// week) while the range would hold more points than the cap (see
+// SetMaxPoints)
+func (s *Service) TimeSeriesGranularity(req *UsageRequest) string {
+	return coarsen(timeSeriesPeriod(req), timeSeriesGranularity(req), s.maxPoints)
+}
+
+// coarsen returns the first granularity, from granularity on, splitting
+// period into at most maxPoints buckets, or week when none does. Unknown
+// granularities and a maxPoints of 0 are left as they are.
+func coarsen(period entity.Period, granularity string, maxPoints int) string {
+	if maxPoints <= 0 {
+		return granularity
+	}
+	length := period.To.Sub(period.From)
+	coarsening := false
+	for _, g := range granularitySteps {
+		if g.granularity == granularity {
+			coarsening = true
+		}
+		if !coarsening {
+			continue
+		}
+		granularity = g.granularity
+		if int64((length+g.step-1)/g.step) <= int64(maxPoints) {
+			break
+		}
+	}
+	return granularity
+}
+
+// timeSeriesPeriod returns the range of a time series request, by default
+// the last 7 days
+func timeSeriesPeriod(req *UsageRequest) entity.Period {
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	if req.From != nil {
+		from = *req.From
+	}
+	if req.To != nil {
+		to = *req.To
+	}
+	return entity.Period{From: from, To: to}
+}
+
+// timeSeriesGranularity returns the requested granularity, by default day
+func timeSeriesGranularity(req *UsageRequest) string {
+	if req.Granularity == "" {
+		return "day"
+	}
+	return req.Granularity
+}
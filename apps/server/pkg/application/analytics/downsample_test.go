@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestCoarsen(t *testing.T) {
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) entity.Period {
+		return entity.Period{From: to.AddDate(0, 0, -n), To: to}
+	}
+
+	tests := []struct {
+		name        string
+		period      entity.Period
+		granularity string
+		maxPoints   int
+		want        string
+	}{
+		{"90 days hourly coarsens to daily", days(90), "hour", 1000, "day"},
+		{"30 days hourly fits", days(30), "hour", 1000, "hour"},
+		{"41 days hourly fits", days(41) /* 984 points */, "hour", 1000, "hour"},
+		{"42 days hourly coarsens", days(42) /* 1008 points */, "hour", 1000, "day"},
+		{"10 years daily coarsens to weekly", days(3650), "day", 1000, "week"},
+		{"weekly is never coarsened", days(36500), "week", 1000, "week"},
+		{"hourly skips day when it doesn't fit either", days(365), "hour", 100, "week"},
+		{"daily is not refined", days(1), "day", 1000, "day"},
+		{"no cap", days(90), "hour", 0, "hour"},
+		{"unknown granularity", days(90), "minute", 1000, "minute"},
+	}
+	for _, tt := range tests {
+		if got := coarsen(tt.period, tt.granularity, tt.maxPoints); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -4,15 +4,17 @@ import "time"
 
 // SummaryRequest is the request for analytics summary
 type SummaryRequest struct {
-	From *time.Time `json:"from,omitempty"`
-	To   *time.Time `json:"to,omitempty"`
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	IncludeTests bool       `json:"includeTests,omitempty"` // include the traces the project excludes from analytics
 }
 
 // UsageRequest is the request for usage time series
 type UsageRequest struct {
-	From        *time.Time `json:"from,omitempty"`
-	To          *time.Time `json:"to,omitempty"`
-	Granularity string     `json:"granularity,omitempty"` // "hour" | "day" | "week"
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	Granularity  string     `json:"granularity,omitempty"`  // "hour" | "day" | "week"
+	IncludeTests bool       `json:"includeTests,omitempty"` // include the traces the project excludes from analytics
 }
 
 // PeriodRequest holds from/to with optional fields
@@ -27,4 +29,27 @@ type PeriodRequest struct {
 	SessionID string // filter by session
 	UserID    string // filter by user
 	Name      string // filter by trace name
+
+	// IncludeTests includes the traces the project excludes from analytics
+	// (see entity.ProjectSettings.AnalyticsExclusion)
+	IncludeTests bool
+}
+
+// LatencyHistogramRequest selects the spans of a latency histogram and its
+// bucket edges
+type LatencyHistogramRequest struct {
+	PeriodRequest
+	Edges    []int  // ascending bucket edges in ms; empty = entity.DefaultLatencyHistogramEdges
+	SpanType string // filter by span type
+	Model    string // filter by model
+}
+
+// PublicMetrics is the aggregate view of a project served without auth on
+// status pages. It carries numbers only: no span content, names or user IDs.
+type PublicMetrics struct {
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Requests     int       `json:"requests"`     // traces started in the period
+	ErrorRate    float64   `json:"errorRate"`    // 0-100 percentage of errored traces
+	P95LatencyMs int       `json:"p95LatencyMs"` // span latency
 }
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// ErrUnknownPreset is returned for a period preset other than those listed in PeriodPresets
+var ErrUnknownPreset = errors.New("unknown period preset")
+
+// Period presets, resolved server-side so every client gets the same bounds
+const (
+	PresetLast24h     = "last_24h"
+	PresetLast7d      = "last_7d"
+	PresetLast30d     = "last_30d"
+	PresetMonthToDate = "month_to_date"
+)
+
+// PeriodPresets lists the recognized presets
+var PeriodPresets = []string{PresetLast24h, PresetLast7d, PresetLast30d, PresetMonthToDate}
+
+// ResolvePreset turns a preset into a concrete period at now, in loc. Periods
+// end at the close of the current minute, so the bounds (and any cache key
+// built from them) stay the same for a minute. Day-based presets start at
+// local midnight: last_7d and last_30d cover today and the 6 or 29 days
+// before, month_to_date starts on the 1st.
+func ResolvePreset(preset string, now time.Time, loc *time.Location) (entity.Period, error) {
+	now = now.In(loc)
+	to := now.Truncate(time.Minute).Add(time.Minute)
+	y, m, d := now.Date()
+
+	var from time.Time
+	switch preset {
+	case PresetLast24h:
+		from = to.Add(-24 * time.Hour)
+	case PresetLast7d:
+		from = time.Date(y, m, d-6, 0, 0, 0, 0, loc)
+	case PresetLast30d:
+		from = time.Date(y, m, d-29, 0, 0, 0, 0, loc)
+	case PresetMonthToDate:
+		from = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	default:
+		return entity.Period{}, ErrUnknownPreset
+	}
+	return entity.Period{From: from, To: to}, nil
+}
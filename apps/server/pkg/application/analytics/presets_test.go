@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolvePreset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	// 2026-03-10 02:30:45 UTC: still March 9 in New York (UTC-4 since the
+	// DST change on March 8), already 08:00 on March 10 in Kolkata (UTC+5:30)
+	now := time.Date(2026, 3, 10, 2, 30, 45, 0, time.UTC)
+	endOfMinute := time.Date(2026, 3, 10, 2, 31, 0, 0, time.UTC)
+
+	tests := []struct {
+		preset   string
+		loc      *time.Location
+		wantFrom time.Time // in UTC
+	}{
+		{PresetLast24h, time.UTC, time.Date(2026, 3, 9, 2, 31, 0, 0, time.UTC)},
+		{PresetLast7d, time.UTC, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{PresetLast30d, time.UTC, time.Date(2026, 2, 9, 0, 0, 0, 0, time.UTC)},
+		{PresetMonthToDate, time.UTC, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+
+		// New York midnights: EDT (UTC-4) after March 8, EST (UTC-5) before
+		{PresetLast24h, newYork, time.Date(2026, 3, 9, 2, 31, 0, 0, time.UTC)},
+		{PresetLast7d, newYork, time.Date(2026, 3, 3, 5, 0, 0, 0, time.UTC)},
+		{PresetLast30d, newYork, time.Date(2026, 2, 8, 5, 0, 0, 0, time.UTC)},
+		{PresetMonthToDate, newYork, time.Date(2026, 3, 1, 5, 0, 0, 0, time.UTC)},
+
+		{PresetLast7d, kolkata, time.Date(2026, 3, 3, 18, 30, 0, 0, time.UTC)},
+		{PresetMonthToDate, kolkata, time.Date(2026, 2, 28, 18, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset+"/"+tt.loc.String(), func(t *testing.T) {
+			period, err := ResolvePreset(tt.preset, now, tt.loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !period.From.Equal(tt.wantFrom) {
+				t.Errorf("from = %v, want %v", period.From.UTC(), tt.wantFrom)
+			}
+			if !period.To.Equal(endOfMinute) {
+				t.Errorf("to = %v, want %v", period.To.UTC(), endOfMinute)
+			}
+			if period.From.Location() != tt.loc {
+				t.Errorf("expected bounds in %v, got %v", tt.loc, period.From.Location())
+			}
+		})
+	}
+
+	t.Run("stable within a minute", func(t *testing.T) {
+		a, _ := ResolvePreset(PresetLast24h, now, time.UTC)
+		b, _ := ResolvePreset(PresetLast24h, now.Add(10*time.Second), time.UTC)
+		if a != b {
+			t.Errorf("expected the same period, got %v and %v", a, b)
+		}
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		if _, err := ResolvePreset("last_year", now, time.UTC); !errors.Is(err, ErrUnknownPreset) {
+			t.Errorf("expected ErrUnknownPreset, got %v", err)
+		}
+	})
+}
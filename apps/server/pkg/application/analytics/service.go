@@ -8,55 +8,122 @@ import (
 	"github.com/lelemon/server/pkg/domain/repository"
 )
 
-// Service handles analytics operations
+// Service handles analytics operations. Costs are reported in USD and in the
+// project's display currency (see SetRateSource).
 type Service struct {
-	store repository.Store
+	store    repository.Store
+	projects repository.ProjectStore
+	rates    RateSource // nil: USD only
+
+	maxPoints int // time series point cap (see SetMaxPoints)
 }
 
-// NewService creates a new analytics service
+// NewService creates a new analytics service. Projects are read from store
+// until SetProjectStore says otherwise.
 func NewService(store repository.Store) *Service {
-	return &Service{store: store}
+	return &Service{store: store, projects: store, maxPoints: DefaultMaxTimeSeriesPoints}
+}
+
+// SetProjectStore sets where projects (their display currency) are read
+// from, when they live apart from traces, e.g. in the primary store
+func (s *Service) SetProjectStore(projects repository.ProjectStore) {
+	s.projects = projects
+}
+
+// SetMaxPoints caps the points of usage and latency time series: a
+// granularity that would exceed n points over the requested range is
+// coarsened (see TimeSeriesGranularity). 0 disables the cap.
+func (s *Service) SetMaxPoints(n int) {
+	s.maxPoints = n
 }
 
 // GetSummary returns aggregate statistics for a project
 func (s *Service) GetSummary(ctx context.Context, projectID string, req *SummaryRequest) (*entity.Stats, error) {
-	to := time.Now()
-	from := to.AddDate(0, 0, -7)
-	if req.From != nil {
-		from = *req.From
+	stats, err := s.store.GetStats(ctx, projectID, entity.AnalyticsQuery{
+		Period: summaryPeriod(req),
+		Filter: s.exclusion(ctx, projectID, req.IncludeTests),
+	})
+	if err != nil {
+		return nil, err
 	}
-	if req.To != nil {
-		to = *req.To
+	currency, rate := s.displayCurrency(ctx, projectID)
+	stats.TotalCost, stats.Currency = convertCost(stats.TotalCostUSD, rate), currency
+	return stats, nil
+}
+
+// GetSummaryComparison returns the summary of the requested period and of
+// the equal-length period just before it, with the change between them
+func (s *Service) GetSummaryComparison(ctx context.Context, projectID string, req *SummaryRequest) (*entity.StatsComparison, error) {
+	period := summaryPeriod(req)
+	filter := s.exclusion(ctx, projectID, req.IncludeTests)
+	current, err := s.store.GetStats(ctx, projectID, entity.AnalyticsQuery{Period: period, Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.store.GetStats(ctx, projectID, entity.AnalyticsQuery{Period: entity.Period{
+		From: period.From.Add(-period.To.Sub(period.From)),
+		To:   period.From.Add(-time.Nanosecond),
+	}, Filter: filter})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.store.GetStats(ctx, projectID, entity.AnalyticsQuery{
-		Period: entity.Period{From: from, To: to},
-	})
+	currency, rate := s.displayCurrency(ctx, projectID)
+	current.TotalCost, current.Currency = convertCost(current.TotalCostUSD, rate), currency
+	previous.TotalCost, previous.Currency = convertCost(previous.TotalCostUSD, rate), currency
+	return &entity.StatsComparison{
+		Current:  *current,
+		Previous: *previous,
+		Change:   entity.CompareStats(*current, *previous),
+	}, nil
 }
 
-// GetUsage returns usage time series data
-func (s *Service) GetUsage(ctx context.Context, projectID string, req *UsageRequest) ([]entity.DataPoint, error) {
+// summaryPeriod returns the requested summary period, by default the last 7 days
+func summaryPeriod(req *SummaryRequest) entity.Period {
 	to := time.Now()
 	from := to.AddDate(0, 0, -7)
-	granularity := "day"
 	if req.From != nil {
 		from = *req.From
 	}
 	if req.To != nil {
 		to = *req.To
 	}
-	if req.Granularity != "" {
-		granularity = req.Granularity
+	return entity.Period{From: from, To: to}
+}
+
+// GetUsage returns usage time series data, at TimeSeriesGranularity
+func (s *Service) GetUsage(ctx context.Context, projectID string, req *UsageRequest) ([]entity.DataPoint, error) {
+	points, err := s.store.GetUsageTimeSeries(ctx, projectID, s.timeSeriesOpts(ctx, projectID, req))
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	for i := range points {
+		points[i].Cost, points[i].Currency = convertCost(points[i].CostUSD, rate), currency
 	}
+	return points, nil
+}
 
-	return s.store.GetUsageTimeSeries(ctx, projectID, entity.TimeSeriesOpts{
-		Period:      entity.Period{From: from, To: to},
-		Granularity: granularity,
-	})
+// exclusion returns a filter leaving out the traces the project excludes
+// from analytics (see entity.ProjectSettings.AnalyticsExclusion), or none
+// when includeTests is set
+func (s *Service) exclusion(ctx context.Context, projectID string, includeTests bool) entity.AnalyticsFilter {
+	if includeTests {
+		return entity.AnalyticsFilter{}
+	}
+	project, err := s.projects.GetProjectByID(ctx, projectID)
+	if err != nil || project.Settings.AnalyticsExclusion == nil {
+		return entity.AnalyticsFilter{}
+	}
+	return entity.AnalyticsFilter{
+		ExcludeTags:         project.Settings.AnalyticsExclusion.Tags,
+		ExcludeEnvironments: project.Settings.AnalyticsExclusion.Environments,
+	}
 }
 
-// buildQuery constructs an AnalyticsQuery from a PeriodRequest
-func buildQuery(req *PeriodRequest) entity.AnalyticsQuery {
+// buildQuery constructs an AnalyticsQuery from a PeriodRequest, leaving out
+// the traces the project excludes from analytics
+func (s *Service) buildQuery(ctx context.Context, projectID string, req *PeriodRequest) entity.AnalyticsQuery {
 	to := time.Now()
 	from := to.AddDate(0, 0, -7)
 	if req.From != nil {
@@ -65,25 +132,38 @@ func buildQuery(req *PeriodRequest) entity.AnalyticsQuery {
 	if req.To != nil {
 		to = *req.To
 	}
+	filter := s.exclusion(ctx, projectID, req.IncludeTests)
+	filter.Tag, filter.SessionID, filter.UserID, filter.Name = req.Tag, req.SessionID, req.UserID, req.Name
 	return entity.AnalyticsQuery{
 		Period: entity.Period{From: from, To: to},
-		Filter: entity.AnalyticsFilter{
-			Tag:       req.Tag,
-			SessionID: req.SessionID,
-			UserID:    req.UserID,
-			Name:      req.Name,
-		},
+		Filter: filter,
 	}
 }
 
 // GetModelStats returns analytics grouped by model
 func (s *Service) GetModelStats(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.ModelStats, error) {
-	return s.store.GetModelStats(ctx, projectID, buildQuery(req))
+	stats, err := s.store.GetModelStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	for i := range stats {
+		stats[i].TotalCost, stats[i].Currency = convertCost(stats[i].TotalCostUSD, rate), currency
+	}
+	return stats, nil
 }
 
 // GetTagStats returns analytics grouped by tag
 func (s *Service) GetTagStats(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.TagStats, error) {
-	return s.store.GetTagStats(ctx, projectID, buildQuery(req), req.Prefix)
+	stats, err := s.store.GetTagStats(ctx, projectID, s.buildQuery(ctx, projectID, req), req.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	for i := range stats {
+		stats[i].TotalCost, stats[i].Currency = convertCost(stats[i].TotalCostUSD, rate), currency
+	}
+	return stats, nil
 }
 
 // GetTopUsers returns top users by cost
@@ -92,35 +172,138 @@ func (s *Service) GetTopUsers(ctx context.Context, projectID string, req *Period
 	if limit <= 0 {
 		limit = 10
 	}
-	return s.store.GetTopUsers(ctx, projectID, buildQuery(req), limit)
+	stats, err := s.store.GetTopUsers(ctx, projectID, s.buildQuery(ctx, projectID, req), limit)
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	for i := range stats {
+		stats[i].TotalCost, stats[i].Currency = convertCost(stats[i].TotalCostUSD, rate), currency
+	}
+	return stats, nil
+}
+
+// GetToolViolationStats returns tool calls with schema-violating arguments, grouped by tool
+func (s *Service) GetToolViolationStats(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.ToolViolationStats, error) {
+	return s.store.GetToolViolationStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
+}
+
+// GetEvalStats returns the verdicts of eval spans, grouped by evaluator and label
+func (s *Service) GetEvalStats(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.EvalStats, error) {
+	return s.store.GetEvalStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
+}
+
+// GetCacheEfficiency returns prompt-cache hit ratios per model
+func (s *Service) GetCacheEfficiency(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.CacheEfficiency, error) {
+	results, err := s.store.GetCacheEfficiency(ctx, projectID, s.buildQuery(ctx, projectID, req))
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].InputTokens > 0 {
+			results[i].CacheHitRatio = float64(results[i].CacheReadTokens) / float64(results[i].InputTokens)
+		}
+	}
+	return results, nil
+}
+
+// GetFeedbackStats returns aggregate end-user feedback, including the
+// percentage of positive ratings
+func (s *Service) GetFeedbackStats(ctx context.Context, projectID string, req *PeriodRequest) (*entity.FeedbackStats, error) {
+	return s.store.GetFeedbackStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
+}
+
+// GetSessionStats returns session counts and per-session averages, plus the
+// sessions started each day of the period
+func (s *Service) GetSessionStats(ctx context.Context, projectID string, req *PeriodRequest) (*entity.SessionStats, error) {
+	stats, err := s.store.GetSessionStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	stats.AvgCost, stats.Currency = convertCost(stats.AvgCostUSD, rate), currency
+	return stats, nil
+}
+
+// GetUnpricedModels returns the models whose spans were priced at $0 for lack
+// of a pricing table entry, so operators know which models to add
+func (s *Service) GetUnpricedModels(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.UnpricedModelStats, error) {
+	return s.store.GetUnpricedModels(ctx, projectID, s.buildQuery(ctx, projectID, req))
+}
+
+// GetStorageStats returns the serialized input/output bytes stored per span
+// type, read from the recorded sizes rather than the payloads themselves
+func (s *Service) GetStorageStats(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.StorageStats, error) {
+	return s.store.GetStorageStats(ctx, projectID, s.buildQuery(ctx, projectID, req))
 }
 
 // GetHourlyHeatmap returns usage by hour and day of week
 func (s *Service) GetHourlyHeatmap(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.HourlyHeatmap, error) {
-	return s.store.GetHourlyHeatmap(ctx, projectID, buildQuery(req))
+	cells, err := s.store.GetHourlyHeatmap(ctx, projectID, s.buildQuery(ctx, projectID, req))
+	if err != nil {
+		return nil, err
+	}
+	currency, rate := s.displayCurrency(ctx, projectID)
+	for i := range cells {
+		cells[i].Cost, cells[i].Currency = convertCost(cells[i].CostUSD, rate), currency
+	}
+	return cells, nil
 }
 
 // GetLatencyDistribution returns latency histogram buckets
 func (s *Service) GetLatencyDistribution(ctx context.Context, projectID string, req *PeriodRequest) ([]entity.LatencyBucket, error) {
-	return s.store.GetLatencyDistribution(ctx, projectID, buildQuery(req))
+	return s.store.GetLatencyDistribution(ctx, projectID, s.buildQuery(ctx, projectID, req))
+}
+
+// GetLatencyHistogram returns span counts per latency bucket, every bucket
+// included even when empty
+func (s *Service) GetLatencyHistogram(ctx context.Context, projectID string, req *LatencyHistogramRequest) ([]entity.HistogramBucket, error) {
+	edges := req.Edges
+	if len(edges) == 0 {
+		edges = entity.DefaultLatencyHistogramEdges
+	}
+	return s.store.GetLatencyHistogram(ctx, projectID, entity.LatencyHistogramQuery{
+		AnalyticsQuery: s.buildQuery(ctx, projectID, &req.PeriodRequest),
+		Edges:          edges,
+		SpanType:       entity.SpanType(req.SpanType),
+		Model:          req.Model,
+	})
 }
 
-// GetLatencyTimeSeries returns p50/p95/p99 latency over time
+// GetLatencyTimeSeries returns p50/p95/p99 latency over time, at
+// TimeSeriesGranularity
 func (s *Service) GetLatencyTimeSeries(ctx context.Context, projectID string, req *UsageRequest) ([]entity.LatencyPoint, error) {
-	to := time.Now()
-	from := to.AddDate(0, 0, -7)
-	granularity := "day"
-	if req.From != nil {
-		from = *req.From
+	return s.store.GetLatencyTimeSeries(ctx, projectID, s.timeSeriesOpts(ctx, projectID, req))
+}
+
+// timeSeriesOpts returns the store options of a time series request
+func (s *Service) timeSeriesOpts(ctx context.Context, projectID string, req *UsageRequest) entity.TimeSeriesOpts {
+	period := timeSeriesPeriod(req)
+	return entity.TimeSeriesOpts{
+		Period:      period,
+		Granularity: coarsen(period, timeSeriesGranularity(req), s.maxPoints),
+		Filter:      s.exclusion(ctx, projectID, req.IncludeTests),
 	}
-	if req.To != nil {
-		to = *req.To
+}
+
+// GetPublicMetrics returns the public status-page metrics for a project over
+// period. Callers must check the project has PublicMetricsEnabled.
+func (s *Service) GetPublicMetrics(ctx context.Context, projectID string, period entity.Period) (*PublicMetrics, error) {
+	q := entity.AnalyticsQuery{Period: period, Filter: s.exclusion(ctx, projectID, false)}
+	stats, err := s.store.GetStats(ctx, projectID, q)
+	if err != nil {
+		return nil, err
 	}
-	if req.Granularity != "" {
-		granularity = req.Granularity
+	latency, err := s.store.GetLatencyPercentiles(ctx, projectID, q)
+	if err != nil {
+		return nil, err
 	}
-	return s.store.GetLatencyTimeSeries(ctx, projectID, entity.TimeSeriesOpts{
-		Period:      entity.Period{From: from, To: to},
-		Granularity: granularity,
-	})
+
+	return &PublicMetrics{
+		From:         period.From,
+		To:           period.To,
+		Requests:     stats.TotalTraces,
+		ErrorRate:    stats.ErrorRate,
+		P95LatencyMs: latency.P95,
+	}, nil
 }
@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+func TestGetSummaryComparison(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir() + "/comparison.db"
+	store := newTestStoreAt(t, dbPath)
+
+	project := newTestProject(t, store, "Comparison", entity.ProjectSettings{})
+
+	// addTrace stores a backdated trace with one llm span of 100 tokens
+	addTrace := func(createdAt time.Time, cost float64) {
+		t.Helper()
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		if err := store.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		backdateTrace(t, dbPath, trace.ID, createdAt)
+		tokens := 100
+		if err := store.CreateSpan(ctx, project.ID, &entity.Span{
+			TraceID: trace.ID, Type: entity.SpanTypeLLM, Name: "chat", InputTokens: &tokens,
+			CostUSD: &cost, Status: entity.SpanStatusSuccess, StartedAt: createdAt,
+		}); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+	}
+
+	to := time.Now().UTC().Truncate(time.Hour)
+	from := to.AddDate(0, 0, -7)
+	// Current week: 3 traces, $3 and 300 tokens
+	for i := 1; i <= 3; i++ {
+		addTrace(to.Add(-time.Duration(i)*time.Hour), 1)
+	}
+	// Previous week: 2 traces, $4 and 200 tokens
+	addTrace(from.Add(-time.Hour), 2)
+	addTrace(from.AddDate(0, 0, -6), 2)
+	// Before both
+	addTrace(from.AddDate(0, 0, -10), 50)
+
+	svc := NewService(store)
+	result, err := svc.GetSummaryComparison(ctx, project.ID, &SummaryRequest{From: &from, To: &to})
+	if err != nil {
+		t.Fatalf("GetSummaryComparison failed: %v", err)
+	}
+
+	if result.Current.TotalTraces != 3 || result.Current.TotalTokens != 300 || result.Current.TotalCostUSD != 3 {
+		t.Errorf("expected 3 traces, 300 tokens and $3 this week, got %+v", result.Current)
+	}
+	if result.Previous.TotalTraces != 2 || result.Previous.TotalTokens != 200 || result.Previous.TotalCostUSD != 4 {
+		t.Errorf("expected 2 traces, 200 tokens and $4 the week before, got %+v", result.Previous)
+	}
+	if result.Previous.Currency != "USD" || result.Previous.TotalCost != 4 {
+		t.Errorf("expected the previous cost in USD, got %v %s", result.Previous.TotalCost, result.Previous.Currency)
+	}
+
+	for name, c := range map[string]struct {
+		got  *float64
+		want float64
+	}{
+		"TotalTraces":  {result.Change.TotalTraces, 50},
+		"TotalSpans":   {result.Change.TotalSpans, 50},
+		"TotalTokens":  {result.Change.TotalTokens, 50},
+		"TotalCostUSD": {result.Change.TotalCostUSD, -25},
+	} {
+		if c.got == nil || math.Abs(*c.got-c.want) > 1e-9 {
+			t.Errorf("%s: expected a change of %v%%, got %v", name, c.want, c.got)
+		}
+	}
+	// No users or errors in either week: no change to report
+	if result.Change.DistinctUsers != nil || result.Change.ErrorRate != nil {
+		t.Errorf("expected no change from a previous value of 0, got %v and %v",
+			result.Change.DistinctUsers, result.Change.ErrorRate)
+	}
+}
+
+func TestCompareStats(t *testing.T) {
+	change := entity.CompareStats(
+		entity.Stats{TotalTraces: 0, TotalCostUSD: 1.5, AvgDurationMs: 150, ErrorRate: 5},
+		entity.Stats{TotalTraces: 8, TotalCostUSD: 1, AvgDurationMs: 200, ErrorRate: 0},
+	)
+	for name, c := range map[string]struct {
+		got  *float64
+		want float64
+	}{
+		"TotalTraces":   {change.TotalTraces, -100},
+		"TotalCostUSD":  {change.TotalCostUSD, 50},
+		"AvgDurationMs": {change.AvgDurationMs, -25},
+	} {
+		if c.got == nil || math.Abs(*c.got-c.want) > 1e-9 {
+			t.Errorf("%s: expected %v%%, got %v", name, c.want, c.got)
+		}
+	}
+	if change.ErrorRate != nil {
+		t.Errorf("expected no error rate change from 0, got %v", *change.ErrorRate)
+	}
+}
+
+// backdateTrace sets a stored trace's created_at; CreateTrace always stamps the current time
+func backdateTrace(t *testing.T, dbPath, traceID string, createdAt time.Time) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE traces SET created_at = ? WHERE id = ?`, createdAt, traceID); err != nil {
+		t.Fatalf("failed to backdate trace: %v", err)
+	}
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	return newTestStoreAt(t, t.TempDir()+"/test.db")
+}
+
+// newTestStoreAt is newTestStore with the database file at path, for setup
+// that has to reach the database directly
+func newTestStoreAt(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
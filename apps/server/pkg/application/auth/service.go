@@ -14,6 +14,7 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrEmailExists        = errors.New("email already registered")
 	ErrWeakPassword       = errors.New("password must be at least 12 characters with uppercase, lowercase, and number")
+	ErrInvalidOAuthState  = auth.ErrInvalidState
 )
 
 // Service handles authentication operations
@@ -104,15 +105,16 @@ func (s *Service) Login(ctx context.Context, req *LoginRequest) (*AuthResponse,
 	}, nil
 }
 
-// GetGoogleAuthURL returns the Google OAuth URL
-func (s *Service) GetGoogleAuthURL(state string) string {
-	return s.oauth.GetAuthURL(state)
+// BeginGoogleAuth returns the Google OAuth URL and the single-use state it carries
+func (s *Service) BeginGoogleAuth() (url, state string, err error) {
+	return s.oauth.BeginAuth()
 }
 
-// HandleGoogleCallback processes the Google OAuth callback
-func (s *Service) HandleGoogleCallback(ctx context.Context, code string) (*AuthResponse, error) {
+// HandleGoogleCallback processes the Google OAuth callback. It fails with
+// ErrInvalidOAuthState unless state was issued by BeginGoogleAuth and not used yet.
+func (s *Service) HandleGoogleCallback(ctx context.Context, state, code string) (*AuthResponse, error) {
 	// Exchange code for user info
-	googleUser, err := s.oauth.ExchangeCode(ctx, code)
+	googleUser, err := s.oauth.ExchangeCode(ctx, state, code)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,24 @@
+package export
+
+import "time"
+
+// JobStatus is the lifecycle state of an export job
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job describes a project export to object storage
+type Job struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"projectId"`
+	Status      JobStatus  `json:"status"`
+	Key         string     `json:"key,omitempty"` // object key, set once completed
+	Traces      int        `json:"traces"`        // traces written so far
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
@@ -0,0 +1,48 @@
+package export
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// DefaultBatchSize is how many trace IDs IterateTraces fetches per page
+const DefaultBatchSize = 500
+
+// IterateTraces calls fn for every trace of the project (with its spans), oldest
+// first. Pages are fetched by keyset cursor, so memory stays bounded by one page
+// of IDs plus one trace regardless of project size. Iteration stops at the first
+// error returned by fn.
+func IterateTraces(ctx context.Context, store repository.TraceStore, projectID string, batchSize int, fn func(*entity.TraceWithSpans) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var after *entity.TraceCursor
+	for {
+		cursors, err := store.ListTraceIDs(ctx, projectID, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range cursors {
+			trace, err := store.GetTrace(ctx, projectID, c.ID)
+			if errors.Is(err, entity.ErrNotFound) {
+				continue // deleted since the page was read
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(trace); err != nil {
+				return err
+			}
+		}
+
+		if len(cursors) < batchSize {
+			return nil
+		}
+		after = &cursors[len(cursors)-1]
+	}
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// jobRetention is how long finished jobs stay queryable
+const jobRetention = 24 * time.Hour
+
+// ObjectStore is the destination of exports. S3 is the built-in backend;
+// GCS or Azure Blob can be added by implementing it.
+type ObjectStore interface {
+	// Put streams body to key and returns once the object is fully written.
+	// body has no known length, so implementations must not buffer it whole.
+	Put(ctx context.Context, key string, body io.Reader) error
+}
+
+// Service exports a project's traces (with spans) as gzip-compressed NDJSON,
+// one TraceWithSpans per line, to an object store. Exports run in the
+// background; jobs are tracked in memory and lost on restart.
+type Service struct {
+	store     repository.TraceStore
+	objects   ObjectStore
+	prefix    string
+	batchSize int
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	wg   sync.WaitGroup
+	now  func() time.Time
+}
+
+// NewService creates an export service writing objects under prefix
+// (e.g. "lelemon/exports/").
+func NewService(store repository.TraceStore, objects ObjectStore, prefix string) *Service {
+	return &Service{
+		store:     store,
+		objects:   objects,
+		prefix:    prefix,
+		batchSize: DefaultBatchSize,
+		jobs:      make(map[string]*Job),
+		now:       time.Now,
+	}
+}
+
+// Start begins exporting the project's traces and returns the new job.
+// Returns ErrConflict if an export of the project is already running.
+func (s *Service) Start(projectID string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, job := range s.jobs {
+		if job.ProjectID == projectID && job.Status == JobStatusRunning {
+			return nil, entity.ErrConflict
+		}
+		if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > jobRetention {
+			delete(s.jobs, id)
+		}
+	}
+
+	job := &Job{
+		ID:        uuid.New().String(),
+		ProjectID: projectID,
+		Status:    JobStatusRunning,
+		StartedAt: now,
+	}
+	s.jobs[job.ID] = job
+
+	key := fmt.Sprintf("%s%s/%s-%s.ndjson.gz", s.prefix, projectID, now.UTC().Format("20060102T150405Z"), job.ID)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(job, key)
+	}()
+
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// Get returns a snapshot of the project's export job
+func (s *Service) Get(projectID, jobID string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok || job.ProjectID != projectID {
+		return nil, entity.ErrNotFound
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// Wait blocks until all running exports have finished
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+// run streams the export through a pipe so the encoder and the upload proceed
+// together: nothing larger than the object store's part buffer is held in memory.
+func (s *Service) run(job *Job, key string) {
+	ctx := context.Background()
+	pr, pw := io.Pipe()
+
+	written := make(chan error, 1)
+	go func() {
+		err := s.write(ctx, job, pw)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	putErr := s.objects.Put(ctx, key, pr)
+	pr.CloseWithError(putErr) // unblocks the writer if the upload gave up early
+	writeErr := <-written
+
+	err := writeErr
+	if err == nil {
+		err = putErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	completedAt := s.now()
+	job.CompletedAt = &completedAt
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		slog.Error("trace export failed", "project_id", job.ProjectID, "job_id", job.ID, "error", err)
+		return
+	}
+	job.Status = JobStatusCompleted
+	job.Key = key
+	slog.Info("trace export completed", "project_id", job.ProjectID, "job_id", job.ID, "traces", job.Traces, "key", key)
+}
+
+// write encodes every trace of the project as gzip-compressed NDJSON
+func (s *Service) write(ctx context.Context, job *Job, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+
+	err := IterateTraces(ctx, s.store, job.ProjectID, s.batchSize, func(t *entity.TraceWithSpans) error {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		job.Traces++
+		s.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,226 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+// mockObjectStore keeps uploaded objects in memory
+type mockObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    error
+	release chan struct{} // if set, Put waits on it before reading
+}
+
+func (m *mockObjectStore) Put(ctx context.Context, key string, body io.Reader) error {
+	if m.release != nil {
+		<-m.release
+	}
+	if m.fail != nil {
+		return m.fail
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	return newTestStoreAt(t, t.TempDir()+"/test.db")
+}
+
+// newTestStoreAt is newTestStore with the database file at path, for setup
+// that has to reach the database directly
+func newTestStoreAt(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
+
+func readNDJSON(t *testing.T, data []byte) []entity.TraceWithSpans {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("export is not gzip: %v", err)
+	}
+	var traces []entity.TraceWithSpans
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		var tr entity.TraceWithSpans
+		if err := json.Unmarshal(scanner.Bytes(), &tr); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		traces = append(traces, tr)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	return traces
+}
+
+func TestExport_WritesAllTracesAsGzipNDJSON(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir() + "/export.db"
+	store := newTestStoreAt(t, dbPath)
+	project := newTestProject(t, store, "export", entity.ProjectSettings{})
+	other := newTestProject(t, store, "other", entity.ProjectSettings{})
+
+	// Several traces share a timestamp so pages split inside a tie
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	for i := 0; i < 7; i++ {
+		tr := &entity.Trace{
+			ID: fmt.Sprintf("trace-%02d", i), ProjectID: project.ID,
+			Status: entity.TraceStatusCompleted,
+		}
+		if err := store.CreateTrace(ctx, tr); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		tr.CreatedAt = base.Add(time.Duration(i/3) * time.Minute)
+		backdateTrace(t, dbPath, tr.ID, tr.CreatedAt)
+		if err := store.CreateSpan(ctx, project.ID, &entity.Span{TraceID: tr.ID, Type: entity.SpanTypeLLM, Name: "call", Status: entity.SpanStatusSuccess, StartedAt: tr.CreatedAt}); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+	}
+	if err := store.CreateTrace(ctx, &entity.Trace{ID: "foreign", ProjectID: other.ID, Status: entity.TraceStatusCompleted}); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	objects := &mockObjectStore{objects: map[string][]byte{}}
+	svc := NewService(store, objects, "exports/")
+	svc.batchSize = 2
+
+	job, err := svc.Start(project.ID)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if job.ID == "" || job.Status != JobStatusRunning {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	svc.Wait()
+
+	done, err := svc.Get(project.ID, job.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if done.Status != JobStatusCompleted || done.Traces != 7 || done.CompletedAt == nil {
+		t.Fatalf("unexpected finished job: %+v", done)
+	}
+	if !strings.HasPrefix(done.Key, "exports/"+project.ID+"/") || !strings.HasSuffix(done.Key, ".ndjson.gz") {
+		t.Errorf("unexpected object key %q", done.Key)
+	}
+
+	traces := readNDJSON(t, objects.objects[done.Key])
+	if len(traces) != 7 {
+		t.Fatalf("expected 7 exported traces, got %d", len(traces))
+	}
+	for i, tr := range traces {
+		if want := fmt.Sprintf("trace-%02d", i); tr.ID != want {
+			t.Errorf("line %d: expected %s (oldest first, no duplicates), got %s", i, want, tr.ID)
+		}
+		if len(tr.Spans) != 1 {
+			t.Errorf("line %d: expected spans to be included, got %d", i, len(tr.Spans))
+		}
+	}
+
+	if _, err := svc.Get(other.ID, job.ID); !errors.Is(err, entity.ErrNotFound) {
+		t.Errorf("expected job to be hidden from other projects, got %v", err)
+	}
+}
+
+func TestExport_UploadFailure(t *testing.T) {
+	store := newTestStore(t)
+	project := newTestProject(t, store, "failing", entity.ProjectSettings{})
+	if err := store.CreateTrace(context.Background(), &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	objects := &mockObjectStore{objects: map[string][]byte{}, fail: errors.New("access denied")}
+	svc := NewService(store, objects, "")
+
+	job, err := svc.Start(project.ID)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	svc.Wait() // must not hang even though the upload never reads the body
+
+	done, _ := svc.Get(project.ID, job.ID)
+	if done.Status != JobStatusFailed || done.Key != "" || !strings.Contains(done.Error, "access denied") {
+		t.Errorf("unexpected failed job: %+v", done)
+	}
+}
+
+func TestExport_OneRunningExportPerProject(t *testing.T) {
+	store := newTestStore(t)
+	project := newTestProject(t, store, "busy", entity.ProjectSettings{})
+
+	objects := &mockObjectStore{objects: map[string][]byte{}, release: make(chan struct{})}
+	svc := NewService(store, objects, "")
+
+	if _, err := svc.Start(project.ID); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := svc.Start(project.ID); !errors.Is(err, entity.ErrConflict) {
+		t.Errorf("expected ErrConflict for a concurrent export, got %v", err)
+	}
+
+	close(objects.release)
+	svc.Wait()
+
+	if _, err := svc.Start(project.ID); err != nil {
+		t.Errorf("expected a new export once the previous finished, got %v", err)
+	}
+	svc.Wait()
+}
+
+// backdateTrace sets a stored trace's created_at; CreateTrace always stamps the current time
+func backdateTrace(t *testing.T, dbPath, traceID string, createdAt time.Time) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE traces SET created_at = ? WHERE id = ?`, createdAt, traceID); err != nil {
+		t.Fatalf("failed to backdate trace: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"strconv"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// indexAttributes promotes the project's indexed metadata keys into each
+// span's Attributes, leaving Metadata untouched. Only scalar values are
+// promoted; objects, arrays and nulls are left to the metadata JSON.
+func (p *EventProcessor) indexAttributes(spans []entity.Span, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	for i := range spans {
+		span := &spans[i]
+		for _, key := range keys {
+			value, ok := attributeValue(span.Metadata[key])
+			if !ok {
+				continue
+			}
+			if span.Attributes == nil {
+				span.Attributes = make(map[string]string, len(keys))
+			}
+			span.Attributes[key] = value
+		}
+	}
+}
+
+// attributeValue returns the text form of a metadata value as it is indexed:
+// strings as is, numbers and booleans as they read in JSON. Reports false for
+// values that are not indexed.
+func attributeValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// DefaultBackfillBatchSize is how many spans BackfillSpanTools reads and
+// updates at a time
+const DefaultBackfillBatchSize = 500
+
+// BackfillSpanTools derives the sub_type and tool_uses of llm spans stored
+// before those columns existed from their output, with the same extraction
+// ingest applies to new spans. Spans are handled batchSize at a time
+// (DefaultBackfillBatchSize when <= 0), each batch written in its own short
+// transaction so ingest is never locked out for long. A span is no longer
+// listed once its sub_type is set, so an interrupted backfill resumes where
+// it stopped when run again. Progress is logged per batch; it returns how
+// many spans were updated.
+func BackfillSpanTools(ctx context.Context, store repository.SpanToolBackfiller, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatchSize
+	}
+
+	var updated int64
+	after := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+		spans, err := store.ListSpansMissingSubType(ctx, after, batchSize)
+		if err != nil {
+			return updated, fmt.Errorf("list spans: %w", err)
+		}
+		if len(spans) == 0 {
+			return updated, nil
+		}
+
+		for i := range spans {
+			subType := determineLLMSubType(spans[i].Output)
+			spans[i].SubType = &subType
+			spans[i].ToolUses = extractToolUsesFromOutput(spans[i].Output, spans[i].ID)
+		}
+		n, err := store.SetSpanSubTypes(ctx, spans)
+		if err != nil {
+			return updated, fmt.Errorf("update spans after %q: %w", after, err)
+		}
+		updated += n
+		after = spans[len(spans)-1].ID
+		slog.Info("span tool backfill progress", "updated", updated, "last_span_id", after)
+	}
+}
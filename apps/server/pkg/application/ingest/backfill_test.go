@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestBackfillSpanTools(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "Backfill", entity.ProjectSettings{})
+	trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+	if err := store.CreateTrace(ctx, trace); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	planning := "planning"
+	// Spans as stored before sub_type and tool_uses were written: only the
+	// output holds the tool calls
+	seed := []entity.Span{
+		{ID: "anthropic-tool-call", Type: entity.SpanTypeLLM, Output: []any{
+			map[string]any{"type": "text", "text": "Let me check"},
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "Lima"}},
+		}},
+		{ID: "bedrock-tool-call", Type: entity.SpanTypeLLM, Output: []any{
+			map[string]any{"toolUse": map[string]any{"toolUseId": "tooluse_1", "name": "search", "input": map[string]any{"q": "go"}}},
+		}},
+		{ID: "plain-answer", Type: entity.SpanTypeLLM, Output: "It is sunny"},
+		{ID: "text-blocks", Type: entity.SpanTypeLLM, Output: []any{map[string]any{"type": "text", "text": "Done"}}},
+		// Not backfilled: no output, not an llm span, or already set
+		{ID: "no-output", Type: entity.SpanTypeLLM},
+		{ID: "tool-span", Type: entity.SpanTypeTool, Output: []any{
+			map[string]any{"type": "tool_use", "id": "toolu_2", "name": "nested"},
+		}},
+		{ID: "already-set", Type: entity.SpanTypeLLM, SubType: &planning, Output: "kept as is"},
+	}
+	for i := range seed {
+		seed[i].TraceID = trace.ID
+		seed[i].Name = seed[i].ID
+		seed[i].Status = entity.SpanStatusSuccess
+		seed[i].StartedAt = time.Now().Add(time.Duration(i) * time.Millisecond)
+		if err := store.CreateSpan(ctx, project.ID, &seed[i]); err != nil {
+			t.Fatalf("failed to create span %s: %v", seed[i].ID, err)
+		}
+	}
+
+	// Two spans per batch, so the four candidates take several batches
+	updated, err := BackfillSpanTools(ctx, store, 2)
+	if err != nil {
+		t.Fatalf("BackfillSpanTools failed: %v", err)
+	}
+	if updated != 4 {
+		t.Errorf("expected 4 spans updated, got %d", updated)
+	}
+
+	result, err := store.GetTrace(ctx, project.ID, trace.ID)
+	if err != nil {
+		t.Fatalf("failed to get trace: %v", err)
+	}
+	spans := make(map[string]entity.Span)
+	for _, sp := range result.Spans {
+		spans[sp.ID] = sp
+	}
+
+	want := map[string]struct {
+		subType string // "" when it should stay unset
+		tools   []string
+	}{
+		"anthropic-tool-call": {"planning", []string{"get_weather"}},
+		"bedrock-tool-call":   {"planning", []string{"search"}},
+		"plain-answer":        {"response", nil},
+		"text-blocks":         {"response", nil},
+		"no-output":           {"", nil},
+		"tool-span":           {"", nil},
+		"already-set":         {"planning", nil},
+	}
+	for id, w := range want {
+		sp, ok := spans[id]
+		if !ok {
+			t.Errorf("span %s missing", id)
+			continue
+		}
+		var subType string
+		if sp.SubType != nil {
+			subType = *sp.SubType
+		}
+		if subType != w.subType {
+			t.Errorf("%s: expected sub_type %q, got %q", id, w.subType, subType)
+		}
+		if len(sp.ToolUses) != len(w.tools) {
+			t.Errorf("%s: expected tool uses %v, got %+v", id, w.tools, sp.ToolUses)
+			continue
+		}
+		for i, name := range w.tools {
+			if sp.ToolUses[i].Name != name {
+				t.Errorf("%s: expected tool %s, got %s", id, name, sp.ToolUses[i].Name)
+			}
+		}
+	}
+	if tu := spans["anthropic-tool-call"].ToolUses; len(tu) == 1 && tu[0].ID != "toolu_1" {
+		t.Errorf("expected the tool use to keep its ID, got %s", tu[0].ID)
+	}
+
+	t.Run("rerun resumes with nothing left", func(t *testing.T) {
+		updated, err := BackfillSpanTools(ctx, store, 2)
+		if err != nil {
+			t.Fatalf("BackfillSpanTools failed: %v", err)
+		}
+		if updated != 0 {
+			t.Errorf("expected no spans updated, got %d", updated)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := BackfillSpanTools(cancelled, store, 2); err == nil {
+			t.Error("expected an error for a cancelled context")
+		}
+	})
+}
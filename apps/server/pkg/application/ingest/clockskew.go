@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// clockSkewPolicy bounds how far event timestamps may be from server time,
+// so clock-skewed or replayed clients don't scatter spans across charts
+type clockSkewPolicy struct {
+	maxSkew time.Duration // 0 disables the check
+	clamp   bool          // store out-of-window events at receive time instead of rejecting them
+}
+
+func (c clockSkewPolicy) enabled() bool {
+	return c.maxSkew > 0
+}
+
+// check returns event as is when its timestamp is missing or within maxSkew
+// of now. Otherwise it returns an error or, when clamping, a copy stamped
+// with now whose metadata keeps the original timestamp.
+func (c clockSkewPolicy) check(event IngestEvent, now time.Time) (IngestEvent, error) {
+	if !c.enabled() || event.Timestamp == nil {
+		return event, nil
+	}
+	ts := *event.Timestamp
+	skew := ts.Sub(now)
+	if skew >= -c.maxSkew && skew <= c.maxSkew {
+		return event, nil
+	}
+
+	if !c.clamp {
+		direction := "in the past"
+		if skew > 0 {
+			direction = "in the future"
+		}
+		return event, fmt.Errorf("timestamp %s is more than %s %s", ts.Format(time.RFC3339), c.maxSkew, direction)
+	}
+
+	metadata := make(map[string]any, len(event.Metadata)+1)
+	maps.Copy(metadata, event.Metadata)
+	metadata["originalTimestamp"] = ts.Format(time.RFC3339Nano)
+	event.Metadata = metadata
+	event.Timestamp = &now
+	return event, nil
+}
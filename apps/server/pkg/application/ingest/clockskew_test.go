@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestClockSkewPolicy_Check(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+	reject := clockSkewPolicy{maxSkew: 24 * time.Hour}
+	clamp := clockSkewPolicy{maxSkew: 24 * time.Hour, clamp: true}
+
+	tests := []struct {
+		name    string
+		policy  clockSkewPolicy
+		ts      *time.Time
+		wantErr string // substring; empty when the event is accepted
+		wantTS  *time.Time
+	}{
+		{"in window past", reject, at(-23 * time.Hour), "", at(-23 * time.Hour)},
+		{"in window future", reject, at(time.Hour), "", at(time.Hour)},
+		{"no timestamp", reject, nil, "", nil},
+		{"too far past", reject, at(-25 * time.Hour), "in the past", nil},
+		{"too far future", reject, at(48 * time.Hour), "in the future", nil},
+		{"clamped past", clamp, at(-72 * time.Hour), "", &now},
+		{"clamped future", clamp, at(48 * time.Hour), "", &now},
+		{"disabled", clockSkewPolicy{}, at(-365 * 24 * time.Hour), "", at(-365 * 24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := IngestEvent{Timestamp: tt.ts, Metadata: map[string]any{"k": "v"}}
+			got, err := tt.policy.check(event, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (got.Timestamp == nil) != (tt.wantTS == nil) || (got.Timestamp != nil && !got.Timestamp.Equal(*tt.wantTS)) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, tt.wantTS)
+			}
+			clamped := tt.wantTS == &now
+			if (got.Metadata["originalTimestamp"] != nil) != clamped {
+				t.Errorf("originalTimestamp in metadata: got %v, clamped %v", got.Metadata, clamped)
+			}
+			if _, ok := event.Metadata["originalTimestamp"]; ok {
+				t.Error("the caller's metadata was modified")
+			}
+		})
+	}
+}
+
+func TestIngest_ClockSkew(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "skew", entity.ProjectSettings{})
+
+	now := time.Now()
+	event := func(traceID string, at time.Time) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanType: "tool", Name: "search", Status: "success", Timestamp: &at}
+	}
+
+	t.Run("rejects out-of-window events and keeps the rest", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		svc.SetMaxClockSkew(24*time.Hour, false)
+
+		traceID := uuid.New().String()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			event(traceID, now.Add(-48*time.Hour)),
+			event(traceID, now.Add(-time.Hour)),
+			event(traceID, now.Add(48*time.Hour)),
+		}})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Success || resp.Processed != 1 || len(resp.Errors) != 2 {
+			t.Fatalf("expected 1 processed and 2 errors, got %+v", resp)
+		}
+		if resp.Errors[0].Index != 0 || resp.Errors[1].Index != 2 {
+			t.Errorf("expected errors for events 0 and 2, got %+v", resp.Errors)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected only the in-window span, got %+v (%v)", trace, err)
+		}
+	})
+
+	t.Run("clamps out-of-window events to server time", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		svc.SetMaxClockSkew(24*time.Hour, true)
+
+		traceID := uuid.New().String()
+		past := now.Add(-72 * time.Hour)
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{event(traceID, past)}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected the clamped span, got %+v (%v)", trace, err)
+		}
+		span := trace.Spans[0]
+		if span.StartedAt.Before(now.Add(-time.Minute)) {
+			t.Errorf("expected the span clamped to server time, started at %v", span.StartedAt)
+		}
+		if span.Metadata["originalTimestamp"] != past.Format(time.RFC3339Nano) {
+			t.Errorf("expected the original timestamp in metadata, got %v", span.Metadata["originalTimestamp"])
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+const (
+	// DefaultDedupWindow applies when a project enables dedup without a window.
+	DefaultDedupWindow = 5 * time.Minute
+
+	// dedupCacheSize bounds the recent hashes remembered per project.
+	dedupCacheSize = 10000
+)
+
+// dedupWindow returns the project's content-hash dedup window, or 0 when disabled.
+func dedupWindow(settings entity.ProjectSettings) time.Duration {
+	cfg := settings.IngestDedup
+	if cfg == nil || !cfg.Enabled {
+		return 0
+	}
+	if cfg.WindowSeconds <= 0 {
+		return DefaultDedupWindow
+	}
+	return time.Duration(cfg.WindowSeconds) * time.Second
+}
+
+// spanContentHash identifies a span by content rather than ID:
+// (traceId, type, name, input, output, startedAt).
+func spanContentHash(span *entity.Span) string {
+	content, _ := json.Marshal(struct {
+		TraceID   string
+		Type      entity.SpanType
+		Name      string
+		Input     any
+		Output    any
+		StartedAt time.Time
+	}{span.TraceID, span.Type, span.Name, span.Input, span.Output, span.StartedAt.UTC()})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// spanDeduper remembers recently ingested span hashes in a bounded LRU per project.
+// In-memory only: duplicates that straddle a restart (or land on another replica) get through.
+type spanDeduper struct {
+	mu       sync.Mutex
+	size     int
+	projects map[string]*hashLRU
+}
+
+type hashLRU struct {
+	order *list.List               // front = most recently seen
+	items map[string]*list.Element // hash -> element holding *hashEntry
+}
+
+type hashEntry struct {
+	hash   string
+	seenAt time.Time
+}
+
+func newSpanDeduper(size int) *spanDeduper {
+	return &spanDeduper{
+		size:     size,
+		projects: make(map[string]*hashLRU),
+	}
+}
+
+// seen reports whether hash was recorded for the project within window of
+// now. A hit doesn't extend the window: it runs from when the span was stored.
+func (d *spanDeduper) seen(projectID, hash string, window time.Duration, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lru, ok := d.projects[projectID]
+	if !ok {
+		return false
+	}
+	el, ok := lru.items[hash]
+	return ok && now.Sub(el.Value.(*hashEntry).seenAt) < window
+}
+
+// record remembers hashes of spans stored for the project at now
+func (d *spanDeduper) record(projectID string, hashes []string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lru, ok := d.projects[projectID]
+	if !ok {
+		lru = &hashLRU{order: list.New(), items: make(map[string]*list.Element)}
+		d.projects[projectID] = lru
+	}
+	for _, hash := range hashes {
+		if el, ok := lru.items[hash]; ok {
+			el.Value.(*hashEntry).seenAt = now
+			lru.order.MoveToFront(el)
+			continue
+		}
+		lru.items[hash] = lru.order.PushFront(&hashEntry{hash: hash, seenAt: now})
+		if lru.order.Len() > d.size {
+			oldest := lru.order.Back()
+			lru.order.Remove(oldest)
+			delete(lru.items, oldest.Value.(*hashEntry).hash)
+		}
+	}
+}
+
+// filter drops spans already seen for the project within window, and repeats
+// within spans. It records nothing: the returned hashes, one per kept span,
+// are recorded once the spans are stored, so a retry of a failed write isn't
+// taken for a duplicate.
+func (d *spanDeduper) filter(projectID string, spans []entity.Span, window time.Duration) ([]entity.Span, []string) {
+	now := time.Now()
+	kept := spans[:0]
+	var hashes []string
+	batch := make(map[string]bool, len(spans))
+	for i := range spans {
+		hash := spanContentHash(&spans[i])
+		if batch[hash] || d.seen(projectID, hash, window, now) {
+			continue
+		}
+		batch[hash] = true
+		kept = append(kept, spans[i])
+		hashes = append(hashes, hash)
+	}
+	return kept, hashes
+}
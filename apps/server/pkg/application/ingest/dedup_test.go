@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_Dedup(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	svc := NewService(store, service.NewPricingCalculator())
+
+	enabled := newTestProject(t, store, "enabled", entity.ProjectSettings{
+		IngestDedup: &entity.IngestDedupSettings{Enabled: true, WindowSeconds: 60},
+	})
+	disabled := newTestProject(t, store, "disabled", entity.ProjectSettings{})
+
+	startedAt := time.Now().UTC().Truncate(time.Millisecond)
+	event := func(traceID, output string, at time.Time) IngestEvent {
+		return IngestEvent{
+			TraceID: traceID, SpanType: "tool", Name: "search", Status: "success",
+			Input: map[string]any{"q": "weather"}, Output: output, Timestamp: &at,
+		}
+	}
+	ingest := func(p *entity.Project, events ...IngestEvent) {
+		t.Helper()
+		resp, err := svc.Ingest(ctx, p, &IngestRequest{Events: events})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+	}
+	spanCount := func(p *entity.Project, traceID string) int {
+		t.Helper()
+		trace, err := store.GetTrace(ctx, p.ID, traceID)
+		if err != nil {
+			t.Fatalf("failed to get trace: %v", err)
+		}
+		return len(trace.Spans)
+	}
+
+	t.Run("exact duplicates are skipped", func(t *testing.T) {
+		traceID := uuid.New().String()
+		ingest(enabled, event(traceID, "sunny", startedAt))
+		ingest(enabled, event(traceID, "sunny", startedAt))
+		// Duplicates within a single batch are caught too
+		ingest(enabled, event(traceID, "sunny", startedAt), event(traceID, "sunny", startedAt))
+
+		if got := spanCount(enabled, traceID); got != 1 {
+			t.Errorf("expected 1 span, got %d", got)
+		}
+	})
+
+	t.Run("near duplicates are kept", func(t *testing.T) {
+		traceID := uuid.New().String()
+		ingest(enabled,
+			event(traceID, "sunny", startedAt),
+			event(traceID, "rainy", startedAt),                       // different output
+			event(traceID, "sunny", startedAt.Add(time.Millisecond)), // different start
+		)
+		ingest(enabled, event(uuid.New().String(), "sunny", startedAt)) // different trace
+
+		if got := spanCount(enabled, traceID); got != 3 {
+			t.Errorf("expected 3 spans, got %d", got)
+		}
+	})
+
+	t.Run("spans that failed to store are not recorded", func(t *testing.T) {
+		ingest(enabled, IngestEvent{TraceID: uuid.New().String(), SpanID: "dedup-taken", SpanType: "tool", Name: "search", Status: "success"})
+
+		// The span ID is taken, so the span fails to store and a retry of
+		// the same content under a fresh ID must not be dropped
+		traceID := uuid.New().String()
+		failing := event(traceID, "retried", startedAt)
+		failing.SpanID = "dedup-taken"
+		resp, err := svc.Ingest(ctx, enabled, &IngestRequest{Events: []IngestEvent{failing}})
+		if err != nil || len(resp.FailedSpans) != 1 {
+			t.Fatalf("expected the span to fail, got %v %+v", err, resp)
+		}
+		retry := event(traceID, "retried", startedAt)
+		retry.SpanID = "dedup-retry"
+		ingest(enabled, retry)
+		retry.SpanID = "dedup-retry-again"
+		ingest(enabled, retry)
+
+		if got := spanCount(enabled, traceID); got != 1 {
+			t.Errorf("expected the retry stored once, got %d spans", got)
+		}
+	})
+
+	t.Run("disabled projects keep duplicates", func(t *testing.T) {
+		traceID := uuid.New().String()
+		ingest(disabled, event(traceID, "sunny", startedAt))
+		ingest(disabled, event(traceID, "sunny", startedAt))
+
+		if got := spanCount(disabled, traceID); got != 2 {
+			t.Errorf("expected 2 spans, got %d", got)
+		}
+	})
+}
+
+func TestSpanDeduper_Window(t *testing.T) {
+	d := newSpanDeduper(10)
+	now := time.Now()
+	window := time.Minute
+
+	if d.seen("p1", "h", window, now) {
+		t.Fatal("unrecorded hash reported as duplicate")
+	}
+	d.record("p1", []string{"h"}, now)
+	if !d.seen("p1", "h", window, now.Add(30*time.Second)) {
+		t.Error("expected duplicate inside the window")
+	}
+	if d.seen("p2", "h", window, now.Add(30*time.Second)) {
+		t.Error("hashes must not be shared across projects")
+	}
+	// The hit above doesn't extend the window
+	if d.seen("p1", "h", window, now.Add(61*time.Second)) {
+		t.Error("expected the hash to expire a window after it was recorded")
+	}
+}
+
+func TestSpanDeduper_Bounded(t *testing.T) {
+	d := newSpanDeduper(3)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		d.record("p1", []string{fmt.Sprintf("h%d", i)}, now)
+	}
+	if got := len(d.projects["p1"].items); got != 3 {
+		t.Fatalf("expected cache bounded to 3, got %d", got)
+	}
+	if !d.seen("p1", "h4", time.Hour, now) {
+		t.Error("expected recent hash to be retained")
+	}
+	if d.seen("p1", "h0", time.Hour, now) {
+		t.Error("expected oldest hash to be evicted")
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings entity.ProjectSettings
+		want     time.Duration
+	}{
+		{"unset", entity.ProjectSettings{}, 0},
+		{"disabled", entity.ProjectSettings{IngestDedup: &entity.IngestDedupSettings{WindowSeconds: 30}}, 0},
+		{"default window", entity.ProjectSettings{IngestDedup: &entity.IngestDedupSettings{Enabled: true}}, DefaultDedupWindow},
+		{"custom window", entity.ProjectSettings{IngestDedup: &entity.IngestDedupSettings{Enabled: true, WindowSeconds: 30}}, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedupWindow(tt.settings); got != tt.want {
+				t.Errorf("dedupWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package ingest
 
-import "time"
+import (
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
 
 // IngestRequest is the request payload for the ingest endpoint
 type IngestRequest struct {
@@ -9,6 +13,11 @@ type IngestRequest struct {
 
 // IngestEvent represents a single LLM event
 type IngestEvent struct {
+	// Type is "span" (the default) or "trace.end", which finalizes traceId
+	// instead of adding a span to it: its status (completed, the default, or
+	// error), output and timestamp become the trace's (see EventTypeTraceEnd)
+	Type string `json:"type,omitempty"`
+
 	// Span metadata
 	SpanType string `json:"spanType"` // "llm" | "tool" | "retrieval" | "custom"
 	Provider string `json:"provider"` // "openai" | "anthropic" | "gemini" | "bedrock" | "openrouter" | "unknown"
@@ -25,11 +34,16 @@ type IngestEvent struct {
 	OutputTokens *int `json:"outputTokens,omitempty"`
 
 	// Execution
-	DurationMs   *int   `json:"durationMs,omitempty"`
-	Status       string `json:"status"` // "success" | "error"
-	ErrorMessage string `json:"errorMessage,omitempty"`
-	ErrorStack   string `json:"errorStack,omitempty"`
-	Streaming    bool   `json:"streaming,omitempty"`
+	DurationMs   *int     `json:"durationMs,omitempty"`
+	CostUSD      *float64 `json:"costUsd,omitempty"` // Explicit cost (e.g. self-hosted models); takes precedence over the computed cost
+	Status       string   `json:"status"`            // "success" | "error" | "timeout" | "cancelled" | "pending"
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+	ErrorStack   string   `json:"errorStack,omitempty"`
+	Streaming    bool     `json:"streaming,omitempty"`
+
+	// Client-computed cost (USD) per token class, e.g. {"input": 0.01, "output": 0.03}.
+	// Without costUsd, their sum is the span's explicit cost.
+	CostDetails map[string]float64 `json:"costDetails,omitempty"`
 
 	// Context
 	SessionID string `json:"sessionId,omitempty"`
@@ -41,6 +55,15 @@ type IngestEvent struct {
 	ParentSpanID string `json:"parentSpanId,omitempty"`
 	ToolCallID   string `json:"toolCallId,omitempty"`
 
+	// ParentTraceID links the event's trace to the trace that spawned it (e.g.
+	// an orchestrator's), when the trace is created
+	ParentTraceID string `json:"parentTraceId,omitempty"`
+
+	// Links reference related spans outside the span's parent chain, e.g. the
+	// messages a batch job consumed. A link without a traceId points into the
+	// event's own trace; links without a spanId are dropped.
+	Links []entity.SpanLink `json:"links,omitempty"`
+
 	// Extended fields (legacy - extracted from RawResponse when available)
 	StopReason       string `json:"stopReason,omitempty"`
 	CacheReadTokens  *int   `json:"cacheReadTokens,omitempty"`
@@ -49,19 +72,53 @@ type IngestEvent struct {
 	FirstTokenMs     *int   `json:"firstTokenMs,omitempty"` // SDK must still provide this (timing)
 	Thinking         string `json:"thinking,omitempty"`
 
+	// Generation settings, e.g. {"temperature": 0.2, "max_tokens": 512}. Merged
+	// over those extracted from an LLM span's input and rawResponse.
+	ModelParams map[string]any `json:"modelParams,omitempty"`
+
 	// Custom data
 	Metadata map[string]any `json:"metadata,omitempty"`
-	Tags     []string       `json:"tags,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`     // trace tags
+	SpanTags []string       `json:"spanTags,omitempty"` // tags of this span alone
 
 	// Timestamp
 	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Sequence  *int       `json:"sequence,omitempty"` // Emission order, for spans sharing a timestamp; defaults to the event's position in the batch
 }
 
 // IngestResponse is the response payload for the ingest endpoint
 type IngestResponse struct {
-	Success   bool          `json:"success"`
-	Processed int           `json:"processed"`
-	Errors    []IngestError `json:"errors,omitempty"`
+	Success    bool          `json:"success"`
+	Processed  int           `json:"processed"`
+	SampledOut int           `json:"sampledOut,omitempty"` // events accepted but dropped by the project's sample rate
+	Errors     []IngestError `json:"errors,omitempty"`
+
+	// Warnings lists events that were ingested but break a project policy,
+	// e.g. sent without a sessionId the project requires
+	Warnings []IngestError `json:"warnings,omitempty"`
+
+	// FailedSpans lists the spans the store could not write, e.g. for a
+	// duplicate span ID; the rest of the batch was stored
+	FailedSpans []FailedSpan `json:"failedSpans,omitempty"`
+
+	// RunawayTraces lists the batch's traces over the project's trace limits;
+	// SDKs should abort them. In async mode a trace shows up from the first
+	// batch after the one that crossed the limit has been processed.
+	RunawayTraces []string `json:"runawayTraces,omitempty"`
+}
+
+// DryRunResponse is the response payload for the ingest dry-run endpoint
+type DryRunResponse struct {
+	Spans    []entity.Span `json:"spans"`
+	Errors   []IngestError `json:"errors,omitempty"`
+	Warnings []IngestError `json:"warnings,omitempty"`
+}
+
+// FailedSpan is a span that passed validation but could not be stored
+type FailedSpan struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+	Message string `json:"message"`
 }
 
 // IngestError represents an error for a specific event
@@ -0,0 +1,110 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// MetadataEvalVerdict is the span metadata key holding an eval span's
+// verdict, parsed from its output at ingest
+const MetadataEvalVerdict = "eval_verdict"
+
+// EvalVerdict is the structured result of an evaluation span, e.g. an
+// LLM-as-judge call: a score, a label (e.g. "pass" or "fail") and the
+// judge's rationale. Any of them may be missing.
+type EvalVerdict struct {
+	Score     *float64 `json:"score,omitempty"`
+	Label     string   `json:"label,omitempty"`
+	Rationale string   `json:"rationale,omitempty"`
+}
+
+// verdict field names, in order of preference
+var (
+	verdictLabelKeys     = []string{"label", "verdict", "result"}
+	verdictRationaleKeys = []string{"rationale", "reasoning", "explanation", "reason"}
+	verdictPassKeys      = []string{"pass", "passed"}
+)
+
+// extractVerdict is the built-in stage recording an eval span's verdict. It
+// runs after extractResponse, so it reads the output from rawResponse too.
+func (p *EventProcessor) extractVerdict(span *entity.Span, event IngestEvent) {
+	if span.Type != entity.SpanTypeEval {
+		return
+	}
+	verdict, ok := parseVerdict(span.Output)
+	if !ok {
+		return
+	}
+	if span.Metadata == nil {
+		span.Metadata = make(map[string]any)
+	}
+	span.Metadata[MetadataEvalVerdict] = verdict
+}
+
+// parseVerdict reads a verdict from an eval span's output: an object with a
+// numeric score, a label (or verdict/result, or a pass/passed boolean mapped
+// to "pass" or "fail") and a rationale (or reasoning/explanation/reason), or
+// the same object JSON-encoded in a string, as judges often answer in text.
+// Label case is normalized so "PASS" and "pass" aggregate together. Outputs
+// without a score or label carry no verdict.
+func parseVerdict(output any) (EvalVerdict, bool) {
+	fields, ok := verdictFields(output)
+	if !ok {
+		return EvalVerdict{}, false
+	}
+
+	var verdict EvalVerdict
+	if score, ok := fields["score"].(float64); ok {
+		verdict.Score = &score
+	}
+	for _, key := range verdictLabelKeys {
+		if label, ok := fields[key].(string); ok && strings.TrimSpace(label) != "" {
+			verdict.Label = strings.ToLower(strings.TrimSpace(label))
+			break
+		}
+	}
+	if verdict.Label == "" {
+		for _, key := range verdictPassKeys {
+			if pass, ok := fields[key].(bool); ok {
+				verdict.Label = "fail"
+				if pass {
+					verdict.Label = "pass"
+				}
+				break
+			}
+		}
+	}
+	for _, key := range verdictRationaleKeys {
+		if rationale, ok := fields[key].(string); ok && rationale != "" {
+			verdict.Rationale = rationale
+			break
+		}
+	}
+
+	if verdict.Score == nil && verdict.Label == "" {
+		return EvalVerdict{}, false
+	}
+	return verdict, true
+}
+
+// verdictFields returns output as a JSON object, decoding it from a string
+// (optionally in a ```json fence) when needed
+func verdictFields(output any) (map[string]any, bool) {
+	switch v := output.(type) {
+	case map[string]any:
+		return v, true
+	case string:
+		text := strings.TrimSpace(v)
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &fields); err != nil {
+			return nil, false
+		}
+		return fields, true
+	}
+	return nil, false
+}
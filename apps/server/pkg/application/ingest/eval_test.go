@@ -0,0 +1,52 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVerdict(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		output any
+		want   EvalVerdict
+		ok     bool
+	}{
+		{
+			name:   "object",
+			output: map[string]any{"score": 0.8, "label": "PASS", "rationale": "cites the source"},
+			want:   EvalVerdict{Score: score(0.8), Label: "pass", Rationale: "cites the source"},
+			ok:     true,
+		},
+		{
+			name:   "json text in a fence",
+			output: "```json\n{\"verdict\": \"fail\", \"reasoning\": \"hallucinated a date\"}\n```",
+			want:   EvalVerdict{Label: "fail", Rationale: "hallucinated a date"},
+			ok:     true,
+		},
+		{
+			name:   "pass boolean",
+			output: map[string]any{"passed": false, "score": 2.0},
+			want:   EvalVerdict{Score: score(2), Label: "fail"},
+			ok:     true,
+		},
+		{
+			name:   "no score or label",
+			output: map[string]any{"rationale": "looks fine"},
+		},
+		{
+			name:   "free text",
+			output: "The answer is correct.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseVerdict(tt.output)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVerdict() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
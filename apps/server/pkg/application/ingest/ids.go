@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// IDFormat is a format event IDs may be required to have
+type IDFormat string
+
+const (
+	IDFormatAny  IDFormat = ""
+	IDFormatUUID IDFormat = "uuid"
+	IDFormatULID IDFormat = "ulid"
+)
+
+// IDValidation constrains the trace, span and parent IDs events carry, so
+// client bugs can't index blank or pathological keys
+type IDValidation struct {
+	MaxLength int      // 0: no limit
+	Charset   string   // allowed characters as the body of a regexp character class, e.g. "A-Za-z0-9_-"; empty allows any
+	Format    IDFormat // required format; IDFormatAny allows any
+	Strict    bool     // reject events with an invalid ID instead of dropping the ID
+}
+
+// idPolicy applies an IDValidation to events
+type idPolicy struct {
+	maxLength int
+	charset   string
+	allowed   *regexp.Regexp // matches IDs made of charset; nil allows any characters
+	format    IDFormat
+	strict    bool
+}
+
+func newIDPolicy(v IDValidation) (idPolicy, error) {
+	p := idPolicy{maxLength: v.MaxLength, charset: v.Charset, format: v.Format, strict: v.Strict}
+	switch v.Format {
+	case IDFormatAny, IDFormatUUID, IDFormatULID:
+	default:
+		return idPolicy{}, fmt.Errorf("unknown ID format %q (uuid or ulid)", v.Format)
+	}
+	if v.MaxLength < 0 {
+		return idPolicy{}, fmt.Errorf("invalid ID max length %d", v.MaxLength)
+	}
+	if v.Charset != "" {
+		re, err := regexp.Compile(`^[` + v.Charset + `]+$`)
+		if err != nil {
+			return idPolicy{}, fmt.Errorf("invalid ID charset %q: %w", v.Charset, err)
+		}
+		p.allowed = re
+	}
+	return p, nil
+}
+
+func (p idPolicy) enabled() bool {
+	return p.maxLength > 0 || p.allowed != nil || p.format != IDFormatAny
+}
+
+// check returns event as is when its IDs are valid or absent. Otherwise it
+// returns an error naming the first invalid ID or, when not strict, a copy
+// without the invalid IDs, which ingest then treats as never sent: the server
+// assigns span IDs, spans lose their parent and events their trace.
+func (p idPolicy) check(event IngestEvent) (IngestEvent, error) {
+	if !p.enabled() {
+		return event, nil
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"traceId", &event.TraceID},
+		{"spanId", &event.SpanID},
+		{"parentSpanId", &event.ParentSpanID},
+		{"parentTraceId", &event.ParentTraceID},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			continue
+		}
+		if err := p.validate(*f.value); err != nil {
+			if p.strict {
+				return event, fmt.Errorf("invalid %s: %w", f.name, err)
+			}
+			*f.value = ""
+		}
+	}
+	return event, nil
+}
+
+// validate reports why id doesn't satisfy the policy
+func (p idPolicy) validate(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("blank ID")
+	}
+	if p.maxLength > 0 && len(id) > p.maxLength {
+		return fmt.Errorf("ID is %d bytes long, more than %d", len(id), p.maxLength)
+	}
+	if p.allowed != nil && !p.allowed.MatchString(id) {
+		return fmt.Errorf("ID %q has characters outside [%s]", id, p.charset)
+	}
+	switch p.format {
+	case IDFormatUUID:
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("ID %q is not a UUID", id)
+		}
+	case IDFormatULID:
+		if !isULID(id) {
+			return fmt.Errorf("ID %q is not a ULID", id)
+		}
+	}
+	return nil
+}
+
+// isULID reports whether id is a ULID: 26 Crockford base32 characters, the
+// first at most 7 so the 128-bit value doesn't overflow
+func isULID(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for _, c := range strings.ToUpper(id) {
+		if !strings.ContainsRune("0123456789ABCDEFGHJKMNPQRSTVWXYZ", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIDPolicy_Check(t *testing.T) {
+	validUUID := uuid.New().String()
+	overlong := strings.Repeat("a", 200)
+
+	tests := []struct {
+		name       string
+		validation IDValidation
+		spanID     string
+		wantErr    string // substring; empty when the event is accepted
+		wantSpanID string
+	}{
+		{"absent", IDValidation{MaxLength: 64, Strict: true}, "", "", ""},
+		{"blank", IDValidation{MaxLength: 64, Strict: true}, "   ", "blank ID", ""},
+		{"within max length", IDValidation{MaxLength: 64, Strict: true}, "span-1", "", "span-1"},
+		{"overlong", IDValidation{MaxLength: 64, Strict: true}, overlong, "more than 64", ""},
+		{"outside charset", IDValidation{Charset: "A-Za-z0-9_-", Strict: true}, "span/../1", "characters outside", ""},
+		{"within charset", IDValidation{Charset: "A-Za-z0-9_-", Strict: true}, "span_1-a", "", "span_1-a"},
+		{"uuid", IDValidation{Format: IDFormatUUID, Strict: true}, validUUID, "", validUUID},
+		{"not a uuid", IDValidation{Format: IDFormatUUID, Strict: true}, "span-1", "not a UUID", ""},
+		{"ulid", IDValidation{Format: IDFormatULID, Strict: true}, "01HZX3K8M9QW2E5R7T6Y4V1A0B", "", "01HZX3K8M9QW2E5R7T6Y4V1A0B"},
+		{"not a ulid", IDValidation{Format: IDFormatULID, Strict: true}, validUUID, "not a ULID", ""},
+		{"ulid overflow", IDValidation{Format: IDFormatULID, Strict: true}, "81HZX3K8M9QW2E5R7T6Y4V1A0B", "not a ULID", ""},
+		{"lenient drops the ID", IDValidation{Format: IDFormatUUID}, "span-1", "", ""},
+		{"disabled", IDValidation{}, overlong, "", overlong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newIDPolicy(tt.validation)
+			if err != nil {
+				t.Fatalf("newIDPolicy failed: %v", err)
+			}
+			got, err := policy.check(IngestEvent{SpanID: tt.spanID})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if !strings.Contains(err.Error(), "spanId") {
+					t.Errorf("expected the error to name spanId, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.SpanID != tt.wantSpanID {
+				t.Errorf("spanId = %q, want %q", got.SpanID, tt.wantSpanID)
+			}
+		})
+	}
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		for _, v := range []IDValidation{
+			{Format: "snowflake"},
+			{Charset: "z-a"},
+			{MaxLength: -1},
+		} {
+			if _, err := newIDPolicy(v); err == nil {
+				t.Errorf("%+v: expected an error", v)
+			}
+		}
+	})
+}
+
+func TestIngest_IDValidation(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "ids", entity.ProjectSettings{})
+
+	event := func(traceID, spanID string) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanID: spanID, SpanType: "tool", Name: "search", Status: "success"}
+	}
+
+	t.Run("strict rejects events with invalid IDs and keeps the rest", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		if err := svc.SetIDValidation(IDValidation{MaxLength: 64, Format: IDFormatUUID, Strict: true}); err != nil {
+			t.Fatalf("SetIDValidation failed: %v", err)
+		}
+
+		traceID := uuid.New().String()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			event(traceID, uuid.New().String()),
+			event("not-a-uuid", uuid.New().String()),
+			event(traceID, strings.Repeat("f", 100)),
+			event(traceID, " "),
+		}})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Success || resp.Processed != 1 || len(resp.Errors) != 3 {
+			t.Fatalf("expected 1 processed and 3 errors, got %+v", resp)
+		}
+		for i, e := range resp.Errors {
+			if e.Index != i+1 {
+				t.Errorf("expected an error for event %d, got %+v", i+1, e)
+			}
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected only the valid span, got %+v (%v)", trace, err)
+		}
+	})
+
+	t.Run("lenient ingests without the invalid ID", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		if err := svc.SetIDValidation(IDValidation{Format: IDFormatUUID}); err != nil {
+			t.Fatalf("SetIDValidation failed: %v", err)
+		}
+
+		traceID := uuid.New().String()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{event(traceID, "span-1")}})
+		if err != nil || !resp.Success || resp.Processed != 1 {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected the span, got %+v (%v)", trace, err)
+		}
+		if _, err := uuid.Parse(trace.Spans[0].ID); err != nil {
+			t.Errorf("expected a server-assigned span ID, got %q", trace.Spans[0].ID)
+		}
+	})
+}
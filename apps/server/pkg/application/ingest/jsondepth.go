@@ -0,0 +1,107 @@
+package ingest
+
+import "fmt"
+
+// truncatedJSONValue replaces values nested deeper than the depth limit when
+// truncating
+const truncatedJSONValue = "[truncated: max depth exceeded]"
+
+// jsonDepthPolicy bounds how deeply an event's input, output and metadata may
+// nest, so pathological payloads can't blow up marshaling or bloat the store.
+// Depth counts nested objects and arrays: {"a": {"b": 1}} has depth 2.
+// Only JSON-decoded values (map[string]any, []any) are walked.
+type jsonDepthPolicy struct {
+	maxDepth int  // 0 disables the check
+	truncate bool // cut values deeper than maxDepth instead of rejecting the event
+}
+
+func (p jsonDepthPolicy) enabled() bool {
+	return p.maxDepth > 0
+}
+
+// check returns event as is when its JSON fields are within maxDepth.
+// Otherwise it returns an error naming the first offending field or, when
+// truncating, a copy with the too-deep values replaced by truncatedJSONValue.
+func (p jsonDepthPolicy) check(event IngestEvent) (IngestEvent, error) {
+	if !p.enabled() {
+		return event, nil
+	}
+
+	fields := []struct {
+		name  string
+		value *any
+	}{
+		{"input", &event.Input},
+		{"output", &event.Output},
+		{"rawResponse", &event.RawResponse},
+	}
+	for _, f := range fields {
+		if !exceedsDepth(*f.value, p.maxDepth) {
+			continue
+		}
+		if !p.truncate {
+			return event, fmt.Errorf("%s is nested deeper than %d levels", f.name, p.maxDepth)
+		}
+		*f.value = truncateDepth(*f.value, p.maxDepth)
+	}
+
+	if exceedsDepth(event.Metadata, p.maxDepth) {
+		if !p.truncate {
+			return event, fmt.Errorf("metadata is nested deeper than %d levels", p.maxDepth)
+		}
+		event.Metadata = truncateDepth(event.Metadata, p.maxDepth).(map[string]any)
+	}
+	return event, nil
+}
+
+// exceedsDepth reports whether v nests more than depth objects/arrays deep.
+// It stops at the limit, so self-referencing values terminate.
+func exceedsDepth(v any, depth int) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		if depth == 0 {
+			return true
+		}
+		for _, child := range v {
+			if exceedsDepth(child, depth-1) {
+				return true
+			}
+		}
+	case []any:
+		if depth == 0 {
+			return true
+		}
+		for _, child := range v {
+			if exceedsDepth(child, depth-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truncateDepth returns a copy of v with objects/arrays below depth replaced
+// by truncatedJSONValue. v itself is not modified.
+func truncateDepth(v any, depth int) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if depth == 0 {
+			return truncatedJSONValue
+		}
+		out := make(map[string]any, len(v))
+		for k, child := range v {
+			out[k] = truncateDepth(child, depth-1)
+		}
+		return out
+	case []any:
+		if depth == 0 {
+			return truncatedJSONValue
+		}
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = truncateDepth(child, depth-1)
+		}
+		return out
+	}
+	return v
+}
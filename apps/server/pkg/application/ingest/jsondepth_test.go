@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+// nested returns depth levels of alternating objects and arrays, outermost an
+// object: {"next": [{"next": ...}]}
+func nested(depth int) any {
+	var v any = "leaf"
+	for i := 0; i < depth; i++ {
+		if (depth-1-i)%2 == 0 {
+			v = map[string]any{"next": v}
+		} else {
+			v = []any{v}
+		}
+	}
+	return v
+}
+
+func TestJSONDepthPolicy_Check(t *testing.T) {
+	reject := jsonDepthPolicy{maxDepth: 4}
+	truncate := jsonDepthPolicy{maxDepth: 4, truncate: true}
+
+	tests := []struct {
+		name    string
+		policy  jsonDepthPolicy
+		event   IngestEvent
+		wantErr string // substring; empty when the event is accepted
+	}{
+		{"at the limit", reject, IngestEvent{Input: nested(4), Metadata: nested(4).(map[string]any)}, ""},
+		{"input one over", reject, IngestEvent{Input: nested(5)}, "input is nested deeper than 4 levels"},
+		{"output one over", reject, IngestEvent{Output: nested(5)}, "output is nested deeper than 4 levels"},
+		{"metadata one over", reject, IngestEvent{Metadata: map[string]any{"a": nested(4)}}, "metadata is nested deeper than 4 levels"},
+		{"scalars", reject, IngestEvent{Input: "text", Output: 42}, ""},
+		{"truncated", truncate, IngestEvent{Input: nested(6), Metadata: map[string]any{"a": nested(4), "b": 1}}, ""},
+		{"disabled", jsonDepthPolicy{}, IngestEvent{Input: nested(100)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.check(tt.event)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.policy.enabled() && (exceedsDepth(got.Input, tt.policy.maxDepth) || exceedsDepth(got.Metadata, tt.policy.maxDepth)) {
+				t.Errorf("expected the event within %d levels, got %+v", tt.policy.maxDepth, got)
+			}
+		})
+	}
+
+	t.Run("truncation keeps the allowed levels", func(t *testing.T) {
+		event := IngestEvent{Metadata: map[string]any{"a": nested(4), "b": 1}}
+		got, _ := truncate.check(event)
+
+		// metadata(1) -> a(2) -> [](3) -> {}(4) -> the level-5 array is cut
+		inner := got.Metadata["a"].(map[string]any)["next"].([]any)[0].(map[string]any)["next"]
+		if inner != truncatedJSONValue {
+			t.Errorf("expected the too-deep value truncated, got %v", inner)
+		}
+		if got.Metadata["b"] != 1 {
+			t.Errorf("expected shallow values kept, got %v", got.Metadata)
+		}
+		if !exceedsDepth(event.Metadata, 4) {
+			t.Error("the caller's metadata was modified")
+		}
+	})
+}
+
+func TestIngest_MaxJSONDepth(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "depth", entity.ProjectSettings{})
+
+	event := func(traceID string, metadataDepth int) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanType: "tool", Name: "search", Status: "success",
+			Metadata: nested(metadataDepth).(map[string]any)}
+	}
+
+	t.Run("rejects too deep events and keeps the rest", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		svc.SetMaxJSONDepth(5, false)
+
+		traceID := uuid.New().String()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			event(traceID, 5),
+			event(traceID, 7),
+		}})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Success || resp.Processed != 1 || len(resp.Errors) != 1 || resp.Errors[0].Index != 1 {
+			t.Fatalf("expected event 1 rejected, got %+v", resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected only the shallow span, got %+v (%v)", trace, err)
+		}
+	})
+
+	t.Run("truncates too deep events", func(t *testing.T) {
+		svc := NewService(store, service.NewPricingCalculator())
+		svc.SetMaxJSONDepth(5, true)
+
+		traceID := uuid.New().String()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{event(traceID, 7)}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("expected the truncated span, got %+v (%v)", trace, err)
+		}
+		if exceedsDepth(trace.Spans[0].Metadata, 5) {
+			t.Errorf("expected stored metadata within 5 levels, got %v", trace.Spans[0].Metadata)
+		}
+	})
+}
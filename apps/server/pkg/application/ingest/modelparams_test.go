@@ -0,0 +1,95 @@
+package ingest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_ModelParams(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	project := newTestProject(t, store, "params", entity.ProjectSettings{})
+
+	tests := []struct {
+		name  string
+		event IngestEvent
+		want  map[string]any
+	}{
+		{
+			name: "openai request and responses echo",
+			event: IngestEvent{
+				SpanType: "llm", Provider: "openai", Model: "gpt-4o",
+				Input: map[string]any{
+					"messages":    []any{map[string]any{"role": "user", "content": "hi"}},
+					"temperature": 0.2, "max_completion_tokens": float64(256), "seed": float64(7),
+				},
+				RawResponse: map[string]any{
+					"object": "response", "top_p": 0.9, "temperature": 0.3,
+					"reasoning": map[string]any{"effort": "low"},
+					"usage":     map[string]any{"input_tokens": float64(5), "output_tokens": float64(3)},
+				},
+			},
+			want: map[string]any{"temperature": 0.3, "top_p": 0.9, "max_tokens": float64(256), "seed": float64(7), "reasoning_effort": "low"},
+		},
+		{
+			name: "gemini generation config",
+			event: IngestEvent{
+				SpanType: "llm", Provider: "gemini", Model: "gemini-1.5-pro",
+				Input: map[string]any{
+					"contents":         []any{},
+					"generationConfig": map[string]any{"temperature": 1.0, "topK": float64(40), "maxOutputTokens": float64(1024), "stopSequences": []any{"END"}},
+				},
+			},
+			want: map[string]any{"temperature": 1.0, "top_k": float64(40), "max_tokens": float64(1024), "stop": []any{"END"}},
+		},
+		{
+			name: "anthropic thinking budget, explicit params win",
+			event: IngestEvent{
+				SpanType: "llm", Provider: "anthropic", Model: "claude-3-7-sonnet",
+				Input: map[string]any{
+					"max_tokens": float64(2048), "temperature": 1.0,
+					"thinking": map[string]any{"type": "enabled", "budget_tokens": float64(1024)},
+				},
+				ModelParams: map[string]any{"temperature": 0.0, "variant": "b"},
+			},
+			want: map[string]any{"max_tokens": float64(2048), "temperature": 0.0, "thinking_budget": float64(1024), "variant": "b"},
+		},
+		{
+			name:  "tool inputs are not settings",
+			event: IngestEvent{SpanType: "tool", Name: "search", Input: map[string]any{"temperature": 0.5}},
+		},
+		{
+			name:  "explicit params on any span",
+			event: IngestEvent{SpanType: "retrieval", Name: "lookup", ModelParams: map[string]any{"top_k": float64(5)}},
+			want:  map[string]any{"top_k": float64(5)},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traceID := "params-trace-" + string(rune('a'+i))
+			tt.event.TraceID = traceID
+			resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{tt.event}})
+			if err != nil || !resp.Success {
+				t.Fatalf("ingest failed: %v %+v", err, resp)
+			}
+
+			trace, err := store.GetTrace(ctx, project.ID, traceID)
+			if err != nil {
+				t.Fatalf("GetTrace failed: %v", err)
+			}
+			if len(trace.Spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(trace.Spans))
+			}
+			if got := trace.Spans[0].ModelParams; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected model params %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
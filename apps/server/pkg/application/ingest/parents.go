@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// MetadataInvalidParent is the span metadata key holding the parentSpanId a
+// span was sent with when that parent was dropped because it made the span
+// its own ancestor. Such spans are stored as roots.
+const MetadataInvalidParent = "invalid_parent"
+
+// parentCycles finds the spans of a batch whose parent links loop back to
+// themselves, a span being its own parent included, and returns each of them
+// with the cycle it is on (e.g. "a -> b -> c -> a"). Only links between spans
+// of the batch are followed; a parent outside it ends the walk.
+func parentCycles(events []IngestEvent) map[string]string {
+	parents := make(map[string]string)
+	for _, event := range events {
+		if event.SpanID != "" && event.ParentSpanID != "" {
+			parents[event.SpanID] = event.ParentSpanID
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(parents))
+	cycles := make(map[string]string)
+	for _, event := range events {
+		if event.SpanID == "" || state[event.SpanID] != 0 {
+			continue
+		}
+		// Walk up from the span until a root, a parent outside the batch or
+		// a span already walked; one walked in this pass closes a cycle
+		var path []string
+		id, ok := event.SpanID, true
+		for ok && state[id] == 0 {
+			state[id] = visiting
+			path = append(path, id)
+			id, ok = parents[id]
+		}
+		if ok && state[id] == visiting {
+			for i, spanID := range path {
+				if spanID == id {
+					loop := append(path[i:len(path):len(path)], id)
+					description := strings.Join(loop, " -> ")
+					for _, member := range path[i:] {
+						cycles[member] = description
+					}
+					break
+				}
+			}
+		}
+		for _, spanID := range path {
+			state[spanID] = visited
+		}
+	}
+	return cycles
+}
+
+// checkParent rejects a span on a parent cycle (strict) or detaches it from
+// its parent, keeping the dropped parent in MetadataInvalidParent. The event's
+// metadata is copied rather than changed, as it belongs to the request.
+func checkParent(event IngestEvent, cycles map[string]string, strict bool) (IngestEvent, error) {
+	cycle, ok := cycles[event.SpanID]
+	if !ok || event.ParentSpanID == "" {
+		return event, nil
+	}
+	if strict {
+		if event.ParentSpanID == event.SpanID {
+			return event, fmt.Errorf("span %q is its own parent", event.SpanID)
+		}
+		return event, fmt.Errorf("span %q is on a parent cycle: %s", event.SpanID, cycle)
+	}
+
+	metadata := make(map[string]any, len(event.Metadata)+1)
+	maps.Copy(metadata, event.Metadata)
+	metadata[MetadataInvalidParent] = event.ParentSpanID
+	event.Metadata = metadata
+	event.ParentSpanID = ""
+	return event, nil
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_ParentCycles(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	// Span and trace IDs are unique across projects: prefix them per case
+	batch := func(prefix string) []IngestEvent {
+		events := []IngestEvent{
+			{SpanID: "ok", SpanType: "agent", Name: "agent", Status: "success"},
+			{SpanID: "self", ParentSpanID: "self", SpanType: "tool", Name: "self", Status: "success"},
+			{SpanID: "a", ParentSpanID: "c", SpanType: "tool", Name: "a", Status: "success"},
+			{SpanID: "b", ParentSpanID: "a", SpanType: "tool", Name: "b", Status: "success"},
+			{SpanID: "c", ParentSpanID: "b", SpanType: "tool", Name: "c", Status: "success"},
+			{SpanID: "child", ParentSpanID: "a", SpanType: "llm", Name: "child", Status: "success"},
+		}
+		for i := range events {
+			events[i].TraceID = prefix + "/trace"
+			events[i].SpanID = prefix + "/" + events[i].SpanID
+			events[i].ParentSpanID = prefix + "/" + events[i].ParentSpanID
+			if events[i].ParentSpanID == prefix+"/" {
+				events[i].ParentSpanID = ""
+			}
+		}
+		return events
+	}
+
+	t.Run("cycles found in batch", func(t *testing.T) {
+		cycles := parentCycles(batch("x"))
+		want := map[string]string{
+			"x/self": "x/self -> x/self",
+			"x/a":    "x/a -> x/c -> x/b -> x/a",
+			"x/b":    "x/a -> x/c -> x/b -> x/a",
+			"x/c":    "x/a -> x/c -> x/b -> x/a",
+		}
+		if fmt.Sprint(cycles) != fmt.Sprint(want) {
+			t.Errorf("expected cycles %v, got %v", want, cycles)
+		}
+	})
+
+	t.Run("stored as flagged roots", func(t *testing.T) {
+		project := newTestProject(t, store, "lenient", entity.ProjectSettings{})
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: batch("lenient")})
+		if err != nil || !resp.Success || resp.Processed != 6 {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, "lenient/trace")
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		wantParents := map[string]string{"ok": "", "self": "", "a": "", "b": "", "c": "", "child": "lenient/a"}
+		wantFlags := map[string]any{"self": "lenient/self", "a": "lenient/c", "b": "lenient/a", "c": "lenient/b"}
+		for _, span := range trace.Spans {
+			name := span.Name
+			parent := ""
+			if span.ParentSpanID != nil {
+				parent = *span.ParentSpanID
+			}
+			if parent != wantParents[name] {
+				t.Errorf("span %s: expected parent %q, got %q", name, wantParents[name], parent)
+			}
+			if got := span.Metadata[MetadataInvalidParent]; got != wantFlags[name] {
+				t.Errorf("span %s: expected %s %v, got %v", name, MetadataInvalidParent, wantFlags[name], got)
+			}
+		}
+		if len(trace.Spans) != 6 {
+			t.Errorf("expected 6 spans, got %d", len(trace.Spans))
+		}
+	})
+
+	t.Run("rejected in strict projects", func(t *testing.T) {
+		project := newTestProject(t, store, "strict", entity.ProjectSettings{StrictSpanTypes: true})
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: batch("strict")})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Success || resp.Processed != 2 {
+			t.Errorf("expected 2 events processed, got %+v", resp)
+		}
+		want := []IngestError{
+			{Index: 1, Message: `span "strict/self" is its own parent`},
+			{Index: 2, Message: `span "strict/a" is on a parent cycle: strict/a -> strict/c -> strict/b -> strict/a`},
+			{Index: 3, Message: `span "strict/b" is on a parent cycle: strict/a -> strict/c -> strict/b -> strict/a`},
+			{Index: 4, Message: `span "strict/c" is on a parent cycle: strict/a -> strict/c -> strict/b -> strict/a`},
+		}
+		if fmt.Sprint(resp.Errors) != fmt.Sprint(want) {
+			t.Errorf("expected errors %v, got %v", want, resp.Errors)
+		}
+	})
+}
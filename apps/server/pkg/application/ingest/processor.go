@@ -5,30 +5,131 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/application/webhook"
 	"github.com/lelemon/server/pkg/domain/entity"
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/domain/service"
 )
 
+// MetadataUnpricedModel is the span metadata flag set when the span's model is
+// missing from the pricing table (its cost was recorded as $0)
+const MetadataUnpricedModel = "unpriced_model"
+
+// MetadataCostOverride is the span metadata flag set when the event carried an
+// explicit costUsd. That cost is stored as sent and never recomputed.
+const MetadataCostOverride = "cost_override"
+
 // EventProcessor handles the core logic of converting events to spans and storing them.
 // This is the single source of truth for event processing, used by both sync and async paths.
 type EventProcessor struct {
-	store   repository.Store
-	pricing *service.PricingCalculator
+	store     repository.Store
+	pricing   *service.PricingCalculator
+	dedup     *spanDeduper
+	schemas   *toolSchemaCache
+	redactors *redactorCache
+	traces    *traceTotalsTracker
+	usage     UsageRecorder       // optional
+	webhooks  *webhook.Dispatcher // optional
+
+	// transforms run in order on every span built from an event, after the
+	// built-in extraction and pricing stages (see eventToSpan); they are the
+	// ones registered with AddSpanTransforms
+	transforms []SpanTransform
+}
+
+// SpanTransform is one stage of the ingest pipeline. It receives the span
+// built from an event (IDs, type, name, input, status, timing, metadata
+// already set) and fills in or rewrites fields in place, e.g. to redact PII,
+// add metadata or apply a custom cost rule. Stages must be safe for
+// concurrent use: async ingest runs them from several workers.
+type SpanTransform func(span *entity.Span, event IngestEvent)
+
+// UsageRecorder receives the traces and spans written by each processed batch,
+// e.g. for billing. It is called once per batch per project, so implementations
+// should be cheap (buffer in memory and persist in the background).
+type UsageRecorder interface {
+	RecordUsage(projectID string, traces, spans int)
+}
+
+// traceBatch collects what a batch writes: the traces to create and all
+// spans go to the store in one CreateTracesWithSpans call, then trace
+// statuses, ended traces' metadata, runaway flags and stored agent spans'
+// subtree costs are updated
+type traceBatch struct {
+	traces       []*entity.Trace
+	spans        []entity.Span
+	statuses     map[string]entity.TraceStatus // trace ID -> status set after the write
+	ended        map[string]map[string]any     // stored trace ID -> metadata set by its trace.end event
+	runaways     []runawayTrace
+	subtreeCosts map[string]float64 // stored agent span ID -> subtree cost set after the write
+	hashes       map[int]string     // index in spans -> content hash recorded for dedup once stored
+}
+
+// runawayTrace is a trace that crossed a trace limit with this batch
+type runawayTrace struct {
+	traceID  string
+	metadata map[string]any // the trace's current metadata, to add the flag to
+	reason   string
 }
 
 // NewEventProcessor creates a new event processor
 func NewEventProcessor(store repository.Store, pricing *service.PricingCalculator) *EventProcessor {
-	return &EventProcessor{
-		store:   store,
-		pricing: pricing,
+	p := &EventProcessor{
+		store:     store,
+		pricing:   pricing,
+		dedup:     newSpanDeduper(dedupCacheSize),
+		schemas:   newToolSchemaCache(),
+		redactors: newRedactorCache(),
+		traces:    newTraceTotalsTracker(traceTotalsCacheSize),
+	}
+	return p
+}
+
+// AddSpanTransforms appends stages to the pipeline, after the built-in
+// extraction and pricing stages
+func (p *EventProcessor) AddSpanTransforms(transforms ...SpanTransform) {
+	p.transforms = append(p.transforms, transforms...)
+}
+
+// ProcessOptions carries the project settings applied while processing a batch
+type ProcessOptions struct {
+	DedupWindow       time.Duration                   // drop spans whose content was already ingested within the window; 0 disables
+	ToolSchemas       map[string]any                  // tool name -> JSON Schema for its arguments
+	Redaction         *entity.RedactionSettings       // PII redaction; nil or disabled stores data as sent
+	IndexedAttributes []string                        // metadata keys promoted to span attributes
+	SampleRate        float64                         // share of traces kept (1 keeps all); recorded on sampled traces
+	TraceLimits       *entity.TraceLimitSettings      // span count and cost ceilings per trace; nil disables
+	TraceNameSources  []string                        // fallback chain naming traces without an agent span
+	TraceErrorRule    string                          // which failed spans error their trace
+	DefaultSpanStatus entity.SpanStatus               // status of events sent without one
+	Webhook           *webhook.Subscription           // trace events to deliver; nil sends none
+	PricingOverrides  map[string]service.ModelPricing // model rates taking precedence over the pricing table
+}
+
+// NewProcessOptions derives the processing options from a project's settings
+func NewProcessOptions(settings entity.ProjectSettings) ProcessOptions {
+	return ProcessOptions{
+		DedupWindow:       dedupWindow(settings),
+		ToolSchemas:       settings.ToolSchemas,
+		Redaction:         settings.Redaction,
+		IndexedAttributes: settings.IndexedAttributes,
+		SampleRate:        sampleRate(settings),
+		TraceLimits:       settings.TraceLimits,
+		TraceNameSources:  traceNameSources(settings),
+		TraceErrorRule:    traceErrorRule(settings),
+		DefaultSpanStatus: defaultSpanStatus(settings),
+		Webhook:           webhook.SubscriptionFor(settings),
+		PricingOverrides:  service.ProjectPricingOverrides(settings),
 	}
 }
 
 // ProcessEvents processes a batch of events for a project
-func (p *EventProcessor) ProcessEvents(ctx context.Context, projectID string, events []IngestEvent) error {
+func (p *EventProcessor) ProcessEvents(ctx context.Context, projectID string, events []IngestEvent, opts ProcessOptions) error {
 	if len(events) == 0 {
 		return nil
 	}
@@ -45,89 +146,322 @@ func (p *EventProcessor) ProcessEvents(ctx context.Context, projectID string, ev
 		}
 	}
 
-	// Process trace groups
+	batch := traceBatch{
+		statuses:     make(map[string]entity.TraceStatus),
+		ended:        make(map[string]map[string]any),
+		subtreeCosts: make(map[string]float64),
+		hashes:       make(map[int]string),
+	}
+
+	// Prepare trace groups
 	for traceID, groupEvents := range traceGroups {
-		if err := p.processTraceGroup(ctx, projectID, traceID, groupEvents); err != nil {
+		if err := p.prepareTraceGroup(ctx, projectID, traceID, groupEvents, opts, &batch); err != nil {
 			slog.Error("failed to process trace group", "trace_id", traceID, "error", err)
 		}
 	}
 
-	// Process session groups (legacy)
+	// Prepare session groups (legacy)
 	for sessionID, groupEvents := range sessionGroups {
-		if err := p.processSessionGroup(ctx, projectID, sessionID, groupEvents); err != nil {
-			slog.Error("failed to process session group", "session_id", sessionID, "error", err)
+		p.prepareSessionGroup(projectID, sessionID, groupEvents, opts, &batch)
+	}
+
+	return p.writeBatch(ctx, projectID, &batch, opts.Webhook)
+}
+
+// SpanWriteError is returned by ProcessEvents when some of the batch's spans
+// could not be stored; the rest of the batch was, so it must not be retried
+type SpanWriteError struct {
+	Failed []FailedSpan
+}
+
+func (e *SpanWriteError) Error() string {
+	return fmt.Sprintf("%d spans could not be stored", len(e.Failed))
+}
+
+// writeBatch stores the batch's traces and spans in one call, so spans never
+// reference a trace row that doesn't exist yet whatever order they arrived
+// in, then applies the trace end, status, runaway and subtree cost updates and
+// dispatches their webhook events to sub. When that write fails, the traces
+// are written on their own and the spans one by one (see writePartial), and
+// the spans that still fail are returned in a *SpanWriteError. Only the
+// spans stored are recorded for dedup.
+func (p *EventProcessor) writeBatch(ctx context.Context, projectID string, batch *traceBatch, sub *webhook.Subscription) error {
+	if len(batch.traces) == 0 && len(batch.spans) == 0 && len(batch.ended) == 0 {
+		return nil
+	}
+
+	// A batch of trace.end events for stored traces writes no rows
+	var failed []FailedSpan
+	if len(batch.traces) > 0 || len(batch.spans) > 0 {
+		var errs []entity.SpanWriteError
+		created, err := p.store.CreateTracesWithSpans(ctx, projectID, batch.traces, batch.spans)
+		if err != nil && len(batch.spans) > 0 {
+			slog.Warn("batch write failed, writing spans one by one", "project_id", projectID, "spans", len(batch.spans), "error", err)
+			created, errs, err = p.writePartial(ctx, projectID, batch)
+		}
+		if err != nil {
+			return fmt.Errorf("create traces and spans: %w", err)
+		}
+		for _, e := range errs {
+			span := batch.spans[e.Index]
+			slog.Error("failed to store span", "project_id", projectID, "trace_id", span.TraceID, "span_id", span.ID, "error", e.Err)
+			failed = append(failed, FailedSpan{TraceID: span.TraceID, SpanID: span.ID, Message: e.Err.Error()})
+			delete(batch.hashes, e.Index)
+		}
+		if len(batch.hashes) > 0 {
+			hashes := make([]string, 0, len(batch.hashes))
+			for _, hash := range batch.hashes {
+				hashes = append(hashes, hash)
+			}
+			p.dedup.record(projectID, hashes, time.Now())
+		}
+		if stored := len(batch.spans) - len(failed); p.usage != nil && (created > 0 || stored > 0) {
+			p.usage.RecordUsage(projectID, created, stored)
 		}
 	}
 
+	for traceID, metadata := range batch.ended {
+		if err := p.store.UpdateTrace(ctx, projectID, traceID, entity.TraceUpdate{Metadata: metadata}); err != nil {
+			slog.Error("failed to finalize trace", "trace_id", traceID, "error", err)
+		}
+	}
+	for _, r := range batch.runaways {
+		if err := p.flagRunaway(ctx, projectID, r.traceID, r.metadata, r.reason); err != nil {
+			slog.Error("failed to flag runaway trace", "trace_id", r.traceID, "error", err)
+		}
+		if r.reason == "maxCostUsd" {
+			p.dispatch(sub, webhook.Event{
+				Event: entity.WebhookEventBudgetExceeded, ProjectID: projectID, TraceID: r.traceID,
+				Text: fmt.Sprintf("Lelemon: trace %s went over its cost limit", r.traceID),
+			})
+		}
+	}
+	for traceID, status := range batch.statuses {
+		if err := p.store.UpdateTraceStatus(ctx, projectID, traceID, status); err != nil {
+			slog.Error("failed to update trace status", "trace_id", traceID, "error", err)
+			continue
+		}
+		event := entity.WebhookEventTraceCompleted
+		if status == entity.TraceStatusError {
+			event = entity.WebhookEventTraceError
+		}
+		p.dispatch(sub, webhook.Event{
+			Event: event, ProjectID: projectID, TraceID: traceID, Status: string(status),
+			Text: fmt.Sprintf("Lelemon: trace %s %s", traceID, status),
+		})
+	}
+	if len(batch.subtreeCosts) > 0 {
+		if _, err := p.store.UpdateSpanSubtreeCosts(ctx, projectID, batch.subtreeCosts); err != nil {
+			slog.Error("failed to update span subtree costs", "project_id", projectID, "error", err)
+		}
+	}
+	if len(failed) > 0 {
+		return &SpanWriteError{Failed: failed}
+	}
 	return nil
 }
 
-// processTraceGroup adds spans to an existing trace or creates it with the specified ID
-func (p *EventProcessor) processTraceGroup(ctx context.Context, projectID, traceID string, events []IngestEvent) error {
+// writePartial writes the batch's traces, then its spans with
+// CreateSpansPartial, so one span the store rejects (e.g. a duplicate ID)
+// doesn't lose the others. It returns the number of traces created and the
+// spans that failed.
+func (p *EventProcessor) writePartial(ctx context.Context, projectID string, batch *traceBatch) (int, []entity.SpanWriteError, error) {
+	created, err := p.store.CreateTracesWithSpans(ctx, projectID, batch.traces, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	errs, err := p.store.CreateSpansPartial(ctx, projectID, batch.spans)
+	if err != nil {
+		return created, nil, err
+	}
+	return created, errs, nil
+}
+
+// dispatch queues a webhook event when a dispatcher is set
+func (p *EventProcessor) dispatch(sub *webhook.Subscription, event webhook.Event) {
+	if p.webhooks != nil {
+		p.webhooks.Dispatch(sub, event)
+	}
+}
+
+// prepareTraceGroup adds a group's spans to the batch, plus the trace with the
+// specified ID when it doesn't exist yet. The group's last trace.end event,
+// if any, sets the trace's status and output; without one the status is
+// inferred from the spans (see nextTraceStatus), unless an earlier trace.end
+// already ended the trace.
+func (p *EventProcessor) prepareTraceGroup(ctx context.Context, projectID, traceID string, events []IngestEvent, opts ProcessOptions, batch *traceBatch) error {
 	if len(events) == 0 {
 		return nil
 	}
+	events, ends := splitTraceEnds(events)
 
-	// Get or create trace
 	existing, err := p.store.GetTrace(ctx, projectID, traceID)
 	if err != nil && !errors.Is(err, entity.ErrNotFound) {
 		return fmt.Errorf("get trace: %w", err)
 	}
 
-	if existing == nil {
-		trace := p.buildTrace(projectID, traceID, events)
-		if err := p.store.CreateTrace(ctx, trace); err != nil {
-			return fmt.Errorf("create trace: %w", err)
+	var traceMetadata map[string]any
+	if existing != nil {
+		traceMetadata = existing.Metadata
+	} else {
+		traceEvents := events
+		if len(traceEvents) == 0 {
+			traceEvents = ends
+		}
+		trace := p.buildTrace(projectID, traceID, traceEvents, opts.TraceNameSources)
+		if opts.SampleRate < 1 {
+			trace.Metadata[MetadataSampleRate] = opts.SampleRate
+		}
+		p.redactTrace(trace, opts.Redaction)
+		batch.traces = append(batch.traces, trace)
+		traceMetadata = trace.Metadata
+	}
+
+	// Record the end before runaway flags, which rewrite the same metadata
+	var end *IngestEvent
+	if len(ends) > 0 {
+		end = &ends[len(ends)-1]
+		ended := make(map[string]any, len(traceMetadata)+2)
+		for k, v := range traceMetadata {
+			ended[k] = v
+		}
+		finalizeTrace(ended, *end, time.Now())
+		if end.Output != nil {
+			p.redactTraceOutput(projectID, ended, opts.Redaction)
+		}
+		if existing != nil {
+			batch.ended[traceID] = ended
+		} else {
+			batch.traces[len(batch.traces)-1].Metadata = ended
 		}
+		traceMetadata = ended
 	}
 
-	// Create spans
-	spans, hasErrors := p.buildSpans(traceID, events)
-	if err := p.store.CreateSpans(ctx, spans); err != nil {
-		return fmt.Errorf("create spans: %w", err)
+	// Skip content already seen within the dedup window. Session groups
+	// always get a fresh trace ID, so only explicit traces can repeat.
+	spans := p.transformSpans(projectID, traceID, events, opts)
+	if opts.DedupWindow > 0 {
+		var hashes []string
+		spans, hashes = p.dedup.filter(projectID, spans, opts.DedupWindow)
+		for i, hash := range hashes {
+			batch.hashes[len(batch.spans)+i] = hash
+		}
 	}
 
-	// Update status if errors
-	if hasErrors {
-		return p.store.UpdateTraceStatus(ctx, projectID, traceID, entity.TraceStatusError)
+	if opts.TraceLimits != nil {
+		if reason := p.traces.add(projectID, traceID, existing, spans, opts.TraceLimits); reason != "" {
+			batch.runaways = append(batch.runaways, runawayTrace{traceID: traceID, metadata: traceMetadata, reason: reason})
+		}
 	}
 
+	current := entity.TraceStatusActive
+	if existing != nil {
+		current = existing.Status
+	}
+	status := nextTraceStatus(current, spans, opts.TraceErrorRule)
+	switch {
+	case end != nil:
+		status = traceEndStatus(*end)
+	case existing != nil && explicitlyEnded(existing.Metadata):
+		status = current
+	}
+	if status != current {
+		batch.statuses[traceID] = status
+	}
+	if status != entity.TraceStatusActive {
+		var stored []entity.Span
+		if existing != nil {
+			stored = existing.Spans
+		}
+		setSubtreeCosts(stored, spans, batch)
+	}
+	batch.spans = append(batch.spans, spans...)
 	return nil
 }
 
-// processSessionGroup creates a new trace for a session (legacy behavior)
-func (p *EventProcessor) processSessionGroup(ctx context.Context, projectID, sessionID string, events []IngestEvent) error {
-	if len(events) == 0 {
-		return nil
+// setSubtreeCosts sets the subtree cost of the agent spans of a trace that is
+// completed or errored once this batch is written, from its stored spans and
+// the batch's. New agent spans get it before they are written; stored ones
+// whose cost changed (e.g. with late spans) are queued in batch.subtreeCosts.
+func setSubtreeCosts(stored, spans []entity.Span, batch *traceBatch) {
+	isNew := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		isNew[span.ID] = true
+	}
+	all := make([]entity.Span, 0, len(stored)+len(spans))
+	for _, span := range stored {
+		if !isNew[span.ID] {
+			all = append(all, span)
+		}
 	}
+	all = append(all, spans...)
 
-	// Create trace (generates new ID)
-	trace := p.buildTrace(projectID, "", events)
-	if sessionID != "" {
-		trace.SessionID = &sessionID
+	costs := entity.SubtreeCosts(all)
+	if len(costs) == 0 {
+		return
+	}
+	for i := range spans {
+		if cost, ok := costs[spans[i].ID]; ok {
+			spans[i].SubtreeCostUSD = &cost
+		}
 	}
+	for _, span := range stored {
+		cost, ok := costs[span.ID]
+		if !ok || isNew[span.ID] {
+			continue
+		}
+		if span.SubtreeCostUSD == nil || *span.SubtreeCostUSD != cost {
+			batch.subtreeCosts[span.ID] = cost
+		}
+	}
+}
 
-	if err := p.store.CreateTrace(ctx, trace); err != nil {
-		return fmt.Errorf("create trace: %w", err)
+// flagRunaway records in the trace's metadata that it exceeded a trace limit
+func (p *EventProcessor) flagRunaway(ctx context.Context, projectID, traceID string, metadata map[string]any, reason string) error {
+	updated := make(map[string]any, len(metadata)+2)
+	for k, v := range metadata {
+		updated[k] = v
 	}
+	updated[MetadataRunaway] = true
+	updated[MetadataRunawayReason] = reason
 
-	// Create spans
-	spans, hasErrors := p.buildSpans(trace.ID, events)
-	if err := p.store.CreateSpans(ctx, spans); err != nil {
-		return fmt.Errorf("create spans: %w", err)
+	slog.Warn("runaway trace", "project_id", projectID, "trace_id", traceID, "limit", reason)
+	if err := p.store.UpdateTrace(ctx, projectID, traceID, entity.TraceUpdate{Metadata: updated}); err != nil {
+		return fmt.Errorf("flag runaway trace: %w", err)
+	}
+	return nil
+}
+
+// prepareSessionGroup adds a new trace for a session, with its spans, to the
+// batch (legacy behavior)
+func (p *EventProcessor) prepareSessionGroup(projectID, sessionID string, events []IngestEvent, opts ProcessOptions, batch *traceBatch) {
+	if len(events) == 0 {
+		return
 	}
 
-	// Update status
-	status := entity.TraceStatusCompleted
-	if hasErrors {
-		status = entity.TraceStatusError
+	trace := p.buildTrace(projectID, uuid.New().String(), events, opts.TraceNameSources)
+	if sessionID != "" {
+		trace.SessionID = &sessionID
 	}
+	p.redactTrace(trace, opts.Redaction)
+	batch.traces = append(batch.traces, trace)
 
-	return p.store.UpdateTraceStatus(ctx, projectID, trace.ID, status)
+	spans := p.transformSpans(projectID, trace.ID, events, opts)
+
+	// A session group is the whole trace: no later batch can end it
+	status := nextTraceStatus(entity.TraceStatusActive, spans, opts.TraceErrorRule)
+	if status == entity.TraceStatusActive {
+		status = entity.TraceStatusCompleted
+	}
+	batch.statuses[trace.ID] = status
+	setSubtreeCosts(nil, spans, batch)
+	batch.spans = append(batch.spans, spans...)
 }
 
-// buildTrace creates a trace entity from events
-func (p *EventProcessor) buildTrace(projectID, traceID string, events []IngestEvent) *entity.Trace {
+// buildTrace creates a trace entity from events. A trace without a named
+// agent span or _traceName metadata is named from nameSources (see
+// deriveTraceName).
+func (p *EventProcessor) buildTrace(projectID, traceID string, events []IngestEvent, nameSources []string) *entity.Trace {
 	firstEvent := events[0]
 
 	trace := &entity.Trace{
@@ -165,6 +499,11 @@ func (p *EventProcessor) buildTrace(projectID, traceID string, events []IngestEv
 			trace.Name = &name
 		}
 	}
+	if trace.Name == nil {
+		if name := deriveTraceName(events, nameSources); name != "" {
+			trace.Name = &name
+		}
+	}
 	if trace.SessionID == nil && firstEvent.SessionID != "" {
 		trace.SessionID = &firstEvent.SessionID
 	}
@@ -174,6 +513,12 @@ func (p *EventProcessor) buildTrace(projectID, traceID string, events []IngestEv
 	if trace.Tags == nil && firstEvent.Tags != nil {
 		trace.Tags = firstEvent.Tags
 	}
+	for _, event := range events {
+		if event.ParentTraceID != "" {
+			trace.ParentTraceID = &event.ParentTraceID
+			break
+		}
+	}
 	if firstEvent.Input != nil {
 		trace.Metadata["input"] = firstEvent.Input
 	}
@@ -186,25 +531,47 @@ func (p *EventProcessor) buildTrace(projectID, traceID string, events []IngestEv
 	return trace
 }
 
-// buildSpans converts events to spans
-func (p *EventProcessor) buildSpans(traceID string, events []IngestEvent) ([]entity.Span, bool) {
+// transformSpans is the ingest transform: it converts events into the spans
+// stored under traceID (provider parsing, tokens, cost, subtype, tool argument
+// validation) without touching the store. Dedup is not applied here.
+// Redaction runs after every pipeline stage so it also covers fields that
+// custom stages fill in; attributes are indexed last, from the final metadata.
+func (p *EventProcessor) transformSpans(projectID, traceID string, events []IngestEvent, opts ProcessOptions) []entity.Span {
+	spans := p.buildSpans(traceID, events, opts.DefaultSpanStatus, p.pricing.WithOverrides(opts.PricingOverrides))
+	p.validateToolArgs(projectID, spans, opts.ToolSchemas)
+	p.redactSpans(projectID, spans, opts.Redaction)
+	p.indexAttributes(spans, opts.IndexedAttributes)
+	return spans
+}
+
+// buildSpans converts events to spans, priced by pricing. Events without a
+// status get defaultStatus, when set, instead of EventToSpan's success.
+func (p *EventProcessor) buildSpans(traceID string, events []IngestEvent, defaultStatus entity.SpanStatus, pricing *service.PricingCalculator) []entity.Span {
 	spans := make([]entity.Span, 0, len(events))
-	hasErrors := false
 
-	for _, event := range events {
-		span := p.EventToSpan(traceID, event)
-		spans = append(spans, span)
-		if event.Status == "error" {
-			hasErrors = true
+	for i, event := range events {
+		span := p.eventToSpan(traceID, event, pricing)
+		if event.Status == "" && defaultStatus != "" {
+			span.Status = defaultStatus
 		}
+		if event.Sequence == nil {
+			span.Sequence = i
+		}
+		spans = append(spans, span)
 	}
 
-	return spans, hasErrors
+	return spans
 }
 
 // EventToSpan converts an IngestEvent to a Span entity.
 // This is the SINGLE implementation used by both sync and async paths.
 func (p *EventProcessor) EventToSpan(traceID string, event IngestEvent) entity.Span {
+	return p.eventToSpan(traceID, event, p.pricing)
+}
+
+// eventToSpan is EventToSpan pricing the span with pricing, which carries the
+// project's overrides
+func (p *EventProcessor) eventToSpan(traceID string, event IngestEvent, pricing *service.PricingCalculator) entity.Span {
 	now := time.Now()
 	startedAt := now
 	if event.Timestamp != nil {
@@ -212,10 +579,7 @@ func (p *EventProcessor) EventToSpan(traceID string, event IngestEvent) entity.S
 	}
 
 	spanType := parseSpanType(event.SpanType)
-	spanStatus := entity.SpanStatusSuccess
-	if event.Status == "error" {
-		spanStatus = entity.SpanStatusError
-	}
+	spanStatus := parseSpanStatus(event.Status)
 
 	name := coalesce(event.Name, event.Model, string(spanType))
 	metadata := p.buildMetadata(event)
@@ -251,13 +615,41 @@ func (p *EventProcessor) EventToSpan(traceID string, event IngestEvent) entity.S
 	if event.FirstTokenMs != nil {
 		span.FirstTokenMs = event.FirstTokenMs
 	}
+	if len(event.SpanTags) > 0 {
+		span.Tags = event.SpanTags
+	}
+	span.Links = spanLinks(event.Links, traceID)
+	if event.Sequence != nil {
+		span.Sequence = *event.Sequence
+	}
 
-	// Process response data (rawResponse or legacy fields)
-	p.processResponseData(&span, event, spanType)
+	p.extractResponse(&span, event)
+	p.extractModelParams(&span, event)
+	p.extractVerdict(&span, event)
+	p.priceSpan(&span, event, pricing)
+	for _, transform := range p.transforms {
+		transform(&span, event)
+	}
 
 	return span
 }
 
+// spanLinks returns the links that name a span, defaulting their trace to
+// traceID, or nil when none do
+func spanLinks(links []entity.SpanLink, traceID string) []entity.SpanLink {
+	var kept []entity.SpanLink
+	for _, link := range links {
+		if link.SpanID == "" {
+			continue
+		}
+		if link.TraceID == "" {
+			link.TraceID = traceID
+		}
+		kept = append(kept, link)
+	}
+	return kept
+}
+
 // buildMetadata constructs the metadata map for a span
 func (p *EventProcessor) buildMetadata(event IngestEvent) map[string]any {
 	metadata := make(map[string]any)
@@ -287,17 +679,84 @@ func (p *EventProcessor) buildMetadata(event IngestEvent) map[string]any {
 	return metadata
 }
 
-// processResponseData extracts output, tokens, and other data from the event
-func (p *EventProcessor) processResponseData(span *entity.Span, event IngestEvent, spanType entity.SpanType) {
+// extractResponse is the built-in extraction stage: output, tokens, stop
+// reason, subtype and tool uses, from rawResponse or the legacy fields
+func (p *EventProcessor) extractResponse(span *entity.Span, event IngestEvent) {
 	if event.RawResponse != nil {
-		p.processRawResponse(span, event, spanType)
+		p.processRawResponse(span, event)
 	} else {
-		p.processLegacyFields(span, event, spanType)
+		p.processLegacyFields(span, event, span.Type)
+	}
+}
+
+// extractModelParams is the built-in stage recording the generation settings:
+// those an LLM span's request (input) and rawResponse carry, overridden by the
+// event's explicit modelParams
+func (p *EventProcessor) extractModelParams(span *entity.Span, event IngestEvent) {
+	var params map[string]any
+	if span.Type == entity.SpanTypeLLM {
+		params = service.ExtractModelParams(event.Input, event.RawResponse)
+	}
+	for k, v := range event.ModelParams {
+		if params == nil {
+			params = make(map[string]any, len(event.ModelParams))
+		}
+		params[k] = v
+	}
+	span.ModelParams = params
+}
+
+// priceSpan is the built-in pricing stage. Cost is calculated from disjoint
+// token buckets so cache/reasoning are priced at their own rates (and never
+// double-counted against input/output). A rawResponse that could not be
+// parsed leaves the span unpriced. An explicit costUsd on the event is stored
+// as sent instead (see MetadataCostOverride), as is the sum of its costDetails
+// when costUsd is absent (see entity.SpanMetadataCostDetails).
+func (p *EventProcessor) priceSpan(span *entity.Span, event IngestEvent, pricing *service.PricingCalculator) {
+	if len(event.CostDetails) > 0 {
+		details := make(map[string]any, len(event.CostDetails))
+		for class, amount := range event.CostDetails {
+			details[class] = amount
+		}
+		if span.Metadata == nil {
+			span.Metadata = make(map[string]any)
+		}
+		span.Metadata[entity.SpanMetadataCostDetails] = details
+		if event.CostUSD == nil {
+			event.CostUSD = sumCostDetails(event.CostDetails)
+		}
+	}
+
+	// An explicit cost takes precedence over the computed one, for any span type
+	if event.CostUSD != nil {
+		cost := *event.CostUSD
+		span.CostUSD = &cost
+		if span.Metadata == nil {
+			span.Metadata = make(map[string]any)
+		}
+		span.Metadata[MetadataCostOverride] = true
+		return
 	}
+	if span.Type != entity.SpanTypeLLM || event.Model == "" {
+		return
+	}
+	if event.RawResponse != nil && span.InputTokens == nil {
+		return
+	}
+
+	usage := service.NormalizeTokenUsage(
+		event.Provider,
+		derefInt(span.InputTokens),
+		derefInt(span.OutputTokens),
+		derefInt(span.CacheReadTokens),
+		derefInt(span.CacheWriteTokens),
+		derefInt(span.ReasoningTokens),
+	)
+	p.setCost(span, event.Model, usage, pricing)
 }
 
 // processRawResponse parses rawResponse and populates span fields
-func (p *EventProcessor) processRawResponse(span *entity.Span, event IngestEvent, spanType entity.SpanType) {
+func (p *EventProcessor) processRawResponse(span *entity.Span, event IngestEvent) {
 	parsed := service.ParseProviderResponse(event.Provider, event.RawResponse)
 	if parsed == nil {
 		slog.Warn("rawResponse parsing returned nil", "provider", event.Provider)
@@ -314,21 +773,6 @@ func (p *EventProcessor) processRawResponse(span *entity.Span, event IngestEvent
 	span.Thinking = parsed.Thinking
 	span.SubType = parsed.SubType
 	span.ToolUses = parsed.ToolUses
-
-	// Calculate cost from disjoint token buckets so cache/reasoning are priced
-	// at their own rates (and never double-counted against input/output).
-	if spanType == entity.SpanTypeLLM && event.Model != "" {
-		usage := service.NormalizeTokenUsage(
-			event.Provider,
-			parsed.InputTokens,
-			parsed.OutputTokens,
-			derefInt(parsed.CacheReadTokens),
-			derefInt(parsed.CacheWriteTokens),
-			derefInt(parsed.ReasoningTokens),
-		)
-		cost := p.pricing.CalculateCostBreakdown(event.Model, usage).Total
-		span.CostUSD = &cost
-	}
 }
 
 // processLegacyFields uses event fields directly (for backward compatibility)
@@ -347,20 +791,6 @@ func (p *EventProcessor) processLegacyFields(span *entity.Span, event IngestEven
 		span.Thinking = &event.Thinking
 	}
 
-	// Calculate cost from disjoint token buckets (see processRawResponse).
-	if spanType == entity.SpanTypeLLM && event.Model != "" {
-		usage := service.NormalizeTokenUsage(
-			event.Provider,
-			derefInt(event.InputTokens),
-			derefInt(event.OutputTokens),
-			derefInt(event.CacheReadTokens),
-			derefInt(event.CacheWriteTokens),
-			derefInt(event.ReasoningTokens),
-		)
-		cost := p.pricing.CalculateCostBreakdown(event.Model, usage).Total
-		span.CostUSD = &cost
-	}
-
 	// Extract subtype and tool uses from output
 	if spanType == entity.SpanTypeLLM {
 		subType := determineLLMSubType(event.Output)
@@ -372,27 +802,92 @@ func (p *EventProcessor) processLegacyFields(span *entity.Span, event IngestEven
 	}
 }
 
+// setCost prices the span's usage and flags spans whose model has no pricing,
+// so their $0 cost shows up in the unpriced-models report instead of silently
+// under-reporting spend.
+func (p *EventProcessor) setCost(span *entity.Span, model string, usage service.TokenUsage, pricing *service.PricingCalculator) {
+	cost := pricing.CalculateCostBreakdown(model, usage).Total
+	span.CostUSD = &cost
+
+	if !pricing.HasPricing(model) {
+		if span.Metadata == nil {
+			span.Metadata = make(map[string]any)
+		}
+		span.Metadata[MetadataUnpricedModel] = true
+	}
+}
+
 // --- Helper functions ---
 
+// parseSpanType maps an event's spanType to a recognized type. Empty and
+// unrecognized types are coerced to llm (strict projects reject the latter
+// before processing; see Service.Ingest).
 func parseSpanType(s string) entity.SpanType {
-	switch s {
-	case "agent":
-		return entity.SpanTypeAgent
-	case "tool":
-		return entity.SpanTypeTool
-	case "retrieval":
-		return entity.SpanTypeRetrieval
-	case "embedding":
-		return entity.SpanTypeEmbedding
-	case "guardrail":
-		return entity.SpanTypeGuardrail
-	case "rerank":
-		return entity.SpanTypeRerank
-	case "custom":
-		return entity.SpanTypeCustom
-	default:
-		return entity.SpanTypeLLM
+	if t := entity.SpanType(s); entity.IsKnownSpanType(t) {
+		return t
 	}
+	return entity.SpanTypeLLM
+}
+
+// parseSpanStatus maps an event status to a span status: empty is success
+// (projects may default to pending, see buildSpans), and an unrecognized
+// status is an error (strict projects reject it instead)
+func parseSpanStatus(s string) entity.SpanStatus {
+	switch status := entity.SpanStatus(s); {
+	case s == "":
+		return entity.SpanStatusSuccess
+	case entity.IsKnownSpanStatus(status):
+		return status
+	}
+	return entity.SpanStatusError
+}
+
+// defaultSpanStatus returns the status of the project's spans sent without one
+func defaultSpanStatus(settings entity.ProjectSettings) entity.SpanStatus {
+	if settings.DefaultSpanStatus == "" {
+		return entity.SpanStatusSuccess
+	}
+	return entity.SpanStatus(settings.DefaultSpanStatus)
+}
+
+// sumCostDetails totals a costDetails breakdown, rounded like computed costs
+func sumCostDetails(details map[string]float64) *float64 {
+	total := 0.0
+	for _, amount := range details {
+		total += amount
+	}
+	total = math.Round(total*1000000) / 1000000
+	return &total
+}
+
+// hasExplicitCosts reports whether any of events sends a costUsd or costDetails
+func hasExplicitCosts(events []IngestEvent) bool {
+	for _, event := range events {
+		if event.CostUSD != nil || len(event.CostDetails) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCost reports why an event's explicit costUsd or costDetails can't be
+// stored: they are kept as sent (see priceSpan), so each must be a finite,
+// non-negative amount
+func checkCost(event IngestEvent) error {
+	if event.CostUSD != nil && !validCost(*event.CostUSD) {
+		return fmt.Errorf("invalid costUsd %v (must be a finite, non-negative amount)", *event.CostUSD)
+	}
+	for class, amount := range event.CostDetails {
+		if !validCost(amount) {
+			return fmt.Errorf("invalid costDetails.%s %v (must be a finite, non-negative amount)", class, amount)
+		}
+	}
+	return nil
+}
+
+// validCost reports whether cost is a finite, non-negative amount
+func validCost(cost float64) bool {
+	return cost >= 0 && !math.IsInf(cost, 0)
 }
 
 func coalesce(values ...string) string {
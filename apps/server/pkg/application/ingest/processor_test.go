@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+func TestIngest_OutOfOrderBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "order", entity.ProjectSettings{})
+	svc := NewService(store, service.NewPricingCalculator())
+
+	t.Run("children and spans before their parent and trace", func(t *testing.T) {
+		// Reverse order: the tool span before its LLM parent, both before the
+		// agent root that carries the trace name, and a second trace in between
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			{TraceID: "reversed", SpanID: "tool", ParentSpanID: "llm", SpanType: "tool", Name: "search", Status: "success"},
+			{TraceID: "other", SpanID: "other-llm", SpanType: "llm", Name: "chat", Status: "success"},
+			{TraceID: "reversed", SpanID: "llm", ParentSpanID: "root", SpanType: "llm", Name: "chat", Status: "success"},
+			{TraceID: "reversed", SpanID: "root", SpanType: "agent", Name: "support-agent", Status: "success"},
+		}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, "reversed")
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		if trace.Name == nil || *trace.Name != "support-agent" {
+			t.Errorf("expected the trace named after its agent span, got %v", trace.Name)
+		}
+		parents := make(map[string]string)
+		for _, span := range trace.Spans {
+			parent := ""
+			if span.ParentSpanID != nil {
+				parent = *span.ParentSpanID
+			}
+			parents[span.ID] = parent
+		}
+		want := map[string]string{"tool": "llm", "llm": "root", "root": ""}
+		if len(parents) != len(want) {
+			t.Fatalf("expected %d spans, got %v", len(want), parents)
+		}
+		for id, parent := range want {
+			if got, ok := parents[id]; !ok || got != parent {
+				t.Errorf("span %s: expected parent %q, got %q (stored: %v)", id, parent, got, ok)
+			}
+		}
+
+		other, err := store.GetTrace(ctx, project.ID, "other")
+		if err != nil || len(other.Spans) != 1 {
+			t.Errorf("expected the other trace with its span, got %v %v", other, err)
+		}
+	})
+
+	t.Run("concurrent batches creating the same trace", func(t *testing.T) {
+		const batches = 4
+		var wg sync.WaitGroup
+		failures := make(chan string, batches)
+		for i := 0; i < batches; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				event := IngestEvent{TraceID: "shared", SpanType: "tool", Name: "fetch", Status: "success"}
+				resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{event}})
+				if err != nil || !resp.Success {
+					failures <- fmt.Sprintf("%v %+v", err, resp)
+				}
+			}()
+		}
+		wg.Wait()
+		close(failures)
+		for failure := range failures {
+			t.Errorf("ingest failed: %s", failure)
+		}
+
+		trace, err := store.GetTrace(ctx, project.ID, "shared")
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		if len(trace.Spans) != batches {
+			t.Errorf("expected every batch's span stored, got %d", len(trace.Spans))
+		}
+	})
+}
+
+func TestIngest_TraceIDOfAnotherProject(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	owner := newTestProject(t, store, "owner", entity.ProjectSettings{})
+	intruder := newTestProject(t, store, "intruder", entity.ProjectSettings{})
+	svc := NewService(store, service.NewPricingCalculator())
+
+	resp, err := svc.Ingest(ctx, owner, &IngestRequest{Events: []IngestEvent{
+		{TraceID: "trace-1", SpanID: "owner-span", SpanType: "tool", Name: "fetch", Status: "success"},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	// The other project reusing the trace ID must not write into it
+	resp, err = svc.Ingest(ctx, intruder, &IngestRequest{Events: []IngestEvent{
+		{TraceID: "trace-1", SpanID: "intruder-span", SpanType: "tool", Name: "fetch", Status: "success"},
+	}})
+	if err == nil && resp.Success {
+		t.Errorf("expected the ingest to fail, got %+v", resp)
+	}
+
+	trace, err := store.GetTrace(ctx, owner.ID, "trace-1")
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if len(trace.Spans) != 1 || trace.Spans[0].ID != "owner-span" {
+		t.Errorf("expected only the owner's span in its trace, got %+v", trace.Spans)
+	}
+	if _, err := store.GetTrace(ctx, intruder.ID, "trace-1"); err == nil {
+		t.Error("expected no trace-1 for the other project")
+	}
+}
+
+func TestIngest_RejectsInvalidCosts(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+	project := newTestProject(t, store, "invalid-costs", entity.ProjectSettings{})
+
+	cost := func(v float64) *float64 { return &v }
+	events := []IngestEvent{
+		{TraceID: "cost-trace", SpanID: "cost-negative", SpanType: "tool", Name: "negative", CostUSD: cost(-0.5)},
+		{TraceID: "cost-trace", SpanID: "cost-nan", SpanType: "tool", Name: "nan", CostUSD: cost(math.NaN())},
+		{TraceID: "cost-trace", SpanID: "cost-inf", SpanType: "tool", Name: "inf", CostUSD: cost(math.Inf(1))},
+		{TraceID: "cost-trace", SpanID: "cost-zero", SpanType: "tool", Name: "zero", CostUSD: cost(0)},
+		{TraceID: "cost-trace", SpanID: "cost-valid", SpanType: "tool", Name: "valid", CostUSD: cost(0.25)},
+	}
+	resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events})
+	if err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+	if resp.Processed != 2 || len(resp.Errors) != 3 {
+		t.Fatalf("expected 2 processed and 3 errors, got %+v", resp)
+	}
+	for i, e := range resp.Errors {
+		if e.Index != i || !strings.Contains(e.Message, "costUsd") {
+			t.Errorf("unexpected error %d: %+v", i, e)
+		}
+	}
+
+	trace, err := store.GetTrace(ctx, project.ID, "cost-trace")
+	if err != nil {
+		t.Fatalf("failed to get trace: %v", err)
+	}
+	if len(trace.Spans) != 2 {
+		t.Fatalf("expected only the valid spans to be stored, got %d", len(trace.Spans))
+	}
+}
+
+func TestIngest_RejectsInvalidCostDetails(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+	project := newTestProject(t, store, "invalid-cost-details", entity.ProjectSettings{})
+
+	events := []IngestEvent{
+		{TraceID: "details-trace", SpanID: "details-negative", SpanType: "llm", Name: "negative",
+			CostDetails: map[string]float64{"input": 0.01, "output": -0.5}},
+		{TraceID: "details-trace", SpanID: "details-nan", SpanType: "llm", Name: "nan",
+			CostDetails: map[string]float64{"input": math.NaN()}},
+		{TraceID: "details-trace", SpanID: "details-inf", SpanType: "llm", Name: "inf",
+			CostDetails: map[string]float64{"output": math.Inf(1)}},
+		{TraceID: "details-trace", SpanID: "details-valid", SpanType: "llm", Name: "valid",
+			CostDetails: map[string]float64{"input": 0.01, "output": 0}},
+	}
+	resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events})
+	if err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+	if resp.Processed != 1 || len(resp.Errors) != 3 {
+		t.Fatalf("expected 1 processed and 3 errors, got %+v", resp)
+	}
+	for i, e := range resp.Errors {
+		if e.Index != i || !strings.Contains(e.Message, "costDetails") {
+			t.Errorf("unexpected error %d: %+v", i, e)
+		}
+	}
+
+	trace, err := store.GetTrace(ctx, project.ID, "details-trace")
+	if err != nil {
+		t.Fatalf("failed to get trace: %v", err)
+	}
+	if len(trace.Spans) != 1 || *trace.Spans[0].CostUSD != 0.01 {
+		t.Errorf("expected only the valid span, costing 0.01, got %+v", trace.Spans)
+	}
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
@@ -0,0 +1,192 @@
+package ingest
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// RedactedPlaceholder replaces each PII match in redacted span data
+const RedactedPlaceholder = "[REDACTED]"
+
+// Built-in PII patterns. Card-like numbers (13-19 digits, optionally separated
+// by spaces or dashes) are only redacted when they pass the Luhn check, so
+// IDs and millisecond timestamps survive.
+var (
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)|\b\d{3})[\s.-]?\d{3}[\s.-]\d{4}\b|\+\d{1,3}(?:[\s.-]?\d{2,4}){3,5}\b`)
+)
+
+// redactor replaces PII in span data with RedactedPlaceholder
+type redactor struct {
+	custom []*regexp.Regexp
+}
+
+// redactorCache keeps each project's compiled redactor, recompiling only
+// when the project's patterns change.
+type redactorCache struct {
+	mu       sync.Mutex
+	projects map[string]cachedRedactor
+}
+
+type cachedRedactor struct {
+	fingerprint string
+	redactor    *redactor
+}
+
+func newRedactorCache() *redactorCache {
+	return &redactorCache{projects: make(map[string]cachedRedactor)}
+}
+
+// get returns the project's redactor, or nil when redaction is off. Patterns
+// that fail to compile are logged and skipped so one bad entry doesn't
+// disable the rest (the built-in patterns always apply).
+func (c *redactorCache) get(projectID string, settings *entity.RedactionSettings) *redactor {
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+	fingerprint := strings.Join(settings.Patterns, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.projects[projectID]; ok && cached.fingerprint == fingerprint {
+		return cached.redactor
+	}
+
+	r := &redactor{}
+	for _, pattern := range settings.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			slog.Warn("invalid redaction pattern", "project_id", projectID, "pattern", pattern, "error", err)
+			continue
+		}
+		r.custom = append(r.custom, re)
+	}
+
+	c.projects[projectID] = cachedRedactor{fingerprint: fingerprint, redactor: r}
+	return r
+}
+
+// redactSpans redacts the input, output and extracted tool call arguments of
+// each span when the project has redaction enabled
+func (p *EventProcessor) redactSpans(projectID string, spans []entity.Span, settings *entity.RedactionSettings) {
+	r := p.redactors.get(projectID, settings)
+	if r == nil {
+		return
+	}
+	for i := range spans {
+		span := &spans[i]
+		span.Input = r.value(span.Input)
+		span.Output = r.value(span.Output)
+		for j := range span.ToolUses {
+			span.ToolUses[j].Input = r.value(span.ToolUses[j].Input)
+			span.ToolUses[j].Output = r.value(span.ToolUses[j].Output)
+		}
+	}
+}
+
+// redactEvents redacts the input, output and raw response of events when the
+// project has redaction enabled, before they are written to the ingest WAL.
+// The spans built from them are redacted again, which leaves them unchanged.
+func (p *EventProcessor) redactEvents(projectID string, events []IngestEvent, settings *entity.RedactionSettings) {
+	r := p.redactors.get(projectID, settings)
+	if r == nil {
+		return
+	}
+	for i := range events {
+		event := &events[i]
+		event.Input = r.value(event.Input)
+		event.Output = r.value(event.Output)
+		event.RawResponse = r.value(event.RawResponse)
+	}
+}
+
+// redactTrace redacts the input a trace copies from its first event
+func (p *EventProcessor) redactTrace(trace *entity.Trace, settings *entity.RedactionSettings) {
+	r := p.redactors.get(trace.ProjectID, settings)
+	if r == nil {
+		return
+	}
+	if input, ok := trace.Metadata["input"]; ok {
+		trace.Metadata["input"] = r.value(input)
+	}
+}
+
+// redactTraceOutput redacts the output a trace.end event sets in metadata
+func (p *EventProcessor) redactTraceOutput(projectID string, metadata map[string]any, settings *entity.RedactionSettings) {
+	r := p.redactors.get(projectID, settings)
+	if r == nil {
+		return
+	}
+	if output, ok := metadata[MetadataTraceOutput]; ok {
+		metadata[MetadataTraceOutput] = r.value(output)
+	}
+}
+
+// value redacts every string in v, walking nested JSON (objects and arrays)
+// so the structure is preserved. Maps and slices are redacted in place.
+func (r *redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.text(v)
+	case map[string]any:
+		for k, item := range v {
+			v[k] = r.value(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = r.value(item)
+		}
+		return v
+	case []map[string]any:
+		for _, item := range v {
+			r.value(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// text redacts PII in a single string
+func (r *redactor) text(s string) string {
+	s = cardPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if luhnValid(match) {
+			return RedactedPlaceholder
+		}
+		return match
+	})
+	s = emailPattern.ReplaceAllString(s, RedactedPlaceholder)
+	s = phonePattern.ReplaceAllString(s, RedactedPlaceholder)
+	for _, re := range r.custom {
+		s = re.ReplaceAllString(s, RedactedPlaceholder)
+	}
+	return s
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
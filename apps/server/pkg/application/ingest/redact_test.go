@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestRedactorText(t *testing.T) {
+	r := newRedactorCache().get("p1", &entity.RedactionSettings{
+		Enabled:  true,
+		Patterns: []string{`ACCT-\d{6}`, `(unclosed`},
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "Contact jane.doe+work@example.co.uk today", "Contact [REDACTED] today"},
+		{"US phone", "Call (555) 123-4567 or 555.123.4567", "Call [REDACTED] or [REDACTED]"},
+		{"international phone", "Ring +44 20 7946 0958", "Ring [REDACTED]"},
+		{"card number", "Card 4111 1111 1111 1111 on file", "Card [REDACTED] on file"},
+		{"card without separators", "4242424242424242", "[REDACTED]"},
+		{"non-Luhn digits kept", "order 1234567890123 at 1699000000000", "order 1234567890123 at 1699000000000"},
+		{"dates and counts kept", "On 2024-01-15 we used 1500 tokens", "On 2024-01-15 we used 1500 tokens"},
+		{"custom pattern", "Account ACCT-123456 is overdue", "Account [REDACTED] is overdue"},
+		{"no PII", "What is the weather in Lima?", "What is the weather in Lima?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.text(tt.in); got != tt.want {
+				t.Errorf("text(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorValue_PreservesStructure(t *testing.T) {
+	r := newRedactorCache().get("p1", &entity.RedactionSettings{Enabled: true})
+
+	input := []any{
+		map[string]any{"role": "system", "content": "You are helpful."},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "My email is jane@example.com"},
+			map[string]any{"type": "image", "size": 1024.0},
+		}},
+	}
+	want := []any{
+		map[string]any{"role": "system", "content": "You are helpful."},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "My email is [REDACTED]"},
+			map[string]any{"type": "image", "size": 1024.0},
+		}},
+	}
+
+	if got := r.value(input); !reflect.DeepEqual(got, want) {
+		t.Errorf("value() = %v, want %v", got, want)
+	}
+}
+
+func TestRedactorCache(t *testing.T) {
+	c := newRedactorCache()
+
+	if r := c.get("p1", nil); r != nil {
+		t.Error("expected no redactor without settings")
+	}
+	if r := c.get("p1", &entity.RedactionSettings{Patterns: []string{"x"}}); r != nil {
+		t.Error("expected no redactor when redaction is disabled")
+	}
+
+	settings := &entity.RedactionSettings{Enabled: true, Patterns: []string{`secret-\w+`}}
+	first := c.get("p1", settings)
+	if again := c.get("p1", settings); again != first {
+		t.Error("expected unchanged settings to reuse the compiled redactor")
+	}
+	if changed := c.get("p1", &entity.RedactionSettings{Enabled: true}); changed == first || len(changed.custom) != 0 {
+		t.Error("expected changed patterns to recompile")
+	}
+}
@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// MetadataRunaway is the trace metadata flag set when a trace exceeds the
+// project's trace limits; MetadataRunawayReason names the limit it crossed
+// ("maxSpans" or "maxCostUsd").
+const (
+	MetadataRunaway       = "runaway"
+	MetadataRunawayReason = "runaway_reason"
+)
+
+// traceTotalsCacheSize bounds the traces whose running totals are remembered.
+const traceTotalsCacheSize = 10000
+
+// traceTotalsTracker keeps running span counts and costs per trace in a
+// bounded LRU, so trace limits are checked without re-querying the store.
+// In-memory only: a trace evicted (or ingested on another replica) is
+// re-seeded from the store's totals the next time it is seen.
+type traceTotalsTracker struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List               // front = most recently updated
+	items map[string]*list.Element // projectID/traceID -> element holding *traceTotals
+}
+
+type traceTotals struct {
+	key     string
+	spans   int
+	costUSD float64
+	runaway bool
+}
+
+func newTraceTotalsTracker(size int) *traceTotalsTracker {
+	return &traceTotalsTracker{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// add records spans for the trace and returns the limit the trace crossed,
+// only on the batch that crosses it; "" otherwise. existing seeds the totals
+// when the trace is not cached (nil for a trace created by this batch).
+func (t *traceTotalsTracker) add(projectID, traceID string, existing *entity.TraceWithSpans, spans []entity.Span, limits *entity.TraceLimitSettings) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals := t.get(projectID, traceID)
+	if totals == nil {
+		totals = &traceTotals{key: projectID + "/" + traceID}
+		if existing != nil {
+			totals.spans = existing.TotalSpans
+			totals.costUSD = existing.TotalCostUSD
+			_, totals.runaway = existing.Metadata[MetadataRunaway]
+		}
+		t.items[totals.key] = t.order.PushFront(totals)
+		if t.order.Len() > t.size {
+			oldest := t.order.Back()
+			t.order.Remove(oldest)
+			delete(t.items, oldest.Value.(*traceTotals).key)
+		}
+	}
+
+	totals.spans += len(spans)
+	for _, span := range spans {
+		if span.CostUSD != nil {
+			totals.costUSD += *span.CostUSD
+		}
+	}
+
+	if totals.runaway {
+		return ""
+	}
+	var reason string
+	switch {
+	case limits.MaxSpans > 0 && totals.spans > limits.MaxSpans:
+		reason = "maxSpans"
+	case limits.MaxCostUSD > 0 && totals.costUSD > limits.MaxCostUSD:
+		reason = "maxCostUsd"
+	default:
+		return ""
+	}
+	totals.runaway = true
+	return reason
+}
+
+// runaway reports whether the trace has been flagged as exceeding its limits
+func (t *traceTotalsTracker) runaway(projectID, traceID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals := t.get(projectID, traceID)
+	return totals != nil && totals.runaway
+}
+
+// get returns the cached totals and marks them recently used; the caller holds mu
+func (t *traceTotalsTracker) get(projectID, traceID string) *traceTotals {
+	el, ok := t.items[projectID+"/"+traceID]
+	if !ok {
+		return nil
+	}
+	t.order.MoveToFront(el)
+	return el.Value.(*traceTotals)
+}
+
+// runawayTraces returns the distinct traces of events flagged as runaway, in
+// request order
+func (t *traceTotalsTracker) runawayTraces(projectID string, events []IngestEvent) []string {
+	var traceIDs []string
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.TraceID == "" || seen[event.TraceID] {
+			continue
+		}
+		seen[event.TraceID] = true
+		if t.runaway(projectID, event.TraceID) {
+			traceIDs = append(traceIDs, event.TraceID)
+		}
+	}
+	return traceIDs
+}
@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestTraceTotalsTracker(t *testing.T) {
+	cost := func(v float64) *float64 { return &v }
+	limits := &entity.TraceLimitSettings{MaxSpans: 3, MaxCostUSD: 1}
+
+	t.Run("flags the batch that crosses a limit once", func(t *testing.T) {
+		tracker := newTraceTotalsTracker(10)
+		if reason := tracker.add("p", "t", nil, make([]entity.Span, 3), limits); reason != "" {
+			t.Fatalf("expected no flag at the limit, got %q", reason)
+		}
+		if reason := tracker.add("p", "t", nil, make([]entity.Span, 1), limits); reason != "maxSpans" {
+			t.Fatalf("expected maxSpans, got %q", reason)
+		}
+		if reason := tracker.add("p", "t", nil, make([]entity.Span, 1), limits); reason != "" {
+			t.Errorf("expected the trace flagged only once, got %q", reason)
+		}
+		if !tracker.runaway("p", "t") || tracker.runaway("other", "t") {
+			t.Error("expected only p/t runaway")
+		}
+	})
+
+	t.Run("cost limit", func(t *testing.T) {
+		tracker := newTraceTotalsTracker(10)
+		tracker.add("p", "t", nil, []entity.Span{{CostUSD: cost(0.6)}}, limits)
+		if reason := tracker.add("p", "t", nil, []entity.Span{{CostUSD: cost(0.6)}}, limits); reason != "maxCostUsd" {
+			t.Errorf("expected maxCostUsd, got %q", reason)
+		}
+	})
+
+	t.Run("seeds uncached traces from the store totals", func(t *testing.T) {
+		tracker := newTraceTotalsTracker(10)
+		existing := &entity.TraceWithSpans{TotalSpans: 3}
+		if reason := tracker.add("p", "t", existing, make([]entity.Span, 1), limits); reason != "maxSpans" {
+			t.Errorf("expected maxSpans, got %q", reason)
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		tracker := newTraceTotalsTracker(2)
+		tracker.add("p", "a", nil, make([]entity.Span, 4), limits)
+		tracker.add("p", "b", nil, nil, limits)
+		tracker.add("p", "c", nil, nil, limits)
+		if tracker.order.Len() != 2 || tracker.runaway("p", "a") {
+			t.Errorf("expected the least recently used trace evicted, got %d cached", tracker.order.Len())
+		}
+	})
+}
+
+func TestIngest_TraceLimits(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "limits", entity.ProjectSettings{TraceLimits: &entity.TraceLimitSettings{MaxSpans: 5}})
+	svc := NewService(store, service.NewPricingCalculator())
+
+	// An agent loop sending two tool spans per step
+	step := func(traceID string) *IngestResponse {
+		t.Helper()
+		event := IngestEvent{TraceID: traceID, SpanType: "tool", Name: "search", Status: "success"}
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{event, event}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := step("loop"); len(resp.RunawayTraces) != 0 {
+			t.Fatalf("step %d: expected no runaway traces under the ceiling, got %v", i, resp.RunawayTraces)
+		}
+	}
+	step("other")
+
+	// 6 spans > 5: the step crossing the ceiling signals it, and so does every later one
+	for i := 0; i < 2; i++ {
+		if resp := step("loop"); !slices.Equal(resp.RunawayTraces, []string{"loop"}) {
+			t.Fatalf("expected the loop trace flagged, got %v", resp.RunawayTraces)
+		}
+	}
+	if resp := step("other"); len(resp.RunawayTraces) != 0 {
+		t.Errorf("expected the other trace under its ceiling, got %v", resp.RunawayTraces)
+	}
+
+	trace, err := store.GetTrace(ctx, project.ID, "loop")
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if trace.Metadata[MetadataRunaway] != true || trace.Metadata[MetadataRunawayReason] != "maxSpans" {
+		t.Errorf("expected the runaway flag in trace metadata, got %v", trace.Metadata)
+	}
+	if len(trace.Spans) != 8 {
+		t.Errorf("expected runaway spans still stored, got %d", len(trace.Spans))
+	}
+}
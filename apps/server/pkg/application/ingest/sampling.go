@@ -0,0 +1,50 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// MetadataSampleRate is the trace metadata key recording the sample rate a
+// trace was kept at, so its costs and counts can be extrapolated (divide by it)
+const MetadataSampleRate = "sample_rate"
+
+// sampleRate returns the project's trace sample rate, clamped to [0, 1]
+func sampleRate(settings entity.ProjectSettings) float64 {
+	if settings.SampleRate == nil {
+		return 1
+	}
+	return min(max(*settings.SampleRate, 0), 1)
+}
+
+// keepTrace decides whether a trace is sampled. The decision depends only on
+// the trace ID, so every span of a trace gets the same answer whichever batch
+// it arrives in.
+func keepTrace(traceID string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	sum := sha256.Sum256([]byte(traceID))
+	// Top 53 bits as a uniform value in [0, 1)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < rate
+}
+
+// sampleEvents drops the events of traces not kept at rate. Events without a
+// traceId (legacy session events) get a fresh trace per batch, so there is no
+// stable ID to sample on and they are always kept.
+func sampleEvents(events []IngestEvent, rate float64) (kept []IngestEvent, dropped int) {
+	if rate >= 1 {
+		return events, 0
+	}
+	kept = make([]IngestEvent, 0, len(events))
+	for _, event := range events {
+		if event.TraceID != "" && !keepTrace(event.TraceID, rate) {
+			dropped++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return kept, dropped
+}
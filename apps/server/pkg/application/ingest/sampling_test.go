@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestKeepTrace(t *testing.T) {
+	for _, rate := range []float64{0, 0.25, 0.5, 1} {
+		kept := 0
+		for i := range 10000 {
+			id := fmt.Sprintf("trace-%d", i)
+			keep := keepTrace(id, rate)
+			if keep != keepTrace(id, rate) {
+				t.Fatalf("rate %v: decision for %s is not deterministic", rate, id)
+			}
+			if keep {
+				kept++
+			}
+		}
+		if got := float64(kept) / 10000; got < rate-0.02 || got > rate+0.02 {
+			t.Errorf("rate %v: kept %.3f of traces", rate, got)
+		}
+	}
+}
+
+func TestIngest_SamplesWholeTraces(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	rate := 0.5
+	project := newTestProject(t, store, "sampled", entity.ProjectSettings{SampleRate: &rate})
+	svc := NewService(store, service.NewPricingCalculator())
+
+	// 3 spans per trace, shuffled across batches so a trace's spans arrive
+	// in different requests and in any order
+	const traces, spansPerTrace = 100, 3
+	var events []IngestEvent
+	for i := range traces {
+		for j := range spansPerTrace {
+			events = append(events, IngestEvent{
+				TraceID: fmt.Sprintf("sampled-trace-%d", i), SpanID: fmt.Sprintf("sampled-span-%d-%d", i, j),
+				SpanType: "tool", Name: "step", Status: "success",
+			})
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(events), func(i, j int) { events[i], events[j] = events[j], events[i] })
+
+	processed, sampledOut := 0, 0
+	for start := 0; start < len(events); start += 25 {
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events[start:min(start+25, len(events))]})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+		processed += resp.Processed
+		sampledOut += resp.SampledOut
+	}
+
+	kept := 0
+	for i := range traces {
+		traceID := fmt.Sprintf("sampled-trace-%d", i)
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if errors.Is(err, entity.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("get trace: %v", err)
+		}
+		kept++
+		if len(trace.Spans) != spansPerTrace {
+			t.Errorf("%s: expected all %d spans kept, got %d", traceID, spansPerTrace, len(trace.Spans))
+		}
+		if trace.Metadata[MetadataSampleRate] != rate {
+			t.Errorf("%s: expected sample rate %v in metadata, got %v", traceID, rate, trace.Metadata[MetadataSampleRate])
+		}
+	}
+
+	if kept < traces*3/10 || kept > traces*7/10 {
+		t.Errorf("expected about half the traces kept, got %d of %d", kept, traces)
+	}
+	if processed != kept*spansPerTrace || sampledOut != (traces-kept)*spansPerTrace {
+		t.Errorf("expected %d processed and %d sampled out, got %d and %d",
+			kept*spansPerTrace, (traces-kept)*spansPerTrace, processed, sampledOut)
+	}
+
+	t.Run("legacy session events are always kept", func(t *testing.T) {
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			{SessionID: "session-1", SpanType: "tool", Name: "step", Status: "success"},
+		}})
+		if err != nil || resp.Processed != 1 || resp.SampledOut != 0 {
+			t.Errorf("expected the session event to be ingested, got %+v (%v)", resp, err)
+		}
+	})
+}
@@ -2,8 +2,13 @@ package ingest
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/lelemon/server/pkg/application/webhook"
+	"github.com/lelemon/server/pkg/domain/entity"
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/domain/service"
 )
@@ -13,6 +18,9 @@ type Service struct {
 	processor *EventProcessor
 	worker    *Worker
 	async     bool
+	clock     clockSkewPolicy
+	depth     jsonDepthPolicy
+	ids       idPolicy
 }
 
 // NewService creates a new ingest service (sync mode for tests)
@@ -36,6 +44,134 @@ func NewAsyncService(store repository.Store, pricing *service.PricingCalculator,
 	}
 }
 
+// SetUsageRecorder reports the traces and spans written by each batch to r.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetUsageRecorder(r UsageRecorder) {
+	s.processor.usage = r
+}
+
+// SetProjectStore sets where the projects of jobs replayed from the WAL are
+// read from, when they live apart from traces, e.g. in the primary store.
+// No-op in sync mode.
+// Call it before EnableWAL.
+func (s *Service) SetProjectStore(projects repository.ProjectStore) {
+	if s.worker != nil {
+		s.worker.projects = projects
+	}
+}
+
+// SetWebhookDispatcher delivers the trace events projects subscribe to
+// (trace.completed, trace.error, budget.exceeded) through d.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetWebhookDispatcher(d *webhook.Dispatcher) {
+	s.processor.webhooks = d
+}
+
+// AddSpanTransforms adds custom stages to the ingest pipeline (see SpanTransform).
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) AddSpanTransforms(transforms ...SpanTransform) {
+	s.processor.AddSpanTransforms(transforms...)
+}
+
+// SetMaxClockSkew makes ingest check event timestamps against server time:
+// events more than maxSkew in the past or future are rejected with a
+// per-event error or, with clamp, stored at the time the server received
+// them. 0 disables the check (the default).
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetMaxClockSkew(maxSkew time.Duration, clamp bool) {
+	s.clock = clockSkewPolicy{maxSkew: maxSkew, clamp: clamp}
+}
+
+// SetMaxJSONDepth limits how deeply event input, output and metadata may nest:
+// deeper events are rejected with a per-event error or, with truncate, stored
+// with the too-deep values replaced by a marker. 0 disables the check.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetMaxJSONDepth(maxDepth int, truncate bool) {
+	s.depth = jsonDepthPolicy{maxDepth: maxDepth, truncate: truncate}
+}
+
+// SetIDValidation checks the trace and span IDs of events against v: events
+// with an invalid ID are rejected with a per-event error in strict mode, or
+// ingested without the ID. The zero IDValidation disables the check (the
+// default). It returns an error for an unknown format or invalid charset.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetIDValidation(v IDValidation) error {
+	ids, err := newIDPolicy(v)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
+	return nil
+}
+
+// SetWorkerAutoscale lets async ingest grow beyond its base workers, up to
+// maxWorkers, while more than highWater jobs are queued; the extra workers
+// exit after idleTimeout without a job. No-op in sync mode.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) SetWorkerAutoscale(maxWorkers, highWater int, idleTimeout time.Duration) {
+	if s.worker != nil {
+		s.worker.SetAutoscale(maxWorkers, highWater, idleTimeout)
+	}
+}
+
+// EnableWAL makes async ingest record each queued job in a write-ahead log at
+// path, removing it once its batch is stored, so jobs still queued when the
+// process dies are not lost: jobs left in the log are queued again here. A
+// job may be stored twice if the process dies right after storing it. The
+// log holds events already redacted, and no processing options: replayed
+// jobs are processed with their project's current settings.
+// No-op in sync mode.
+// Call it before ingesting; it is not safe to change while batches are processed.
+func (s *Service) EnableWAL(path string) error {
+	if s.worker == nil {
+		return nil
+	}
+	wal, pending, err := openJobWAL(path)
+	if err != nil {
+		return err
+	}
+	s.worker.wal = wal
+	if len(pending) > 0 {
+		slog.Info("replaying ingest WAL", "path", path, "jobs", len(pending))
+		s.worker.replay(pending, nil)
+	}
+	return nil
+}
+
+// SetMaintenance turns ingest maintenance mode on or off: while on, batches
+// are accepted but only written to the WAL, and they are stored once it is
+// turned off, e.g. around database maintenance. Fails in sync mode or
+// without EnableWAL.
+func (s *Service) SetMaintenance(on bool) error {
+	if s.worker == nil {
+		return ErrMaintenanceNeedsWAL
+	}
+	return s.worker.SetMaintenance(on)
+}
+
+// WorkerStats reports the async worker's load. All are zero in sync mode.
+type WorkerStats struct {
+	ActiveWorkers int  `json:"active_workers"`
+	QueuedJobs    int  `json:"queued_jobs"`
+	Maintenance   bool `json:"maintenance"`
+	DeferredJobs  int  `json:"deferred_jobs"` // held by maintenance mode
+}
+
+// WorkerStats returns the current number of async workers and queued jobs,
+// and the maintenance mode
+func (s *Service) WorkerStats() WorkerStats {
+	if s.worker == nil {
+		return WorkerStats{}
+	}
+	maintenance, deferred := s.worker.Maintenance()
+	return WorkerStats{
+		ActiveWorkers: s.worker.ActiveWorkers(),
+		QueuedJobs:    s.worker.QueueSize(),
+		Maintenance:   maintenance,
+		DeferredJobs:  deferred,
+	}
+}
+
 // Stop gracefully shuts down the async worker
 func (s *Service) Stop(timeout time.Duration) {
 	if s.worker != nil {
@@ -46,35 +182,175 @@ func (s *Service) Stop(timeout time.Duration) {
 // Ingest processes a batch of events
 // In async mode: enqueues and returns immediately
 // In sync mode: processes synchronously
-func (s *Service) Ingest(ctx context.Context, projectID string, req *IngestRequest) (*IngestResponse, error) {
+func (s *Service) Ingest(ctx context.Context, project *entity.Project, req *IngestRequest) (*IngestResponse, error) {
 	if len(req.Events) == 0 {
 		return &IngestResponse{Success: true, Processed: 0}, nil
 	}
+	opts := NewProcessOptions(project.Settings)
+
+	// Invalid events (unrecognized span types, statuses and parent cycles in strict
+	// projects, skewed timestamps, too deeply nested JSON, invalid IDs, missing
+	// required session IDs) are rejected per event; the rest of the batch is still ingested
+	events, rejected, warnings := s.validateEvents(project, req.Events)
+	if len(events) == 0 {
+		return &IngestResponse{Success: false, Processed: 0, Errors: rejected}, nil
+	}
+
+	// Head-based sampling: drop whole traces before any work is done on them
+	events, sampledOut := sampleEvents(events, opts.SampleRate)
+	if len(events) == 0 {
+		return &IngestResponse{Success: len(rejected) == 0, Processed: 0, SampledOut: sampledOut, Errors: rejected, Warnings: warnings}, nil
+	}
 
 	// Async mode: enqueue and return
 	if s.async && s.worker != nil {
 		queued := s.worker.Enqueue(Job{
-			ProjectID: projectID,
-			Events:    req.Events,
+			ProjectID: project.ID,
+			Events:    events,
+			Options:   opts,
 		})
 		return &IngestResponse{
-			Success:   queued,
-			Processed: len(req.Events),
+			Success:       queued && len(rejected) == 0,
+			Processed:     len(events),
+			SampledOut:    sampledOut,
+			Errors:        rejected,
+			Warnings:      warnings,
+			RunawayTraces: s.runawayTraces(project.ID, events, opts),
 		}, nil
 	}
 
-	// Sync mode: process directly
-	err := s.processor.ProcessEvents(ctx, projectID, req.Events)
+	// Sync mode: process directly. Spans the store rejects are reported
+	// without failing the rest of the batch.
+	err := s.processor.ProcessEvents(ctx, project.ID, events, opts)
+	var partial *SpanWriteError
+	if errors.As(err, &partial) {
+		return &IngestResponse{
+			Success:       false,
+			Processed:     len(events) - len(partial.Failed),
+			SampledOut:    sampledOut,
+			Errors:        rejected,
+			Warnings:      warnings,
+			FailedSpans:   partial.Failed,
+			RunawayTraces: s.runawayTraces(project.ID, events, opts),
+		}, nil
+	}
 	if err != nil {
 		return &IngestResponse{
 			Success:   false,
 			Processed: 0,
-			Errors:    []IngestError{{Index: 0, Message: err.Error()}},
+			Errors:    append(rejected, IngestError{Index: 0, Message: err.Error()}),
 		}, nil
 	}
 
 	return &IngestResponse{
-		Success:   true,
-		Processed: len(req.Events),
+		Success:       len(rejected) == 0,
+		Processed:     len(events),
+		SampledOut:    sampledOut,
+		Errors:        rejected,
+		Warnings:      warnings,
+		RunawayTraces: s.runawayTraces(project.ID, events, opts),
 	}, nil
 }
+
+// runawayTraces returns the batch's traces flagged as over the project's trace limits
+func (s *Service) runawayTraces(projectID string, events []IngestEvent, opts ProcessOptions) []string {
+	if opts.TraceLimits == nil {
+		return nil
+	}
+	return s.processor.traces.runawayTraces(projectID, events)
+}
+
+// DryRun runs the ingest transform over a batch and returns the spans that
+// Ingest would store, in request order, without writing anything. Spans keep
+// the event's traceId; legacy session events have none until a trace is
+// created for them. trace.end events add no span. Event validation applies
+// as in Ingest, but dedup and sampling do not.
+func (s *Service) DryRun(ctx context.Context, project *entity.Project, req *IngestRequest) (*DryRunResponse, error) {
+	events, rejected, warnings := s.validateEvents(project, req.Events)
+	events, _ = splitTraceEnds(events)
+
+	spans := s.processor.transformSpans(project.ID, "", events, NewProcessOptions(project.Settings))
+	for i := range spans {
+		spans[i].TraceID = events[i].TraceID
+	}
+
+	return &DryRunResponse{Spans: spans, Errors: rejected, Warnings: warnings}, nil
+}
+
+// validateEvents splits events into those to ingest and errors for the rest,
+// indexed into the request, with warnings for ingested events that break a
+// project policy. Strict projects reject unrecognized event and
+// span types (an empty spanType means llm) and spans on a parent cycle within the
+// batch, which other projects store as roots (see checkParent); projects
+// with strictSpanStatus reject unrecognized statuses, which others ingest as
+// errors (see parseSpanStatus); timestamps are checked against the clock skew policy, JSON fields against the
+// depth policy and IDs against the ID policy, which may return clamped, truncated or ID-less copies of events.
+// trace.end events skip the span checks but need a traceId and a trace status (see checkTraceEnd).
+// Span events without a sessionId are rejected or warned about per the project's requireSessionId.
+// An explicit costUsd and costDetails amounts must be finite and non-negative (see checkCost).
+func (s *Service) validateEvents(project *entity.Project, events []IngestEvent) ([]IngestEvent, []IngestError, []IngestError) {
+	strict := project.Settings.StrictSpanTypes
+	strictStatus := project.Settings.StrictSpanStatus
+	requireSession := project.Settings.RequireSessionID
+	cycles := parentCycles(events)
+	if !strict && !strictStatus && requireSession == "" && len(cycles) == 0 && !s.clock.enabled() && !s.depth.enabled() && !s.ids.enabled() && !hasTraceEnds(events) && !hasExplicitCosts(events) {
+		return events, nil, nil
+	}
+
+	now := time.Now()
+	var rejected, warnings []IngestError
+	valid := make([]IngestEvent, 0, len(events))
+	for i, event := range events {
+		if strict && event.Type != "" && event.Type != EventTypeSpan && !isTraceEnd(event) {
+			rejected = append(rejected, IngestError{
+				Index:   i,
+				Message: fmt.Sprintf("unknown event type %q", event.Type),
+			})
+			continue
+		}
+		if strict && !isTraceEnd(event) && event.SpanType != "" && !entity.IsKnownSpanType(entity.SpanType(event.SpanType)) {
+			rejected = append(rejected, IngestError{
+				Index:   i,
+				Message: fmt.Sprintf("unknown spanType %q", event.SpanType),
+			})
+			continue
+		}
+		if strictStatus && !isTraceEnd(event) && event.Status != "" && !entity.IsKnownSpanStatus(entity.SpanStatus(event.Status)) {
+			rejected = append(rejected, IngestError{
+				Index:   i,
+				Message: fmt.Sprintf("unknown status %q", event.Status),
+			})
+			continue
+		}
+		event, err := s.ids.check(event)
+		if err == nil {
+			event, err = checkParent(event, cycles, strict)
+		}
+		if err == nil {
+			event, err = s.clock.check(event, now)
+		}
+		if err == nil {
+			event, err = s.depth.check(event)
+		}
+		if err == nil {
+			err = checkCost(event)
+		}
+		// After the ID check, which may have dropped the trace ID
+		if err == nil && isTraceEnd(event) {
+			err = checkTraceEnd(event)
+		}
+		if err != nil {
+			rejected = append(rejected, IngestError{Index: i, Message: err.Error()})
+			continue
+		}
+		if requireSession != "" && event.SessionID == "" && !isTraceEnd(event) {
+			if requireSession == entity.SessionIDRequirementReject {
+				rejected = append(rejected, IngestError{Index: i, Message: "missing sessionId"})
+				continue
+			}
+			warnings = append(warnings, IngestError{Index: i, Message: "missing sessionId"})
+		}
+		valid = append(valid, event)
+	}
+	return valid, rejected, warnings
+}
@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_SpanStatuses(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	// One trace per status, so the trace status follows from its only span
+	statuses := []string{"", "success", "pending", "error", "timeout", "cancelled", "exploded"}
+	batch := func(prefix string) []IngestEvent {
+		events := make([]IngestEvent, len(statuses))
+		for i, status := range statuses {
+			events[i] = IngestEvent{
+				TraceID: prefix + "/" + status, SpanID: prefix + "/" + status + "/span",
+				SpanType: "tool", Name: "call", Status: status,
+			}
+		}
+		return events
+	}
+
+	t.Run("lenient project", func(t *testing.T) {
+		project := newTestProject(t, store, "lenient", entity.ProjectSettings{})
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: batch("lenient")})
+		if err != nil || !resp.Success || resp.Processed != len(statuses) {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		want := map[string]struct {
+			span  entity.SpanStatus
+			trace entity.TraceStatus
+		}{
+			"":          {entity.SpanStatusSuccess, entity.TraceStatusCompleted},
+			"success":   {entity.SpanStatusSuccess, entity.TraceStatusCompleted},
+			"pending":   {entity.SpanStatusPending, entity.TraceStatusActive},
+			"error":     {entity.SpanStatusError, entity.TraceStatusError},
+			"timeout":   {entity.SpanStatusTimeout, entity.TraceStatusError},
+			"cancelled": {entity.SpanStatusCancelled, entity.TraceStatusCompleted},
+			"exploded":  {entity.SpanStatusError, entity.TraceStatusError}, // unknown: an error
+		}
+		for status, w := range want {
+			trace, err := store.GetTrace(ctx, project.ID, "lenient/"+status)
+			if err != nil {
+				t.Fatalf("GetTrace %q failed: %v", status, err)
+			}
+			if len(trace.Spans) != 1 || trace.Spans[0].Status != w.span || trace.Status != w.trace {
+				t.Errorf("status %q: expected span %s in a %s trace, got %+v", status, w.span, w.trace, trace)
+			}
+		}
+
+		stats, err := store.GetStats(ctx, project.ID, entity.AnalyticsQuery{
+			Period: entity.Period{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)},
+		})
+		if err != nil {
+			t.Fatalf("GetStats failed: %v", err)
+		}
+		if stats.ErrorSpans != 2 || stats.TimeoutSpans != 1 || stats.CancelledSpans != 1 {
+			t.Errorf("expected 2 error, 1 timeout and 1 cancelled spans, got %d/%d/%d",
+				stats.ErrorSpans, stats.TimeoutSpans, stats.CancelledSpans)
+		}
+	})
+
+	t.Run("strict project rejects unknown statuses", func(t *testing.T) {
+		project := newTestProject(t, store, "strict", entity.ProjectSettings{StrictSpanStatus: true})
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: batch("strict")})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Success || resp.Processed != len(statuses)-1 {
+			t.Errorf("expected all but the unknown status processed, got %+v", resp)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Index != 6 || resp.Errors[0].Message != `unknown status "exploded"` {
+			t.Errorf("expected the unknown status rejected, got %+v", resp.Errors)
+		}
+	})
+	t.Run("project defaulting to pending", func(t *testing.T) {
+		project := newTestProject(t, store, "streaming", entity.ProjectSettings{DefaultSpanStatus: "pending"})
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: batch("pending")})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+
+		// Only the omitted status takes the default; explicit ones win
+		want := map[string]entity.SpanStatus{
+			"":        entity.SpanStatusPending,
+			"success": entity.SpanStatusSuccess,
+			"error":   entity.SpanStatusError,
+		}
+		for status, w := range want {
+			trace, err := store.GetTrace(ctx, project.ID, "pending/"+status)
+			if err != nil {
+				t.Fatalf("GetTrace %q failed: %v", status, err)
+			}
+			if len(trace.Spans) != 1 || trace.Spans[0].Status != w {
+				t.Errorf("status %q: expected span %s, got %+v", status, w, trace.Spans)
+			}
+		}
+		// A root span left pending keeps its trace active
+		trace, err := store.GetTrace(ctx, project.ID, "pending/")
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		if trace.Status != entity.TraceStatusActive {
+			t.Errorf("expected an active trace, got %s", trace.Status)
+		}
+
+		// A root span with an explicit status ends it
+		resp, err = svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{{
+			TraceID: "pending/", SpanID: "pending//end", SpanType: "agent", Name: "run", Status: "success",
+		}}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+		if trace, err = store.GetTrace(ctx, project.ID, "pending/"); err != nil || trace.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected the trace completed by the explicit status, got %+v (%v)", trace, err)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+const (
+	// streamChunkSize is how many parsed events IngestStream hands to Ingest at once
+	streamChunkSize = 500
+	// maxStreamLineBytes bounds one NDJSON line (one event); longer lines are rejected
+	maxStreamLineBytes = 1 << 20
+	// maxStreamErrors bounds the per-line errors a stream response lists; the
+	// rejected count still covers every line
+	maxStreamErrors = 100
+)
+
+// StreamIngestResponse summarizes an NDJSON ingest stream. Error indexes are
+// 0-based line numbers in the body.
+type StreamIngestResponse struct {
+	Success       bool          `json:"success"`
+	Accepted      int           `json:"accepted"`
+	Rejected      int           `json:"rejected"`
+	SampledOut    int           `json:"sampledOut,omitempty"`
+	Errors        []IngestError `json:"errors,omitempty"`      // the first maxStreamErrors rejections
+	Warnings      []IngestError `json:"warnings,omitempty"`    // the first maxStreamErrors warnings
+	FailedSpans   []FailedSpan  `json:"failedSpans,omitempty"` // the first maxStreamErrors spans the store could not write
+	RunawayTraces []string      `json:"runawayTraces,omitempty"`
+}
+
+// IngestStream ingests an NDJSON body, one event per line, without holding
+// the whole batch in memory: events are parsed as they are read and ingested
+// in chunks of streamChunkSize. Blank lines are skipped; malformed or
+// oversized lines (including a truncated last line) are rejected with a
+// per-line error and the stream goes on. A read error stops the stream, after
+// which the lines already read stay ingested.
+func (s *Service) IngestStream(ctx context.Context, project *entity.Project, body io.Reader) (*StreamIngestResponse, error) {
+	resp := &StreamIngestResponse{}
+	reader := bufio.NewReaderSize(body, 64<<10)
+
+	var chunk []IngestEvent
+	var chunkLines []int // line number of each chunk event
+	runaway := make(map[string]bool)
+	queued := true // false once async mode drops a chunk on a full queue
+
+	reject := func(line int, message string) {
+		resp.Rejected++
+		if len(resp.Errors) < maxStreamErrors {
+			resp.Errors = append(resp.Errors, IngestError{Index: line, Message: message})
+		}
+	}
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		result, err := s.Ingest(ctx, project, &IngestRequest{Events: chunk})
+		if err != nil {
+			return err
+		}
+		queued = queued && (result.Success || len(result.Errors) > 0)
+		resp.Accepted += result.Processed
+		resp.SampledOut += result.SampledOut
+		// A failed chunk write reports one error for all its events, a partial
+		// one the spans that failed
+		if failed := len(chunk) - result.Processed - result.SampledOut - len(result.Errors); failed > 0 {
+			resp.Rejected += failed
+		}
+		for _, e := range result.Errors {
+			reject(chunkLines[e.Index], e.Message)
+		}
+		for _, f := range result.FailedSpans {
+			if len(resp.FailedSpans) < maxStreamErrors {
+				resp.FailedSpans = append(resp.FailedSpans, f)
+			}
+		}
+		for _, w := range result.Warnings {
+			if len(resp.Warnings) < maxStreamErrors {
+				resp.Warnings = append(resp.Warnings, IngestError{Index: chunkLines[w.Index], Message: w.Message})
+			}
+		}
+		for _, traceID := range result.RunawayTraces {
+			if !runaway[traceID] {
+				runaway[traceID] = true
+				resp.RunawayTraces = append(resp.RunawayTraces, traceID)
+			}
+		}
+		// Async jobs keep the chunk's events: start a new slice
+		chunk, chunkLines = nil, chunkLines[:0]
+		return nil
+	}
+
+	for line := 0; ; line++ {
+		raw, tooLong, readErr := readStreamLine(reader, maxStreamLineBytes)
+		switch {
+		case readErr != nil && readErr != io.EOF:
+			reject(line, fmt.Sprintf("read body: %v", readErr))
+		case tooLong:
+			reject(line, fmt.Sprintf("line exceeds %d bytes", maxStreamLineBytes))
+		case len(bytes.TrimSpace(raw)) > 0:
+			var event IngestEvent
+			if err := json.Unmarshal(raw, &event); err != nil {
+				reject(line, fmt.Sprintf("invalid JSON: %v", err))
+				break
+			}
+			chunk = append(chunk, event)
+			chunkLines = append(chunkLines, line)
+		}
+
+		if len(chunk) >= streamChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	resp.Success = queued && resp.Rejected == 0
+	return resp, nil
+}
+
+// readStreamLine reads the next line, without its newline. A line longer than
+// max is consumed but not returned (tooLong). err is io.EOF once the body is
+// exhausted; the line returned with it, if any, had no trailing newline.
+func readStreamLine(r *bufio.Reader, max int) (line []byte, tooLong bool, err error) {
+	for {
+		fragment, readErr := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(fragment) > max+1 { // +1: the newline
+				tooLong, line = true, nil
+			} else {
+				line = append(line, fragment...)
+			}
+		}
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), tooLong, readErr
+	}
+}
@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestSubtreeCosts(t *testing.T) {
+	cost := func(v float64) *float64 { return &v }
+	parent := func(id string) *string { return &id }
+	spans := []entity.Span{
+		{ID: "root", Type: entity.SpanTypeAgent},
+		{ID: "plan", ParentSpanID: parent("root"), Type: entity.SpanTypeLLM, CostUSD: cost(0.5)},
+		{ID: "sub", ParentSpanID: parent("root"), Type: entity.SpanTypeAgent, CostUSD: cost(0.125)},
+		{ID: "search", ParentSpanID: parent("sub"), Type: entity.SpanTypeLLM, CostUSD: cost(0.25)},
+		{ID: "fetch", ParentSpanID: parent("search"), Type: entity.SpanTypeTool, CostUSD: cost(1)},
+		{ID: "orphan", ParentSpanID: parent("missing"), Type: entity.SpanTypeLLM, CostUSD: cost(2)},
+		// A parent cycle counts each span once per agent
+		{ID: "loop-a", ParentSpanID: parent("loop-b"), Type: entity.SpanTypeAgent, CostUSD: cost(4)},
+		{ID: "loop-b", ParentSpanID: parent("loop-a"), Type: entity.SpanTypeLLM, CostUSD: cost(8)},
+	}
+
+	want := map[string]float64{"root": 1.875, "sub": 1.375, "loop-a": 12}
+	got := entity.SubtreeCosts(spans)
+	if len(got) != len(want) {
+		t.Fatalf("expected costs for %v, got %v", want, got)
+	}
+	for id, cost := range want {
+		if got[id] != cost {
+			t.Errorf("%s: expected %v, got %v", id, cost, got[id])
+		}
+	}
+}
+
+func TestIngest_AgentSubtreeCost(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	project := newTestProject(t, store, "subtree", entity.ProjectSettings{})
+
+	const traceID = "subtree-trace"
+	ingest := func(t *testing.T, events ...IngestEvent) {
+		t.Helper()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+	}
+	span := func(spanID, parentID, spanType string, cost float64) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanID: spanID, ParentSpanID: parentID, SpanType: spanType,
+			Name: spanID, Status: "success", CostUSD: &cost}
+	}
+	// check asserts each agent span's stored subtree cost is the sum of its
+	// own cost and its descendants'
+	check := func(t *testing.T, want map[string]float64) {
+		t.Helper()
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		for _, sp := range trace.Spans {
+			cost, isAgent := want[sp.ID]
+			switch {
+			case !isAgent && sp.SubtreeCostUSD != nil:
+				t.Errorf("%s: expected no subtree cost, got %v", sp.ID, *sp.SubtreeCostUSD)
+			case isAgent && sp.SubtreeCostUSD == nil:
+				t.Errorf("%s: expected subtree cost %v, got none", sp.ID, cost)
+			case isAgent && math.Abs(*sp.SubtreeCostUSD-cost) > 1e-9:
+				t.Errorf("%s: expected subtree cost %v, got %v", sp.ID, cost, *sp.SubtreeCostUSD)
+			}
+		}
+	}
+
+	t.Run("not set while the trace runs", func(t *testing.T) {
+		ingest(t,
+			span("subtree/research", "subtree/root", "agent", 0.125),
+			span("subtree/search", "subtree/research", "llm", 0.25),
+			span("subtree/fetch", "subtree/search", "tool", 0.0625),
+		)
+		check(t, map[string]float64{})
+	})
+
+	t.Run("set on every agent span when the trace completes", func(t *testing.T) {
+		ingest(t,
+			span("subtree/plan", "subtree/root", "llm", 0.5),
+			span("subtree/root", "", "agent", 0),
+		)
+		check(t, map[string]float64{
+			"subtree/root":     0.125 + 0.25 + 0.0625 + 0.5,
+			"subtree/research": 0.125 + 0.25 + 0.0625,
+		})
+	})
+
+	t.Run("late spans update their ancestors", func(t *testing.T) {
+		ingest(t, span("subtree/retry", "subtree/research", "llm", 1))
+		check(t, map[string]float64{
+			"subtree/root":     0.125 + 0.25 + 0.0625 + 0.5 + 1,
+			"subtree/research": 0.125 + 0.25 + 0.0625 + 1,
+		})
+	})
+}
@@ -0,0 +1,161 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// MetadataToolArgViolations is the span metadata key listing tool calls whose
+// arguments don't satisfy the project's registered schema.
+const MetadataToolArgViolations = "tool_arg_violations"
+
+// ToolArgViolation describes one tool call that failed schema validation
+type ToolArgViolation struct {
+	ToolUseID string   `json:"toolUseId"`
+	Tool      string   `json:"tool"`
+	Errors    []string `json:"errors"`
+}
+
+// toolSchemaCache keeps each project's compiled tool schemas, recompiling only
+// when the project's settings change.
+type toolSchemaCache struct {
+	mu       sync.Mutex
+	projects map[string]compiledToolSchemas
+}
+
+type compiledToolSchemas struct {
+	fingerprint string
+	schemas     map[string]*jsonschema.Schema
+}
+
+func newToolSchemaCache() *toolSchemaCache {
+	return &toolSchemaCache{projects: make(map[string]compiledToolSchemas)}
+}
+
+// get returns the compiled schemas for the project. Schemas that fail to
+// compile are logged and skipped so one bad entry doesn't disable the rest.
+func (c *toolSchemaCache) get(projectID string, raw map[string]any) map[string]*jsonschema.Schema {
+	fingerprint, err := json.Marshal(raw)
+	if err != nil {
+		slog.Warn("invalid tool schemas", "project_id", projectID, "error", err)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.projects[projectID]; ok && cached.fingerprint == string(fingerprint) {
+		return cached.schemas
+	}
+
+	schemas := make(map[string]*jsonschema.Schema, len(raw))
+	for tool, doc := range raw {
+		schema, err := compileToolSchema(tool, doc)
+		if err != nil {
+			slog.Warn("invalid tool schema", "project_id", projectID, "tool", tool, "error", err)
+			continue
+		}
+		schemas[tool] = schema
+	}
+
+	c.projects[projectID] = compiledToolSchemas{fingerprint: string(fingerprint), schemas: schemas}
+	return schemas
+}
+
+func compileToolSchema(tool string, doc any) (*jsonschema.Schema, error) {
+	url := "tool://" + tool
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(url)
+}
+
+// validateToolArgs checks the extracted tool calls of each span against the
+// project's tool schemas and records violations in the span metadata.
+// Validation never rejects a span; tools without a schema are ignored.
+func (p *EventProcessor) validateToolArgs(projectID string, spans []entity.Span, raw map[string]any) {
+	if len(raw) == 0 {
+		return
+	}
+
+	var schemas map[string]*jsonschema.Schema
+	for i := range spans {
+		span := &spans[i]
+		if len(span.ToolUses) == 0 {
+			continue
+		}
+		if schemas == nil {
+			schemas = p.schemas.get(projectID, raw)
+		}
+
+		var violations []ToolArgViolation
+		for _, tu := range span.ToolUses {
+			schema, ok := schemas[tu.Name]
+			if !ok {
+				continue
+			}
+			if errs := validateToolInput(schema, tu.Input); len(errs) > 0 {
+				violations = append(violations, ToolArgViolation{ToolUseID: tu.ID, Tool: tu.Name, Errors: errs})
+			}
+		}
+
+		if len(violations) > 0 {
+			if span.Metadata == nil {
+				span.Metadata = make(map[string]any)
+			}
+			span.Metadata[MetadataToolArgViolations] = violations
+		}
+	}
+}
+
+// validateToolInput returns the schema errors for a tool call's arguments.
+// OpenAI-style providers send arguments as a JSON-encoded string, so strings are
+// decoded first.
+func validateToolInput(schema *jsonschema.Schema, input any) []string {
+	if s, ok := input.(string); ok {
+		var decoded any
+		if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+			return []string{"arguments are not valid JSON"}
+		}
+		input = decoded
+	} else {
+		// Normalize to plain JSON values (e.g. typed structs from parsers)
+		b, err := json.Marshal(input)
+		if err != nil {
+			return []string{"arguments are not valid JSON"}
+		}
+		input = nil
+		json.Unmarshal(b, &input)
+	}
+
+	err := schema.Validate(input)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []string{err.Error()}
+	}
+
+	var errs []string
+	for _, unit := range verr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		loc := unit.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", loc, unit.Error))
+	}
+	sort.Strings(errs)
+	return errs
+}
@@ -0,0 +1,63 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateToolInput(t *testing.T) {
+	schema, err := compileToolSchema("get_weather", map[string]any{
+		"type":       "object",
+		"required":   []any{"city"},
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   any
+		wantErr string // substring of the first error; "" = valid
+	}{
+		{"valid object", map[string]any{"city": "Lima"}, ""},
+		{"valid JSON string arguments", `{"city":"Lima"}`, ""},
+		{"missing required property", map[string]any{}, "city"},
+		{"wrong type", map[string]any{"city": 42}, "/city"},
+		{"wrong type in JSON string", `{"city":42}`, "/city"},
+		{"malformed JSON string", `{"city":`, "not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateToolInput(schema, tt.input)
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) == 0 || !strings.Contains(errs[0], tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestToolSchemaCache_SkipsInvalidSchemas(t *testing.T) {
+	c := newToolSchemaCache()
+	raw := map[string]any{
+		"good": map[string]any{"type": "object"},
+		"bad":  map[string]any{"type": 12},
+	}
+
+	schemas := c.get("p1", raw)
+	if _, ok := schemas["good"]; !ok {
+		t.Error("expected the valid schema to compile")
+	}
+	if _, ok := schemas["bad"]; ok {
+		t.Error("expected the invalid schema to be skipped")
+	}
+	if again := c.get("p1", raw); again["good"] != schemas["good"] {
+		t.Error("expected unchanged settings to reuse compiled schemas")
+	}
+}
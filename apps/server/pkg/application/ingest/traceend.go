@@ -0,0 +1,95 @@
+package ingest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// Event types (see IngestEvent.Type)
+const (
+	EventTypeSpan     = "span"      // the default: the event is a span
+	EventTypeTraceEnd = "trace.end" // finalizes the event's trace
+)
+
+// Trace metadata keys set by a trace.end event, next to the "input" a trace
+// copies from its first event
+const (
+	MetadataTraceOutput  = "output"
+	MetadataTraceEndedAt = "ended_at" // RFC 3339; marks the trace as explicitly ended
+)
+
+// isTraceEnd reports whether event finalizes its trace instead of being a span
+func isTraceEnd(event IngestEvent) bool {
+	return event.Type == EventTypeTraceEnd
+}
+
+// hasTraceEnds reports whether any of events is a trace.end event
+func hasTraceEnds(events []IngestEvent) bool {
+	for _, event := range events {
+		if isTraceEnd(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTraceEnd reports why a trace.end event can't be ingested: it needs
+// the trace it ends, and a trace status (completed, the default, or error)
+func checkTraceEnd(event IngestEvent) error {
+	if event.TraceID == "" {
+		return fmt.Errorf("%s event without a traceId", EventTypeTraceEnd)
+	}
+	switch entity.TraceStatus(event.Status) {
+	case "", entity.TraceStatusCompleted, entity.TraceStatusError:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s status %q (completed or error)", EventTypeTraceEnd, event.Status)
+	}
+}
+
+// splitTraceEnds separates a trace group's span events from its trace.end
+// events, each kept in order
+func splitTraceEnds(events []IngestEvent) (spans, ends []IngestEvent) {
+	if !hasTraceEnds(events) {
+		return events, nil
+	}
+	for _, event := range events {
+		if isTraceEnd(event) {
+			ends = append(ends, event)
+		} else {
+			spans = append(spans, event)
+		}
+	}
+	return spans, ends
+}
+
+// traceEndStatus returns the status a trace.end event gives its trace
+func traceEndStatus(end IngestEvent) entity.TraceStatus {
+	if end.Status == "" {
+		return entity.TraceStatusCompleted
+	}
+	return entity.TraceStatus(end.Status)
+}
+
+// finalizeTrace records a trace.end event in its trace's metadata: the
+// final output, if any, and when the trace ended (the event's timestamp, or
+// now)
+func finalizeTrace(metadata map[string]any, end IngestEvent, now time.Time) {
+	if end.Output != nil {
+		metadata[MetadataTraceOutput] = end.Output
+	}
+	endedAt := now
+	if end.Timestamp != nil {
+		endedAt = *end.Timestamp
+	}
+	metadata[MetadataTraceEndedAt] = endedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// explicitlyEnded reports whether a trace.end event has finalized the trace
+// with metadata, whose status then no longer follows its spans
+func explicitlyEnded(metadata map[string]any) bool {
+	_, ok := metadata[MetadataTraceEndedAt]
+	return ok
+}
@@ -0,0 +1,123 @@
+package ingest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_TraceEnd(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	project := newTestProject(t, store, "traceend", entity.ProjectSettings{})
+
+	// ingest sends one batch and returns the trace after it
+	ingest := func(t *testing.T, traceID string, events ...IngestEvent) *entity.TraceWithSpans {
+		t.Helper()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil {
+			t.Fatalf("failed to get trace: %v", err)
+		}
+		return trace
+	}
+	span := func(traceID, spanID, parentID, status string) IngestEvent {
+		if parentID != "" {
+			parentID = traceID + "-" + parentID
+		}
+		return IngestEvent{TraceID: traceID, SpanID: traceID + "-" + spanID, ParentSpanID: parentID, SpanType: "tool", Name: spanID, Status: status}
+	}
+	end := func(traceID, status string) IngestEvent {
+		return IngestEvent{Type: EventTypeTraceEnd, TraceID: traceID, Status: status}
+	}
+
+	t.Run("start, spans, then end finalize the trace", func(t *testing.T) {
+		const traceID = "end-run"
+		if trace := ingest(t, traceID, span(traceID, "root", "", "pending")); trace.Status != entity.TraceStatusActive {
+			t.Fatalf("expected the started trace to be active, got %s", trace.Status)
+		}
+		if trace := ingest(t, traceID, span(traceID, "step", "root", "success")); trace.Status != entity.TraceStatusActive {
+			t.Fatalf("expected the trace to stay active until it ends, got %s", trace.Status)
+		}
+
+		endedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		finish := end(traceID, "")
+		finish.Output = map[string]any{"answer": "42"}
+		finish.Timestamp = &endedAt
+		trace := ingest(t, traceID, finish)
+
+		if trace.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected completed, got %s", trace.Status)
+		}
+		if len(trace.Spans) != 2 {
+			t.Errorf("expected the end event to add no span, got %d spans", len(trace.Spans))
+		}
+		if got := trace.Metadata[MetadataTraceOutput]; !reflect.DeepEqual(got, map[string]any{"answer": "42"}) {
+			t.Errorf("expected the final output, got %v", got)
+		}
+		if got := trace.Metadata[MetadataTraceEndedAt]; got != endedAt.Format(time.RFC3339Nano) {
+			t.Errorf("expected ended_at %s, got %v", endedAt.Format(time.RFC3339Nano), got)
+		}
+	})
+
+	t.Run("the end status overrides inference", func(t *testing.T) {
+		const traceID = "end-override"
+		trace := ingest(t, traceID,
+			span(traceID, "root", "", "success"),
+			span(traceID, "step", "root", "error"),
+			end(traceID, "completed"),
+		)
+		if trace.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected the explicit completed status, got %s", trace.Status)
+		}
+
+		// A late failed span doesn't reopen an explicitly ended trace
+		if trace := ingest(t, traceID, span(traceID, "late", "root", "error")); trace.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected the trace to stay completed, got %s", trace.Status)
+		}
+	})
+
+	t.Run("an end alone creates the trace", func(t *testing.T) {
+		const traceID = "end-only"
+		if trace := ingest(t, traceID, end(traceID, "error")); trace.Status != entity.TraceStatusError {
+			t.Errorf("expected error, got %s", trace.Status)
+		}
+	})
+
+	t.Run("without an end the status is inferred", func(t *testing.T) {
+		const traceID = "end-inferred"
+		if trace := ingest(t, traceID, span(traceID, "root", "", "success")); trace.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected the root span to complete the trace, got %s", trace.Status)
+		}
+		if trace := ingest(t, traceID, span(traceID, "late", "root", "error")); trace.Status != entity.TraceStatusError {
+			t.Errorf("expected a late failed span to error the trace, got %s", trace.Status)
+		}
+	})
+
+	t.Run("invalid end events are rejected", func(t *testing.T) {
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			end("", ""),
+			end("end-invalid", "success"),
+			span("end-invalid", "root", "", "success"),
+		}})
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		if resp.Processed != 1 || len(resp.Errors) != 2 {
+			t.Fatalf("expected 1 processed and 2 errors, got %+v", resp)
+		}
+		if !strings.Contains(resp.Errors[0].Message, "traceId") || !strings.Contains(resp.Errors[1].Message, "status") {
+			t.Errorf("unexpected errors: %+v", resp.Errors)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// traceNamePreviewRunes bounds a trace name derived from an input preview
+const traceNamePreviewRunes = 80
+
+// traceNameSources returns the project's trace name fallback chain
+func traceNameSources(settings entity.ProjectSettings) []string {
+	if len(settings.TraceNameSources) == 0 {
+		return entity.DefaultTraceNameSources
+	}
+	return settings.TraceNameSources
+}
+
+// deriveTraceName names a trace that has no named agent span from its events,
+// trying sources in order; "" when none applies
+func deriveTraceName(events []IngestEvent, sources []string) string {
+	for _, source := range sources {
+		for _, event := range events {
+			var name string
+			switch source {
+			case entity.TraceNameSourceRoot:
+				if event.ParentSpanID == "" {
+					name = coalesce(event.Name, event.Model)
+				}
+			case entity.TraceNameSourceLLM:
+				if parseSpanType(event.SpanType) == entity.SpanTypeLLM {
+					name = coalesce(event.Name, event.Model)
+				}
+			case entity.TraceNameSourceInput:
+				name = inputPreview(event.Input)
+			}
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// inputPreview renders a span input as one line of at most
+// traceNamePreviewRunes runes
+func inputPreview(input any) string {
+	var text string
+	switch v := input.(type) {
+	case nil:
+		return ""
+	case string:
+		text = v
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		text = string(encoded)
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > traceNamePreviewRunes {
+		text = string(runes[:traceNamePreviewRunes-1]) + "…"
+	}
+	return text
+}
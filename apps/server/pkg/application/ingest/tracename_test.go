@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_TraceName(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	longInput := "  Summarize\nthis " + strings.Repeat("very ", 30) + "long document"
+	tests := []struct {
+		name    string
+		sources []string
+		events  []IngestEvent
+		want    string // "" expects no name
+	}{
+		{
+			name: "agent span",
+			events: []IngestEvent{
+				{SpanID: "root", SpanType: "custom", Name: "pipeline", Status: "success"},
+				{SpanID: "agent", ParentSpanID: "root", SpanType: "agent", Name: "support-agent", Status: "success"},
+			},
+			want: "support-agent",
+		},
+		{
+			name: "root span",
+			events: []IngestEvent{
+				{SpanID: "llm", ParentSpanID: "root", SpanType: "llm", Model: "gpt-4o", Status: "success"},
+				{SpanID: "root", SpanType: "custom", Name: "pipeline", Status: "success"},
+			},
+			want: "pipeline",
+		},
+		{
+			name: "first llm span",
+			events: []IngestEvent{
+				{SpanID: "tool", ParentSpanID: "missing", SpanType: "tool", Name: "search", Status: "success"},
+				{SpanID: "llm", ParentSpanID: "missing", SpanType: "llm", Model: "gpt-4o", Status: "success"},
+			},
+			want: "gpt-4o",
+		},
+		{
+			name: "input preview",
+			events: []IngestEvent{
+				{SpanID: "tool", ParentSpanID: "missing", SpanType: "tool", Input: longInput, Status: "success"},
+			},
+			want: "Summarize this very very very very very very very very very very very very very…",
+		},
+		{
+			name:    "configured order",
+			sources: []string{entity.TraceNameSourceLLM, entity.TraceNameSourceRoot},
+			events: []IngestEvent{
+				{SpanID: "root", SpanType: "custom", Name: "pipeline", Status: "success"},
+				{SpanID: "llm", ParentSpanID: "root", SpanType: "llm", Name: "chat", Status: "success"},
+			},
+			want: "chat",
+		},
+		{
+			name:    "fallback disabled",
+			sources: []string{entity.TraceNameSourceNone},
+			events: []IngestEvent{
+				{SpanID: "root", SpanType: "custom", Name: "pipeline", Status: "success"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := newTestProject(t, store, tt.name, entity.ProjectSettings{TraceNameSources: tt.sources})
+			// Span and trace IDs are unique across projects
+			traceID := "trace/" + tt.name
+			for i, event := range tt.events {
+				tt.events[i].TraceID = traceID
+				tt.events[i].SpanID = tt.name + "/" + event.SpanID
+				if event.ParentSpanID != "" {
+					tt.events[i].ParentSpanID = tt.name + "/" + event.ParentSpanID
+				}
+			}
+			resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: tt.events})
+			if err != nil || !resp.Success {
+				t.Fatalf("ingest failed: %v %+v", err, resp)
+			}
+
+			trace, err := store.GetTrace(ctx, project.ID, traceID)
+			if err != nil {
+				t.Fatalf("GetTrace failed: %v", err)
+			}
+			got := ""
+			if trace.Name != nil {
+				got = *trace.Name
+			}
+			if got != tt.want {
+				t.Errorf("expected trace name %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package ingest
+
+import "github.com/lelemon/server/pkg/domain/entity"
+
+// MetadataRequiredSpan is the span metadata flag, set by the client, marking a
+// span whose failure fails its trace under TraceErrorRuleRootSpan as a root
+// span's does
+const MetadataRequiredSpan = "_required"
+
+// traceErrorRule returns the project's trace error rule
+func traceErrorRule(settings entity.ProjectSettings) string {
+	if settings.TraceErrorRule == "" {
+		return entity.TraceErrorRuleAnySpan
+	}
+	return settings.TraceErrorRule
+}
+
+// nextTraceStatus returns the status of a trace once spans are added to it,
+// from its current status:
+//   - error is final
+//   - a failed span (error or timeout) errors the trace: any span under
+//     TraceErrorRuleAnySpan, only a root or required span under
+//     TraceErrorRuleRootSpan
+//   - an active trace completes when a root span ends (any status but pending)
+//   - otherwise the trace keeps its status, so a trace stays active until its
+//     root span arrives
+func nextTraceStatus(current entity.TraceStatus, spans []entity.Span, errorRule string) entity.TraceStatus {
+	if current == entity.TraceStatusError {
+		return current
+	}
+
+	next := current
+	for _, span := range spans {
+		root := span.ParentSpanID == nil
+		if span.Status.Failed() && (errorRule != entity.TraceErrorRuleRootSpan || root || requiredSpan(span)) {
+			return entity.TraceStatusError
+		}
+		if root && span.Status != entity.SpanStatusPending && next == entity.TraceStatusActive {
+			next = entity.TraceStatusCompleted
+		}
+	}
+	return next
+}
+
+// requiredSpan reports whether the client flagged span as required
+func requiredSpan(span entity.Span) bool {
+	required, _ := span.Metadata[MetadataRequiredSpan].(bool)
+	return required
+}
@@ -0,0 +1,107 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestNextTraceStatus(t *testing.T) {
+	parent := "root"
+	root := func(status entity.SpanStatus) entity.Span { return entity.Span{Status: status} }
+	child := func(status entity.SpanStatus) entity.Span { return entity.Span{ParentSpanID: &parent, Status: status} }
+	required := child(entity.SpanStatusTimeout)
+	required.Metadata = map[string]any{MetadataRequiredSpan: true}
+
+	const (
+		active    = entity.TraceStatusActive
+		completed = entity.TraceStatusCompleted
+		failed    = entity.TraceStatusError
+	)
+	tests := []struct {
+		name    string
+		current entity.TraceStatus
+		spans   []entity.Span
+		rule    string
+		want    entity.TraceStatus
+	}{
+		{"children alone keep the trace active", active, []entity.Span{child(entity.SpanStatusSuccess)}, entity.TraceErrorRuleAnySpan, active},
+		{"a pending root keeps the trace active", active, []entity.Span{root(entity.SpanStatusPending)}, entity.TraceErrorRuleAnySpan, active},
+		{"an ended root completes the trace", active, []entity.Span{child(entity.SpanStatusSuccess), root(entity.SpanStatusSuccess)}, entity.TraceErrorRuleAnySpan, completed},
+		{"a cancelled root completes the trace", active, []entity.Span{root(entity.SpanStatusCancelled)}, entity.TraceErrorRuleAnySpan, completed},
+		{"a failed child errors the trace", active, []entity.Span{root(entity.SpanStatusSuccess), child(entity.SpanStatusError)}, entity.TraceErrorRuleAnySpan, failed},
+		{"a late failed child errors a completed trace", completed, []entity.Span{child(entity.SpanStatusTimeout)}, entity.TraceErrorRuleAnySpan, failed},
+		{"root rule ignores failed children", active, []entity.Span{child(entity.SpanStatusError), root(entity.SpanStatusSuccess)}, entity.TraceErrorRuleRootSpan, completed},
+		{"root rule errors on a failed root", active, []entity.Span{root(entity.SpanStatusTimeout)}, entity.TraceErrorRuleRootSpan, failed},
+		{"root rule errors on a failed required span", active, []entity.Span{required}, entity.TraceErrorRuleRootSpan, failed},
+		{"error is final", failed, []entity.Span{root(entity.SpanStatusSuccess)}, entity.TraceErrorRuleAnySpan, failed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTraceStatus(tt.current, tt.spans, tt.rule); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIngest_TraceStatusTransitions(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	svc := NewService(store, service.NewPricingCalculator())
+
+	// ingest sends one batch and returns the trace's status after it
+	ingest := func(t *testing.T, project *entity.Project, events ...IngestEvent) entity.TraceStatus {
+		t.Helper()
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+		trace, err := store.GetTrace(ctx, project.ID, events[0].TraceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		return trace.Status
+	}
+	span := func(traceID, spanID, parentID, status string) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanID: spanID, ParentSpanID: parentID, SpanType: "tool", Name: spanID, Status: status}
+	}
+
+	t.Run("active until the root span ends", func(t *testing.T) {
+		project := newTestProject(t, store, "status_any", entity.ProjectSettings{})
+		if got := ingest(t, project, span("pending", "pending/root", "", "pending")); got != entity.TraceStatusActive {
+			t.Errorf("pending root: expected active, got %s", got)
+		}
+
+		if got := ingest(t, project, span("streamed", "streamed/step", "streamed/root", "success")); got != entity.TraceStatusActive {
+			t.Errorf("after a child: expected active, got %s", got)
+		}
+		if got := ingest(t, project, span("streamed", "streamed/root", "", "success")); got != entity.TraceStatusCompleted {
+			t.Errorf("after the root ended: expected completed, got %s", got)
+		}
+		if got := ingest(t, project, span("streamed", "streamed/retry", "streamed/root", "error")); got != entity.TraceStatusError {
+			t.Errorf("after a failed child: expected error, got %s", got)
+		}
+		if got := ingest(t, project, span("streamed", "streamed/late", "streamed/root", "success")); got != entity.TraceStatusError {
+			t.Errorf("after a later child: expected error to stay, got %s", got)
+		}
+	})
+
+	t.Run("root error rule", func(t *testing.T) {
+		project := newTestProject(t, store, "status_root", entity.ProjectSettings{TraceErrorRule: entity.TraceErrorRuleRootSpan})
+		if got := ingest(t, project,
+			span("recovered", "recovered/root", "", "success"), span("recovered", "recovered/flaky", "recovered/root", "error"),
+		); got != entity.TraceStatusCompleted {
+			t.Errorf("failed child: expected completed, got %s", got)
+		}
+
+		critical := span("critical", "critical/charge", "critical/root", "timeout")
+		critical.Metadata = map[string]any{MetadataRequiredSpan: true}
+		if got := ingest(t, project, critical); got != entity.TraceStatusError {
+			t.Errorf("failed required span: expected error, got %s", got)
+		}
+	})
+}
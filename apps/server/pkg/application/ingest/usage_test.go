@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+type recordedUsage struct {
+	projectID     string
+	traces, spans int
+}
+
+type mockUsageRecorder struct {
+	calls []recordedUsage
+}
+
+func (m *mockUsageRecorder) RecordUsage(projectID string, traces, spans int) {
+	m.calls = append(m.calls, recordedUsage{projectID, traces, spans})
+}
+
+func TestIngest_RecordsUsagePerBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "usage", entity.ProjectSettings{IngestDedup: &entity.IngestDedupSettings{Enabled: true, WindowSeconds: 60}})
+
+	recorder := &mockUsageRecorder{}
+	svc := NewService(store, service.NewPricingCalculator())
+	svc.SetUsageRecorder(recorder)
+
+	ingest := func(events ...IngestEvent) {
+		t.Helper()
+		if resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: events}); err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+	}
+	at := time.Now()
+	span := func(traceID, name string) IngestEvent {
+		return IngestEvent{TraceID: traceID, SpanType: "tool", Name: name, Status: "success", Timestamp: &at}
+	}
+
+	// Two new traces and three spans in one batch: a single report
+	ingest(span("t1", "a"), span("t1", "b"), span("t2", "a"))
+	// More spans for an existing trace count spans only; the duplicate is dropped
+	ingest(span("t1", "c"), span("t1", "c"))
+	// Legacy session events get a fresh trace
+	ingest(IngestEvent{SessionID: "s1", SpanType: "tool", Name: "legacy", Status: "success"})
+
+	want := []recordedUsage{{project.ID, 2, 3}, {project.ID, 0, 1}, {project.ID, 1, 1}}
+	if len(recorder.calls) != len(want) {
+		t.Fatalf("expected %d reports, got %+v", len(want), recorder.calls)
+	}
+	for i, w := range want {
+		if recorder.calls[i] != w {
+			t.Errorf("report %d: expected %+v, got %+v", i, w, recorder.calls[i])
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+)
+
+// walCompactBytes is the log size past which it is rewritten with only the
+// pending jobs, once those take up less than half of it
+const walCompactBytes = 16 << 20
+
+// walMaxAttempts is how many times a job may fail to be processed before it
+// is moved out of the log to its dead-letter file (the log's path plus
+// ".dead"), so a job that can never be stored isn't replayed on every start
+const walMaxAttempts = 5
+
+// walRecord is one line of the write-ahead log: a job as it was enqueued
+// (Job set), the failed processing attempts of job ID so far (Attempts set)
+// or the completion of job ID
+type walRecord struct {
+	ID       uint64 `json:"id"`
+	Job      *Job   `json:"job,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// walEntry locates a pending job's record in the log file
+type walEntry struct {
+	offset   int64
+	size     int64
+	attempts int // failed processing attempts so far
+}
+
+// jobWAL is a write-ahead log of async ingest jobs: an append-only file of
+// JSON lines where a job is recorded, and synced, before it is queued and
+// marked done once its batch is stored. Jobs still pending when the process
+// dies are replayed on the next start (see openJobWAL): at least once, as a
+// crash between storing a batch and marking it done replays it too. Only the
+// location of pending jobs is kept in memory; they are read back from the
+// file when replayed.
+type jobWAL struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	nextID  uint64
+	pending map[uint64]*walEntry // job ID -> its record in the file
+	live    int64                // bytes of the pending records
+}
+
+// openJobWAL opens (creating if needed) the log at path and returns the IDs
+// of the jobs it holds that were never marked done, in the order they were
+// enqueued (see job to read them). A torn last line, left by a crash
+// mid-write, is skipped.
+func openJobWAL(path string) (*jobWAL, []uint64, error) {
+	w := &jobWAL{path: path, pending: make(map[uint64]*walEntry)}
+
+	if f, err := os.Open(path); err == nil {
+		r := bufio.NewReaderSize(f, 64<<10)
+		var offset int64
+		for line := 1; ; line++ {
+			record, err := r.ReadBytes('\n')
+			start := offset
+			offset += int64(len(record))
+			if errors.Is(err, io.EOF) {
+				if len(record) > 0 {
+					slog.Warn("skipping torn ingest WAL record", "path", path, "line", line)
+				}
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, nil, fmt.Errorf("read ingest WAL: %w", err)
+			}
+
+			var rec walRecord
+			if err := json.Unmarshal(record, &rec); err != nil {
+				slog.Warn("skipping unreadable ingest WAL record", "path", path, "line", line, "error", err)
+				continue
+			}
+			w.nextID = max(w.nextID, rec.ID)
+			switch {
+			case rec.Job != nil:
+				w.pending[rec.ID] = &walEntry{offset: start, size: int64(len(record))}
+			case rec.Attempts > 0:
+				if e, ok := w.pending[rec.ID]; ok {
+					e.attempts = rec.Attempts
+				}
+			default:
+				delete(w.pending, rec.ID)
+			}
+		}
+		f.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("open ingest WAL: %w", err)
+	}
+
+	// Start from a log holding just the pending jobs
+	if err := w.rewrite(); err != nil {
+		return nil, nil, err
+	}
+	return w, w.pendingIDs(), nil
+}
+
+// append records job, synced to disk, and returns its log ID
+func (w *jobWAL) append(job Job) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextID++
+	id := w.nextID
+	line, err := json.Marshal(walRecord{ID: id, Job: &job})
+	if err != nil {
+		return 0, fmt.Errorf("encode ingest WAL record: %w", err)
+	}
+	line = append(line, '\n')
+	offset := w.size
+	if err := w.write(line); err != nil {
+		return 0, err
+	}
+	w.pending[id] = &walEntry{offset: offset, size: int64(len(line))}
+	w.live += int64(len(line))
+	return id, nil
+}
+
+// job reads pending job id back from the log
+func (w *jobWAL) job(id uint64) (Job, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line, err := w.read(id)
+	if err != nil {
+		return Job{}, err
+	}
+	var rec walRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return Job{}, fmt.Errorf("decode ingest WAL job %d: %w", id, err)
+	}
+	if rec.Job == nil {
+		return Job{}, fmt.Errorf("ingest WAL record %d holds no job", id)
+	}
+	rec.Job.walID = id
+	return *rec.Job, nil
+}
+
+// done marks job id as stored, so it is not replayed
+func (w *jobWAL) done(id uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.remove(id)
+}
+
+// fail records a failed processing attempt of job id, which stays pending for
+// the next start. Its last allowed attempt moves it to the dead-letter file
+// instead, which fail reports.
+func (w *jobWAL) fail(id uint64) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e, ok := w.pending[id]
+	if !ok {
+		return false, nil
+	}
+	e.attempts++
+	if e.attempts < walMaxAttempts {
+		line, _ := json.Marshal(walRecord{ID: id, Attempts: e.attempts})
+		return false, w.write(append(line, '\n'))
+	}
+
+	line, err := w.read(id)
+	if err != nil {
+		return false, err
+	}
+	if err := appendDeadLetter(w.path+".dead", line); err != nil {
+		return false, err
+	}
+	return true, w.remove(id)
+}
+
+// remove drops job id from the pending jobs. The log is emptied when no job
+// is pending, and compacted when mostly made of finished jobs.
+func (w *jobWAL) remove(id uint64) error {
+	e, ok := w.pending[id]
+	if !ok {
+		return nil
+	}
+	delete(w.pending, id)
+	w.live -= e.size
+
+	if len(w.pending) == 0 || (w.size > walCompactBytes && w.live < w.size/2) {
+		return w.rewrite()
+	}
+	done, _ := json.Marshal(walRecord{ID: id})
+	return w.write(append(done, '\n'))
+}
+
+// read returns the record of pending job id, read from the log file
+func (w *jobWAL) read(id uint64) ([]byte, error) {
+	e, ok := w.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("ingest WAL job %d is not pending", id)
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("read ingest WAL: %w", err)
+	}
+	defer f.Close()
+	return readRecord(f, e)
+}
+
+func readRecord(f *os.File, e *walEntry) ([]byte, error) {
+	line := make([]byte, e.size)
+	if _, err := f.ReadAt(line, e.offset); err != nil {
+		return nil, fmt.Errorf("read ingest WAL: %w", err)
+	}
+	return line, nil
+}
+
+// pendingIDs returns the IDs of the pending jobs, in the order they were
+// enqueued
+func (w *jobWAL) pendingIDs() []uint64 {
+	ids := make([]uint64, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (w *jobWAL) write(line []byte) error {
+	if w.file == nil {
+		return errors.New("ingest WAL is closed")
+	}
+	if _, err := w.file.Write(line); err != nil {
+		return fmt.Errorf("write ingest WAL: %w", err)
+	}
+	w.size += int64(len(line))
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("sync ingest WAL: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the log with one holding only the pending jobs, and their
+// attempts, copied from it through a temporary file renamed over it
+func (w *jobWAL) rewrite() error {
+	ids := w.pendingIDs()
+
+	tmp, err := os.OpenFile(w.path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("compact ingest WAL: %w", err)
+	}
+	offsets := make(map[uint64]int64, len(ids))
+	var size, live int64
+	if len(ids) > 0 {
+		src, err := os.Open(w.path)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("compact ingest WAL: %w", err)
+		}
+		for _, id := range ids {
+			e := w.pending[id]
+			line, err := readRecord(src, e)
+			if err == nil && e.attempts > 0 {
+				attempts, _ := json.Marshal(walRecord{ID: id, Attempts: e.attempts})
+				line = append(append(line, attempts...), '\n')
+			}
+			if err == nil {
+				_, err = tmp.Write(line)
+			}
+			if err != nil {
+				src.Close()
+				tmp.Close()
+				return fmt.Errorf("compact ingest WAL: %w", err)
+			}
+			offsets[id] = size
+			size += int64(len(line))
+			live += e.size
+		}
+		src.Close()
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact ingest WAL: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(w.path+".tmp", w.path); err != nil {
+		return fmt.Errorf("compact ingest WAL: %w", err)
+	}
+	for id, offset := range offsets {
+		w.pending[id].offset = offset
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		w.file = nil
+		return fmt.Errorf("open ingest WAL: %w", err)
+	}
+	w.size, w.live = size, live
+	return nil
+}
+
+// appendDeadLetter appends a job's record, synced, to the dead-letter file
+// at path
+func appendDeadLetter(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open ingest dead-letter file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("write ingest dead-letter file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync ingest dead-letter file: %w", err)
+	}
+	return nil
+}
+
+// close closes the log file; jobs still pending stay in it for the next start
+func (w *jobWAL) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
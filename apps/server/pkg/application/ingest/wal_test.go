@@ -0,0 +1,378 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_WALReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestStore(t)
+	project := newTestProject(t, store, "wal", entity.ProjectSettings{})
+	walPath := dir + "/ingest.wal"
+
+	// First run: jobs are queued but the process dies before any worker
+	// stores them
+	wal, pending, err := openJobWAL(walPath)
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected an empty WAL, got %d jobs", len(pending))
+	}
+	crashed := NewWorker(NewEventProcessor(store, service.NewPricingCalculator()), 10)
+	crashed.wal = wal
+	for i := 0; i < 3; i++ {
+		queued := crashed.Enqueue(Job{ProjectID: project.ID, Events: []IngestEvent{
+			{TraceID: fmt.Sprintf("wal-trace-%d", i), SpanType: "tool", Name: "search", Status: "success"},
+		}})
+		if !queued {
+			t.Fatalf("job %d was not queued", i)
+		}
+	}
+	wal.close()
+
+	// Restart: the jobs left in the WAL are replayed and stored
+	svc := NewAsyncService(store, service.NewPricingCalculator(), 10, 1)
+	if err := svc.EnableWAL(walPath); err != nil {
+		t.Fatalf("failed to enable WAL: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("failed to list traces: %v", err)
+		}
+		if page.Total == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 replayed traces, got %d", page.Total)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	svc.Stop(5 * time.Second)
+
+	// Stored jobs are gone from the WAL: nothing is replayed again
+	if info, err := os.Stat(walPath); err != nil || info.Size() != 0 {
+		t.Errorf("expected an empty WAL once the jobs are stored, got %v (%v)", info, err)
+	}
+	reopened, pending, err := openJobWAL(walPath)
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer reopened.close()
+	if len(pending) != 0 {
+		t.Errorf("expected no jobs to replay, got %d", len(pending))
+	}
+}
+
+func TestIngest_WALKeepsNoSecretsOrPII(t *testing.T) {
+	store := newTestStore(t)
+	webhookURL := "https://hooks.example.com/lelemon"
+	project := newTestProject(t, store, "wal-secrets", entity.ProjectSettings{
+		Redaction:     &entity.RedactionSettings{Enabled: true},
+		WebhookURL:    &webhookURL,
+		WebhookEvents: []string{entity.WebhookEventTraceCompleted},
+		WebhookSecret: "whsec-do-not-persist",
+	})
+	walPath := t.TempDir() + "/ingest.wal"
+
+	wal, _, err := openJobWAL(walPath)
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	worker := NewWorker(NewEventProcessor(store, service.NewPricingCalculator()), 10)
+	worker.wal = wal
+	worker.Enqueue(Job{ProjectID: project.ID, Options: NewProcessOptions(project.Settings), Events: []IngestEvent{
+		{TraceID: "wal-pii", SpanType: "llm", Model: "gpt-4o", Status: "success",
+			Input:  []any{map[string]any{"role": "user", "content": "Mail jane@example.com"}},
+			Output: "Call (555) 123-4567"},
+	}})
+	wal.close()
+
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("failed to read WAL: %v", err)
+	}
+	for _, leaked := range []string{"jane@example.com", "123-4567", "whsec-do-not-persist"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("expected %q kept out of the WAL, got %s", leaked, data)
+		}
+	}
+
+	// Replayed jobs take their options from the project's settings
+	wal, pending, err := openJobWAL(walPath)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected 1 pending job, got %d (%v)", len(pending), err)
+	}
+	defer wal.close()
+	job, err := wal.job(pending[0])
+	if err != nil {
+		t.Fatalf("failed to read job: %v", err)
+	}
+	if !worker.loadOptions(&job) {
+		t.Fatal("expected the job's options to be loaded")
+	}
+	if job.Options.Webhook == nil || job.Options.Webhook.Secret != "whsec-do-not-persist" {
+		t.Errorf("expected the webhook secret from the project's settings, got %+v", job.Options.Webhook)
+	}
+	if job.Options.Redaction == nil || !job.Options.Redaction.Enabled {
+		t.Errorf("expected the project's redaction settings, got %+v", job.Options.Redaction)
+	}
+}
+
+func TestIngest_MaintenanceDefersToWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestStore(t)
+	project := newTestProject(t, store, "maintenance", entity.ProjectSettings{})
+	walPath := dir + "/ingest.wal"
+
+	if err := NewService(store, service.NewPricingCalculator()).SetMaintenance(true); err != ErrMaintenanceNeedsWAL {
+		t.Errorf("expected sync ingest to refuse maintenance mode, got %v", err)
+	}
+	svc := NewAsyncService(store, service.NewPricingCalculator(), 10, 1)
+	defer svc.Stop(5 * time.Second)
+	if err := svc.SetMaintenance(true); err != ErrMaintenanceNeedsWAL {
+		t.Errorf("expected maintenance mode to need the WAL, got %v", err)
+	}
+	if err := svc.EnableWAL(walPath); err != nil {
+		t.Fatalf("failed to enable WAL: %v", err)
+	}
+	if err := svc.SetMaintenance(true); err != nil {
+		t.Fatalf("failed to enable maintenance mode: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+			{TraceID: fmt.Sprintf("deferred-trace-%d", i), SpanType: "tool", Name: "search", Status: "success"},
+		}})
+		if err != nil || !resp.Success || resp.Processed != 1 {
+			t.Fatalf("expected batch %d accepted, got %+v (%v)", i, resp, err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond) // a worker would have stored them by now
+
+	page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to list traces: %v", err)
+	}
+	if page.Total != 0 {
+		t.Errorf("expected nothing stored during maintenance, got %d traces", page.Total)
+	}
+	if stats := svc.WorkerStats(); !stats.Maintenance || stats.DeferredJobs != 3 {
+		t.Errorf("expected 3 deferred jobs in maintenance, got %+v", stats)
+	}
+	if info, err := os.Stat(walPath); err != nil || info.Size() == 0 {
+		t.Errorf("expected the deferred jobs in the WAL, got %v (%v)", info, err)
+	}
+
+	if err := svc.SetMaintenance(false); err != nil {
+		t.Fatalf("failed to disable maintenance mode: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("failed to list traces: %v", err)
+		}
+		if page.Total == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 deferred traces stored, got %d", page.Total)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := svc.WorkerStats(); stats.Maintenance || stats.DeferredJobs != 0 {
+		t.Errorf("expected maintenance over, got %+v", stats)
+	}
+}
+
+func TestWorker_MaintenanceReplayKeepsOrder(t *testing.T) {
+	store := newTestStore(t)
+	project := newTestProject(t, store, "replay-order", entity.ProjectSettings{})
+	wal, _, err := openJobWAL(t.TempDir() + "/ingest.wal")
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+
+	// No workers run and the queue holds one job, so the replay blocks on
+	// its second job until the test takes the first
+	w := NewWorker(NewEventProcessor(store, service.NewPricingCalculator()), 1)
+	w.wal = wal
+	defer w.Stop(5 * time.Second)
+	enqueue := func(name string) {
+		w.Enqueue(Job{ProjectID: project.ID, Events: []IngestEvent{{TraceID: "ordered", Name: name, SpanType: "tool"}}})
+	}
+	next := func() string {
+		select {
+		case job := <-w.jobs:
+			return job.Events[0].Name
+		case <-time.After(5 * time.Second):
+			return "<none>"
+		}
+	}
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+	deferred := func(n int) func() bool {
+		return func() bool { _, got := w.Maintenance(); return got == n }
+	}
+
+	if err := w.SetMaintenance(true); err != nil {
+		t.Fatalf("failed to enable maintenance mode: %v", err)
+	}
+	enqueue("a")
+	enqueue("b")
+	if err := w.SetMaintenance(false); err != nil {
+		t.Fatalf("failed to disable maintenance mode: %v", err)
+	}
+	if !waitFor(func() bool { return w.QueueSize() == 1 }) {
+		t.Fatal("expected the replay to queue the first deferred job")
+	}
+
+	// A job arriving mid-replay waits behind the deferred ones
+	enqueue("c")
+	if !waitFor(deferred(1)) {
+		t.Fatal("expected a job enqueued during the replay to be deferred")
+	}
+
+	// Maintenance back on stops the replay: b goes back ahead of c
+	if err := w.SetMaintenance(true); err != nil {
+		t.Fatalf("failed to enable maintenance mode: %v", err)
+	}
+	if !waitFor(deferred(2)) {
+		_, n := w.Maintenance()
+		t.Fatalf("expected the stopped replay's job deferred again, got %d deferred", n)
+	}
+	if got := next(); got != "a" {
+		t.Fatalf("expected job a queued first, got %s", got)
+	}
+	time.Sleep(20 * time.Millisecond) // a running replay would queue b now
+	if got := w.QueueSize(); got != 0 {
+		t.Fatalf("expected nothing queued during maintenance, got %d jobs", got)
+	}
+
+	if err := w.SetMaintenance(false); err != nil {
+		t.Fatalf("failed to disable maintenance mode: %v", err)
+	}
+	for _, want := range []string{"b", "c"} {
+		if got := next(); got != want {
+			t.Fatalf("expected job %s replayed next, got %s", want, got)
+		}
+	}
+
+	// Once the replay is done, jobs are queued directly again
+	if !waitFor(func() bool {
+		w.maintMu.Lock()
+		defer w.maintMu.Unlock()
+		return w.stopReplay == nil
+	}) {
+		t.Fatal("expected the replay to finish")
+	}
+	enqueue("d")
+	if got := next(); got != "d" {
+		t.Errorf("expected job d queued directly, got %s", got)
+	}
+}
+
+func TestJobWAL_ReadsJobsBack(t *testing.T) {
+	path := t.TempDir() + "/ingest.wal"
+	wal, _, err := openJobWAL(path)
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	var ids []uint64
+	for _, project := range []string{"p1", "p2", "p3"} {
+		id, err := wal.append(Job{ProjectID: project})
+		if err != nil {
+			t.Fatalf("failed to append job: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := wal.done(ids[0]); err != nil {
+		t.Fatalf("failed to mark job done: %v", err)
+	}
+	if job, err := wal.job(ids[2]); err != nil || job.ProjectID != "p3" || job.walID != ids[2] {
+		t.Errorf("expected job p3 read back, got %+v (%v)", job, err)
+	}
+	if _, err := wal.job(ids[0]); err == nil {
+		t.Error("expected no job for a finished ID")
+	}
+	wal.close()
+
+	// Reopening compacts the log: the pending jobs move but still read back
+	reopened, pending, err := openJobWAL(path)
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer reopened.close()
+	if len(pending) != 2 || pending[0] != ids[1] || pending[1] != ids[2] {
+		t.Fatalf("expected jobs %v pending, got %v", ids[1:], pending)
+	}
+	for i, project := range []string{"p2", "p3"} {
+		if job, err := reopened.job(pending[i]); err != nil || job.ProjectID != project {
+			t.Errorf("expected job %s read back, got %+v (%v)", project, job, err)
+		}
+	}
+}
+
+func TestJobWAL_DeadLettersFailingJobs(t *testing.T) {
+	path := t.TempDir() + "/ingest.wal"
+	wal, _, err := openJobWAL(path)
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	id, err := wal.append(Job{ProjectID: "poison"})
+	if err != nil {
+		t.Fatalf("failed to append job: %v", err)
+	}
+	for i := 1; i < walMaxAttempts-1; i++ {
+		if dead, err := wal.fail(id); err != nil || dead {
+			t.Fatalf("attempt %d: expected the job kept, got %v (%v)", i, dead, err)
+		}
+	}
+	wal.close()
+
+	// Attempts survive a restart, compaction included
+	wal, pending, err := openJobWAL(path)
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer wal.close()
+	if len(pending) != 1 || pending[0] != id {
+		t.Fatalf("expected the job still pending, got %v", pending)
+	}
+	if dead, err := wal.fail(id); err != nil || dead {
+		t.Fatalf("expected the job kept until its last attempt, got %v (%v)", dead, err)
+	}
+	if dead, err := wal.fail(id); err != nil || !dead {
+		t.Fatalf("expected the job dead-lettered, got %v (%v)", dead, err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("expected the job gone from the WAL, got %v (%v)", info, err)
+	}
+	dead, err := os.ReadFile(path + ".dead")
+	if err != nil || !strings.Contains(string(dead), `"ProjectID":"poison"`) {
+		t.Errorf("expected the job in the dead-letter file, got %s (%v)", dead, err)
+	}
+}
@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/webhook"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestIngest_WebhookEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := newTestStore(t)
+
+	const secret = "whsec_test"
+	var mu sync.Mutex
+	var received []webhook.Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(webhook.SignatureHeader); got != webhook.Sign(secret, body) {
+			t.Errorf("expected a valid signature, got %q", got)
+		}
+		var event webhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	dispatcher := webhook.NewDispatcher(webhook.NewSenderWithOptions(webhook.SenderOptions{AllowLoopback: true}), 10)
+	dispatcher.Start(ctx)
+	svc := NewService(store, service.NewPricingCalculator())
+	svc.SetWebhookDispatcher(dispatcher)
+
+	url := receiver.URL
+	project := newTestProject(t, store, "webhooks", entity.ProjectSettings{
+		WebhookURL:    &url,
+		WebhookSecret: secret,
+		WebhookEvents: []string{entity.WebhookEventTraceCompleted},
+	})
+
+	resp, err := svc.Ingest(ctx, project, &IngestRequest{Events: []IngestEvent{
+		{TraceID: "done", SpanID: "done/root", SpanType: "agent", Name: "agent", Status: "success"},
+		{TraceID: "running", SpanID: "running/step", ParentSpanID: "running/root", SpanType: "tool", Name: "step", Status: "success"},
+		{TraceID: "failed", SpanID: "failed/root", SpanType: "agent", Name: "agent", Status: "error"}, // trace.error: not subscribed
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // let any unexpected delivery arrive
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("expected only the completed trace delivered, got %+v", received)
+	}
+	event := received[0]
+	if event.Event != entity.WebhookEventTraceCompleted || event.TraceID != "done" || event.ProjectID != project.ID ||
+		event.Status != string(entity.TraceStatusCompleted) || event.OccurredAt.IsZero() {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
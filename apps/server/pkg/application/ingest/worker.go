@@ -2,15 +2,27 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
 )
 
 // Job represents an ingest job to be processed
 type Job struct {
 	ProjectID string
 	Events    []IngestEvent
+
+	// Options are left out of the write-ahead log as they carry project
+	// secrets (webhook signing secret, pricing overrides): replayed jobs
+	// take them from the project's current settings
+	Options ProcessOptions `json:"-"`
+
+	walID uint64 // write-ahead log ID; 0 when the WAL is off
 }
 
 // Worker processes ingest jobs asynchronously
@@ -19,40 +31,284 @@ type Worker struct {
 	jobs      chan Job
 	wg        sync.WaitGroup
 	shutdown  chan struct{}
+	stopMu    sync.Mutex // orders burst worker starts before Stop waits on wg
+
+	// Autoscaling (off unless maxWorkers exceeds the workers passed to Start):
+	// while more than highWater jobs are queued, Enqueue starts burst workers
+	// up to maxWorkers in total; each exits after idleTimeout without a job.
+	maxWorkers  int
+	highWater   int
+	idleTimeout time.Duration
+	active      atomic.Int32
+
+	wal      *jobWAL                 // records queued jobs until stored; nil disables it
+	projects repository.ProjectStore // where replayed jobs' options are read from
+
+	// Maintenance mode (see SetMaintenance): jobs are only recorded in the
+	// WAL, their IDs held in deferred until it ends and the replay started
+	// then has queued them all
+	maintMu     sync.Mutex
+	maintenance bool
+	deferred    []uint64
+	stopReplay  chan struct{} // closed to stop the running replay; nil when none runs
+	replayDone  chan struct{} // closed once the last replay started has returned
 }
 
+// ErrMaintenanceNeedsWAL is returned when maintenance mode is turned on
+// without a write-ahead log to defer jobs to
+var ErrMaintenanceNeedsWAL = errors.New("maintenance mode needs the ingest WAL")
+
 // NewWorker creates a new ingest worker
 func NewWorker(processor *EventProcessor, bufferSize int) *Worker {
 	return &Worker{
 		processor: processor,
+		projects:  processor.store,
 		jobs:      make(chan Job, bufferSize),
 		shutdown:  make(chan struct{}),
 	}
 }
 
-// Start begins processing jobs in background
+// SetAutoscale lets the worker grow to maxWorkers while more than highWater
+// jobs are queued, shrinking back to the base workers once the extra ones
+// have been idle for idleTimeout. A maxWorkers not above the base workers
+// disables scaling. Call it before enqueuing jobs.
+func (w *Worker) SetAutoscale(maxWorkers, highWater int, idleTimeout time.Duration) {
+	w.maxWorkers = maxWorkers
+	w.highWater = highWater
+	w.idleTimeout = idleTimeout
+}
+
+// Start begins processing jobs in background with the given base workers,
+// which run until Stop
 func (w *Worker) Start(workers int) {
 	for i := 0; i < workers; i++ {
+		w.active.Add(1)
 		w.wg.Add(1)
 		go w.run()
 	}
 	slog.Info("ingest worker started", "workers", workers, "buffer_size", cap(w.jobs))
 }
 
-// Enqueue adds a job to the queue
+// Enqueue adds a job to the queue. With a write-ahead log, the job is
+// recorded in it first, its events redacted so no PII the project redacts
+// reaches the disk, and not queued if that fails.
 func (w *Worker) Enqueue(job Job) bool {
+	if w.wal != nil {
+		w.processor.redactEvents(job.ProjectID, job.Events, job.Options.Redaction)
+		id, err := w.wal.append(job)
+		if err != nil {
+			slog.Error("failed to record ingest job in WAL, dropping job", "project_id", job.ProjectID, "events", len(job.Events), "error", err)
+			return false
+		}
+		job.walID = id
+	}
+	if w.deferJob(job) {
+		return true
+	}
+
 	select {
 	case w.jobs <- job:
+		w.scaleUp()
 		return true
 	default:
 		slog.Warn("ingest queue full, dropping job", "project_id", job.ProjectID, "events", len(job.Events))
+		w.walDone(job)
+		return false
+	}
+}
+
+// replay queues the jobs with the given write-ahead log IDs, read back from
+// the log one at a time with the options of their project's current
+// settings, waiting for room in the queue rather than dropping them. It
+// returns the IDs not queued when stop is closed; on shutdown the rest stay
+// pending in the log.
+func (w *Worker) replay(ids []uint64, stop <-chan struct{}) []uint64 {
+	for i, id := range ids {
+		select {
+		case <-stop:
+			return ids[i:]
+		default:
+		}
+		job, err := w.wal.job(id)
+		if err != nil {
+			slog.Error("failed to read ingest job from WAL", "id", id, "error", err)
+			continue
+		}
+		if !w.loadOptions(&job) {
+			continue
+		}
+		select {
+		case w.jobs <- job:
+			w.scaleUp()
+		case <-stop:
+			return ids[i:]
+		case <-w.shutdown:
+			return nil
+		}
+	}
+	return nil
+}
+
+// loadOptions sets the options of a job read back from the write-ahead log
+// from its project's settings. A job of a deleted project is dropped; one
+// whose project can't be read now stays pending for the next start.
+func (w *Worker) loadOptions(job *Job) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	project, err := w.projects.GetProjectByID(ctx, job.ProjectID)
+	if errors.Is(err, entity.ErrNotFound) {
+		slog.Warn("dropping ingest job of a deleted project from WAL", "project_id", job.ProjectID, "events", len(job.Events))
+		w.walDone(*job)
+		return false
+	}
+	if err != nil {
+		slog.Error("failed to read project of ingest job in WAL", "project_id", job.ProjectID, "error", err)
+		return false
+	}
+	job.Options = NewProcessOptions(project.Settings)
+	return true
+}
+
+// SetMaintenance turns maintenance mode on or off. While on, Enqueue records
+// jobs in the write-ahead log only, leaving the store alone; turning it off
+// queues the deferred jobs, in order, in the background, read back from the
+// log so a long maintenance window doesn't hold them in memory. New jobs keep
+// being deferred behind them until the replay has queued them all, so a
+// trace's jobs are never queued out of order, and turning maintenance back
+// on stops the replay, the rest staying deferred. Jobs already queued are
+// still processed. The mode is not persisted: after a restart the deferred
+// jobs are replayed from the log like any pending job.
+func (w *Worker) SetMaintenance(on bool) error {
+	if on && w.wal == nil {
+		return ErrMaintenanceNeedsWAL
+	}
+
+	w.maintMu.Lock()
+	defer w.maintMu.Unlock()
+	if on {
+		w.maintenance = true
+		if w.stopReplay != nil {
+			close(w.stopReplay)
+			w.stopReplay = nil
+		}
+		return nil
+	}
+	if !w.maintenance {
+		return nil
+	}
+	w.maintenance = false
+	stop, prev, done := make(chan struct{}), w.replayDone, make(chan struct{})
+	w.stopReplay, w.replayDone = stop, done
+	if len(w.deferred) > 0 {
+		slog.Info("ingest maintenance ended, replaying deferred jobs", "jobs", len(w.deferred))
+	}
+	go w.replayDeferred(stop, prev, done)
+	return nil
+}
+
+// replayDeferred queues the deferred jobs, including those deferred while it
+// runs, until none is left or stop is closed. It first waits for the replay
+// before it (prev, when not nil) to return the jobs it stopped at.
+func (w *Worker) replayDeferred(stop, prev, done chan struct{}) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+	}
+	for {
+		w.maintMu.Lock()
+		select {
+		case <-stop: // maintenance is back on: the jobs stay deferred
+			w.maintMu.Unlock()
+			return
+		default:
+		}
+		ids := w.deferred
+		w.deferred = nil
+		if len(ids) == 0 {
+			if w.stopReplay == stop {
+				w.stopReplay = nil
+			}
+			w.maintMu.Unlock()
+			return
+		}
+		w.maintMu.Unlock()
+
+		if rest := w.replay(ids, stop); len(rest) > 0 {
+			// Stopped by maintenance: the rest go back ahead of the jobs
+			// deferred since
+			w.maintMu.Lock()
+			w.deferred = append(rest, w.deferred...)
+			w.maintMu.Unlock()
+			return
+		}
+		select {
+		case <-w.shutdown:
+			return
+		default:
+		}
+	}
+}
+
+// Maintenance reports whether maintenance mode is on and how many jobs it
+// has deferred
+func (w *Worker) Maintenance() (bool, int) {
+	w.maintMu.Lock()
+	defer w.maintMu.Unlock()
+	return w.maintenance, len(w.deferred)
+}
+
+// deferJob holds job while maintenance is on or its deferred jobs are being
+// replayed
+func (w *Worker) deferJob(job Job) bool {
+	w.maintMu.Lock()
+	defer w.maintMu.Unlock()
+	if !w.maintenance && w.stopReplay == nil {
 		return false
 	}
+	w.deferred = append(w.deferred, job.walID)
+	return true
+}
+
+// ActiveWorkers returns the number of running workers, base and burst
+func (w *Worker) ActiveWorkers() int {
+	return int(w.active.Load())
+}
+
+// scaleUp starts a burst worker when the queue is above the high-water mark
+// and the worker limit allows it
+func (w *Worker) scaleUp() {
+	if len(w.jobs) <= w.highWater {
+		return
+	}
+	for {
+		n := w.active.Load()
+		if int(n) >= w.maxWorkers {
+			return
+		}
+		if w.active.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+
+	w.stopMu.Lock()
+	defer w.stopMu.Unlock()
+	select {
+	case <-w.shutdown:
+		w.active.Add(-1)
+		return
+	default:
+	}
+	w.wg.Add(1)
+	go w.runBurst()
+	slog.Debug("ingest worker scaled up", "workers", w.ActiveWorkers(), "pending_jobs", len(w.jobs))
 }
 
 // Stop gracefully shuts down the worker
 func (w *Worker) Stop(timeout time.Duration) {
+	w.stopMu.Lock()
 	close(w.shutdown)
+	w.stopMu.Unlock()
 
 	done := make(chan struct{})
 	go func() {
@@ -66,6 +322,12 @@ func (w *Worker) Stop(timeout time.Duration) {
 	case <-time.After(timeout):
 		slog.Warn("ingest worker shutdown timeout", "pending_jobs", len(w.jobs))
 	}
+
+	if w.wal != nil {
+		if err := w.wal.close(); err != nil {
+			slog.Error("failed to close ingest WAL", "error", err)
+		}
+	}
 }
 
 // QueueSize returns current number of pending jobs
@@ -75,7 +337,26 @@ func (w *Worker) QueueSize() int {
 
 func (w *Worker) run() {
 	defer w.wg.Done()
+	defer w.active.Add(-1)
+
+	for {
+		select {
+		case <-w.shutdown:
+			w.drain()
+			return
+		case job := <-w.jobs:
+			w.processJob(job)
+		}
+	}
+}
+
+// runBurst is run by the workers scaleUp starts; it exits once idle
+func (w *Worker) runBurst() {
+	defer w.wg.Done()
+	defer w.active.Add(-1)
 
+	idle := time.NewTimer(w.idleTimeout)
+	defer idle.Stop()
 	for {
 		select {
 		case <-w.shutdown:
@@ -83,6 +364,10 @@ func (w *Worker) run() {
 			return
 		case job := <-w.jobs:
 			w.processJob(job)
+			idle.Reset(w.idleTimeout)
+		case <-idle.C:
+			slog.Debug("ingest worker scaled down", "workers", w.ActiveWorkers()-1)
+			return
 		}
 	}
 }
@@ -102,11 +387,56 @@ func (w *Worker) processJob(job Job) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := w.processor.ProcessEvents(ctx, job.ProjectID, job.Events); err != nil {
+	err := w.processor.ProcessEvents(ctx, job.ProjectID, job.Events, job.Options)
+	var partial *SpanWriteError
+	if errors.As(err, &partial) {
+		// The rest of the job is stored: retrying would only duplicate it
+		slog.Warn("ingest job partially stored",
+			"project_id", job.ProjectID,
+			"events", len(job.Events),
+			"failed_spans", len(partial.Failed),
+		)
+	} else if err != nil {
 		slog.Error("failed to process ingest job",
 			"project_id", job.ProjectID,
 			"events", len(job.Events),
 			"error", err,
 		)
+		// Left pending in the WAL: the job is retried on the next start, up
+		// to walMaxAttempts times
+		w.walFailed(job)
+		return
+	}
+	w.walDone(job)
+}
+
+// walFailed records a failed attempt at a job in the write-ahead log, which
+// keeps it for the next start until it has failed walMaxAttempts times
+func (w *Worker) walFailed(job Job) {
+	if w.wal == nil || job.walID == 0 {
+		return
+	}
+	dead, err := w.wal.fail(job.walID)
+	if err != nil {
+		slog.Error("failed to record ingest job attempt in WAL", "project_id", job.ProjectID, "error", err)
+		return
+	}
+	if dead {
+		slog.Error("ingest job failed too many times, moved to the dead-letter file",
+			"project_id", job.ProjectID,
+			"events", len(job.Events),
+			"attempts", walMaxAttempts,
+			"path", w.wal.path+".dead",
+		)
+	}
+}
+
+// walDone removes a job from the write-ahead log, if it was recorded there
+func (w *Worker) walDone(job Job) {
+	if w.wal == nil || job.walID == 0 {
+		return
+	}
+	if err := w.wal.done(job.walID); err != nil {
+		slog.Error("failed to mark ingest job done in WAL", "project_id", job.ProjectID, "error", err)
 	}
 }
@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestWorker_Autoscale(t *testing.T) {
+	store := newTestStore(t)
+	project := newTestProject(t, store, "worker", entity.ProjectSettings{})
+
+	// Every job blocks in the pipeline until the gate opens, so each worker
+	// holds one job and the rest stay queued
+	newWorker := func(gate chan struct{}) *Worker {
+		processor := NewEventProcessor(store, service.NewPricingCalculator())
+		processor.AddSpanTransforms(func(span *entity.Span, event IngestEvent) { <-gate })
+		return NewWorker(processor, 100)
+	}
+	flood := func(w *Worker, jobs int) {
+		for i := 0; i < jobs; i++ {
+			w.Enqueue(Job{ProjectID: project.ID, Events: []IngestEvent{
+				{TraceID: fmt.Sprintf("trace-%d", i), SpanType: "tool", Name: "search", Status: "success"},
+			}})
+		}
+	}
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	t.Run("scales up under load and back down when idle", func(t *testing.T) {
+		gate := make(chan struct{})
+		w := newWorker(gate)
+		w.SetAutoscale(6, 2, 50*time.Millisecond)
+		w.Start(1)
+		defer w.Stop(5 * time.Second)
+
+		flood(w, 20)
+		if got := w.ActiveWorkers(); got != 6 {
+			t.Fatalf("expected 6 workers under load, got %d", got)
+		}
+
+		close(gate)
+		if !waitFor(func() bool { return w.QueueSize() == 0 && w.ActiveWorkers() == 1 }) {
+			t.Fatalf("expected the base worker only once idle, got %d workers and %d queued jobs", w.ActiveWorkers(), w.QueueSize())
+		}
+	})
+
+	t.Run("fixed workers without autoscaling", func(t *testing.T) {
+		gate := make(chan struct{})
+		w := newWorker(gate)
+		w.Start(2)
+		defer w.Stop(5 * time.Second)
+
+		flood(w, 20)
+		if got := w.ActiveWorkers(); got != 2 {
+			t.Errorf("expected 2 workers, got %d", got)
+		}
+		close(gate)
+	})
+
+	t.Run("stop waits for burst workers", func(t *testing.T) {
+		gate := make(chan struct{})
+		w := newWorker(gate)
+		w.SetAutoscale(4, 0, time.Hour)
+		w.Start(1)
+
+		flood(w, 10)
+		close(gate)
+		w.Stop(5 * time.Second)
+		if got := w.ActiveWorkers(); got != 0 {
+			t.Errorf("expected all workers stopped, got %d", got)
+		}
+		if got := w.QueueSize(); got != 0 {
+			t.Errorf("expected the queue drained, got %d jobs", got)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// TokenUsageMetric is the GenAI semantic-convention metric recorded from OTLP
+// metrics: a histogram (or counter) of tokens per call, split by
+// gen_ai.token.type
+const TokenUsageMetric = "gen_ai.client.token.usage"
+
+// temporalityCumulative is the OTLP cumulative aggregation temporality. Only
+// delta (or unspecified) points are recorded: cumulative ones repeat the
+// running total on every export.
+const temporalityCumulative = 2
+
+// Service records OTLP metrics exports as usage. Each export becomes one
+// trace holding an llm span per provider, model and timestamp, carrying the
+// summed input and output tokens, so the usage shows up in analytics and
+// cost like SDK events. Spans link to the spans of their points' exemplars.
+type Service struct {
+	ingest *ingest.Service
+}
+
+// NewService creates an OTLP metrics receiver recording through ingestSvc
+func NewService(ingestSvc *ingest.Service) *Service {
+	return &Service{ingest: ingestSvc}
+}
+
+// MetricsRequest is an OTLP/JSON ExportMetricsServiceRequest, reduced to the
+// fields read here
+type MetricsRequest struct {
+	ResourceMetrics []struct {
+		ScopeMetrics []struct {
+			Metrics []Metric `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+// Metric is one OTLP metric; exactly one of its data fields is set
+type Metric struct {
+	Name      string     `json:"name"`
+	Sum       *aggregate `json:"sum,omitempty"`
+	Histogram *aggregate `json:"histogram,omitempty"`
+
+	// Unsupported types, decoded only to count their rejected points
+	Gauge                *otherAggregate `json:"gauge,omitempty"`
+	ExponentialHistogram *otherAggregate `json:"exponentialHistogram,omitempty"`
+	Summary              *otherAggregate `json:"summary,omitempty"`
+}
+
+type aggregate struct {
+	DataPoints             []dataPoint `json:"dataPoints"`
+	AggregationTemporality int         `json:"aggregationTemporality"`
+}
+
+type otherAggregate struct {
+	DataPoints []json.RawMessage `json:"dataPoints"`
+}
+
+// dataPoint covers sum (AsInt/AsDouble) and histogram (Sum) data points
+type dataPoint struct {
+	Attributes   []keyValue  `json:"attributes"`
+	TimeUnixNano int64Value  `json:"timeUnixNano"`
+	AsInt        *int64Value `json:"asInt,omitempty"`
+	AsDouble     *float64    `json:"asDouble,omitempty"`
+	Sum          *float64    `json:"sum,omitempty"`
+	Exemplars    []exemplar  `json:"exemplars,omitempty"`
+}
+
+// exemplar is a sampled measurement of a data point, with the span it was
+// recorded in
+type exemplar struct {
+	TraceID            string     `json:"traceId"`
+	SpanID             string     `json:"spanId"`
+	FilteredAttributes []keyValue `json:"filteredAttributes"`
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// int64Value is an OTLP/JSON 64-bit integer, a decimal string per the
+// protobuf JSON mapping (plain numbers are accepted too)
+type int64Value int64
+
+func (v *int64Value) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s", data)
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+// MetricsResponse is an OTLP/JSON ExportMetricsServiceResponse
+type MetricsResponse struct {
+	PartialSuccess *PartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+// PartialSuccess reports the data points that were not recorded, and why
+type PartialSuccess struct {
+	RejectedDataPoints int64  `json:"rejectedDataPoints"`
+	ErrorMessage       string `json:"errorMessage"`
+}
+
+// usageKey groups the token data points of one call
+type usageKey struct {
+	provider, model string
+	time            int64
+}
+
+type usage struct {
+	input, output *int
+	points        int64
+	links         []entity.SpanLink // the spans of the points' exemplars
+}
+
+// link adds a link to the span of each exemplar of point not linked yet
+func (u *usage) link(point dataPoint) {
+	for _, ex := range point.Exemplars {
+		if ex.TraceID == "" || ex.SpanID == "" || slices.ContainsFunc(u.links, func(l entity.SpanLink) bool {
+			return l.TraceID == ex.TraceID && l.SpanID == ex.SpanID
+		}) {
+			continue
+		}
+		link := entity.SpanLink{TraceID: ex.TraceID, SpanID: ex.SpanID}
+		for k, v := range attributeMap(ex.FilteredAttributes) {
+			if link.Attributes == nil {
+				link.Attributes = make(map[string]any, len(ex.FilteredAttributes))
+			}
+			link.Attributes[k] = v
+		}
+		u.links = append(u.links, link)
+	}
+}
+
+// IngestMetrics records the token usage data points of an export. Other
+// metrics, gauges, cumulative points and unknown token types are rejected and
+// reported in the partial success, as the OTLP protocol expects.
+func (s *Service) IngestMetrics(ctx context.Context, project *entity.Project, req *MetricsRequest) (*MetricsResponse, error) {
+	var rejected int64
+	reasons := make(map[string]bool)
+	reject := func(points int, reason string) {
+		rejected += int64(points)
+		reasons[reason] = true
+	}
+
+	usages := make(map[usageKey]*usage)
+	var order []usageKey
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, metric := range sm.Metrics {
+				points, reason := tokenUsagePoints(metric)
+				if reason != "" {
+					reject(points, reason)
+					continue
+				}
+				for _, point := range metric.pointsOf() {
+					attrs := attributeMap(point.Attributes)
+					tokens := point.value()
+					key := usageKey{
+						provider: firstNonEmpty(attrs["gen_ai.provider.name"], attrs["gen_ai.system"]),
+						model:    firstNonEmpty(attrs["gen_ai.response.model"], attrs["gen_ai.request.model"]),
+						time:     int64(point.TimeUnixNano),
+					}
+					u := usages[key]
+					if u == nil {
+						u = &usage{}
+					}
+					switch attrs["gen_ai.token.type"] {
+					case "input":
+						u.input = addTokens(u.input, tokens)
+					case "output":
+						u.output = addTokens(u.output, tokens)
+					default:
+						reject(1, fmt.Sprintf("%s: unsupported gen_ai.token.type %q", TokenUsageMetric, attrs["gen_ai.token.type"]))
+						continue
+					}
+					if usages[key] == nil {
+						usages[key] = u
+						order = append(order, key)
+					}
+					u.points++
+					u.link(point)
+				}
+			}
+		}
+	}
+
+	if len(order) > 0 {
+		traceID := uuid.New().String()
+		events := make([]ingest.IngestEvent, len(order))
+		for i, key := range order {
+			u := usages[key]
+			timestamp := time.Unix(0, key.time).UTC()
+			events[i] = ingest.IngestEvent{
+				SpanType:     "llm",
+				Provider:     key.provider,
+				Model:        key.model,
+				Name:         TokenUsageMetric,
+				InputTokens:  u.input,
+				OutputTokens: u.output,
+				Status:       "success",
+				TraceID:      traceID,
+				SpanID:       uuid.New().String(),
+				Metadata:     map[string]any{"otlp": "metrics"},
+				Links:        u.links,
+			}
+			if key.time > 0 {
+				events[i].Timestamp = &timestamp
+			}
+		}
+
+		resp, err := s.ingest.Ingest(ctx, project, &ingest.IngestRequest{Events: events})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Errors {
+			reject(int(usages[order[e.Index]].points), e.Message)
+		}
+	}
+
+	if rejected == 0 {
+		return &MetricsResponse{}, nil
+	}
+	messages := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		messages = append(messages, reason)
+	}
+	sort.Strings(messages)
+	return &MetricsResponse{PartialSuccess: &PartialSuccess{
+		RejectedDataPoints: rejected,
+		ErrorMessage:       strings.Join(messages, "; "),
+	}}, nil
+}
+
+// tokenUsagePoints returns why metric cannot be recorded, with its data point
+// count, or "" when it is a delta token usage sum or histogram
+func tokenUsagePoints(metric Metric) (int, string) {
+	points := metric.pointCount()
+	switch {
+	case metric.Name != TokenUsageMetric:
+		return points, fmt.Sprintf("unsupported metric %q: only %s is recorded", metric.Name, TokenUsageMetric)
+	case metric.Sum == nil && metric.Histogram == nil:
+		return points, fmt.Sprintf("%s: unsupported metric type, expected a sum or histogram", TokenUsageMetric)
+	}
+
+	agg := metric.Histogram
+	if agg == nil {
+		agg = metric.Sum
+	}
+	if agg.AggregationTemporality == temporalityCumulative {
+		return points, fmt.Sprintf("%s: cumulative temporality is not supported, export delta temporality", TokenUsageMetric)
+	}
+	return points, ""
+}
+
+// pointsOf returns the data points of a sum or histogram metric; other types
+// are not decoded
+func (m Metric) pointsOf() []dataPoint {
+	switch {
+	case m.Histogram != nil:
+		return m.Histogram.DataPoints
+	case m.Sum != nil:
+		return m.Sum.DataPoints
+	}
+	return nil
+}
+
+// pointCount counts the data points of a metric of any type
+func (m Metric) pointCount() int {
+	for _, other := range []*otherAggregate{m.Gauge, m.ExponentialHistogram, m.Summary} {
+		if other != nil {
+			return len(other.DataPoints)
+		}
+	}
+	return len(m.pointsOf())
+}
+
+// value is the token count of a data point: a histogram's sum, or a sum's value
+func (p dataPoint) value() int {
+	switch {
+	case p.Sum != nil:
+		return int(*p.Sum)
+	case p.AsInt != nil:
+		return int(*p.AsInt)
+	case p.AsDouble != nil:
+		return int(*p.AsDouble)
+	}
+	return 0
+}
+
+func attributeMap(attrs []keyValue) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value.StringValue
+	}
+	return m
+}
+
+func addTokens(total *int, n int) *int {
+	if total != nil {
+		n += *total
+	}
+	return &n
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -8,22 +8,32 @@ import (
 
 // CreateProjectRequest is the request to create a project
 type CreateProjectRequest struct {
-	Name string `json:"name"`
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"` // see entity.ProjectSettings.Environment
 }
 
 // UpdateProjectRequest is the request to update a project
 type UpdateProjectRequest struct {
-	Name     *string                  `json:"name,omitempty"`
+	Name     *string                 `json:"name,omitempty"`
 	Settings *entity.ProjectSettings `json:"settings,omitempty"`
+	// ClearSecrets removes settings secrets by name ("webhookSecret",
+	// "ingestSigningSecret"). Responses omit the secrets, so settings
+	// without one keep the current value.
+	ClearSecrets []string `json:"clearSecrets,omitempty"`
 }
 
 // ProjectResponse is the response for project endpoints
 type ProjectResponse struct {
-	ID        string                  `json:"id"`
-	Name      string                  `json:"name"`
-	Settings  entity.ProjectSettings `json:"settings"`
-	CreatedAt time.Time               `json:"createdAt"`
-	UpdatedAt time.Time               `json:"updatedAt"`
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Settings entity.ProjectSettings `json:"settings"`
+	// Usage of the current API key (flushed periodically, so may lag by up to a minute)
+	APIKeyLastUsedAt   *time.Time `json:"apiKeyLastUsedAt,omitempty"`
+	APIKeyRequestCount int64      `json:"apiKeyRequestCount"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	// Approximate storage of the project's traces and spans
+	Footprint entity.Footprint `json:"footprint"`
 }
 
 // RotateAPIKeyResponse is the response for API key rotation
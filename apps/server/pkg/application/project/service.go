@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 
 	"github.com/lelemon/server/pkg/domain/entity"
@@ -13,42 +14,63 @@ import (
 
 // Service handles project operations
 type Service struct {
-	store repository.Store
+	store  repository.Store
+	traces repository.TraceStore
 }
 
-// NewService creates a new project service
+// NewService creates a new project service. Traces (for the storage
+// footprint) are read from store until SetTraceStore is called.
 func NewService(store repository.Store) *Service {
-	return &Service{store: store}
+	return &Service{store: store, traces: store}
 }
 
-// GetCurrent returns the current project (from API key auth)
-func (s *Service) GetCurrent(ctx context.Context, project *entity.Project) *ProjectResponse {
-	return &ProjectResponse{
-		ID:        project.ID,
-		Name:      project.Name,
-		Settings:  project.Settings,
-		CreatedAt: project.CreatedAt,
-		UpdatedAt: project.UpdatedAt,
-	}
+// SetTraceStore sets where the projects' traces live, when apart from the
+// projects themselves, e.g. in the analytics store
+func (s *Service) SetTraceStore(traces repository.TraceStore) {
+	s.traces = traces
 }
 
-// UpdateCurrent updates the current project
-func (s *Service) UpdateCurrent(ctx context.Context, projectID string, req *UpdateProjectRequest) error {
-	updates := entity.ProjectUpdate{}
-
-	if req.Name != nil {
-		updates.Name = req.Name
+// GetCurrent returns the current project (from API key auth), including usage
+// of its current API key and its storage footprint
+func (s *Service) GetCurrent(ctx context.Context, project *entity.Project) (*ProjectResponse, error) {
+	usage, err := s.store.GetAPIKeyUsage(ctx, project.APIKeyHash)
+	if err != nil {
+		return nil, err
 	}
-	if req.Settings != nil {
-		updates.Settings = req.Settings
+	footprint, err := s.traces.GetProjectFootprint(ctx, project.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.store.UpdateProject(ctx, projectID, updates)
+	return &ProjectResponse{
+		ID:                 project.ID,
+		Name:               project.Name,
+		Settings:           project.Settings.Redacted(),
+		APIKeyLastUsedAt:   usage.LastUsedAt,
+		APIKeyRequestCount: usage.RequestCount,
+		Footprint:          footprint,
+		CreatedAt:          project.CreatedAt,
+		UpdatedAt:          project.UpdatedAt,
+	}, nil
+}
+
+// UpdateCurrent updates the current project. Its API key alone can't change
+// the settings secrets.
+func (s *Service) UpdateCurrent(ctx context.Context, projectID string, req *UpdateProjectRequest) error {
+	project, err := s.store.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, project, req, false)
 }
 
 // Create creates a new project
 func (s *Service) Create(ctx context.Context, ownerEmail string, req *CreateProjectRequest) (*entity.Project, error) {
-	apiKey, err := generateAPIKey()
+	if !entity.ValidEnvironment(req.Environment) {
+		return nil, fmt.Errorf("%w: invalid environment %q", entity.ErrBadRequest, req.Environment)
+	}
+
+	apiKey, err := generateAPIKey(req.Environment)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +83,7 @@ func (s *Service) Create(ctx context.Context, ownerEmail string, req *CreateProj
 		APIKey:     apiKey,
 		APIKeyHash: hashStr,
 		OwnerEmail: ownerEmail,
-		Settings:   entity.ProjectSettings{},
+		Settings:   entity.ProjectSettings{Environment: req.Environment},
 	}
 
 	if err := s.store.CreateProject(ctx, project); err != nil {
@@ -81,6 +103,19 @@ func (s *Service) IsOwner(ctx context.Context, projectID, ownerEmail string) (bo
 	return s.store.IsProjectOwner(ctx, projectID, ownerEmail)
 }
 
+// HasPublicMetrics reports whether a project exists and has opted into
+// serving its aggregate metrics without auth (Settings.PublicMetricsEnabled)
+func (s *Service) HasPublicMetrics(ctx context.Context, projectID string) (bool, error) {
+	project, err := s.store.GetProjectByID(ctx, projectID)
+	if errors.Is(err, entity.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return project.Settings.PublicMetricsEnabled, nil
+}
+
 // Update updates a project by ID (for dashboard)
 func (s *Service) Update(ctx context.Context, projectID string, ownerEmail string, req *UpdateProjectRequest) error {
 	// Verify ownership
@@ -92,15 +127,91 @@ func (s *Service) Update(ctx context.Context, projectID string, ownerEmail strin
 		return entity.ErrNotFound
 	}
 
+	return s.update(ctx, project, req, true)
+}
+
+// update applies req to project. Settings replace the current ones whole,
+// except that omitting the environment keeps the current one: it's part of
+// the API key, which would otherwise be rejected. Secrets are kept too
+// unless set or cleared, which only the dashboard (secrets true) may do.
+func (s *Service) update(ctx context.Context, project *entity.Project, req *UpdateProjectRequest, secrets bool) error {
 	updates := entity.ProjectUpdate{}
 	if req.Name != nil {
 		updates.Name = req.Name
 	}
+	if err := applySecrets(project.Settings, req, secrets); err != nil {
+		return err
+	}
 	if req.Settings != nil {
+		if !entity.ValidEnvironment(req.Settings.Environment) {
+			return fmt.Errorf("%w: invalid environment %q", entity.ErrBadRequest, req.Settings.Environment)
+		}
+		if req.Settings.Environment == "" {
+			req.Settings.Environment = project.Settings.Environment
+		}
+		if !entity.ValidCurrency(req.Settings.Currency) {
+			return fmt.Errorf("%w: invalid currency %q", entity.ErrBadRequest, req.Settings.Currency)
+		}
+		if !entity.ValidTraceNameSources(req.Settings.TraceNameSources) {
+			return fmt.Errorf("%w: invalid traceNameSources %v", entity.ErrBadRequest, req.Settings.TraceNameSources)
+		}
+		if !entity.ValidTraceErrorRule(req.Settings.TraceErrorRule) {
+			return fmt.Errorf("%w: invalid traceErrorRule %q", entity.ErrBadRequest, req.Settings.TraceErrorRule)
+		}
+		if !entity.ValidDefaultSpanStatus(req.Settings.DefaultSpanStatus) {
+			return fmt.Errorf("%w: invalid defaultSpanStatus %q", entity.ErrBadRequest, req.Settings.DefaultSpanStatus)
+		}
+		if days := req.Settings.SpanContentRetentionDays; days != nil && *days < 1 {
+			return fmt.Errorf("%w: spanContentRetentionDays must be at least 1", entity.ErrBadRequest)
+		}
+		if !entity.ValidWebhookEvents(req.Settings.WebhookEvents) {
+			return fmt.Errorf("%w: invalid webhookEvents %v", entity.ErrBadRequest, req.Settings.WebhookEvents)
+		}
+		if !entity.ValidSessionIDRequirement(req.Settings.RequireSessionID) {
+			return fmt.Errorf("%w: invalid requireSessionId %q", entity.ErrBadRequest, req.Settings.RequireSessionID)
+		}
+		if !entity.ValidPricingOverrides(req.Settings.PricingOverrides) {
+			return fmt.Errorf("%w: pricingOverrides need a model name and non-negative rates", entity.ErrBadRequest)
+		}
 		updates.Settings = req.Settings
 	}
 
-	return s.store.UpdateProject(ctx, projectID, updates)
+	return s.store.UpdateProject(ctx, project.ID, updates)
+}
+
+// applySecrets carries the project's current secrets over into the settings
+// of req, except those it sets or clears. Without secrets (API key auth)
+// req may do neither.
+func applySecrets(current entity.ProjectSettings, req *UpdateProjectRequest, secrets bool) error {
+	set := req.Settings != nil && (req.Settings.WebhookSecret != "" || req.Settings.IngestSigningSecret != "")
+	if !secrets && (set || len(req.ClearSecrets) > 0) {
+		return fmt.Errorf("%w: secrets can only be changed from the dashboard", entity.ErrForbidden)
+	}
+	if req.Settings == nil {
+		if len(req.ClearSecrets) == 0 {
+			return nil
+		}
+		settings := current
+		req.Settings = &settings
+	}
+
+	if req.Settings.WebhookSecret == "" {
+		req.Settings.WebhookSecret = current.WebhookSecret
+	}
+	if req.Settings.IngestSigningSecret == "" {
+		req.Settings.IngestSigningSecret = current.IngestSigningSecret
+	}
+	for _, name := range req.ClearSecrets {
+		switch name {
+		case "webhookSecret":
+			req.Settings.WebhookSecret = ""
+		case "ingestSigningSecret":
+			req.Settings.IngestSigningSecret = ""
+		default:
+			return fmt.Errorf("%w: unknown secret %q", entity.ErrBadRequest, name)
+		}
+	}
+	return nil
 }
 
 // Delete deletes a project
@@ -117,9 +228,15 @@ func (s *Service) Delete(ctx context.Context, projectID string, ownerEmail strin
 	return s.store.DeleteProject(ctx, projectID)
 }
 
-// RotateAPIKey generates a new API key for a project
+// RotateAPIKey generates a new API key for a project, with the prefix of the
+// project's environment
 func (s *Service) RotateAPIKey(ctx context.Context, projectID string) (*RotateAPIKeyResponse, error) {
-	apiKey, err := generateAPIKey()
+	project, err := s.store.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := generateAPIKey(project.Settings.Environment)
 	if err != nil {
 		return nil, err
 	}
@@ -136,11 +253,11 @@ func (s *Service) RotateAPIKey(ctx context.Context, projectID string) (*RotateAP
 	}, nil
 }
 
-// generateAPIKey creates a new random API key with le_ prefix
-func generateAPIKey() (string, error) {
+// generateAPIKey creates a new random API key with the le_ prefix of environment
+func generateAPIKey(environment string) (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
-	return "le_" + hex.EncodeToString(bytes), nil
+	return entity.APIKeyPrefixFor(environment) + hex.EncodeToString(bytes), nil
 }
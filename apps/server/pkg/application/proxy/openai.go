@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// DefaultOpenAIUpstream is the OpenAI API base URL requests are forwarded to
+const DefaultOpenAIUpstream = "https://api.openai.com"
+
+// Request headers that control the proxy itself and are never forwarded
+const (
+	// UpstreamAuthHeader carries the caller's OpenAI credentials ("Bearer sk-..."),
+	// sent upstream as Authorization. Authorization itself holds the project API key.
+	UpstreamAuthHeader = "X-Upstream-Authorization"
+	// TraceIDHeader groups proxied calls into one trace; each call gets its own otherwise
+	TraceIDHeader   = "X-Lelemon-Trace-Id"
+	SessionIDHeader = "X-Lelemon-Session-Id"
+	UserIDHeader    = "X-Lelemon-User-Id"
+)
+
+// DefaultRequestHeaders are the client headers forwarded upstream unless
+// configured otherwise (see SetHeaderAllowlists): content negotiation and
+// OpenAI's organization, project and beta headers
+var DefaultRequestHeaders = []string{
+	"Content-Type", "Accept", "User-Agent", "OpenAI-Organization", "OpenAI-Project", "OpenAI-Beta",
+}
+
+// DefaultResponseHeaders are the upstream headers returned to the client
+// unless configured otherwise: the body's type, request IDs and rate limits
+var DefaultResponseHeaders = []string{
+	"Content-Type", "Cache-Control", "Retry-After", "X-Request-Id",
+	"OpenAI-Organization", "OpenAI-Processing-Ms", "OpenAI-Version", "X-Ratelimit-*",
+}
+
+// hopHeaders are connection-level headers that must not be copied across a proxy
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// ownHeaders are set or consumed by the proxy itself, so they are never
+// copied across it, allowlisted or not
+var ownHeaders = []string{
+	"Authorization", "Cookie", "Content-Length", "Accept-Encoding", "Host",
+	UpstreamAuthHeader, TraceIDHeader, SessionIDHeader, UserIDHeader,
+}
+
+// neverCopied holds hopHeaders and ownHeaders, lowercased
+var neverCopied = func() map[string]bool {
+	set := make(map[string]bool, len(hopHeaders)+len(ownHeaders))
+	for _, h := range append(append([]string(nil), hopHeaders...), ownHeaders...) {
+		set[strings.ToLower(h)] = true
+	}
+	return set
+}()
+
+// headerAllowlist matches header names case-insensitively; an entry ending
+// in "*" matches every header with that prefix
+type headerAllowlist []string
+
+func newHeaderAllowlist(names []string) headerAllowlist {
+	list := make(headerAllowlist, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, strings.ToLower(name))
+		}
+	}
+	return list
+}
+
+func (l headerAllowlist) allows(name string) bool {
+	name = strings.ToLower(name)
+	if neverCopied[name] {
+		return false
+	}
+	for _, entry := range l {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// copy copies the allowed headers of src to dst
+func (l headerAllowlist) copy(dst, src http.Header) {
+	for k, values := range src {
+		if l.allows(k) {
+			dst[k] = append([]string(nil), values...)
+		}
+	}
+}
+
+// Service forwards OpenAI chat completion requests to an upstream
+// OpenAI-compatible API and records each call as an llm span, so an app can
+// be instrumented by pointing its OpenAI base URL at lelemon.
+type Service struct {
+	ingest          *ingest.Service
+	upstream        string
+	client          *http.Client
+	requestHeaders  headerAllowlist
+	responseHeaders headerAllowlist
+}
+
+// NewService creates a proxy forwarding to upstream (e.g. DefaultOpenAIUpstream).
+// Calls are recorded through ingestSvc like SDK events.
+func NewService(ingestSvc *ingest.Service, upstream string) *Service {
+	return &Service{
+		ingest:   ingestSvc,
+		upstream: strings.TrimSuffix(upstream, "/"),
+		// No client timeout: streamed completions can run for minutes; the
+		// caller's request context bounds each call
+		client:          &http.Client{},
+		requestHeaders:  newHeaderAllowlist(DefaultRequestHeaders),
+		responseHeaders: newHeaderAllowlist(DefaultResponseHeaders),
+	}
+}
+
+// SetHeaderAllowlists replaces the client headers forwarded upstream and the
+// upstream headers returned to the client, e.g. to pass Anthropic's
+// anthropic-beta through. Names are case-insensitive and a trailing "*"
+// matches a prefix. A nil list keeps its default.
+func (s *Service) SetHeaderAllowlists(request, response []string) {
+	if request != nil {
+		s.requestHeaders = newHeaderAllowlist(request)
+	}
+	if response != nil {
+		s.responseHeaders = newHeaderAllowlist(response)
+	}
+}
+
+// ChatCompletion is one proxied call, as recorded
+type ChatCompletion struct {
+	Request      []byte // Request body as sent by the client
+	StatusCode   int
+	Response     []byte // Response body (the raw event stream when Streaming)
+	Streaming    bool
+	DurationMs   int
+	FirstTokenMs *int // Streaming only: time to the first chunk
+	TraceID      string
+	SessionID    string
+	UserID       string
+}
+
+// Forward sends the client's chat completion request upstream with the
+// client's allowlisted headers. Authorization is UpstreamAuthHeader when given.
+func (s *Service) Forward(ctx context.Context, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.upstream+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	s.requestHeaders.copy(req.Header, header)
+	if auth := header.Get(UpstreamAuthHeader); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	return s.client.Do(req)
+}
+
+// CopyResponseHeaders copies the allowlisted upstream response headers to
+// the client's
+func (s *Service) CopyResponseHeaders(dst, src http.Header) {
+	s.responseHeaders.copy(dst, src)
+}
+
+// Record stores the call as an llm span of the project. Upstream errors are
+// recorded as error spans with the upstream message.
+func (s *Service) Record(ctx context.Context, project *entity.Project, call *ChatCompletion) error {
+	var request struct {
+		Model    string `json:"model"`
+		Messages any    `json:"messages"`
+	}
+	json.Unmarshal(call.Request, &request)
+
+	durationMs := call.DurationMs
+	event := ingest.IngestEvent{
+		SpanType:     "llm",
+		Provider:     "openai",
+		Model:        request.Model,
+		Name:         "chat.completions",
+		Input:        request.Messages,
+		DurationMs:   &durationMs,
+		Status:       "success",
+		Streaming:    call.Streaming,
+		FirstTokenMs: call.FirstTokenMs,
+		TraceID:      call.TraceID,
+		SpanID:       uuid.New().String(),
+		SessionID:    call.SessionID,
+		UserID:       call.UserID,
+		Metadata:     map[string]any{"proxy": "openai"},
+	}
+	if event.TraceID == "" {
+		event.TraceID = uuid.New().String()
+	}
+
+	if call.StatusCode >= 200 && call.StatusCode < 300 {
+		var response map[string]any
+		if call.Streaming {
+			response = AssembleStream(call.Response)
+		} else {
+			json.Unmarshal(call.Response, &response)
+		}
+		if response != nil {
+			event.RawResponse = response
+			if model, ok := response["model"].(string); ok && event.Model == "" {
+				event.Model = model
+			}
+		}
+	} else {
+		event.Status = "error"
+		event.ErrorMessage = upstreamError(call.StatusCode, call.Response)
+	}
+
+	resp, err := s.ingest.Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{event}})
+	if err != nil {
+		return err
+	}
+	if !resp.Success && len(resp.Errors) > 0 {
+		return fmt.Errorf("record span: %s", resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// upstreamError extracts the message of an OpenAI error response
+func upstreamError(status int, body []byte) string {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Error.Message != "" {
+		return resp.Error.Message
+	}
+	return fmt.Sprintf("upstream returned %d %s", status, http.StatusText(status))
+}
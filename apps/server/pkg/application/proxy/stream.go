@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// AssembleStream rebuilds a chat.completion response from a streamed
+// (text/event-stream) one: content and tool call arguments are concatenated
+// per choice, and usage is taken from the final chunk when the client asked
+// for it (stream_options.include_usage). Returns nil if no chunk parses.
+func AssembleStream(body []byte) map[string]any {
+	type toolCall struct {
+		id, typ, name string
+		args          strings.Builder
+	}
+	type choice struct {
+		role, finishReason string
+		content            strings.Builder
+		toolCalls          map[int]*toolCall
+	}
+
+	var (
+		response map[string]any
+		choices  = map[int]*choice{}
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		data = strings.TrimSpace(data)
+		if !ok || data == "" || data == "[DONE]" {
+			continue
+		}
+
+		var chunk struct {
+			ID      string `json:"id"`
+			Model   string `json:"model"`
+			Created int64  `json:"created"`
+			Choices []struct {
+				Index int `json:"index"`
+				Delta struct {
+					Role      string `json:"role"`
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Type     string `json:"type"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage map[string]any `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+
+		if response == nil {
+			response = map[string]any{
+				"id":      chunk.ID,
+				"object":  "chat.completion",
+				"created": chunk.Created,
+				"model":   chunk.Model,
+			}
+		}
+		if chunk.Usage != nil {
+			response["usage"] = chunk.Usage
+		}
+
+		for _, c := range chunk.Choices {
+			ch, ok := choices[c.Index]
+			if !ok {
+				ch = &choice{role: "assistant", toolCalls: map[int]*toolCall{}}
+				choices[c.Index] = ch
+			}
+			if c.Delta.Role != "" {
+				ch.role = c.Delta.Role
+			}
+			ch.content.WriteString(c.Delta.Content)
+			if c.FinishReason != nil {
+				ch.finishReason = *c.FinishReason
+			}
+			for _, tc := range c.Delta.ToolCalls {
+				call, ok := ch.toolCalls[tc.Index]
+				if !ok {
+					call = &toolCall{typ: "function"}
+					ch.toolCalls[tc.Index] = call
+				}
+				if tc.ID != "" {
+					call.id = tc.ID
+				}
+				if tc.Type != "" {
+					call.typ = tc.Type
+				}
+				if tc.Function.Name != "" {
+					call.name = tc.Function.Name
+				}
+				call.args.WriteString(tc.Function.Arguments)
+			}
+		}
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(choices))
+	for i := range choices {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	assembled := make([]any, 0, len(indexes))
+	for _, i := range indexes {
+		ch := choices[i]
+		message := map[string]any{"role": ch.role, "content": ch.content.String()}
+		if len(ch.toolCalls) > 0 {
+			callIndexes := make([]int, 0, len(ch.toolCalls))
+			for j := range ch.toolCalls {
+				callIndexes = append(callIndexes, j)
+			}
+			sort.Ints(callIndexes)
+
+			calls := make([]any, 0, len(callIndexes))
+			for _, j := range callIndexes {
+				tc := ch.toolCalls[j]
+				calls = append(calls, map[string]any{
+					"id":       tc.id,
+					"type":     tc.typ,
+					"function": map[string]any{"name": tc.name, "arguments": tc.args.String()},
+				})
+			}
+			message["tool_calls"] = calls
+		}
+		assembled = append(assembled, map[string]any{
+			"index":         i,
+			"message":       message,
+			"finish_reason": ch.finishReason,
+		})
+	}
+	response["choices"] = assembled
+
+	return response
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/service"
+)
+
+func TestAssembleStream_ToolCalls(t *testing.T) {
+	stream := `data: {"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}
+
+data: {"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}
+
+data: {"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Lima\"}"}}]}}]}
+
+data: {"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+	response := AssembleStream([]byte(stream))
+	if response == nil {
+		t.Fatal("expected an assembled response")
+	}
+
+	// The assembled response must read like a regular completion
+	parsed := service.ParseProviderResponse("openai", response)
+	if parsed == nil || parsed.StopReason == nil || *parsed.StopReason != "tool_calls" {
+		t.Fatalf("expected finish reason tool_calls, got %+v", parsed)
+	}
+	if len(parsed.ToolUses) != 1 {
+		t.Fatalf("expected 1 tool call, got %+v", parsed.ToolUses)
+	}
+	call := parsed.ToolUses[0]
+	if call.ID != "call_1" || call.Name != "get_weather" || call.Input != `{"city":"Lima"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+}
+
+func TestAssembleStream_NoChunks(t *testing.T) {
+	if response := AssembleStream([]byte("data: [DONE]\n\n")); response != nil {
+		t.Errorf("expected nil for a stream without chunks, got %v", response)
+	}
+}
@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// ErrTargetProjectNotFound is returned when copying into a project that
+// doesn't exist or has a different owner than the trace's project
+var ErrTargetProjectNotFound = errors.New("target project not found")
+
+// MetadataCopiedFrom is the trace metadata key recording where a copied trace
+// came from: {"projectId": ..., "traceId": ...}
+const MetadataCopiedFrom = "copied_from"
+
+// Copy deep-copies a trace and all of its spans into targetProjectID, e.g. to
+// replay a production trace in a sandbox project without touching production
+// analytics. The copy gets fresh trace and span IDs, with parent links
+// remapped so the span hierarchy is preserved. Both projects must have the
+// same owner.
+//
+// Reads and writes go through the service's store by project, so with a
+// regional store the copy lands in the target project's own backend (e.g. from
+// ClickHouse to SQLite). The trace and its spans are written together, in one
+// transaction where the target backend has them.
+func (s *Service) Copy(ctx context.Context, projectID, traceID, targetProjectID string) (*CopyTraceResponse, error) {
+	source, err := s.projects.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.projects.GetProjectByID(ctx, targetProjectID)
+	if errors.Is(err, entity.ErrNotFound) || (err == nil && target.OwnerEmail != source.OwnerEmail) {
+		return nil, ErrTargetProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := s.store.GetTrace(ctx, projectID, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := original.Trace
+	trace.ID = uuid.New().String()
+	trace.ProjectID = target.ID
+	trace.Metadata = maps.Clone(original.Metadata)
+	if trace.Metadata == nil {
+		trace.Metadata = make(map[string]any)
+	}
+	trace.Metadata[MetadataCopiedFrom] = map[string]any{"projectId": projectID, "traceId": traceID}
+
+	// Fresh IDs first, so children can point at their parent's copy
+	spanIDs := make(map[string]string, len(original.Spans))
+	for _, span := range original.Spans {
+		spanIDs[span.ID] = uuid.New().String()
+	}
+	spans := make([]entity.Span, len(original.Spans))
+	for i, span := range original.Spans {
+		span.ID = spanIDs[span.ID]
+		span.TraceID = trace.ID
+		if span.ParentSpanID != nil {
+			if parentID, ok := spanIDs[*span.ParentSpanID]; ok {
+				span.ParentSpanID = &parentID
+			} else {
+				span.ParentSpanID = nil // parent was never ingested
+			}
+		}
+		span.Metadata = maps.Clone(span.Metadata)
+		span.ToolUses = append([]entity.ToolUse(nil), span.ToolUses...)
+		span.ModelParams = maps.Clone(span.ModelParams)
+		span.Tags = append([]string(nil), span.Tags...)
+		span.Links = copyLinks(span.Links, original.ID, trace.ID, spanIDs)
+		spans[i] = span
+	}
+
+	if _, err := s.store.CreateTracesWithSpans(ctx, target.ID, []*entity.Trace{&trace}, spans); err != nil {
+		return nil, err
+	}
+
+	return &CopyTraceResponse{TraceID: trace.ID, ProjectID: target.ID, SpanIDs: spanIDs}, nil
+}
+
+// copyLinks copies links for a span copied from trace originalID to traceID,
+// pointing those into the original trace at the copies of their spans
+func copyLinks(links []entity.SpanLink, originalID, traceID string, spanIDs map[string]string) []entity.SpanLink {
+	if len(links) == 0 {
+		return nil
+	}
+	copied := make([]entity.SpanLink, len(links))
+	for i, link := range links {
+		link.Attributes = maps.Clone(link.Attributes)
+		if spanID, ok := spanIDs[link.SpanID]; ok && link.TraceID == originalID {
+			link.TraceID, link.SpanID = traceID, spanID
+		}
+		copied[i] = link
+	}
+	return copied
+}
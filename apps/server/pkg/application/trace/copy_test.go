@@ -0,0 +1,145 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/store"
+)
+
+func TestCopy_AcrossStores(t *testing.T) {
+	ctx := context.Background()
+	// Production data in the default store, the sandbox in its own region.
+	// Both databases need the project rows for their foreign keys.
+	primary, sandboxStore := newTestStore(t), newTestStore(t)
+	regional := store.NewRegional(primary, primary, map[string]repository.Store{"sandbox": sandboxStore})
+
+	newProject := func(key, owner, region string) *entity.Project {
+		p := &entity.Project{Name: key, APIKey: key, APIKeyHash: key, OwnerEmail: owner,
+			Settings: entity.ProjectSettings{Region: region}}
+		if err := primary.CreateProject(ctx, p); err != nil {
+			t.Fatalf("failed to create project: %v", err)
+		}
+		if region != "" {
+			if err := sandboxStore.CreateProject(ctx, p); err != nil {
+				t.Fatalf("failed to create project: %v", err)
+			}
+		}
+		return p
+	}
+	prod := newProject("le_copy_prod", "copy@test.com", "")
+	sandbox := newProject("le_copy_sandbox", "copy@test.com", "sandbox")
+	foreign := newProject("le_copy_foreign", "other@test.com", "")
+
+	svc := NewService(regional, service.NewPricingCalculator())
+	svc.SetProjectStore(primary)
+
+	// agent -> llm -> tool, plus a second tool under the agent
+	tr := &entity.Trace{ProjectID: prod.ID, Status: entity.TraceStatusCompleted, Metadata: map[string]any{"env": "prod"}}
+	if err := regional.CreateTrace(ctx, tr); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+	start := time.Now().Add(-time.Minute)
+	agentID, llmID := "copy-agent", "copy-llm"
+	spans := []entity.Span{
+		{ID: agentID, Type: entity.SpanTypeAgent, Name: "agent", StartedAt: start},
+		{ID: llmID, ParentSpanID: &agentID, Type: entity.SpanTypeLLM, Name: "chat", StartedAt: start.Add(time.Second)},
+		{ID: "copy-tool-1", ParentSpanID: &llmID, Type: entity.SpanTypeTool, Name: "search", StartedAt: start.Add(2 * time.Second)},
+		{ID: "copy-tool-2", ParentSpanID: &agentID, Type: entity.SpanTypeTool, Name: "fetch", StartedAt: start.Add(3 * time.Second)},
+	}
+	for i := range spans {
+		spans[i].TraceID = tr.ID
+		spans[i].Status = entity.SpanStatusSuccess
+	}
+	if err := regional.CreateSpans(ctx, prod.ID, spans); err != nil {
+		t.Fatalf("failed to create spans: %v", err)
+	}
+
+	result, err := svc.Copy(ctx, prod.ID, tr.ID, sandbox.ID)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	// The copy lives in the sandbox database only
+	copied, err := sandboxStore.GetTrace(ctx, sandbox.ID, result.TraceID)
+	if err != nil {
+		t.Fatalf("copied trace not in the sandbox store: %v", err)
+	}
+	if _, err := primary.GetTrace(ctx, sandbox.ID, result.TraceID); !errors.Is(err, entity.ErrNotFound) {
+		t.Errorf("expected the copy to stay out of the production store, got %v", err)
+	}
+	if copied.ID == tr.ID || copied.Status != entity.TraceStatusCompleted || copied.Metadata["env"] != "prod" {
+		t.Errorf("unexpected copied trace: %+v", copied.Trace)
+	}
+
+	if len(copied.Spans) != len(spans) {
+		t.Fatalf("expected %d spans, got %d", len(spans), len(copied.Spans))
+	}
+	byID := make(map[string]entity.Span)
+	for _, sp := range copied.Spans {
+		byID[sp.ID] = sp
+	}
+	for _, original := range spans {
+		newID := result.SpanIDs[original.ID]
+		sp, ok := byID[newID]
+		if !ok || newID == original.ID {
+			t.Fatalf("span %s: expected a copy with a fresh ID, got %q", original.ID, newID)
+		}
+		if sp.Name != original.Name {
+			t.Errorf("span %s: expected name %s, got %s", original.ID, original.Name, sp.Name)
+		}
+		switch {
+		case original.ParentSpanID == nil && sp.ParentSpanID != nil:
+			t.Errorf("span %s: expected a root span, got parent %s", original.ID, *sp.ParentSpanID)
+		case original.ParentSpanID != nil && (sp.ParentSpanID == nil || *sp.ParentSpanID != result.SpanIDs[*original.ParentSpanID]):
+			t.Errorf("span %s: expected parent %s, got %v", original.ID, result.SpanIDs[*original.ParentSpanID], sp.ParentSpanID)
+		}
+	}
+
+	t.Run("target owned by someone else", func(t *testing.T) {
+		if _, err := svc.Copy(ctx, prod.ID, tr.ID, foreign.ID); !errors.Is(err, ErrTargetProjectNotFound) {
+			t.Errorf("expected ErrTargetProjectNotFound, got %v", err)
+		}
+	})
+
+	t.Run("trace and spans written in one call", func(t *testing.T) {
+		svc := NewService(&separateWritesStore{Store: regional, t: t}, service.NewPricingCalculator())
+		svc.SetProjectStore(primary)
+		result, err := svc.Copy(ctx, prod.ID, tr.ID, sandbox.ID)
+		if err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		copied, err := sandboxStore.GetTrace(ctx, sandbox.ID, result.TraceID)
+		if err != nil || len(copied.Spans) != len(spans) {
+			t.Errorf("expected the copy with %d spans, got %v (err %v)", len(spans), copied, err)
+		}
+	})
+
+	t.Run("unknown trace", func(t *testing.T) {
+		if _, err := svc.Copy(ctx, prod.ID, "missing-trace", sandbox.ID); !errors.Is(err, entity.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+// separateWritesStore fails the test when a trace or its spans are written on
+// their own rather than together with CreateTracesWithSpans
+type separateWritesStore struct {
+	repository.Store
+	t *testing.T
+}
+
+func (s *separateWritesStore) CreateTrace(ctx context.Context, trace *entity.Trace) error {
+	s.t.Errorf("unexpected CreateTrace for trace %s", trace.ID)
+	return s.Store.CreateTrace(ctx, trace)
+}
+
+func (s *separateWritesStore) CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error {
+	s.t.Errorf("unexpected CreateSpans for %d spans", len(spans))
+	return s.Store.CreateSpans(ctx, projectID, spans)
+}
@@ -1,5 +1,7 @@
 package trace
 
+import "time"
+
 // CreateTraceRequest is the request to create a trace
 type CreateTraceRequest struct {
 	SessionID string         `json:"sessionId,omitempty"`
@@ -30,4 +32,73 @@ type CreateSpanRequest struct {
 	Provider     string         `json:"provider,omitempty"`
 	ParentSpanID string         `json:"parentSpanId,omitempty"`
 	Metadata     map[string]any `json:"metadata,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	Links        []SpanLink     `json:"links,omitempty"` // a link without a traceId points into this trace; without a spanId it is dropped
+}
+
+// UpdateSpanRequest is the request to update a span; tags replace the
+// span's tags (an empty list clears them)
+type UpdateSpanRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// CopyTraceRequest is the request to copy a trace into another project
+type CopyTraceRequest struct {
+	TargetProjectID string `json:"targetProjectId"`
+}
+
+// CopyTraceResponse identifies the copy; SpanIDs maps each original span ID
+// to the ID of its copy
+type CopyTraceResponse struct {
+	TraceID   string            `json:"traceId"`
+	ProjectID string            `json:"projectId"`
+	SpanIDs   map[string]string `json:"spanIds"`
+}
+
+// SearchSpansRequest is the request to search spans across traces.
+// All filters are optional; Name, Model and Status match exactly, Text is a
+// case-insensitive substring of the span name, input, output or error message.
+// Metadata matches top-level metadata keys exactly; Attribute does the same
+// for one key through the attribute index, so it only finds spans ingested
+// while the key was in the project's indexedAttributes.
+type SearchSpansRequest struct {
+	Type      string            `json:"type,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Model     string            `json:"model,omitempty"`
+	From      *time.Time        `json:"from,omitempty"`
+	To        *time.Time        `json:"to,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Tags      []string          `json:"tags,omitempty"` // spans with any of these span tags
+	Attribute *AttributeMatch   `json:"attribute,omitempty"`
+	Limit     int               `json:"limit,omitempty"`
+	Offset    int               `json:"offset,omitempty"`
+}
+
+// AttributeMatch selects spans whose indexed attribute Key equals Value
+type AttributeMatch struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// FeedbackRequest is an end user's rating of a trace: -1, 0 or 1
+type FeedbackRequest struct {
+	Value   *int   `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// RecostRequest is the request to recompute span costs with the current pricing table.
+// Both bounds are optional; the default window is the last 30 days.
+type RecostRequest struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// RecostResponse reports how many spans were examined and how many had their cost changed
+type RecostResponse struct {
+	Scanned int       `json:"scanned"`
+	Updated int64     `json:"updated"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
 }
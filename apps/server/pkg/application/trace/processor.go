@@ -79,6 +79,23 @@ func ProcessTraceDetail(trace *entity.TraceWithSpans) *TraceDetailResponse {
 			totalDurationMs += *span.DurationMs
 		}
 	}
+	totalSpans := len(trace.Spans)
+	if trace.SpansTruncated {
+		// Only the first spans were loaded; the store summed them all
+		totalSpans = trace.TotalSpans
+		totalTokens = trace.TotalTokens
+		totalCostUSD = trace.TotalCostUSD
+		totalDurationMs = trace.TotalDurationMs
+	}
+
+	var rootCause *RootCauseError
+	if trace.RootCauseError != nil {
+		rootCause = &RootCauseError{
+			SpanID:   trace.RootCauseError.SpanID,
+			SpanName: trace.RootCauseError.SpanName,
+			Message:  trace.RootCauseError.Message,
+		}
+	}
 
 	return &TraceDetailResponse{
 		ID:              trace.ID,
@@ -90,10 +107,15 @@ func ProcessTraceDetail(trace *entity.TraceWithSpans) *TraceDetailResponse {
 		Metadata:        trace.Metadata,
 		CreatedAt:       trace.CreatedAt,
 		UpdatedAt:       trace.UpdatedAt,
-		TotalSpans:      len(trace.Spans),
+		ParentTraceID:   trace.ParentTraceID,
+		TotalSpans:      totalSpans,
 		TotalTokens:     totalTokens,
 		TotalCostUSD:    totalCostUSD,
 		TotalDurationMs: totalDurationMs,
+		SpansTruncated:  trace.SpansTruncated,
+		RootCauseError:  rootCause,
+		ToolsUsed:       trace.ToolsUsed,
+		MaxDepth:        trace.MaxDepth,
 		SpanTree:        spanTree,
 		Timeline:        timeline,
 	}
@@ -127,9 +149,14 @@ func spanToProcessed(span entity.Span, toolResults map[string]toolResultData) Pr
 		ReasoningTokens:  span.ReasoningTokens,
 		FirstTokenMs:     span.FirstTokenMs,
 		Thinking:         span.Thinking,
+		ModelParams:      span.ModelParams,
 		SubType:          span.SubType, // Pre-computed at ingest
 	}
 
+	for _, link := range span.Links {
+		processed.Links = append(processed.Links, SpanLink(link))
+	}
+
 	// Decompose cost by token type for LLM spans (computed on-the-fly from the
 	// stored token counts + pricing table — no extra persistence).
 	processed.CostBreakdown = computeSpanCostBreakdown(span)
@@ -164,7 +191,11 @@ func spanToProcessed(span entity.Span, toolResults map[string]toolResultData) Pr
 // computeSpanCostBreakdown decomposes an LLM span's cost by token type. Returns
 // nil for non-LLM spans or spans without a model. Uses the same disjoint-bucket
 // normalization as ingest, so the components always sum to the stored CostUSD.
+// A breakdown sent at ingest (cost_details) is returned as is, for any span type.
 func computeSpanCostBreakdown(span entity.Span) *SpanCostBreakdown {
+	if reported := reportedCostBreakdown(span.Metadata); reported != nil {
+		return reported
+	}
 	if span.Type != entity.SpanTypeLLM || span.Model == nil {
 		return nil
 	}
@@ -203,6 +234,39 @@ func computeSpanCostBreakdown(span entity.Span) *SpanCostBreakdown {
 	}
 }
 
+// reportedCostBreakdown builds the breakdown from a span's cost_details
+// metadata. Classes without a field of their own only count toward the total.
+func reportedCostBreakdown(metadata map[string]any) *SpanCostBreakdown {
+	details, ok := metadata[entity.SpanMetadataCostDetails].(map[string]any)
+	if !ok || len(details) == 0 {
+		return nil
+	}
+
+	b := &SpanCostBreakdown{Reported: true}
+	total := 0.0
+	for class, v := range details {
+		amount, ok := v.(float64)
+		if !ok {
+			continue
+		}
+		total += amount
+		switch class {
+		case "input":
+			b.Input = amount
+		case "output":
+			b.Output = amount
+		case "cacheRead":
+			b.CacheRead = amount
+		case "cacheWrite":
+			b.CacheWrite = amount
+		case "reasoning":
+			b.Reasoning = amount
+		}
+	}
+	b.Total = math.Round(total*1000000) / 1000000
+	return b
+}
+
 // intOrZero dereferences a *int, returning 0 for nil.
 func intOrZero(p *int) int {
 	if p == nil {
@@ -492,24 +556,43 @@ func buildSpanTree(spans []ProcessedSpan, timeline TimelineContext) []SpanNode {
 		}
 	}
 
-	// THEN: Build parent-child relationships for regular spans
-	var rootNodes []*SpanNode
+	// THEN: Build parent-child relationships for regular spans. Nodes are
+	// copied into their parent's Children, so each one is assembled with all
+	// its descendants before it is attached.
+	childIDs := make(map[string][]string)
+	var rootIDs []string
 	for _, span := range spans {
-		node := nodeMap[span.ID]
 		if span.ParentSpanID != nil {
-			if parent, ok := nodeMap[*span.ParentSpanID]; ok {
-				// Add as child (node already has its tool use children)
-				parent.Children = append(parent.Children, *node)
-			} else {
-				rootNodes = append(rootNodes, node)
+			if _, ok := nodeMap[*span.ParentSpanID]; ok {
+				childIDs[*span.ParentSpanID] = append(childIDs[*span.ParentSpanID], span.ID)
+				continue
+			}
+		}
+		rootIDs = append(rootIDs, span.ID)
+	}
+
+	attached := make(map[string]bool, len(spans))
+	var assemble func(id string) *SpanNode
+	assemble = func(id string) *SpanNode {
+		attached[id] = true
+		node := nodeMap[id]
+		for _, childID := range childIDs[id] {
+			if !attached[childID] {
+				node.Children = append(node.Children, *assemble(childID))
 			}
-		} else {
-			rootNodes = append(rootNodes, node)
 		}
+		return node
+	}
+	rootNodes := make([]*SpanNode, 0, len(rootIDs))
+	for _, id := range rootIDs {
+		rootNodes = append(rootNodes, assemble(id))
 	}
 
-	// Set depths recursively
+	// Set depths and subtree totals recursively
 	setDepths(rootNodes, 0)
+	for _, node := range rootNodes {
+		setSubtreeTotals(node)
+	}
 
 	// Sort children: agent first, then by time, tool uses at end
 	sortNodes(rootNodes)
@@ -535,9 +618,25 @@ func setDepths(nodes []*SpanNode, depth int) {
 	}
 }
 
-// sortNodes recursively sorts nodes
+// setSubtreeTotals sets the subtree cost and tokens of node and all its
+// descendants
+func setSubtreeTotals(node *SpanNode) {
+	if node.Span.CostUSD != nil {
+		node.SubtreeCostUSD = *node.Span.CostUSD
+	}
+	node.SubtreeTokens = intOrZero(node.Span.InputTokens) + intOrZero(node.Span.OutputTokens)
+	for i := range node.Children {
+		child := &node.Children[i]
+		setSubtreeTotals(child)
+		node.SubtreeCostUSD += child.SubtreeCostUSD
+		node.SubtreeTokens += child.SubtreeTokens
+	}
+}
+
+// sortNodes recursively sorts nodes. The sort is stable, so siblings started
+// at the same time keep the store's emission order.
 func sortNodes(nodes []*SpanNode) {
-	sort.Slice(nodes, func(i, j int) bool {
+	sort.SliceStable(nodes, func(i, j int) bool {
 		// Agent spans first
 		if nodes[i].Span.Type == "agent" && nodes[j].Span.Type != "agent" {
 			return true
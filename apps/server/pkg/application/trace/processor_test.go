@@ -3,6 +3,7 @@ package trace
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/lelemon/server/pkg/domain/entity"
 )
@@ -61,3 +62,57 @@ func TestComputeSpanCostBreakdown_NonLLM(t *testing.T) {
 		t.Errorf("LLM span without model should have no breakdown, got %+v", b)
 	}
 }
+
+// TestProcessTraceDetail_SubtreeTotals verifies each tree node's subtree cost
+// and tokens are its own plus all its descendants', at every level.
+func TestProcessTraceDetail_SubtreeTotals(t *testing.T) {
+	cost := func(v float64) *float64 { return &v }
+	start := time.Now()
+	spans := []entity.Span{
+		{ID: "root", Type: entity.SpanTypeAgent, Name: "agent", StartedAt: start},
+		{ID: "research", ParentSpanID: ptrStr("root"), Type: entity.SpanTypeAgent, Name: "research",
+			CostUSD: cost(0.125), StartedAt: start.Add(time.Second)},
+		{ID: "search", ParentSpanID: ptrStr("research"), Type: entity.SpanTypeLLM, Name: "search",
+			InputTokens: ptrInt(100), OutputTokens: ptrInt(20), CostUSD: cost(0.25), StartedAt: start.Add(2 * time.Second)},
+		{ID: "fetch", ParentSpanID: ptrStr("search"), Type: entity.SpanTypeTool, Name: "fetch",
+			CostUSD: cost(0.0625), StartedAt: start.Add(3 * time.Second)},
+		{ID: "answer", ParentSpanID: ptrStr("root"), Type: entity.SpanTypeLLM, Name: "answer",
+			InputTokens: ptrInt(300), OutputTokens: ptrInt(50), CostUSD: cost(0.5), StartedAt: start.Add(4 * time.Second)},
+	}
+
+	detail := ProcessTraceDetail(&entity.TraceWithSpans{Spans: spans})
+	nodes := make(map[string]SpanNode)
+	var walk func([]SpanNode)
+	walk = func(level []SpanNode) {
+		for _, node := range level {
+			nodes[node.Span.ID] = node
+			walk(node.Children)
+		}
+	}
+	walk(detail.SpanTree)
+
+	want := []struct {
+		id     string
+		cost   float64
+		tokens int
+	}{
+		{"root", 0.125 + 0.25 + 0.0625 + 0.5, 120 + 350},
+		{"research", 0.125 + 0.25 + 0.0625, 120},
+		{"search", 0.25 + 0.0625, 120},
+		{"fetch", 0.0625, 0},
+		{"answer", 0.5, 350},
+	}
+	for _, w := range want {
+		node, ok := nodes[w.id]
+		if !ok {
+			t.Errorf("%s: missing from the tree", w.id)
+			continue
+		}
+		if !approxEq(node.SubtreeCostUSD, w.cost) || node.SubtreeTokens != w.tokens {
+			t.Errorf("%s: expected subtree %v / %d tokens, got %v / %d", w.id, w.cost, w.tokens, node.SubtreeCostUSD, node.SubtreeTokens)
+		}
+	}
+	if got := nodes["root"].SubtreeCostUSD; !approxEq(got, detail.TotalCostUSD) {
+		t.Errorf("expected the root's subtree cost to equal the trace total %v, got %v", detail.TotalCostUSD, got)
+	}
+}
@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+const (
+	// DefaultReapInterval is how often stale active traces are looked for.
+	DefaultReapInterval = time.Minute
+
+	reapBatchSize = 100
+)
+
+// Reaper marks active traces as error once they have gone without a new span
+// for the inactivity timeout, so hung agents don't stay "active" forever.
+// A trace whose agent resumes after being reaped keeps the error status.
+type Reaper struct {
+	projects repository.ProjectStore
+	traces   repository.TraceStore
+	timeout  time.Duration
+}
+
+// NewReaper creates a reaper for traces inactive for at least timeout.
+// Projects are read from projects; traces from traces.
+func NewReaper(projects repository.ProjectStore, traces repository.TraceStore, timeout time.Duration) *Reaper {
+	return &Reaper{
+		projects: projects,
+		traces:   traces,
+		timeout:  timeout,
+	}
+}
+
+// Start runs Reap every interval in the background until ctx is cancelled.
+func (r *Reaper) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.Reap(ctx); err != nil {
+					slog.Warn("stale trace reaping failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Reap marks every project's stale active traces as error, once, and returns
+// how many were marked. Per-project failures are logged and do not stop the pass.
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	projects, err := r.projects.ListProjects(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list projects: %w", err)
+	}
+
+	reaped := 0
+	for _, p := range projects {
+		n, err := r.reapProject(ctx, p.ID)
+		reaped += n
+		if err != nil {
+			slog.Warn("stale trace reaping failed", "projectID", p.ID, "error", err)
+		}
+	}
+
+	return reaped, nil
+}
+
+func (r *Reaper) reapProject(ctx context.Context, projectID string) (int, error) {
+	minInactiveMs := r.timeout.Milliseconds()
+	filter := entity.TraceFilter{MinInactiveMs: &minInactiveMs, Limit: reapBatchSize}
+
+	// Reaped traces leave the filter, so each batch starts from the top. A
+	// batch with nothing new (an update not yet visible) ends the pass.
+	seen := make(map[string]bool)
+	for {
+		page, err := r.traces.ListTraces(ctx, projectID, filter)
+		if err != nil {
+			return len(seen), err
+		}
+		progressed := false
+		for _, t := range page.Data {
+			if seen[t.ID] {
+				continue
+			}
+			if err := r.traces.UpdateTraceStatus(ctx, projectID, t.ID, entity.TraceStatusError); err != nil {
+				return len(seen), fmt.Errorf("trace %s: %w", t.ID, err)
+			}
+			seen[t.ID] = true
+			progressed = true
+		}
+		if !progressed || len(page.Data) < reapBatchSize {
+			return len(seen), nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestReaper_MarksStaleActiveTraces(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir() + "/reaper.db"
+	store := newTestStoreAt(t, dbPath)
+
+	project := newTestProject(t, store, "reaper", entity.ProjectSettings{})
+
+	now := time.Now()
+	newTrace := func(status entity.TraceStatus, createdAt time.Time, spanStarts ...time.Time) string {
+		tr := &entity.Trace{ProjectID: project.ID, Status: status}
+		if err := store.CreateTrace(ctx, tr); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		backdateTrace(t, dbPath, tr.ID, createdAt)
+		for _, startedAt := range spanStarts {
+			sp := &entity.Span{TraceID: tr.ID, Type: entity.SpanTypeTool, Name: "step",
+				Status: entity.SpanStatusSuccess, StartedAt: startedAt}
+			if err := store.CreateSpan(ctx, project.ID, sp); err != nil {
+				t.Fatalf("failed to create span: %v", err)
+			}
+		}
+		return tr.ID
+	}
+
+	stale := newTrace(entity.TraceStatusActive, now.Add(-3*time.Hour), now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	staleNoSpans := newTrace(entity.TraceStatusActive, now.Add(-2*time.Hour))
+	resumed := newTrace(entity.TraceStatusActive, now.Add(-3*time.Hour), now.Add(-3*time.Hour), now.Add(-time.Minute))
+	fresh := newTrace(entity.TraceStatusActive, now.Add(-time.Minute))
+	completed := newTrace(entity.TraceStatusCompleted, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+
+	t.Run("lists active traces with their inactivity", func(t *testing.T) {
+		minInactiveMs := time.Hour.Milliseconds()
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{MinInactiveMs: &minInactiveMs})
+		if err != nil {
+			t.Fatalf("ListTraces: %v", err)
+		}
+		got := map[string]*int64{}
+		for _, tr := range page.Data {
+			got[tr.ID] = tr.InactiveForMs
+		}
+		if len(got) != 2 || got[stale] == nil || got[staleNoSpans] == nil {
+			t.Fatalf("expected the two stale traces, got %v", got)
+		}
+		// Inactivity counts from the latest span, not the first
+		if ms := *got[stale]; ms < (2*time.Hour).Milliseconds() || ms > (3*time.Hour).Milliseconds() {
+			t.Errorf("expected ~2h of inactivity for the stale trace, got %dms", ms)
+		}
+		if ms := *got[staleNoSpans]; ms < (2 * time.Hour).Milliseconds() {
+			t.Errorf("expected >=2h of inactivity for the trace without spans, got %dms", ms)
+		}
+
+		all, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{})
+		if err != nil {
+			t.Fatalf("ListTraces: %v", err)
+		}
+		for _, tr := range all.Data {
+			if tr.ID == completed && tr.InactiveForMs != nil {
+				t.Errorf("expected no inactivity for a completed trace, got %d", *tr.InactiveForMs)
+			}
+		}
+	})
+
+	t.Run("reaps only stale active traces", func(t *testing.T) {
+		reaped, err := NewReaper(store, store, time.Hour).Reap(ctx)
+		if err != nil {
+			t.Fatalf("Reap: %v", err)
+		}
+		if reaped != 2 {
+			t.Errorf("expected 2 traces reaped, got %d", reaped)
+		}
+
+		want := map[string]entity.TraceStatus{
+			stale:        entity.TraceStatusError,
+			staleNoSpans: entity.TraceStatusError,
+			resumed:      entity.TraceStatusActive,
+			fresh:        entity.TraceStatusActive,
+			completed:    entity.TraceStatusCompleted,
+		}
+		for id, status := range want {
+			tr, err := store.GetTrace(ctx, project.ID, id)
+			if err != nil {
+				t.Fatalf("GetTrace: %v", err)
+			}
+			if tr.Status != status {
+				t.Errorf("trace %s: expected %s, got %s", id, status, tr.Status)
+			}
+		}
+
+		// A second pass finds nothing left to reap
+		if reaped, err := NewReaper(store, store, time.Hour).Reap(ctx); err != nil || reaped != 0 {
+			t.Errorf("expected nothing on the second pass, got %d (%v)", reaped, err)
+		}
+	})
+}
+
+// backdateTrace sets a stored trace's created_at; CreateTrace always stamps the current time
+func backdateTrace(t *testing.T, dbPath, traceID string, createdAt time.Time) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE traces SET created_at = ? WHERE id = ?`, createdAt, traceID); err != nil {
+		t.Fatalf("failed to backdate trace: %v", err)
+	}
+}
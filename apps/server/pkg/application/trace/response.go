@@ -16,12 +16,28 @@ type TraceDetailResponse struct {
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 
+	// ParentTraceID links to the trace that spawned this one
+	ParentTraceID *string `json:"parentTraceId,omitempty"`
+
 	// Aggregate metrics
 	TotalSpans      int     `json:"totalSpans"`
 	TotalTokens     int     `json:"totalTokens"`
 	TotalCostUSD    float64 `json:"totalCostUsd"`
 	TotalDurationMs int     `json:"totalDurationMs"`
 
+	// SpansTruncated is set when the tree holds only the first spans of the
+	// trace; page through the rest with GET /traces/{id}/spans
+	SpansTruncated bool `json:"spansTruncated,omitempty"`
+
+	// RootCauseError points at the span the trace's failure originated in
+	RootCauseError *RootCauseError `json:"rootCauseError,omitempty"`
+
+	// ToolsUsed are the distinct tools the trace invoked
+	ToolsUsed []string `json:"toolsUsed"`
+
+	// MaxDepth is the number of levels of the span tree
+	MaxDepth int `json:"maxDepth"`
+
 	// Pre-processed span tree (hierarchical structure)
 	SpanTree []SpanNode `json:"spanTree"`
 
@@ -29,6 +45,13 @@ type TraceDetailResponse struct {
 	Timeline TimelineContext `json:"timeline"`
 }
 
+// RootCauseError is the originating error of a failed trace
+type RootCauseError struct {
+	SpanID   string `json:"spanId"`
+	SpanName string `json:"spanName"`
+	Message  string `json:"message"`
+}
+
 // SpanNode represents a node in the span tree
 type SpanNode struct {
 	Span          ProcessedSpan `json:"span"`
@@ -36,6 +59,10 @@ type SpanNode struct {
 	Depth         int           `json:"depth"`
 	TimelineStart float64       `json:"timelineStart"` // 0-100%
 	TimelineWidth float64       `json:"timelineWidth"` // 0-100%
+	// Subtree totals: the node's own cost and tokens plus its descendants',
+	// computed from the spans in the tree on every request
+	SubtreeCostUSD float64 `json:"subtreeCostUsd"`
+	SubtreeTokens  int     `json:"subtreeTokens"`
 }
 
 // ProcessedSpan is a span with computed fields for visualization
@@ -68,6 +95,12 @@ type ProcessedSpan struct {
 	FirstTokenMs     *int    `json:"firstTokenMs"`
 	Thinking         *string `json:"thinking"`
 
+	// Generation settings (temperature, top_p, max_tokens, ...)
+	ModelParams map[string]any `json:"modelParams,omitempty"`
+
+	// Related spans outside the parent chain (OpenTelemetry span links)
+	Links []SpanLink `json:"links,omitempty"`
+
 	// Computed fields (calculated by backend)
 	SubType       *string            `json:"subType,omitempty"`       // "planning" | "response" for LLM spans
 	ToolUses      []ToolUse          `json:"toolUses,omitempty"`      // Extracted tool calls from output
@@ -79,6 +112,7 @@ type ProcessedSpan struct {
 
 // SpanCostBreakdown decomposes an LLM span's cost (USD) by token type, plus the
 // savings achieved by paying the cache-read rate instead of full input price.
+// Spans ingested with costDetails report the client's breakdown instead.
 type SpanCostBreakdown struct {
 	Input        float64 `json:"input"`
 	Output       float64 `json:"output"`
@@ -86,7 +120,8 @@ type SpanCostBreakdown struct {
 	CacheWrite   float64 `json:"cacheWrite"`
 	Reasoning    float64 `json:"reasoning"`
 	Total        float64 `json:"total"`
-	CacheSavings float64 `json:"cacheSavings"` // saved vs paying full input price on cached reads
+	CacheSavings float64 `json:"cacheSavings"`       // saved vs paying full input price on cached reads
+	Reported     bool    `json:"reported,omitempty"` // taken from the costDetails sent at ingest rather than the pricing table
 }
 
 // ToolUse represents a tool call extracted from LLM output
@@ -99,6 +134,14 @@ type ToolUse struct {
 	DurationMs *int   `json:"durationMs"`
 }
 
+// SpanLink references a span related to another outside its parent chain,
+// possibly in another trace
+type SpanLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
 // TimelineContext provides timing information for timeline visualization
 type TimelineContext struct {
 	MinTime       int64 `json:"minTime"`       // Unix timestamp in milliseconds
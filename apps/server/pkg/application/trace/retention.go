@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// DefaultContentSweepInterval is how often span content past its retention
+// is looked for.
+const DefaultContentSweepInterval = time.Hour
+
+// ContentSweeper clears the input, output and thinking of spans older than
+// their project's SpanContentRetentionDays. Tokens, cost and duration stay,
+// so analytics over the period are unchanged.
+type ContentSweeper struct {
+	projects repository.ProjectStore
+	traces   repository.TraceStore
+	now      func() time.Time
+}
+
+// NewContentSweeper creates a span content sweeper.
+// Projects are read from projects; spans are cleared in traces.
+func NewContentSweeper(projects repository.ProjectStore, traces repository.TraceStore) *ContentSweeper {
+	return &ContentSweeper{
+		projects: projects,
+		traces:   traces,
+		now:      time.Now,
+	}
+}
+
+// Start runs Sweep every interval in the background until ctx is cancelled.
+func (s *ContentSweeper) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultContentSweepInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Sweep(ctx); err != nil {
+					slog.Warn("span content sweep failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Sweep clears expired span content in every project with a span content
+// retention, once, and returns how many spans were cleared. Per-project
+// failures are logged and do not stop the pass.
+func (s *ContentSweeper) Sweep(ctx context.Context) (int64, error) {
+	projects, err := s.projects.ListProjects(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list projects: %w", err)
+	}
+
+	var cleared int64
+	for _, p := range projects {
+		days := p.Settings.SpanContentRetentionDays
+		if days == nil || *days < 1 {
+			continue
+		}
+		n, err := s.traces.ExpireSpanContent(ctx, p.ID, s.now().AddDate(0, 0, -*days))
+		if err != nil {
+			slog.Warn("span content sweep failed", "projectID", p.ID, "error", err)
+			continue
+		}
+		cleared += n
+	}
+
+	return cleared, nil
+}
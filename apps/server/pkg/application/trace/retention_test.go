@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+func TestContentSweeper_ClearsExpiredSpanContent(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	week := 7
+	retained := newTestProject(t, store, "retained", entity.ProjectSettings{SpanContentRetentionDays: &week})
+	forever := newTestProject(t, store, "forever", entity.ProjectSettings{})
+
+	now := time.Now()
+	newSpan := func(project *entity.Project, startedAt time.Time) string {
+		tr := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		if err := store.CreateTrace(ctx, tr); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		in, out, cost, duration := 100, 20, 0.25, 1500
+		thinking := "let me think"
+		sp := &entity.Span{TraceID: tr.ID, Type: entity.SpanTypeLLM, Name: "chat", Status: entity.SpanStatusSuccess,
+			Input: map[string]any{"prompt": "secret"}, Output: "answer", Thinking: &thinking,
+			InputTokens: &in, OutputTokens: &out, CostUSD: &cost, DurationMs: &duration,
+			Metadata: map[string]any{"team": "support"}, StartedAt: startedAt}
+		if err := store.CreateSpan(ctx, project.ID, sp); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+		return tr.ID
+	}
+	span := func(project *entity.Project, traceID string) entity.Span {
+		t.Helper()
+		trace, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("GetTrace: %v", err)
+		}
+		return trace.Spans[0]
+	}
+	stats := func(project *entity.Project) *entity.Stats {
+		t.Helper()
+		stats, err := store.GetStats(ctx, project.ID, entity.AnalyticsQuery{
+			Period: entity.Period{From: now.AddDate(0, 0, -30), To: now.Add(time.Hour)},
+		})
+		if err != nil {
+			t.Fatalf("GetStats: %v", err)
+		}
+		return stats
+	}
+
+	expired := newSpan(retained, now.AddDate(0, 0, -10))
+	recent := newSpan(retained, now.AddDate(0, 0, -1))
+	kept := newSpan(forever, now.AddDate(0, 0, -10))
+	before := stats(retained)
+
+	sweeper := NewContentSweeper(store, store)
+	cleared, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if cleared != 1 {
+		t.Errorf("expected 1 span cleared, got %d", cleared)
+	}
+
+	t.Run("content is cleared and flagged", func(t *testing.T) {
+		sp := span(retained, expired)
+		if sp.Input != nil || sp.Output != nil || sp.Thinking != nil {
+			t.Errorf("expected input, output and thinking cleared, got %v / %v / %v", sp.Input, sp.Output, sp.Thinking)
+		}
+		if sp.Metadata[entity.SpanMetadataContentExpired] != true || sp.Metadata["team"] != "support" {
+			t.Errorf("expected the content_expired flag next to the existing metadata, got %v", sp.Metadata)
+		}
+	})
+
+	t.Run("metrics remain", func(t *testing.T) {
+		sp := span(retained, expired)
+		if *sp.InputTokens != 100 || *sp.OutputTokens != 20 || *sp.CostUSD != 0.25 || *sp.DurationMs != 1500 {
+			t.Errorf("expected tokens, cost and duration kept, got %+v", sp)
+		}
+		after := stats(retained)
+		if after.TotalTokens != before.TotalTokens || after.TotalCostUSD != before.TotalCostUSD || after.TotalSpans != before.TotalSpans {
+			t.Errorf("expected analytics unchanged, got %+v then %+v", before, after)
+		}
+	})
+
+	t.Run("spans within the window and other projects keep their content", func(t *testing.T) {
+		for _, sp := range []entity.Span{span(retained, recent), span(forever, kept)} {
+			if sp.Input == nil || sp.Output == nil || sp.Metadata[entity.SpanMetadataContentExpired] != nil {
+				t.Errorf("expected content kept, got %+v", sp)
+			}
+		}
+	})
+
+	t.Run("sweeping again clears nothing", func(t *testing.T) {
+		if cleared, err := sweeper.Sweep(ctx); err != nil || cleared != 0 {
+			t.Errorf("expected nothing cleared, got %d (%v)", cleared, err)
+		}
+	})
+}
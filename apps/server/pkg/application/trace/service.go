@@ -2,26 +2,56 @@ package trace
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/lelemon/server/pkg/domain/entity"
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/domain/service"
 )
 
+// ErrInvalidFeedback is returned for a feedback value other than -1, 0 or 1
+var ErrInvalidFeedback = errors.New("feedback value must be -1, 0 or 1")
+
+// DefaultMaxTraceSpans caps the spans returned with a trace, so one runaway
+// trace can't produce an unbounded response
+const DefaultMaxTraceSpans = 5000
+
 // Service handles trace operations
 type Service struct {
-	store   repository.Store
-	pricing *service.PricingCalculator
+	store           repository.Store
+	projects        repository.ProjectStore
+	pricing         *service.PricingCalculator
+	maxSpans        int
+	recostBatchSize int
 }
 
-// NewService creates a new trace service
+// NewService creates a new trace service. Projects are read from store until
+// SetProjectStore says otherwise.
 func NewService(store repository.Store, pricing *service.PricingCalculator) *Service {
 	return &Service{
-		store:   store,
-		pricing: pricing,
+		store:           store,
+		projects:        store,
+		pricing:         pricing,
+		maxSpans:        DefaultMaxTraceSpans,
+		recostBatchSize: defaultRecostBatchSize,
 	}
 }
 
+// SetProjectStore sets where projects are read from (ownership checks), when
+// they live apart from traces, e.g. in the primary store
+func (s *Service) SetProjectStore(projects repository.ProjectStore) {
+	s.projects = projects
+}
+
+// SetMaxSpans caps the spans returned by Get and GetDetail; larger traces are
+// marked SpansTruncated and the rest are read with ListSpans. 0 disables the cap.
+func (s *Service) SetMaxSpans(n int) {
+	s.maxSpans = n
+}
+
 // Create creates a new trace
 func (s *Service) Create(ctx context.Context, projectID string, req *CreateTraceRequest) (*entity.Trace, error) {
 	trace := &entity.Trace{
@@ -51,25 +81,49 @@ func (s *Service) Create(ctx context.Context, projectID string, req *CreateTrace
 	return trace, nil
 }
 
-// Get retrieves a trace with its spans
+// Get retrieves a trace with its spans, up to the span cap
 func (s *Service) Get(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error) {
-	return s.store.GetTrace(ctx, projectID, traceID)
+	trace, err := s.store.GetTraceCapped(ctx, projectID, traceID, s.maxSpans)
+	if err != nil {
+		return nil, err
+	}
+	trace.SetRootCauseError()
+	trace.SetToolsUsed()
+	trace.SetDepths()
+	return trace, nil
 }
 
 // GetDetail retrieves a trace with pre-processed span tree for visualization
 func (s *Service) GetDetail(ctx context.Context, projectID, traceID string) (*TraceDetailResponse, error) {
-	trace, err := s.store.GetTrace(ctx, projectID, traceID)
+	trace, err := s.store.GetTraceCapped(ctx, projectID, traceID, s.maxSpans)
 	if err != nil {
 		return nil, err
 	}
+	trace.SetRootCauseError()
+	trace.SetToolsUsed()
+	trace.SetDepths()
 	return ProcessTraceDetail(trace), nil
 }
 
+// ListSpans pages through the spans of a trace, earliest first
+func (s *Service) ListSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) {
+	return s.store.ListTraceSpans(ctx, projectID, traceID, limit, offset)
+}
+
 // List retrieves traces with pagination and filtering
 func (s *Service) List(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
 	return s.store.ListTraces(ctx, projectID, filter)
 }
 
+// ListChildren pages through the traces linked to traceID as their parent
+// (see entity.Trace.ParentTraceID), newest first
+func (s *Service) ListChildren(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.TraceWithMetrics], error) {
+	if _, err := s.store.GetTraceCapped(ctx, projectID, traceID, 1); err != nil {
+		return nil, err
+	}
+	return s.store.ListTraces(ctx, projectID, entity.TraceFilter{ParentTraceID: &traceID, Limit: limit, Offset: offset})
+}
+
 // Update updates a trace
 func (s *Service) Update(ctx context.Context, projectID, traceID string, req *UpdateTraceRequest) error {
 	updates := entity.TraceUpdate{}
@@ -88,6 +142,14 @@ func (s *Service) Update(ctx context.Context, projectID, traceID string, req *Up
 	return s.store.UpdateTrace(ctx, projectID, traceID, updates)
 }
 
+// UpdateSpan updates a span of a trace
+func (s *Service) UpdateSpan(ctx context.Context, projectID, traceID, spanID string, req *UpdateSpanRequest) error {
+	if req.Tags == nil {
+		return fmt.Errorf("%w: tags is required", entity.ErrBadRequest)
+	}
+	return s.store.UpdateSpan(ctx, projectID, traceID, spanID, entity.SpanUpdate{Tags: req.Tags})
+}
+
 // AddSpan adds a span to a trace
 func (s *Service) AddSpan(ctx context.Context, projectID, traceID string, req *CreateSpanRequest) (*entity.Span, error) {
 	// Verify trace exists and belongs to project
@@ -109,10 +171,8 @@ func (s *Service) AddSpan(ctx context.Context, projectID, traceID string, req *C
 
 	// Determine status
 	spanStatus := entity.SpanStatusSuccess
-	if req.Status == "error" {
-		spanStatus = entity.SpanStatusError
-	} else if req.Status == "pending" {
-		spanStatus = entity.SpanStatusPending
+	if status := entity.SpanStatus(req.Status); entity.IsKnownSpanStatus(status) {
+		spanStatus = status
 	}
 
 	// Calculate cost for LLM spans
@@ -153,6 +213,18 @@ func (s *Service) AddSpan(ctx context.Context, projectID, traceID string, req *C
 	if req.Model != "" {
 		span.Model = &req.Model
 	}
+	if len(req.Tags) > 0 {
+		span.Tags = req.Tags
+	}
+	for _, link := range req.Links {
+		if link.SpanID == "" {
+			continue
+		}
+		if link.TraceID == "" {
+			link.TraceID = traceID
+		}
+		span.Links = append(span.Links, entity.SpanLink(link))
+	}
 	if req.Provider != "" {
 		span.Provider = &req.Provider
 	}
@@ -160,19 +232,218 @@ func (s *Service) AddSpan(ctx context.Context, projectID, traceID string, req *C
 		span.Metadata = make(map[string]any)
 	}
 
-	if err := s.store.CreateSpan(ctx, span); err != nil {
+	if err := s.store.CreateSpan(ctx, projectID, span); err != nil {
 		return nil, err
 	}
 
 	return span, nil
 }
 
+// AddFeedback records an end user's feedback on a trace as a "feedback"
+// score from source user
+func (s *Service) AddFeedback(ctx context.Context, projectID, traceID string, req *FeedbackRequest) (*entity.Score, error) {
+	if req.Value == nil || *req.Value < -1 || *req.Value > 1 {
+		return nil, ErrInvalidFeedback
+	}
+
+	// Verify trace exists and belongs to project
+	if _, err := s.store.GetTrace(ctx, projectID, traceID); err != nil {
+		return nil, err
+	}
+
+	score := &entity.Score{
+		ProjectID: projectID,
+		TraceID:   traceID,
+		Name:      entity.ScoreNameFeedback,
+		Value:     float64(*req.Value),
+		Source:    entity.ScoreSourceUser,
+	}
+	if req.Comment != "" {
+		score.Comment = &req.Comment
+	}
+
+	if err := s.store.CreateScore(ctx, score); err != nil {
+		return nil, err
+	}
+
+	return score, nil
+}
+
 // ListSessions retrieves sessions with pagination
 func (s *Service) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
 	return s.store.ListSessions(ctx, projectID, filter)
 }
 
+// SearchSpans finds spans across all of the project's traces.
+// Each span carries its TraceID so callers can open the enclosing trace.
+func (s *Service) SearchSpans(ctx context.Context, projectID string, req *SearchSpansRequest) (*entity.Page[entity.Span], error) {
+	if req.From != nil && req.To != nil && req.From.After(*req.To) {
+		return nil, entity.ErrBadRequest
+	}
+
+	filter := entity.SpanFilter{
+		From:     req.From,
+		To:       req.To,
+		Text:     req.Text,
+		Metadata: req.Metadata,
+		Tags:     req.Tags,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+	if req.Type != "" {
+		spanType := entity.SpanType(req.Type)
+		filter.Type = &spanType
+	}
+	if req.Name != "" {
+		filter.Name = &req.Name
+	}
+	if req.Status != "" {
+		status := entity.SpanStatus(req.Status)
+		filter.Status = &status
+	}
+	if req.Model != "" {
+		filter.Model = &req.Model
+	}
+
+	if req.Attribute != nil {
+		return s.store.SearchSpansByAttribute(ctx, projectID, req.Attribute.Key, req.Attribute.Value, filter)
+	}
+	return s.store.SearchSpans(ctx, projectID, filter)
+}
+
 // DeleteAll deletes all traces for a project
 func (s *Service) DeleteAll(ctx context.Context, projectID string) (int64, error) {
 	return s.store.DeleteAllTraces(ctx, projectID)
 }
+
+// DeleteByFilter deletes the project's traces matching filter
+func (s *Service) DeleteByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error) {
+	return s.store.DeleteTracesByFilter(ctx, projectID, filter)
+}
+
+// defaultRecostWindow is the range re-priced when a recost request omits From.
+const defaultRecostWindow = 30 * 24 * time.Hour
+
+// defaultRecostBatchSize is the number of spans Recost reads and rewrites at a time.
+const defaultRecostBatchSize = 1000
+
+// Recost recomputes CostUSD for the project's LLM spans in the requested range
+// using the current pricing table, under the project's pricing overrides. Only spans whose cost actually changes are
+// written, so running it twice is a no-op. The stored subtree costs of the
+// agent spans above them are refreshed too.
+func (s *Service) Recost(ctx context.Context, projectID string, req *RecostRequest) (*RecostResponse, error) {
+	to := time.Now()
+	if req.To != nil {
+		to = *req.To
+	}
+	from := to.Add(-defaultRecostWindow)
+	if req.From != nil {
+		from = *req.From
+	}
+	if from.After(to) {
+		return nil, entity.ErrBadRequest
+	}
+
+	project, err := s.store.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	pricing := s.pricing.WithOverrides(service.ProjectPricingOverrides(project.Settings))
+
+	var scanned int
+	var updated int64
+	traceIDs := make(map[string]bool)
+	var after *entity.SpanCursor
+	for {
+		spans, err := s.store.ListSpansForRecost(ctx, projectID, from, to, after, s.recostBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		scanned += len(spans)
+
+		costs := make(map[string]float64)
+		for _, span := range spans {
+			if span.Model == nil {
+				continue
+			}
+			provider := ""
+			if span.Provider != nil {
+				provider = *span.Provider
+			}
+			usage := service.NormalizeTokenUsage(
+				provider,
+				derefInt(span.InputTokens),
+				derefInt(span.OutputTokens),
+				derefInt(span.CacheReadTokens),
+				derefInt(span.CacheWriteTokens),
+				derefInt(span.ReasoningTokens),
+			)
+			cost := pricing.CalculateCostBreakdown(*span.Model, usage).Total
+			if span.CostUSD != nil && math.Abs(*span.CostUSD-cost) < 1e-9 {
+				continue
+			}
+			costs[span.ID] = cost
+			traceIDs[span.TraceID] = true
+		}
+
+		n, err := s.store.UpdateSpanCosts(ctx, projectID, costs)
+		if err != nil {
+			return nil, err
+		}
+		updated += n
+
+		if len(spans) < s.recostBatchSize {
+			break
+		}
+		last := spans[len(spans)-1]
+		after = &entity.SpanCursor{StartedAt: last.StartedAt, ID: last.ID}
+	}
+
+	for traceID := range traceIDs {
+		if err := s.refreshSubtreeCosts(ctx, projectID, traceID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RecostResponse{
+		Scanned: scanned,
+		Updated: updated,
+		From:    from,
+		To:      to,
+	}, nil
+}
+
+// refreshSubtreeCosts rewrites the stored subtree costs of a finished trace's
+// agent spans that no longer match its span costs. Running traces are left
+// to ingest, which sets them when the trace ends.
+func (s *Service) refreshSubtreeCosts(ctx context.Context, projectID, traceID string) error {
+	trace, err := s.store.GetTrace(ctx, projectID, traceID)
+	if errors.Is(err, entity.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if trace.Status == entity.TraceStatusActive {
+		return nil
+	}
+
+	costs := entity.SubtreeCosts(trace.Spans)
+	for _, span := range trace.Spans {
+		if cost, ok := costs[span.ID]; ok && span.SubtreeCostUSD != nil && *span.SubtreeCostUSD == cost {
+			delete(costs, span.ID)
+		}
+	}
+	if len(costs) == 0 {
+		return nil
+	}
+	_, err = s.store.UpdateSpanSubtreeCosts(ctx, projectID, costs)
+	return err
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
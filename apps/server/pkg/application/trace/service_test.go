@@ -0,0 +1,535 @@
+package trace
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+func TestRecost(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	pricing := service.NewPricingCalculator()
+	svc := NewService(store, pricing)
+
+	newLLMSpan := func(projectID string, startedAt time.Time, cost float64) *entity.Span {
+		tr := &entity.Trace{ProjectID: projectID, Status: entity.TraceStatusCompleted}
+		if err := store.CreateTrace(ctx, tr); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		model, provider := "gpt-4o", "openai"
+		in, out := 1000, 500
+		sp := &entity.Span{
+			TraceID:      tr.ID,
+			Type:         entity.SpanTypeLLM,
+			Name:         "chat",
+			Model:        &model,
+			Provider:     &provider,
+			InputTokens:  &in,
+			OutputTokens: &out,
+			CostUSD:      &cost,
+			Status:       entity.SpanStatusSuccess,
+			StartedAt:    startedAt,
+		}
+		if err := store.CreateSpan(ctx, projectID, sp); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+		return sp
+	}
+	spanCost := func(projectID, traceID string) float64 {
+		tr, err := store.GetTrace(ctx, projectID, traceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		return *tr.Spans[0].CostUSD
+	}
+
+	// Costs recorded under an old rate card ($1.00 for a call now priced at $0.0075).
+	now := time.Now()
+	project := newTestProject(t, store, "recost", entity.ProjectSettings{})
+	other := newTestProject(t, store, "other", entity.ProjectSettings{})
+	stale := newLLMSpan(project.ID, now.Add(-time.Hour), 1.0)
+	outOfRange := newLLMSpan(project.ID, now.Add(-60*24*time.Hour), 1.0)
+	foreign := newLLMSpan(other.ID, now.Add(-time.Hour), 1.0)
+
+	expected := pricing.CalculateCost("gpt-4o", 1000, 500)
+
+	t.Run("recomputes stale costs in range", func(t *testing.T) {
+		result, err := svc.Recost(ctx, project.ID, &RecostRequest{})
+		if err != nil {
+			t.Fatalf("Recost failed: %v", err)
+		}
+		if result.Scanned != 1 || result.Updated != 1 {
+			t.Errorf("expected 1 scanned / 1 updated, got %d / %d", result.Scanned, result.Updated)
+		}
+		if got := spanCost(project.ID, stale.TraceID); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("expected cost %f, got %f", expected, got)
+		}
+		if got := spanCost(project.ID, outOfRange.TraceID); got != 1.0 {
+			t.Errorf("span outside range should keep its cost, got %f", got)
+		}
+		if got := spanCost(other.ID, foreign.TraceID); got != 1.0 {
+			t.Errorf("other project's span should keep its cost, got %f", got)
+		}
+	})
+
+	t.Run("is idempotent", func(t *testing.T) {
+		result, err := svc.Recost(ctx, project.ID, &RecostRequest{})
+		if err != nil {
+			t.Fatalf("Recost failed: %v", err)
+		}
+		if result.Updated != 0 {
+			t.Errorf("expected 0 updated on second run, got %d", result.Updated)
+		}
+	})
+
+	t.Run("explicit range", func(t *testing.T) {
+		from := now.Add(-90 * 24 * time.Hour)
+		result, err := svc.Recost(ctx, project.ID, &RecostRequest{From: &from})
+		if err != nil {
+			t.Fatalf("Recost failed: %v", err)
+		}
+		if result.Scanned != 2 || result.Updated != 1 {
+			t.Errorf("expected 2 scanned / 1 updated, got %d / %d", result.Scanned, result.Updated)
+		}
+	})
+
+	t.Run("rejects inverted range", func(t *testing.T) {
+		from, to := now, now.Add(-time.Hour)
+		if _, err := svc.Recost(ctx, project.ID, &RecostRequest{From: &from, To: &to}); err != entity.ErrBadRequest {
+			t.Errorf("expected ErrBadRequest, got %v", err)
+		}
+	})
+}
+
+func TestRecost_PagesThroughSpans(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	pricing := service.NewPricingCalculator()
+	svc := NewService(store, pricing)
+	svc.recostBatchSize = 2
+
+	project := newTestProject(t, store, "recost-pages", entity.ProjectSettings{})
+	tr := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+	if err := store.CreateTrace(ctx, tr); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	// Five spans, three sharing a start time, so pages split on the ID tiebreak.
+	now := time.Now().Truncate(time.Second)
+	starts := []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-2 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)}
+	for _, startedAt := range starts {
+		model, provider := "gpt-4o", "openai"
+		in, out, cost := 1000, 500, 1.0
+		sp := &entity.Span{
+			TraceID:      tr.ID,
+			Type:         entity.SpanTypeLLM,
+			Name:         "chat",
+			Model:        &model,
+			Provider:     &provider,
+			InputTokens:  &in,
+			OutputTokens: &out,
+			CostUSD:      &cost,
+			Status:       entity.SpanStatusSuccess,
+			StartedAt:    startedAt,
+		}
+		if err := store.CreateSpan(ctx, project.ID, sp); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+	}
+
+	result, err := svc.Recost(ctx, project.ID, &RecostRequest{})
+	if err != nil {
+		t.Fatalf("Recost failed: %v", err)
+	}
+	if result.Scanned != len(starts) || result.Updated != int64(len(starts)) {
+		t.Errorf("expected %d scanned / %d updated, got %d / %d", len(starts), len(starts), result.Scanned, result.Updated)
+	}
+
+	got, err := store.GetTrace(ctx, project.ID, tr.ID)
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	expected := pricing.CalculateCost("gpt-4o", 1000, 500)
+	for _, sp := range got.Spans {
+		if sp.CostUSD == nil || math.Abs(*sp.CostUSD-expected) > 1e-9 {
+			t.Errorf("span %s: expected cost %f, got %v", sp.ID, expected, sp.CostUSD)
+		}
+	}
+}
+
+func TestRecost_KeepsExplicitCosts(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	pricing := service.NewPricingCalculator()
+	svc := NewService(store, pricing)
+	ingestSvc := ingest.NewService(store, pricing)
+
+	project := newTestProject(t, store, "override", entity.ProjectSettings{})
+
+	explicit := 0.42
+	in, out := 1000, 500
+	llmEvent := func(traceID string, cost *float64) ingest.IngestEvent {
+		return ingest.IngestEvent{
+			TraceID: traceID, SpanType: "llm", Provider: "openai", Model: "gpt-4o", Status: "success",
+			InputTokens: &in, OutputTokens: &out, CostUSD: cost,
+		}
+	}
+	resp, err := ingestSvc.Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{
+		llmEvent("override-trace", &explicit),
+		llmEvent("computed-trace", nil),
+		{TraceID: "override-trace", SpanType: "tool", Name: "gpu-job", Status: "success", CostUSD: &explicit},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	traceCost := func(traceID string) (float64, []entity.Span) {
+		t.Helper()
+		tr, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		total := 0.0
+		for _, sp := range tr.Spans {
+			if sp.CostUSD != nil {
+				total += *sp.CostUSD
+			}
+		}
+		return total, tr.Spans
+	}
+
+	t.Run("explicit cost is stored instead of the computed one", func(t *testing.T) {
+		total, spans := traceCost("override-trace")
+		for _, sp := range spans {
+			if sp.CostUSD == nil || *sp.CostUSD != explicit {
+				t.Errorf("span %s: expected cost %f, got %v", sp.Name, explicit, sp.CostUSD)
+			}
+			if sp.Metadata[ingest.MetadataCostOverride] != true {
+				t.Errorf("span %s: expected the cost override flag, got %v", sp.Name, sp.Metadata)
+			}
+		}
+		if math.Abs(total-2*explicit) > 1e-9 {
+			t.Errorf("expected trace cost %f, got %f", 2*explicit, total)
+		}
+	})
+
+	t.Run("explicit cost survives recompute", func(t *testing.T) {
+		// Make the computed span stale so recost has something to rewrite
+		_, computed := traceCost("computed-trace")
+		if _, err := store.UpdateSpanCosts(ctx, project.ID, map[string]float64{computed[0].ID: 1.0}); err != nil {
+			t.Fatalf("UpdateSpanCosts failed: %v", err)
+		}
+
+		result, err := svc.Recost(ctx, project.ID, &RecostRequest{})
+		if err != nil {
+			t.Fatalf("Recost failed: %v", err)
+		}
+		if result.Scanned != 1 || result.Updated != 1 {
+			t.Errorf("expected only the computed span recosted, got %d scanned / %d updated", result.Scanned, result.Updated)
+		}
+
+		if total, _ := traceCost("override-trace"); math.Abs(total-2*explicit) > 1e-9 {
+			t.Errorf("expected explicit costs to be kept (%f), got %f", 2*explicit, total)
+		}
+		if total, _ := traceCost("computed-trace"); math.Abs(total-pricing.CalculateCost("gpt-4o", in, out)) > 1e-9 {
+			t.Errorf("expected the computed span repriced, got %f", total)
+		}
+	})
+
+	t.Run("aggregates include explicit costs", func(t *testing.T) {
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{})
+		if err != nil {
+			t.Fatalf("ListTraces failed: %v", err)
+		}
+		found := false
+		for _, tr := range page.Data {
+			if tr.ID != "override-trace" {
+				continue
+			}
+			found = true
+			if math.Abs(tr.TotalCostUSD-2*explicit) > 1e-9 {
+				t.Errorf("expected trace total %f, got %f", 2*explicit, tr.TotalCostUSD)
+			}
+		}
+		if !found {
+			t.Error("override-trace not listed")
+		}
+	})
+}
+
+func TestRecost_RefreshesSubtreeCosts(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	pricing := service.NewPricingCalculator()
+	svc := NewService(store, pricing)
+	ingestSvc := ingest.NewService(store, pricing)
+
+	project := newTestProject(t, store, "subtree", entity.ProjectSettings{})
+
+	in, out := 1000, 500
+	resp, err := ingestSvc.Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{
+		{TraceID: "subtree-trace", SpanID: "subtree-agent", SpanType: "agent", Name: "agent", Status: "success"},
+		{TraceID: "subtree-trace", SpanID: "subtree-llm", ParentSpanID: "subtree-agent", SpanType: "llm",
+			Provider: "openai", Model: "gpt-4o", Status: "success", InputTokens: &in, OutputTokens: &out},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+	// Price the call under an old rate card
+	if _, err := store.UpdateSpanCosts(ctx, project.ID, map[string]float64{"subtree-llm": 1.0}); err != nil {
+		t.Fatalf("UpdateSpanCosts failed: %v", err)
+	}
+
+	if _, err := svc.Recost(ctx, project.ID, &RecostRequest{}); err != nil {
+		t.Fatalf("Recost failed: %v", err)
+	}
+	tr, err := store.GetTrace(ctx, project.ID, "subtree-trace")
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	expected := pricing.CalculateCost("gpt-4o", in, out)
+	for _, sp := range tr.Spans {
+		if sp.ID == "subtree-agent" && (sp.SubtreeCostUSD == nil || math.Abs(*sp.SubtreeCostUSD-expected) > 1e-9) {
+			t.Errorf("expected the agent's subtree cost repriced to %f, got %v", expected, sp.SubtreeCostUSD)
+		}
+	}
+}
+
+func TestIngest_CostDetailsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "details", entity.ProjectSettings{})
+
+	details := map[string]float64{"input": 0.01, "output": 0.03, "cacheRead": 0.002, "audio": 0.005}
+	explicit := 0.05
+	in, out := 1000, 500
+	resp, err := ingest.NewService(store, service.NewPricingCalculator()).Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{
+		{TraceID: "details-trace", SpanID: "details-only", SpanType: "llm", Provider: "openai", Model: "gpt-4o", Status: "success",
+			InputTokens: &in, OutputTokens: &out, CostDetails: details},
+		{TraceID: "details-trace", SpanID: "details-and-cost", SpanType: "tool", Name: "transcribe", Status: "success",
+			CostUSD: &explicit, CostDetails: map[string]float64{"audio": 0.05}},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	tr, err := store.GetTrace(ctx, project.ID, "details-trace")
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	spans := make(map[string]entity.Span)
+	for _, sp := range tr.Spans {
+		spans[sp.ID] = sp
+	}
+
+	t.Run("details are stored in span metadata", func(t *testing.T) {
+		stored, ok := spans["details-only"].Metadata[entity.SpanMetadataCostDetails].(map[string]any)
+		if !ok || len(stored) != len(details) {
+			t.Fatalf("expected cost details in metadata, got %v", spans["details-only"].Metadata)
+		}
+		for class, amount := range details {
+			if stored[class] != amount {
+				t.Errorf("%s: expected %v, got %v", class, amount, stored[class])
+			}
+		}
+	})
+
+	t.Run("details sum to the span cost without costUsd", func(t *testing.T) {
+		sp := spans["details-only"]
+		if sp.CostUSD == nil || !approxEq(*sp.CostUSD, 0.047) {
+			t.Errorf("expected cost 0.047, got %v", sp.CostUSD)
+		}
+		if sp.Metadata[ingest.MetadataCostOverride] != true {
+			t.Errorf("expected the cost override flag, got %v", sp.Metadata)
+		}
+		if sp := spans["details-and-cost"]; sp.CostUSD == nil || *sp.CostUSD != explicit {
+			t.Errorf("expected costUsd to take precedence, got %v", sp.CostUSD)
+		}
+	})
+
+	t.Run("trace detail reports the breakdown", func(t *testing.T) {
+		detail := ProcessTraceDetail(tr)
+		breakdowns := make(map[string]*SpanCostBreakdown)
+		for _, node := range detail.SpanTree {
+			breakdowns[node.Span.ID] = node.Span.CostBreakdown
+		}
+
+		b := breakdowns["details-only"]
+		if b == nil || !b.Reported {
+			t.Fatalf("expected a reported breakdown, got %+v", b)
+		}
+		if !approxEq(b.Input, 0.01) || !approxEq(b.Output, 0.03) || !approxEq(b.CacheRead, 0.002) || !approxEq(b.Total, 0.047) {
+			t.Errorf("unexpected breakdown: %+v", b)
+		}
+		if b := breakdowns["details-and-cost"]; b == nil || !b.Reported || !approxEq(b.Total, 0.05) {
+			t.Errorf("expected a reported breakdown for the tool span, got %+v", b)
+		}
+	})
+}
+
+func TestService_RootCauseError(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "rootcause", entity.ProjectSettings{})
+
+	// agent -> llm -> tool: the tool call fails and the failure propagates up;
+	// the agent's error is the earliest, but the tool is where it began
+	start := time.Now().Add(-time.Minute)
+	at := func(offset time.Duration) *time.Time {
+		ts := start.Add(offset)
+		return &ts
+	}
+	agentErr, llmErr, toolErr := "agent run failed", "tool call failed", "connection refused"
+	resp, err := ingest.NewService(store, service.NewPricingCalculator()).Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{
+		{TraceID: "failed-run", SpanID: "agent", SpanType: "agent", Name: "support-agent", Status: "error", ErrorMessage: agentErr, Timestamp: at(0)},
+		{TraceID: "failed-run", SpanID: "llm", ParentSpanID: "agent", SpanType: "llm", Name: "chat", Status: "error", ErrorMessage: llmErr, Timestamp: at(time.Second)},
+		{TraceID: "failed-run", SpanID: "search", ParentSpanID: "llm", SpanType: "tool", Name: "search", Status: "success", Timestamp: at(2 * time.Second)},
+		{TraceID: "failed-run", SpanID: "fetch", ParentSpanID: "llm", SpanType: "tool", Name: "fetch_order", Status: "error", ErrorMessage: toolErr, Timestamp: at(3 * time.Second)},
+		{TraceID: "ok-run", SpanID: "ok", SpanType: "agent", Name: "support-agent", Status: "success"},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	svc := NewService(store, service.NewPricingCalculator())
+
+	t.Run("points at the nested failing tool span", func(t *testing.T) {
+		tr, err := svc.Get(ctx, project.ID, "failed-run")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		want := entity.RootCauseError{SpanID: "fetch", SpanName: "fetch_order", Message: toolErr}
+		if tr.RootCauseError == nil || *tr.RootCauseError != want {
+			t.Errorf("expected root cause %+v, got %+v", want, tr.RootCauseError)
+		}
+	})
+
+	t.Run("trace detail", func(t *testing.T) {
+		detail, err := svc.GetDetail(ctx, project.ID, "failed-run")
+		if err != nil {
+			t.Fatalf("GetDetail failed: %v", err)
+		}
+		if detail.RootCauseError == nil || detail.RootCauseError.SpanID != "fetch" || detail.RootCauseError.Message != toolErr {
+			t.Errorf("expected the fetch span as root cause, got %+v", detail.RootCauseError)
+		}
+	})
+
+	t.Run("none without errors", func(t *testing.T) {
+		tr, err := svc.Get(ctx, project.ID, "ok-run")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if tr.RootCauseError != nil {
+			t.Errorf("expected no root cause, got %+v", tr.RootCauseError)
+		}
+	})
+}
+
+func TestService_Depth(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "depth", entity.ProjectSettings{})
+
+	// agent -> llm -> tool -> sub-agent llm, with a second tool next to the first
+	resp, err := ingest.NewService(store, service.NewPricingCalculator()).Ingest(ctx, project, &ingest.IngestRequest{Events: []ingest.IngestEvent{
+		{TraceID: "deep-run", SpanID: "deep/agent", SpanType: "agent", Name: "planner"},
+		{TraceID: "deep-run", SpanID: "deep/llm", ParentSpanID: "deep/agent", SpanType: "llm", Name: "plan"},
+		{TraceID: "deep-run", SpanID: "deep/search", ParentSpanID: "deep/llm", SpanType: "tool", Name: "search"},
+		{TraceID: "deep-run", SpanID: "deep/fetch", ParentSpanID: "deep/llm", SpanType: "tool", Name: "fetch"},
+		{TraceID: "deep-run", SpanID: "deep/summarize", ParentSpanID: "deep/search", SpanType: "llm", Name: "summarize"},
+		{TraceID: "flat-run", SpanID: "flat/agent", SpanType: "agent", Name: "planner"},
+		{TraceID: "flat-run", SpanID: "flat/llm", ParentSpanID: "flat/agent", SpanType: "llm", Name: "plan"},
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("ingest failed: %v %+v", err, resp)
+	}
+
+	svc := NewService(store, service.NewPricingCalculator())
+
+	t.Run("span depths and max depth", func(t *testing.T) {
+		tr, err := svc.Get(ctx, project.ID, "deep-run")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if tr.MaxDepth != 4 {
+			t.Errorf("expected MaxDepth 4, got %d", tr.MaxDepth)
+		}
+		want := map[string]int{"deep/agent": 1, "deep/llm": 2, "deep/search": 3, "deep/fetch": 3, "deep/summarize": 4}
+		for _, sp := range tr.Spans {
+			if sp.Depth != want[sp.ID] {
+				t.Errorf("expected span %s at depth %d, got %d", sp.ID, want[sp.ID], sp.Depth)
+			}
+		}
+	})
+
+	t.Run("trace detail", func(t *testing.T) {
+		detail, err := svc.GetDetail(ctx, project.ID, "deep-run")
+		if err != nil {
+			t.Fatalf("GetDetail failed: %v", err)
+		}
+		if detail.MaxDepth != 4 {
+			t.Errorf("expected MaxDepth 4, got %d", detail.MaxDepth)
+		}
+	})
+
+	t.Run("min depth filter", func(t *testing.T) {
+		for minDepth, want := range map[int]int{1: 2, 2: 2, 3: 1, 4: 1, 5: 0} {
+			page, err := svc.List(ctx, project.ID, entity.TraceFilter{MinDepth: &minDepth})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if page.Total != want || len(page.Data) != want {
+				t.Errorf("minDepth %d: expected %d traces, got %d (total %d)", minDepth, want, len(page.Data), page.Total)
+			}
+			if want == 1 && page.Data[0].ID != "deep-run" {
+				t.Errorf("minDepth %d: expected the deep run, got %s", minDepth, page.Data[0].ID)
+			}
+		}
+	})
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	return newTestStoreAt(t, t.TempDir()+"/test.db")
+}
+
+// newTestStoreAt is newTestStore with the database file at path, for setup
+// that has to reach the database directly
+func newTestStoreAt(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
@@ -0,0 +1,365 @@
+package traceimport
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// SourceLangfuse marks imported spans (metadata importedFrom)
+const SourceLangfuse = "langfuse"
+
+// Metadata keys recording Langfuse ids that were replaced on import
+const (
+	MetadataLangfuseID       = "langfuseId"
+	MetadataLangfuseTraceID  = "langfuseTraceId"
+	MetadataLangfuseParentID = "langfuseParentId" // parent observation missing from the export
+)
+
+// idNamespace derives the UUIDs that replace non-UUID Langfuse ids, so
+// importing the same export twice yields the same ids
+var idNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://lelemon.dev/import/langfuse"))
+
+// LangfuseExport is a Langfuse trace export: traces with their observations
+// nested (as returned by the Langfuse trace API), observations listed flat
+// with their traceId, or both
+type LangfuseExport struct {
+	Traces       []LangfuseTrace       `json:"traces"`
+	Observations []LangfuseObservation `json:"observations"`
+}
+
+// LangfuseTrace is a Langfuse trace, reduced to the fields imported
+type LangfuseTrace struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	Timestamp    *time.Time            `json:"timestamp"`
+	SessionID    string                `json:"sessionId"`
+	UserID       string                `json:"userId"`
+	Input        any                   `json:"input"`
+	Output       any                   `json:"output"`
+	Metadata     any                   `json:"metadata"`
+	Tags         []string              `json:"tags"`
+	Observations []LangfuseObservation `json:"observations"`
+}
+
+// LangfuseObservation is a Langfuse observation (span, generation, event or
+// one of the typed spans), reduced to the fields imported
+type LangfuseObservation struct {
+	ID                  string             `json:"id"`
+	TraceID             string             `json:"traceId"`
+	ParentObservationID string             `json:"parentObservationId"`
+	Type                string             `json:"type"` // "SPAN" | "GENERATION" | "EVENT" | "AGENT" | "TOOL" | ...
+	Name                string             `json:"name"`
+	StartTime           *time.Time         `json:"startTime"`
+	EndTime             *time.Time         `json:"endTime"`
+	CompletionStartTime *time.Time         `json:"completionStartTime"`
+	Model               string             `json:"model"`
+	ModelParameters     map[string]any     `json:"modelParameters"`
+	Input               any                `json:"input"`
+	Output              any                `json:"output"`
+	Metadata            any                `json:"metadata"`
+	Level               string             `json:"level"` // "DEBUG" | "DEFAULT" | "WARNING" | "ERROR"
+	StatusMessage       string             `json:"statusMessage"`
+	Usage               *LangfuseUsage     `json:"usage"`
+	UsageDetails        map[string]int     `json:"usageDetails"`
+	CostDetails         map[string]float64 `json:"costDetails"`
+	CalculatedTotalCost *float64           `json:"calculatedTotalCost"`
+	TotalCost           *float64           `json:"totalCost"` // older exports
+}
+
+// LangfuseUsage is a generation's legacy usage object, in either its current
+// (input/output) or OpenAI-style (promptTokens/completionTokens) form
+type LangfuseUsage struct {
+	Input            *int `json:"input"`
+	Output           *int `json:"output"`
+	PromptTokens     *int `json:"promptTokens"`
+	CompletionTokens *int `json:"completionTokens"`
+}
+
+// langfuseSpanTypes maps Langfuse observation types to span types; other
+// types (SPAN, EVENT, CHAIN, EVALUATOR) become custom spans
+var langfuseSpanTypes = map[string]entity.SpanType{
+	"GENERATION": entity.SpanTypeLLM,
+	"AGENT":      entity.SpanTypeAgent,
+	"TOOL":       entity.SpanTypeTool,
+	"RETRIEVER":  entity.SpanTypeRetrieval,
+	"EMBEDDING":  entity.SpanTypeEmbedding,
+	"GUARDRAIL":  entity.SpanTypeGuardrail,
+}
+
+// Usage detail keys read for cache and reasoning tokens, by provider convention
+var (
+	cacheReadKeys  = []string{"cache_read_input_tokens", "input_cache_read", "input_cached_tokens"}
+	cacheWriteKeys = []string{"cache_creation_input_tokens", "input_cache_creation"}
+	reasoningKeys  = []string{"output_reasoning_tokens", "reasoning_tokens"}
+)
+
+// source is the trace and observation an ingest event was converted from
+type source struct {
+	traceID, observationID string
+}
+
+// langfuseTrace is a trace of the export with all its observations
+type langfuseTrace struct {
+	trace        LangfuseTrace
+	observations []LangfuseObservation
+}
+
+// ConvertLangfuse converts an export to ingest events, one per observation,
+// with the trace and observation each came from. Observations are ordered by
+// start time within their trace; a trace without observations becomes a
+// single custom span. UUID ids are kept, other ids are replaced by UUIDs
+// derived from them (the original is kept in metadata), and parent links to
+// observations missing from the export are dropped.
+func ConvertLangfuse(export *LangfuseExport) ([]ingest.IngestEvent, []source) {
+	traces := groupLangfuseTraces(export)
+
+	var events []ingest.IngestEvent
+	var sources []source
+	for _, t := range traces {
+		traceID, traceRemapped := importID("trace", t.trace.ID)
+
+		observationIDs := make(map[string]bool, len(t.observations))
+		for _, o := range t.observations {
+			observationIDs[o.ID] = true
+		}
+
+		first := len(events)
+		for _, o := range t.observations {
+			events = append(events, convertObservation(o, traceID, observationIDs))
+			sources = append(sources, source{traceID: t.trace.ID, observationID: o.ID})
+		}
+		if len(t.observations) == 0 {
+			spanID, _ := importID("trace-span", t.trace.ID)
+			events = append(events, ingest.IngestEvent{
+				SpanType:  string(entity.SpanTypeCustom),
+				Name:      t.trace.Name,
+				Input:     t.trace.Input,
+				Output:    t.trace.Output,
+				Status:    "success",
+				TraceID:   traceID,
+				SpanID:    spanID,
+				Timestamp: t.trace.Timestamp,
+				Metadata:  map[string]any{"importedFrom": SourceLangfuse},
+			})
+			sources = append(sources, source{traceID: t.trace.ID})
+		}
+
+		// Trace-level fields: every span carries the session, user and tags;
+		// the trace name and metadata travel on the first (see buildTrace)
+		for i := first; i < len(events); i++ {
+			events[i].SessionID = t.trace.SessionID
+			events[i].UserID = t.trace.UserID
+			events[i].Tags = t.trace.Tags
+		}
+		metadata := events[first].Metadata
+		mergeMetadata(metadata, t.trace.Metadata)
+		if t.trace.Name != "" {
+			metadata["_traceName"] = t.trace.Name
+		}
+		if traceRemapped {
+			metadata[MetadataLangfuseTraceID] = t.trace.ID
+		}
+	}
+	return events, sources
+}
+
+// groupLangfuseTraces returns the export's traces in order, each with its
+// nested and flat-listed observations sorted by start time
+func groupLangfuseTraces(export *LangfuseExport) []*langfuseTrace {
+	var traces []*langfuseTrace
+	byID := make(map[string]*langfuseTrace)
+	traceFor := func(id string) *langfuseTrace {
+		t := byID[id]
+		if t == nil {
+			t = &langfuseTrace{trace: LangfuseTrace{ID: id}}
+			byID[id] = t
+			traces = append(traces, t)
+		}
+		return t
+	}
+
+	for _, trace := range export.Traces {
+		t := traceFor(trace.ID)
+		t.trace = trace
+		for _, o := range trace.Observations {
+			o.TraceID = trace.ID
+			t.observations = append(t.observations, o)
+		}
+	}
+	for _, o := range export.Observations {
+		t := traceFor(o.TraceID)
+		t.observations = append(t.observations, o)
+	}
+
+	for _, t := range traces {
+		sort.SliceStable(t.observations, func(i, j int) bool {
+			a, b := t.observations[i].StartTime, t.observations[j].StartTime
+			if a == nil || b == nil {
+				return a != nil // untimed observations go last
+			}
+			return a.Before(*b)
+		})
+	}
+	return traces
+}
+
+// convertObservation converts one observation into an event of traceID;
+// observationIDs holds the original ids of the trace's observations
+func convertObservation(o LangfuseObservation, traceID string, observationIDs map[string]bool) ingest.IngestEvent {
+	spanType, ok := langfuseSpanTypes[o.Type]
+	if !ok {
+		spanType = entity.SpanTypeCustom
+	}
+
+	metadata := map[string]any{"importedFrom": SourceLangfuse}
+	mergeMetadata(metadata, o.Metadata)
+	if len(o.ModelParameters) > 0 {
+		metadata["modelParameters"] = o.ModelParameters
+	}
+	if o.Level != "" && o.Level != "DEFAULT" {
+		metadata["level"] = o.Level
+	}
+
+	spanID, remapped := importID("observation", o.ID)
+	if remapped {
+		metadata[MetadataLangfuseID] = o.ID
+	}
+
+	event := ingest.IngestEvent{
+		SpanType:  string(spanType),
+		Model:     o.Model,
+		Name:      o.Name,
+		Input:     o.Input,
+		Output:    o.Output,
+		Status:    "success",
+		TraceID:   traceID,
+		SpanID:    spanID,
+		Metadata:  metadata,
+		Timestamp: o.StartTime,
+	}
+
+	switch {
+	case o.ParentObservationID == "":
+	case observationIDs[o.ParentObservationID]:
+		event.ParentSpanID, _ = importID("observation", o.ParentObservationID)
+	default:
+		metadata[MetadataLangfuseParentID] = o.ParentObservationID
+	}
+
+	if o.Level == "ERROR" {
+		event.Status = "error"
+		event.ErrorMessage = o.StatusMessage
+	} else if o.StatusMessage != "" {
+		metadata["statusMessage"] = o.StatusMessage
+	}
+
+	if o.StartTime != nil && o.EndTime != nil {
+		event.DurationMs = millisBetween(*o.StartTime, *o.EndTime)
+	}
+	if o.StartTime != nil && o.CompletionStartTime != nil {
+		event.FirstTokenMs = millisBetween(*o.StartTime, *o.CompletionStartTime)
+	}
+
+	setLangfuseUsage(&event, o)
+	setLangfuseCost(&event, o)
+	return event
+}
+
+// setLangfuseUsage sets the event's token counts from the observation's usage
+// details, falling back to its legacy usage object
+func setLangfuseUsage(event *ingest.IngestEvent, o LangfuseObservation) {
+	var usage LangfuseUsage
+	if o.Usage != nil {
+		usage = *o.Usage
+	}
+	event.InputTokens = firstCount(o.UsageDetails, []string{"input"}, usage.Input, usage.PromptTokens)
+	event.OutputTokens = firstCount(o.UsageDetails, []string{"output"}, usage.Output, usage.CompletionTokens)
+	event.CacheReadTokens = firstCount(o.UsageDetails, cacheReadKeys)
+	event.CacheWriteTokens = firstCount(o.UsageDetails, cacheWriteKeys)
+	event.ReasoningTokens = firstCount(o.UsageDetails, reasoningKeys)
+}
+
+// setLangfuseCost keeps the cost Langfuse computed, so imported spans cost
+// what they cost at the time rather than at current prices. Without one the
+// cost is computed on ingest as usual.
+func setLangfuseCost(event *ingest.IngestEvent, o LangfuseObservation) {
+	for class, cost := range o.CostDetails {
+		if class == "total" {
+			continue
+		}
+		if event.CostDetails == nil {
+			event.CostDetails = make(map[string]float64, len(o.CostDetails))
+		}
+		event.CostDetails[class] = cost
+	}
+
+	switch total, ok := o.CostDetails["total"]; {
+	case ok:
+		event.CostUSD = &total
+	case o.CalculatedTotalCost != nil:
+		event.CostUSD = o.CalculatedTotalCost
+	case o.TotalCost != nil:
+		event.CostUSD = o.TotalCost
+	}
+}
+
+// importID returns the id to store for a Langfuse id of kind, and whether it
+// was replaced: UUIDs are kept, other ids (including empty ones, which get a
+// random UUID) are not valid trace or span ids in every store
+func importID(kind, id string) (string, bool) {
+	if id == "" {
+		return uuid.New().String(), false
+	}
+	if _, err := uuid.Parse(id); err == nil {
+		return id, false
+	}
+	return uuid.NewSHA1(idNamespace, []byte(kind+":"+id)).String(), true
+}
+
+// mergeMetadata copies Langfuse metadata into dst: an object's keys, or any
+// other value under "langfuseMetadata"
+func mergeMetadata(dst map[string]any, metadata any) {
+	switch m := metadata.(type) {
+	case nil:
+	case map[string]any:
+		for k, v := range m {
+			dst[k] = v
+		}
+	default:
+		dst["langfuseMetadata"] = m
+	}
+}
+
+// firstCount returns the first of details' keys present, else the first
+// non-nil fallback
+func firstCount(details map[string]int, keys []string, fallbacks ...*int) *int {
+	for _, key := range keys {
+		if n, ok := details[key]; ok {
+			return &n
+		}
+	}
+	for _, n := range fallbacks {
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func millisBetween(start, end time.Time) *int {
+	ms := int(end.Sub(start).Milliseconds())
+	if ms < 0 {
+		return nil
+	}
+	return &ms
+}
+
+// countTraces counts the distinct traces of an export
+func countTraces(export *LangfuseExport) int {
+	return len(groupLangfuseTraces(export))
+}
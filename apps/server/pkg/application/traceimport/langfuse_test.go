@@ -0,0 +1,183 @@
+package traceimport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+)
+
+const (
+	sampleTraceID = "7f4c1e2a-3b5d-4c6e-8f90-1a2b3c4d5e6f"
+	pipelineID    = "0a1b2c3d-0000-4000-8000-000000000001"
+)
+
+func loadSample(t *testing.T) *LangfuseExport {
+	t.Helper()
+	data, err := os.ReadFile("testdata/langfuse_export.json")
+	if err != nil {
+		t.Fatalf("failed to read sample export: %v", err)
+	}
+	var export LangfuseExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("failed to decode sample export: %v", err)
+	}
+	return &export
+}
+
+func TestConvertLangfuse(t *testing.T) {
+	events, sources := ConvertLangfuse(loadSample(t))
+	if len(events) != 5 || len(sources) != 5 {
+		t.Fatalf("expected 5 events, got %d (%d sources)", len(events), len(sources))
+	}
+	byName := make(map[string]ingest.IngestEvent, len(events))
+	for _, e := range events {
+		byName[e.Name] = e
+	}
+
+	t.Run("ordered by start time with trace fields on the first", func(t *testing.T) {
+		var names []string
+		for _, e := range events {
+			names = append(names, e.Name)
+		}
+		if want := "[rag-pipeline cache-miss vector-search answer chat]"; fmt.Sprint(names) != want {
+			t.Errorf("expected order %s, got %v", want, names)
+		}
+		root := events[0]
+		if root.Metadata["_traceName"] != "rag-query" || root.Metadata["env"] != "production" || root.Metadata["pipeline"] != "v2" {
+			t.Errorf("expected trace name and metadata on the first event, got %v", root.Metadata)
+		}
+		for _, e := range events[:4] {
+			if e.TraceID != sampleTraceID || e.SessionID != "session-1" || e.UserID != "user-42" || len(e.Tags) != 2 {
+				t.Errorf("%s: expected trace fields, got %+v", e.Name, e)
+			}
+		}
+	})
+
+	t.Run("span types", func(t *testing.T) {
+		want := map[string]string{"rag-pipeline": "custom", "cache-miss": "custom", "vector-search": "retrieval", "answer": "llm", "chat": "llm"}
+		for name, spanType := range want {
+			if got := byName[name].SpanType; got != spanType {
+				t.Errorf("%s: expected spanType %s, got %s", name, spanType, got)
+			}
+		}
+	})
+
+	t.Run("generation usage, cost and timing", func(t *testing.T) {
+		answer := byName["answer"]
+		if *answer.InputTokens != 820 || *answer.OutputTokens != 45 || *answer.CacheReadTokens != 512 {
+			t.Errorf("expected usage 820/45/512, got %d/%d/%d", *answer.InputTokens, *answer.OutputTokens, *answer.CacheReadTokens)
+		}
+		if answer.CostUSD == nil || *answer.CostUSD != 0.0025 || len(answer.CostDetails) != 2 {
+			t.Errorf("expected Langfuse cost kept, got %v %v", answer.CostUSD, answer.CostDetails)
+		}
+		if *answer.DurationMs != 1500 || *answer.FirstTokenMs != 250 {
+			t.Errorf("expected 1500ms duration and 250ms first token, got %d %d", *answer.DurationMs, *answer.FirstTokenMs)
+		}
+		if answer.Metadata["modelParameters"] == nil {
+			t.Errorf("expected model parameters in metadata, got %v", answer.Metadata)
+		}
+
+		chat := byName["chat"]
+		if *chat.InputTokens != 120 || *chat.OutputTokens != 0 || *chat.CostUSD != 0.00036 {
+			t.Errorf("expected legacy usage and calculated cost, got %+v", chat)
+		}
+		if chat.Status != "error" || chat.ErrorMessage != "overloaded_error" {
+			t.Errorf("expected ERROR level as error status, got %s %q", chat.Status, chat.ErrorMessage)
+		}
+		if cacheMiss := byName["cache-miss"]; cacheMiss.Status != "success" || cacheMiss.Metadata["level"] != "WARNING" || cacheMiss.Metadata["statusMessage"] != "semantic cache miss" {
+			t.Errorf("expected WARNING kept in metadata, got %+v", cacheMiss)
+		}
+	})
+
+	t.Run("ids", func(t *testing.T) {
+		pipeline := byName["rag-pipeline"]
+		if pipeline.SpanID != pipelineID || pipeline.ParentSpanID != "" {
+			t.Errorf("expected UUID id kept as a root, got %q parent %q", pipeline.SpanID, pipeline.ParentSpanID)
+		}
+
+		answer := byName["answer"]
+		if answer.SpanID == "gen-answer" || answer.Metadata[MetadataLangfuseID] != "gen-answer" || answer.ParentSpanID != pipelineID {
+			t.Errorf("expected non-UUID id replaced under its parent, got %q %v parent %q", answer.SpanID, answer.Metadata, answer.ParentSpanID)
+		}
+		again, _ := ConvertLangfuse(loadSample(t))
+		if again[3].SpanID != answer.SpanID || again[4].TraceID != byName["chat"].TraceID {
+			t.Errorf("expected replaced ids to be stable across imports")
+		}
+
+		chat := byName["chat"]
+		if chat.Metadata[MetadataLangfuseTraceID] != "chat-42" || chat.ParentSpanID != "" || chat.Metadata[MetadataLangfuseParentID] != "not-exported" {
+			t.Errorf("expected missing parent dropped and ids recorded, got %v parent %q", chat.Metadata, chat.ParentSpanID)
+		}
+		if chat.Metadata["langfuseMetadata"] != "retry" {
+			t.Errorf("expected non-object metadata kept, got %v", chat.Metadata)
+		}
+		if sources[4] != (source{traceID: "chat-42", observationID: "retry-1"}) {
+			t.Errorf("expected source of the flat observation, got %+v", sources[4])
+		}
+	})
+}
+
+func TestImportLangfuse(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	project := newTestProject(t, store, "import", entity.ProjectSettings{})
+
+	svc := NewService(ingest.NewService(store, service.NewPricingCalculator()))
+	resp, err := svc.ImportLangfuse(ctx, project, loadSample(t))
+	if err != nil || !resp.Success || resp.Traces != 2 || resp.Spans != 5 {
+		t.Fatalf("import failed: %v %+v", err, resp)
+	}
+
+	trace, err := store.GetTrace(ctx, project.ID, sampleTraceID)
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if trace.Name == nil || *trace.Name != "rag-query" || trace.SessionID == nil || *trace.SessionID != "session-1" {
+		t.Errorf("expected trace name and session from the export, got %+v", trace.Trace)
+	}
+	if len(trace.Spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(trace.Spans))
+	}
+	for _, span := range trace.Spans {
+		if span.Name == "answer" {
+			if span.ParentSpanID == nil || *span.ParentSpanID != pipelineID || span.Model == nil || *span.Model != "gpt-4o" {
+				t.Errorf("expected generation under the pipeline span, got %+v", span)
+			}
+			if span.CostUSD == nil || *span.CostUSD != 0.0025 {
+				t.Errorf("expected imported cost 0.0025, got %v", span.CostUSD)
+			}
+		}
+	}
+}
+
+// newTestStore returns a migrated SQLite store in a temporary directory,
+// closed when the test ends
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *sqlite.Store, name string, settings entity.ProjectSettings) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com", Settings: settings}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
+}
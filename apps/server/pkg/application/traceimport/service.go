@@ -0,0 +1,64 @@
+package traceimport
+
+import (
+	"context"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// Service imports traces exported from other observability tools. Exports are
+// converted to ingest events and recorded through the ingest service, so
+// imported spans get the same parsing, pricing and redaction as SDK events.
+type Service struct {
+	ingest *ingest.Service
+}
+
+// NewService creates a trace importer recording through ingestSvc
+func NewService(ingestSvc *ingest.Service) *Service {
+	return &Service{ingest: ingestSvc}
+}
+
+// ImportResponse is the response payload for the trace import endpoint
+type ImportResponse struct {
+	Success bool          `json:"success"`
+	Traces  int           `json:"traces"` // traces in the export
+	Spans   int           `json:"spans"`  // spans accepted for ingestion
+	Errors  []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError reports an observation (or trace, when ObservationID is empty)
+// that was not imported
+type ImportError struct {
+	TraceID       string `json:"traceId"`
+	ObservationID string `json:"observationId,omitempty"`
+	Message       string `json:"message"`
+}
+
+// ImportLangfuse imports a Langfuse trace export into project
+func (s *Service) ImportLangfuse(ctx context.Context, project *entity.Project, export *LangfuseExport) (*ImportResponse, error) {
+	events, sources := ConvertLangfuse(export)
+
+	resp, err := s.ingest.Ingest(ctx, project, &ingest.IngestRequest{Events: events})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResponse{
+		Success: resp.Success,
+		Traces:  countTraces(export),
+		Spans:   resp.Processed,
+	}
+	for _, e := range resp.Errors {
+		var src source
+		if e.Index < len(sources) {
+			src = sources[e.Index]
+		}
+		result.Errors = append(result.Errors, ImportError{
+			TraceID:       src.traceID,
+			ObservationID: src.observationID,
+			Message:       e.Message,
+		})
+	}
+	return result, nil
+}
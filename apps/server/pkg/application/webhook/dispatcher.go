@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// DefaultQueueSize bounds the events waiting for delivery; events dispatched
+// while the queue is full are dropped
+const DefaultQueueSize = 1000
+
+// Event is the webhook payload of a trace event
+type Event struct {
+	Text       string    `json:"text"`
+	Event      string    `json:"event"` // entity.WebhookEventTraceCompleted and the others
+	ProjectID  string    `json:"projectId"`
+	TraceID    string    `json:"traceId"`
+	Status     string    `json:"status,omitempty"` // the trace's status
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// Subscription is where a project's trace events go and which it wants
+type Subscription struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+}
+
+// SubscriptionFor returns the project's event subscription, or nil when it
+// has no webhook URL or subscribes to no events
+func SubscriptionFor(settings entity.ProjectSettings) *Subscription {
+	if settings.WebhookURL == nil || *settings.WebhookURL == "" || len(settings.WebhookEvents) == 0 {
+		return nil
+	}
+	return &Subscription{URL: *settings.WebhookURL, Secret: settings.WebhookSecret, Events: settings.WebhookEvents}
+}
+
+// Wants reports whether the subscription includes the event type
+func (s *Subscription) Wants(event string) bool {
+	return s != nil && slices.Contains(s.Events, event)
+}
+
+type delivery struct {
+	sub   *Subscription
+	event Event
+}
+
+// Dispatcher delivers trace events in the background, so ingest never waits
+// on a webhook. Deliveries are attempted once: a failure is logged, and
+// events still queued when the dispatcher stops are lost.
+type Dispatcher struct {
+	sender *Sender
+	queue  chan delivery
+}
+
+// NewDispatcher creates a dispatcher queueing up to queueSize events
+// (DefaultQueueSize when <= 0)
+func NewDispatcher(sender *Sender, queueSize int) *Dispatcher {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Dispatcher{sender: sender, queue: make(chan delivery, queueSize)}
+}
+
+// Start delivers queued events in the background until ctx is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case dl := <-d.queue:
+				if err := d.sender.Send(ctx, dl.sub.URL, dl.sub.Secret, &dl.event); err != nil {
+					slog.Warn("webhook delivery failed", "projectID", dl.event.ProjectID, "event", dl.event.Event, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Dispatch queues event for delivery if sub subscribes to its type.
+// It never blocks: when the queue is full the event is dropped.
+func (d *Dispatcher) Dispatch(sub *Subscription, event Event) {
+	if !sub.Wants(event.Event) {
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	select {
+	case d.queue <- delivery{sub: sub, event: event}:
+	default:
+		slog.Warn("webhook queue full, event dropped", "projectID", event.ProjectID, "event", event.Event)
+	}
+}
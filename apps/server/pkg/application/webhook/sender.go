@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body, as
+// sha256=<hex>, when the project has a webhook secret
+const SignatureHeader = "X-Lelemon-Signature"
+
+const sendTimeout = 10 * time.Second
+
+// ErrTargetNotAllowed is returned for webhook URLs that are not http(s), or
+// that resolve to a loopback, private (RFC 1918, fc00::/7), shared (CGNAT
+// 100.64.0.0/10), link-local or unspecified address
+var ErrTargetNotAllowed = errors.New("webhook target not allowed")
+
+// Sender posts JSON payloads to project webhooks
+type Sender struct {
+	client *http.Client
+}
+
+// SenderOptions tunes a Sender
+type SenderOptions struct {
+	// AllowLoopback lets webhooks reach loopback addresses, for tests
+	// against a local receiver. Link-local targets (cloud metadata
+	// endpoints) are refused regardless.
+	AllowLoopback bool
+}
+
+// NewSender creates a webhook sender that refuses internal targets (see
+// ErrTargetNotAllowed)
+func NewSender() *Sender {
+	return NewSenderWithOptions(SenderOptions{})
+}
+
+// NewSenderWithOptions creates a webhook sender with the given options
+func NewSenderWithOptions(opts SenderOptions) *Sender {
+	// The address is checked once resolved, at dial time, so neither a DNS
+	// name pointing inward nor a redirect gets around it
+	dialer := &net.Dialer{
+		Timeout: sendTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !allowedIP(ip, opts.AllowLoopback) {
+				return fmt.Errorf("%w: %s", ErrTargetNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	// No proxy: it would dial the target itself, skipping the check above
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Sender{client: &http.Client{Timeout: sendTimeout, Transport: transport}}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal to
+// many cloud networks
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func allowedIP(ip net.IP, allowLoopback bool) bool {
+	if ip.IsLoopback() {
+		return allowLoopback
+	}
+	return !ip.IsPrivate() && !sharedAddressSpace.Contains(ip) &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
+}
+
+// Send posts payload as JSON to target, signed with secret unless it is empty.
+// Only http(s) targets are sent to. Any status outside 2xx is an error.
+func (s *Sender) Send(ctx context.Context, target, secret string, payload any) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrTargetNotAllowed, u.Scheme)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of body: receivers recompute it
+// with the shared secret and compare in constant time
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
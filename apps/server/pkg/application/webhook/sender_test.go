@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSender_RestrictsTargets(t *testing.T) {
+	ctx := context.Background()
+	var received int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer receiver.Close()
+	payload := map[string]string{"event": "test"}
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"non-http scheme", "file:///etc/passwd"},
+		{"loopback", receiver.URL},
+		{"loopback by name", strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)},
+		{"link-local metadata endpoint", "http://169.254.169.254/latest/meta-data"},
+		{"unspecified", "http://0.0.0.0:1/"},
+		{"private IPv4", "http://10.0.0.1:1/"},
+		{"private IPv6", "http://[fd00::1]:1/"},
+		{"shared address space", "http://100.64.0.1:1/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewSender().Send(ctx, tt.target, "", payload); !errors.Is(err, ErrTargetNotAllowed) {
+				t.Errorf("expected ErrTargetNotAllowed, got %v", err)
+			}
+		})
+	}
+	if received != 0 {
+		t.Fatalf("expected no deliveries, got %d", received)
+	}
+
+	t.Run("loopback when allowed", func(t *testing.T) {
+		sender := NewSenderWithOptions(SenderOptions{AllowLoopback: true})
+		if err := sender.Send(ctx, receiver.URL, "", payload); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if received != 1 {
+			t.Errorf("expected 1 delivery, got %d", received)
+		}
+	})
+}
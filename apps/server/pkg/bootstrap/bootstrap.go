@@ -0,0 +1,410 @@
+// Package bootstrap wires the core server's stores, services and background
+// workers from config. The community and enterprise binaries both build on it,
+// so a setting wired here works in either.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/alert"
+	"github.com/lelemon/server/pkg/application/analytics"
+	appauth "github.com/lelemon/server/pkg/application/auth"
+	"github.com/lelemon/server/pkg/application/export"
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/application/project"
+	"github.com/lelemon/server/pkg/application/proxy"
+	"github.com/lelemon/server/pkg/application/trace"
+	"github.com/lelemon/server/pkg/application/webhook"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/auth"
+	"github.com/lelemon/server/pkg/infrastructure/config"
+	"github.com/lelemon/server/pkg/infrastructure/objectstore"
+	"github.com/lelemon/server/pkg/infrastructure/store"
+	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/handler"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// ingestDrainTimeout bounds how long Stop waits for queued ingest jobs
+const ingestDrainTimeout = 10 * time.Second
+
+// Core holds the core server's stores and services. Build it with New, start
+// its background workers with Start, and shut it down with Stop then Close.
+type Core struct {
+	PrimaryStore   repository.Store // users, projects (API key auth)
+	AnalyticsStore repository.Store // traces, spans; PrimaryStore unless configured apart
+
+	IngestSvc    *ingest.Service
+	TraceSvc     *trace.Service
+	AnalyticsSvc *analytics.Service
+	ProjectSvc   *project.Service
+	AuthSvc      *appauth.Service
+	JWTService   *auth.JWTService
+	KeyUsage     *middleware.APIKeyUsageTracker
+	ExportSvc    *export.Service // nil unless EXPORT_S3_BUCKET is set
+	ProxySvc     *proxy.Service  // nil unless OPENAI_PROXY_ENABLED is set
+
+	cfg         *config.Config
+	log         *slog.Logger
+	stopWorkers context.CancelFunc
+}
+
+// New opens and migrates the stores cfg selects and builds the services on
+// them. Background workers and the ingest WAL wait for Start.
+func New(ctx context.Context, cfg *config.Config, log *slog.Logger) (_ *Core, err error) {
+	c := &Core{cfg: cfg, log: log, stopWorkers: func() {}}
+	defer func() {
+		if err != nil {
+			c.closeStores()
+		}
+	}()
+
+	if err := c.openStores(ctx); err != nil {
+		return nil, err
+	}
+
+	// Initialize auth services
+	c.JWTService = auth.NewJWTService(cfg.JWTSecret, cfg.JWTExpiration)
+	oauthService := auth.NewOAuthService(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL)
+
+	// Initialize application services
+	// - Services that handle traces/spans/analytics use AnalyticsStore
+	// - Services that handle users/projects use PrimaryStore
+	pricing := service.NewPricingCalculator()
+	service.SetModelAliases(cfg.PricingModelAliases)
+	service.SetFineTunedMultiplier(cfg.PricingFineTuneFactor)
+
+	c.IngestSvc = ingest.NewAsyncService(c.AnalyticsStore, pricing, 1000, cfg.IngestWorkers)
+	if cfg.IngestMaxWorkers > cfg.IngestWorkers {
+		c.IngestSvc.SetWorkerAutoscale(cfg.IngestMaxWorkers, cfg.IngestScaleUpQueueDepth, cfg.IngestWorkerIdleTimeout)
+		log.Info("ingest worker autoscaling enabled", "max_workers", cfg.IngestMaxWorkers, "queue_depth", cfg.IngestScaleUpQueueDepth)
+	}
+	c.IngestSvc.SetProjectStore(c.PrimaryStore)
+	c.IngestSvc.SetMaxClockSkew(cfg.IngestMaxClockSkew, cfg.IngestClampTimestamps)
+	c.IngestSvc.SetMaxJSONDepth(cfg.IngestMaxJSONDepth, cfg.IngestTruncateDeepJSON)
+	if err := c.IngestSvc.SetIDValidation(ingest.IDValidation{
+		MaxLength: cfg.IngestIDMaxLength,
+		Charset:   cfg.IngestIDCharset,
+		Format:    ingest.IDFormat(cfg.IngestIDFormat),
+		Strict:    cfg.IngestIDStrict,
+	}); err != nil {
+		c.IngestSvc.Stop(ingestDrainTimeout)
+		return nil, fmt.Errorf("invalid ingest ID validation: %w", err)
+	}
+	c.TraceSvc = trace.NewService(c.AnalyticsStore, pricing)
+	c.TraceSvc.SetMaxSpans(cfg.TraceMaxSpans)
+	c.TraceSvc.SetProjectStore(c.PrimaryStore)
+	c.AnalyticsSvc = analytics.NewService(c.AnalyticsStore)
+	c.AnalyticsSvc.SetProjectStore(c.PrimaryStore)
+	c.AnalyticsSvc.SetRateSource(analytics.StaticRates(cfg.CurrencyRates))
+	c.AnalyticsSvc.SetMaxPoints(cfg.AnalyticsMaxPoints)
+	c.ProjectSvc = project.NewService(c.PrimaryStore)
+	c.ProjectSvc.SetTraceStore(c.AnalyticsStore)
+	c.AuthSvc = appauth.NewService(c.PrimaryStore, c.JWTService, oauthService)
+
+	// Trace exports to S3 (routes are mounted only when a bucket is configured)
+	if cfg.ExportS3Bucket != "" {
+		objects, err := objectstore.NewS3(ctx, objectstore.S3Config{
+			Bucket:          cfg.ExportS3Bucket,
+			Region:          cfg.ExportS3Region,
+			Endpoint:        cfg.ExportS3Endpoint,
+			AccessKeyID:     cfg.ExportS3AccessKeyID,
+			SecretAccessKey: cfg.ExportS3SecretAccessKey,
+		})
+		if err != nil {
+			c.IngestSvc.Stop(ingestDrainTimeout)
+			return nil, fmt.Errorf("failed to initialize export store: %w", err)
+		}
+		c.ExportSvc = export.NewService(c.AnalyticsStore, objects, cfg.ExportS3Prefix)
+		log.Info("trace exports enabled", "bucket", cfg.ExportS3Bucket)
+	}
+
+	// OpenAI-compatible proxy (zero-code instrumentation)
+	if cfg.OpenAIProxyEnabled {
+		c.ProxySvc = proxy.NewService(c.IngestSvc, cfg.OpenAIProxyUpstream)
+		c.ProxySvc.SetHeaderAllowlists(cfg.OpenAIProxyReqHeaders, cfg.OpenAIProxyRespHeaders)
+		log.Info("openai proxy enabled", "upstream", cfg.OpenAIProxyUpstream)
+	}
+
+	// API key last-seen tracking (buffered; flushed at most once a minute per key)
+	c.KeyUsage = middleware.NewAPIKeyUsageTracker(c.PrimaryStore, middleware.DefaultKeyUsageFlushInterval)
+
+	return c, nil
+}
+
+// openStores opens the primary store (with its read replica) and the
+// analytics store (separate, sharded or regional), and migrates them
+func (c *Core) openStores(ctx context.Context) error {
+	cfg, log := c.cfg, c.log
+
+	// Server-side query limits (Postgres statement_timeout / ClickHouse max_execution_time)
+	// and SQLite connection tuning
+	storeOpts := store.Options{
+		StatementTimeout: cfg.DBStatementTimeout,
+		SQLite: sqlite.Options{
+			BusyTimeout:        cfg.SQLiteBusyTimeout,
+			JournalMode:        cfg.SQLiteJournalMode,
+			ReadConns:          cfg.SQLiteReadConns,
+			CheckpointInterval: cfg.SQLiteCheckpointInterval,
+		},
+	}
+
+	// Initialize primary store (users, projects), with its read replica
+	primaryOpts := storeOpts
+	primaryOpts.PostgresReadReplicaURL = cfg.ReadDatabaseURL
+	primaryStore, err := store.NewWithOptions(cfg.DatabaseURL, primaryOpts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize primary store: %w", err)
+	}
+	c.PrimaryStore = primaryStore
+	c.AnalyticsStore = primaryStore
+	if cfg.ReadDatabaseURL != "" {
+		if store.Backend(cfg.DatabaseURL) == "postgres" {
+			log.Info("using postgres read replica")
+		} else {
+			log.Warn("READ_DATABASE_URL ignored: only postgres supports read replicas")
+		}
+	}
+
+	// Initialize analytics store (traces, spans) - defaults to primary
+	if len(cfg.AnalyticsShardURLs) > 0 {
+		analyticsStore, err := store.NewShardedFromURLs(cfg.AnalyticsShardURLs, storeOpts)
+		if err != nil {
+			return fmt.Errorf("failed to initialize sharded analytics store: %w", err)
+		}
+		c.AnalyticsStore = analyticsStore
+		log.Info("using sharded analytics store", "shards", len(cfg.AnalyticsShardURLs))
+	} else if cfg.AnalyticsDatabaseURL != "" {
+		analyticsStore, err := store.NewWithOptions(cfg.AnalyticsDatabaseURL, storeOpts)
+		if err != nil {
+			return fmt.Errorf("failed to initialize analytics store: %w", err)
+		}
+		c.AnalyticsStore = analyticsStore
+		log.Info("using separate analytics store")
+	}
+
+	// Data residency: projects with a region setting keep their data in that
+	// region's store; the rest use the analytics store above
+	if len(cfg.AnalyticsRegionURLs) > 0 {
+		regional, err := store.NewRegionalFromURLs(c.PrimaryStore, c.AnalyticsStore, cfg.AnalyticsRegionURLs, storeOpts)
+		if err != nil {
+			return fmt.Errorf("failed to initialize regional analytics stores: %w", err)
+		}
+		c.AnalyticsStore = regional
+		log.Info("using regional analytics stores", "regions", len(cfg.AnalyticsRegionURLs))
+	}
+
+	// Run migrations
+	if err := c.PrimaryStore.Migrate(ctx); err != nil {
+		return fmt.Errorf("failed to run primary migrations: %w", err)
+	}
+	if c.AnalyticsStore != c.PrimaryStore {
+		if err := c.AnalyticsStore.Migrate(ctx); err != nil {
+			return fmt.Errorf("failed to run analytics migrations: %w", err)
+		}
+	}
+	log.Info("database migrations completed")
+	return nil
+}
+
+// Start runs the background workers: pricing auto-sync, error-rate alerts,
+// span content retention, trace event webhooks, the stale trace reaper and
+// the span tool backfill. It then opens the ingest WAL, replaying the jobs
+// left in it, so callers set their ingest hooks (e.g. a usage recorder)
+// before Start. Stop ends the workers.
+func (c *Core) Start(ctx context.Context) error {
+	cfg, log := c.cfg, c.log
+	ctx, c.stopWorkers = context.WithCancel(ctx)
+
+	// Auto-sync model pricing from external sources in the background.
+	// Non-blocking and offline-safe: the built-in table stays in effect
+	// until/unless a refresh succeeds. Precedence: LiteLLM > OpenRouter > local.
+	//   PRICING_AUTOSYNC=false       disable all external sources
+	//   PRICING_SOURCE_URL=...       override the LiteLLM (primary) URL
+	//   PRICING_OPENROUTER=false     disable the OpenRouter (secondary) source
+	//   PRICING_OPENROUTER_URL=...   override the OpenRouter URL
+	if os.Getenv("PRICING_AUTOSYNC") != "false" {
+		sources := service.PricingSources{
+			LiteLLMURL: envOr("PRICING_SOURCE_URL", service.DefaultLiteLLMPricingURL),
+			Interval:   service.DefaultPricingRefreshInterval,
+		}
+		if os.Getenv("PRICING_OPENROUTER") != "false" {
+			sources.OpenRouterURL = envOr("PRICING_OPENROUTER_URL", service.DefaultOpenRouterPricingURL)
+		}
+		service.StartPricingRefresh(ctx, sources)
+		log.Info("pricing auto-sync enabled", "litellm", sources.LiteLLMURL, "openrouter", sources.OpenRouterURL)
+	}
+
+	// Error-rate alerts (per-project config in Settings.ErrorAlert)
+	alert.NewEvaluator(c.PrimaryStore, c.AnalyticsStore).Start(ctx, cfg.AlertEvalInterval)
+
+	// Clear span content past each project's Settings.SpanContentRetentionDays
+	trace.NewContentSweeper(c.PrimaryStore, c.AnalyticsStore).Start(ctx, trace.DefaultContentSweepInterval)
+
+	// One-off: fill in sub_type/tool_uses of llm spans stored before those
+	// columns existed (resumable; spans already filled in are skipped)
+	if cfg.BackfillSpanTools {
+		if backfiller, ok := c.AnalyticsStore.(repository.SpanToolBackfiller); ok {
+			go func() {
+				n, err := ingest.BackfillSpanTools(ctx, backfiller, ingest.DefaultBackfillBatchSize)
+				if err != nil {
+					log.Warn("span tool backfill stopped", "updated", n, "error", err)
+					return
+				}
+				log.Info("span tool backfill completed", "updated", n)
+			}()
+		} else {
+			log.Warn("BACKFILL_SPAN_TOOLS ignored: only a single sqlite analytics store supports it")
+		}
+	}
+
+	// Trace event webhooks (per-project subscriptions in Settings.WebhookEvents)
+	webhooks := webhook.NewDispatcher(webhook.NewSender(), webhook.DefaultQueueSize)
+	webhooks.Start(ctx)
+	c.IngestSvc.SetWebhookDispatcher(webhooks)
+
+	// Mark traces of hung agents as error (disabled unless a timeout is set)
+	if cfg.TraceInactivityTimeout > 0 {
+		trace.NewReaper(c.PrimaryStore, c.AnalyticsStore, cfg.TraceInactivityTimeout).Start(ctx, cfg.TraceReapInterval)
+		log.Info("stale trace reaper enabled", "timeout", cfg.TraceInactivityTimeout)
+	}
+
+	// Last, so replayed jobs go through every hook set above
+	if cfg.IngestWALPath != "" {
+		if err := c.IngestSvc.EnableWAL(cfg.IngestWALPath); err != nil {
+			c.stopWorkers()
+			return fmt.Errorf("failed to open ingest WAL %s: %w", cfg.IngestWALPath, err)
+		}
+		log.Info("ingest WAL enabled", "path", cfg.IngestWALPath)
+	}
+	return nil
+}
+
+// RouterConfig returns the router config of the core routes, for the caller
+// to extend (e.g. with Extensions) before building the router
+func (c *Core) RouterConfig() (apphttp.RouterConfig, error) {
+	cfg := c.cfg
+
+	// Ingest auth schemes for telemetry agents (API key only by default)
+	ingestAuth := middleware.IngestAuthConfig{
+		Schemes:        make(map[string][]middleware.IngestAuthScheme, len(cfg.IngestAuthSchemes)),
+		BearerTokens:   cfg.IngestBearerTokens,
+		ClientSubjects: cfg.IngestClientSubjects,
+		SubjectHeader:  cfg.IngestSubjectHeader,
+		NoCORS:         cfg.IngestNoCORSRoutes,
+	}
+	for route, schemes := range cfg.IngestAuthSchemes {
+		for _, scheme := range schemes {
+			ingestAuth.Schemes[route] = append(ingestAuth.Schemes[route], middleware.IngestAuthScheme(scheme))
+		}
+	}
+
+	// Frontends OAuth logins may land on besides FRONTEND_URL (the resolver
+	// picking one is left to the caller, e.g. per organization)
+	frontendAllowlist, err := handler.NewFrontendAllowlist(cfg.FrontendAllowlist...)
+	if err != nil {
+		return apphttp.RouterConfig{}, fmt.Errorf("invalid FRONTEND_ALLOWLIST: %w", err)
+	}
+
+	// Rate limit policies (RATE_LIMITS) replacing the defaults of the same name
+	rateLimits, err := middleware.ParseRateLimitPolicies(cfg.RateLimits)
+	if err != nil {
+		return apphttp.RouterConfig{}, fmt.Errorf("invalid RATE_LIMITS: %w", err)
+	}
+
+	return apphttp.RouterConfig{
+		PrimaryStore:       c.PrimaryStore,
+		AnalyticsStore:     c.AnalyticsStore,
+		IngestSvc:          c.IngestSvc,
+		TraceSvc:           c.TraceSvc,
+		AnalyticsSvc:       c.AnalyticsSvc,
+		ProjectSvc:         c.ProjectSvc,
+		AuthSvc:            c.AuthSvc,
+		JWTService:         c.JWTService,
+		FrontendURL:        cfg.FrontendURL,
+		FrontendAllowlist:  frontendAllowlist,
+		AllowedOrigins:     cfg.AllowedOrigins,
+		IngestAuth:         ingestAuth,
+		KeyUsage:           c.KeyUsage,
+		ExportSvc:          c.ExportSvc,
+		ProxySvc:           c.ProxySvc,
+		StoreBackends:      storeBackends(cfg),
+		AdminToken:         cfg.AdminAPIToken,
+		MaxBodyBytes:       cfg.MaxBodyBytes,
+		IngestMaxBodyBytes: cfg.IngestMaxBodyBytes,
+		RateLimits:         rateLimits,
+	}, nil
+}
+
+// Stop ends the background workers and drains the ingest queue. Call it once
+// the HTTP server has stopped taking requests.
+func (c *Core) Stop() {
+	c.stopWorkers()
+	c.IngestSvc.Stop(ingestDrainTimeout)
+}
+
+// Close flushes buffered API key usage and closes the stores. Call it after Stop.
+func (c *Core) Close(ctx context.Context) error {
+	var errs []error
+	if err := c.KeyUsage.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("api key usage flush: %w", err))
+	}
+	errs = append(errs, c.closeStores()...)
+	return errors.Join(errs...)
+}
+
+// closeStores closes the stores opened so far
+func (c *Core) closeStores() []error {
+	var errs []error
+	if c.AnalyticsStore != nil && c.AnalyticsStore != c.PrimaryStore {
+		if err := c.AnalyticsStore.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("analytics store close: %w", err))
+		}
+	}
+	if c.PrimaryStore != nil {
+		if err := c.PrimaryStore.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("primary store close: %w", err))
+		}
+	}
+	return errs
+}
+
+// envOr returns the value of environment variable key, or fallback if unset/empty.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// storeBackends lists the database backends the config selects, for GET /api/v1/version
+func storeBackends(cfg *config.Config) handler.StoreBackends {
+	analyticsURLs := cfg.AnalyticsShardURLs
+	if len(analyticsURLs) == 0 {
+		analyticsURLs = []string{cfg.AnalyticsDatabaseURL}
+		if cfg.AnalyticsDatabaseURL == "" {
+			analyticsURLs = []string{cfg.DatabaseURL}
+		}
+	}
+	regions := make([]string, 0, len(cfg.AnalyticsRegionURLs))
+	for region := range cfg.AnalyticsRegionURLs {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		analyticsURLs = append(analyticsURLs, cfg.AnalyticsRegionURLs[region])
+	}
+
+	return handler.StoreBackends{
+		Primary:   store.Backend(cfg.DatabaseURL),
+		Analytics: store.Backends(analyticsURLs...),
+	}
+}
@@ -0,0 +1,59 @@
+package bootstrap
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lelemon/server/pkg/infrastructure/config"
+)
+
+func TestCore_WiresConfig(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "ingest.wal")
+	t.Setenv("DATABASE_URL", "sqlite://"+filepath.Join(dir, "lelemon.db"))
+	t.Setenv("PRICING_AUTOSYNC", "false")
+	t.Setenv("INGEST_WAL_PATH", walPath)
+	t.Setenv("OPENAI_PROXY_ENABLED", "true")
+	t.Setenv("ADMIN_API_TOKEN", "admin-token")
+	t.Setenv("INGEST_MAX_BODY_BYTES", "1024")
+	cfg := config.Load()
+
+	ctx := context.Background()
+	core, err := New(ctx, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	routerCfg, err := core.RouterConfig()
+	if err != nil {
+		t.Fatalf("RouterConfig: %v", err)
+	}
+	if routerCfg.ProxySvc == nil {
+		t.Error("expected the proxy service to be wired")
+	}
+	if routerCfg.AdminToken != "admin-token" {
+		t.Errorf("expected admin token, got %q", routerCfg.AdminToken)
+	}
+	if routerCfg.IngestMaxBodyBytes != 1024 {
+		t.Errorf("expected ingest body limit 1024, got %d", routerCfg.IngestMaxBodyBytes)
+	}
+	if routerCfg.StoreBackends.Primary != "sqlite" {
+		t.Errorf("expected sqlite primary backend, got %q", routerCfg.StoreBackends.Primary)
+	}
+
+	if err := core.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := os.Stat(walPath); err != nil {
+		t.Errorf("expected the ingest WAL to be opened: %v", err)
+	}
+
+	core.Stop()
+	if err := core.Close(ctx); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
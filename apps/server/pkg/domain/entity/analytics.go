@@ -3,12 +3,66 @@ package entity
 import "time"
 
 type Stats struct {
-	TotalTraces   int
-	TotalSpans    int
-	TotalTokens   int
-	TotalCostUSD  float64
-	AvgDurationMs int
-	ErrorRate     float64 // 0-100 percentage
+	TotalTraces    int
+	TotalSpans     int
+	TotalTokens    int
+	TotalCostUSD   float64
+	AvgDurationMs  int
+	ErrorRate      float64 // 0-100 percentage
+	DistinctModels int     // models used by spans in the period
+	DistinctUsers  int     // user IDs set on traces in the period
+
+	// Spans by failure status, so timeouts and cancellations can be told
+	// apart from other errors
+	ErrorSpans     int
+	TimeoutSpans   int
+	CancelledSpans int
+
+	// TotalCost is TotalCostUSD in Currency, the project's display currency
+	TotalCost float64
+	Currency  string
+}
+
+// StatsComparison holds a period's stats next to those of the equal-length
+// period just before it, for "+12% vs last week" deltas
+type StatsComparison struct {
+	Current  Stats
+	Previous Stats
+	Change   StatsChange
+}
+
+// StatsChange is the percentage change of each stat from the previous period
+// to the current one, e.g. 12.5 for +12.5% (for ErrorRate, the change of the
+// rate itself). It is nil for a stat that was 0 in the previous period.
+type StatsChange struct {
+	TotalTraces   *float64
+	TotalSpans    *float64
+	TotalTokens   *float64
+	TotalCostUSD  *float64
+	AvgDurationMs *float64
+	ErrorRate     *float64
+	DistinctUsers *float64
+}
+
+// CompareStats returns the percentage change of each stat from previous to
+// current
+func CompareStats(current, previous Stats) StatsChange {
+	change := func(cur, prev float64) *float64 {
+		if prev == 0 {
+			return nil
+		}
+		pct := (cur - prev) / prev * 100
+		return &pct
+	}
+	return StatsChange{
+		TotalTraces:   change(float64(current.TotalTraces), float64(previous.TotalTraces)),
+		TotalSpans:    change(float64(current.TotalSpans), float64(previous.TotalSpans)),
+		TotalTokens:   change(float64(current.TotalTokens), float64(previous.TotalTokens)),
+		TotalCostUSD:  change(current.TotalCostUSD, previous.TotalCostUSD),
+		AvgDurationMs: change(float64(current.AvgDurationMs), float64(previous.AvgDurationMs)),
+		ErrorRate:     change(current.ErrorRate, previous.ErrorRate),
+		DistinctUsers: change(float64(current.DistinctUsers), float64(previous.DistinctUsers)),
+	}
 }
 
 type DataPoint struct {
@@ -17,6 +71,10 @@ type DataPoint struct {
 	Spans   int
 	Tokens  int
 	CostUSD float64
+
+	// Cost is CostUSD in Currency, the project's display currency
+	Cost     float64
+	Currency string
 }
 
 type Period struct {
@@ -49,6 +107,10 @@ type ModelStats struct {
 	P50LatencyMs int
 	P95LatencyMs int
 	P99LatencyMs int
+
+	// TotalCost is TotalCostUSD in Currency, the project's display currency
+	TotalCost float64
+	Currency  string
 }
 
 // TagStats represents analytics grouped by tag
@@ -58,6 +120,10 @@ type TagStats struct {
 	TotalTokens  int
 	TotalCostUSD float64
 	AvgLatencyMs int
+
+	// TotalCost is TotalCostUSD in Currency, the project's display currency
+	TotalCost float64
+	Currency  string
 }
 
 // UserStats represents analytics grouped by user
@@ -68,6 +134,58 @@ type UserStats struct {
 	TotalCostUSD float64
 	AvgLatencyMs int
 	LastActive   time.Time
+
+	// TotalCost is TotalCostUSD in Currency, the project's display currency
+	TotalCost float64
+	Currency  string
+}
+
+// ToolViolationStats counts tool calls whose arguments violated the project's
+// registered JSON Schema, grouped by tool
+type ToolViolationStats struct {
+	Tool       string
+	Violations int // tool calls with invalid arguments
+	Spans      int // LLM spans containing at least one of them
+}
+
+// EvalStats aggregates the verdicts of eval spans (see SpanTypeEval) by
+// evaluator, the span name, and verdict label
+type EvalStats struct {
+	Name     string
+	Label    string   // "" for verdicts with only a score
+	Verdicts int      // eval spans with this name and label
+	Scored   int      // those with a score
+	AvgScore *float64 // mean score of the scored ones; nil when none is
+}
+
+// CacheEfficiency is prompt-cache usage for one model's LLM spans. InputTokens
+// is the whole prompt, cached tokens included: providers whose input_tokens
+// exclude them (Anthropic, Bedrock) have cache reads and writes added back.
+type CacheEfficiency struct {
+	Model            string
+	Provider         string
+	Requests         int
+	InputTokens      int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	CacheHitRatio    float64 // CacheReadTokens / InputTokens; 0 when there was no input
+}
+
+// UnpricedModelStats counts LLM spans whose model has no pricing table entry
+// (their cost was recorded as $0), grouped by provider and model
+type UnpricedModelStats struct {
+	Provider string
+	Model    string
+	Spans    int
+}
+
+// StorageStats sums the serialized input and output sizes of spans, grouped by
+// span type. Spans stored before sizes were recorded count as 0 bytes
+type StorageStats struct {
+	Type        string
+	Spans       int
+	InputBytes  int64
+	OutputBytes int64
 }
 
 // HourlyHeatmap represents usage by hour of day and day of week
@@ -77,6 +195,10 @@ type HourlyHeatmap struct {
 	Traces  int
 	Tokens  int
 	CostUSD float64
+
+	// Cost is CostUSD in Currency, the project's display currency
+	Cost     float64
+	Currency string
 }
 
 // LatencyBucket represents a latency histogram bucket
@@ -87,6 +209,41 @@ type LatencyBucket struct {
 	Count  int
 }
 
+// DefaultLatencyHistogramEdges are the bucket edges (ms) of a latency
+// histogram requested without its own
+var DefaultLatencyHistogramEdges = []int{100, 500, 1000, 2000, 5000, 10000}
+
+// MaxLatencyHistogramEdges bounds the bucket edges a latency histogram takes
+const MaxLatencyHistogramEdges = 50
+
+// LatencyHistogramQuery selects the spans a latency histogram counts. Edges
+// are ascending bucket boundaries in ms: n edges make n+1 buckets, the first
+// starting at 0 and the last open-ended.
+type LatencyHistogramQuery struct {
+	AnalyticsQuery
+	Edges    []int
+	SpanType SpanType // empty = every type
+	Model    string   // empty = every model
+}
+
+// HistogramBucket counts the spans with MinMs <= duration < MaxMs. MaxMs is
+// nil for the last, open-ended bucket.
+type HistogramBucket struct {
+	MinMs int
+	MaxMs *int
+	Count int
+}
+
+// NewHistogramBuckets returns the empty buckets delimited by edges
+func NewHistogramBuckets(edges []int) []HistogramBucket {
+	buckets := make([]HistogramBucket, len(edges)+1)
+	for i, edge := range edges {
+		buckets[i].MaxMs = &edge
+		buckets[i+1].MinMs = edge
+	}
+	return buckets
+}
+
 // LatencyPoint represents percentile latency at a point in time
 type LatencyPoint struct {
 	Time time.Time
@@ -95,12 +252,25 @@ type LatencyPoint struct {
 	P99  int
 }
 
+// LatencyPercentiles summarizes span latency over a whole period
+type LatencyPercentiles struct {
+	P50 int
+	P95 int
+	P99 int
+}
+
 // AnalyticsFilter holds optional dimensional filters for analytics queries
 type AnalyticsFilter struct {
 	Tag       string // exact tag match
 	SessionID string // filter by session
 	UserID    string // filter by user
 	Name      string // filter by trace name
+
+	// ExcludeTags leaves out traces carrying any of these tags, and
+	// ExcludeEnvironments those whose "environment" metadata is one of these
+	// (see ProjectSettings.AnalyticsExclusion)
+	ExcludeTags         []string
+	ExcludeEnvironments []string
 }
 
 // HasFilters returns true if any dimensional filter is set
@@ -2,8 +2,25 @@ package entity
 
 // Page represents a paginated result
 type Page[T any] struct {
-	Data   []T `json:"Data"`
-	Total  int `json:"Total"`
-	Limit  int `json:"Limit"`
-	Offset int `json:"Offset"`
+	Data       []T  `json:"Data"`
+	Total      int  `json:"Total"`
+	Limit      int  `json:"Limit"`
+	Offset     int  `json:"Offset"`
+	HasMore    bool `json:"HasMore"`    // more results after this page
+	TotalPages int  `json:"TotalPages"` // pages of Limit results covering Total
+}
+
+// NewPage builds a page and derives HasMore/TotalPages from the counts
+func NewPage[T any](data []T, total, limit, offset int) *Page[T] {
+	p := &Page[T]{
+		Data:    data,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: offset+len(data) < total,
+	}
+	if limit > 0 {
+		p.TotalPages = (total + limit - 1) / limit
+	}
+	return p
 }
@@ -0,0 +1,10 @@
+package entity
+
+// TableOptimization reports a forced merge of a table's parts (see
+// repository.TableOptimizer)
+type TableOptimization struct {
+	Table       string `json:"table"`
+	PartsBefore int    `json:"partsBefore"` // active parts before the merge
+	PartsAfter  int    `json:"partsAfter"`
+	DurationMs  int64  `json:"durationMs"`
+}
@@ -1,11 +1,15 @@
 package entity
 
-import "time"
+import (
+	"regexp"
+	"strings"
+	"time"
+)
 
 type Project struct {
 	ID         string
 	Name       string
-	APIKey     string // Public key (le_xxx...)
+	APIKey     string // Public key (le_xxx..., le_<environment>_xxx... with Settings.Environment)
 	APIKeyHash string // SHA-256 hash for lookup
 	OwnerEmail string
 	Settings   ProjectSettings
@@ -14,10 +18,343 @@ type Project struct {
 }
 
 type ProjectSettings struct {
-	RetentionDays *int              `json:"retentionDays,omitempty"`
-	WebhookURL    *string           `json:"webhookUrl,omitempty"`
-	ModelAliases  map[string]string `json:"modelAliases,omitempty"`  // e.g. {"us.anthropic.claude-sonnet-4-6": "Claude Sonnet"}
-	SpanColors    map[string]string `json:"spanColors,omitempty"`    // e.g. {"sales": "#22c55e", "support": "#3b82f6"}
+	RetentionDays *int                 `json:"retentionDays,omitempty"`
+	WebhookURL    *string              `json:"webhookUrl,omitempty"`
+	ModelAliases  map[string]string    `json:"modelAliases,omitempty"` // e.g. {"us.anthropic.claude-sonnet-4-6": "Claude Sonnet"}
+	SpanColors    map[string]string    `json:"spanColors,omitempty"`   // e.g. {"sales": "#22c55e", "support": "#3b82f6"}
+	ErrorAlert    *ErrorAlertSettings  `json:"errorAlert,omitempty"`
+	IngestDedup   *IngestDedupSettings `json:"ingestDedup,omitempty"`
+	ToolSchemas   map[string]any       `json:"toolSchemas,omitempty"` // tool name -> JSON Schema its call arguments must satisfy
+	Redaction     *RedactionSettings   `json:"redaction,omitempty"`
+
+	// IndexedAttributes lists the top-level span metadata keys promoted at
+	// ingest into the indexed attribute table, for fast exact-match search.
+	// Only spans ingested after a key is added are indexed under it.
+	IndexedAttributes []string `json:"indexedAttributes,omitempty"`
+
+	// ListMetadataKeys lists the top-level trace metadata keys returned by
+	// trace list endpoints; the trace detail still returns all metadata.
+	// Empty returns all metadata in lists too.
+	ListMetadataKeys []string `json:"listMetadataKeys,omitempty"`
+
+	// Region pins the project's traces to a data region (e.g. "eu") when the
+	// server is configured with per-region stores; empty uses the default store
+	Region string `json:"region,omitempty"`
+
+	// SampleRate is the share of traces kept at ingest, from 0 to 1; nil keeps
+	// every trace. Traces are kept or dropped whole, by a hash of their ID.
+	SampleRate *float64 `json:"sampleRate,omitempty"`
+
+	// StrictSpanTypes rejects events with an unrecognized spanType (see
+	// SpanTypes) instead of ingesting them as llm spans, and spans whose
+	// parent links form a cycle instead of storing them as roots
+	StrictSpanTypes bool `json:"strictSpanTypes,omitempty"`
+
+	// StrictSpanStatus rejects events with an unrecognized status (see
+	// SpanStatuses) instead of ingesting them as errors
+	StrictSpanStatus bool `json:"strictSpanStatus,omitempty"`
+
+	// DefaultSpanStatus is the status of spans ingested without one:
+	// "success" (the default) for clients that only send finished spans, or
+	// "pending" for streaming clients, which send spans as they start and
+	// give the finished ones an explicit status. An explicit status always
+	// takes precedence; pending root spans keep their trace active.
+	DefaultSpanStatus string `json:"defaultSpanStatus,omitempty"`
+
+	// PublicMetricsEnabled serves the project's aggregate metrics (request
+	// volume, error rate, p95 latency) without auth at
+	// /api/v1/public/projects/{id}/metrics, e.g. for a status page
+	PublicMetricsEnabled bool `json:"publicMetricsEnabled,omitempty"`
+
+	// TraceLimits flags runaway traces (e.g. agent loops) at ingest
+	TraceLimits *TraceLimitSettings `json:"traceLimits,omitempty"`
+
+	// Environment (e.g. "prod", "test") is embedded in the project's API key
+	// prefix (le_prod_...), and keys of another environment are rejected, so
+	// a production key can't be used by mistake against a staging project.
+	// Changing it rejects the current key until the key is rotated.
+	Environment string `json:"environment,omitempty"`
+
+	// TraceNameSources lists where a trace without a named agent span (or
+	// _traceName metadata) takes its name from, tried in order at ingest
+	// (see TraceNameSourceRoot and the others). Empty uses
+	// DefaultTraceNameSources; ["none"] leaves such traces unnamed.
+	TraceNameSources []string `json:"traceNameSources,omitempty"`
+
+	// TraceErrorRule decides which failed spans mark their trace errored at
+	// ingest: TraceErrorRuleAnySpan (the default) or TraceErrorRuleRootSpan
+	TraceErrorRule string `json:"traceErrorRule,omitempty"`
+
+	// Currency is the ISO 4217 code analytics show costs in, alongside USD
+	// (e.g. "EUR"); empty is USD. Costs are stored in USD and converted when
+	// read, at the server's configured rates.
+	Currency string `json:"currency,omitempty"`
+
+	// AnalyticsExclusion leaves test traces out of analytics aggregates by
+	// default, so they don't pollute production numbers; they still show in
+	// trace lists, and analytics requests can include them with
+	// ?includeTests=true
+	AnalyticsExclusion *AnalyticsExclusionSettings `json:"analyticsExclusion,omitempty"`
+
+	// SpanContentRetentionDays clears the input, output and thinking of spans
+	// older than this many days, keeping their tokens, cost and duration for
+	// analytics; nil keeps span content as long as the trace
+	SpanContentRetentionDays *int `json:"spanContentRetentionDays,omitempty"`
+
+	// WebhookEvents subscribes WebhookURL to trace events (see
+	// WebhookEventTraceCompleted and the others), delivered as they happen
+	// at ingest. The error-rate alert is sent whatever is listed here.
+	WebhookEvents []string `json:"webhookEvents,omitempty"`
+
+	// WebhookSecret signs every webhook call, alerts included: the
+	// X-Lelemon-Signature header carries sha256=<hex HMAC-SHA256 of the
+	// body>. Empty sends calls unsigned. Like IngestSigningSecret it is
+	// write-only: responses omit it (see Redacted).
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+
+	// IngestSigningSecret requires every ingest request to be signed, on top
+	// of its API key (or other ingest auth), so a leaked key alone can't send
+	// telemetry: X-Lelemon-Timestamp carries the Unix time in seconds and
+	// X-Lelemon-Signature sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">.
+	// Requests outside the timestamp window are rejected as replays. Empty
+	// accepts unsigned requests.
+	IngestSigningSecret string `json:"ingestSigningSecret,omitempty"`
+
+	// PricingOverrides are the project's own model rates, e.g. a negotiated
+	// discount, keyed by model name or prefix (the longest matching key
+	// wins). They take precedence over the global pricing table when spans
+	// are priced at ingest or recosted; spans already stored keep their cost
+	// until recosted.
+	PricingOverrides map[string]PricingOverride `json:"pricingOverrides,omitempty"`
+
+	// RequireSessionID flags ingest events sent without a sessionId, which
+	// leave their trace out of session views: SessionIDRequirementWarn
+	// ingests them with a per-event warning in the ingest response,
+	// SessionIDRequirementReject rejects them with a per-event error. Empty
+	// accepts them. trace.end events are exempt.
+	RequireSessionID string `json:"requireSessionId,omitempty"`
+}
+
+// Trace name sources (see ProjectSettings.TraceNameSources)
+const (
+	TraceNameSourceRoot  = "root"  // the name of the trace's root span
+	TraceNameSourceLLM   = "llm"   // the name (or model) of its first llm span
+	TraceNameSourceInput = "input" // a preview of its first span input
+	TraceNameSourceNone  = "none"  // no fallback
+)
+
+// DefaultTraceNameSources is the trace name fallback chain of projects that
+// don't configure one
+var DefaultTraceNameSources = []string{TraceNameSourceRoot, TraceNameSourceLLM, TraceNameSourceInput}
+
+// ValidTraceNameSources reports whether sources is a valid trace name
+// fallback chain: known sources without repeats, or "none" alone
+func ValidTraceNameSources(sources []string) bool {
+	seen := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		switch source {
+		case TraceNameSourceRoot, TraceNameSourceLLM, TraceNameSourceInput:
+		case TraceNameSourceNone:
+			if len(sources) > 1 {
+				return false
+			}
+		default:
+			return false
+		}
+		if seen[source] {
+			return false
+		}
+		seen[source] = true
+	}
+	return true
+}
+
+// Trace error rules (see ProjectSettings.TraceErrorRule)
+const (
+	TraceErrorRuleAnySpan  = "any"  // any failed span errors the trace
+	TraceErrorRuleRootSpan = "root" // only a failed root span, or one flagged _required
+)
+
+// ValidTraceErrorRule reports whether rule is a known trace error rule, or
+// empty for the default
+func ValidTraceErrorRule(rule string) bool {
+	return rule == "" || rule == TraceErrorRuleAnySpan || rule == TraceErrorRuleRootSpan
+}
+
+// ValidDefaultSpanStatus reports whether status can be a project's default
+// span status: empty, success or pending
+func ValidDefaultSpanStatus(status string) bool {
+	return status == "" || status == string(SpanStatusSuccess) || status == string(SpanStatusPending)
+}
+
+// Session ID requirements (see ProjectSettings.RequireSessionID)
+const (
+	SessionIDRequirementWarn   = "warn"   // ingest events without a sessionId, with a warning
+	SessionIDRequirementReject = "reject" // reject events without a sessionId
+)
+
+// Redacted returns the settings without their secrets (WebhookSecret and
+// IngestSigningSecret), as every response carries them: a leaked API key
+// must not reveal the secret that signed ingest requires on top of it
+func (s ProjectSettings) Redacted() ProjectSettings {
+	s.WebhookSecret = ""
+	s.IngestSigningSecret = ""
+	return s
+}
+
+// ValidSessionIDRequirement reports whether requirement is a known session ID
+// requirement, or empty to accept events without one
+func ValidSessionIDRequirement(requirement string) bool {
+	return requirement == "" || requirement == SessionIDRequirementWarn || requirement == SessionIDRequirementReject
+}
+
+// Webhook event types (see ProjectSettings.WebhookEvents)
+const (
+	WebhookEventTraceCompleted = "trace.completed" // a trace's root span ended
+	WebhookEventTraceError     = "trace.error"     // a trace was marked errored
+	WebhookEventBudgetExceeded = "budget.exceeded" // a trace went over traceLimits.maxCostUsd
+)
+
+// ValidWebhookEvents reports whether events lists known webhook event types
+func ValidWebhookEvents(events []string) bool {
+	for _, event := range events {
+		switch event {
+		case WebhookEventTraceCompleted, WebhookEventTraceError, WebhookEventBudgetExceeded:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency reports whether code can be a project currency: empty, or an
+// uppercase three-letter ISO 4217 code
+func ValidCurrency(code string) bool {
+	return code == "" || currencyPattern.MatchString(code)
+}
+
+// APIKeyPrefix is the prefix of every project API key
+const APIKeyPrefix = "le_"
+
+var environmentPattern = regexp.MustCompile(`^[a-z][a-z0-9]{0,15}$`)
+
+// ValidEnvironment reports whether env can name a project environment: empty,
+// or up to 16 lowercase letters and digits starting with a letter
+func ValidEnvironment(env string) bool {
+	return env == "" || environmentPattern.MatchString(env)
+}
+
+// APIKeyPrefixFor returns the API key prefix of a project environment:
+// le_<env>_, or le_ without an environment
+func APIKeyPrefixFor(env string) string {
+	if env == "" {
+		return APIKeyPrefix
+	}
+	return APIKeyPrefix + env + "_"
+}
+
+// APIKeyEnvironment returns the environment in an API key's prefix, or "" for
+// a key without one. The random part is hex, so it never holds an underscore.
+func APIKeyEnvironment(key string) string {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return ""
+	}
+	env, _, found := strings.Cut(rest, "_")
+	if !found {
+		return ""
+	}
+	return env
+}
+
+// TraceLimitSettings caps each trace's span count and cost. A trace that goes
+// over either is flagged runaway in its metadata and listed in the ingest
+// response's runawayTraces, so SDKs can abort the run; its spans are still
+// stored. Zero disables a limit.
+type TraceLimitSettings struct {
+	MaxSpans   int     `json:"maxSpans,omitempty"`
+	MaxCostUSD float64 `json:"maxCostUsd,omitempty"`
+}
+
+// PricingOverride is a model's rates in USD per 1K tokens. Cache and
+// reasoning rates left at 0 are derived from Input and Output as for the
+// global pricing table.
+type PricingOverride struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cacheRead,omitempty"`
+	CacheWrite float64 `json:"cacheWrite,omitempty"`
+	Reasoning  float64 `json:"reasoning,omitempty"`
+}
+
+// ValidPricingOverrides reports whether every override names a model and
+// has no negative rate
+func ValidPricingOverrides(overrides map[string]PricingOverride) bool {
+	for model, o := range overrides {
+		if strings.TrimSpace(model) == "" {
+			return false
+		}
+		if o.Input < 0 || o.Output < 0 || o.CacheRead < 0 || o.CacheWrite < 0 || o.Reasoning < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AnalyticsExclusionSettings selects the traces left out of analytics: those
+// carrying any of Tags, or whose "environment" metadata is one of
+// Environments
+type AnalyticsExclusionSettings struct {
+	Tags         []string `json:"tags,omitempty"`         // e.g. ["test"]
+	Environments []string `json:"environments,omitempty"` // e.g. ["sandbox"]
+}
+
+// ErrorAlertSettings configures the error-rate alert: when the share of
+// errored traces over the last Window minutes reaches Threshold, the project's
+// webhook is called, then silenced for Cooldown minutes.
+type ErrorAlertSettings struct {
+	Threshold       float64 `json:"threshold"`                 // error rate percentage (0-100); <= 0 disables
+	WindowMinutes   int     `json:"windowMinutes,omitempty"`   // default 15
+	CooldownMinutes int     `json:"cooldownMinutes,omitempty"` // default 60
+	WebhookURL      *string `json:"webhookUrl,omitempty"`      // e.g. a Slack incoming webhook; defaults to Settings.WebhookURL
+}
+
+// RedactionSettings opts a project into PII redaction at ingest: emails, phone
+// numbers and card-like numbers in span input and output (at any depth of
+// nested JSON), plus matches of any custom patterns, are replaced with
+// "[REDACTED]" before they are stored.
+type RedactionSettings struct {
+	Enabled  bool     `json:"enabled"`
+	Patterns []string `json:"patterns,omitempty"` // extra regular expressions (RE2 syntax)
+}
+
+// IngestDedupSettings opts a project into content-hash deduplication at ingest:
+// a span identical to one received within the window (same trace, type, name,
+// input, output and start time) is dropped. Protects against clients that
+// re-send spans without stable IDs (e.g. at-least-once queues).
+type IngestDedupSettings struct {
+	Enabled       bool `json:"enabled"`
+	WindowSeconds int  `json:"windowSeconds,omitempty"` // default 300
+}
+
+// APIKeyUsage tracks when a project API key was last used and how many
+// requests it has authenticated. Usage is keyed by the key hash, so rotating
+// the key starts a fresh record.
+type APIKeyUsage struct {
+	LastUsedAt   *time.Time
+	RequestCount int64
+}
+
+// Footprint is the approximate storage a project's traces and spans take, for
+// billing and cleanup. Row counts are exact; bytes are estimates whose method
+// depends on the store (see repository.TraceStore.GetProjectFootprint).
+type Footprint struct {
+	Traces     int64 `json:"traces"`
+	Spans      int64 `json:"spans"`
+	TraceBytes int64 `json:"traceBytes"`
+	SpanBytes  int64 `json:"spanBytes"`
 }
 
 type ProjectUpdate struct {
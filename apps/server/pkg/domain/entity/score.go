@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// ScoreSource says who produced a score
+type ScoreSource string
+
+const (
+	ScoreSourceUser ScoreSource = "user" // end-user feedback collected by the app
+)
+
+// ScoreNameFeedback names end-user thumbs up/down scores
+const ScoreNameFeedback = "feedback"
+
+// Score is a value attached to a trace. End-user feedback is stored as a
+// "feedback" score from source user, with value -1 (negative), 0 (neutral)
+// or 1 (positive); it is kept apart from programmatic eval scores by source.
+type Score struct {
+	ID        string
+	ProjectID string
+	TraceID   string
+	Name      string
+	Value     float64
+	Source    ScoreSource
+	Comment   *string
+	CreatedAt time.Time
+}
+
+// FeedbackStats aggregates end-user feedback given in a period
+type FeedbackStats struct {
+	Total        int
+	Positive     int
+	Neutral      int
+	Negative     int
+	PositiveRate float64 // 0-100 percentage of all feedback that was positive
+}
@@ -24,3 +24,25 @@ type SessionFilter struct {
 	Limit  int
 	Offset int
 }
+
+// SessionStats aggregates the sessions with traces in a period. Averages are
+// per session, over its traces in the period.
+type SessionStats struct {
+	Sessions            int // distinct sessions
+	AvgTracesPerSession float64
+	AvgCostUSD          float64
+	AvgDurationMs       int // from the session's first trace to its last
+	// NewSessions counts the sessions started each day of the period (by
+	// their first trace ever), oldest first; days without any are omitted
+	NewSessions []SessionDataPoint
+
+	// AvgCost is AvgCostUSD in Currency, the project's display currency
+	AvgCost  float64
+	Currency string
+}
+
+// SessionDataPoint is the number of sessions started on a day
+type SessionDataPoint struct {
+	Time        time.Time
+	NewSessions int
+}
@@ -1,6 +1,11 @@
 package entity
 
-import "time"
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
 
 type SpanType string
 
@@ -12,17 +17,70 @@ const (
 	SpanTypeEmbedding SpanType = "embedding"
 	SpanTypeGuardrail SpanType = "guardrail"
 	SpanTypeRerank    SpanType = "rerank"
+	SpanTypeEval      SpanType = "eval" // evaluation, e.g. LLM-as-judge; its output carries a verdict
 	SpanTypeCustom    SpanType = "custom"
 )
 
+// spanTypes is the registry of recognized span types, in canonical order
+var spanTypes = struct {
+	sync.RWMutex
+	list []SpanType
+}{list: []SpanType{
+	SpanTypeLLM, SpanTypeAgent, SpanTypeTool, SpanTypeRetrieval,
+	SpanTypeEmbedding, SpanTypeGuardrail, SpanTypeRerank, SpanTypeEval, SpanTypeCustom,
+}}
+
+// RegisterSpanType adds t to the recognized span types, so ingest accepts it
+// instead of coercing it to llm (or rejecting it in strict mode). Intended to be
+// called at startup by editions or integrations that emit their own types.
+func RegisterSpanType(t SpanType) {
+	spanTypes.Lock()
+	defer spanTypes.Unlock()
+	if t != "" && !slices.Contains(spanTypes.list, t) {
+		spanTypes.list = append(spanTypes.list, t)
+	}
+}
+
+// SpanTypes returns the recognized span types
+func SpanTypes() []SpanType {
+	spanTypes.RLock()
+	defer spanTypes.RUnlock()
+	return slices.Clone(spanTypes.list)
+}
+
+// IsKnownSpanType reports whether t is a recognized span type
+func IsKnownSpanType(t SpanType) bool {
+	spanTypes.RLock()
+	defer spanTypes.RUnlock()
+	return slices.Contains(spanTypes.list, t)
+}
+
 type SpanStatus string
 
 const (
-	SpanStatusPending SpanStatus = "pending"
-	SpanStatusSuccess SpanStatus = "success"
-	SpanStatusError   SpanStatus = "error"
+	SpanStatusPending   SpanStatus = "pending"
+	SpanStatusSuccess   SpanStatus = "success"
+	SpanStatusError     SpanStatus = "error"
+	SpanStatusTimeout   SpanStatus = "timeout"   // the operation exceeded its deadline
+	SpanStatusCancelled SpanStatus = "cancelled" // the caller aborted the operation
 )
 
+// SpanStatuses lists the recognized span statuses
+var SpanStatuses = []SpanStatus{
+	SpanStatusPending, SpanStatusSuccess, SpanStatusError, SpanStatusTimeout, SpanStatusCancelled,
+}
+
+// IsKnownSpanStatus reports whether s is a recognized span status
+func IsKnownSpanStatus(s SpanStatus) bool {
+	return slices.Contains(SpanStatuses, s)
+}
+
+// Failed reports whether the span failed: it errored or timed out. A
+// cancelled span was stopped on purpose and is not a failure.
+func (s SpanStatus) Failed() bool {
+	return s == SpanStatusError || s == SpanStatusTimeout
+}
+
 // ToolUse represents a tool call extracted from LLM output
 type ToolUse struct {
 	ID       string `json:"id"`
@@ -51,6 +109,9 @@ type Span struct {
 	Metadata     map[string]any
 	StartedAt    time.Time
 	EndedAt      *time.Time
+	// Sequence is the span's emission order within its ingest batch; it
+	// orders spans of a trace that share a StartedAt
+	Sequence int
 	// Extended fields (Phase 7.1)
 	StopReason       *string
 	CacheReadTokens  *int
@@ -61,6 +122,109 @@ type Span struct {
 	// Pre-computed fields (calculated at ingest time)
 	SubType  *string   `json:"subType,omitempty"`  // "planning" | "response" for LLM spans
 	ToolUses []ToolUse `json:"toolUses,omitempty"` // Extracted tool calls from output
+	// ModelParams are the generation settings the model was called with
+	// (temperature, top_p, max_tokens, ...), sent or extracted at ingest
+	ModelParams map[string]any `json:"modelParams,omitempty"`
+	// Tags label the span itself (e.g. "regression-test", "slow"), apart
+	// from its trace's tags
+	Tags []string `json:"tags,omitempty"`
+	// Links reference spans related to this one outside the trace tree, e.g.
+	// the requests whose messages a batch job consumed (OpenTelemetry span links)
+	Links []SpanLink `json:"links,omitempty"`
+	// SubtreeCostUSD is an agent span's cost plus that of all its
+	// descendants (see SubtreeCosts), stored at ingest once the span's trace
+	// is completed or errored; nil on other spans and while the trace runs
+	SubtreeCostUSD *float64 `json:"subtreeCostUsd,omitempty"`
+	// Attributes are the metadata values promoted at ingest for indexed search
+	// (see ProjectSettings.IndexedAttributes). Write-only: Metadata stays the
+	// source of truth, so stores don't read them back.
+	Attributes map[string]string `json:"-"`
+	// Depth is the span's level in its trace tree, 1 for root spans. Not
+	// stored: set on the spans of a fetched trace (see SetDepths), 0 elsewhere.
+	Depth int `json:"depth,omitempty"`
+}
+
+// SpanLink is a non-hierarchical reference from a span to another span,
+// possibly in another trace
+type SpanLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// SpanMetadataContentExpired is the span metadata flag set when the span's
+// input, output and thinking were cleared by the project's span content
+// retention (see ProjectSettings.SpanContentRetentionDays)
+const SpanMetadataContentExpired = "content_expired"
+
+// SpanMetadataCostDetails is the span metadata key holding the client's
+// per-token-class cost breakdown, sent as costDetails at ingest
+const SpanMetadataCostDetails = "cost_details"
+
+// TagsJSON returns the span's tags serialized for storage, or nil when it
+// has none
+func (s *Span) TagsJSON() *string {
+	if len(s.Tags) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(s.Tags)
+	if err != nil {
+		return nil
+	}
+	str := string(b)
+	return &str
+}
+
+// ModelParamsJSON returns the span's model params serialized for storage,
+// or nil when it has none
+func (s *Span) ModelParamsJSON() *string {
+	if len(s.ModelParams) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(s.ModelParams)
+	if err != nil {
+		return nil
+	}
+	str := string(b)
+	return &str
+}
+
+// LinksJSON returns the span's links serialized for storage, or nil when it
+// has none
+func (s *Span) LinksJSON() *string {
+	if len(s.Links) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(s.Links)
+	if err != nil {
+		return nil
+	}
+	str := string(b)
+	return &str
+}
+
+// SpanFilter selects spans across traces (span search)
+type SpanFilter struct {
+	Type   *SpanType
+	Name   *string
+	Status *SpanStatus
+	Model  *string
+	From   *time.Time // on StartedAt
+	To     *time.Time
+	Text   string // case-insensitive substring of name, input, output or error message
+	// Metadata matches top-level metadata keys exactly (scalars compared as
+	// text). Extracted from the metadata JSON, so slow on large projects; see
+	// SearchSpansByAttribute for indexed keys.
+	Metadata map[string]string
+	// Tags keeps spans with at least one of these span tags
+	Tags   []string
+	Limit  int
+	Offset int
+}
+
+// SpanUpdate holds the span fields that can change after ingest
+type SpanUpdate struct {
+	Tags []string
 }
 
 type NewSpan struct {
@@ -89,3 +253,19 @@ type NewSpan struct {
 	FirstTokenMs     *int
 	Thinking         *string
 }
+
+// SpanWriteError is a span a partial write could not store (see
+// repository.TraceStore.CreateSpansPartial)
+type SpanWriteError struct {
+	Index int // into the spans written
+	Err   error
+}
+
+// PayloadBytes is the stored size of an encoded span input or output: the
+// length of its JSON, 0 when there is none
+func PayloadBytes(encoded []byte) int {
+	if string(encoded) == "null" {
+		return 0
+	}
+	return len(encoded)
+}
@@ -1,6 +1,9 @@
 package entity
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 type TraceStatus string
 
@@ -21,6 +24,9 @@ type Trace struct {
 	Metadata  map[string]any
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// ParentTraceID links the trace to the one that spawned it, e.g. a
+	// sub-agent's trace to its orchestrator's; set at ingest
+	ParentTraceID *string
 }
 
 // TraceWithSpans includes calculated metrics from spans
@@ -31,6 +37,161 @@ type TraceWithSpans struct {
 	TotalTokens     int
 	TotalCostUSD    float64
 	TotalDurationMs int
+	// SpansTruncated is set when Spans holds only the first spans of the
+	// trace; the totals still cover all of them
+	SpansTruncated bool
+	// RootCauseError points at the span the trace's failure originated in;
+	// nil when no span errored (see SetRootCauseError)
+	RootCauseError *RootCauseError
+	// ToolsUsed are the distinct tools the trace invoked (see SetToolsUsed)
+	ToolsUsed []string
+	// MaxDepth is the number of levels of the span tree: the Depth of the
+	// deepest span, 0 without spans (see SetDepths)
+	MaxDepth int
+}
+
+// RootCauseError is the originating error of a failed trace
+type RootCauseError struct {
+	SpanID   string
+	SpanName string
+	Message  string
+}
+
+// SetRootCauseError sets RootCauseError from the deepest failed (errored or
+// timed out) span, the earliest started among equally deep ones: a failing leaf (e.g. a tool call)
+// marks its ancestors errored too, so the deepest error is where it began.
+// Only Spans are considered, so a truncated trace may point at a later error.
+func (t *TraceWithSpans) SetRootCauseError() {
+	depths := spanDepths(t.Spans)
+
+	var cause *Span
+	causeDepth := -1
+	for i := range t.Spans {
+		span := &t.Spans[i]
+		if !span.Status.Failed() {
+			continue
+		}
+		d := depths[span.ID]
+		if d > causeDepth || (d == causeDepth && span.StartedAt.Before(cause.StartedAt)) {
+			cause, causeDepth = span, d
+		}
+	}
+	if cause == nil {
+		t.RootCauseError = nil
+		return
+	}
+
+	t.RootCauseError = &RootCauseError{SpanID: cause.ID, SpanName: cause.Name}
+	if cause.ErrorMessage != nil {
+		t.RootCauseError.Message = *cause.ErrorMessage
+	}
+}
+
+// SetDepths sets each span's Depth and the trace's MaxDepth from the parent
+// links. A span whose parent is not among Spans counts as a root, so a
+// truncated trace may report its later spans shallower than they are.
+func (t *TraceWithSpans) SetDepths() {
+	depths := spanDepths(t.Spans)
+	t.MaxDepth = 0
+	for i := range t.Spans {
+		t.Spans[i].Depth = depths[t.Spans[i].ID]
+		if t.Spans[i].Depth > t.MaxDepth {
+			t.MaxDepth = t.Spans[i].Depth
+		}
+	}
+}
+
+// spanDepths returns the tree level of each span by ID, 1 for roots. Each
+// span is resolved once, walking up only to the first ancestor already known,
+// so it is linear in the number of spans; a parent cycle is cut after as many
+// steps as there are spans.
+func spanDepths(spans []Span) map[string]int {
+	parents := make(map[string]string, len(spans))
+	for _, span := range spans {
+		parents[span.ID] = ""
+	}
+	for _, span := range spans {
+		if span.ParentSpanID != nil {
+			if _, ok := parents[*span.ParentSpanID]; ok {
+				parents[span.ID] = *span.ParentSpanID
+			}
+		}
+	}
+
+	depths := make(map[string]int, len(spans))
+	var path []string
+	for _, span := range spans {
+		path = path[:0]
+		id, base := span.ID, 0
+		for id != "" && len(path) < len(spans) {
+			if d, ok := depths[id]; ok {
+				base = d
+				break
+			}
+			path = append(path, id)
+			id = parents[id]
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			base++
+			depths[path[i]] = base
+		}
+	}
+	return depths
+}
+
+// SubtreeCosts returns the cost of each agent span by ID plus the cost of
+// all its descendants among spans. A span counts at most once per agent, even
+// within a parent cycle.
+func SubtreeCosts(spans []Span) map[string]float64 {
+	parents := make(map[string]string, len(spans))
+	costs := make(map[string]float64)
+	for _, span := range spans {
+		if span.ParentSpanID != nil {
+			parents[span.ID] = *span.ParentSpanID
+		}
+		if span.Type == SpanTypeAgent {
+			costs[span.ID] = 0
+		}
+	}
+
+	// Add each span's cost to itself and every agent ancestor
+	seen := make(map[string]bool)
+	for _, span := range spans {
+		if span.CostUSD == nil || *span.CostUSD == 0 {
+			continue
+		}
+		clear(seen)
+		for id := span.ID; id != "" && !seen[id]; id = parents[id] {
+			seen[id] = true
+			if _, ok := costs[id]; ok {
+				costs[id] += *span.CostUSD
+			}
+		}
+	}
+	return costs
+}
+
+// SetToolsUsed sets ToolsUsed, sorted, from the names of tool spans and of
+// the tool calls extracted from llm outputs. Only Spans are considered, so a
+// truncated trace may miss tools invoked later.
+func (t *TraceWithSpans) SetToolsUsed() {
+	seen := make(map[string]bool)
+	for _, span := range t.Spans {
+		if span.Type == SpanTypeTool && span.Name != "" {
+			seen[span.Name] = true
+		}
+		for _, toolUse := range span.ToolUses {
+			if toolUse.Name != "" {
+				seen[toolUse.Name] = true
+			}
+		}
+	}
+
+	t.ToolsUsed = make([]string, 0, len(seen))
+	for name := range seen {
+		t.ToolsUsed = append(t.ToolsUsed, name)
+	}
+	sort.Strings(t.ToolsUsed)
 }
 
 // TraceWithMetrics is a trace with calculated metrics (without spans)
@@ -40,6 +201,33 @@ type TraceWithMetrics struct {
 	TotalTokens     int
 	TotalCostUSD    float64
 	TotalDurationMs int
+	InactiveForMs   *int64 // Active traces only: time since the last span started (or the trace was created)
+}
+
+// TraceMetrics are the span totals of one trace, as on TraceWithSpans
+type TraceMetrics struct {
+	TotalSpans      int
+	TotalTokens     int
+	TotalCostUSD    float64
+	TotalDurationMs int
+}
+
+// SetLastActivity sets InactiveForMs for an active trace from the start of
+// its latest span. The trace's creation counts as activity too, so a zero
+// lastSpanAt (no spans) measures from CreatedAt.
+func (t *TraceWithMetrics) SetLastActivity(lastSpanAt, now time.Time) {
+	if t.Status != TraceStatusActive {
+		return
+	}
+	last := t.CreatedAt
+	if lastSpanAt.After(last) {
+		last = lastSpanAt
+	}
+	ms := now.Sub(last).Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	t.InactiveForMs = &ms
 }
 
 type TraceFilter struct {
@@ -50,8 +238,37 @@ type TraceFilter struct {
 	Tags      []string
 	From      *time.Time
 	To        *time.Time
-	Limit     int
-	Offset    int
+	// ParentTraceID keeps only the traces linked to this one (its children)
+	ParentTraceID *string
+	// MinInactiveMs keeps only active traces with no new span (nor creation)
+	// for at least this long: candidates for stuck agents
+	MinInactiveMs *int64
+	// Tool keeps only traces that invoked this tool: a tool span of that name
+	// or, on stores that keep them (SQLite), a tool call in an llm output
+	Tool *string
+	// MinDepth keeps only traces whose span tree has at least this many
+	// levels (see TraceWithSpans.MaxDepth), up to MaxFilterDepth
+	MinDepth *int
+	Limit    int
+	Offset   int
+}
+
+// MaxFilterDepth bounds TraceFilter.MinDepth: stores walk one parent link
+// per level.
+const MaxFilterDepth = 32
+
+// TraceCursor is a keyset position in a project's traces, ordered by
+// (CreatedAt, ID). Used to walk every trace without OFFSET scans.
+type TraceCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// SpanCursor is a keyset position in a project's spans, ordered by
+// (StartedAt, ID). Used to page through spans for recosting.
+type SpanCursor struct {
+	StartedAt time.Time
+	ID        string
 }
 
 type TraceUpdate struct {
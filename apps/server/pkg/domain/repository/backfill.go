@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// SpanToolBackfiller fills in the sub_type and tool_uses of llm spans stored
+// before those columns existed. Like TableOptimizer it is not part of Store:
+// only SQLite implements it, as older SQLite databases are the ones holding
+// such spans. Callers obtain it via a type assertion on the analytics store:
+//
+//	backfiller, ok := analyticsStore.(repository.SpanToolBackfiller)
+type SpanToolBackfiller interface {
+	// ListSpansMissingSubType returns up to limit llm spans, across all
+	// projects, with an output but no sub_type and an ID after afterID, in ID
+	// order. Only ID, Type and Output are set.
+	ListSpansMissingSubType(ctx context.Context, afterID string, limit int) ([]entity.Span, error)
+	// SetSpanSubTypes writes the SubType and ToolUses of spans, in one
+	// transaction, skipping spans whose sub_type has been set meanwhile, and
+	// returns how many spans were updated
+	SetSpanSubTypes(ctx context.Context, spans []entity.Span) (int64, error)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/lelemon/server/pkg/domain/entity"
 )
@@ -17,6 +18,7 @@ type Store interface {
 	// Composed interfaces
 	ProjectStore
 	TraceStore
+	ScoreStore
 	AnalyticsStore
 	UserStore
 }
@@ -29,8 +31,13 @@ type ProjectStore interface {
 	UpdateProject(ctx context.Context, id string, updates entity.ProjectUpdate) error
 	DeleteProject(ctx context.Context, id string) error
 	ListProjectsByOwner(ctx context.Context, email string) ([]entity.Project, error)
+	ListProjects(ctx context.Context) ([]entity.Project, error) // all projects (background jobs)
 	IsProjectOwner(ctx context.Context, projectID, ownerEmail string) (bool, error)
 	RotateAPIKey(ctx context.Context, id string, newKey, newHash string) error
+
+	// API key usage (written in batches by the auth middleware's usage tracker)
+	RecordAPIKeyUsage(ctx context.Context, projectID, keyHash string, lastUsedAt time.Time, requests int64) error
+	GetAPIKeyUsage(ctx context.Context, keyHash string) (*entity.APIKeyUsage, error)
 }
 
 // TraceStore handles trace and span operations
@@ -40,17 +47,78 @@ type TraceStore interface {
 	UpdateTrace(ctx context.Context, projectID, traceID string, updates entity.TraceUpdate) error
 	UpdateTraceStatus(ctx context.Context, projectID, traceID string, status entity.TraceStatus) error
 	DeleteAllTraces(ctx context.Context, projectID string) (int64, error)
+	// DeleteTracesByFilter deletes the traces (and their spans) matching filter;
+	// Limit and Offset are ignored. ClickHouse deletes asynchronously, so its
+	// count (of the matching traces just before the delete) is an estimate.
+	DeleteTracesByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error)
+
+	// Span writes. projectID is the owner of the spans' trace; single-database
+	// stores don't need it, but sharded stores route the write by it.
+	CreateSpan(ctx context.Context, projectID string, span *entity.Span) error
+	CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error
+	// CreateSpansPartial is CreateSpans without the all-or-nothing: each span,
+	// with its indexed attributes, is stored or not on its own, so one that
+	// violates a constraint doesn't lose the rest. It returns the spans that
+	// failed; the error is for failures to write at all (e.g. a lost connection).
+	CreateSpansPartial(ctx context.Context, projectID string, spans []entity.Span) ([]entity.SpanWriteError, error)
+	// CreateTracesWithSpans creates the traces that don't exist yet and then
+	// the spans, atomically where the database supports it, so spans may
+	// arrive before (or in the same batch as) their trace. It returns the
+	// number of traces actually created.
+	CreateTracesWithSpans(ctx context.Context, projectID string, traces []*entity.Trace, spans []entity.Span) (int, error)
+	// UpdateSpan applies updates to a span of the project's trace, returning
+	// entity.ErrNotFound when there is no such span
+	UpdateSpan(ctx context.Context, projectID, traceID, spanID string, updates entity.SpanUpdate) error
 
-	// Span writes
-	CreateSpan(ctx context.Context, span *entity.Span) error
-	CreateSpans(ctx context.Context, spans []entity.Span) error
+	// Span cost maintenance (re-pricing after pricing table updates).
+	// Spans whose cost was sent explicitly at ingest (cost_override) are not
+	// listed: an explicit cost takes precedence over the computed one.
+	// Spans are returned oldest first, starting after the cursor.
+	ListSpansForRecost(ctx context.Context, projectID string, from, to time.Time, after *entity.SpanCursor, limit int) ([]entity.Span, error)
+	UpdateSpanCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error)
+	// UpdateSpanSubtreeCosts sets the stored subtree cost of the project's
+	// agent spans by span ID (see entity.Span.SubtreeCostUSD)
+	UpdateSpanSubtreeCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error)
+
+	// Span content retention: ExpireSpanContent clears the input, output and
+	// thinking of the project's spans started before cutoff and flags them
+	// with entity.SpanMetadataContentExpired, keeping tokens, cost and
+	// duration. Spans already flagged are skipped; returns the spans cleared.
+	ExpireSpanContent(ctx context.Context, projectID string, cutoff time.Time) (int64, error)
 
 	// Trace reads
 	GetTrace(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error)
+	// GetTraceCapped is GetTrace returning at most maxSpans spans, earliest
+	// first (0 means no cap). The totals always cover every span.
+	GetTraceCapped(ctx context.Context, projectID, traceID string, maxSpans int) (*entity.TraceWithSpans, error)
+	// GetTracesMetrics returns the span totals of several traces in one
+	// grouped query, keyed by trace ID. IDs that aren't traces of the project
+	// are left out; traces without spans get zero totals.
+	GetTracesMetrics(ctx context.Context, projectID string, traceIDs []string) (map[string]entity.TraceMetrics, error)
 	ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error)
+	ListTraceIDs(ctx context.Context, projectID string, after *entity.TraceCursor, limit int) ([]entity.TraceCursor, error) // oldest first, starting after the cursor
+
+	// Span reads
+	ListTraceSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) // earliest first
+	SearchSpans(ctx context.Context, projectID string, filter entity.SpanFilter) (*entity.Page[entity.Span], error)
+	// SearchSpansByAttribute is SearchSpans narrowed to spans whose indexed
+	// attribute key equals value (see entity.Span.Attributes). Spans are only
+	// found under keys that were indexed when they were ingested.
+	SearchSpansByAttribute(ctx context.Context, projectID, key, value string, filter entity.SpanFilter) (*entity.Page[entity.Span], error)
 
 	// Session reads
 	ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error)
+
+	// GetProjectFootprint returns the project's trace and span row counts and
+	// estimated bytes: the summed column sizes on SQLite, and each table's size
+	// (pg_total_relation_size, ClickHouse system.parts) prorated by the
+	// project's share of its rows elsewhere
+	GetProjectFootprint(ctx context.Context, projectID string) (entity.Footprint, error)
+}
+
+// ScoreStore handles trace scores (e.g. end-user feedback)
+type ScoreStore interface {
+	CreateScore(ctx context.Context, score *entity.Score) error
 }
 
 // AnalyticsStore handles analytics queries
@@ -60,9 +128,18 @@ type AnalyticsStore interface {
 	GetModelStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ModelStats, error)
 	GetTagStats(ctx context.Context, projectID string, q entity.AnalyticsQuery, prefix string) ([]entity.TagStats, error)
 	GetTopUsers(ctx context.Context, projectID string, q entity.AnalyticsQuery, limit int) ([]entity.UserStats, error)
+	GetToolViolationStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ToolViolationStats, error)
+	GetEvalStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.EvalStats, error)
+	GetUnpricedModels(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.UnpricedModelStats, error)
+	GetStorageStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.StorageStats, error)
+	GetCacheEfficiency(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.CacheEfficiency, error)
+	GetFeedbackStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.FeedbackStats, error)
+	GetSessionStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.SessionStats, error)
 	GetHourlyHeatmap(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.HourlyHeatmap, error)
 	GetLatencyDistribution(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.LatencyBucket, error)
+	GetLatencyHistogram(ctx context.Context, projectID string, q entity.LatencyHistogramQuery) ([]entity.HistogramBucket, error)
 	GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error)
+	GetLatencyPercentiles(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.LatencyPercentiles, error)
 }
 
 // UserStore handles user operations (for dashboard auth)
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// TableOptimizer forces merges of a store's table parts. ClickHouse
+// ReplacingMergeTree tables accumulate unmerged parts under heavy update load
+// (traces are rewritten on every status change), which slows the FINAL
+// queries that read them. Like OAuthStore it is not part of Store: only
+// ClickHouse (and the sharded and regional stores over it) implement it.
+// Callers obtain it via a type assertion on the analytics store:
+//
+//	optimizer, ok := analyticsStore.(repository.TableOptimizer)
+type TableOptimizer interface {
+	// OptimizeTable runs OPTIMIZE TABLE ... FINAL on table and reports its
+	// active part counts before and after. It returns nil when the store
+	// holds no optimizable tables (e.g. a sharded store over SQLite), and
+	// entity.ErrBadRequest for tables that are not optimizable.
+	OptimizeTable(ctx context.Context, table string) (*entity.TableOptimization, error)
+}
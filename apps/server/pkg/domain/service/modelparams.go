@@ -0,0 +1,75 @@
+package service
+
+// modelParamKeys maps the generation settings providers accept, in their
+// request and config spellings, to the names spans record them under
+var modelParamKeys = map[string]string{
+	"temperature":           "temperature",
+	"top_p":                 "top_p",
+	"topP":                  "top_p",
+	"top_k":                 "top_k",
+	"topK":                  "top_k",
+	"max_tokens":            "max_tokens",
+	"maxTokens":             "max_tokens",
+	"max_completion_tokens": "max_tokens",
+	"max_output_tokens":     "max_tokens",
+	"maxOutputTokens":       "max_tokens",
+	"frequency_penalty":     "frequency_penalty",
+	"frequencyPenalty":      "frequency_penalty",
+	"presence_penalty":      "presence_penalty",
+	"presencePenalty":       "presence_penalty",
+	"stop":                  "stop",
+	"stop_sequences":        "stop",
+	"stopSequences":         "stop",
+	"seed":                  "seed",
+	"reasoning_effort":      "reasoning_effort",
+}
+
+// modelParamConfigs are the objects some providers group the settings in:
+// Gemini's generationConfig (config in the Gen AI SDK) and Bedrock Converse's
+// inferenceConfig
+var modelParamConfigs = []string{"generationConfig", "generation_config", "config", "inferenceConfig"}
+
+// ExtractModelParams collects the generation settings (temperature, top_p,
+// max_tokens, ...) from an LLM request, as SDKs send it for the span input,
+// and from its raw response: the OpenAI Responses API echoes the settings it
+// applied, which take precedence. Returns nil when neither carries any.
+func ExtractModelParams(request, rawResponse any) map[string]any {
+	params := make(map[string]any)
+	collectModelParams(params, request)
+	collectModelParams(params, rawResponse)
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// collectModelParams adds the settings found at the top level of v, or in one
+// of its modelParamConfigs, to params
+func collectModelParams(params map[string]any, v any) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	for _, key := range modelParamConfigs {
+		if config, ok := obj[key].(map[string]any); ok {
+			collectModelParams(params, config)
+		}
+	}
+	for key, value := range obj {
+		if name, ok := modelParamKeys[key]; ok && value != nil {
+			params[name] = value
+		}
+	}
+
+	// Nested settings: OpenAI reasoning.effort, Anthropic thinking.budget_tokens
+	if reasoning, ok := obj["reasoning"].(map[string]any); ok {
+		if effort, ok := reasoning["effort"].(string); ok {
+			params["reasoning_effort"] = effort
+		}
+	}
+	if thinking, ok := obj["thinking"].(map[string]any); ok {
+		if budget, ok := thinking["budget_tokens"].(float64); ok {
+			params["thinking_budget"] = budget
+		}
+	}
+}
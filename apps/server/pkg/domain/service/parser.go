@@ -42,6 +42,10 @@ func ParseProviderResponse(provider string, rawResponse any) *ParsedResponse {
 	}
 }
 
+// redactedThinking stands in for a redacted_thinking block, whose content is
+// encrypted
+const redactedThinking = "[redacted thinking]"
+
 // parseAnthropicResponse parses Anthropic Messages API response
 // Format: { content: [...], usage: { input_tokens, output_tokens, ... }, stop_reason }
 func parseAnthropicResponse(raw any) *ParsedResponse {
@@ -75,13 +79,15 @@ func parseAnthropicResponse(raw any) *ParsedResponse {
 		result.StopReason = &v
 	}
 
-	// Extract content
+	// Extract content. Blocks are kept in order: text blocks (split e.g.
+	// around citations) are joined, every thinking block is kept, including
+	// those interleaved between tool calls, and every tool_use is listed.
 	if content, ok := resp["content"].([]any); ok {
 		var textParts []string
 		var thinkingParts []string
 		hasToolUse := false
 
-		for i, block := range content {
+		for _, block := range content {
 			blockMap, ok := block.(map[string]any)
 			if !ok {
 				continue
@@ -98,6 +104,9 @@ func parseAnthropicResponse(raw any) *ParsedResponse {
 				if thinking, ok := blockMap["thinking"].(string); ok {
 					thinkingParts = append(thinkingParts, thinking)
 				}
+			case "redacted_thinking":
+				// Encrypted by Anthropic; keep its place in the reasoning
+				thinkingParts = append(thinkingParts, redactedThinking)
 			case "tool_use":
 				hasToolUse = true
 				id, _ := blockMap["id"].(string)
@@ -112,7 +121,6 @@ func parseAnthropicResponse(raw any) *ParsedResponse {
 						Status: "pending",
 					})
 				}
-				_ = i // suppress unused warning
 			}
 		}
 
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
 )
 
 // =============================================================================
@@ -205,6 +208,69 @@ func TestAnthropicScenarios(t *testing.T) {
 	})
 }
 
+// TestAnthropicMultiContent tests a response mixing several text, thinking
+// and tool_use blocks: nothing may be dropped and order must be kept
+func TestAnthropicMultiContent(t *testing.T) {
+	fixture := loadFixture(t, "anthropic_multi_content.json")
+	response := fixture["response"].(map[string]any)
+
+	t.Run("all blocks extracted", func(t *testing.T) {
+		result := ParseProviderResponse("anthropic", response)
+
+		assertNotNil(t, result)
+		assertStopReason(t, result, "tool_use")
+		assertSubType(t, result, "planning")
+		if result.InputTokens != 412 || result.OutputTokens != 168 {
+			t.Errorf("expected 412→168 tokens, got %d→%d", result.InputTokens, result.OutputTokens)
+		}
+
+		want := []entity.ToolUse{
+			{ID: "toolu_01A09q90qw90lq917835lq9", Name: "get_weather", Input: map[string]any{"city": "Madrid"}, Status: "pending"},
+			{ID: "toolu_01B19r91rx91mr928946mr0", Name: "get_time", Input: map[string]any{"timezone": "Europe/Madrid"}, Status: "pending"},
+		}
+		if !reflect.DeepEqual(result.ToolUses, want) {
+			t.Errorf("expected tool uses %+v, got %+v", want, result.ToolUses)
+		}
+
+		wantThinking := "The user wants the weather and the time in Madrid.\n\nMadrid is in the Europe/Madrid time zone."
+		if result.Thinking == nil || *result.Thinking != wantThinking {
+			t.Errorf("expected both thinking blocks in order, got %v", result.Thinking)
+		}
+
+		// With tool calls the raw content array is the output, every block included
+		if blocks, ok := result.Output.([]any); !ok || len(blocks) != 6 {
+			t.Errorf("expected the 6 content blocks as output, got %v", result.Output)
+		}
+	})
+
+	t.Run("text blocks joined in order", func(t *testing.T) {
+		var blocks []any
+		for _, block := range response["content"].([]any) {
+			if block.(map[string]any)["type"] != "tool_use" {
+				blocks = append(blocks, block)
+			}
+		}
+		result := ParseProviderResponse("anthropic", map[string]any{"content": blocks, "stop_reason": "end_turn"})
+
+		assertSubType(t, result, "response")
+		if result.Output != "I'll check the weather in Madrid and its local time." {
+			t.Errorf("expected the text blocks concatenated, got %v", result.Output)
+		}
+	})
+
+	t.Run("redacted thinking keeps its place", func(t *testing.T) {
+		result := ParseProviderResponse("anthropic", map[string]any{"content": []any{
+			map[string]any{"type": "thinking", "thinking": "First."},
+			map[string]any{"type": "redacted_thinking", "data": "EmwKAhgBEgy3va3pzix"},
+			map[string]any{"type": "text", "text": "Done."},
+		}})
+
+		if result.Thinking == nil || *result.Thinking != "First.\n\n[redacted thinking]" {
+			t.Errorf("expected the redacted block marked, got %v", result.Thinking)
+		}
+	})
+}
+
 // TestBedrockScenarios tests Bedrock Converse API scenarios
 func TestBedrockScenarios(t *testing.T) {
 	t.Run("text_response", func(t *testing.T) {
@@ -244,14 +244,50 @@ func deriveRates(model string, mp ModelPricing) ModelPricing {
 // table (external source overlaid on the local map; see pricing_source.go), and
 // the returned pricing has its cache/reasoning rates resolved via deriveRates.
 func findPricing(model string) (ModelPricing, bool) {
+	if mp, ok := lookupPricing(model); ok {
+		return mp, true
+	}
+
+	// No pricing found — track it for observability (logs once + on threshold).
+	recordUnknownModel(model)
+	return defaultPricing, false
+}
+
+// lookupPricing is findPricing without unknown-model tracking. Configured
+// aliases are resolved first; a fine-tuned model without a table entry of its
+// own is priced at its base model's rates times the fine-tune multiplier (see
+// pricing_alias.go).
+func lookupPricing(model string) (ModelPricing, bool) {
 	table := currentPricingTable()
+	model = resolveAlias(model)
 
-	// Try exact match first
+	key, mp, ok := matchPricing(table, model)
+	base, fineTuned := fineTunedBase(model)
+	if !fineTuned {
+		if ok {
+			return deriveRates(model, mp), true
+		}
+		return defaultPricing, false
+	}
+
+	// A dedicated fine-tune entry (e.g. LiteLLM's "ft:gpt-4o-mini-2024-07-18")
+	// wins; a plain prefix match like "gpt-3.5-turbo" is just the base model.
+	if _, keyFineTuned := fineTunedBase(key); ok && keyFineTuned {
+		return deriveRates(base, mp), true
+	}
+	if _, mp, ok := matchPricing(table, base); ok {
+		return scaleRates(deriveRates(base, mp), fineTunedMultiplier()), true
+	}
+	return defaultPricing, false
+}
+
+// matchPricing finds the table entry for model: exact match first, then the
+// longest key that prefixes it
+func matchPricing(table map[string]ModelPricing, model string) (string, ModelPricing, bool) {
 	if mp, ok := table[model]; ok {
-		return deriveRates(model, mp), true
+		return model, mp, true
 	}
 
-	// Try prefix match (longest match wins)
 	var bestMatch string
 	var bestPricing ModelPricing
 	for key, mp := range table {
@@ -260,18 +296,13 @@ func findPricing(model string) (ModelPricing, bool) {
 			bestPricing = mp
 		}
 	}
-
-	if bestMatch != "" {
-		return deriveRates(model, bestPricing), true
-	}
-
-	// No pricing found — track it for observability (logs once + on threshold).
-	recordUnknownModel(model)
-	return defaultPricing, false
+	return bestMatch, bestPricing, bestMatch != ""
 }
 
 // PricingCalculator calculates costs for LLM calls
-type PricingCalculator struct{}
+type PricingCalculator struct {
+	overrides map[string]ModelPricing // take precedence over the table; see WithOverrides
+}
 
 // NewPricingCalculator creates a new pricing calculator
 func NewPricingCalculator() *PricingCalculator {
@@ -310,7 +341,7 @@ func round6(v float64) float64 {
 // returns the per-category decomposition plus the total. The total is rounded
 // from the un-rounded components so it matches the legacy input+output result.
 func (p *PricingCalculator) CalculateCostBreakdown(model string, usage TokenUsage) CostBreakdown {
-	mp, _ := findPricing(model)
+	mp, _ := p.find(model)
 
 	inputCost := (float64(usage.Input) / 1000) * mp.Input
 	outputCost := (float64(usage.Output) / 1000) * mp.Output
@@ -369,10 +400,20 @@ func NormalizeTokenUsage(provider string, input, output, cacheRead, cacheWrite,
 
 // GetModelPricing returns the pricing for a model
 func (p *PricingCalculator) GetModelPricing(model string) ModelPricing {
-	mp, _ := findPricing(model)
+	mp, _ := p.find(model)
 	return mp
 }
 
+// HasPricing reports whether model resolves to an override or a pricing table
+// entry. Costs of models without one are reported as $0.
+func (p *PricingCalculator) HasPricing(model string) bool {
+	if _, ok := p.override(model); ok {
+		return true
+	}
+	_, ok := lookupPricing(model)
+	return ok
+}
+
 // CalculateCost is a convenience function
 func CalculateCost(model string, inputTokens, outputTokens int) float64 {
 	return NewPricingCalculator().CalculateCost(model, inputTokens, outputTokens)
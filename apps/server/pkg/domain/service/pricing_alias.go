@@ -0,0 +1,79 @@
+package service
+
+import (
+	"strings"
+	"sync"
+)
+
+// modelResolution holds the operator's model-name rules applied before a
+// pricing table lookup: explicit aliases (e.g. an Azure deployment name to the
+// model it serves) and the surcharge for fine-tuned models billed at their base
+// model's rate. Set once at startup; guarded for tests that swap it.
+var modelResolution = struct {
+	mu                 sync.RWMutex
+	aliases            map[string]string
+	fineTuneMultiplier float64
+}{fineTuneMultiplier: 1}
+
+// SetModelAliases installs explicit alias -> base model mappings, e.g.
+// {"prod-chat": "gpt-4o"}. Aliases match the reported model name exactly and
+// take precedence over the pricing table.
+func SetModelAliases(aliases map[string]string) {
+	modelResolution.mu.Lock()
+	modelResolution.aliases = aliases
+	modelResolution.mu.Unlock()
+}
+
+// SetFineTunedMultiplier sets the factor applied to a base model's rates when
+// pricing a fine-tuned model that has no table entry of its own. Values <= 0
+// reset it to 1 (base rate).
+func SetFineTunedMultiplier(multiplier float64) {
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	modelResolution.mu.Lock()
+	modelResolution.fineTuneMultiplier = multiplier
+	modelResolution.mu.Unlock()
+}
+
+// resolveAlias returns the configured base model for an alias, or model itself
+func resolveAlias(model string) string {
+	modelResolution.mu.RLock()
+	defer modelResolution.mu.RUnlock()
+	if base, ok := modelResolution.aliases[model]; ok {
+		return base
+	}
+	return model
+}
+
+func fineTunedMultiplier() float64 {
+	modelResolution.mu.RLock()
+	defer modelResolution.mu.RUnlock()
+	return modelResolution.fineTuneMultiplier
+}
+
+// fineTunedBase extracts the base model from a fine-tuned model ID:
+//
+//	ft:gpt-4o-2024-08-06:my-org:custom-suffix:abc123 -> gpt-4o-2024-08-06
+//	davinci-002:ft-my-org-2023-11-01-12-00-00       -> davinci-002
+func fineTunedBase(model string) (string, bool) {
+	if rest, ok := strings.CutPrefix(model, "ft:"); ok {
+		base, _, _ := strings.Cut(rest, ":")
+		return base, base != ""
+	}
+	if base, suffix, ok := strings.Cut(model, ":"); ok && strings.HasPrefix(suffix, "ft-") && base != "" {
+		return base, true
+	}
+	return "", false
+}
+
+// scaleRates multiplies every rate in mp by factor
+func scaleRates(mp ModelPricing, factor float64) ModelPricing {
+	return ModelPricing{
+		Input:      mp.Input * factor,
+		Output:     mp.Output * factor,
+		CacheRead:  mp.CacheRead * factor,
+		CacheWrite: mp.CacheWrite * factor,
+		Reasoning:  mp.Reasoning * factor,
+	}
+}
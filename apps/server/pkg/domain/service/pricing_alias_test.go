@@ -0,0 +1,108 @@
+package service
+
+import "testing"
+
+// resetModelResolution clears aliases and the fine-tune multiplier after a test
+// so the global rules can't leak across tests.
+func resetModelResolution(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		SetModelAliases(nil)
+		SetFineTunedMultiplier(1)
+	})
+}
+
+// TestFineTuned_ResolvesToBaseRate prices OpenAI fine-tune IDs at the base
+// model's rates, derived rates included.
+func TestFineTuned_ResolvesToBaseRate(t *testing.T) {
+	resetModelResolution(t)
+	base, _ := findPricing("gpt-4o-mini")
+
+	for _, model := range []string{
+		"ft:gpt-4o-mini:acme::abc123",
+		"ft:gpt-4o-mini-2024-07-18:acme:support-bot:9xYz",
+	} {
+		mp, ok := findPricing(model)
+		if !ok {
+			t.Fatalf("%s: expected pricing to be found", model)
+		}
+		if mp != base {
+			t.Errorf("%s: pricing = %+v, want base %+v", model, mp, base)
+		}
+	}
+}
+
+// TestFineTuned_LegacyFormat handles the "<base>:ft-<org>-<date>" IDs.
+func TestFineTuned_LegacyFormat(t *testing.T) {
+	resetModelResolution(t)
+	base, _ := findPricing("gpt-3.5-turbo")
+
+	mp, ok := findPricing("gpt-3.5-turbo:ft-acme-2023-11-01-12-00-00")
+	if !ok || mp != base {
+		t.Errorf("legacy fine-tune = %+v (found %v), want base %+v", mp, ok, base)
+	}
+
+	// The base name prefixes the ID, but the surcharge still applies
+	SetFineTunedMultiplier(2)
+	if mp, _ := findPricing("gpt-3.5-turbo:ft-acme-2023-11-01-12-00-00"); !approxEqual(mp.Input, base.Input*2) {
+		t.Errorf("legacy fine-tune input = %v, want %v", mp.Input, base.Input*2)
+	}
+}
+
+// TestFineTuned_Multiplier applies the surcharge to every rate.
+func TestFineTuned_Multiplier(t *testing.T) {
+	resetModelResolution(t)
+	SetFineTunedMultiplier(1.5)
+	base, _ := findPricing("gpt-4o")
+
+	mp, _ := findPricing("ft:gpt-4o-2024-08-06:acme::abc123")
+	if !approxEqual(mp.Input, base.Input*1.5) || !approxEqual(mp.Output, base.Output*1.5) {
+		t.Errorf("input/output = %v/%v, want %v/%v", mp.Input, mp.Output, base.Input*1.5, base.Output*1.5)
+	}
+	if !approxEqual(mp.CacheRead, base.CacheRead*1.5) {
+		t.Errorf("cache read = %v, want %v", mp.CacheRead, base.CacheRead*1.5)
+	}
+
+	// The base model itself is unaffected
+	if got, _ := findPricing("gpt-4o"); got != base {
+		t.Errorf("base pricing changed to %+v", got)
+	}
+}
+
+// TestFineTuned_TableEntryWins prefers a dedicated fine-tune price (e.g. from
+// LiteLLM) over base rate * multiplier.
+func TestFineTuned_TableEntryWins(t *testing.T) {
+	resetModelResolution(t)
+	resetSnapshot(t)
+	SetFineTunedMultiplier(2)
+	applyExternalPricing(map[string]ModelPricing{
+		"ft:gpt-4o-mini-2024-07-18": {Input: 0.0003, Output: 0.0012},
+	})
+
+	mp, _ := findPricing("ft:gpt-4o-mini-2024-07-18:acme::abc123")
+	if mp.Input != 0.0003 || mp.Output != 0.0012 {
+		t.Errorf("pricing = %+v, want the table's fine-tune entry", mp)
+	}
+}
+
+// TestModelAliases maps a deployment name to its base model, fine-tunes included.
+func TestModelAliases(t *testing.T) {
+	resetModelResolution(t)
+	SetModelAliases(map[string]string{
+		"prod-chat":   "claude-sonnet-4-5",
+		"support-bot": "ft:gpt-4o:acme::abc123",
+	})
+
+	if _, ok := lookupPricing("prod-chat"); !ok {
+		t.Fatal("prod-chat: expected the alias to be priced")
+	}
+	if got, want := NewPricingCalculator().GetModelPricing("prod-chat"), NewPricingCalculator().GetModelPricing("claude-sonnet-4-5"); got != want {
+		t.Errorf("prod-chat = %+v, want %+v", got, want)
+	}
+	if got, want := NewPricingCalculator().GetModelPricing("support-bot"), NewPricingCalculator().GetModelPricing("gpt-4o"); got != want {
+		t.Errorf("support-bot = %+v, want %+v", got, want)
+	}
+	if NewPricingCalculator().HasPricing("prod-chat-2") {
+		t.Error("aliases should match exactly")
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import "github.com/lelemon/server/pkg/domain/entity"
+
+// WithOverrides returns a calculator that prices models matching an entry of
+// overrides (exact name first, then the longest prefix) at its rates instead
+// of the pricing table's, e.g. a project's negotiated discount. Overrides are
+// matched against the reported model name and then its configured alias; cache
+// and reasoning rates left at 0 are derived as for table entries. Without
+// overrides it returns p.
+func (p *PricingCalculator) WithOverrides(overrides map[string]ModelPricing) *PricingCalculator {
+	if len(overrides) == 0 {
+		return p
+	}
+	return &PricingCalculator{overrides: overrides}
+}
+
+// override returns the override rates for model, if any
+func (p *PricingCalculator) override(model string) (ModelPricing, bool) {
+	if len(p.overrides) == 0 {
+		return ModelPricing{}, false
+	}
+	_, mp, ok := matchPricing(p.overrides, model)
+	if !ok {
+		if base := resolveAlias(model); base != model {
+			_, mp, ok = matchPricing(p.overrides, base)
+		}
+	}
+	if !ok {
+		return ModelPricing{}, false
+	}
+	return deriveRates(model, mp), true
+}
+
+// find is findPricing with the calculator's overrides taking precedence
+func (p *PricingCalculator) find(model string) (ModelPricing, bool) {
+	if mp, ok := p.override(model); ok {
+		return mp, true
+	}
+	return findPricing(model)
+}
+
+// ProjectPricingOverrides returns a project's model rates that take precedence
+// over the pricing table (see entity.ProjectSettings.PricingOverrides), or nil
+// when it has none
+func ProjectPricingOverrides(settings entity.ProjectSettings) map[string]ModelPricing {
+	if len(settings.PricingOverrides) == 0 {
+		return nil
+	}
+	overrides := make(map[string]ModelPricing, len(settings.PricingOverrides))
+	for model, o := range settings.PricingOverrides {
+		overrides[model] = ModelPricing{
+			Input:      o.Input,
+			Output:     o.Output,
+			CacheRead:  o.CacheRead,
+			CacheWrite: o.CacheWrite,
+			Reasoning:  o.Reasoning,
+		}
+	}
+	return overrides
+}
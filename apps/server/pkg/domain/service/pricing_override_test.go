@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// TestWithOverrides prices overridden models at the override rates and the
+// rest at the table's.
+func TestWithOverrides(t *testing.T) {
+	calc := NewPricingCalculator()
+	discounted := calc.WithOverrides(ProjectPricingOverrides(entity.ProjectSettings{
+		PricingOverrides: map[string]entity.PricingOverride{
+			"gpt-4o": {Input: 0.00125, Output: 0.005}, // 50% off
+		},
+	}))
+	usage := TokenUsage{Input: 1000, Output: 1000, CacheRead: 1000}
+
+	if got := calc.CalculateCostBreakdown("gpt-4o", usage).Total; got != 0.01375 {
+		t.Errorf("table: gpt-4o cost = %v, want 0.01375", got)
+	}
+	// Cache reads derive from the override's input rate
+	if got := discounted.CalculateCostBreakdown("gpt-4o", usage).Total; got != 0.006875 {
+		t.Errorf("override: gpt-4o cost = %v, want 0.006875", got)
+	}
+	if got := discounted.CalculateCostBreakdown("gpt-4o-2024-08-06", usage).Total; got != 0.006875 {
+		t.Errorf("override: versioned gpt-4o cost = %v, want 0.006875 (prefix match)", got)
+	}
+	if got, want := discounted.CalculateCostBreakdown("gpt-4.1", usage), calc.CalculateCostBreakdown("gpt-4.1", usage); got != want {
+		t.Errorf("gpt-4.1 = %+v, want the table's %+v", got, want)
+	}
+
+	if calc.WithOverrides(nil) != calc {
+		t.Error("no overrides should return the calculator itself")
+	}
+}
+
+func TestWithOverrides_UnknownModel(t *testing.T) {
+	calc := NewPricingCalculator().WithOverrides(map[string]ModelPricing{
+		"acme-llm": {Input: 0.001, Output: 0.002},
+	})
+
+	if !calc.HasPricing("acme-llm-v2") {
+		t.Error("acme-llm-v2: expected the override to price it")
+	}
+	if got := calc.CalculateCost("acme-llm-v2", 1000, 1000); got != 0.003 {
+		t.Errorf("acme-llm-v2 cost = %v, want 0.003", got)
+	}
+	if NewPricingCalculator().HasPricing("acme-llm-v2") {
+		t.Error("overrides must not leak into the shared table")
+	}
+}
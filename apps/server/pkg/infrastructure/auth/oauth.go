@@ -19,9 +19,13 @@ type GoogleUser struct {
 	Picture       string `json:"picture"`
 }
 
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
 // OAuthService handles OAuth operations
 type OAuthService struct {
-	config *oauth2.Config
+	config      *oauth2.Config
+	userInfoURL string
+	states      *oauthStates
 }
 
 // NewOAuthService creates a new OAuth service
@@ -37,23 +41,45 @@ func NewOAuthService(clientID, clientSecret, redirectURL string) *OAuthService {
 			},
 			Endpoint: google.Endpoint,
 		},
+		userInfoURL: googleUserInfoURL,
+		states:      newOAuthStates(),
 	}
 }
 
-// GetAuthURL returns the URL for OAuth authorization
-func (s *OAuthService) GetAuthURL(state string) string {
-	return s.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// SetEndpoint points the service at another Google-compatible provider
+// (e.g. a stub in tests) instead of Google
+func (s *OAuthService) SetEndpoint(authURL, tokenURL, userInfoURL string) {
+	s.config.Endpoint = oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL}
+	s.userInfoURL = userInfoURL
+}
+
+// BeginAuth starts an authorization code flow. It returns the URL to send the
+// user to and the state it carries: a signed, single-use value bound to a PKCE
+// verifier that ExchangeCode requires within 10 minutes.
+func (s *OAuthService) BeginAuth() (authURL, state string, err error) {
+	verifier := oauth2.GenerateVerifier()
+	state, err = s.states.issue(verifier)
+	if err != nil {
+		return "", "", err
+	}
+	return s.config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier)), state, nil
 }
 
-// ExchangeCode exchanges an authorization code for user info
-func (s *OAuthService) ExchangeCode(ctx context.Context, code string) (*GoogleUser, error) {
-	token, err := s.config.Exchange(ctx, code)
+// ExchangeCode exchanges an authorization code for user info. state must be
+// one issued by BeginAuth and not used yet, otherwise it returns ErrInvalidState.
+func (s *OAuthService) ExchangeCode(ctx context.Context, state, code string) (*GoogleUser, error) {
+	verifier, err := s.states.consume(state)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
 
 	client := s.config.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	resp, err := client.Get(s.userInfoURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
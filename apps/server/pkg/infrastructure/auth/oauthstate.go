@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidState = errors.New("invalid OAuth state")
+
+const (
+	oauthStateTTL       = 10 * time.Minute
+	maxPendingOAuthFlow = 10000
+)
+
+// oauthStates issues signed, single-use state values for the authorization
+// code flow and keeps each one's PKCE verifier until the callback. Pending
+// states live in process memory, so a login must complete on the instance
+// that started it. Past maxPendingOAuthFlow the oldest pending state is
+// evicted, so a flood of login starts can't lock new logins out.
+type oauthStates struct {
+	key []byte
+
+	mu      sync.Mutex
+	pending map[string]*list.Element // by nonce; elements hold *pendingOAuthFlow
+	order   *list.List               // oldest first, which is also soonest to expire
+	now     func() time.Time
+}
+
+type pendingOAuthFlow struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+func newOAuthStates() *oauthStates {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &oauthStates{key: key, pending: make(map[string]*list.Element), order: list.New(), now: time.Now}
+}
+
+// issue returns a new state, "<nonce>.<signature>", remembering verifier for it
+func (s *oauthStates) issue(verifier string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		flow := el.Value.(*pendingOAuthFlow)
+		if !now.After(flow.expiresAt) && s.order.Len() < maxPendingOAuthFlow {
+			break
+		}
+		s.order.Remove(el)
+		delete(s.pending, flow.nonce)
+	}
+	s.pending[nonce] = s.order.PushBack(&pendingOAuthFlow{nonce: nonce, verifier: verifier, expiresAt: now.Add(oauthStateTTL)})
+
+	return nonce + "." + s.sign(nonce), nil
+}
+
+// consume validates state and returns its PKCE verifier. A state is accepted
+// once: tampered, unknown, expired and already used states fail with ErrInvalidState.
+func (s *oauthStates) consume(state string) (string, error) {
+	nonce, sig, ok := strings.Cut(state, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(nonce))) {
+		return "", ErrInvalidState
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.pending[nonce]
+	if !ok {
+		return "", ErrInvalidState
+	}
+	s.order.Remove(el)
+	delete(s.pending, nonce)
+	flow := el.Value.(*pendingOAuthFlow)
+	if s.now().After(flow.expiresAt) {
+		return "", ErrInvalidState
+	}
+	return flow.verifier, nil
+}
+
+func (s *oauthStates) sign(nonce string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
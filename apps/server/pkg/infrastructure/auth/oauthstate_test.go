@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOAuthStates_EvictsOldest(t *testing.T) {
+	s := newOAuthStates()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	first, err := s.issue("verifier-first")
+	if err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+	second, err := s.issue("verifier-second")
+	if err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+	// Fill up the pending states: new logins still start, evicting the oldest
+	for i := 2; i < maxPendingOAuthFlow+1; i++ {
+		if _, err := s.issue("verifier"); err != nil {
+			t.Fatalf("issue %d failed: %v", i, err)
+		}
+	}
+	if len(s.pending) != maxPendingOAuthFlow || s.order.Len() != maxPendingOAuthFlow {
+		t.Fatalf("expected %d pending states, got %d (%d ordered)", maxPendingOAuthFlow, len(s.pending), s.order.Len())
+	}
+
+	if _, err := s.consume(first); err != ErrInvalidState {
+		t.Errorf("expected the oldest state evicted, got %v", err)
+	}
+	if verifier, err := s.consume(second); err != nil || verifier != "verifier-second" {
+		t.Errorf("expected the second state kept, got %q (%v)", verifier, err)
+	}
+}
+
+func TestOAuthStates_Expiry(t *testing.T) {
+	s := newOAuthStates()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	expired, _ := s.issue("verifier-expired")
+	now = now.Add(oauthStateTTL + time.Second)
+	fresh, _ := s.issue("verifier-fresh")
+
+	if len(s.pending) != 1 {
+		t.Errorf("expected the expired state dropped on issue, got %d pending", len(s.pending))
+	}
+	if _, err := s.consume(expired); err != ErrInvalidState {
+		t.Errorf("expected the expired state rejected, got %v", err)
+	}
+	if verifier, err := s.consume(fresh); err != nil || verifier != "verifier-fresh" {
+		t.Errorf("expected the fresh state accepted, got %q (%v)", verifier, err)
+	}
+	if _, err := s.consume(fresh); err != ErrInvalidState {
+		t.Errorf("expected a used state rejected, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package buildinfo exposes the version of the running binary. Release builds
+// inject the values with ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/lelemon/server/pkg/infrastructure/buildinfo.Version=1.2.0 \
+//	  -X github.com/lelemon/server/pkg/infrastructure/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/lelemon/server/pkg/infrastructure/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X ..."
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build info. Commit and build time not injected with ldflags
+// fall back to the VCS stamp Go embeds when building from a checkout, then to
+// "unknown".
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -14,13 +14,27 @@ type Config struct {
 	Port        int
 	FrontendURL string
 
+	// Further origins OAuth logins may land on (e.g. https://*.example.com),
+	// such as the frontends organizations set in the enterprise edition
+	FrontendAllowlist []string
+
 	// Logging
 	LogLevel  string // debug, info, warn, error
 	LogFormat string // json, text
 
 	// Database
 	DatabaseURL          string
-	AnalyticsDatabaseURL string // Optional: separate store for traces/spans/analytics
+	ReadDatabaseURL      string            // Optional: read replica of a Postgres DatabaseURL for list and analytics reads
+	AnalyticsDatabaseURL string            // Optional: separate store for traces/spans/analytics
+	AnalyticsShardURLs   []string          // Optional: shard analytics across these stores by project (overrides AnalyticsDatabaseURL)
+	AnalyticsRegionURLs  map[string]string // Optional: region -> analytics store, for projects with a region setting
+	DBStatementTimeout   time.Duration     // Server-side per-query limit (Postgres/ClickHouse)
+
+	// SQLite tuning (ignored by other backends)
+	SQLiteBusyTimeout        time.Duration
+	SQLiteJournalMode        string
+	SQLiteReadConns          int           // Separate read-only pool size (WAL only); 0 = share the writer
+	SQLiteCheckpointInterval time.Duration // PRAGMA wal_checkpoint(TRUNCATE) period; 0 = disabled
 
 	// JWT
 	JWTSecret     string
@@ -31,9 +45,72 @@ type Config struct {
 	GoogleClientSecret string
 	GoogleRedirectURL  string
 
+	// Alerts
+	AlertEvalInterval time.Duration // How often project error-rate alerts are evaluated
+
+	// Ingest
+	IngestMaxClockSkew     time.Duration // Events timestamped further than this from server time are rejected; 0 = disabled
+	IngestClampTimestamps  bool          // Store such events at receive time instead of rejecting them
+	IngestMaxJSONDepth     int           // Events whose input/output/metadata nest deeper are rejected; 0 = disabled
+	IngestTruncateDeepJSON bool          // Store such events with the too-deep values cut instead of rejecting them
+	IngestIDMaxLength      int           // Trace/span IDs longer than this are invalid; 0 = no limit
+	IngestIDCharset        string        // Characters allowed in IDs, as a regexp character class body; empty = any
+	IngestIDFormat         string        // "uuid" or "ulid" to require that format; empty = any
+	IngestIDStrict         bool          // Reject events with an invalid ID instead of ingesting them without it
+
+	// Async ingest workers
+	IngestWorkers           int           // Base workers, always running
+	IngestMaxWorkers        int           // Autoscaling limit; at or below IngestWorkers = no autoscaling
+	IngestScaleUpQueueDepth int           // Queued jobs above which burst workers are started
+	IngestWorkerIdleTimeout time.Duration // Burst workers exit after this long without a job
+	IngestWALPath           string        // Write-ahead log of queued jobs, replayed on start; empty = disabled
+
+	// Ingest auth for telemetry agents (routes relative to /api/v1, e.g. "/ingest")
+	IngestAuthSchemes    map[string][]string // Route -> accepted schemes (apikey, bearer, mtls); unlisted routes take API keys
+	IngestBearerTokens   map[string]string   // Operator-issued token -> project ID
+	IngestClientSubjects map[string]string   // Client certificate subject CN -> project ID
+	IngestSubjectHeader  string              // Header a TLS-terminating proxy sets to the verified client subject
+	IngestNoCORSRoutes   []string            // Server-only ingest routes served without CORS
+
+	// Stuck traces
+	TraceInactivityTimeout time.Duration // Active traces with no new span for this long are marked error; 0 = disabled
+	TraceReapInterval      time.Duration // How often stale active traces are looked for
+	TraceMaxSpans          int           // Spans returned with a trace; the rest are paged with ListSpans. 0 = no cap
+
+	// Analytics
+	AnalyticsMaxPoints int // Usage/latency time series points; finer granularities are coarsened to fit. 0 = no cap
+
+	// Maintenance
+	BackfillSpanTools bool // Derive sub_type/tool_uses of llm spans stored before those columns existed, at startup (SQLite)
+
+	// Pricing
+	PricingModelAliases   map[string]string  // Model alias (e.g. a deployment name) -> base model it is priced as
+	PricingFineTuneFactor float64            // Multiplier on the base model's rates for fine-tuned models; 1 = base rate
+	CurrencyRates         map[string]float64 // Currency code -> units per USD, for projects showing costs in another currency
+
+	// OpenAI-compatible proxy (disabled unless OpenAIProxyEnabled)
+	OpenAIProxyEnabled  bool
+	OpenAIProxyUpstream string // Base URL requests are forwarded to
+	// Headers passed through the proxy; nil keeps proxy.DefaultRequestHeaders
+	// and proxy.DefaultResponseHeaders
+	OpenAIProxyReqHeaders  []string
+	OpenAIProxyRespHeaders []string
+
+	// Trace exports (S3; disabled when ExportS3Bucket is empty)
+	ExportS3Bucket          string
+	ExportS3Prefix          string // Key prefix, e.g. "lelemon/exports/"
+	ExportS3Region          string
+	ExportS3Endpoint        string // S3-compatible endpoint (MinIO, R2); empty = AWS
+	ExportS3AccessKeyID     string // Empty = default AWS credential chain
+	ExportS3SecretAccessKey string
+
 	// Security
-	AllowedOrigins []string // CORS allowed origins (empty = allow FrontendURL only)
-	Environment    string   // development, staging, production
+	AllowedOrigins     []string // CORS allowed origins (empty = allow FrontendURL only)
+	Environment        string   // development, staging, production
+	AdminAPIToken      string   // Bearer token for operator routes (/admin/...); empty = routes disabled
+	MaxBodyBytes       int64    // Request body limit
+	IngestMaxBodyBytes int64    // Request body limit of the ingest routes (ingest, OTLP, proxy, trace import)
+	RateLimits         []string // Rate limit policies replacing the defaults of the same name (see middleware.ParseRateLimitPolicies)
 }
 
 // Load loads configuration from environment variables
@@ -48,6 +125,39 @@ func Load() *Config {
 		allowedOrigins = []string{frontendURL}
 	}
 
+	// Parse ingest auth schemes: "/ingest=apikey|bearer,/ingest/dry-run=apikey"
+	ingestAuthSchemes := make(map[string][]string)
+	for route, schemes := range getEnvMap("INGEST_AUTH_SCHEMES", ",") {
+		for _, scheme := range strings.Split(schemes, "|") {
+			switch scheme = strings.TrimSpace(scheme); scheme {
+			case "apikey", "bearer", "mtls":
+				ingestAuthSchemes[route] = append(ingestAuthSchemes[route], scheme)
+			default:
+				log.Fatalf("FATAL: INGEST_AUTH_SCHEMES: unknown scheme %q for %s (want apikey, bearer or mtls)", scheme, route)
+			}
+		}
+	}
+
+	fineTuneFactor := getEnvFloat("PRICING_FINETUNE_MULTIPLIER", 1)
+	if fineTuneFactor <= 0 {
+		log.Fatalf("FATAL: PRICING_FINETUNE_MULTIPLIER must be positive, got %v", fineTuneFactor)
+	}
+
+	// Parse exchange rates: "EUR=0.92,GBP=0.79"
+	currencyRates := make(map[string]float64)
+	for code, value := range getEnvMap("CURRENCY_RATES", ",") {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			log.Fatalf("FATAL: CURRENCY_RATES: rate for %s must be a positive number, got %q", code, value)
+		}
+		currencyRates[strings.ToUpper(code)] = rate
+	}
+
+	ingestWorkers := getEnvInt("INGEST_WORKERS", 4)
+	if ingestWorkers < 1 {
+		log.Fatalf("FATAL: INGEST_WORKERS must be at least 1, got %d", ingestWorkers)
+	}
+
 	// Validate JWT_SECRET in production
 	jwtSecret := getEnv("JWT_SECRET", "change-me-in-production-please")
 	if env == "production" {
@@ -57,19 +167,69 @@ func Load() *Config {
 	}
 
 	return &Config{
-		Port:                 getEnvInt("PORT", 8080),
-		FrontendURL:          frontendURL,
-		LogLevel:             getEnv("LOG_LEVEL", "info"),
-		LogFormat:            getEnv("LOG_FORMAT", "json"),
-		DatabaseURL:          getEnv("DATABASE_URL", "sqlite://./data/lelemon.db"),
-		AnalyticsDatabaseURL: getEnv("ANALYTICS_DATABASE_URL", ""),
-		JWTSecret:            jwtSecret,
-		JWTExpiration:        getEnvDuration("JWT_EXPIRATION", 24*7*time.Hour), // 7 days
-		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:    getEnv("GOOGLE_REDIRECT_URL", baseURL+"/api/v1/auth/google/callback"),
-		AllowedOrigins:       allowedOrigins,
-		Environment:          env,
+		Port:                     getEnvInt("PORT", 8080),
+		FrontendURL:              frontendURL,
+		FrontendAllowlist:        getEnvList("FRONTEND_ALLOWLIST", ","),
+		LogLevel:                 getEnv("LOG_LEVEL", "info"),
+		LogFormat:                getEnv("LOG_FORMAT", "json"),
+		DatabaseURL:              getEnv("DATABASE_URL", "sqlite://./data/lelemon.db"),
+		ReadDatabaseURL:          getEnv("READ_DATABASE_URL", ""),
+		AnalyticsDatabaseURL:     getEnv("ANALYTICS_DATABASE_URL", ""),
+		AnalyticsShardURLs:       getEnvList("ANALYTICS_SHARD_URLS", ","),
+		AnalyticsRegionURLs:      getEnvMap("ANALYTICS_REGION_URLS", ","),
+		DBStatementTimeout:       getEnvDuration("DB_STATEMENT_TIMEOUT", 60*time.Second),
+		SQLiteBusyTimeout:        getEnvDuration("SQLITE_BUSY_TIMEOUT", 5*time.Second),
+		SQLiteJournalMode:        getEnv("SQLITE_JOURNAL_MODE", "WAL"),
+		SQLiteReadConns:          getEnvInt("SQLITE_READ_CONNS", 0),
+		SQLiteCheckpointInterval: getEnvDuration("SQLITE_CHECKPOINT_INTERVAL", 5*time.Minute),
+		JWTSecret:                jwtSecret,
+		JWTExpiration:            getEnvDuration("JWT_EXPIRATION", 24*7*time.Hour), // 7 days
+		GoogleClientID:           getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:       getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL:        getEnv("GOOGLE_REDIRECT_URL", baseURL+"/api/v1/auth/google/callback"),
+		AlertEvalInterval:        getEnvDuration("ALERT_EVAL_INTERVAL", time.Minute),
+		IngestMaxClockSkew:       getEnvDuration("INGEST_MAX_CLOCK_SKEW", 0),
+		IngestClampTimestamps:    getEnv("INGEST_CLAMP_TIMESTAMPS", "false") == "true",
+		IngestMaxJSONDepth:       getEnvInt("INGEST_MAX_JSON_DEPTH", 64),
+		IngestTruncateDeepJSON:   getEnv("INGEST_TRUNCATE_DEEP_JSON", "false") == "true",
+		IngestIDMaxLength:        getEnvInt("INGEST_ID_MAX_LENGTH", 0),
+		IngestIDCharset:          getEnv("INGEST_ID_CHARSET", ""),
+		IngestIDFormat:           getEnv("INGEST_ID_FORMAT", ""),
+		IngestIDStrict:           getEnv("INGEST_ID_STRICT", "false") == "true",
+		IngestWorkers:            ingestWorkers,
+		IngestMaxWorkers:         getEnvInt("INGEST_MAX_WORKERS", 0),
+		IngestScaleUpQueueDepth:  getEnvInt("INGEST_SCALE_UP_QUEUE_DEPTH", 100),
+		IngestWorkerIdleTimeout:  getEnvDuration("INGEST_WORKER_IDLE_TIMEOUT", 30*time.Second),
+		IngestWALPath:            getEnv("INGEST_WAL_PATH", ""),
+		IngestAuthSchemes:        ingestAuthSchemes,
+		IngestBearerTokens:       getEnvMap("INGEST_BEARER_TOKENS", ","),
+		IngestClientSubjects:     getEnvMap("INGEST_MTLS_SUBJECTS", ","),
+		IngestSubjectHeader:      getEnv("INGEST_MTLS_SUBJECT_HEADER", ""),
+		IngestNoCORSRoutes:       getEnvList("INGEST_NO_CORS", ","),
+		TraceInactivityTimeout:   getEnvDuration("TRACE_INACTIVITY_TIMEOUT", 0),
+		TraceReapInterval:        getEnvDuration("TRACE_REAP_INTERVAL", time.Minute),
+		TraceMaxSpans:            getEnvInt("TRACE_MAX_SPANS", 5000),
+		AnalyticsMaxPoints:       getEnvInt("ANALYTICS_MAX_POINTS", 1000),
+		BackfillSpanTools:        getEnv("BACKFILL_SPAN_TOOLS", "false") == "true",
+		PricingModelAliases:      getEnvMap("PRICING_MODEL_ALIASES", ","),
+		PricingFineTuneFactor:    fineTuneFactor,
+		CurrencyRates:            currencyRates,
+		OpenAIProxyEnabled:       getEnv("OPENAI_PROXY_ENABLED", "false") == "true",
+		OpenAIProxyUpstream:      getEnv("OPENAI_PROXY_UPSTREAM", "https://api.openai.com"),
+		OpenAIProxyReqHeaders:    getEnvList("OPENAI_PROXY_REQUEST_HEADERS", ","),
+		OpenAIProxyRespHeaders:   getEnvList("OPENAI_PROXY_RESPONSE_HEADERS", ","),
+		ExportS3Bucket:           getEnv("EXPORT_S3_BUCKET", ""),
+		ExportS3Prefix:           getEnv("EXPORT_S3_PREFIX", "exports/"),
+		ExportS3Region:           getEnv("EXPORT_S3_REGION", ""),
+		ExportS3Endpoint:         getEnv("EXPORT_S3_ENDPOINT", ""),
+		ExportS3AccessKeyID:      getEnv("EXPORT_S3_ACCESS_KEY_ID", ""),
+		ExportS3SecretAccessKey:  getEnv("EXPORT_S3_SECRET_ACCESS_KEY", ""),
+		AllowedOrigins:           allowedOrigins,
+		Environment:              env,
+		AdminAPIToken:            getEnv("ADMIN_API_TOKEN", ""),
+		MaxBodyBytes:             int64(getEnvInt("MAX_BODY_BYTES", 1<<20)),
+		IngestMaxBodyBytes:       int64(getEnvInt("INGEST_MAX_BODY_BYTES", 5<<20)),
+		RateLimits:               getEnvList("RATE_LIMITS", ";"),
 	}
 }
 
@@ -89,6 +249,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if d, err := time.ParseDuration(value); err == nil {
@@ -98,6 +267,18 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvMap parses sep-separated key=value pairs; entries without "=" are skipped
+func getEnvMap(key, sep string) map[string]string {
+	result := make(map[string]string)
+	for _, entry := range getEnvList(key, sep) {
+		k, v, ok := strings.Cut(entry, "=")
+		if k, v = strings.TrimSpace(k), strings.TrimSpace(v); ok && k != "" && v != "" {
+			result[k] = v
+		}
+	}
+	return result
+}
+
 func getEnvList(key, sep string) []string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -0,0 +1,77 @@
+// Package objectstore implements export destinations (see export.ObjectStore)
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Config configures the S3 backend. Credentials fall back to the default AWS
+// chain (env, shared config, instance role) when AccessKeyID is empty.
+type S3Config struct {
+	Bucket          string
+	Region          string
+	Endpoint        string // optional, for S3-compatible stores (MinIO, R2); enables path-style addressing
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3 uploads objects to an S3 bucket with multipart uploads, so bodies of
+// unknown length are streamed in parts rather than buffered.
+type S3 struct {
+	bucket   string
+	uploader *transfermanager.Client
+}
+
+// NewS3 creates an S3 object store
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("s3: load config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3{bucket: cfg.Bucket, uploader: transfermanager.New(client)}, nil
+}
+
+// Put streams body to s3://bucket/key as gzip-encoded NDJSON
+func (s *S3) Put(ctx context.Context, key string, body io.Reader) error {
+	_, err := s.uploader.UploadObject(ctx, &transfermanager.UploadObjectInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		Body:            body,
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	if err != nil {
+		return fmt.Errorf("s3: upload %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBackends(t *testing.T) {
+	tests := []struct {
+		urls []string
+		want []string
+	}{
+		{[]string{"sqlite:///data/lelemon.db"}, []string{"sqlite"}},
+		{[]string{"/data/lelemon.db"}, []string{"sqlite"}},
+		{[]string{"postgresql://db/lelemon", ""}, []string{"postgres"}},
+		{[]string{"clickhouse://a:9000", "clickhouses://b:9440", "postgres://c/lelemon"}, []string{"clickhouse", "postgres"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if got := Backends(tt.urls...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Backends(%v) = %v, want %v", tt.urls, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// Trace and span IDs are keyed as UUIDs, but clients may send IDs of any
+// format (e.g. "contract-span-001") and get them back exactly as sent. Such
+// IDs are keyed by a UUID derived from them (UUIDv5), so lookups by the
+// client's ID find the row, and kept verbatim in the client_* columns, which
+// reads prefer to the UUID's string form.
+
+// clientIDNamespace is the UUIDv5 namespace client IDs are derived in
+var clientIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://lelemon.dev/ids"))
+
+// idUUID returns the UUID id is keyed by: id itself when it parses as one
+func idUUID(id string) uuid.UUID {
+	if u, err := uuid.Parse(id); err == nil {
+		return u
+	}
+	return uuid.NewSHA1(clientIDNamespace, []byte(id))
+}
+
+// clientID returns id to store next to its UUID, or nil when the UUID's
+// string form reads back as id
+func clientID(id string, u uuid.UUID) *string {
+	if id == u.String() {
+		return nil
+	}
+	return &id
+}
+
+// idUUIDs maps ids to the UUIDs they are keyed by
+func idUUIDs(ids []string) []uuid.UUID {
+	uuids := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		uuids[i] = idUUID(id)
+	}
+	return uuids
+}
+
+// spanIDs holds the keys of a span's ID, trace ID and parent span ID, and
+// the client IDs stored next to them
+type spanIDs struct {
+	id, traceID                           uuid.UUID
+	parentSpanID                          *uuid.UUID
+	clientID, clientTraceID, clientParent *string
+}
+
+func newSpanIDs(span *entity.Span) spanIDs {
+	ids := spanIDs{id: idUUID(span.ID), traceID: idUUID(span.TraceID)}
+	ids.clientID = clientID(span.ID, ids.id)
+	ids.clientTraceID = clientID(span.TraceID, ids.traceID)
+	if span.ParentSpanID != nil {
+		parent := idUUID(*span.ParentSpanID)
+		ids.parentSpanID = &parent
+		ids.clientParent = clientID(*span.ParentSpanID, parent)
+	}
+	return ids
+}
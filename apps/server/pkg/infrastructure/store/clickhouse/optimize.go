@@ -0,0 +1,54 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// optimizableTables are the ReplacingMergeTree tables read with FINAL, whose
+// queries slow down as unmerged parts accumulate
+var optimizableTables = map[string]bool{"traces": true, "projects": true, "users": true}
+
+// OptimizeTable forces a merge of table's parts with OPTIMIZE TABLE ... FINAL,
+// e.g. after a bulk import, and reports its active part counts before and
+// after. The merge runs synchronously and rewrites the whole table.
+func (s *Store) OptimizeTable(ctx context.Context, table string) (*entity.TableOptimization, error) {
+	if !optimizableTables[table] {
+		return nil, fmt.Errorf("%w: table %q is not optimizable", entity.ErrBadRequest, table)
+	}
+
+	result := &entity.TableOptimization{Table: table}
+	var err error
+	if result.PartsBefore, err = s.activeParts(ctx, table); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	// The table name can't be bound; it is one of optimizableTables
+	if err := s.conn.Exec(ctx, fmt.Sprintf("OPTIMIZE TABLE %s FINAL", table)); err != nil {
+		return nil, fmt.Errorf("optimize %s: %w", table, err)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if result.PartsAfter, err = s.activeParts(ctx, table); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// activeParts counts the active data parts of a table in the store's database
+func (s *Store) activeParts(ctx context.Context, table string) (int, error) {
+	var parts uint64
+	err := s.conn.QueryRow(ctx, `
+		SELECT count()
+		FROM system.parts
+		WHERE database = currentDatabase() AND table = ? AND active
+	`, table).Scan(&parts)
+	if err != nil {
+		return 0, fmt.Errorf("count %s parts: %w", table, err)
+	}
+	return int(parts), nil
+}
@@ -2,8 +2,10 @@ package clickhouse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -286,6 +288,67 @@ func TestClickHouseTraceOperations(t *testing.T) {
 	})
 }
 
+func TestClickHouseDeleteCounts(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	project := &entity.Project{
+		Name:       "Delete Test",
+		APIKey:     fmt.Sprintf("le_delete_%d", time.Now().UnixNano()),
+		APIKeyHash: "delete_hash",
+		OwnerEmail: "delete@example.com",
+	}
+	store.CreateProject(ctx, project)
+
+	for i := 0; i < 5; i++ {
+		status := entity.TraceStatusCompleted
+		if i < 2 {
+			status = entity.TraceStatusError
+		}
+		store.CreateTrace(ctx, &entity.Trace{ProjectID: project.ID, Status: status})
+	}
+
+	countTraces := func(filter entity.TraceFilter) int {
+		t.Helper()
+		filter.Limit = 100
+		page, err := store.ListTraces(ctx, project.ID, filter)
+		if err != nil {
+			t.Fatalf("ListTraces failed: %v", err)
+		}
+		return page.Total
+	}
+
+	t.Run("delete by filter returns the matching count", func(t *testing.T) {
+		errored := entity.TraceStatusError
+		filter := entity.TraceFilter{Status: &errored}
+		before := countTraces(filter)
+
+		deleted, err := store.DeleteTracesByFilter(ctx, project.ID, filter)
+		if err != nil {
+			t.Fatalf("DeleteTracesByFilter failed: %v", err)
+		}
+		if deleted != int64(before) || before != 2 {
+			t.Errorf("deleted count: got %d, want %d (pre-delete count, expected 2)", deleted, before)
+		}
+	})
+
+	t.Run("delete all returns the pre-delete count", func(t *testing.T) {
+		// The previous mutation runs asynchronously; wait for it to land
+		time.Sleep(500 * time.Millisecond)
+		before := countTraces(entity.TraceFilter{})
+
+		deleted, err := store.DeleteAllTraces(ctx, project.ID)
+		if err != nil {
+			t.Fatalf("DeleteAllTraces failed: %v", err)
+		}
+		if deleted != int64(before) || before == 0 {
+			t.Errorf("deleted count: got %d, want %d", deleted, before)
+		}
+	})
+}
+
 func TestClickHouseSpanOperations(t *testing.T) {
 	store := setupTestStore(t)
 	defer store.Close()
@@ -324,7 +387,7 @@ func TestClickHouseSpanOperations(t *testing.T) {
 			StartedAt:    time.Now(),
 		}
 
-		if err := store.CreateSpan(ctx, span); err != nil {
+		if err := store.CreateSpan(ctx, project.ID, span); err != nil {
 			t.Fatalf("CreateSpan failed: %v", err)
 		}
 
@@ -358,7 +421,7 @@ func TestClickHouseSpanOperations(t *testing.T) {
 			},
 		}
 
-		if err := store.CreateSpans(ctx, spans); err != nil {
+		if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
 			t.Fatalf("CreateSpans failed: %v", err)
 		}
 
@@ -401,7 +464,7 @@ func TestClickHouseSpanOperations(t *testing.T) {
 				StartedAt:    time.Now(),
 			},
 		}
-		store.CreateSpans(ctx, spans)
+		store.CreateSpans(ctx, project.ID, spans)
 
 		got, _ := store.GetTrace(ctx, project.ID, newTrace.ID)
 
@@ -444,7 +507,7 @@ func TestClickHouseAnalytics(t *testing.T) {
 		}
 		store.CreateTrace(ctx, trace)
 
-		store.CreateSpan(ctx, &entity.Span{
+		store.CreateSpan(ctx, project.ID, &entity.Span{
 			TraceID:      trace.ID,
 			Type:         entity.SpanTypeLLM,
 			Name:         fmt.Sprintf("span-%d", i),
@@ -544,7 +607,7 @@ func TestClickHouseSessions(t *testing.T) {
 		}
 		store.CreateTrace(ctx, trace)
 
-		store.CreateSpan(ctx, &entity.Span{
+		store.CreateSpan(ctx, project.ID, &entity.Span{
 			TraceID:      trace.ID,
 			Type:         entity.SpanTypeLLM,
 			Name:         fmt.Sprintf("span-%d", i),
@@ -580,3 +643,278 @@ func TestClickHouseSessions(t *testing.T) {
 func ptr(i int) *int          { return &i }
 func ptrS(s string) *string   { return &s }
 func ptrF(f float64) *float64 { return &f }
+
+func TestClickHouseTracesMetrics(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	project := &entity.Project{
+		Name:       "Metrics Test",
+		APIKey:     fmt.Sprintf("le_metrics_%d", time.Now().UnixNano()),
+		APIKeyHash: "metrics_hash",
+		OwnerEmail: "metrics@example.com",
+	}
+	store.CreateProject(ctx, project)
+
+	var traceIDs []string
+	for i := 0; i < 3; i++ {
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		store.CreateTrace(ctx, trace)
+		traceIDs = append(traceIDs, trace.ID)
+
+		// Trace i gets i spans: the first has none
+		var spans []entity.Span
+		for j := 0; j < i; j++ {
+			spans = append(spans, entity.Span{
+				TraceID:      trace.ID,
+				Type:         entity.SpanTypeLLM,
+				Name:         "chat",
+				Status:       entity.SpanStatusSuccess,
+				InputTokens:  ptr(10 * (j + 1)),
+				OutputTokens: ptr(5),
+				DurationMs:   ptr(100 * (i + j)),
+				CostUSD:      ptrF(0.25 * float64(j+1)),
+				StartedAt:    time.Now(),
+			})
+		}
+		if len(spans) > 0 {
+			if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
+				t.Fatalf("CreateSpans failed: %v", err)
+			}
+		}
+	}
+
+	metrics, err := store.GetTracesMetrics(ctx, project.ID, traceIDs)
+	if err != nil {
+		t.Fatalf("GetTracesMetrics failed: %v", err)
+	}
+	if len(metrics) != len(traceIDs) {
+		t.Errorf("expected metrics for %d traces, got %v", len(traceIDs), metrics)
+	}
+	for _, id := range traceIDs {
+		trace, err := store.GetTrace(ctx, project.ID, id)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		want := entity.TraceMetrics{
+			TotalSpans:      trace.TotalSpans,
+			TotalTokens:     trace.TotalTokens,
+			TotalCostUSD:    trace.TotalCostUSD,
+			TotalDurationMs: trace.TotalDurationMs,
+		}
+		if got := metrics[id]; got != want {
+			t.Errorf("trace %s: expected %+v as from GetTrace, got %+v", id, want, got)
+		}
+	}
+}
+
+func TestClickHouseOptimizeTable(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	project := &entity.Project{
+		Name:       "Optimize Test",
+		APIKey:     fmt.Sprintf("le_optimize_%d", time.Now().UnixNano()),
+		APIKeyHash: "optimize_hash",
+		OwnerEmail: "optimize@example.com",
+	}
+	store.CreateProject(ctx, project)
+
+	// Every insert and status update writes a part; hold background merges
+	// so they accumulate, as under heavy update load
+	if err := store.conn.Exec(ctx, "SYSTEM STOP MERGES traces"); err != nil {
+		t.Fatalf("stop merges failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusActive}
+		if err := store.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("CreateTrace failed: %v", err)
+		}
+		if err := store.UpdateTraceStatus(ctx, project.ID, trace.ID, entity.TraceStatusCompleted); err != nil {
+			t.Fatalf("UpdateTraceStatus failed: %v", err)
+		}
+	}
+	if err := store.conn.Exec(ctx, "SYSTEM START MERGES traces"); err != nil {
+		t.Fatalf("start merges failed: %v", err)
+	}
+
+	t.Run("merges the parts", func(t *testing.T) {
+		result, err := store.OptimizeTable(ctx, "traces")
+		if err != nil {
+			t.Fatalf("OptimizeTable failed: %v", err)
+		}
+		if result.PartsBefore <= 1 || result.PartsAfter != 1 {
+			t.Errorf("expected several parts merged into one, got %d -> %d", result.PartsBefore, result.PartsAfter)
+		}
+
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{Limit: 100})
+		if err != nil {
+			t.Fatalf("ListTraces failed: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("expected 5 traces after the merge, got %d", page.Total)
+		}
+	})
+
+	t.Run("rejects other tables", func(t *testing.T) {
+		if _, err := store.OptimizeTable(ctx, "spans; DROP TABLE traces"); !errors.Is(err, entity.ErrBadRequest) {
+			t.Errorf("expected ErrBadRequest, got %v", err)
+		}
+	})
+}
+
+func TestClickHouseClientIDs(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	project := &entity.Project{
+		Name:       "Client ID Project",
+		APIKey:     fmt.Sprintf("le_client_ids_%d", time.Now().UnixNano()),
+		APIKeyHash: "client_ids_hash",
+		OwnerEmail: "client-ids@example.com",
+	}
+	if err := store.CreateProject(ctx, project); err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+
+	// IDs as the contract tests send them, one trace per run
+	traceID := fmt.Sprintf("contract-trace-%d", time.Now().UnixNano())
+	parentID := "contract-span-001"
+	start := time.Now()
+	spans := []entity.Span{
+		{ID: parentID, TraceID: traceID, Type: entity.SpanTypeAgent, Name: "agent", Status: entity.SpanStatusSuccess, StartedAt: start},
+		{ID: "contract-span-002", TraceID: traceID, ParentSpanID: ptrS(parentID), Type: entity.SpanTypeLLM, Name: "chat",
+			Status: entity.SpanStatusSuccess, StartedAt: start.Add(time.Millisecond), InputTokens: ptr(10), CostUSD: ptrF(0.5),
+			Attributes: map[string]string{"env": "contract"}},
+	}
+	trace := &entity.Trace{ID: traceID, ProjectID: project.ID, Status: entity.TraceStatusActive}
+	if _, err := store.CreateTracesWithSpans(ctx, project.ID, []*entity.Trace{trace}, spans); err != nil {
+		t.Fatalf("CreateTracesWithSpans failed: %v", err)
+	}
+
+	t.Run("get trace returns the IDs as sent", func(t *testing.T) {
+		got, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		if got.ID != traceID {
+			t.Errorf("trace ID = %q, want %q", got.ID, traceID)
+		}
+		if len(got.Spans) != 2 {
+			t.Fatalf("expected 2 spans, got %d", len(got.Spans))
+		}
+		if got.Spans[0].ID != parentID || got.Spans[0].TraceID != traceID || got.Spans[0].ParentSpanID != nil {
+			t.Errorf("unexpected root span IDs: %+v", got.Spans[0])
+		}
+		child := got.Spans[1]
+		if child.ID != "contract-span-002" || child.ParentSpanID == nil || *child.ParentSpanID != parentID {
+			t.Errorf("unexpected child span IDs: id %q, parent %v", child.ID, child.ParentSpanID)
+		}
+	})
+
+	t.Run("lists and metrics are keyed by the sent ID", func(t *testing.T) {
+		page, err := store.ListTraces(ctx, project.ID, entity.TraceFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("ListTraces failed: %v", err)
+		}
+		if len(page.Data) != 1 || page.Data[0].ID != traceID || page.Data[0].TotalSpans != 2 {
+			t.Errorf("expected the trace with its 2 spans, got %+v", page.Data)
+		}
+
+		metrics, err := store.GetTracesMetrics(ctx, project.ID, []string{traceID})
+		if err != nil {
+			t.Fatalf("GetTracesMetrics failed: %v", err)
+		}
+		if metrics[traceID].TotalSpans != 2 {
+			t.Errorf("expected metrics for %s, got %+v", traceID, metrics)
+		}
+
+		found, err := store.SearchSpansByAttribute(ctx, project.ID, "env", "contract", entity.SpanFilter{})
+		if err != nil {
+			t.Fatalf("SearchSpansByAttribute failed: %v", err)
+		}
+		if len(found.Data) != 1 || found.Data[0].ID != "contract-span-002" {
+			t.Errorf("expected contract-span-002 by attribute, got %+v", found.Data)
+		}
+	})
+
+	t.Run("updates keep the sent ID", func(t *testing.T) {
+		if err := store.UpdateTraceStatus(ctx, project.ID, traceID, entity.TraceStatusCompleted); err != nil {
+			t.Fatalf("UpdateTraceStatus failed: %v", err)
+		}
+		got, err := store.GetTrace(ctx, project.ID, traceID)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		if got.ID != traceID || got.Status != entity.TraceStatusCompleted {
+			t.Errorf("expected %s completed, got %s %s", traceID, got.ID, got.Status)
+		}
+	})
+}
+
+func TestIDUUID(t *testing.T) {
+	canonical := "0b6e2d4a-8c1f-4e3b-9a7d-5f2c1e8b4a60"
+
+	if u := idUUID(canonical); u.String() != canonical || clientID(canonical, u) != nil {
+		t.Errorf("expected a UUID ID keyed as itself with no client ID, got %s", u)
+	}
+	// Parses as a UUID but doesn't read back the same: keep it as sent
+	upper := strings.ToUpper(canonical)
+	if u := idUUID(upper); u.String() != canonical || clientID(upper, u) == nil || *clientID(upper, u) != upper {
+		t.Errorf("expected %s keyed as %s and kept as sent", upper, canonical)
+	}
+
+	a, b := idUUID("contract-span-001"), idUUID("contract-span-001")
+	if a != b {
+		t.Errorf("expected the same UUID for the same ID, got %s and %s", a, b)
+	}
+	if a == idUUID("contract-span-002") {
+		t.Error("expected different UUIDs for different IDs")
+	}
+	if id := clientID("contract-span-001", a); id == nil || *id != "contract-span-001" {
+		t.Errorf("expected the client ID kept, got %v", id)
+	}
+
+	ids := newSpanIDs(&entity.Span{ID: "child", TraceID: "trace", ParentSpanID: ptrS("parent")})
+	if ids.parentSpanID == nil || *ids.parentSpanID != idUUID("parent") || *ids.clientParent != "parent" {
+		t.Errorf("expected the parent keyed like the parent span, got %+v", ids)
+	}
+}
+
+// Non-UUID project IDs fail before any query, so no server is needed
+func TestClickHouseInvalidProjectIDs(t *testing.T) {
+	ctx := context.Background()
+	store := &Store{}
+
+	if err := store.CreateTrace(ctx, &entity.Trace{ID: "trace-1", ProjectID: "project-1"}); err == nil {
+		t.Error("CreateTrace: expected an error for a non-UUID project ID")
+	}
+	if _, err := store.GetStats(ctx, "project-1", entity.AnalyticsQuery{}); err == nil {
+		t.Error("GetStats: expected an error for a non-UUID project ID")
+	}
+	if _, err := store.GetProjectByID(ctx, ""); !errors.Is(err, entity.ErrNotFound) {
+		t.Errorf("GetProjectByID: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMaxExecutionSeconds(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    int
+	}{
+		{100 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{60 * time.Second, 60},
+	}
+	for _, tt := range tests {
+		if got := maxExecutionSeconds(tt.timeout); got != tt.want {
+			t.Errorf("maxExecutionSeconds(%v) = %d, want %d", tt.timeout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// optimizeStores optimizes table on each of stores that supports it, in turn,
+// and sums their part counts and durations; nil when none does
+func optimizeStores(ctx context.Context, table string, stores []repository.Store) (*entity.TableOptimization, error) {
+	var total *entity.TableOptimization
+	for _, st := range stores {
+		optimizer, ok := st.(repository.TableOptimizer)
+		if !ok {
+			continue
+		}
+		result, err := optimizer.OptimizeTable(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			continue
+		}
+		if total == nil {
+			total = &entity.TableOptimization{Table: table}
+		}
+		total.PartsBefore += result.PartsBefore
+		total.PartsAfter += result.PartsAfter
+		total.DurationMs += result.DurationMs
+	}
+	return total, nil
+}
@@ -61,7 +61,7 @@ func (s *Store) migrateOAuth(ctx context.Context) error {
 		`CREATE INDEX IF NOT EXISTS idx_mcp_refresh_subject ON mcp_refresh_tokens(subject_key, client_id)`,
 	}
 	for _, m := range migrations {
-		if _, err := s.pool.Exec(ctx, m); err != nil {
+		if _, err := s.maint.Exec(ctx, m); err != nil {
 			return fmt.Errorf("oauth migration failed: %w", err)
 		}
 	}
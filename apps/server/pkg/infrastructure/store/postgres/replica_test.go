@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+var errFakePool = errors.New("fake pool")
+
+// fakePool counts the queries sent to it and fails them all
+type fakePool struct {
+	queries int
+	closed  bool
+}
+
+func (p *fakePool) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	p.queries++
+	return pgconn.CommandTag{}, errFakePool
+}
+
+func (p *fakePool) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	p.queries++
+	return nil, errFakePool
+}
+
+func (p *fakePool) QueryRow(context.Context, string, ...any) pgx.Row {
+	p.queries++
+	return fakeRow{}
+}
+
+func (p *fakePool) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	p.queries++
+	return fakeBatch{}
+}
+
+func (p *fakePool) Begin(context.Context) (pgx.Tx, error) {
+	p.queries++
+	return nil, errFakePool
+}
+
+func (p *fakePool) Ping(context.Context) error { return errFakePool }
+func (p *fakePool) Close()                     { p.closed = true }
+
+type fakeRow struct{}
+
+func (fakeRow) Scan(...any) error { return errFakePool }
+
+type fakeBatch struct{}
+
+func (fakeBatch) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, errFakePool }
+func (fakeBatch) Query() (pgx.Rows, error)         { return nil, errFakePool }
+func (fakeBatch) QueryRow() pgx.Row                { return fakeRow{} }
+func (fakeBatch) Close() error                     { return errFakePool }
+
+func TestReadReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+	const projectID = "0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f11"
+	period := entity.AnalyticsQuery{}
+
+	tests := []struct {
+		name    string
+		call    func(s *Store) error
+		replica bool
+	}{
+		{"ListTraces", func(s *Store) error {
+			_, err := s.ListTraces(ctx, projectID, entity.TraceFilter{Limit: 10})
+			return err
+		}, true},
+		{"SearchSpans", func(s *Store) error {
+			_, err := s.SearchSpans(ctx, projectID, entity.SpanFilter{Limit: 10})
+			return err
+		}, true},
+		{"ListSessions", func(s *Store) error {
+			_, err := s.ListSessions(ctx, projectID, entity.SessionFilter{Limit: 10})
+			return err
+		}, true},
+		{"GetStats", func(s *Store) error {
+			_, err := s.GetStats(ctx, projectID, period)
+			return err
+		}, true},
+		{"GetModelStats", func(s *Store) error {
+			_, err := s.GetModelStats(ctx, projectID, period)
+			return err
+		}, true},
+		// Reads that must see a write made just before stay on the primary
+		{"GetTrace", func(s *Store) error {
+			_, err := s.GetTrace(ctx, projectID, "0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f12")
+			return err
+		}, false},
+		{"GetProjectByAPIKeyHash", func(s *Store) error {
+			_, err := s.GetProjectByAPIKeyHash(ctx, "hash")
+			return err
+		}, false},
+		{"GetUserByEmail", func(s *Store) error {
+			_, err := s.GetUserByEmail(ctx, "replica@test.com")
+			return err
+		}, false},
+		{"CreateTracesWithSpans", func(s *Store) error {
+			_, err := s.CreateTracesWithSpans(ctx, projectID, []*entity.Trace{{ProjectID: projectID}}, nil)
+			return err
+		}, false},
+		{"UpdateTraceStatus", func(s *Store) error {
+			return s.UpdateTraceStatus(ctx, projectID, "0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f12", entity.TraceStatusCompleted)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, replica := &fakePool{}, &fakePool{}
+			s := &Store{pool: primary, reader: replica, maint: primary}
+			if err := tt.call(s); !errors.Is(err, errFakePool) {
+				t.Fatalf("expected the fake pool's error, got %v", err)
+			}
+
+			usedPrimary, usedReplica := primary.queries > 0, replica.queries > 0
+			if usedReplica != tt.replica || usedPrimary == tt.replica {
+				t.Errorf("expected replica=%v, got %d primary and %d replica queries",
+					tt.replica, primary.queries, replica.queries)
+			}
+		})
+	}
+
+	t.Run("without a replica everything reads from the primary", func(t *testing.T) {
+		primary := &fakePool{}
+		s := &Store{pool: primary, reader: primary, maint: primary}
+		for _, tt := range tests {
+			tt.call(s)
+		}
+		if primary.queries < len(tests) {
+			t.Errorf("expected every call on the primary, got %d queries", primary.queries)
+		}
+	})
+
+	t.Run("close closes both pools", func(t *testing.T) {
+		primary, replica, maint := &fakePool{}, &fakePool{}, &fakePool{}
+		(&Store{pool: primary, reader: replica, maint: maint}).Close()
+		if !primary.closed || !replica.closed || !maint.closed {
+			t.Errorf("expected all pools closed, got primary=%v replica=%v maint=%v", primary.closed, replica.closed, maint.closed)
+		}
+	})
+}
+
+func TestMaintenancePoolRouting(t *testing.T) {
+	ctx := context.Background()
+	const projectID = "0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f11"
+
+	tests := []struct {
+		name  string
+		call  func(s *Store) error
+		maint bool
+	}{
+		{"Migrate", func(s *Store) error {
+			return s.Migrate(ctx)
+		}, true},
+		{"DeleteProject", func(s *Store) error {
+			return s.DeleteProject(ctx, projectID)
+		}, true},
+		{"DeleteAllTraces", func(s *Store) error {
+			_, err := s.DeleteAllTraces(ctx, projectID)
+			return err
+		}, true},
+		{"DeleteTracesByFilter", func(s *Store) error {
+			_, err := s.DeleteTracesByFilter(ctx, projectID, entity.TraceFilter{})
+			return err
+		}, true},
+		{"ListSpansForRecost", func(s *Store) error {
+			_, err := s.ListSpansForRecost(ctx, projectID, time.Time{}, time.Now(), nil, 10)
+			return err
+		}, true},
+		{"UpdateSpanCosts", func(s *Store) error {
+			_, err := s.UpdateSpanCosts(ctx, projectID, map[string]float64{"0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f12": 1})
+			return err
+		}, true},
+		{"ExpireSpanContent", func(s *Store) error {
+			_, err := s.ExpireSpanContent(ctx, projectID, time.Now())
+			return err
+		}, true},
+		// Ingest writes keep the primary's statement_timeout
+		{"UpdateSpanSubtreeCosts", func(s *Store) error {
+			_, err := s.UpdateSpanSubtreeCosts(ctx, projectID, map[string]float64{"0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f12": 1})
+			return err
+		}, false},
+		{"UpdateTraceStatus", func(s *Store) error {
+			return s.UpdateTraceStatus(ctx, projectID, "0b9c1c8e-4a4f-4f57-9a57-3f0cbf3c7f12", entity.TraceStatusCompleted)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, maint := &fakePool{}, &fakePool{}
+			s := &Store{pool: primary, reader: primary, maint: maint}
+			if err := tt.call(s); !errors.Is(err, errFakePool) {
+				t.Fatalf("expected the fake pool's error, got %v", err)
+			}
+
+			usedPrimary, usedMaint := primary.queries > 0, maint.queries > 0
+			if usedMaint != tt.maint || usedPrimary == tt.maint {
+				t.Errorf("expected maint=%v, got %d primary and %d maintenance queries",
+					tt.maint, primary.queries, maint.queries)
+			}
+		})
+	}
+}
@@ -3,31 +3,104 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lelemon/server/pkg/domain/entity"
 )
 
 // Store implements repository.Store for PostgreSQL
 type Store struct {
-	pool *pgxpool.Pool
+	pool   pool
+	reader pool // read replica for list and analytics reads; same as pool when not configured
+	maint  pool // primary without statement_timeout for migrations and bulk writes; same as pool when no timeout is set
+}
+
+// pool is the part of *pgxpool.Pool the store uses
+type pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// Options tunes the PostgreSQL store
+type Options struct {
+	// StatementTimeout is applied server-side (statement_timeout) to every
+	// request-path query, so a runaway analytics query is killed even if the
+	// client never cancels. Migrations, project deletion, bulk trace
+	// deletion, recosting and content expiry run on a small separate pool
+	// without it, since they legitimately take longer. Zero leaves the
+	// server default.
+	StatementTimeout time.Duration
+
+	// ReadReplicaURL opens a second pool on a read replica. Trace lists,
+	// span search, sessions and analytics read from it; writes, and the
+	// reads that must see them (users, projects, API keys, single traces
+	// fetched right after ingest), stay on the primary. Lists may lag the
+	// primary by the replication delay. Empty reads everything from the
+	// primary.
+	ReadReplicaURL string
 }
 
 // New creates a new PostgreSQL store with connection pooling
 func New(connString string) (*Store, error) {
+	return NewWithOptions(connString, Options{})
+}
+
+// NewWithOptions creates a new PostgreSQL store with the given options
+func NewWithOptions(connString string, opts Options) (*Store, error) {
+	primary, err := newPool(connString, opts.StatementTimeout, 25)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{pool: primary, reader: primary, maint: primary}
+
+	if opts.StatementTimeout > 0 {
+		maint, err := newPool(connString, 0, 2)
+		if err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("maintenance pool: %w", err)
+		}
+		s.maint = maint
+	}
+
+	if opts.ReadReplicaURL != "" {
+		reader, err := newPool(opts.ReadReplicaURL, opts.StatementTimeout, 25)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("read replica: %w", err)
+		}
+		s.reader = reader
+	}
+
+	return s, nil
+}
+
+// newPool creates a connection pool tuned for the store. A zero
+// statementTimeout leaves the server default.
+func newPool(connString string, statementTimeout time.Duration, maxConns int32) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse connection string: %w", err)
 	}
 
+	if statementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprintf("%d", statementTimeout.Milliseconds())
+	}
+
 	// Configure pool
-	config.MaxConns = 25
-	config.MinConns = 5
+	config.MaxConns = maxConns
+	config.MinConns = min(5, maxConns)
 	config.MaxConnLifetime = time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
 
@@ -36,7 +109,7 @@ func New(connString string) (*Store, error) {
 		return nil, fmt.Errorf("failed to create pool: %w", err)
 	}
 
-	return &Store{pool: pool}, nil
+	return pool, nil
 }
 
 // Migrate runs database migrations
@@ -79,7 +152,8 @@ func (s *Store) Migrate(ctx context.Context) error {
 			tags JSONB DEFAULT '[]',
 			metadata JSONB DEFAULT '{}',
 			created_at TIMESTAMPTZ DEFAULT NOW(),
-			updated_at TIMESTAMPTZ DEFAULT NOW()
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			parent_trace_id TEXT
 		)`,
 
 		// Spans table
@@ -107,7 +181,14 @@ func (s *Store) Migrate(ctx context.Context) error {
 			cache_write_tokens INTEGER,
 			reasoning_tokens INTEGER,
 			first_token_ms INTEGER,
-			thinking TEXT
+			thinking TEXT,
+			sequence INTEGER NOT NULL DEFAULT 0,
+			input_bytes INTEGER NOT NULL DEFAULT 0,
+			output_bytes INTEGER NOT NULL DEFAULT 0,
+			model_params JSONB,
+			tags JSONB,
+			subtree_cost_usd DOUBLE PRECISION,
+			links JSONB
 		)`,
 
 		// Phase 7.1: Add extended fields to existing spans table
@@ -121,12 +202,67 @@ func (s *Store) Migrate(ctx context.Context) error {
 		// Phase 7.2: Add name field to traces table
 		`ALTER TABLE traces ADD COLUMN IF NOT EXISTS name TEXT`,
 
+		// Emission order, breaking ties between spans started at the same time
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS sequence INTEGER NOT NULL DEFAULT 0`,
+
+		// Serialized input/output sizes, for storage analytics without reading the blobs
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS input_bytes INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS output_bytes INTEGER NOT NULL DEFAULT 0`,
+
+		// Generation settings (temperature, top_p, ...)
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS model_params JSONB`,
+
+		// Span tags, a JSON array
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS tags JSONB`,
+
+		// Agent span cost including descendants, set when the trace ends
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS subtree_cost_usd DOUBLE PRECISION`,
+
+		// Span links (non-hierarchical references to other spans), a JSON array
+		`ALTER TABLE spans ADD COLUMN IF NOT EXISTS links JSONB`,
+
+		// Trace that spawned this one (e.g. an orchestrator's delegated agent).
+		// Text, like session_id, so a client's unknown or non-UUID ID is kept.
+		`ALTER TABLE traces ADD COLUMN IF NOT EXISTS parent_trace_id TEXT`,
+
+		// API key usage (last-seen + request counter per key hash)
+		`CREATE TABLE IF NOT EXISTS api_key_usage (
+			key_hash TEXT PRIMARY KEY,
+			project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+			last_used_at TIMESTAMPTZ NOT NULL,
+			request_count BIGINT NOT NULL DEFAULT 0
+		)`,
+
+		// Trace scores (end-user feedback)
+		`CREATE TABLE IF NOT EXISTS scores (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+			trace_id UUID NOT NULL REFERENCES traces(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			source TEXT NOT NULL,
+			comment TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_scores_project_created ON scores(project_id, created_at DESC)`,
+
+		// Span attributes: metadata values promoted at ingest for indexed search
+		`CREATE TABLE IF NOT EXISTS span_attributes (
+			span_id UUID NOT NULL REFERENCES spans(id) ON DELETE CASCADE,
+			project_id UUID NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (span_id, key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_span_attributes_lookup ON span_attributes(project_id, key, value)`,
+
 		// Indexes - Basic
 		`CREATE INDEX IF NOT EXISTS idx_projects_api_key_hash ON projects(api_key_hash)`,
 		`CREATE INDEX IF NOT EXISTS idx_projects_owner ON projects(owner_email)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_project_created ON traces(project_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_session ON traces(project_id, session_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_user ON traces(project_id, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_traces_parent ON traces(project_id, parent_trace_id) WHERE parent_trace_id IS NOT NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_spans_trace ON spans(trace_id, started_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id)`,
@@ -146,7 +282,7 @@ func (s *Store) Migrate(ctx context.Context) error {
 	}
 
 	for _, m := range migrations {
-		if _, err := s.pool.Exec(ctx, m); err != nil {
+		if _, err := s.maint.Exec(ctx, m); err != nil {
 			return fmt.Errorf("migration failed: %w", err)
 		}
 	}
@@ -166,6 +302,12 @@ func (s *Store) Ping(ctx context.Context) error {
 
 // Close closes the connection pool
 func (s *Store) Close() error {
+	if s.reader != s.pool {
+		s.reader.Close()
+	}
+	if s.maint != s.pool {
+		s.maint.Close()
+	}
 	s.pool.Close()
 	return nil
 }
@@ -351,7 +493,7 @@ func (s *Store) UpdateProject(ctx context.Context, id string, updates entity.Pro
 }
 
 func (s *Store) DeleteProject(ctx context.Context, id string) error {
-	_, err := s.pool.Exec(ctx, "DELETE FROM projects WHERE id = $1", id)
+	_, err := s.maint.Exec(ctx, "DELETE FROM projects WHERE id = $1", id)
 	return err
 }
 
@@ -378,6 +520,29 @@ func (s *Store) ListProjectsByOwner(ctx context.Context, email string) ([]entity
 	return projects, nil
 }
 
+func (s *Store) ListProjects(ctx context.Context) ([]entity.Project, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, api_key, api_key_hash, owner_email, settings, created_at, updated_at
+		FROM projects ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []entity.Project
+	for rows.Next() {
+		var p entity.Project
+		var settingsJSON []byte
+		if err := rows.Scan(&p.ID, &p.Name, &p.APIKey, &p.APIKeyHash, &p.OwnerEmail, &settingsJSON, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(settingsJSON, &p.Settings)
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
 func (s *Store) IsProjectOwner(ctx context.Context, projectID, ownerEmail string) (bool, error) {
 	var exists bool
 	err := s.pool.QueryRow(ctx,
@@ -396,6 +561,36 @@ func (s *Store) RotateAPIKey(ctx context.Context, id string, newKey, newHash str
 	return err
 }
 
+func (s *Store) RecordAPIKeyUsage(ctx context.Context, projectID, keyHash string, lastUsedAt time.Time, requests int64) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO api_key_usage (key_hash, project_id, last_used_at, request_count)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key_hash) DO UPDATE SET
+			last_used_at = GREATEST(api_key_usage.last_used_at, EXCLUDED.last_used_at),
+			request_count = api_key_usage.request_count + EXCLUDED.request_count
+	`, keyHash, projectID, lastUsedAt, requests)
+	return err
+}
+
+func (s *Store) GetAPIKeyUsage(ctx context.Context, keyHash string) (*entity.APIKeyUsage, error) {
+	var usage entity.APIKeyUsage
+	var lastUsedAt time.Time
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT last_used_at, request_count FROM api_key_usage WHERE key_hash = $1
+	`, keyHash).Scan(&lastUsedAt, &usage.RequestCount)
+
+	if err == pgx.ErrNoRows {
+		return &usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usage.LastUsedAt = &lastUsedAt
+	return &usage, nil
+}
+
 // ============================================
 // TRACE OPERATIONS
 // ============================================
@@ -412,9 +607,9 @@ func (s *Store) CreateTrace(ctx context.Context, t *entity.Trace) error {
 	metadataJSON, _ := json.Marshal(t.Metadata)
 
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, tagsJSON, metadataJSON, t.CreatedAt, t.UpdatedAt)
+		INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, tagsJSON, metadataJSON, t.CreatedAt, t.UpdatedAt, t.ParentTraceID)
 
 	return err
 }
@@ -465,7 +660,17 @@ func (s *Store) UpdateTraceStatus(ctx context.Context, projectID, traceID string
 
 func (s *Store) DeleteAllTraces(ctx context.Context, projectID string) (int64, error) {
 	// Spans are deleted via CASCADE when traces are deleted
-	result, err := s.pool.Exec(ctx, `DELETE FROM traces WHERE project_id = $1`, projectID)
+	result, err := s.maint.Exec(ctx, `DELETE FROM traces WHERE project_id = $1`, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (s *Store) DeleteTracesByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error) {
+	// Spans are deleted via CASCADE when traces are deleted
+	whereClause, args := traceFilterWhere(projectID, filter)
+	result, err := s.maint.Exec(ctx, fmt.Sprintf(`DELETE FROM traces t WHERE %s`, whereClause), args...)
 	if err != nil {
 		return 0, err
 	}
@@ -473,14 +678,18 @@ func (s *Store) DeleteAllTraces(ctx context.Context, projectID string) (int64, e
 }
 
 func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error) {
+	return s.GetTraceCapped(ctx, projectID, traceID, 0)
+}
+
+func (s *Store) GetTraceCapped(ctx context.Context, projectID, traceID string, maxSpans int) (*entity.TraceWithSpans, error) {
 	var t entity.Trace
 	var tagsJSON, metadataJSON []byte
 	var name, sessionID, userID *string
 
 	err := s.pool.QueryRow(ctx, `
-		SELECT id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at
+		SELECT id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id
 		FROM traces WHERE project_id = $1 AND id = $2
-	`, projectID, traceID).Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON, &t.CreatedAt, &t.UpdatedAt)
+	`, projectID, traceID).Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON, &t.CreatedAt, &t.UpdatedAt, &t.ParentTraceID)
 
 	if err == pgx.ErrNoRows {
 		return nil, entity.ErrNotFound
@@ -495,14 +704,28 @@ func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entit
 	json.Unmarshal(tagsJSON, &t.Tags)
 	json.Unmarshal(metadataJSON, &t.Metadata)
 
-	// Get spans
-	spans, err := s.getSpansForTrace(ctx, traceID)
+	// Get spans, one past the cap to tell whether there are more
+	limit := 0
+	if maxSpans > 0 {
+		limit = maxSpans + 1
+	}
+	spans, err := s.getSpansForTrace(ctx, traceID, limit, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate metrics
 	result := &entity.TraceWithSpans{Trace: t, Spans: spans}
+	if maxSpans > 0 && len(spans) > maxSpans {
+		// Metrics still cover every span, not just those returned
+		result.Spans = spans[:maxSpans]
+		result.SpansTruncated = true
+		if err := s.sumTraceSpans(ctx, traceID, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// Calculate metrics
 	for _, span := range spans {
 		result.TotalSpans++
 		if span.InputTokens != nil {
@@ -522,25 +745,117 @@ func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entit
 	return result, nil
 }
 
-func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.Span, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT id, trace_id, parent_span_id, type, name, input, output,
-		       input_tokens, output_tokens, cost_usd, duration_ms, status,
-		       error_message, model, provider, metadata, started_at, ended_at,
-		       stop_reason, cache_read_tokens, cache_write_tokens,
-		       reasoning_tokens, first_token_ms, thinking
-		FROM spans WHERE trace_id = $1 ORDER BY started_at
-	`, traceID)
+// getSpansForTrace returns the trace's spans in start order, then emission
+// order; limit <= 0 returns all
+func (s *Store) getSpansForTrace(ctx context.Context, traceID string, limit, offset int) ([]entity.Span, error) {
+	query := `
+		SELECT ` + spanColumns + `
+		FROM spans WHERE trace_id = $1 ORDER BY started_at, sequence, id
+	`
+	args := []any{traceID}
+	if limit > 0 {
+		query += ` LIMIT $2 OFFSET $3`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanSpans(rows)
+}
+
+// sumTraceSpans sets the trace metrics from all of its spans, in SQL
+func (s *Store) sumTraceSpans(ctx context.Context, traceID string, result *entity.TraceWithSpans) error {
+	return s.pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(input_tokens), 0) + COALESCE(SUM(output_tokens), 0),
+		       COALESCE(SUM(cost_usd), 0),
+		       COALESCE(SUM(duration_ms), 0)
+		FROM spans WHERE trace_id = $1
+	`, traceID).Scan(&result.TotalSpans, &result.TotalTokens, &result.TotalCostUSD, &result.TotalDurationMs)
+}
+
+func (s *Store) GetTracesMetrics(ctx context.Context, projectID string, traceIDs []string) (map[string]entity.TraceMetrics, error) {
+	metrics := make(map[string]entity.TraceMetrics, len(traceIDs))
+	if len(traceIDs) == 0 {
+		return metrics, nil
+	}
+
+	rows, err := s.reader.Query(ctx, `
+		SELECT t.id,
+		       COUNT(s.id),
+		       COALESCE(SUM(s.input_tokens), 0) + COALESCE(SUM(s.output_tokens), 0),
+		       COALESCE(SUM(s.cost_usd), 0),
+		       COALESCE(SUM(s.duration_ms), 0)
+		FROM traces t
+		LEFT JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.id = ANY($2::uuid[])
+		GROUP BY t.id
+	`, projectID, traceIDs)
+	if err != nil {
+		return nil, fmt.Errorf("GetTracesMetrics query error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var m entity.TraceMetrics
+		if err := rows.Scan(&id, &m.TotalSpans, &m.TotalTokens, &m.TotalCostUSD, &m.TotalDurationMs); err != nil {
+			return nil, fmt.Errorf("GetTracesMetrics scan error: %w", err)
+		}
+		metrics[id] = m
+	}
+	return metrics, rows.Err()
+}
+
+func (s *Store) ListTraceSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) {
+	var exists bool
+	if err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM traces WHERE project_id = $1 AND id = $2)
+	`, projectID, traceID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, entity.ErrNotFound
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM spans WHERE trace_id = $1`, traceID).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	spans, err := s.getSpansForTrace(ctx, traceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewPage(spans, total, limit, offset), nil
+}
+
+// spanColumns is the column list scanSpans expects, in order.
+const spanColumns = `id, trace_id, parent_span_id, type, name, input, output,
+		       input_tokens, output_tokens, cost_usd, duration_ms, status,
+		       error_message, model, provider, metadata, started_at, ended_at,
+		       stop_reason, cache_read_tokens, cache_write_tokens,
+		       reasoning_tokens, first_token_ms, thinking, sequence, model_params, tags, subtree_cost_usd, links`
+
+// scanSpans reads full span rows selected with spanColumns.
+func scanSpans(rows pgx.Rows) ([]entity.Span, error) {
 	var spans []entity.Span
 	for rows.Next() {
 		var sp entity.Span
 		var parentSpanID *string
-		var inputJSON, outputJSON, metadataJSON []byte
+		var inputJSON, outputJSON, metadataJSON, modelParamsJSON, tagsJSON, linksJSON []byte
 		var errorMsg, model, provider *string
 		var stopReason, thinking *string
 		var inputTokens, outputTokens, durationMs *int
@@ -553,7 +868,7 @@ func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.
 			&durationMs, &sp.Status, &errorMsg, &model, &provider, &metadataJSON,
 			&sp.StartedAt, &endedAt,
 			&stopReason, &cacheReadTokens, &cacheWriteTokens,
-			&reasoningTokens, &firstTokenMs, &thinking)
+			&reasoningTokens, &firstTokenMs, &thinking, &sp.Sequence, &modelParamsJSON, &tagsJSON, &sp.SubtreeCostUSD, &linksJSON)
 		if err != nil {
 			return nil, err
 		}
@@ -583,15 +898,52 @@ func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.
 		sp.ReasoningTokens = reasoningTokens
 		sp.FirstTokenMs = firstTokenMs
 		sp.Thinking = thinking
+		if modelParamsJSON != nil {
+			json.Unmarshal(modelParamsJSON, &sp.ModelParams)
+		}
+		if tagsJSON != nil {
+			json.Unmarshal(tagsJSON, &sp.Tags)
+		}
+		if linksJSON != nil {
+			json.Unmarshal(linksJSON, &sp.Links)
+		}
 
 		spans = append(spans, sp)
 	}
 
-	return spans, nil
+	return spans, rows.Err()
 }
 
-func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
-	// Build query with positional parameters
+func (s *Store) ListTraceIDs(ctx context.Context, projectID string, after *entity.TraceCursor, limit int) ([]entity.TraceCursor, error) {
+	query := `SELECT id, created_at FROM traces WHERE project_id = $1`
+	args := []any{projectID}
+	if after != nil {
+		query += ` AND (created_at, id) > ($2, $3)`
+		args = append(args, after.CreatedAt, after.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at, id LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cursors []entity.TraceCursor
+	for rows.Next() {
+		var c entity.TraceCursor
+		if err := rows.Scan(&c.ID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		cursors = append(cursors, c)
+	}
+	return cursors, rows.Err()
+}
+
+// traceFilterWhere builds the WHERE clause (over traces aliased as t) shared by
+// ListTraces and DeleteTracesByFilter
+func traceFilterWhere(projectID string, filter entity.TraceFilter) (string, []any) {
 	where := []string{"t.project_id = $1"}
 	args := []any{projectID}
 	argNum := 2
@@ -611,6 +963,11 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		args = append(args, *filter.UserID)
 		argNum++
 	}
+	if filter.ParentTraceID != nil {
+		where = append(where, fmt.Sprintf("t.parent_trace_id = $%d", argNum))
+		args = append(args, *filter.ParentTraceID)
+		argNum++
+	}
 	if filter.Status != nil {
 		where = append(where, fmt.Sprintf("t.status = $%d", argNum))
 		args = append(args, string(*filter.Status))
@@ -632,13 +989,47 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		args = append(args, *filter.To)
 		argNum++
 	}
+	if filter.MinInactiveMs != nil {
+		cutoff := time.Now().Add(-time.Duration(*filter.MinInactiveMs) * time.Millisecond)
+		where = append(where, fmt.Sprintf(
+			"t.status = 'active' AND t.created_at <= $%d AND t.id NOT IN (SELECT trace_id FROM spans WHERE started_at > $%d)",
+			argNum, argNum))
+		args = append(args, cutoff)
+		argNum++
+	}
+	// Tool calls in llm outputs are not stored here: tool spans only
+	if filter.Tool != nil {
+		where = append(where, fmt.Sprintf("t.id IN (SELECT trace_id FROM spans WHERE type = 'tool' AND name = $%d)", argNum))
+		args = append(args, *filter.Tool)
+		argNum++
+	}
+	// Walks up from every span, one parent per level, until MinDepth levels
+	// are found; bounded, so a parent cycle cannot loop
+	if filter.MinDepth != nil {
+		where = append(where, fmt.Sprintf(`t.id IN (
+			WITH RECURSIVE chain(trace_id, parent_id, depth) AS (
+				SELECT s.trace_id, s.parent_span_id, 1 FROM spans s
+				WHERE s.trace_id IN (SELECT id FROM traces WHERE project_id = $1)
+				UNION ALL
+				SELECT c.trace_id, p.parent_span_id, c.depth + 1 FROM chain c
+				JOIN spans p ON p.id = c.parent_id AND p.trace_id = c.trace_id
+				WHERE c.depth < $%d)
+			SELECT trace_id FROM chain WHERE depth >= $%d)`, argNum, argNum))
+		args = append(args, *filter.MinDepth)
+		argNum++
+	}
 
-	whereClause := strings.Join(where, " AND ")
+	return strings.Join(where, " AND "), args
+}
+
+func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
+	whereClause, args := traceFilterWhere(projectID, filter)
+	argNum := len(args) + 1
 
 	// Get total count
 	var total int
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM traces t WHERE %s", whereClause)
-	if err := s.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+	if err := s.reader.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
@@ -654,10 +1045,12 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 
 	query := fmt.Sprintf(`
 		SELECT t.id, t.project_id, t.name, t.session_id, t.user_id, t.status, t.tags, t.metadata, t.created_at, t.updated_at,
+		       t.parent_trace_id,
 		       COALESCE(COUNT(s.id), 0) as total_spans,
 		       COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 		       COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as total_cost,
-		       COALESCE(SUM(COALESCE(s.duration_ms, 0)), 0) as total_duration
+		       COALESCE(SUM(COALESCE(s.duration_ms, 0)), 0) as total_duration,
+		       MAX(s.started_at) as last_span_at
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
 		WHERE %s
@@ -667,23 +1060,30 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 	`, whereClause, argNum, argNum+1)
 
 	args = append(args, limit, offset)
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	var traces []entity.TraceWithMetrics
 	for rows.Next() {
 		var t entity.TraceWithMetrics
 		var tagsJSON, metadataJSON []byte
 		var name, sessionID, userID *string
+		var lastSpanAt *time.Time
 
 		err := rows.Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON,
-			&t.CreatedAt, &t.UpdatedAt, &t.TotalSpans, &t.TotalTokens, &t.TotalCostUSD, &t.TotalDurationMs)
+			&t.CreatedAt, &t.UpdatedAt, &t.ParentTraceID, &t.TotalSpans, &t.TotalTokens, &t.TotalCostUSD, &t.TotalDurationMs, &lastSpanAt)
 		if err != nil {
 			return nil, err
 		}
+		var lastSpan time.Time
+		if lastSpanAt != nil {
+			lastSpan = *lastSpanAt
+		}
+		t.SetLastActivity(lastSpan, now)
 
 		t.Name = name
 		t.SessionID = sessionID
@@ -694,19 +1094,14 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		traces = append(traces, t)
 	}
 
-	return &entity.Page[entity.TraceWithMetrics]{
-		Data:   traces,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
-	}, nil
+	return entity.NewPage(traces, total, limit, offset), nil
 }
 
 // ============================================
 // SPAN OPERATIONS
 // ============================================
 
-func (s *Store) CreateSpan(ctx context.Context, span *entity.Span) error {
+func (s *Store) CreateSpan(ctx context.Context, projectID string, span *entity.Span) error {
 	if span.ID == "" {
 		span.ID = uuid.New().String()
 	}
@@ -720,54 +1115,50 @@ func (s *Store) CreateSpan(ctx context.Context, span *entity.Span) error {
 		                   input_tokens, output_tokens, cost_usd, duration_ms, status,
 		                   error_message, model, provider, metadata, started_at, ended_at,
 		                   stop_reason, cache_read_tokens, cache_write_tokens,
-		                   reasoning_tokens, first_token_ms, thinking)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
+		                   reasoning_tokens, first_token_ms, thinking, sequence,
+		                   input_bytes, output_bytes, model_params, tags, subtree_cost_usd, links)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)
 	`, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
 		inputJSON, outputJSON, span.InputTokens, span.OutputTokens,
 		span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
 		span.Provider, metadataJSON, span.StartedAt, span.EndedAt,
 		span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
-		span.ReasoningTokens, span.FirstTokenMs, span.Thinking)
+		span.ReasoningTokens, span.FirstTokenMs, span.Thinking, span.Sequence,
+		entity.PayloadBytes(inputJSON), entity.PayloadBytes(outputJSON), span.ModelParamsJSON(), span.TagsJSON(), span.SubtreeCostUSD, span.LinksJSON())
+	if err != nil || len(span.Attributes) == 0 {
+		return err
+	}
 
-	return err
+	batch := &pgx.Batch{}
+	queueSpanAttributes(batch, projectID, span)
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+// queueSpanAttributes queues the inserts of a span's indexed attributes
+func queueSpanAttributes(batch *pgx.Batch, projectID string, span *entity.Span) {
+	for key, value := range span.Attributes {
+		batch.Queue(`
+			INSERT INTO span_attributes (span_id, project_id, key, value)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (span_id, key) DO UPDATE SET value = EXCLUDED.value
+		`, span.ID, projectID, key, value)
+	}
 }
 
-func (s *Store) CreateSpans(ctx context.Context, spans []entity.Span) error {
+func (s *Store) CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error {
 	if len(spans) == 0 {
 		return nil
 	}
 
 	batch := &pgx.Batch{}
-
 	for i := range spans {
-		span := &spans[i]
-		if span.ID == "" {
-			span.ID = uuid.New().String()
-		}
-
-		inputJSON, _ := json.Marshal(span.Input)
-		outputJSON, _ := json.Marshal(span.Output)
-		metadataJSON, _ := json.Marshal(span.Metadata)
-
-		batch.Queue(`
-			INSERT INTO spans (id, trace_id, parent_span_id, type, name, input, output,
-			                   input_tokens, output_tokens, cost_usd, duration_ms, status,
-			                   error_message, model, provider, metadata, started_at, ended_at,
-			                   stop_reason, cache_read_tokens, cache_write_tokens,
-			                   reasoning_tokens, first_token_ms, thinking)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
-		`, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
-			inputJSON, outputJSON, span.InputTokens, span.OutputTokens,
-			span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
-			span.Provider, metadataJSON, span.StartedAt, span.EndedAt,
-			span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
-			span.ReasoningTokens, span.FirstTokenMs, span.Thinking)
+		queueSpan(batch, projectID, &spans[i])
 	}
 
 	br := s.pool.SendBatch(ctx, batch)
 	defer br.Close()
 
-	for range spans {
+	for range batch.Len() {
 		if _, err := br.Exec(); err != nil {
 			return err
 		}
@@ -776,56 +1167,422 @@ func (s *Store) CreateSpans(ctx context.Context, spans []entity.Span) error {
 	return nil
 }
 
-// ============================================
-// SESSION OPERATIONS
-// ============================================
+// CreateSpansPartial sends each span, with its attributes, as a batch of its
+// own, which Postgres runs as its own implicit transaction. Database errors
+// (e.g. a constraint violation) fail only their span; any other error (e.g.
+// a lost connection) stops the write.
+func (s *Store) CreateSpansPartial(ctx context.Context, projectID string, spans []entity.Span) ([]entity.SpanWriteError, error) {
+	var failed []entity.SpanWriteError
+	for i := range spans {
+		batch := &pgx.Batch{}
+		queueSpan(batch, projectID, &spans[i])
+		if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				return nil, err
+			}
+			failed = append(failed, entity.SpanWriteError{Index: i, Err: err})
+		}
+	}
+	return failed, nil
+}
 
-func (s *Store) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
-	where := []string{"t.project_id = $1", "t.session_id IS NOT NULL"}
-	args := []any{projectID}
-	argNum := 2
+// CreateTracesWithSpans sends the trace and span inserts as one batch, which
+// Postgres runs as a single implicit transaction: a failing insert rolls back
+// the whole batch.
+func (s *Store) CreateTracesWithSpans(ctx context.Context, projectID string, traces []*entity.Trace, spans []entity.Span) (int, error) {
+	if len(traces) == 0 && len(spans) == 0 {
+		return 0, nil
+	}
 
-	if filter.UserID != nil {
-		where = append(where, fmt.Sprintf("t.user_id = $%d", argNum))
-		args = append(args, *filter.UserID)
-		argNum++
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
 	}
-	if filter.From != nil {
-		where = append(where, fmt.Sprintf("t.created_at >= $%d", argNum))
-		args = append(args, *filter.From)
-		argNum++
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	now := time.Now()
+	for _, t := range traces {
+		if t.ID == "" {
+			t.ID = uuid.New().String()
+		}
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		t.UpdatedAt = now
+
+		tagsJSON, _ := json.Marshal(t.Tags)
+		metadataJSON, _ := json.Marshal(t.Metadata)
+
+		// A concurrent batch may have created the trace since it was looked up
+		batch.Queue(`
+			INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (id) DO NOTHING
+		`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, tagsJSON, metadataJSON, t.CreatedAt, t.UpdatedAt, t.ParentTraceID)
+	}
+
+	created := 0
+	var existing []string
+	if batch.Len() > 0 {
+		br := tx.SendBatch(ctx, batch)
+		for _, t := range traces {
+			tag, err := br.Exec()
+			if err != nil {
+				br.Close()
+				return 0, err
+			}
+			if tag.RowsAffected() > 0 {
+				created++
+			} else {
+				existing = append(existing, t.ID)
+			}
+		}
+		if err := br.Close(); err != nil {
+			return 0, err
+		}
 	}
-	if filter.To != nil {
-		where = append(where, fmt.Sprintf("t.created_at <= $%d", argNum))
-		args = append(args, *filter.To)
-		argNum++
+
+	// A trace that already existed must be the project's own, or the spans
+	// would be written under another project's trace
+	if len(existing) > 0 {
+		var foreign string
+		err := tx.QueryRow(ctx, `
+			SELECT id::text FROM traces WHERE id = ANY($1::uuid[]) AND project_id <> $2 LIMIT 1
+		`, existing, projectID).Scan(&foreign)
+		if err == nil {
+			return 0, fmt.Errorf("trace %s belongs to another project: %w", foreign, entity.ErrConflict)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
+		}
 	}
 
-	whereClause := strings.Join(where, " AND ")
+	if len(spans) > 0 {
+		batch = &pgx.Batch{}
+		for i := range spans {
+			queueSpan(batch, projectID, &spans[i])
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return 0, err
+		}
+	}
 
-	// Get total
-	var total int
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(DISTINCT t.session_id) FROM traces t WHERE %s
-	`, whereClause)
-	if err := s.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, err
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
 	}
+	return created, nil
+}
 
-	// Get sessions
-	limit := filter.Limit
-	if limit <= 0 || limit > 100 {
-		limit = 50
+// queueSpan queues the insert of span, and its indexed attributes
+func queueSpan(batch *pgx.Batch, projectID string, span *entity.Span) {
+	if span.ID == "" {
+		span.ID = uuid.New().String()
 	}
-	offset := filter.Offset
 
-	query := fmt.Sprintf(`
-		SELECT
-			t.session_id,
-			MAX(t.user_id) as user_id,
-			COUNT(DISTINCT t.id) as trace_count,
-			COALESCE(COUNT(s.id), 0) as total_spans,
-			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
+	inputJSON, _ := json.Marshal(span.Input)
+	outputJSON, _ := json.Marshal(span.Output)
+	metadataJSON, _ := json.Marshal(span.Metadata)
+
+	batch.Queue(`
+		INSERT INTO spans (id, trace_id, parent_span_id, type, name, input, output,
+		                   input_tokens, output_tokens, cost_usd, duration_ms, status,
+		                   error_message, model, provider, metadata, started_at, ended_at,
+		                   stop_reason, cache_read_tokens, cache_write_tokens,
+		                   reasoning_tokens, first_token_ms, thinking, sequence,
+		                   input_bytes, output_bytes, model_params, tags, subtree_cost_usd, links)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)
+	`, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
+		inputJSON, outputJSON, span.InputTokens, span.OutputTokens,
+		span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
+		span.Provider, metadataJSON, span.StartedAt, span.EndedAt,
+		span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
+		span.ReasoningTokens, span.FirstTokenMs, span.Thinking, span.Sequence,
+		entity.PayloadBytes(inputJSON), entity.PayloadBytes(outputJSON), span.ModelParamsJSON(), span.TagsJSON(), span.SubtreeCostUSD, span.LinksJSON())
+	queueSpanAttributes(batch, projectID, span)
+}
+
+func (s *Store) UpdateSpan(ctx context.Context, projectID, traceID, spanID string, updates entity.SpanUpdate) error {
+	if updates.Tags == nil {
+		return nil
+	}
+
+	var tagsJSON []byte
+	if len(updates.Tags) > 0 {
+		tagsJSON, _ = json.Marshal(updates.Tags)
+	}
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE spans SET tags = $1
+		WHERE id = $2 AND trace_id = $3 AND trace_id IN (SELECT id FROM traces WHERE project_id = $4)
+	`, tagsJSON, spanID, traceID, projectID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SearchSpans(ctx context.Context, projectID string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	where, args := spanFilterWhere(projectID, filter)
+	return s.searchSpans(ctx, where, args, filter)
+}
+
+func (s *Store) SearchSpansByAttribute(ctx context.Context, projectID, key, value string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	where, args := spanFilterWhere(projectID, filter)
+	n := len(args)
+	where = append(where, fmt.Sprintf(
+		"id IN (SELECT span_id FROM span_attributes WHERE project_id = $%d AND key = $%d AND value = $%d)", n+1, n+2, n+3))
+	args = append(args, projectID, key, value)
+	return s.searchSpans(ctx, where, args, filter)
+}
+
+// likeEscaper escapes LIKE wildcards so text matches literally (ESCAPE '\')
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern matching values that contain text
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+// spanFilterWhere builds the WHERE conditions (and args) selecting the
+// project's spans that match filter
+func spanFilterWhere(projectID string, filter entity.SpanFilter) ([]string, []any) {
+	where := []string{"trace_id IN (SELECT id FROM traces WHERE project_id = $1)"}
+	args := []any{projectID}
+	argNum := 2
+
+	if filter.Type != nil {
+		where = append(where, fmt.Sprintf("type = $%d", argNum))
+		args = append(args, string(*filter.Type))
+		argNum++
+	}
+	if filter.Name != nil && *filter.Name != "" {
+		where = append(where, fmt.Sprintf("name = $%d", argNum))
+		args = append(args, *filter.Name)
+		argNum++
+	}
+	if filter.Status != nil {
+		where = append(where, fmt.Sprintf("status = $%d", argNum))
+		args = append(args, string(*filter.Status))
+		argNum++
+	}
+	if filter.Model != nil && *filter.Model != "" {
+		where = append(where, fmt.Sprintf("model = $%d", argNum))
+		args = append(args, *filter.Model)
+		argNum++
+	}
+	if filter.From != nil {
+		where = append(where, fmt.Sprintf("started_at >= $%d", argNum))
+		args = append(args, *filter.From)
+		argNum++
+	}
+	if filter.To != nil {
+		where = append(where, fmt.Sprintf("started_at <= $%d", argNum))
+		args = append(args, *filter.To)
+		argNum++
+	}
+	if filter.Text != "" {
+		where = append(where, fmt.Sprintf(
+			`(name ILIKE $%[1]d ESCAPE '\' OR input::text ILIKE $%[1]d ESCAPE '\' OR output::text ILIKE $%[1]d ESCAPE '\' OR error_message ILIKE $%[1]d ESCAPE '\')`, argNum))
+		args = append(args, containsPattern(filter.Text))
+		argNum++
+	}
+	for key, value := range filter.Metadata {
+		where = append(where, fmt.Sprintf("metadata->>($%d::text) = $%d", argNum, argNum+1))
+		args = append(args, key, value)
+		argNum += 2
+	}
+	if len(filter.Tags) > 0 {
+		where = append(where, fmt.Sprintf("tags ?| $%d", argNum))
+		args = append(args, filter.Tags)
+		argNum++
+	}
+
+	return where, args
+}
+
+func (s *Store) searchSpans(ctx context.Context, where []string, args []any, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	argNum := len(args) + 1
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM spans WHERE %s", whereClause)
+	if err := s.reader.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM spans
+		WHERE %s
+		ORDER BY started_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, spanColumns, whereClause, argNum, argNum+1)
+
+	args = append(args, limit, offset)
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spans, err := scanSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewPage(spans, total, limit, offset), nil
+}
+
+func (s *Store) ListSpansForRecost(ctx context.Context, projectID string, from, to time.Time, after *entity.SpanCursor, limit int) ([]entity.Span, error) {
+	query := `
+		SELECT s.id, s.trace_id, s.type, s.model, s.provider,
+		       s.input_tokens, s.output_tokens, s.cache_read_tokens,
+		       s.cache_write_tokens, s.reasoning_tokens, s.cost_usd, s.started_at
+		FROM spans s
+		JOIN traces t ON t.id = s.trace_id
+		WHERE t.project_id = $1 AND s.type = 'llm' AND s.model IS NOT NULL AND s.model != ''
+		  AND s.started_at >= $2 AND s.started_at <= $3
+		  AND NOT COALESCE(s.metadata @> '{"cost_override": true}', false)`
+	args := []any{projectID, from, to}
+	if after != nil {
+		query += ` AND (s.started_at, s.id) > ($4, $5)`
+		args = append(args, after.StartedAt, after.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY s.started_at, s.id LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := s.maint.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []entity.Span
+	for rows.Next() {
+		var sp entity.Span
+		if err := rows.Scan(&sp.ID, &sp.TraceID, &sp.Type, &sp.Model, &sp.Provider,
+			&sp.InputTokens, &sp.OutputTokens, &sp.CacheReadTokens,
+			&sp.CacheWriteTokens, &sp.ReasoningTokens, &sp.CostUSD, &sp.StartedAt); err != nil {
+			return nil, err
+		}
+		spans = append(spans, sp)
+	}
+
+	return spans, rows.Err()
+}
+
+func (s *Store) UpdateSpanCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.updateSpanValues(ctx, s.maint, projectID, "cost_usd", costs)
+}
+
+func (s *Store) UpdateSpanSubtreeCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.updateSpanValues(ctx, s.pool, projectID, "subtree_cost_usd", costs)
+}
+
+// updateSpanValues sets column to each span's value, by span ID. Recost
+// passes the maintenance pool; ingest's subtree costs stay on the primary.
+func (s *Store) updateSpanValues(ctx context.Context, db pool, projectID, column string, values map[string]float64) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	// Batched UPDATE ... FROM unnest() keeps this a single round-trip per call.
+	ids := make([]string, 0, len(values))
+	vals := make([]float64, 0, len(values))
+	for id, value := range values {
+		ids = append(ids, id)
+		vals = append(vals, value)
+	}
+
+	result, err := db.Exec(ctx, `
+		UPDATE spans s SET `+column+` = u.value
+		FROM unnest($1::uuid[], $2::double precision[]) AS u(id, value), traces t
+		WHERE s.id = u.id AND t.id = s.trace_id AND t.project_id = $3
+	`, ids, vals, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (s *Store) ExpireSpanContent(ctx context.Context, projectID string, cutoff time.Time) (int64, error) {
+	result, err := s.maint.Exec(ctx, `
+		UPDATE spans s SET input = NULL, output = NULL, thinking = NULL,
+			metadata = COALESCE(s.metadata, '{}'::jsonb) || '{"content_expired": true}'::jsonb
+		FROM traces t
+		WHERE t.id = s.trace_id AND t.project_id = $1
+		  AND s.started_at < $2
+		  AND NOT (COALESCE(s.metadata, '{}'::jsonb) ? 'content_expired')
+	`, projectID, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// ============================================
+// SESSION OPERATIONS
+// ============================================
+
+func (s *Store) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
+	where := []string{"t.project_id = $1", "t.session_id IS NOT NULL"}
+	args := []any{projectID}
+	argNum := 2
+
+	if filter.UserID != nil {
+		where = append(where, fmt.Sprintf("t.user_id = $%d", argNum))
+		args = append(args, *filter.UserID)
+		argNum++
+	}
+	if filter.From != nil {
+		where = append(where, fmt.Sprintf("t.created_at >= $%d", argNum))
+		args = append(args, *filter.From)
+		argNum++
+	}
+	if filter.To != nil {
+		where = append(where, fmt.Sprintf("t.created_at <= $%d", argNum))
+		args = append(args, *filter.To)
+		argNum++
+	}
+
+	whereClause := strings.Join(where, " AND ")
+
+	// Get total
+	var total int
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(DISTINCT t.session_id) FROM traces t WHERE %s
+	`, whereClause)
+	if err := s.reader.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	// Get sessions
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset := filter.Offset
+
+	query := fmt.Sprintf(`
+		SELECT
+			t.session_id,
+			MAX(t.user_id) as user_id,
+			COUNT(DISTINCT t.id) as trace_count,
+			COALESCE(COUNT(s.id), 0) as total_spans,
+			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as total_cost,
 			COALESCE(SUM(COALESCE(s.duration_ms, 0)), 0) as total_duration,
 			MAX(CASE WHEN t.status = 'error' THEN 1 ELSE 0 END) as has_error,
@@ -841,7 +1598,7 @@ func (s *Store) ListSessions(ctx context.Context, projectID string, filter entit
 	`, whereClause, argNum, argNum+1)
 
 	args = append(args, limit, offset)
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -867,12 +1624,59 @@ func (s *Store) ListSessions(ctx context.Context, projectID string, filter entit
 		sessions = append(sessions, sess)
 	}
 
-	return &entity.Page[entity.Session]{
-		Data:   sessions,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
-	}, nil
+	return entity.NewPage(sessions, total, limit, offset), nil
+}
+
+func (s *Store) GetProjectFootprint(ctx context.Context, projectID string) (entity.Footprint, error) {
+	// Each table's size (indexes and TOAST included) is prorated by the
+	// project's share of its rows. The planner's row estimate stands in for
+	// the table's total so the whole table isn't counted.
+	var fp entity.Footprint
+	var traceTableBytes, spanTableBytes, traceTableRows, spanTableRows int64
+	err := s.reader.QueryRow(ctx, `
+		WITH project_traces AS (SELECT id FROM traces WHERE project_id = $1)
+		SELECT
+			(SELECT COUNT(*) FROM project_traces),
+			(SELECT COUNT(*) FROM spans WHERE trace_id IN (SELECT id FROM project_traces)),
+			pg_total_relation_size('traces'),
+			pg_total_relation_size('spans'),
+			(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'traces'::regclass),
+			(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'spans'::regclass)
+	`, projectID).Scan(&fp.Traces, &fp.Spans, &traceTableBytes, &spanTableBytes, &traceTableRows, &spanTableRows)
+	if err != nil {
+		return fp, fmt.Errorf("GetProjectFootprint query error: %w", err)
+	}
+	fp.TraceBytes = prorate(traceTableBytes, fp.Traces, traceTableRows)
+	fp.SpanBytes = prorate(spanTableBytes, fp.Spans, spanTableRows)
+	return fp, nil
+}
+
+// prorate returns the share of a table's bytes taken by rows of its total
+// rows. The total is at least rows, since it may be a stale estimate.
+func prorate(bytes, rows, total int64) int64 {
+	if rows == 0 {
+		return 0
+	}
+	return int64(float64(bytes) * float64(rows) / float64(max(total, rows)))
+}
+
+// ============================================
+// SCORE OPERATIONS
+// ============================================
+
+func (s *Store) CreateScore(ctx context.Context, score *entity.Score) error {
+	if score.ID == "" {
+		score.ID = uuid.New().String()
+	}
+	if score.CreatedAt.IsZero() {
+		score.CreatedAt = time.Now()
+	}
+
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO scores (id, project_id, trace_id, name, value, source, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, score.ID, score.ProjectID, score.TraceID, score.Name, score.Value, string(score.Source), score.Comment, score.CreatedAt)
+	return err
 }
 
 // ============================================
@@ -904,6 +1708,16 @@ func buildAnalyticsFilters(f entity.AnalyticsFilter, argOffset int) (string, []i
 		clauses = append(clauses, fmt.Sprintf("t.name = $%d", argOffset))
 		args = append(args, f.Name)
 	}
+	if len(f.ExcludeTags) > 0 {
+		argOffset++
+		clauses = append(clauses, fmt.Sprintf("NOT COALESCE(t.tags ?| $%d, false)", argOffset))
+		args = append(args, f.ExcludeTags)
+	}
+	if len(f.ExcludeEnvironments) > 0 {
+		argOffset++
+		clauses = append(clauses, fmt.Sprintf("COALESCE(t.metadata->>'environment', '') <> ALL($%d)", argOffset))
+		args = append(args, f.ExcludeEnvironments)
+	}
 	sql := ""
 	if len(clauses) > 0 {
 		sql = " AND " + strings.Join(clauses, " AND ")
@@ -919,7 +1733,12 @@ func (s *Store) GetStats(ctx context.Context, projectID string, q entity.Analyti
 			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as total_cost,
 			COALESCE(AVG(s.duration_ms), 0) as avg_duration,
-			COUNT(DISTINCT CASE WHEN t.status = 'error' THEN t.id END) as error_count
+			COUNT(DISTINCT CASE WHEN t.status = 'error' THEN t.id END) as error_count,
+			COUNT(DISTINCT NULLIF(s.model, '')) as distinct_models,
+			COUNT(DISTINCT NULLIF(t.user_id, '')) as distinct_users,
+			COUNT(CASE WHEN s.status = 'error' THEN 1 END) as error_spans,
+			COUNT(CASE WHEN s.status = 'timeout' THEN 1 END) as timeout_spans,
+			COUNT(CASE WHEN s.status = 'cancelled' THEN 1 END) as cancelled_spans
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
 		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
@@ -934,9 +1753,11 @@ func (s *Store) GetStats(ctx context.Context, projectID string, q entity.Analyti
 	var errorCount int
 	var avgDuration float64
 
-	err := s.pool.QueryRow(ctx, query, args...).Scan(
+	err := s.reader.QueryRow(ctx, query, args...).Scan(
 		&stats.TotalTraces, &stats.TotalSpans, &stats.TotalTokens,
-		&stats.TotalCostUSD, &avgDuration, &errorCount)
+		&stats.TotalCostUSD, &avgDuration, &errorCount,
+		&stats.DistinctModels, &stats.DistinctUsers,
+		&stats.ErrorSpans, &stats.TimeoutSpans, &stats.CancelledSpans)
 	if err != nil {
 		return nil, fmt.Errorf("GetStats query error: %w", err)
 	}
@@ -958,6 +1779,7 @@ func (s *Store) GetUsageTimeSeries(ctx context.Context, projectID string, opts e
 		truncTo = "week"
 	}
 
+	filterSQL, filterArgs := buildAnalyticsFilters(opts.Filter, 3)
 	query := fmt.Sprintf(`
 		SELECT
 			date_trunc('%s', t.created_at) as date,
@@ -967,12 +1789,14 @@ func (s *Store) GetUsageTimeSeries(ctx context.Context, projectID string, opts e
 			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as cost
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
-		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3%s
 		GROUP BY date_trunc('%s', t.created_at)
 		ORDER BY date
-	`, truncTo, truncTo)
+	`, truncTo, filterSQL, truncTo)
 
-	rows, err := s.pool.Query(ctx, query, projectID, opts.From, opts.To)
+	args := []interface{}{projectID, opts.From, opts.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetUsageTimeSeries query error: %w", err)
 	}
@@ -1022,7 +1846,7 @@ func (s *Store) GetModelStats(ctx context.Context, projectID string, q entity.An
 		ORDER BY total_cost DESC
 	`
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetModelStats query error: %w", err)
 	}
@@ -1045,20 +1869,155 @@ func (s *Store) GetModelStats(ctx context.Context, projectID string, q entity.An
 	return results, nil
 }
 
+func (s *Store) GetCacheEfficiency(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.CacheEfficiency, error) {
+	query := `
+		SELECT
+			s.model,
+			COALESCE(s.provider, 'unknown') as provider,
+			COUNT(*) as requests,
+			COALESCE(SUM(CASE WHEN LOWER(s.provider) IN ('anthropic', 'bedrock')
+				THEN COALESCE(s.input_tokens, 0) + COALESCE(s.cache_read_tokens, 0) + COALESCE(s.cache_write_tokens, 0)
+				ELSE COALESCE(s.input_tokens, 0) END), 0) as input_tokens,
+			COALESCE(SUM(s.cache_read_tokens), 0) as cache_read_tokens,
+			COALESCE(SUM(s.cache_write_tokens), 0) as cache_write_tokens
+		FROM spans s
+		JOIN traces t ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+			AND s.type = 'llm' AND s.model IS NOT NULL AND s.model != ''
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	query += `
+		GROUP BY s.model, s.provider
+		ORDER BY input_tokens DESC, s.model
+	`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetCacheEfficiency query error: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.CacheEfficiency
+	for rows.Next() {
+		var c entity.CacheEfficiency
+		if err := rows.Scan(&c.Model, &c.Provider, &c.Requests, &c.InputTokens, &c.CacheReadTokens, &c.CacheWriteTokens); err != nil {
+			return nil, fmt.Errorf("GetCacheEfficiency scan error: %w", err)
+		}
+		results = append(results, c)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetFeedbackStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.FeedbackStats, error) {
+	query := `
+		SELECT
+			COUNT(*) as total,
+			COUNT(*) FILTER (WHERE sc.value > 0) as positive,
+			COUNT(*) FILTER (WHERE sc.value = 0) as neutral,
+			COUNT(*) FILTER (WHERE sc.value < 0) as negative
+		FROM scores sc
+		JOIN traces t ON sc.trace_id = t.id
+		WHERE sc.project_id = $1 AND sc.created_at >= $2 AND sc.created_at <= $3
+			AND sc.source = 'user' AND sc.name = 'feedback'
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	var stats entity.FeedbackStats
+	if err := s.reader.QueryRow(ctx, query, args...).Scan(
+		&stats.Total, &stats.Positive, &stats.Neutral, &stats.Negative); err != nil {
+		return nil, fmt.Errorf("GetFeedbackStats query error: %w", err)
+	}
+	if stats.Total > 0 {
+		stats.PositiveRate = (float64(stats.Positive) / float64(stats.Total)) * 100
+	}
+	return &stats, nil
+}
+
+func (s *Store) GetSessionStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.SessionStats, error) {
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	args = append(args, filterArgs...)
+
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(AVG(traces), 0)::float8,
+			COALESCE(AVG(cost), 0)::float8,
+			COALESCE(AVG(EXTRACT(EPOCH FROM (last_trace_at - first_trace_at)) * 1000), 0)::float8
+		FROM (
+			SELECT
+				COUNT(DISTINCT t.id) as traces,
+				COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as cost,
+				MIN(t.created_at) as first_trace_at,
+				MAX(t.created_at) as last_trace_at
+			FROM traces t
+			LEFT JOIN spans s ON s.trace_id = t.id
+			WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+				AND t.session_id IS NOT NULL AND t.session_id != ''
+	` + filterSQL + `
+			GROUP BY t.session_id
+		) sessions
+	`
+	var stats entity.SessionStats
+	var avgDuration float64
+	if err := s.reader.QueryRow(ctx, query, args...).Scan(
+		&stats.Sessions, &stats.AvgTracesPerSession, &stats.AvgCostUSD, &avgDuration); err != nil {
+		return nil, fmt.Errorf("GetSessionStats query error: %w", err)
+	}
+	stats.AvgDurationMs = int(avgDuration)
+
+	// New sessions: those whose first trace ever falls in the period
+	newQuery := `
+		SELECT date_trunc('day', first_trace_at) as day, COUNT(*)
+		FROM (
+			SELECT MIN(t.created_at) as first_trace_at
+			FROM traces t
+			WHERE t.project_id = $1 AND t.session_id IS NOT NULL AND t.session_id != ''
+	` + filterSQL + `
+			GROUP BY t.session_id
+		) firsts
+		WHERE first_trace_at >= $2 AND first_trace_at <= $3
+		GROUP BY day
+		ORDER BY day
+	`
+	rows, err := s.reader.Query(ctx, newQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessionStats new sessions query error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dp entity.SessionDataPoint
+		if err := rows.Scan(&dp.Time, &dp.NewSessions); err != nil {
+			return nil, fmt.Errorf("GetSessionStats new sessions scan error: %w", err)
+		}
+		stats.NewSessions = append(stats.NewSessions, dp)
+	}
+	return &stats, rows.Err()
+}
+
 func (s *Store) GetTagStats(ctx context.Context, projectID string, q entity.AnalyticsQuery, prefix string) ([]entity.TagStats, error) {
 	query := `
 		SELECT
 			tag,
 			COUNT(DISTINCT t.id) as traces,
-			COALESCE(SUM(s.input_tokens + s.output_tokens), 0) as total_tokens,
+			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 			COALESCE(SUM(s.cost_usd), 0) as total_cost,
 			COALESCE(AVG(s.duration_ms), 0) as avg_latency
 		FROM traces t
 		JOIN spans s ON s.trace_id = t.id,
-		unnest(string_to_array(trim(both '[]' from t.tags::text), ',')) as raw_tag,
-		LATERAL (SELECT trim(both ' "' from raw_tag) as tag) cleaned
+		jsonb_array_elements_text(CASE WHEN jsonb_typeof(t.tags) = 'array' THEN t.tags ELSE '[]' END) as tag
 		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
-			AND ($4 = '' OR trim(both ' "' from raw_tag) LIKE $4 || '%')
+			AND ($4 = '' OR tag LIKE $4 || '%')
 	`
 
 	args := []interface{}{projectID, q.From, q.To, prefix}
@@ -1071,7 +2030,7 @@ func (s *Store) GetTagStats(ctx context.Context, projectID string, q entity.Anal
 		ORDER BY total_cost DESC
 	`
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetTagStats query error: %w", err)
 	}
@@ -1121,7 +2080,7 @@ func (s *Store) GetTopUsers(ctx context.Context, projectID string, q entity.Anal
 	`, len(args)+1)
 	args = append(args, limit)
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetTopUsers query error: %w", err)
 	}
@@ -1140,6 +2099,165 @@ func (s *Store) GetTopUsers(ctx context.Context, projectID string, q entity.Anal
 	return results, nil
 }
 
+func (s *Store) GetToolViolationStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ToolViolationStats, error) {
+	query := `
+		SELECT
+			v->>'tool' as tool,
+			COUNT(*) as violations,
+			COUNT(DISTINCT s.id) as spans
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id,
+		jsonb_array_elements(CASE WHEN jsonb_typeof(s.metadata->'tool_arg_violations') = 'array'
+			THEN s.metadata->'tool_arg_violations' ELSE '[]'::jsonb END) as v
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	query += `
+		GROUP BY tool
+		ORDER BY violations DESC, tool
+	`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetToolViolationStats query error: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.ToolViolationStats
+	for rows.Next() {
+		var v entity.ToolViolationStats
+		if err := rows.Scan(&v.Tool, &v.Violations, &v.Spans); err != nil {
+			return nil, fmt.Errorf("GetToolViolationStats scan error: %w", err)
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetEvalStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.EvalStats, error) {
+	query := `
+		SELECT
+			s.name,
+			COALESCE(s.metadata->'eval_verdict'->>'label', '') as label,
+			COUNT(*) as verdicts,
+			COUNT(s.metadata->'eval_verdict'->'score') as scored,
+			AVG((s.metadata->'eval_verdict'->>'score')::float8) as avg_score
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+			AND s.type = 'eval' AND s.metadata->'eval_verdict' IS NOT NULL
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	query += `
+		GROUP BY s.name, label
+		ORDER BY s.name, verdicts DESC, label
+	`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetEvalStats query error: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.EvalStats
+	for rows.Next() {
+		var e entity.EvalStats
+		if err := rows.Scan(&e.Name, &e.Label, &e.Verdicts, &e.Scored, &e.AvgScore); err != nil {
+			return nil, fmt.Errorf("GetEvalStats scan error: %w", err)
+		}
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetUnpricedModels(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.UnpricedModelStats, error) {
+	query := `
+		SELECT
+			COALESCE(s.provider, '') as provider,
+			COALESCE(s.model, '') as model,
+			COUNT(*) as spans
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+			AND s.metadata @> '{"unpriced_model": true}'
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	query += `
+		GROUP BY provider, model
+		ORDER BY spans DESC, provider, model
+	`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetUnpricedModels query error: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.UnpricedModelStats
+	for rows.Next() {
+		var m entity.UnpricedModelStats
+		if err := rows.Scan(&m.Provider, &m.Model, &m.Spans); err != nil {
+			return nil, fmt.Errorf("GetUnpricedModels scan error: %w", err)
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetStorageStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.StorageStats, error) {
+	query := `
+		SELECT
+			s.type,
+			COUNT(*) as spans,
+			COALESCE(SUM(s.input_bytes), 0)::bigint as input_bytes,
+			COALESCE(SUM(s.output_bytes), 0)::bigint as output_bytes
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	query += `
+		GROUP BY s.type
+		ORDER BY input_bytes + output_bytes DESC, s.type
+	`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetStorageStats query error: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.StorageStats
+	for rows.Next() {
+		var st entity.StorageStats
+		if err := rows.Scan(&st.Type, &st.Spans, &st.InputBytes, &st.OutputBytes); err != nil {
+			return nil, fmt.Errorf("GetStorageStats scan error: %w", err)
+		}
+		results = append(results, st)
+	}
+	return results, rows.Err()
+}
+
 func (s *Store) GetHourlyHeatmap(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.HourlyHeatmap, error) {
 	query := `
 		SELECT
@@ -1163,7 +2281,7 @@ func (s *Store) GetHourlyHeatmap(ctx context.Context, projectID string, q entity
 		ORDER BY day, hour
 	`
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetHourlyHeatmap query error: %w", err)
 	}
@@ -1233,7 +2351,7 @@ func (s *Store) GetLatencyDistribution(ctx context.Context, projectID string, q
 		ORDER BY min_ms
 	`
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetLatencyDistribution query error: %w", err)
 	}
@@ -1250,12 +2368,80 @@ func (s *Store) GetLatencyDistribution(ctx context.Context, projectID string, q
 	return results, nil
 }
 
+// GetLatencyHistogram counts span durations per bucket. width_bucket over the
+// edges numbers a span's bucket: 0 below the first edge, len(edges) from the
+// last one.
+func (s *Store) GetLatencyHistogram(ctx context.Context, projectID string, q entity.LatencyHistogramQuery) ([]entity.HistogramBucket, error) {
+	query := `
+		SELECT width_bucket(s.duration_ms, $4::int[]) as bucket, COUNT(*) as count
+		FROM spans s
+		JOIN traces t ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+			AND s.duration_ms IS NOT NULL
+	`
+
+	args := []interface{}{projectID, q.From, q.To, q.Edges}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 4)
+	query += filterSQL
+	args = append(args, filterArgs...)
+	if q.SpanType != "" {
+		args = append(args, string(q.SpanType))
+		query += fmt.Sprintf(" AND s.type = $%d", len(args))
+	}
+	if q.Model != "" {
+		args = append(args, q.Model)
+		query += fmt.Sprintf(" AND s.model = $%d", len(args))
+	}
+	query += ` GROUP BY bucket`
+
+	rows, err := s.reader.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatencyHistogram query error: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := entity.NewHistogramBuckets(q.Edges)
+	for rows.Next() {
+		var i, count int
+		if err := rows.Scan(&i, &count); err != nil {
+			return nil, fmt.Errorf("GetLatencyHistogram scan error: %w", err)
+		}
+		buckets[i].Count = count
+	}
+	return buckets, rows.Err()
+}
+
+func (s *Store) GetLatencyPercentiles(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.LatencyPercentiles, error) {
+	query := `
+		SELECT
+			COALESCE(PERCENTILE_CONT(0.50) WITHIN GROUP (ORDER BY s.duration_ms), 0)::int as p50,
+			COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY s.duration_ms), 0)::int as p95,
+			COALESCE(PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY s.duration_ms), 0)::int as p99
+		FROM spans s
+		JOIN traces t ON s.trace_id = t.id
+		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
+			AND s.duration_ms IS NOT NULL AND s.duration_ms > 0
+	`
+
+	args := []interface{}{projectID, q.From, q.To}
+	filterSQL, filterArgs := buildAnalyticsFilters(q.Filter, 3)
+	query += filterSQL
+	args = append(args, filterArgs...)
+
+	var p entity.LatencyPercentiles
+	if err := s.reader.QueryRow(ctx, query, args...).Scan(&p.P50, &p.P95, &p.P99); err != nil {
+		return nil, fmt.Errorf("GetLatencyPercentiles query error: %w", err)
+	}
+	return &p, nil
+}
+
 func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error) {
 	truncTo := "day"
 	if opts.Granularity == "hour" {
 		truncTo = "hour"
 	}
 
+	filterSQL, filterArgs := buildAnalyticsFilters(opts.Filter, 3)
 	query := fmt.Sprintf(`
 		SELECT
 			date_trunc('%s', t.created_at) as time,
@@ -1265,12 +2451,14 @@ func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts
 		FROM spans s
 		JOIN traces t ON s.trace_id = t.id
 		WHERE t.project_id = $1 AND t.created_at >= $2 AND t.created_at <= $3
-			AND s.duration_ms IS NOT NULL AND s.duration_ms > 0
+			AND s.duration_ms IS NOT NULL AND s.duration_ms > 0%s
 		GROUP BY date_trunc('%s', t.created_at)
 		ORDER BY time
-	`, truncTo, truncTo)
+	`, truncTo, filterSQL, truncTo)
 
-	rows, err := s.pool.Query(ctx, query, projectID, opts.From, opts.To)
+	args := []interface{}{projectID, opts.From, opts.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetLatencyTimeSeries query error: %w", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -338,7 +339,7 @@ func TestPostgresSpanOperations(t *testing.T) {
 			StartedAt:    time.Now(),
 		}
 
-		if err := store.CreateSpan(ctx, span); err != nil {
+		if err := store.CreateSpan(ctx, project.ID, span); err != nil {
 			t.Fatalf("CreateSpan failed: %v", err)
 		}
 
@@ -372,7 +373,7 @@ func TestPostgresSpanOperations(t *testing.T) {
 			},
 		}
 
-		if err := store.CreateSpans(ctx, spans); err != nil {
+		if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
 			t.Fatalf("CreateSpans failed: %v", err)
 		}
 
@@ -415,7 +416,7 @@ func TestPostgresSpanOperations(t *testing.T) {
 				StartedAt:    time.Now(),
 			},
 		}
-		store.CreateSpans(ctx, spans)
+		store.CreateSpans(ctx, project.ID, spans)
 
 		got, _ := store.GetTrace(ctx, project.ID, newTrace.ID)
 
@@ -462,7 +463,7 @@ func TestPostgresAnalytics(t *testing.T) {
 		}
 		store.CreateTrace(ctx, trace)
 
-		store.CreateSpan(ctx, &entity.Span{
+		store.CreateSpan(ctx, project.ID, &entity.Span{
 			TraceID:      trace.ID,
 			Type:         entity.SpanTypeLLM,
 			Name:         fmt.Sprintf("span-%d", i),
@@ -583,7 +584,7 @@ func TestPostgresSessions(t *testing.T) {
 		}
 		store.CreateTrace(ctx, trace)
 
-		store.CreateSpan(ctx, &entity.Span{
+		store.CreateSpan(ctx, project.ID, &entity.Span{
 			TraceID:      trace.ID,
 			Type:         entity.SpanTypeLLM,
 			Name:         fmt.Sprintf("span-%d", i),
@@ -619,3 +620,99 @@ func TestPostgresSessions(t *testing.T) {
 func ptr(i int) *int          { return &i }
 func ptrS(s string) *string   { return &s }
 func ptrF(f float64) *float64 { return &f }
+
+func TestPostgresStatementTimeout(t *testing.T) {
+	dsn := getTestDSN(t)
+
+	store, err := NewWithOptions(dsn, Options{StatementTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	t.Run("server aborts long statements", func(t *testing.T) {
+		_, err := store.pool.Exec(context.Background(), "SELECT pg_sleep(2)")
+		if err == nil || !strings.Contains(err.Error(), "statement timeout") {
+			t.Errorf("expected statement timeout error, got %v", err)
+		}
+	})
+
+	t.Run("client cancellation aborts the query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if _, err := store.pool.Exec(ctx, "SELECT pg_sleep(2)"); err == nil {
+			t.Fatal("expected query to be aborted")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("query took %v to abort after cancellation", elapsed)
+		}
+	})
+}
+
+func TestPostgresTracesMetrics(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	project := &entity.Project{
+		Name:       "Metrics Test",
+		APIKey:     fmt.Sprintf("le_metrics_%d", time.Now().UnixNano()),
+		APIKeyHash: "metrics_hash",
+		OwnerEmail: "metrics@example.com",
+	}
+	store.CreateProject(ctx, project)
+
+	var traceIDs []string
+	for i := 0; i < 3; i++ {
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		store.CreateTrace(ctx, trace)
+		traceIDs = append(traceIDs, trace.ID)
+
+		// Trace i gets i spans: the first has none
+		var spans []entity.Span
+		for j := 0; j < i; j++ {
+			spans = append(spans, entity.Span{
+				TraceID:      trace.ID,
+				Type:         entity.SpanTypeLLM,
+				Name:         "chat",
+				Status:       entity.SpanStatusSuccess,
+				InputTokens:  ptr(10 * (j + 1)),
+				OutputTokens: ptr(5),
+				DurationMs:   ptr(100 * (i + j)),
+				CostUSD:      ptrF(0.25 * float64(j+1)),
+				StartedAt:    time.Now(),
+			})
+		}
+		if len(spans) > 0 {
+			if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
+				t.Fatalf("CreateSpans failed: %v", err)
+			}
+		}
+	}
+
+	metrics, err := store.GetTracesMetrics(ctx, project.ID, traceIDs)
+	if err != nil {
+		t.Fatalf("GetTracesMetrics failed: %v", err)
+	}
+	if len(metrics) != len(traceIDs) {
+		t.Errorf("expected metrics for %d traces, got %v", len(traceIDs), metrics)
+	}
+	for _, id := range traceIDs {
+		trace, err := store.GetTrace(ctx, project.ID, id)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		want := entity.TraceMetrics{
+			TotalSpans:      trace.TotalSpans,
+			TotalTokens:     trace.TotalTokens,
+			TotalCostUSD:    trace.TotalCostUSD,
+			TotalDurationMs: trace.TotalDurationMs,
+		}
+		if got := metrics[id]; got != want {
+			t.Errorf("trace %s: expected %+v as from GetTrace, got %+v", id, want, got)
+		}
+	}
+}
@@ -0,0 +1,597 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// ErrUnknownRegion is returned for a project whose region has no store
+var ErrUnknownRegion = errors.New("no store configured for region")
+
+// regionCacheTTL bounds how long a project's region is cached, i.e. how soon
+// a region change takes effect
+const regionCacheTTL = time.Minute
+
+// RegionalStore keeps each project's traces, spans, scores and analytics in
+// the store of the project's region (ProjectSettings.Region), for data
+// residency. Projects and users live in the primary store, where regions are
+// looked up; projects without a region use the default store.
+//
+// A project whose region has no configured store fails every trace read and
+// write instead of falling back, so its data never leaves the region.
+// Changing a project's region only routes new data: existing data stays where
+// it was written.
+type RegionalStore struct {
+	primary  repository.Store
+	fallback repository.Store
+	regions  map[string]repository.Store
+
+	mu    sync.Mutex
+	cache map[string]cachedRegion
+	now   func() time.Time
+}
+
+type cachedRegion struct {
+	region  string
+	expires time.Time
+}
+
+var _ repository.Store = (*RegionalStore)(nil)
+
+// NewRegional creates a store routing project data to regions by each
+// project's region setting. primary holds projects and users; fallback holds
+// the data of projects without a region (it may be primary itself).
+func NewRegional(primary, fallback repository.Store, regions map[string]repository.Store) *RegionalStore {
+	return &RegionalStore{
+		primary:  primary,
+		fallback: fallback,
+		regions:  regions,
+		cache:    make(map[string]cachedRegion),
+		now:      time.Now,
+	}
+}
+
+// NewRegionalFromURLs opens one store per region from a region -> database URL map
+func NewRegionalFromURLs(primary, fallback repository.Store, urls map[string]string, opts Options) (*RegionalStore, error) {
+	regions := make(map[string]repository.Store, len(urls))
+	for region, url := range urls {
+		s, err := NewWithOptions(url, opts)
+		if err != nil {
+			for _, opened := range regions {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("region %s: %w", region, err)
+		}
+		regions[region] = s
+	}
+	return NewRegional(primary, fallback, regions), nil
+}
+
+// StoreFor returns the store holding the project's data
+func (s *RegionalStore) StoreFor(ctx context.Context, projectID string) (repository.Store, error) {
+	region, err := s.regionOf(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		return s.fallback, nil
+	}
+	store, ok := s.regions[region]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRegion, region)
+	}
+	return store, nil
+}
+
+// regionOf returns the project's region, cached for regionCacheTTL
+func (s *RegionalStore) regionOf(ctx context.Context, projectID string) (string, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	cached, ok := s.cache[projectID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.region, nil
+	}
+
+	project, err := s.primary.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.cache[projectID] = cachedRegion{region: project.Settings.Region, expires: now.Add(regionCacheTTL)}
+	s.mu.Unlock()
+	return project.Settings.Region, nil
+}
+
+// owned returns the data stores this store manages, without duplicates and
+// without the primary store, whose lifecycle belongs to the caller
+func (s *RegionalStore) owned() []repository.Store {
+	var stores []repository.Store
+	seen := map[repository.Store]bool{s.primary: true}
+	for _, st := range append([]repository.Store{s.fallback}, s.sortedRegions()...) {
+		if !seen[st] {
+			seen[st] = true
+			stores = append(stores, st)
+		}
+	}
+	return stores
+}
+
+// sortedRegions returns the region stores in region name order
+func (s *RegionalStore) sortedRegions() []repository.Store {
+	names := make([]string, 0, len(s.regions))
+	for name := range s.regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stores := make([]repository.Store, len(names))
+	for i, name := range names {
+		stores[i] = s.regions[name]
+	}
+	return stores
+}
+
+// ============================================
+// LIFECYCLE
+// ============================================
+
+func (s *RegionalStore) Migrate(ctx context.Context) error {
+	for _, st := range s.owned() {
+		if err := st.Migrate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RegionalStore) Ping(ctx context.Context) error {
+	for _, st := range s.owned() {
+		if err := st.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the default and region stores, even if some fail. The primary
+// store is left open.
+func (s *RegionalStore) Close() error {
+	var errs []error
+	for _, st := range s.owned() {
+		if err := st.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OptimizeTable optimizes table on every data store that supports it, summing
+// the part counts (see repository.TableOptimizer)
+func (s *RegionalStore) OptimizeTable(ctx context.Context, table string) (*entity.TableOptimization, error) {
+	return optimizeStores(ctx, table, s.owned())
+}
+
+// ============================================
+// PROJECT OPERATIONS
+// ============================================
+
+func (s *RegionalStore) CreateProject(ctx context.Context, p *entity.Project) error {
+	return s.primary.CreateProject(ctx, p)
+}
+
+func (s *RegionalStore) GetProjectByID(ctx context.Context, id string) (*entity.Project, error) {
+	return s.primary.GetProjectByID(ctx, id)
+}
+
+func (s *RegionalStore) GetProjectByAPIKeyHash(ctx context.Context, hash string) (*entity.Project, error) {
+	return s.primary.GetProjectByAPIKeyHash(ctx, hash)
+}
+
+func (s *RegionalStore) UpdateProject(ctx context.Context, id string, updates entity.ProjectUpdate) error {
+	return s.primary.UpdateProject(ctx, id, updates)
+}
+
+func (s *RegionalStore) DeleteProject(ctx context.Context, id string) error {
+	return s.primary.DeleteProject(ctx, id)
+}
+
+func (s *RegionalStore) ListProjectsByOwner(ctx context.Context, email string) ([]entity.Project, error) {
+	return s.primary.ListProjectsByOwner(ctx, email)
+}
+
+func (s *RegionalStore) ListProjects(ctx context.Context) ([]entity.Project, error) {
+	return s.primary.ListProjects(ctx)
+}
+
+func (s *RegionalStore) IsProjectOwner(ctx context.Context, projectID, ownerEmail string) (bool, error) {
+	return s.primary.IsProjectOwner(ctx, projectID, ownerEmail)
+}
+
+func (s *RegionalStore) RotateAPIKey(ctx context.Context, id string, newKey, newHash string) error {
+	return s.primary.RotateAPIKey(ctx, id, newKey, newHash)
+}
+
+func (s *RegionalStore) RecordAPIKeyUsage(ctx context.Context, projectID, keyHash string, lastUsedAt time.Time, requests int64) error {
+	return s.primary.RecordAPIKeyUsage(ctx, projectID, keyHash, lastUsedAt, requests)
+}
+
+func (s *RegionalStore) GetAPIKeyUsage(ctx context.Context, keyHash string) (*entity.APIKeyUsage, error) {
+	return s.primary.GetAPIKeyUsage(ctx, keyHash)
+}
+
+// ============================================
+// TRACE OPERATIONS
+// ============================================
+
+func (s *RegionalStore) CreateTrace(ctx context.Context, t *entity.Trace) error {
+	store, err := s.StoreFor(ctx, t.ProjectID)
+	if err != nil {
+		return err
+	}
+	return store.CreateTrace(ctx, t)
+}
+
+func (s *RegionalStore) UpdateTrace(ctx context.Context, projectID, traceID string, updates entity.TraceUpdate) error {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return store.UpdateTrace(ctx, projectID, traceID, updates)
+}
+
+func (s *RegionalStore) UpdateTraceStatus(ctx context.Context, projectID, traceID string, status entity.TraceStatus) error {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return store.UpdateTraceStatus(ctx, projectID, traceID, status)
+}
+
+func (s *RegionalStore) DeleteAllTraces(ctx context.Context, projectID string) (int64, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteAllTraces(ctx, projectID)
+}
+
+func (s *RegionalStore) DeleteTracesByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.DeleteTracesByFilter(ctx, projectID, filter)
+}
+
+func (s *RegionalStore) CreateSpan(ctx context.Context, projectID string, span *entity.Span) error {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return store.CreateSpan(ctx, projectID, span)
+}
+
+func (s *RegionalStore) CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return store.CreateSpans(ctx, projectID, spans)
+}
+
+func (s *RegionalStore) CreateSpansPartial(ctx context.Context, projectID string, spans []entity.Span) ([]entity.SpanWriteError, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.CreateSpansPartial(ctx, projectID, spans)
+}
+
+func (s *RegionalStore) CreateTracesWithSpans(ctx context.Context, projectID string, traces []*entity.Trace, spans []entity.Span) (int, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.CreateTracesWithSpans(ctx, projectID, traces, spans)
+}
+
+func (s *RegionalStore) UpdateSpan(ctx context.Context, projectID, traceID, spanID string, updates entity.SpanUpdate) error {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return store.UpdateSpan(ctx, projectID, traceID, spanID, updates)
+}
+
+func (s *RegionalStore) ListSpansForRecost(ctx context.Context, projectID string, from, to time.Time, after *entity.SpanCursor, limit int) ([]entity.Span, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListSpansForRecost(ctx, projectID, from, to, after, limit)
+}
+
+func (s *RegionalStore) UpdateSpanCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.UpdateSpanCosts(ctx, projectID, costs)
+}
+
+func (s *RegionalStore) UpdateSpanSubtreeCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.UpdateSpanSubtreeCosts(ctx, projectID, costs)
+}
+
+func (s *RegionalStore) ExpireSpanContent(ctx context.Context, projectID string, cutoff time.Time) (int64, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	return store.ExpireSpanContent(ctx, projectID, cutoff)
+}
+
+func (s *RegionalStore) GetTrace(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetTrace(ctx, projectID, traceID)
+}
+
+func (s *RegionalStore) GetTraceCapped(ctx context.Context, projectID, traceID string, maxSpans int) (*entity.TraceWithSpans, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetTraceCapped(ctx, projectID, traceID, maxSpans)
+}
+
+func (s *RegionalStore) GetTracesMetrics(ctx context.Context, projectID string, traceIDs []string) (map[string]entity.TraceMetrics, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetTracesMetrics(ctx, projectID, traceIDs)
+}
+
+func (s *RegionalStore) ListTraceSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListTraceSpans(ctx, projectID, traceID, limit, offset)
+}
+
+func (s *RegionalStore) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListTraces(ctx, projectID, filter)
+}
+
+func (s *RegionalStore) ListTraceIDs(ctx context.Context, projectID string, after *entity.TraceCursor, limit int) ([]entity.TraceCursor, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListTraceIDs(ctx, projectID, after, limit)
+}
+
+func (s *RegionalStore) SearchSpans(ctx context.Context, projectID string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.SearchSpans(ctx, projectID, filter)
+}
+
+func (s *RegionalStore) SearchSpansByAttribute(ctx context.Context, projectID, key, value string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.SearchSpansByAttribute(ctx, projectID, key, value, filter)
+}
+
+func (s *RegionalStore) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.ListSessions(ctx, projectID, filter)
+}
+
+func (s *RegionalStore) GetProjectFootprint(ctx context.Context, projectID string) (entity.Footprint, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return entity.Footprint{}, err
+	}
+	return store.GetProjectFootprint(ctx, projectID)
+}
+
+// ============================================
+// SCORE OPERATIONS
+// ============================================
+
+func (s *RegionalStore) CreateScore(ctx context.Context, score *entity.Score) error {
+	store, err := s.StoreFor(ctx, score.ProjectID)
+	if err != nil {
+		return err
+	}
+	return store.CreateScore(ctx, score)
+}
+
+// ============================================
+// ANALYTICS OPERATIONS
+// ============================================
+
+func (s *RegionalStore) GetStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.Stats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetUsageTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.DataPoint, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetUsageTimeSeries(ctx, projectID, opts)
+}
+
+func (s *RegionalStore) GetModelStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ModelStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetModelStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetTagStats(ctx context.Context, projectID string, q entity.AnalyticsQuery, prefix string) ([]entity.TagStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetTagStats(ctx, projectID, q, prefix)
+}
+
+func (s *RegionalStore) GetTopUsers(ctx context.Context, projectID string, q entity.AnalyticsQuery, limit int) ([]entity.UserStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetTopUsers(ctx, projectID, q, limit)
+}
+
+func (s *RegionalStore) GetToolViolationStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ToolViolationStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetToolViolationStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetEvalStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.EvalStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetEvalStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetUnpricedModels(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.UnpricedModelStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetUnpricedModels(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetStorageStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.StorageStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetStorageStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetCacheEfficiency(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.CacheEfficiency, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetCacheEfficiency(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetFeedbackStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.FeedbackStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetFeedbackStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetSessionStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.SessionStats, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetSessionStats(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetHourlyHeatmap(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.HourlyHeatmap, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetHourlyHeatmap(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetLatencyDistribution(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.LatencyBucket, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetLatencyDistribution(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetLatencyHistogram(ctx context.Context, projectID string, q entity.LatencyHistogramQuery) ([]entity.HistogramBucket, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetLatencyHistogram(ctx, projectID, q)
+}
+
+func (s *RegionalStore) GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetLatencyTimeSeries(ctx, projectID, opts)
+}
+
+func (s *RegionalStore) GetLatencyPercentiles(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.LatencyPercentiles, error) {
+	store, err := s.StoreFor(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetLatencyPercentiles(ctx, projectID, q)
+}
+
+// ============================================
+// USER OPERATIONS
+// ============================================
+
+func (s *RegionalStore) CreateUser(ctx context.Context, u *entity.User) error {
+	return s.primary.CreateUser(ctx, u)
+}
+
+func (s *RegionalStore) GetUserByID(ctx context.Context, id string) (*entity.User, error) {
+	return s.primary.GetUserByID(ctx, id)
+}
+
+func (s *RegionalStore) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return s.primary.GetUserByEmail(ctx, email)
+}
+
+func (s *RegionalStore) UpdateUser(ctx context.Context, id string, updates entity.UserUpdate) error {
+	return s.primary.UpdateUser(ctx, id, updates)
+}
@@ -0,0 +1,119 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/domain/service"
+	"github.com/lelemon/server/pkg/infrastructure/store"
+)
+
+func newSQLite(t *testing.T, name string) repository.Store {
+	t.Helper()
+	s, err := store.New("sqlite://" + t.TempDir() + "/" + name + ".db")
+	if err != nil {
+		t.Fatalf("failed to create %s store: %v", name, err)
+	}
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate %s store: %v", name, err)
+	}
+	return s
+}
+
+func TestRegionalStore_RoutesByProjectRegion(t *testing.T) {
+	ctx := context.Background()
+	primary := newSQLite(t, "primary")
+	eu := newSQLite(t, "eu")
+	t.Cleanup(func() { primary.Close() })
+
+	regional := store.NewRegional(primary, primary, map[string]repository.Store{"eu": eu})
+	t.Cleanup(func() { regional.Close() })
+
+	newProject := func(key, region string) *entity.Project {
+		p := &entity.Project{Name: key, APIKey: key, APIKeyHash: key, OwnerEmail: "regions@test.com",
+			Settings: entity.ProjectSettings{Region: region}}
+		if err := regional.CreateProject(ctx, p); err != nil {
+			t.Fatalf("failed to create project: %v", err)
+		}
+		return p
+	}
+	euProject := newProject("le_eu", "eu")
+	defaultProject := newProject("le_default", "")
+
+	// Projects live in the primary store whatever their region
+	if _, err := eu.GetProjectByID(ctx, euProject.ID); !errors.Is(err, entity.ErrNotFound) {
+		t.Errorf("expected the EU store to hold no projects, got %v", err)
+	}
+
+	// Ingest writes through the regional store, as the server wires it
+	ingestSvc := ingest.NewService(regional, service.NewPricingCalculator())
+	ingestTrace := func(p *entity.Project, traceID string) {
+		t.Helper()
+		resp, err := ingestSvc.Ingest(ctx, p, &ingest.IngestRequest{Events: []ingest.IngestEvent{{
+			TraceID: traceID, SpanID: traceID + "-span", SpanType: "llm", Provider: "openai", Model: "gpt-4o",
+			Status: "success", Input: "hello", Output: "hi",
+		}}})
+		if err != nil || !resp.Success {
+			t.Fatalf("ingest failed: %v %+v", err, resp)
+		}
+	}
+	ingestTrace(euProject, "eu-trace")
+	ingestTrace(defaultProject, "default-trace")
+
+	t.Run("EU project data is written to the EU store only", func(t *testing.T) {
+		if tr, err := eu.GetTrace(ctx, euProject.ID, "eu-trace"); err != nil || len(tr.Spans) != 1 {
+			t.Fatalf("expected the trace and its span in the EU store, got %+v (%v)", tr, err)
+		}
+		if _, err := primary.GetTrace(ctx, euProject.ID, "eu-trace"); !errors.Is(err, entity.ErrNotFound) {
+			t.Errorf("expected the EU trace to be absent from the default store, got %v", err)
+		}
+	})
+
+	t.Run("EU project reads come from the EU store", func(t *testing.T) {
+		tr, err := regional.GetTrace(ctx, euProject.ID, "eu-trace")
+		if err != nil || len(tr.Spans) != 1 {
+			t.Fatalf("expected to read the EU trace, got %+v (%v)", tr, err)
+		}
+		page, err := regional.ListTraces(ctx, euProject.ID, entity.TraceFilter{})
+		if err != nil || page.Total != 1 {
+			t.Fatalf("expected 1 EU trace listed, got %+v (%v)", page, err)
+		}
+		now := time.Now()
+		stats, err := regional.GetStats(ctx, euProject.ID, entity.AnalyticsQuery{
+			Period: entity.Period{From: now.Add(-time.Hour), To: now.Add(time.Minute)},
+		})
+		if err != nil {
+			t.Fatalf("GetStats: %v", err)
+		}
+		if stats.TotalSpans != 1 {
+			t.Errorf("expected EU stats from the EU store, got %+v", stats)
+		}
+	})
+
+	t.Run("projects without a region use the default store", func(t *testing.T) {
+		if _, err := primary.GetTrace(ctx, defaultProject.ID, "default-trace"); err != nil {
+			t.Errorf("expected the trace in the default store: %v", err)
+		}
+		if _, err := eu.GetTrace(ctx, defaultProject.ID, "default-trace"); !errors.Is(err, entity.ErrNotFound) {
+			t.Errorf("expected the trace to be absent from the EU store, got %v", err)
+		}
+	})
+
+	t.Run("an unconfigured region fails instead of falling back", func(t *testing.T) {
+		us := newProject("le_us", "us")
+		err := regional.CreateTrace(ctx, &entity.Trace{ProjectID: us.ID, Status: entity.TraceStatusActive})
+		if !errors.Is(err, store.ErrUnknownRegion) {
+			t.Fatalf("expected ErrUnknownRegion, got %v", err)
+		}
+		for name, s := range map[string]repository.Store{"default": primary, "eu": eu} {
+			if page, err := s.ListTraces(ctx, us.ID, entity.TraceFilter{}); err != nil || page.Total != 0 {
+				t.Errorf("expected no us traces in the %s store, got %+v (%v)", name, page, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,410 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// ShardedStore spreads data across several stores (e.g. one ClickHouse cluster
+// per region) by project: every project-scoped call goes to the shard chosen by
+// hashing the project ID, so a project's traces and spans always live together.
+// Lookups without a project (API key hash, project listings) fan out to all
+// shards; users are not project data and live on the first shard.
+//
+// The mapping depends on the number of shards. Adding or removing a shard
+// moves projects to other shards without moving their existing data.
+type ShardedStore struct {
+	shards []repository.Store
+}
+
+var _ repository.Store = (*ShardedStore)(nil)
+
+// NewSharded creates a store that routes across the given shards, in order
+func NewSharded(shards ...repository.Store) (*ShardedStore, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("sharded store needs at least one shard")
+	}
+	return &ShardedStore{shards: shards}, nil
+}
+
+// NewShardedFromURLs opens one store per database URL and shards across them.
+// The order of urls defines the shard numbers and must stay stable.
+func NewShardedFromURLs(urls []string, opts Options) (*ShardedStore, error) {
+	shards := make([]repository.Store, 0, len(urls))
+	for i, url := range urls {
+		s, err := NewWithOptions(url, opts)
+		if err != nil {
+			for _, opened := range shards {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		shards = append(shards, s)
+	}
+	return NewSharded(shards...)
+}
+
+// ShardFor returns the index of the shard holding the project's data
+func (s *ShardedStore) ShardFor(projectID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(projectID))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// shard returns the store holding the project's data
+func (s *ShardedStore) shard(projectID string) repository.Store {
+	return s.shards[s.ShardFor(projectID)]
+}
+
+// home is the shard for data that doesn't belong to a project
+func (s *ShardedStore) home() repository.Store {
+	return s.shards[0]
+}
+
+// forEach runs fn on every shard, stopping at the first error
+func (s *ShardedStore) forEach(fn func(i int, shard repository.Store) error) error {
+	for i, shard := range s.shards {
+		if err := fn(i, shard); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ============================================
+// LIFECYCLE
+// ============================================
+
+func (s *ShardedStore) Migrate(ctx context.Context) error {
+	return s.forEach(func(_ int, shard repository.Store) error { return shard.Migrate(ctx) })
+}
+
+func (s *ShardedStore) Ping(ctx context.Context) error {
+	return s.forEach(func(_ int, shard repository.Store) error { return shard.Ping(ctx) })
+}
+
+// Close closes every shard, even if some fail
+func (s *ShardedStore) Close() error {
+	var errs []error
+	for i, shard := range s.shards {
+		if err := shard.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OptimizeTable optimizes table on every shard that supports it, summing the
+// part counts (see repository.TableOptimizer)
+func (s *ShardedStore) OptimizeTable(ctx context.Context, table string) (*entity.TableOptimization, error) {
+	return optimizeStores(ctx, table, s.shards)
+}
+
+// ============================================
+// PROJECT OPERATIONS
+// ============================================
+
+// CreateProject assigns the project ID up front (when unset) so it can be routed
+func (s *ShardedStore) CreateProject(ctx context.Context, p *entity.Project) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return s.shard(p.ID).CreateProject(ctx, p)
+}
+
+func (s *ShardedStore) GetProjectByID(ctx context.Context, id string) (*entity.Project, error) {
+	return s.shard(id).GetProjectByID(ctx, id)
+}
+
+// GetProjectByAPIKeyHash asks every shard until one has the key
+func (s *ShardedStore) GetProjectByAPIKeyHash(ctx context.Context, hash string) (*entity.Project, error) {
+	for i, shard := range s.shards {
+		p, err := shard.GetProjectByAPIKeyHash(ctx, hash)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, entity.ErrNotFound) {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil, entity.ErrNotFound
+}
+
+func (s *ShardedStore) UpdateProject(ctx context.Context, id string, updates entity.ProjectUpdate) error {
+	return s.shard(id).UpdateProject(ctx, id, updates)
+}
+
+func (s *ShardedStore) DeleteProject(ctx context.Context, id string) error {
+	return s.shard(id).DeleteProject(ctx, id)
+}
+
+// ListProjectsByOwner merges every shard's projects, newest first
+func (s *ShardedStore) ListProjectsByOwner(ctx context.Context, email string) ([]entity.Project, error) {
+	var projects []entity.Project
+	err := s.forEach(func(_ int, shard repository.Store) error {
+		found, err := shard.ListProjectsByOwner(ctx, email)
+		projects = append(projects, found...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(projects, func(i, j int) bool { return projects[i].CreatedAt.After(projects[j].CreatedAt) })
+	return projects, nil
+}
+
+// ListProjects merges every shard's projects, oldest first
+func (s *ShardedStore) ListProjects(ctx context.Context) ([]entity.Project, error) {
+	var projects []entity.Project
+	err := s.forEach(func(_ int, shard repository.Store) error {
+		found, err := shard.ListProjects(ctx)
+		projects = append(projects, found...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(projects, func(i, j int) bool { return projects[i].CreatedAt.Before(projects[j].CreatedAt) })
+	return projects, nil
+}
+
+func (s *ShardedStore) IsProjectOwner(ctx context.Context, projectID, ownerEmail string) (bool, error) {
+	return s.shard(projectID).IsProjectOwner(ctx, projectID, ownerEmail)
+}
+
+func (s *ShardedStore) RotateAPIKey(ctx context.Context, id string, newKey, newHash string) error {
+	return s.shard(id).RotateAPIKey(ctx, id, newKey, newHash)
+}
+
+func (s *ShardedStore) RecordAPIKeyUsage(ctx context.Context, projectID, keyHash string, lastUsedAt time.Time, requests int64) error {
+	return s.shard(projectID).RecordAPIKeyUsage(ctx, projectID, keyHash, lastUsedAt, requests)
+}
+
+// GetAPIKeyUsage asks every shard until one has usage for the key; a key that
+// was never used gets empty usage, as from a single store
+func (s *ShardedStore) GetAPIKeyUsage(ctx context.Context, keyHash string) (*entity.APIKeyUsage, error) {
+	usage := &entity.APIKeyUsage{}
+	err := s.forEach(func(_ int, shard repository.Store) error {
+		if usage.LastUsedAt != nil {
+			return nil
+		}
+		found, err := shard.GetAPIKeyUsage(ctx, keyHash)
+		if err == nil && found.LastUsedAt != nil {
+			usage = found
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// ============================================
+// TRACE OPERATIONS
+// ============================================
+
+func (s *ShardedStore) CreateTrace(ctx context.Context, t *entity.Trace) error {
+	return s.shard(t.ProjectID).CreateTrace(ctx, t)
+}
+
+func (s *ShardedStore) UpdateTrace(ctx context.Context, projectID, traceID string, updates entity.TraceUpdate) error {
+	return s.shard(projectID).UpdateTrace(ctx, projectID, traceID, updates)
+}
+
+func (s *ShardedStore) UpdateTraceStatus(ctx context.Context, projectID, traceID string, status entity.TraceStatus) error {
+	return s.shard(projectID).UpdateTraceStatus(ctx, projectID, traceID, status)
+}
+
+func (s *ShardedStore) DeleteAllTraces(ctx context.Context, projectID string) (int64, error) {
+	return s.shard(projectID).DeleteAllTraces(ctx, projectID)
+}
+
+func (s *ShardedStore) DeleteTracesByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error) {
+	return s.shard(projectID).DeleteTracesByFilter(ctx, projectID, filter)
+}
+
+func (s *ShardedStore) CreateSpan(ctx context.Context, projectID string, span *entity.Span) error {
+	return s.shard(projectID).CreateSpan(ctx, projectID, span)
+}
+
+func (s *ShardedStore) CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error {
+	return s.shard(projectID).CreateSpans(ctx, projectID, spans)
+}
+
+func (s *ShardedStore) CreateSpansPartial(ctx context.Context, projectID string, spans []entity.Span) ([]entity.SpanWriteError, error) {
+	return s.shard(projectID).CreateSpansPartial(ctx, projectID, spans)
+}
+
+func (s *ShardedStore) CreateTracesWithSpans(ctx context.Context, projectID string, traces []*entity.Trace, spans []entity.Span) (int, error) {
+	return s.shard(projectID).CreateTracesWithSpans(ctx, projectID, traces, spans)
+}
+
+func (s *ShardedStore) UpdateSpan(ctx context.Context, projectID, traceID, spanID string, updates entity.SpanUpdate) error {
+	return s.shard(projectID).UpdateSpan(ctx, projectID, traceID, spanID, updates)
+}
+
+func (s *ShardedStore) ListSpansForRecost(ctx context.Context, projectID string, from, to time.Time, after *entity.SpanCursor, limit int) ([]entity.Span, error) {
+	return s.shard(projectID).ListSpansForRecost(ctx, projectID, from, to, after, limit)
+}
+
+func (s *ShardedStore) UpdateSpanCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.shard(projectID).UpdateSpanCosts(ctx, projectID, costs)
+}
+
+func (s *ShardedStore) UpdateSpanSubtreeCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.shard(projectID).UpdateSpanSubtreeCosts(ctx, projectID, costs)
+}
+
+func (s *ShardedStore) ExpireSpanContent(ctx context.Context, projectID string, cutoff time.Time) (int64, error) {
+	return s.shard(projectID).ExpireSpanContent(ctx, projectID, cutoff)
+}
+
+func (s *ShardedStore) GetTrace(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error) {
+	return s.shard(projectID).GetTrace(ctx, projectID, traceID)
+}
+
+func (s *ShardedStore) GetTraceCapped(ctx context.Context, projectID, traceID string, maxSpans int) (*entity.TraceWithSpans, error) {
+	return s.shard(projectID).GetTraceCapped(ctx, projectID, traceID, maxSpans)
+}
+
+func (s *ShardedStore) GetTracesMetrics(ctx context.Context, projectID string, traceIDs []string) (map[string]entity.TraceMetrics, error) {
+	return s.shard(projectID).GetTracesMetrics(ctx, projectID, traceIDs)
+}
+
+func (s *ShardedStore) ListTraceSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) {
+	return s.shard(projectID).ListTraceSpans(ctx, projectID, traceID, limit, offset)
+}
+
+func (s *ShardedStore) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
+	return s.shard(projectID).ListTraces(ctx, projectID, filter)
+}
+
+func (s *ShardedStore) ListTraceIDs(ctx context.Context, projectID string, after *entity.TraceCursor, limit int) ([]entity.TraceCursor, error) {
+	return s.shard(projectID).ListTraceIDs(ctx, projectID, after, limit)
+}
+
+func (s *ShardedStore) SearchSpans(ctx context.Context, projectID string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	return s.shard(projectID).SearchSpans(ctx, projectID, filter)
+}
+
+func (s *ShardedStore) SearchSpansByAttribute(ctx context.Context, projectID, key, value string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	return s.shard(projectID).SearchSpansByAttribute(ctx, projectID, key, value, filter)
+}
+
+func (s *ShardedStore) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
+	return s.shard(projectID).ListSessions(ctx, projectID, filter)
+}
+
+func (s *ShardedStore) GetProjectFootprint(ctx context.Context, projectID string) (entity.Footprint, error) {
+	return s.shard(projectID).GetProjectFootprint(ctx, projectID)
+}
+
+// ============================================
+// SCORE OPERATIONS
+// ============================================
+
+func (s *ShardedStore) CreateScore(ctx context.Context, score *entity.Score) error {
+	return s.shard(score.ProjectID).CreateScore(ctx, score)
+}
+
+// ============================================
+// ANALYTICS OPERATIONS
+// ============================================
+
+func (s *ShardedStore) GetStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.Stats, error) {
+	return s.shard(projectID).GetStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetUsageTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.DataPoint, error) {
+	return s.shard(projectID).GetUsageTimeSeries(ctx, projectID, opts)
+}
+
+func (s *ShardedStore) GetModelStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ModelStats, error) {
+	return s.shard(projectID).GetModelStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetTagStats(ctx context.Context, projectID string, q entity.AnalyticsQuery, prefix string) ([]entity.TagStats, error) {
+	return s.shard(projectID).GetTagStats(ctx, projectID, q, prefix)
+}
+
+func (s *ShardedStore) GetTopUsers(ctx context.Context, projectID string, q entity.AnalyticsQuery, limit int) ([]entity.UserStats, error) {
+	return s.shard(projectID).GetTopUsers(ctx, projectID, q, limit)
+}
+
+func (s *ShardedStore) GetToolViolationStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ToolViolationStats, error) {
+	return s.shard(projectID).GetToolViolationStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetEvalStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.EvalStats, error) {
+	return s.shard(projectID).GetEvalStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetUnpricedModels(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.UnpricedModelStats, error) {
+	return s.shard(projectID).GetUnpricedModels(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetStorageStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.StorageStats, error) {
+	return s.shard(projectID).GetStorageStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetCacheEfficiency(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.CacheEfficiency, error) {
+	return s.shard(projectID).GetCacheEfficiency(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetFeedbackStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.FeedbackStats, error) {
+	return s.shard(projectID).GetFeedbackStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetSessionStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.SessionStats, error) {
+	return s.shard(projectID).GetSessionStats(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetHourlyHeatmap(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.HourlyHeatmap, error) {
+	return s.shard(projectID).GetHourlyHeatmap(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetLatencyDistribution(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.LatencyBucket, error) {
+	return s.shard(projectID).GetLatencyDistribution(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetLatencyHistogram(ctx context.Context, projectID string, q entity.LatencyHistogramQuery) ([]entity.HistogramBucket, error) {
+	return s.shard(projectID).GetLatencyHistogram(ctx, projectID, q)
+}
+
+func (s *ShardedStore) GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error) {
+	return s.shard(projectID).GetLatencyTimeSeries(ctx, projectID, opts)
+}
+
+func (s *ShardedStore) GetLatencyPercentiles(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.LatencyPercentiles, error) {
+	return s.shard(projectID).GetLatencyPercentiles(ctx, projectID, q)
+}
+
+// ============================================
+// USER OPERATIONS
+// ============================================
+
+func (s *ShardedStore) CreateUser(ctx context.Context, u *entity.User) error {
+	return s.home().CreateUser(ctx, u)
+}
+
+func (s *ShardedStore) GetUserByID(ctx context.Context, id string) (*entity.User, error) {
+	return s.home().GetUserByID(ctx, id)
+}
+
+func (s *ShardedStore) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return s.home().GetUserByEmail(ctx, email)
+}
+
+func (s *ShardedStore) UpdateUser(ctx context.Context, id string, updates entity.UserUpdate) error {
+	return s.home().UpdateUser(ctx, id, updates)
+}
@@ -0,0 +1,138 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/infrastructure/store"
+)
+
+// newShardedSQLite builds a sharded store over n SQLite databases and returns
+// the underlying shards too, so tests can check where data landed
+func newShardedSQLite(t *testing.T, n int) (*store.ShardedStore, []repository.Store) {
+	t.Helper()
+
+	shards := make([]repository.Store, n)
+	for i := range shards {
+		s, err := store.New(fmt.Sprintf("sqlite://%s/shard%d.db", t.TempDir(), i))
+		if err != nil {
+			t.Fatalf("failed to create shard %d: %v", i, err)
+		}
+		shards[i] = s
+	}
+	sharded, err := store.NewSharded(shards...)
+	if err != nil {
+		t.Fatalf("failed to create sharded store: %v", err)
+	}
+	t.Cleanup(func() { sharded.Close() })
+	if err := sharded.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return sharded, shards
+}
+
+func TestShardedStore_ShardForIsStable(t *testing.T) {
+	a, _ := newShardedSQLite(t, 4)
+	b, _ := newShardedSQLite(t, 4)
+
+	used := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("project-%d", i)
+		shard := a.ShardFor(id)
+		if shard < 0 || shard >= 4 {
+			t.Fatalf("%s: shard %d out of range", id, shard)
+		}
+		if again := a.ShardFor(id); again != shard {
+			t.Fatalf("%s: routed to %d then %d", id, shard, again)
+		}
+		if other := b.ShardFor(id); other != shard {
+			t.Fatalf("%s: routed to %d and %d by identically configured stores", id, shard, other)
+		}
+		used[shard] = true
+	}
+	if len(used) != 4 {
+		t.Errorf("expected projects spread over all 4 shards, used %v", used)
+	}
+}
+
+func TestShardedStore_ProjectDataStaysOnOneShard(t *testing.T) {
+	ctx := context.Background()
+	sharded, shards := newShardedSQLite(t, 3)
+
+	// Projects get random IDs, so they spread over the shards
+	var projects []*entity.Project
+	for i := 0; i < 12; i++ {
+		p := &entity.Project{
+			Name:       fmt.Sprintf("Project %d", i),
+			APIKey:     fmt.Sprintf("le_key_%d", i),
+			APIKeyHash: fmt.Sprintf("hash-%d", i),
+			OwnerEmail: "owner@example.com",
+		}
+		if err := sharded.CreateProject(ctx, p); err != nil {
+			t.Fatalf("CreateProject failed: %v", err)
+		}
+		projects = append(projects, p)
+
+		trace := &entity.Trace{ProjectID: p.ID, Status: entity.TraceStatusActive}
+		if err := sharded.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("CreateTrace failed: %v", err)
+		}
+		spans := []entity.Span{
+			{TraceID: trace.ID, Type: entity.SpanTypeLLM, Name: "call", Status: entity.SpanStatusSuccess, StartedAt: time.Now()},
+			{TraceID: trace.ID, Type: entity.SpanTypeTool, Name: "tool", Status: entity.SpanStatusSuccess, StartedAt: time.Now()},
+		}
+		if err := sharded.CreateSpans(ctx, p.ID, spans); err != nil {
+			t.Fatalf("CreateSpans failed: %v", err)
+		}
+	}
+
+	for _, p := range projects {
+		home := sharded.ShardFor(p.ID)
+		for i, shard := range shards {
+			_, projectErr := shard.GetProjectByID(ctx, p.ID)
+			page, err := shard.ListTraces(ctx, p.ID, entity.TraceFilter{Limit: 10})
+			if err != nil {
+				t.Fatalf("ListTraces on shard %d failed: %v", i, err)
+			}
+			if i == home {
+				if projectErr != nil || len(page.Data) != 1 || page.Data[0].TotalSpans != 2 {
+					t.Errorf("%s: expected project, trace and 2 spans on shard %d, got err=%v traces=%+v", p.Name, i, projectErr, page.Data)
+				}
+			} else if !errors.Is(projectErr, entity.ErrNotFound) || len(page.Data) != 0 {
+				t.Errorf("%s: found data on shard %d, expected only shard %d", p.Name, i, home)
+			}
+		}
+
+		// Reads through the sharded store see the project's data
+		page, err := sharded.ListTraces(ctx, p.ID, entity.TraceFilter{Limit: 10})
+		if err != nil || len(page.Data) != 1 {
+			t.Errorf("%s: expected 1 trace via sharded store, got %v (err=%v)", p.Name, page, err)
+		}
+	}
+
+	t.Run("lookups without a project fan out", func(t *testing.T) {
+		for _, p := range projects {
+			found, err := sharded.GetProjectByAPIKeyHash(ctx, p.APIKeyHash)
+			if err != nil || found.ID != p.ID {
+				t.Errorf("GetProjectByAPIKeyHash(%s): got %v, err=%v", p.APIKeyHash, found, err)
+			}
+		}
+		if _, err := sharded.GetProjectByAPIKeyHash(ctx, "no-such-hash"); !errors.Is(err, entity.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for unknown key, got %v", err)
+		}
+
+		all, err := sharded.ListProjects(ctx)
+		if err != nil || len(all) != len(projects) {
+			t.Errorf("ListProjects: expected %d projects, got %d (err=%v)", len(projects), len(all), err)
+		}
+		owned, err := sharded.ListProjectsByOwner(ctx, "owner@example.com")
+		if err != nil || len(owned) != len(projects) {
+			t.Errorf("ListProjectsByOwner: expected %d projects, got %d (err=%v)", len(projects), len(owned), err)
+		}
+	})
+}
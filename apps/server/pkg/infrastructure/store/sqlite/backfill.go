@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// SQLite implementation of repository.SpanToolBackfiller — filling in the
+// sub_type and tool_uses columns for llm spans written before they existed.
+
+// ListSpansMissingSubType returns llm spans with an output but no sub_type,
+// after afterID in ID order. Spans whose content was expired (output NULL)
+// or that never had an output (stored as JSON null) are skipped.
+func (s *Store) ListSpansMissingSubType(ctx context.Context, afterID string, limit int) ([]entity.Span, error) {
+	rows, err := s.reader.QueryContext(ctx, `
+		SELECT id, type, output FROM spans
+		WHERE type = ? AND sub_type IS NULL
+		  AND output IS NOT NULL AND output != 'null'
+		  AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`, entity.SpanTypeLLM, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListSpansMissingSubType: %w", err)
+	}
+	defer rows.Close()
+
+	var spans []entity.Span
+	for rows.Next() {
+		var sp entity.Span
+		var output string
+		if err := rows.Scan(&sp.ID, &sp.Type, &output); err != nil {
+			return nil, fmt.Errorf("ListSpansMissingSubType scan: %w", err)
+		}
+		json.Unmarshal([]byte(output), &sp.Output)
+		spans = append(spans, sp)
+	}
+	return spans, rows.Err()
+}
+
+// SetSpanSubTypes writes each span's sub_type and tool_uses in one
+// transaction. The sub_type IS NULL guard keeps a span ingest has set since
+// it was listed from being overwritten.
+func (s *Store) SetSpanSubTypes(ctx context.Context, spans []entity.Span) (int64, error) {
+	if len(spans) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE spans SET sub_type = ?, tool_uses = ?
+		WHERE id = ? AND sub_type IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var updated int64
+	for _, sp := range spans {
+		var toolUses sql.NullString
+		if len(sp.ToolUses) > 0 {
+			b, _ := json.Marshal(sp.ToolUses)
+			toolUses = sql.NullString{String: string(b), Valid: true}
+		}
+		result, err := stmt.ExecContext(ctx, sp.SubType, toolUses, sp.ID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		updated += n
+	}
+	return updated, tx.Commit()
+}
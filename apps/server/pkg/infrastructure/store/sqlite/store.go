@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -15,12 +16,61 @@ import (
 
 // Store implements repository.Store for SQLite
 type Store struct {
-	db *sql.DB
+	db     *sql.DB // single writer connection
+	reader *sql.DB // read-only pool (WAL); same as db when not configured
+
+	stopCheckpoint chan struct{}
+	checkpointDone chan struct{}
+}
+
+const (
+	// DefaultBusyTimeout is how long a connection waits on a locked database before failing.
+	DefaultBusyTimeout = 5 * time.Second
+	// DefaultJournalMode allows readers to proceed while a write is in progress.
+	DefaultJournalMode = "WAL"
+)
+
+// Options tunes the SQLite store
+type Options struct {
+	BusyTimeout time.Duration // default DefaultBusyTimeout
+	JournalMode string        // DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF; default DefaultJournalMode
+
+	// ReadConns opens a separate pool of read-only connections so reads are not
+	// queued behind the single writer during large ingest batches. Only used in
+	// WAL mode (the only mode where readers and the writer don't block each
+	// other). Zero sends reads through the writer connection.
+	ReadConns int
+
+	// CheckpointInterval runs PRAGMA wal_checkpoint(TRUNCATE) periodically so
+	// the WAL file is reset instead of growing with write bursts. Zero disables it.
+	CheckpointInterval time.Duration
+}
+
+var journalModes = map[string]bool{
+	"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true,
 }
 
 // New creates a new SQLite store
 func New(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	return NewWithOptions(path, Options{})
+}
+
+// NewWithOptions creates a new SQLite store with the given tuning options
+func NewWithOptions(path string, opts Options) (*Store, error) {
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = DefaultBusyTimeout
+	}
+	journalMode := strings.ToUpper(opts.JournalMode)
+	if journalMode == "" {
+		journalMode = DefaultJournalMode
+	}
+	if !journalModes[journalMode] {
+		return nil, fmt.Errorf("invalid SQLite journal mode %q", opts.JournalMode)
+	}
+
+	pragmas := fmt.Sprintf("_pragma=journal_mode(%s)&_pragma=busy_timeout(%d)", journalMode, opts.BusyTimeout.Milliseconds())
+
+	db, err := sql.Open("sqlite", path+"?"+pragmas)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -29,7 +79,52 @@ func New(path string) (*Store, error) {
 	db.SetMaxOpenConns(1) // SQLite only supports one writer
 	db.SetMaxIdleConns(1)
 
-	return &Store{db: db}, nil
+	s := &Store{db: db, reader: db}
+
+	if opts.ReadConns > 0 && journalMode == "WAL" {
+		reader, err := sql.Open("sqlite", path+"?"+pragmas+"&_pragma=query_only(1)")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		reader.SetMaxOpenConns(opts.ReadConns)
+		reader.SetMaxIdleConns(opts.ReadConns)
+		s.reader = reader
+	}
+
+	if opts.CheckpointInterval > 0 && journalMode == "WAL" {
+		s.stopCheckpoint = make(chan struct{})
+		s.checkpointDone = make(chan struct{})
+		go s.checkpointLoop(opts.CheckpointInterval)
+	}
+
+	return s, nil
+}
+
+// Checkpoint copies the WAL into the database file and truncates the WAL
+func (s *Store) Checkpoint(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+func (s *Store) checkpointLoop(interval time.Duration) {
+	defer close(s.checkpointDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCheckpoint:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := s.Checkpoint(ctx); err != nil {
+				slog.Warn("sqlite wal checkpoint failed", "error", err)
+			}
+			cancel()
+		}
+	}
 }
 
 // Migrate runs database migrations
@@ -69,7 +164,8 @@ func (s *Store) Migrate(ctx context.Context) error {
 			tags TEXT DEFAULT '[]',
 			metadata TEXT DEFAULT '{}',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			parent_trace_id TEXT
 		)`,
 
 		// Spans table
@@ -97,7 +193,14 @@ func (s *Store) Migrate(ctx context.Context) error {
 			cache_write_tokens INTEGER,
 			reasoning_tokens INTEGER,
 			first_token_ms INTEGER,
-			thinking TEXT
+			thinking TEXT,
+			sequence INTEGER NOT NULL DEFAULT 0,
+			input_bytes INTEGER NOT NULL DEFAULT 0,
+			output_bytes INTEGER NOT NULL DEFAULT 0,
+			model_params TEXT,
+			tags TEXT,
+			subtree_cost_usd REAL,
+			links TEXT
 		)`,
 
 		// Phase 7.1: Add extended fields to existing spans table
@@ -115,13 +218,67 @@ func (s *Store) Migrate(ctx context.Context) error {
 		// Phase 7.3: Add name field to traces table
 		`ALTER TABLE traces ADD COLUMN name TEXT`,
 
+		// Emission order, breaking ties between spans started at the same time
+		`ALTER TABLE spans ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+
+		// Serialized input/output sizes, for storage analytics without reading the blobs
+		`ALTER TABLE spans ADD COLUMN input_bytes INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE spans ADD COLUMN output_bytes INTEGER NOT NULL DEFAULT 0`,
+
+		// Generation settings (temperature, top_p, ...) as JSON
+		`ALTER TABLE spans ADD COLUMN model_params TEXT`,
+
+		// Span tags as a JSON array
+		`ALTER TABLE spans ADD COLUMN tags TEXT`,
+
+		// Agent span cost including descendants, set when the trace ends
+		`ALTER TABLE spans ADD COLUMN subtree_cost_usd REAL`,
+
+		// Span links (non-hierarchical references to other spans) as a JSON array
+		`ALTER TABLE spans ADD COLUMN links TEXT`,
+
+		// Trace that spawned this one (e.g. an orchestrator's delegated agent)
+		`ALTER TABLE traces ADD COLUMN parent_trace_id TEXT`,
+
+		// API key usage (last-seen + request counter per key hash)
+		`CREATE TABLE IF NOT EXISTS api_key_usage (
+			key_hash TEXT PRIMARY KEY,
+			project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+			last_used_at DATETIME NOT NULL,
+			request_count INTEGER NOT NULL DEFAULT 0
+		)`,
+
+		// Trace scores (end-user feedback)
+		`CREATE TABLE IF NOT EXISTS scores (
+			id TEXT PRIMARY KEY,
+			project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+			trace_id TEXT NOT NULL REFERENCES traces(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			value REAL NOT NULL,
+			source TEXT NOT NULL,
+			comment TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Span attributes: metadata values promoted at ingest for indexed search
+		`CREATE TABLE IF NOT EXISTS span_attributes (
+			span_id TEXT NOT NULL REFERENCES spans(id) ON DELETE CASCADE,
+			project_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (span_id, key)
+		)`,
+
 		// Indexes
 		`CREATE INDEX IF NOT EXISTS idx_projects_api_key_hash ON projects(api_key_hash)`,
 		`CREATE INDEX IF NOT EXISTS idx_projects_owner ON projects(owner_email)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_project_created ON traces(project_id, created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_session ON traces(project_id, session_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_user ON traces(project_id, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_traces_parent ON traces(project_id, parent_trace_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_spans_trace ON spans(trace_id, started_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_scores_project_created ON scores(project_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_span_attributes_lookup ON span_attributes(project_id, key, value)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id)`,
 	}
@@ -149,8 +306,15 @@ func (s *Store) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
 }
 
-// Close closes the database connection
+// Close stops background checkpointing and closes the database connections
 func (s *Store) Close() error {
+	if s.stopCheckpoint != nil {
+		close(s.stopCheckpoint)
+		<-s.checkpointDone
+	}
+	if s.reader != s.db {
+		s.reader.Close()
+	}
 	return s.db.Close()
 }
 
@@ -176,7 +340,7 @@ func (s *Store) CreateUser(ctx context.Context, u *entity.User) error {
 
 func (s *Store) GetUserByID(ctx context.Context, id string) (*entity.User, error) {
 	var u entity.User
-	err := s.db.QueryRowContext(ctx, `
+	err := s.reader.QueryRowContext(ctx, `
 		SELECT id, email, name, password_hash, google_id, created_at, updated_at
 		FROM users WHERE id = ?
 	`, id).Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash, &u.GoogleID, &u.CreatedAt, &u.UpdatedAt)
@@ -192,7 +356,7 @@ func (s *Store) GetUserByID(ctx context.Context, id string) (*entity.User, error
 
 func (s *Store) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var u entity.User
-	err := s.db.QueryRowContext(ctx, `
+	err := s.reader.QueryRowContext(ctx, `
 		SELECT id, email, name, password_hash, google_id, created_at, updated_at
 		FROM users WHERE email = ?
 	`, email).Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash, &u.GoogleID, &u.CreatedAt, &u.UpdatedAt)
@@ -262,7 +426,7 @@ func (s *Store) GetProjectByID(ctx context.Context, id string) (*entity.Project,
 	var p entity.Project
 	var settingsJSON string
 
-	err := s.db.QueryRowContext(ctx, `
+	err := s.reader.QueryRowContext(ctx, `
 		SELECT id, name, api_key, api_key_hash, owner_email, settings, created_at, updated_at
 		FROM projects WHERE id = ?
 	`, id).Scan(&p.ID, &p.Name, &p.APIKey, &p.APIKeyHash, &p.OwnerEmail, &settingsJSON, &p.CreatedAt, &p.UpdatedAt)
@@ -282,7 +446,7 @@ func (s *Store) GetProjectByAPIKeyHash(ctx context.Context, hash string) (*entit
 	var p entity.Project
 	var settingsJSON string
 
-	err := s.db.QueryRowContext(ctx, `
+	err := s.reader.QueryRowContext(ctx, `
 		SELECT id, name, api_key, api_key_hash, owner_email, settings, created_at, updated_at
 		FROM projects WHERE api_key_hash = ?
 	`, hash).Scan(&p.ID, &p.Name, &p.APIKey, &p.APIKeyHash, &p.OwnerEmail, &settingsJSON, &p.CreatedAt, &p.UpdatedAt)
@@ -331,7 +495,7 @@ func (s *Store) DeleteProject(ctx context.Context, id string) error {
 }
 
 func (s *Store) ListProjectsByOwner(ctx context.Context, email string) ([]entity.Project, error) {
-	rows, err := s.db.QueryContext(ctx, `
+	rows, err := s.reader.QueryContext(ctx, `
 		SELECT id, name, api_key, api_key_hash, owner_email, settings, created_at, updated_at
 		FROM projects WHERE owner_email = ? ORDER BY created_at DESC
 	`, email)
@@ -353,9 +517,32 @@ func (s *Store) ListProjectsByOwner(ctx context.Context, email string) ([]entity
 	return projects, nil
 }
 
+func (s *Store) ListProjects(ctx context.Context) ([]entity.Project, error) {
+	rows, err := s.reader.QueryContext(ctx, `
+		SELECT id, name, api_key, api_key_hash, owner_email, settings, created_at, updated_at
+		FROM projects ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []entity.Project
+	for rows.Next() {
+		var p entity.Project
+		var settingsJSON string
+		if err := rows.Scan(&p.ID, &p.Name, &p.APIKey, &p.APIKeyHash, &p.OwnerEmail, &settingsJSON, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(settingsJSON), &p.Settings)
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
 func (s *Store) IsProjectOwner(ctx context.Context, projectID, ownerEmail string) (bool, error) {
 	var count int
-	err := s.db.QueryRowContext(ctx,
+	err := s.reader.QueryRowContext(ctx,
 		`SELECT COUNT(*) FROM projects WHERE id = ? AND owner_email = ?`,
 		projectID, ownerEmail).Scan(&count)
 	if err != nil {
@@ -371,6 +558,36 @@ func (s *Store) RotateAPIKey(ctx context.Context, id string, newKey, newHash str
 	return err
 }
 
+func (s *Store) RecordAPIKeyUsage(ctx context.Context, projectID, keyHash string, lastUsedAt time.Time, requests int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_key_usage (key_hash, project_id, last_used_at, request_count)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(key_hash) DO UPDATE SET
+			last_used_at = MAX(api_key_usage.last_used_at, excluded.last_used_at),
+			request_count = api_key_usage.request_count + excluded.request_count
+	`, keyHash, projectID, lastUsedAt.UTC(), requests)
+	return err
+}
+
+func (s *Store) GetAPIKeyUsage(ctx context.Context, keyHash string) (*entity.APIKeyUsage, error) {
+	var usage entity.APIKeyUsage
+	var lastUsedAt time.Time
+
+	err := s.reader.QueryRowContext(ctx, `
+		SELECT last_used_at, request_count FROM api_key_usage WHERE key_hash = ?
+	`, keyHash).Scan(&lastUsedAt, &usage.RequestCount)
+
+	if err == sql.ErrNoRows {
+		return &usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usage.LastUsedAt = &lastUsedAt
+	return &usage, nil
+}
+
 // ============================================
 // TRACE OPERATIONS
 // ============================================
@@ -387,9 +604,9 @@ func (s *Store) CreateTrace(ctx context.Context, t *entity.Trace) error {
 	metadataJSON, _ := json.Marshal(t.Metadata)
 
 	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, string(tagsJSON), string(metadataJSON), t.CreatedAt, t.UpdatedAt)
+		INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, string(tagsJSON), string(metadataJSON), t.CreatedAt, t.UpdatedAt, t.ParentTraceID)
 
 	return err
 }
@@ -442,16 +659,30 @@ func (s *Store) DeleteAllTraces(ctx context.Context, projectID string) (int64, e
 	return result.RowsAffected()
 }
 
+func (s *Store) DeleteTracesByFilter(ctx context.Context, projectID string, filter entity.TraceFilter) (int64, error) {
+	// Spans are deleted via CASCADE when traces are deleted
+	whereClause, args := traceFilterWhere(projectID, filter)
+	result, err := s.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM traces AS t WHERE %s`, whereClause), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entity.TraceWithSpans, error) {
+	return s.GetTraceCapped(ctx, projectID, traceID, 0)
+}
+
+func (s *Store) GetTraceCapped(ctx context.Context, projectID, traceID string, maxSpans int) (*entity.TraceWithSpans, error) {
 	// Get trace
 	var t entity.Trace
 	var tagsJSON, metadataJSON string
-	var name, sessionID, userID sql.NullString
+	var name, sessionID, userID, parentTraceID sql.NullString
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at
+	err := s.reader.QueryRowContext(ctx, `
+		SELECT id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id
 		FROM traces WHERE project_id = ? AND id = ?
-	`, projectID, traceID).Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON, &t.CreatedAt, &t.UpdatedAt)
+	`, projectID, traceID).Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON, &t.CreatedAt, &t.UpdatedAt, &parentTraceID)
 
 	if err == sql.ErrNoRows {
 		return nil, entity.ErrNotFound
@@ -469,17 +700,34 @@ func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entit
 	if userID.Valid {
 		t.UserID = &userID.String
 	}
+	if parentTraceID.Valid {
+		t.ParentTraceID = &parentTraceID.String
+	}
 	json.Unmarshal([]byte(tagsJSON), &t.Tags)
 	json.Unmarshal([]byte(metadataJSON), &t.Metadata)
 
-	// Get spans
-	spans, err := s.getSpansForTrace(ctx, traceID)
+	// Get spans, one past the cap to tell whether there are more
+	limit := 0
+	if maxSpans > 0 {
+		limit = maxSpans + 1
+	}
+	spans, err := s.getSpansForTrace(ctx, traceID, limit, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate metrics
 	result := &entity.TraceWithSpans{Trace: t, Spans: spans}
+	if maxSpans > 0 && len(spans) > maxSpans {
+		// Metrics still cover every span, not just those returned
+		result.Spans = spans[:maxSpans]
+		result.SpansTruncated = true
+		if err := s.sumTraceSpans(ctx, traceID, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// Calculate metrics
 	for _, span := range spans {
 		result.TotalSpans++
 		if span.InputTokens != nil {
@@ -499,31 +747,127 @@ func (s *Store) GetTrace(ctx context.Context, projectID, traceID string) (*entit
 	return result, nil
 }
 
-func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.Span, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, trace_id, parent_span_id, type, name, input, output,
-		       input_tokens, output_tokens, cost_usd, duration_ms, status,
-		       error_message, model, provider, metadata, started_at, ended_at,
-		       stop_reason, cache_read_tokens, cache_write_tokens,
-		       reasoning_tokens, first_token_ms, thinking,
-		       sub_type, tool_uses
-		FROM spans WHERE trace_id = ? ORDER BY started_at
-	`, traceID)
+// getSpansForTrace returns the trace's spans in start order, then emission
+// order; limit <= 0 returns all
+func (s *Store) getSpansForTrace(ctx context.Context, traceID string, limit, offset int) ([]entity.Span, error) {
+	query := `
+		SELECT ` + spanColumns + `
+		FROM spans WHERE trace_id = ? ORDER BY started_at, sequence, id
+	`
+	args := []any{traceID}
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanSpans(rows)
+}
+
+// sumTraceSpans sets the trace metrics from all of its spans, in SQL
+func (s *Store) sumTraceSpans(ctx context.Context, traceID string, result *entity.TraceWithSpans) error {
+	return s.reader.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(input_tokens), 0) + COALESCE(SUM(output_tokens), 0),
+		       COALESCE(SUM(cost_usd), 0),
+		       COALESCE(SUM(duration_ms), 0)
+		FROM spans WHERE trace_id = ?
+	`, traceID).Scan(&result.TotalSpans, &result.TotalTokens, &result.TotalCostUSD, &result.TotalDurationMs)
+}
+
+func (s *Store) GetTracesMetrics(ctx context.Context, projectID string, traceIDs []string) (map[string]entity.TraceMetrics, error) {
+	metrics := make(map[string]entity.TraceMetrics, len(traceIDs))
+	if len(traceIDs) == 0 {
+		return metrics, nil
+	}
+
+	args := []any{projectID}
+	for _, id := range traceIDs {
+		args = append(args, id)
+	}
+	rows, err := s.reader.QueryContext(ctx, `
+		SELECT t.id,
+		       COUNT(s.id),
+		       COALESCE(SUM(s.input_tokens), 0) + COALESCE(SUM(s.output_tokens), 0),
+		       COALESCE(SUM(s.cost_usd), 0),
+		       COALESCE(SUM(s.duration_ms), 0)
+		FROM traces t
+		LEFT JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.id IN (?`+strings.Repeat(", ?", len(traceIDs)-1)+`)
+		GROUP BY t.id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetTracesMetrics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var m entity.TraceMetrics
+		if err := rows.Scan(&id, &m.TotalSpans, &m.TotalTokens, &m.TotalCostUSD, &m.TotalDurationMs); err != nil {
+			return nil, fmt.Errorf("GetTracesMetrics scan: %w", err)
+		}
+		metrics[id] = m
+	}
+	return metrics, rows.Err()
+}
+
+func (s *Store) ListTraceSpans(ctx context.Context, projectID, traceID string, limit, offset int) (*entity.Page[entity.Span], error) {
+	var exists int
+	if err := s.reader.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM traces WHERE project_id = ? AND id = ?
+	`, projectID, traceID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, entity.ErrNotFound
+	}
+
+	var total int
+	if err := s.reader.QueryRowContext(ctx, `SELECT COUNT(*) FROM spans WHERE trace_id = ?`, traceID).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	spans, err := s.getSpansForTrace(ctx, traceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewPage(spans, total, limit, offset), nil
+}
+
+// spanColumns is the column list scanSpans expects, in order.
+const spanColumns = `id, trace_id, parent_span_id, type, name, input, output,
+		       input_tokens, output_tokens, cost_usd, duration_ms, status,
+		       error_message, model, provider, metadata, started_at, ended_at,
+		       stop_reason, cache_read_tokens, cache_write_tokens,
+		       reasoning_tokens, first_token_ms, thinking,
+		       sub_type, tool_uses, sequence, model_params, tags, subtree_cost_usd, links`
+
+// scanSpans reads full span rows selected with spanColumns.
+func scanSpans(rows *sql.Rows) ([]entity.Span, error) {
 	var spans []entity.Span
 	for rows.Next() {
 		var sp entity.Span
 		var parentSpanID, inputJSON, outputJSON, errorMsg, model, provider sql.NullString
 		var stopReason, thinking sql.NullString
-		var subType, toolUsesJSON sql.NullString
+		var subType, toolUsesJSON, modelParamsJSON, tagsJSON, linksJSON sql.NullString
 		var metadataJSON string
 		var inputTokens, outputTokens, durationMs sql.NullInt64
 		var cacheReadTokens, cacheWriteTokens, reasoningTokens, firstTokenMs sql.NullInt64
-		var costUSD sql.NullFloat64
+		var costUSD, subtreeCost sql.NullFloat64
 		var endedAt sql.NullTime
 
 		err := rows.Scan(&sp.ID, &sp.TraceID, &parentSpanID, &sp.Type, &sp.Name,
@@ -532,7 +876,7 @@ func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.
 			&sp.StartedAt, &endedAt,
 			&stopReason, &cacheReadTokens, &cacheWriteTokens,
 			&reasoningTokens, &firstTokenMs, &thinking,
-			&subType, &toolUsesJSON)
+			&subType, &toolUsesJSON, &sp.Sequence, &modelParamsJSON, &tagsJSON, &subtreeCost, &linksJSON)
 		if err != nil {
 			return nil, err
 		}
@@ -557,6 +901,9 @@ func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.
 		if costUSD.Valid {
 			sp.CostUSD = &costUSD.Float64
 		}
+		if subtreeCost.Valid {
+			sp.SubtreeCostUSD = &subtreeCost.Float64
+		}
 		if durationMs.Valid {
 			v := int(durationMs.Int64)
 			sp.DurationMs = &v
@@ -603,16 +950,53 @@ func (s *Store) getSpansForTrace(ctx context.Context, traceID string) ([]entity.
 		if toolUsesJSON.Valid && toolUsesJSON.String != "" {
 			json.Unmarshal([]byte(toolUsesJSON.String), &sp.ToolUses)
 		}
+		if modelParamsJSON.Valid && modelParamsJSON.String != "" {
+			json.Unmarshal([]byte(modelParamsJSON.String), &sp.ModelParams)
+		}
+		if tagsJSON.Valid && tagsJSON.String != "" {
+			json.Unmarshal([]byte(tagsJSON.String), &sp.Tags)
+		}
+		if linksJSON.Valid && linksJSON.String != "" {
+			json.Unmarshal([]byte(linksJSON.String), &sp.Links)
+		}
 		json.Unmarshal([]byte(metadataJSON), &sp.Metadata)
 
 		spans = append(spans, sp)
 	}
 
-	return spans, nil
+	return spans, rows.Err()
 }
 
-func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
-	// Build query
+func (s *Store) ListTraceIDs(ctx context.Context, projectID string, after *entity.TraceCursor, limit int) ([]entity.TraceCursor, error) {
+	query := `SELECT id, created_at FROM traces WHERE project_id = ?`
+	args := []any{projectID}
+	if after != nil {
+		query += ` AND (created_at > ? OR (created_at = ? AND id > ?))`
+		args = append(args, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	query += ` ORDER BY created_at, id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cursors []entity.TraceCursor
+	for rows.Next() {
+		var c entity.TraceCursor
+		if err := rows.Scan(&c.ID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		cursors = append(cursors, c)
+	}
+	return cursors, rows.Err()
+}
+
+// traceFilterWhere builds the WHERE clause (over traces aliased as t) shared by
+// ListTraces and DeleteTracesByFilter
+func traceFilterWhere(projectID string, filter entity.TraceFilter) (string, []any) {
 	where := []string{"t.project_id = ?"}
 	args := []any{projectID}
 
@@ -628,6 +1012,10 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		where = append(where, "t.user_id = ?")
 		args = append(args, *filter.UserID)
 	}
+	if filter.ParentTraceID != nil {
+		where = append(where, "t.parent_trace_id = ?")
+		args = append(args, *filter.ParentTraceID)
+	}
 	if filter.Status != nil {
 		where = append(where, "t.status = ?")
 		args = append(args, string(*filter.Status))
@@ -649,13 +1037,43 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		where = append(where, "t.created_at <= ?")
 		args = append(args, *filter.To)
 	}
+	if filter.MinInactiveMs != nil {
+		cutoff := time.Now().Add(-time.Duration(*filter.MinInactiveMs) * time.Millisecond)
+		where = append(where, "t.status = ? AND t.created_at <= ? AND t.id NOT IN (SELECT trace_id FROM spans WHERE started_at > ?)")
+		args = append(args, string(entity.TraceStatusActive), cutoff, cutoff)
+	}
+	if filter.Tool != nil {
+		where = append(where, `t.id IN (
+			SELECT s.trace_id FROM spans s
+			WHERE (s.type = 'tool' AND s.name = ?)
+			   OR (s.tool_uses IS NOT NULL AND EXISTS (SELECT 1 FROM json_each(s.tool_uses) WHERE json_extract(value, '$.name') = ?)))`)
+		args = append(args, *filter.Tool, *filter.Tool)
+	}
+	// Walks up from every span, one parent per level, until MinDepth levels
+	// are found; bounded, so a parent cycle cannot loop
+	if filter.MinDepth != nil {
+		where = append(where, `t.id IN (
+			WITH RECURSIVE chain(trace_id, parent_id, depth) AS (
+				SELECT s.trace_id, s.parent_span_id, 1 FROM spans s
+				WHERE s.trace_id IN (SELECT id FROM traces WHERE project_id = ?)
+				UNION ALL
+				SELECT c.trace_id, p.parent_span_id, c.depth + 1 FROM chain c
+				JOIN spans p ON p.id = c.parent_id AND p.trace_id = c.trace_id
+				WHERE c.depth < ?)
+			SELECT trace_id FROM chain WHERE depth >= ?)`)
+		args = append(args, projectID, *filter.MinDepth, *filter.MinDepth)
+	}
+
+	return strings.Join(where, " AND "), args
+}
 
-	whereClause := strings.Join(where, " AND ")
+func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.TraceFilter) (*entity.Page[entity.TraceWithMetrics], error) {
+	whereClause, args := traceFilterWhere(projectID, filter)
 
 	// Get total count
 	var total int
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM traces t WHERE %s", whereClause)
-	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+	if err := s.reader.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
@@ -671,10 +1089,12 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 
 	query := fmt.Sprintf(`
 		SELECT t.id, t.project_id, t.name, t.session_id, t.user_id, t.status, t.tags, t.metadata, t.created_at, t.updated_at,
+		       t.parent_trace_id,
 		       COALESCE(COUNT(s.id), 0) as total_spans,
 		       COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 		       COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as total_cost,
-		       COALESCE(SUM(COALESCE(s.duration_ms, 0)), 0) as total_duration
+		       COALESCE(SUM(COALESCE(s.duration_ms, 0)), 0) as total_duration,
+		       MAX(s.started_at) as last_span_at
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
 		WHERE %s
@@ -684,23 +1104,25 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 	`, whereClause)
 
 	args = append(args, limit, offset)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	var traces []entity.TraceWithMetrics
 	for rows.Next() {
 		var t entity.TraceWithMetrics
 		var tagsJSON, metadataJSON string
-		var name, sessionID, userID sql.NullString
+		var name, sessionID, userID, parentTraceID, lastSpanAt sql.NullString
 
 		err := rows.Scan(&t.ID, &t.ProjectID, &name, &sessionID, &userID, &t.Status, &tagsJSON, &metadataJSON,
-			&t.CreatedAt, &t.UpdatedAt, &t.TotalSpans, &t.TotalTokens, &t.TotalCostUSD, &t.TotalDurationMs)
+			&t.CreatedAt, &t.UpdatedAt, &parentTraceID, &t.TotalSpans, &t.TotalTokens, &t.TotalCostUSD, &t.TotalDurationMs, &lastSpanAt)
 		if err != nil {
 			return nil, err
 		}
+		t.SetLastActivity(parseSQLiteTime(lastSpanAt.String), now)
 
 		if name.Valid {
 			t.Name = &name.String
@@ -711,25 +1133,23 @@ func (s *Store) ListTraces(ctx context.Context, projectID string, filter entity.
 		if userID.Valid {
 			t.UserID = &userID.String
 		}
+		if parentTraceID.Valid {
+			t.ParentTraceID = &parentTraceID.String
+		}
 		json.Unmarshal([]byte(tagsJSON), &t.Tags)
 		json.Unmarshal([]byte(metadataJSON), &t.Metadata)
 
 		traces = append(traces, t)
 	}
 
-	return &entity.Page[entity.TraceWithMetrics]{
-		Data:   traces,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
-	}, nil
+	return entity.NewPage(traces, total, limit, offset), nil
 }
 
 // ============================================
 // SPAN OPERATIONS
 // ============================================
 
-func (s *Store) CreateSpan(ctx context.Context, span *entity.Span) error {
+func (s *Store) CreateSpan(ctx context.Context, projectID string, span *entity.Span) error {
 	if span.ID == "" {
 		span.ID = uuid.New().String()
 	}
@@ -745,26 +1165,54 @@ func (s *Store) CreateSpan(ctx context.Context, span *entity.Span) error {
 		toolUsesJSON = &s
 	}
 
-	_, err := s.db.ExecContext(ctx, `
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
 		INSERT INTO spans (id, trace_id, parent_span_id, type, name, input, output,
 		                   input_tokens, output_tokens, cost_usd, duration_ms, status,
 		                   error_message, model, provider, metadata, started_at, ended_at,
 		                   stop_reason, cache_read_tokens, cache_write_tokens,
 		                   reasoning_tokens, first_token_ms, thinking,
-		                   sub_type, tool_uses)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                   sub_type, tool_uses, sequence, input_bytes, output_bytes, model_params, tags, subtree_cost_usd, links)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
 		string(inputJSON), string(outputJSON), span.InputTokens, span.OutputTokens,
 		span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
 		span.Provider, string(metadataJSON), span.StartedAt, span.EndedAt,
 		span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
 		span.ReasoningTokens, span.FirstTokenMs, span.Thinking,
-		span.SubType, toolUsesJSON)
+		span.SubType, toolUsesJSON, span.Sequence,
+		entity.PayloadBytes(inputJSON), entity.PayloadBytes(outputJSON), span.ModelParamsJSON(), span.TagsJSON(), span.SubtreeCostUSD, span.LinksJSON())
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := insertSpanAttributes(ctx, tx, projectID, []entity.Span{*span}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertSpanAttributes writes the indexed attributes of spans
+func insertSpanAttributes(ctx context.Context, tx *sql.Tx, projectID string, spans []entity.Span) error {
+	for i := range spans {
+		for key, value := range spans[i].Attributes {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT OR REPLACE INTO span_attributes (span_id, project_id, key, value)
+				VALUES (?, ?, ?, ?)
+			`, spans[i].ID, projectID, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func (s *Store) CreateSpans(ctx context.Context, spans []entity.Span) error {
+func (s *Store) CreateSpans(ctx context.Context, projectID string, spans []entity.Span) error {
 	if len(spans) == 0 {
 		return nil
 	}
@@ -775,55 +1223,422 @@ func (s *Store) CreateSpans(ctx context.Context, spans []entity.Span) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO spans (id, trace_id, parent_span_id, type, name, input, output,
-		                   input_tokens, output_tokens, cost_usd, duration_ms, status,
-		                   error_message, model, provider, metadata, started_at, ended_at,
-		                   stop_reason, cache_read_tokens, cache_write_tokens,
-		                   reasoning_tokens, first_token_ms, thinking,
-		                   sub_type, tool_uses)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
+	if err := insertSpans(ctx, tx, projectID, spans); err != nil {
 		return err
 	}
-	defer stmt.Close()
+	return tx.Commit()
+}
 
-	for i := range spans {
-		span := &spans[i]
-		if span.ID == "" {
-			span.ID = uuid.New().String()
-		}
+// CreateSpansPartial inserts each span under its own savepoint, so a failing
+// one is rolled back alone and the others commit together
+func (s *Store) CreateSpansPartial(ctx context.Context, projectID string, spans []entity.Span) ([]entity.SpanWriteError, error) {
+	if len(spans) == 0 {
+		return nil, nil
+	}
 
-		inputJSON, _ := json.Marshal(span.Input)
-		outputJSON, _ := json.Marshal(span.Output)
-		metadataJSON, _ := json.Marshal(span.Metadata)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-		var toolUsesJSON *string
-		if len(span.ToolUses) > 0 {
-			b, _ := json.Marshal(span.ToolUses)
-			str := string(b)
-			toolUsesJSON = &str
+	var failed []entity.SpanWriteError
+	for i := range spans {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT span"); err != nil {
+			return nil, err
 		}
-
-		_, err := stmt.ExecContext(ctx, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
-			string(inputJSON), string(outputJSON), span.InputTokens, span.OutputTokens,
-			span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
-			span.Provider, string(metadataJSON), span.StartedAt, span.EndedAt,
-			span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
-			span.ReasoningTokens, span.FirstTokenMs, span.Thinking,
-			span.SubType, toolUsesJSON)
-		if err != nil {
-			return err
+		if err := insertSpans(ctx, tx, projectID, spans[i:i+1]); err != nil {
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO span"); err != nil {
+				return nil, err
+			}
+			failed = append(failed, entity.SpanWriteError{Index: i, Err: err})
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE span"); err != nil {
+			return nil, err
 		}
 	}
-
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return failed, nil
 }
 
-// ============================================
-// SESSION OPERATIONS
-// ============================================
+func (s *Store) CreateTracesWithSpans(ctx context.Context, projectID string, traces []*entity.Trace, spans []entity.Span) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	created := 0
+	now := time.Now()
+	for _, t := range traces {
+		if t.ID == "" {
+			t.ID = uuid.New().String()
+		}
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		t.UpdatedAt = now
+
+		tagsJSON, _ := json.Marshal(t.Tags)
+		metadataJSON, _ := json.Marshal(t.Metadata)
+
+		// A concurrent batch may have created the trace since it was looked up
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO traces (id, project_id, name, session_id, user_id, status, tags, metadata, created_at, updated_at, parent_trace_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO NOTHING
+		`, t.ID, t.ProjectID, t.Name, t.SessionID, t.UserID, t.Status, string(tagsJSON), string(metadataJSON), t.CreatedAt, t.UpdatedAt, t.ParentTraceID)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			created++
+			continue
+		}
+
+		// The trace that already existed must be the project's own, or the
+		// spans would be written under another project's trace
+		var owner string
+		if err := tx.QueryRowContext(ctx, `SELECT project_id FROM traces WHERE id = ?`, t.ID).Scan(&owner); err != nil {
+			return 0, err
+		}
+		if owner != projectID {
+			return 0, fmt.Errorf("trace %s belongs to another project: %w", t.ID, entity.ErrConflict)
+		}
+	}
+
+	if len(spans) > 0 {
+		if err := insertSpans(ctx, tx, projectID, spans); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+func (s *Store) UpdateSpan(ctx context.Context, projectID, traceID, spanID string, updates entity.SpanUpdate) error {
+	if updates.Tags == nil {
+		return nil
+	}
+
+	var tagsJSON *string
+	if len(updates.Tags) > 0 {
+		b, _ := json.Marshal(updates.Tags)
+		str := string(b)
+		tagsJSON = &str
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE spans SET tags = ?
+		WHERE id = ? AND trace_id = ? AND trace_id IN (SELECT id FROM traces WHERE project_id = ?)
+	`, tagsJSON, spanID, traceID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// insertSpans inserts spans, and their indexed attributes, within tx
+func insertSpans(ctx context.Context, tx *sql.Tx, projectID string, spans []entity.Span) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO spans (id, trace_id, parent_span_id, type, name, input, output,
+		                   input_tokens, output_tokens, cost_usd, duration_ms, status,
+		                   error_message, model, provider, metadata, started_at, ended_at,
+		                   stop_reason, cache_read_tokens, cache_write_tokens,
+		                   reasoning_tokens, first_token_ms, thinking,
+		                   sub_type, tool_uses, sequence, input_bytes, output_bytes, model_params, tags, subtree_cost_usd, links)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := range spans {
+		span := &spans[i]
+		if span.ID == "" {
+			span.ID = uuid.New().String()
+		}
+
+		inputJSON, _ := json.Marshal(span.Input)
+		outputJSON, _ := json.Marshal(span.Output)
+		metadataJSON, _ := json.Marshal(span.Metadata)
+
+		var toolUsesJSON *string
+		if len(span.ToolUses) > 0 {
+			b, _ := json.Marshal(span.ToolUses)
+			str := string(b)
+			toolUsesJSON = &str
+		}
+
+		_, err := stmt.ExecContext(ctx, span.ID, span.TraceID, span.ParentSpanID, span.Type, span.Name,
+			string(inputJSON), string(outputJSON), span.InputTokens, span.OutputTokens,
+			span.CostUSD, span.DurationMs, span.Status, span.ErrorMessage, span.Model,
+			span.Provider, string(metadataJSON), span.StartedAt, span.EndedAt,
+			span.StopReason, span.CacheReadTokens, span.CacheWriteTokens,
+			span.ReasoningTokens, span.FirstTokenMs, span.Thinking,
+			span.SubType, toolUsesJSON, span.Sequence,
+			entity.PayloadBytes(inputJSON), entity.PayloadBytes(outputJSON), span.ModelParamsJSON(), span.TagsJSON(), span.SubtreeCostUSD, span.LinksJSON())
+		if err != nil {
+			return err
+		}
+	}
+
+	return insertSpanAttributes(ctx, tx, projectID, spans)
+}
+
+func (s *Store) SearchSpans(ctx context.Context, projectID string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	where, args := spanFilterWhere(projectID, filter)
+	return s.searchSpans(ctx, where, args, filter)
+}
+
+func (s *Store) SearchSpansByAttribute(ctx context.Context, projectID, key, value string, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	where, args := spanFilterWhere(projectID, filter)
+	where = append(where, "id IN (SELECT span_id FROM span_attributes WHERE project_id = ? AND key = ? AND value = ?)")
+	args = append(args, projectID, key, value)
+	return s.searchSpans(ctx, where, args, filter)
+}
+
+// likeEscaper escapes LIKE wildcards so text matches literally (ESCAPE '\')
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern matching values that contain text
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+// spanFilterWhere builds the WHERE conditions (and args) selecting the
+// project's spans that match filter
+func spanFilterWhere(projectID string, filter entity.SpanFilter) ([]string, []any) {
+	where := []string{"trace_id IN (SELECT id FROM traces WHERE project_id = ?)"}
+	args := []any{projectID}
+
+	if filter.Type != nil {
+		where = append(where, "type = ?")
+		args = append(args, string(*filter.Type))
+	}
+	if filter.Name != nil && *filter.Name != "" {
+		where = append(where, "name = ?")
+		args = append(args, *filter.Name)
+	}
+	if filter.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, string(*filter.Status))
+	}
+	if filter.Model != nil && *filter.Model != "" {
+		where = append(where, "model = ?")
+		args = append(args, *filter.Model)
+	}
+	if filter.From != nil {
+		where = append(where, "started_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		where = append(where, "started_at <= ?")
+		args = append(args, *filter.To)
+	}
+	if filter.Text != "" {
+		// SQLite LIKE is case-insensitive for ASCII
+		where = append(where, `(name LIKE ? ESCAPE '\' OR input LIKE ? ESCAPE '\' OR output LIKE ? ESCAPE '\' OR error_message LIKE ? ESCAPE '\')`)
+		pattern := containsPattern(filter.Text)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	for key, value := range filter.Metadata {
+		// json_extract returns booleans as 0/1; compare them as JSON spells them
+		path := `$."` + key + `"`
+		where = append(where, `(CASE json_type(metadata, ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false'
+			ELSE CAST(json_extract(metadata, ?) AS TEXT) END) = ?`)
+		args = append(args, path, path, value)
+	}
+	if len(filter.Tags) > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(spans.tags) WHERE value IN (?"+strings.Repeat(", ?", len(filter.Tags)-1)+"))")
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+	}
+
+	return where, args
+}
+
+func (s *Store) searchSpans(ctx context.Context, where []string, args []any, filter entity.SpanFilter) (*entity.Page[entity.Span], error) {
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM spans WHERE %s", whereClause)
+	if err := s.reader.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM spans
+		WHERE %s
+		ORDER BY started_at DESC, id
+		LIMIT ? OFFSET ?
+	`, spanColumns, whereClause)
+
+	args = append(args, limit, offset)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spans, err := scanSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewPage(spans, total, limit, offset), nil
+}
+
+func (s *Store) ListSpansForRecost(ctx context.Context, projectID string, from, to time.Time, after *entity.SpanCursor, limit int) ([]entity.Span, error) {
+	query := `
+		SELECT s.id, s.trace_id, s.type, s.model, s.provider,
+		       s.input_tokens, s.output_tokens, s.cache_read_tokens,
+		       s.cache_write_tokens, s.reasoning_tokens, s.cost_usd, s.started_at
+		FROM spans s
+		JOIN traces t ON t.id = s.trace_id
+		WHERE t.project_id = ? AND s.type = 'llm' AND s.model IS NOT NULL AND s.model != ''
+		  AND s.started_at >= ? AND s.started_at <= ?
+		  AND json_extract(s.metadata, '$.cost_override') IS NOT 1`
+	args := []any{projectID, from, to}
+	if after != nil {
+		query += ` AND (s.started_at > ? OR (s.started_at = ? AND s.id > ?))`
+		args = append(args, after.StartedAt, after.StartedAt, after.ID)
+	}
+	query += ` ORDER BY s.started_at, s.id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []entity.Span
+	for rows.Next() {
+		var sp entity.Span
+		var model, provider sql.NullString
+		var inputTokens, outputTokens sql.NullInt64
+		var cacheReadTokens, cacheWriteTokens, reasoningTokens sql.NullInt64
+		var costUSD sql.NullFloat64
+
+		if err := rows.Scan(&sp.ID, &sp.TraceID, &sp.Type, &model, &provider,
+			&inputTokens, &outputTokens, &cacheReadTokens,
+			&cacheWriteTokens, &reasoningTokens, &costUSD, &sp.StartedAt); err != nil {
+			return nil, err
+		}
+
+		if model.Valid {
+			sp.Model = &model.String
+		}
+		if provider.Valid {
+			sp.Provider = &provider.String
+		}
+		sp.InputTokens = nullIntPtr(inputTokens)
+		sp.OutputTokens = nullIntPtr(outputTokens)
+		sp.CacheReadTokens = nullIntPtr(cacheReadTokens)
+		sp.CacheWriteTokens = nullIntPtr(cacheWriteTokens)
+		sp.ReasoningTokens = nullIntPtr(reasoningTokens)
+		if costUSD.Valid {
+			sp.CostUSD = &costUSD.Float64
+		}
+
+		spans = append(spans, sp)
+	}
+
+	return spans, rows.Err()
+}
+
+func (s *Store) UpdateSpanCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.updateSpanValues(ctx, projectID, "cost_usd", costs)
+}
+
+func (s *Store) UpdateSpanSubtreeCosts(ctx context.Context, projectID string, costs map[string]float64) (int64, error) {
+	return s.updateSpanValues(ctx, projectID, "subtree_cost_usd", costs)
+}
+
+// updateSpanValues sets column to each span's value, by span ID, in one
+// transaction
+func (s *Store) updateSpanValues(ctx context.Context, projectID, column string, values map[string]float64) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Scope by project so a caller can never rewrite another project's spans.
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE spans SET `+column+` = ?
+		WHERE id = ? AND trace_id IN (SELECT id FROM traces WHERE project_id = ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var updated int64
+	for spanID, value := range values {
+		result, err := stmt.ExecContext(ctx, value, spanID, projectID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		updated += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+func (s *Store) ExpireSpanContent(ctx context.Context, projectID string, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE spans SET input = NULL, output = NULL, thinking = NULL,
+			metadata = json_set(COALESCE(metadata, '{}'), '$.content_expired', json('true'))
+		WHERE trace_id IN (SELECT id FROM traces WHERE project_id = ?)
+		  AND started_at < ?
+		  AND json_extract(COALESCE(metadata, '{}'), '$.content_expired') IS NULL
+	`, projectID, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// nullIntPtr converts a nullable integer column into an optional int.
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+// ============================================
+// SESSION OPERATIONS
+// ============================================
 
 func (s *Store) ListSessions(ctx context.Context, projectID string, filter entity.SessionFilter) (*entity.Page[entity.Session], error) {
 	where := []string{"t.project_id = ?", "t.session_id IS NOT NULL"}
@@ -849,7 +1664,7 @@ func (s *Store) ListSessions(ctx context.Context, projectID string, filter entit
 	countQuery := fmt.Sprintf(`
 		SELECT COUNT(DISTINCT t.session_id) FROM traces t WHERE %s
 	`, whereClause)
-	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+	if err := s.reader.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, err
 	}
 
@@ -882,7 +1697,7 @@ func (s *Store) ListSessions(ctx context.Context, projectID string, filter entit
 	`, whereClause)
 
 	args = append(args, limit, offset)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -913,12 +1728,63 @@ func (s *Store) ListSessions(ctx context.Context, projectID string, filter entit
 		sessions = append(sessions, sess)
 	}
 
-	return &entity.Page[entity.Session]{
-		Data:   sessions,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
-	}, nil
+	return entity.NewPage(sessions, total, limit, offset), nil
+}
+
+func (s *Store) GetProjectFootprint(ctx context.Context, projectID string) (entity.Footprint, error) {
+	// SQLite has no per-table sizes, so bytes are the summed lengths of each
+	// row's columns (integers and reals counted as 8 bytes)
+	var fp entity.Footprint
+	err := s.reader.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(
+			length(id) + length(project_id) + COALESCE(length(name), 0) +
+			COALESCE(length(session_id), 0) + COALESCE(length(user_id), 0) + length(status) +
+			COALESCE(length(tags), 0) + COALESCE(length(metadata), 0) +
+			COALESCE(length(created_at), 0) + COALESCE(length(updated_at), 0)
+		), 0)
+		FROM traces WHERE project_id = ?
+	`, projectID).Scan(&fp.Traces, &fp.TraceBytes)
+	if err != nil {
+		return fp, fmt.Errorf("GetProjectFootprint traces query error: %w", err)
+	}
+
+	err = s.reader.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(
+			length(id) + length(trace_id) + COALESCE(length(parent_span_id), 0) +
+			length(type) + COALESCE(length(sub_type), 0) + length(name) + length(status) +
+			COALESCE(length(input), 0) + COALESCE(length(output), 0) +
+			COALESCE(length(thinking), 0) + COALESCE(length(tool_uses), 0) +
+			COALESCE(length(error_message), 0) + COALESCE(length(model), 0) +
+			COALESCE(length(provider), 0) + COALESCE(length(stop_reason), 0) +
+			COALESCE(length(metadata), 0) + COALESCE(length(model_params), 0) +
+			COALESCE(length(tags), 0) + COALESCE(length(links), 0) + COALESCE(length(started_at), 0) +
+			COALESCE(length(ended_at), 0) + 8 * 12
+		), 0)
+		FROM spans WHERE trace_id IN (SELECT id FROM traces WHERE project_id = ?)
+	`, projectID).Scan(&fp.Spans, &fp.SpanBytes)
+	if err != nil {
+		return fp, fmt.Errorf("GetProjectFootprint spans query error: %w", err)
+	}
+	return fp, nil
+}
+
+// ============================================
+// SCORE OPERATIONS
+// ============================================
+
+func (s *Store) CreateScore(ctx context.Context, score *entity.Score) error {
+	if score.ID == "" {
+		score.ID = uuid.New().String()
+	}
+	if score.CreatedAt.IsZero() {
+		score.CreatedAt = time.Now()
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scores (id, project_id, trace_id, name, value, source, comment, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, score.ID, score.ProjectID, score.TraceID, score.Name, score.Value, string(score.Source), score.Comment, score.CreatedAt)
+	return err
 }
 
 // ============================================
@@ -944,6 +1810,18 @@ func buildSQLiteFilters(f entity.AnalyticsFilter) (string, []interface{}) {
 		clauses = append(clauses, "t.name = ?")
 		args = append(args, f.Name)
 	}
+	if len(f.ExcludeTags) > 0 {
+		clauses = append(clauses, "NOT EXISTS (SELECT 1 FROM json_each(t.tags) WHERE value IN (?"+strings.Repeat(", ?", len(f.ExcludeTags)-1)+"))")
+		for _, tag := range f.ExcludeTags {
+			args = append(args, tag)
+		}
+	}
+	if len(f.ExcludeEnvironments) > 0 {
+		clauses = append(clauses, "COALESCE(json_extract(t.metadata, '$.environment'), '') NOT IN (?"+strings.Repeat(", ?", len(f.ExcludeEnvironments)-1)+")")
+		for _, env := range f.ExcludeEnvironments {
+			args = append(args, env)
+		}
+	}
 	sql := ""
 	if len(clauses) > 0 {
 		sql = " AND " + strings.Join(clauses, " AND ")
@@ -960,7 +1838,12 @@ func (s *Store) GetStats(ctx context.Context, projectID string, q entity.Analyti
 			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as total_cost,
 			COALESCE(AVG(s.duration_ms), 0) as avg_duration,
-			COUNT(DISTINCT CASE WHEN t.status = 'error' THEN t.id END) as error_count
+			COUNT(DISTINCT CASE WHEN t.status = 'error' THEN t.id END) as error_count,
+			COUNT(DISTINCT NULLIF(s.model, '')) as distinct_models,
+			COUNT(DISTINCT NULLIF(t.user_id, '')) as distinct_users,
+			COUNT(CASE WHEN s.status = 'error' THEN 1 END) as error_spans,
+			COUNT(CASE WHEN s.status = 'timeout' THEN 1 END) as timeout_spans,
+			COUNT(CASE WHEN s.status = 'cancelled' THEN 1 END) as cancelled_spans
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
 		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
@@ -969,12 +1852,16 @@ func (s *Store) GetStats(ctx context.Context, projectID string, q entity.Analyti
 	var stats entity.Stats
 	var errorCount int
 
-	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
+	// Bind as time.Time so bounds compare in the same format the driver stores
+	// created_at in; RFC3339 strings ("T" separator) mis-order against it within a day.
+	args := []interface{}{projectID, q.From, q.To}
 	args = append(args, filterArgs...)
 	var avgDuration float64
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+	err := s.reader.QueryRowContext(ctx, query, args...).Scan(
 		&stats.TotalTraces, &stats.TotalSpans, &stats.TotalTokens,
-		&stats.TotalCostUSD, &avgDuration, &errorCount)
+		&stats.TotalCostUSD, &avgDuration, &errorCount,
+		&stats.DistinctModels, &stats.DistinctUsers,
+		&stats.ErrorSpans, &stats.TimeoutSpans, &stats.CancelledSpans)
 	if err != nil {
 		return nil, fmt.Errorf("GetStats query error: %w", err)
 	}
@@ -988,31 +1875,26 @@ func (s *Store) GetStats(ctx context.Context, projectID string, q entity.Analyti
 }
 
 func (s *Store) GetUsageTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.DataPoint, error) {
-	// Determine date grouping length based on granularity
-	// For day: substr(1,10) = "YYYY-MM-DD"
-	// For hour: substr(1,13) = "YYYY-MM-DDTHH"
-	// For week: we'll use substr(1,10) and group by date
-	dateLen := 10 // default: day
-	if opts.Granularity == "hour" {
-		dateLen = 13
-	}
+	bucket, layout := sqliteTimeBucket(opts.Granularity, "t.created_at")
+	filterSQL, filterArgs := buildSQLiteFilters(opts.Filter)
 
-	// Use substr to extract ISO8601 date part since SQLite strftime doesn't parse Go's RFC3339
 	query := fmt.Sprintf(`
 		SELECT
-			substr(t.created_at, 1, %d) as date,
+			%s as date,
 			COUNT(DISTINCT t.id) as traces,
 			COALESCE(COUNT(s.id), 0) as spans,
 			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as tokens,
 			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as cost
 		FROM traces t
 		LEFT JOIN spans s ON s.trace_id = t.id
-		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
-		GROUP BY substr(t.created_at, 1, %d)
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?%s
+		GROUP BY date
 		ORDER BY date
-	`, dateLen, dateLen)
+	`, bucket, filterSQL)
 
-	rows, err := s.db.QueryContext(ctx, query, projectID, opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339))
+	args := []interface{}{projectID, opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339)}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetUsageTimeSeries query error: %w", err)
 	}
@@ -1027,14 +1909,57 @@ func (s *Store) GetUsageTimeSeries(ctx context.Context, projectID string, opts e
 			return nil, fmt.Errorf("GetUsageTimeSeries scan error: %w", err)
 		}
 
-		// Parse date string back to time.Time
-		dp.Time, _ = time.Parse("2006-01-02", dateStr)
+		dp.Time = parseSQLiteTimeBucket(dateStr, layout)
 		dataPoints = append(dataPoints, dp)
 	}
 
 	return dataPoints, nil
 }
 
+// sqliteTimeBucket returns the SQL expression that truncates a stored timestamp
+// column to the start of its time-series bucket, plus the layout to parse it back.
+// Timestamps are stored as text, so we slice the ISO8601 prefix instead of using
+// strftime on the full value (SQLite can't parse Go's time format).
+//
+//	day:  "YYYY-MM-DD"
+//	hour: "YYYY-MM-DD HH" (or "YYYY-MM-DDTHH")
+//	week: the Monday starting the ISO week, matching Postgres date_trunc('week')
+func sqliteTimeBucket(granularity, column string) (expr, layout string) {
+	day := fmt.Sprintf("substr(%s, 1, 10)", column)
+	switch granularity {
+	case "hour":
+		return fmt.Sprintf("substr(%s, 1, 13)", column), "2006-01-02 15"
+	case "week":
+		// strftime('%w') is 0=Sunday..6=Saturday; shift so Monday is 0 days back.
+		return fmt.Sprintf("date(%s, '-' || ((CAST(strftime('%%w', %s) AS INTEGER) + 6) %% 7) || ' days')", day, day), "2006-01-02"
+	default:
+		return day, "2006-01-02"
+	}
+}
+
+// parseSQLiteTime parses a DATETIME value read through an aggregate (which the
+// driver returns as text): either a bound time.Time, stored in Go's
+// time.String format, or a CURRENT_TIMESTAMP default. Returns the zero time
+// when value is empty or unparseable.
+func parseSQLiteTime(value string) time.Time {
+	// Drop the monotonic clock reading Go appends to time.Now().String()
+	if i := strings.Index(value, " m="); i >= 0 {
+		value = value[:i]
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999 -0700 MST", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSQLiteTimeBucket parses a bucket produced by sqliteTimeBucket.
+func parseSQLiteTimeBucket(value, layout string) time.Time {
+	t, _ := time.Parse(layout, strings.Replace(value, "T", " ", 1))
+	return t
+}
+
 func (s *Store) GetModelStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ModelStats, error) {
 	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
 	query := `
@@ -1057,7 +1982,7 @@ func (s *Store) GetModelStats(ctx context.Context, projectID string, q entity.An
 	`
 	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
 	args = append(args, filterArgs...)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetModelStats: %w", err)
 	}
@@ -1077,20 +2002,175 @@ func (s *Store) GetModelStats(ctx context.Context, projectID string, q entity.An
 	return results, nil
 }
 
+func (s *Store) GetCacheEfficiency(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.CacheEfficiency, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			s.model,
+			COALESCE(s.provider, 'unknown') as provider,
+			COUNT(*) as requests,
+			COALESCE(SUM(CASE WHEN LOWER(s.provider) IN ('anthropic', 'bedrock')
+				THEN COALESCE(s.input_tokens, 0) + COALESCE(s.cache_read_tokens, 0) + COALESCE(s.cache_write_tokens, 0)
+				ELSE COALESCE(s.input_tokens, 0) END), 0) as input_tokens,
+			COALESCE(SUM(s.cache_read_tokens), 0) as cache_read_tokens,
+			COALESCE(SUM(s.cache_write_tokens), 0) as cache_write_tokens
+		FROM spans s
+		JOIN traces t ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND s.type = 'llm' AND s.model IS NOT NULL AND s.model != ''
+	` + filterSQL + `
+		GROUP BY s.model, s.provider
+		ORDER BY input_tokens DESC, s.model
+	`
+	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetCacheEfficiency: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.CacheEfficiency
+	for rows.Next() {
+		var c entity.CacheEfficiency
+		if err := rows.Scan(&c.Model, &c.Provider, &c.Requests, &c.InputTokens, &c.CacheReadTokens, &c.CacheWriteTokens); err != nil {
+			return nil, fmt.Errorf("GetCacheEfficiency scan: %w", err)
+		}
+		results = append(results, c)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetFeedbackStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.FeedbackStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			COUNT(*) as total,
+			COALESCE(SUM(CASE WHEN sc.value > 0 THEN 1 ELSE 0 END), 0) as positive,
+			COALESCE(SUM(CASE WHEN sc.value = 0 THEN 1 ELSE 0 END), 0) as neutral,
+			COALESCE(SUM(CASE WHEN sc.value < 0 THEN 1 ELSE 0 END), 0) as negative
+		FROM scores sc
+		JOIN traces t ON sc.trace_id = t.id
+		WHERE sc.project_id = ? AND sc.source = ? AND sc.name = ?
+			AND sc.created_at >= ? AND sc.created_at <= ?
+	` + filterSQL
+
+	// Bound as time.Time to compare in the driver's storage format (see GetStats)
+	args := []interface{}{projectID, string(entity.ScoreSourceUser), entity.ScoreNameFeedback, q.From, q.To}
+	args = append(args, filterArgs...)
+
+	var stats entity.FeedbackStats
+	if err := s.reader.QueryRowContext(ctx, query, args...).Scan(
+		&stats.Total, &stats.Positive, &stats.Neutral, &stats.Negative); err != nil {
+		return nil, fmt.Errorf("GetFeedbackStats query error: %w", err)
+	}
+	if stats.Total > 0 {
+		stats.PositiveRate = (float64(stats.Positive) / float64(stats.Total)) * 100
+	}
+	return &stats, nil
+}
+
+func (s *Store) GetSessionStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.SessionStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	// One row per session; durations are computed below since created_at is
+	// stored in Go's time format, which SQLite's date functions can't parse
+	query := `
+		SELECT
+			COUNT(DISTINCT t.id) as traces,
+			COALESCE(SUM(COALESCE(s.cost_usd, 0)), 0) as cost,
+			MIN(t.created_at) as first_trace_at,
+			MAX(t.created_at) as last_trace_at
+		FROM traces t
+		LEFT JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND t.session_id IS NOT NULL AND t.session_id != ''
+	` + filterSQL + `
+		GROUP BY t.session_id
+	`
+	// Bound as time.Time to compare in the driver's storage format (see GetStats)
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessionStats query error: %w", err)
+	}
+	defer rows.Close()
+
+	var stats entity.SessionStats
+	var traces int
+	var cost float64
+	var duration time.Duration
+	for rows.Next() {
+		var n int
+		var c float64
+		var firstTraceAt, lastTraceAt string
+		if err := rows.Scan(&n, &c, &firstTraceAt, &lastTraceAt); err != nil {
+			return nil, fmt.Errorf("GetSessionStats scan error: %w", err)
+		}
+		stats.Sessions++
+		traces += n
+		cost += c
+		duration += parseSQLiteTime(lastTraceAt).Sub(parseSQLiteTime(firstTraceAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSessionStats query error: %w", err)
+	}
+	if stats.Sessions > 0 {
+		stats.AvgTracesPerSession = float64(traces) / float64(stats.Sessions)
+		stats.AvgCostUSD = cost / float64(stats.Sessions)
+		stats.AvgDurationMs = int(duration.Milliseconds() / int64(stats.Sessions))
+	}
+
+	// New sessions: those whose first trace ever falls in the period
+	day, layout := sqliteTimeBucket("day", "MIN(t.created_at)")
+	newQuery := `
+		SELECT day, COUNT(*) FROM (
+			SELECT ` + day + ` as day
+			FROM traces t
+			WHERE t.project_id = ? AND t.session_id IS NOT NULL AND t.session_id != ''
+	` + filterSQL + `
+			GROUP BY t.session_id
+			HAVING MIN(t.created_at) >= ? AND MIN(t.created_at) <= ?
+		)
+		GROUP BY day
+		ORDER BY day
+	`
+	newArgs := append([]interface{}{projectID}, filterArgs...)
+	newArgs = append(newArgs, q.From, q.To)
+	newRows, err := s.reader.QueryContext(ctx, newQuery, newArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessionStats new sessions query error: %w", err)
+	}
+	defer newRows.Close()
+
+	for newRows.Next() {
+		var dp entity.SessionDataPoint
+		var dateStr string
+		if err := newRows.Scan(&dateStr, &dp.NewSessions); err != nil {
+			return nil, fmt.Errorf("GetSessionStats new sessions scan error: %w", err)
+		}
+		dp.Time = parseSQLiteTimeBucket(dateStr, layout)
+		stats.NewSessions = append(stats.NewSessions, dp)
+	}
+	return &stats, newRows.Err()
+}
+
 func (s *Store) GetTagStats(ctx context.Context, projectID string, q entity.AnalyticsQuery, prefix string) ([]entity.TagStats, error) {
-	// SQLite doesn't have unnest; use JSON each
+	// SQLite doesn't have unnest; use JSON each. Untagged traces store JSON
+	// null, which json_each yields as a single null row, so keep text values
 	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
 	query := `
 		SELECT
 			trim(je.value, '"') as tag,
 			COUNT(DISTINCT t.id) as traces,
-			COALESCE(SUM(s.input_tokens + s.output_tokens), 0) as total_tokens,
+			COALESCE(SUM(COALESCE(s.input_tokens, 0) + COALESCE(s.output_tokens, 0)), 0) as total_tokens,
 			COALESCE(SUM(s.cost_usd), 0) as total_cost,
 			COALESCE(AVG(s.duration_ms), 0) as avg_latency
 		FROM traces t
 		JOIN spans s ON s.trace_id = t.id,
 		json_each(t.tags) as je
 		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND je.type = 'text'
 			AND (? = '' OR trim(je.value, '"') LIKE ? || '%')
 	` + filterSQL + `
 		GROUP BY tag
@@ -1098,7 +2178,7 @@ func (s *Store) GetTagStats(ctx context.Context, projectID string, q entity.Anal
 	`
 	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339), prefix, prefix}
 	args = append(args, filterArgs...)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetTagStats: %w", err)
 	}
@@ -1139,7 +2219,7 @@ func (s *Store) GetTopUsers(ctx context.Context, projectID string, q entity.Anal
 	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
 	args = append(args, filterArgs...)
 	args = append(args, limit)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetTopUsers: %w", err)
 	}
@@ -1160,6 +2240,148 @@ func (s *Store) GetTopUsers(ctx context.Context, projectID string, q entity.Anal
 	return results, nil
 }
 
+func (s *Store) GetToolViolationStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.ToolViolationStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			json_extract(v.value, '$.tool') as tool,
+			COUNT(*) as violations,
+			COUNT(DISTINCT s.id) as spans
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id,
+		json_each(s.metadata, '$.tool_arg_violations') as v
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+	` + filterSQL + `
+		GROUP BY tool
+		ORDER BY violations DESC, tool
+	`
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetToolViolationStats: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.ToolViolationStats
+	for rows.Next() {
+		var v entity.ToolViolationStats
+		if err := rows.Scan(&v.Tool, &v.Violations, &v.Spans); err != nil {
+			return nil, fmt.Errorf("GetToolViolationStats scan: %w", err)
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetEvalStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.EvalStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			s.name,
+			COALESCE(json_extract(s.metadata, '$.eval_verdict.label'), '') as label,
+			COUNT(*) as verdicts,
+			COUNT(json_extract(s.metadata, '$.eval_verdict.score')) as scored,
+			AVG(json_extract(s.metadata, '$.eval_verdict.score')) as avg_score
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND s.type = 'eval' AND json_extract(s.metadata, '$.eval_verdict') IS NOT NULL
+	` + filterSQL + `
+		GROUP BY s.name, label
+		ORDER BY s.name, verdicts DESC, label
+	`
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetEvalStats: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.EvalStats
+	for rows.Next() {
+		var e entity.EvalStats
+		var avgScore sql.NullFloat64
+		if err := rows.Scan(&e.Name, &e.Label, &e.Verdicts, &e.Scored, &avgScore); err != nil {
+			return nil, fmt.Errorf("GetEvalStats scan: %w", err)
+		}
+		if avgScore.Valid {
+			e.AvgScore = &avgScore.Float64
+		}
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetUnpricedModels(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.UnpricedModelStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			COALESCE(s.provider, '') as provider,
+			COALESCE(s.model, '') as model,
+			COUNT(*) as spans
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND json_extract(s.metadata, '$.unpriced_model') = 1
+	` + filterSQL + `
+		GROUP BY provider, model
+		ORDER BY spans DESC, provider, model
+	`
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetUnpricedModels: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.UnpricedModelStats
+	for rows.Next() {
+		var m entity.UnpricedModelStats
+		if err := rows.Scan(&m.Provider, &m.Model, &m.Spans); err != nil {
+			return nil, fmt.Errorf("GetUnpricedModels scan: %w", err)
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) GetStorageStats(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.StorageStats, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT
+			s.type,
+			COUNT(*) as spans,
+			COALESCE(SUM(s.input_bytes), 0) as input_bytes,
+			COALESCE(SUM(s.output_bytes), 0) as output_bytes
+		FROM traces t
+		JOIN spans s ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+	` + filterSQL + `
+		GROUP BY s.type
+		ORDER BY input_bytes + output_bytes DESC, s.type
+	`
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetStorageStats: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.StorageStats
+	for rows.Next() {
+		var st entity.StorageStats
+		if err := rows.Scan(&st.Type, &st.Spans, &st.InputBytes, &st.OutputBytes); err != nil {
+			return nil, fmt.Errorf("GetStorageStats scan: %w", err)
+		}
+		results = append(results, st)
+	}
+	return results, rows.Err()
+}
+
 func (s *Store) GetHourlyHeatmap(ctx context.Context, projectID string, q entity.AnalyticsQuery) ([]entity.HourlyHeatmap, error) {
 	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
 	query := `
@@ -1178,7 +2400,7 @@ func (s *Store) GetHourlyHeatmap(ctx context.Context, projectID string, q entity
 	`
 	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
 	args = append(args, filterArgs...)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetHourlyHeatmap: %w", err)
 	}
@@ -1237,7 +2459,7 @@ func (s *Store) GetLatencyDistribution(ctx context.Context, projectID string, q
 	`
 	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
 	args = append(args, filterArgs...)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetLatencyDistribution: %w", err)
 	}
@@ -1254,11 +2476,86 @@ func (s *Store) GetLatencyDistribution(ctx context.Context, projectID string, q
 	return results, nil
 }
 
-func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error) {
-	dateLen := 10
-	if opts.Granularity == "hour" {
-		dateLen = 13
+// GetLatencyHistogram counts span durations per bucket, numbering each span's
+// bucket with a CASE over the edges
+func (s *Store) GetLatencyHistogram(ctx context.Context, projectID string, q entity.LatencyHistogramQuery) ([]entity.HistogramBucket, error) {
+	var bucket strings.Builder
+	bucket.WriteString("CASE")
+	for i, edge := range q.Edges {
+		fmt.Fprintf(&bucket, " WHEN s.duration_ms < %d THEN %d", edge, i)
 	}
+	fmt.Fprintf(&bucket, " ELSE %d END", len(q.Edges))
+
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		SELECT ` + bucket.String() + ` as bucket, COUNT(*) as count
+		FROM spans s
+		JOIN traces t ON s.trace_id = t.id
+		WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+			AND s.duration_ms IS NOT NULL
+	` + filterSQL
+	args := []interface{}{projectID, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)}
+	args = append(args, filterArgs...)
+	if q.SpanType != "" {
+		query += ` AND s.type = ?`
+		args = append(args, string(q.SpanType))
+	}
+	if q.Model != "" {
+		query += ` AND s.model = ?`
+		args = append(args, q.Model)
+	}
+	query += ` GROUP BY bucket`
+
+	rows, err := s.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatencyHistogram: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := entity.NewHistogramBuckets(q.Edges)
+	for rows.Next() {
+		var i, count int
+		if err := rows.Scan(&i, &count); err != nil {
+			return nil, fmt.Errorf("GetLatencyHistogram scan: %w", err)
+		}
+		buckets[i].Count = count
+	}
+	return buckets, rows.Err()
+}
+
+// GetLatencyPercentiles approximates p50/p95/p99 span latency over the whole
+// period with ordered offsets, as GetLatencyTimeSeries does per bucket
+func (s *Store) GetLatencyPercentiles(ctx context.Context, projectID string, q entity.AnalyticsQuery) (*entity.LatencyPercentiles, error) {
+	filterSQL, filterArgs := buildSQLiteFilters(q.Filter)
+	query := `
+		WITH durations AS (
+			SELECT s.duration_ms
+			FROM spans s
+			JOIN traces t ON s.trace_id = t.id
+			WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
+				AND s.duration_ms IS NOT NULL AND s.duration_ms > 0
+	` + filterSQL + `
+		),
+		counts AS (SELECT COUNT(*) as cnt FROM durations)
+		SELECT
+			COALESCE((SELECT duration_ms FROM durations ORDER BY duration_ms LIMIT 1 OFFSET (SELECT cnt * 50 / 100 FROM counts)), 0),
+			COALESCE((SELECT duration_ms FROM durations ORDER BY duration_ms LIMIT 1 OFFSET (SELECT cnt * 95 / 100 FROM counts)), 0),
+			COALESCE((SELECT duration_ms FROM durations ORDER BY duration_ms LIMIT 1 OFFSET (SELECT cnt * 99 / 100 FROM counts)), 0)
+	`
+	// Bind as time.Time, as in GetStats, so bounds compare like created_at is stored
+	args := []interface{}{projectID, q.From, q.To}
+	args = append(args, filterArgs...)
+
+	var p entity.LatencyPercentiles
+	if err := s.reader.QueryRowContext(ctx, query, args...).Scan(&p.P50, &p.P95, &p.P99); err != nil {
+		return nil, fmt.Errorf("GetLatencyPercentiles: %w", err)
+	}
+	return &p, nil
+}
+
+func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts entity.TimeSeriesOpts) ([]entity.LatencyPoint, error) {
+	bucket, layout := sqliteTimeBucket(opts.Granularity, "t.created_at")
+	filterSQL, filterArgs := buildSQLiteFilters(opts.Filter)
 
 	// SQLite lacks PERCENTILE_CONT. We approximate percentiles using
 	// subqueries with LIMIT/OFFSET based on the count per time bucket.
@@ -1266,12 +2563,12 @@ func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts
 	query := fmt.Sprintf(`
 		WITH bucketed AS (
 			SELECT
-				substr(t.created_at, 1, %d) as date,
+				%s as date,
 				s.duration_ms
 			FROM spans s
 			JOIN traces t ON s.trace_id = t.id
 			WHERE t.project_id = ? AND t.created_at >= ? AND t.created_at <= ?
-				AND s.duration_ms IS NOT NULL AND s.duration_ms > 0
+				AND s.duration_ms IS NOT NULL AND s.duration_ms > 0%s
 		),
 		counts AS (
 			SELECT date, COUNT(*) as cnt FROM bucketed GROUP BY date
@@ -1285,9 +2582,11 @@ func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts
 		JOIN bucketed b ON b.date = c.date
 		GROUP BY b.date
 		ORDER BY b.date
-	`, dateLen)
+	`, bucket, filterSQL)
 
-	rows, err := s.db.QueryContext(ctx, query, projectID, opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339))
+	args := []interface{}{projectID, opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339)}
+	args = append(args, filterArgs...)
+	rows, err := s.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("GetLatencyTimeSeries: %w", err)
 	}
@@ -1303,7 +2602,7 @@ func (s *Store) GetLatencyTimeSeries(ctx context.Context, projectID string, opts
 		}
 		p.P50 = int(p50)
 		p.P95 = int(p95)
-		p.Time, _ = time.Parse("2006-01-02", dateStr)
+		p.Time = parseSQLiteTimeBucket(dateStr, layout)
 		results = append(results, p)
 	}
 	return results, nil
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -249,7 +250,7 @@ func TestAnalyticsQueries(t *testing.T) {
 		Status:       entity.SpanStatusSuccess,
 		StartedAt:    time.Now(),
 	}
-	if err := store.CreateSpan(ctx, &span); err != nil {
+	if err := store.CreateSpan(ctx, project.ID, &span); err != nil {
 		t.Fatalf("failed to create span: %v", err)
 	}
 
@@ -281,4 +282,399 @@ func TestAnalyticsQueries(t *testing.T) {
 		}
 		t.Logf("Data points: %d", len(data))
 	})
+
+	t.Run("GetLatencyPercentiles", func(t *testing.T) {
+		// Durations 1..100ms; the span above has none and is ignored
+		spans := make([]entity.Span, 100)
+		for i := range spans {
+			duration := i + 1
+			spans[i] = entity.Span{TraceID: trace.ID, Type: entity.SpanTypeTool, Name: "step",
+				Status: entity.SpanStatusSuccess, StartedAt: time.Now(), DurationMs: &duration}
+		}
+		if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
+			t.Fatalf("failed to create spans: %v", err)
+		}
+
+		q := entity.AnalyticsQuery{Period: entity.Period{From: time.Now().Add(-24 * time.Hour), To: time.Now().Add(24 * time.Hour)}}
+		p, err := store.GetLatencyPercentiles(ctx, project.ID, q)
+		if err != nil {
+			t.Fatalf("GetLatencyPercentiles failed: %v", err)
+		}
+		if p.P50 != 51 || p.P95 != 96 || p.P99 != 100 {
+			t.Errorf("expected p50/p95/p99 of 51/96/100, got %+v", p)
+		}
+
+		q.Period = entity.Period{From: time.Now().Add(-48 * time.Hour), To: time.Now().Add(-24 * time.Hour)}
+		if p, err := store.GetLatencyPercentiles(ctx, project.ID, q); err != nil || *p != (entity.LatencyPercentiles{}) {
+			t.Errorf("expected zeros for an empty period, got %+v (%v)", p, err)
+		}
+	})
+}
+
+func TestGetSessionStats(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	project := newTestProject(t, store, "Sessions")
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-3 * 24 * time.Hour)
+	// createTrace adds a trace to a session at day+offset with one span of the given cost
+	createTrace := func(t *testing.T, sessionID string, offset time.Duration, cost float64) {
+		t.Helper()
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		if sessionID != "" {
+			trace.SessionID = &sessionID
+		}
+		if err := store.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		trace.CreatedAt = day.Add(offset)
+		if _, err := store.db.ExecContext(ctx, `UPDATE traces SET created_at = ? WHERE id = ?`, trace.CreatedAt, trace.ID); err != nil {
+			t.Fatalf("failed to backdate trace: %v", err)
+		}
+		span := entity.Span{TraceID: trace.ID, Type: entity.SpanTypeLLM, Name: "call",
+			Status: entity.SpanStatusSuccess, StartedAt: trace.CreatedAt, CostUSD: &cost}
+		if err := store.CreateSpan(ctx, project.ID, &span); err != nil {
+			t.Fatalf("failed to create span: %v", err)
+		}
+	}
+
+	// Started before the period; only its last trace is in it
+	createTrace(t, "early", -48*time.Hour, 5)
+	createTrace(t, "early", time.Hour, 1)
+	// Three traces over 2 minutes on the first day
+	createTrace(t, "chat-a", 10*time.Hour, 0.5)
+	createTrace(t, "chat-a", 10*time.Hour+time.Minute, 0.25)
+	createTrace(t, "chat-a", 10*time.Hour+2*time.Minute, 0.25)
+	// Two traces over 4 minutes on the second day
+	createTrace(t, "chat-b", 34*time.Hour, 2)
+	createTrace(t, "chat-b", 34*time.Hour+4*time.Minute, 1)
+	// Traces outside a session are ignored
+	createTrace(t, "", 12*time.Hour, 100)
+
+	q := entity.AnalyticsQuery{Period: entity.Period{From: day, To: day.Add(72 * time.Hour)}}
+	stats, err := store.GetSessionStats(ctx, project.ID, q)
+	if err != nil {
+		t.Fatalf("GetSessionStats failed: %v", err)
+	}
+
+	if stats.Sessions != 3 {
+		t.Errorf("expected 3 sessions, got %d", stats.Sessions)
+	}
+	if stats.AvgTracesPerSession != 2 {
+		t.Errorf("expected 2 traces per session, got %v", stats.AvgTracesPerSession)
+	}
+	if stats.AvgCostUSD != 5.0/3 {
+		t.Errorf("expected avg cost %v, got %v", 5.0/3, stats.AvgCostUSD)
+	}
+	if want := int((2 * time.Minute).Milliseconds()); stats.AvgDurationMs != want {
+		t.Errorf("expected avg duration %dms, got %d", want, stats.AvgDurationMs)
+	}
+
+	want := []entity.SessionDataPoint{{Time: day, NewSessions: 1}, {Time: day.Add(24 * time.Hour), NewSessions: 1}}
+	if len(stats.NewSessions) != len(want) {
+		t.Fatalf("expected new sessions %v, got %v", want, stats.NewSessions)
+	}
+	for i, dp := range stats.NewSessions {
+		if !dp.Time.Equal(want[i].Time) || dp.NewSessions != want[i].NewSessions {
+			t.Errorf("new sessions[%d]: expected %v, got %v", i, want[i], dp)
+		}
+	}
+
+	t.Run("empty period", func(t *testing.T) {
+		q := entity.AnalyticsQuery{Period: entity.Period{From: day.Add(-240 * time.Hour), To: day.Add(-120 * time.Hour)}}
+		stats, err := store.GetSessionStats(ctx, project.ID, q)
+		if err != nil {
+			t.Fatalf("GetSessionStats failed: %v", err)
+		}
+		if stats.Sessions != 0 || stats.AvgCostUSD != 0 || len(stats.NewSessions) != 0 {
+			t.Errorf("expected no sessions, got %+v", stats)
+		}
+	})
+}
+
+func TestQueriesHonorContextCancellation(t *testing.T) {
+	store := newTestStore(t)
+
+	t.Run("cancelled mid-query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Unbounded recursive CTE: only returns if the context aborts it.
+		start := time.Now()
+		var n int
+		err := store.db.QueryRowContext(ctx, `
+			WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c)
+			SELECT COUNT(*) FROM c
+		`).Scan(&n)
+		if err == nil {
+			t.Fatal("expected query to be aborted")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("query took %v to abort after cancellation", elapsed)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	period := entity.Period{From: time.Now().Add(-time.Hour), To: time.Now()}
+
+	t.Run("GetStats", func(t *testing.T) {
+		if _, err := store.GetStats(ctx, "p", entity.AnalyticsQuery{Period: period}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("GetUsageTimeSeries", func(t *testing.T) {
+		opts := entity.TimeSeriesOpts{Period: period, Granularity: "day"}
+		if _, err := store.GetUsageTimeSeries(ctx, "p", opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("ListTraces", func(t *testing.T) {
+		if _, err := store.ListTraces(ctx, "p", entity.TraceFilter{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestReadPoolAndCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	tmpFile := t.TempDir() + "/test_pool.db"
+	store, err := NewWithOptions(tmpFile, Options{ReadConns: 4, CheckpointInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	project := newTestProject(t, store, "pool")
+
+	t.Run("reads see committed writes", func(t *testing.T) {
+		got, err := store.GetProjectByID(ctx, project.ID)
+		if err != nil {
+			t.Fatalf("GetProjectByID failed: %v", err)
+		}
+		if got.Name != "pool" {
+			t.Errorf("expected project name pool, got %s", got.Name)
+		}
+	})
+
+	t.Run("read pool rejects writes", func(t *testing.T) {
+		if _, err := store.reader.ExecContext(ctx, "DELETE FROM projects"); err == nil {
+			t.Error("expected write through the read pool to fail")
+		}
+	})
+
+	t.Run("checkpoint truncates the WAL", func(t *testing.T) {
+		if err := store.Checkpoint(ctx); err != nil {
+			t.Fatalf("Checkpoint failed: %v", err)
+		}
+		info, err := os.Stat(tmpFile + "-wal")
+		if err != nil {
+			t.Fatalf("stat WAL: %v", err)
+		}
+		if info.Size() != 0 {
+			t.Errorf("expected empty WAL after checkpoint, got %d bytes", info.Size())
+		}
+	})
+
+	t.Run("invalid journal mode", func(t *testing.T) {
+		if _, err := NewWithOptions(t.TempDir()+"/bad.db", Options{JournalMode: "WAL); DROP"}); err == nil {
+			t.Error("expected invalid journal mode to be rejected")
+		}
+	})
+}
+
+// BenchmarkReadsDuringIngest measures read latency while a writer continuously
+// ingests span batches, with reads on the writer connection (default) vs. a
+// separate WAL read pool:
+//
+//	go test ./pkg/infrastructure/store/sqlite -run '^$' -bench ReadsDuringIngest
+func BenchmarkReadsDuringIngest(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts Options
+	}{
+		{"shared-conn", Options{}},
+		{"read-pool", Options{ReadConns: 4}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ctx := context.Background()
+			store, err := NewWithOptions(b.TempDir()+"/bench.db", bc.opts)
+			if err != nil {
+				b.Fatalf("failed to create store: %v", err)
+			}
+			defer store.Close()
+			if err := store.Migrate(ctx); err != nil {
+				b.Fatalf("failed to migrate: %v", err)
+			}
+
+			project := &entity.Project{Name: "bench", APIKey: "le_bench", APIKeyHash: "bench", OwnerEmail: "bench@test.com"}
+			if err := store.CreateProject(ctx, project); err != nil {
+				b.Fatalf("failed to create project: %v", err)
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					tr := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+					if err := store.CreateTrace(ctx, tr); err != nil {
+						return
+					}
+					spans := make([]entity.Span, 200)
+					for i := range spans {
+						spans[i] = entity.Span{TraceID: tr.ID, Type: entity.SpanTypeLLM, Name: "chat",
+							Status: entity.SpanStatusSuccess, StartedAt: time.Now()}
+					}
+					if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
+						return
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.GetProjectByID(ctx, project.ID); err != nil {
+					b.Fatalf("GetProjectByID failed: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
+
+func TestGetTracesMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	project := newTestProject(t, store, "Metrics")
+	other := newTestProject(t, store, "Other")
+
+	var traceIDs []string
+	for i := 0; i < 3; i++ {
+		trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+		if err := store.CreateTrace(ctx, trace); err != nil {
+			t.Fatalf("failed to create trace: %v", err)
+		}
+		traceIDs = append(traceIDs, trace.ID)
+
+		// Trace i gets i spans: the first has none
+		var spans []entity.Span
+		for j := 0; j < i; j++ {
+			in, out, duration, cost := 10*(j+1), 5, 100*(i+j), 0.25*float64(j+1)
+			spans = append(spans, entity.Span{
+				TraceID: trace.ID, Type: entity.SpanTypeLLM, Name: "chat", Status: entity.SpanStatusSuccess,
+				InputTokens: &in, OutputTokens: &out, DurationMs: &duration, CostUSD: &cost, StartedAt: time.Now(),
+			})
+		}
+		if err := store.CreateSpans(ctx, project.ID, spans); err != nil {
+			t.Fatalf("failed to create spans: %v", err)
+		}
+	}
+	foreign := &entity.Trace{ProjectID: other.ID, Status: entity.TraceStatusCompleted}
+	if err := store.CreateTrace(ctx, foreign); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	metrics, err := store.GetTracesMetrics(ctx, project.ID, append(traceIDs, foreign.ID, "missing"))
+	if err != nil {
+		t.Fatalf("GetTracesMetrics failed: %v", err)
+	}
+	if len(metrics) != len(traceIDs) {
+		t.Errorf("expected metrics for the project's %d traces only, got %v", len(traceIDs), metrics)
+	}
+	for _, id := range traceIDs {
+		trace, err := store.GetTrace(ctx, project.ID, id)
+		if err != nil {
+			t.Fatalf("GetTrace failed: %v", err)
+		}
+		want := entity.TraceMetrics{
+			TotalSpans:      trace.TotalSpans,
+			TotalTokens:     trace.TotalTokens,
+			TotalCostUSD:    trace.TotalCostUSD,
+			TotalDurationMs: trace.TotalDurationMs,
+		}
+		if got, ok := metrics[id]; !ok || got != want {
+			t.Errorf("trace %s: expected %+v as from GetTrace, got %+v", id, want, got)
+		}
+	}
+
+	if empty, err := store.GetTracesMetrics(ctx, project.ID, nil); err != nil || len(empty) != 0 {
+		t.Errorf("expected no metrics for no IDs, got %v %v", empty, err)
+	}
+}
+
+func TestCreateSpansPartial(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	project := newTestProject(t, store, "Partial")
+	trace := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+	if err := store.CreateTrace(ctx, trace); err != nil {
+		t.Fatalf("failed to create trace: %v", err)
+	}
+
+	span := func(id string) entity.Span {
+		return entity.Span{ID: id, TraceID: trace.ID, Type: entity.SpanTypeTool, Name: id, Status: entity.SpanStatusSuccess, StartedAt: time.Now()}
+	}
+	if err := store.CreateSpans(ctx, project.ID, []entity.Span{span("existing")}); err != nil {
+		t.Fatalf("failed to create spans: %v", err)
+	}
+
+	failed, err := store.CreateSpansPartial(ctx, project.ID, []entity.Span{span("first"), span("existing"), span("last")})
+	if err != nil {
+		t.Fatalf("CreateSpansPartial failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].Index != 1 || failed[0].Err == nil {
+		t.Fatalf("expected only the duplicate span at index 1 to fail, got %+v", failed)
+	}
+
+	got, err := store.GetTrace(ctx, project.ID, trace.ID)
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if got.TotalSpans != 3 {
+		t.Errorf("expected the existing span plus the two valid ones, got %d spans", got.TotalSpans)
+	}
+}
+
+// newTestStore returns a migrated store in a temporary directory, closed
+// when the test ends
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := New(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+// newTestProject creates a project named name, with an API key derived from it
+func newTestProject(t *testing.T, store *Store, name string) *entity.Project {
+	t.Helper()
+	project := &entity.Project{Name: name, APIKey: "le_" + name, APIKeyHash: name, OwnerEmail: name + "@test.com"}
+	if err := store.CreateProject(context.Background(), project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	return project
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"strings"
+	"time"
 
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/infrastructure/store/clickhouse"
@@ -9,23 +10,78 @@ import (
 	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
 )
 
+// Options configures store behaviour shared across backends
+type Options struct {
+	// StatementTimeout bounds each query server-side (Postgres statement_timeout,
+	// ClickHouse max_execution_time). SQLite has no server-side equivalent and
+	// relies on request context cancellation only.
+	StatementTimeout time.Duration
+
+	// SQLite tunes the SQLite backend (busy timeout, journal mode, read pool, WAL checkpoints)
+	SQLite sqlite.Options
+
+	// PostgresReadReplicaURL is a read replica of a Postgres database, serving
+	// its list and analytics reads (see postgres.Options). Ignored by other
+	// backends.
+	PostgresReadReplicaURL string
+}
+
 // New creates a new store based on the database URL
 func New(databaseURL string) (repository.Store, error) {
+	return NewWithOptions(databaseURL, Options{})
+}
+
+// NewWithOptions creates a new store based on the database URL with the given options
+func NewWithOptions(databaseURL string, opts Options) (repository.Store, error) {
 	switch {
 	case strings.HasPrefix(databaseURL, "sqlite://"):
 		path := strings.TrimPrefix(databaseURL, "sqlite://")
-		return sqlite.New(path)
+		return sqlite.NewWithOptions(path, opts.SQLite)
 
 	case strings.HasPrefix(databaseURL, "postgres://"),
 		strings.HasPrefix(databaseURL, "postgresql://"):
-		return postgres.New(databaseURL)
+		return postgres.NewWithOptions(databaseURL, postgres.Options{
+			StatementTimeout: opts.StatementTimeout,
+			ReadReplicaURL:   opts.PostgresReadReplicaURL,
+		})
 
 	case strings.HasPrefix(databaseURL, "clickhouse://"),
 		strings.HasPrefix(databaseURL, "clickhouses://"):
-		return clickhouse.New(databaseURL)
+		return clickhouse.NewWithOptions(databaseURL, clickhouse.Options{StatementTimeout: opts.StatementTimeout})
 
 	default:
 		// Default to SQLite with the provided path
-		return sqlite.New(databaseURL)
+		return sqlite.NewWithOptions(databaseURL, opts.SQLite)
+	}
+}
+
+// Backend returns the backend NewWithOptions selects for the database URL:
+// "sqlite", "postgres" or "clickhouse"
+func Backend(databaseURL string) string {
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"),
+		strings.HasPrefix(databaseURL, "postgresql://"):
+		return "postgres"
+	case strings.HasPrefix(databaseURL, "clickhouse://"),
+		strings.HasPrefix(databaseURL, "clickhouses://"):
+		return "clickhouse"
+	default:
+		return "sqlite"
+	}
+}
+
+// Backends returns the distinct backends of the database URLs, in order
+func Backends(databaseURLs ...string) []string {
+	var backends []string
+	seen := make(map[string]bool)
+	for _, u := range databaseURLs {
+		if u == "" {
+			continue
+		}
+		if b := Backend(u); !seen[b] {
+			seen[b] = true
+			backends = append(backends, b)
+		}
 	}
+	return backends
 }
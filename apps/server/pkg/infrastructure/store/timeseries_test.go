@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/infrastructure/store"
+)
+
+// testStore is a store backend plus a hook that rewrites a trace's
+// created_at, which CreateTrace always stamps with the current time.
+type testStore struct {
+	repository.Store
+	backdate func(t *testing.T, traceID string, createdAt time.Time)
+}
+
+// testStores returns every store backend available in this environment.
+// SQLite always runs; Postgres and ClickHouse join when their test URLs are set.
+func testStores(t *testing.T) map[string]testStore {
+	t.Helper()
+
+	path := t.TempDir() + "/timeseries.db"
+	urls := map[string]string{
+		"sqlite": "sqlite://" + path,
+	}
+	backdates := map[string]func(t *testing.T, traceID string, createdAt time.Time){
+		"sqlite": func(t *testing.T, traceID string, createdAt time.Time) {
+			t.Helper()
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatalf("sqlite: failed to open database: %v", err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(`UPDATE traces SET created_at = ? WHERE id = ?`, createdAt, traceID); err != nil {
+				t.Fatalf("sqlite: failed to backdate trace: %v", err)
+			}
+		},
+	}
+	if dsn := os.Getenv("POSTGRES_TEST_URL"); dsn != "" {
+		urls["postgres"] = dsn
+		backdates["postgres"] = func(t *testing.T, traceID string, createdAt time.Time) {
+			t.Helper()
+			conn, err := pgx.Connect(context.Background(), dsn)
+			if err != nil {
+				t.Fatalf("postgres: failed to connect: %v", err)
+			}
+			defer conn.Close(context.Background())
+			if _, err := conn.Exec(context.Background(), `UPDATE traces SET created_at = $1 WHERE id = $2`, createdAt, traceID); err != nil {
+				t.Fatalf("postgres: failed to backdate trace: %v", err)
+			}
+		}
+	}
+	if dsn := os.Getenv("CLICKHOUSE_TEST_URL"); dsn != "" {
+		urls["clickhouse"] = dsn
+		backdates["clickhouse"] = func(t *testing.T, traceID string, createdAt time.Time) {
+			t.Helper()
+			opts, err := clickhouse.ParseDSN(dsn)
+			if err != nil {
+				t.Fatalf("clickhouse: failed to parse DSN: %v", err)
+			}
+			conn, err := clickhouse.Open(opts)
+			if err != nil {
+				t.Fatalf("clickhouse: failed to connect: %v", err)
+			}
+			defer conn.Close()
+			// created_at is part of the sorting key, so copy the row and drop the original
+			ctx := context.Background()
+			if err := conn.Exec(ctx, `INSERT INTO traces SELECT * REPLACE (? AS created_at) FROM traces FINAL WHERE id = ?`, createdAt, traceID); err != nil {
+				t.Fatalf("clickhouse: failed to backdate trace: %v", err)
+			}
+			if err := conn.Exec(ctx, `ALTER TABLE traces DELETE WHERE id = ? AND created_at != ? SETTINGS mutations_sync = 1`, traceID, createdAt); err != nil {
+				t.Fatalf("clickhouse: failed to drop the original trace row: %v", err)
+			}
+		}
+	}
+
+	stores := make(map[string]testStore, len(urls))
+	for name, url := range urls {
+		s, err := store.New(url)
+		if err != nil {
+			t.Fatalf("%s: failed to create store: %v", name, err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("%s: failed to migrate: %v", name, err)
+		}
+		stores[name] = testStore{Store: s, backdate: backdates[name]}
+	}
+	return stores
+}
+
+func TestWeeklyTimeSeries_MatchesAcrossStores(t *testing.T) {
+	ctx := context.Background()
+
+	// Week boundaries are Mondays (Postgres date_trunc('week')).
+	createdAt := []time.Time{
+		time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC),  // Sun -> week of Mon 2025-12-29
+		time.Date(2026, 1, 5, 0, 30, 0, 0, time.UTC),  // Mon -> 2026-01-05
+		time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC),   // Wed -> 2026-01-05
+		time.Date(2026, 1, 11, 23, 0, 0, 0, time.UTC), // Sun -> 2026-01-05
+		time.Date(2026, 1, 12, 8, 0, 0, 0, time.UTC),  // Mon -> 2026-01-12
+	}
+	expected := []struct {
+		week   string
+		traces int
+	}{
+		{"2025-12-29", 1},
+		{"2026-01-05", 3},
+		{"2026-01-12", 1},
+	}
+
+	results := make(map[string][]entity.DataPoint)
+	for name, s := range testStores(t) {
+		project := &entity.Project{
+			Name:       "Weekly",
+			APIKey:     "le_" + uuid.NewString(),
+			APIKeyHash: uuid.NewString(),
+			OwnerEmail: "weekly@test.com",
+		}
+		if err := s.CreateProject(ctx, project); err != nil {
+			t.Fatalf("%s: failed to create project: %v", name, err)
+		}
+		for _, ts := range createdAt {
+			tr := &entity.Trace{ProjectID: project.ID, Status: entity.TraceStatusCompleted}
+			if err := s.CreateTrace(ctx, tr); err != nil {
+				t.Fatalf("%s: failed to create trace: %v", name, err)
+			}
+			s.backdate(t, tr.ID, ts)
+		}
+
+		points, err := s.GetUsageTimeSeries(ctx, project.ID, entity.TimeSeriesOpts{
+			Period: entity.Period{
+				From: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Granularity: "week",
+		})
+		if err != nil {
+			t.Fatalf("%s: GetUsageTimeSeries failed: %v", name, err)
+		}
+		results[name] = points
+
+		if len(points) != len(expected) {
+			t.Fatalf("%s: expected %d weekly buckets, got %d: %+v", name, len(expected), len(points), points)
+		}
+		for i, want := range expected {
+			if got := points[i].Time.UTC().Format("2006-01-02"); got != want.week {
+				t.Errorf("%s: bucket %d: expected week %s, got %s", name, i, want.week, got)
+			}
+			if points[i].Traces != want.traces {
+				t.Errorf("%s: bucket %s: expected %d traces, got %d", name, want.week, want.traces, points[i].Traces)
+			}
+		}
+	}
+
+	// Every backend must agree with SQLite bucket-for-bucket.
+	for name, points := range results {
+		for i := range points {
+			if !points[i].Time.Equal(results["sqlite"][i].Time) {
+				t.Errorf("%s: bucket %d differs from sqlite: %v vs %v", name, i, points[i].Time, results["sqlite"][i].Time)
+			}
+		}
+	}
+}
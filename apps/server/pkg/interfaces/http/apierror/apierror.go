@@ -0,0 +1,123 @@
+// Package apierror writes the JSON error envelope every API error response uses:
+//
+//	{"error": {"code": "not_found", "message": "Trace not found", "details": ...}}
+//
+// Codes are stable and machine-readable, for SDKs to branch on; messages are
+// for people and may change. Details is optional, endpoint-specific data.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+)
+
+// Code is a machine-readable error code
+type Code string
+
+const (
+	CodeValidationFailed Code = "validation_failed"   // 400, 413: the request is malformed or invalid
+	CodeUnauthorized     Code = "unauthorized"        // 401: missing or invalid credentials
+	CodeQuotaExceeded    Code = "quota_exceeded"      // 402: a plan limit was reached
+	CodeForbidden        Code = "forbidden"           // 403: authenticated, but not allowed
+	CodeFeatureNotInPlan Code = "feature_not_in_plan" // 403: the organization's plan does not include the feature
+	CodeNotFound         Code = "not_found"           // 404
+	CodeMethodNotAllowed Code = "method_not_allowed"  // 405
+	CodeConflict         Code = "conflict"            // 409: the resource already exists or changed
+	CodeRateLimited      Code = "rate_limited"        // 429: retry later
+	CodeInternal         Code = "internal_error"      // 500
+	CodeNotImplemented   Code = "not_implemented"     // 501: the feature is not configured on this server
+	CodeUnavailable      Code = "unavailable"         // 502, 503, 504: a dependency failed
+)
+
+// Envelope is the body of every error response
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// Error is the content of the envelope
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// CodeFor returns the code for an HTTP error status
+func CodeFor(status int) Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusPaymentRequired:
+		return CodeQuotaExceeded
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	default:
+		return CodeInternal
+	}
+}
+
+// Write writes an error response with the code for status
+func Write(w http.ResponseWriter, status int, message string) {
+	WriteCode(w, status, CodeFor(status), message, nil)
+}
+
+// WriteCode writes an error response with an explicit code and optional details
+func WriteCode(w http.ResponseWriter, status int, code Code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: Error{Code: code, Message: message, Details: details}})
+}
+
+// StatusFor returns the HTTP status for a domain error (see entity/errors.go);
+// any other error is a 500
+func StatusFor(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, entity.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, entity.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, entity.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FromError writes the response for a domain error. notFound is the message
+// for entity.ErrNotFound; other client errors carry the error's own text. A 500
+// is logged and gets a generic message, so internal details never reach the
+// client.
+func FromError(w http.ResponseWriter, err error, notFound string) {
+	status := StatusFor(err)
+	switch status {
+	case http.StatusNotFound:
+		Write(w, status, notFound)
+	case http.StatusInternalServerError:
+		slog.Error("internal error", "error", err)
+		Write(w, status, "Internal server error")
+	default:
+		Write(w, status, err.Error())
+	}
+}
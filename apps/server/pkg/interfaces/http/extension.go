@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/lelemon/server/pkg/application/ingest"
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/infrastructure/auth"
 	"github.com/lelemon/server/pkg/interfaces/http/handler"
@@ -25,6 +26,10 @@ type RouterDeps struct {
 	PrimaryStore   repository.Store
 	AnalyticsStore repository.Store
 
+	// Ingest, for adding pipeline stages with AddSpanTransforms
+	// (e.g. PII redaction, tagging, custom cost rules)
+	IngestSvc *ingest.Service
+
 	// Auth
 	JWTService *auth.JWTService
 
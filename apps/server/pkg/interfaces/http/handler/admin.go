@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// AdminHandler handles operator maintenance requests
+type AdminHandler struct {
+	store     repository.Store
+	ingestSvc *ingest.Service
+}
+
+// NewAdminHandler creates a new admin handler over the analytics store and
+// the ingest service
+func NewAdminHandler(store repository.Store, ingestSvc *ingest.Service) *AdminHandler {
+	return &AdminHandler{store: store, ingestSvc: ingestSvc}
+}
+
+// OptimizeResponse reports a forced merge. Optimized is false, with no
+// result, when the analytics store has nothing to optimize (it is not
+// ClickHouse).
+type OptimizeResponse struct {
+	Optimized bool                      `json:"optimized"`
+	Result    *entity.TableOptimization `json:"result,omitempty"`
+}
+
+// Optimize handles POST /api/v1/admin/optimize?table=traces: it forces a merge
+// of the table's parts (OPTIMIZE TABLE ... FINAL), e.g. after a bulk import,
+// and reports the part counts before and after. The table defaults to traces.
+func (h *AdminHandler) Optimize(w http.ResponseWriter, r *http.Request) {
+	table := r.URL.Query().Get("table")
+	if table == "" {
+		table = "traces"
+	}
+
+	var resp OptimizeResponse
+	if optimizer, ok := h.store.(repository.TableOptimizer); ok {
+		result, err := optimizer.OptimizeTable(r.Context(), table)
+		if errors.Is(err, entity.ErrBadRequest) {
+			apierror.Write(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to optimize table", "table", table, "error", err)
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		resp = OptimizeResponse{Optimized: result != nil, Result: result}
+	}
+	if resp.Result != nil {
+		slog.Info("optimized table", "table", table, "parts_before", resp.Result.PartsBefore,
+			"parts_after", resp.Result.PartsAfter, "duration_ms", resp.Result.DurationMs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, resp)
+}
+
+// MaintenanceResponse reports the ingest maintenance mode and the batches it
+// holds until it ends
+type MaintenanceResponse struct {
+	Enabled      bool `json:"enabled"`
+	DeferredJobs int  `json:"deferredJobs"`
+}
+
+// GetIngestMaintenance handles GET /api/v1/admin/ingest/maintenance
+func (h *AdminHandler) GetIngestMaintenance(w http.ResponseWriter, r *http.Request) {
+	h.respondMaintenance(w)
+}
+
+// SetIngestMaintenance handles POST /api/v1/admin/ingest/maintenance with
+// {"enabled": true|false}: while enabled, ingest accepts batches but only
+// writes them to the WAL, and stores them once disabled. 409 when ingest is
+// synchronous or has no WAL.
+func (h *AdminHandler) SetIngestMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		apierror.Write(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+
+	err := h.ingestSvc.SetMaintenance(*req.Enabled)
+	if errors.Is(err, ingest.ErrMaintenanceNeedsWAL) {
+		apierror.Write(w, http.StatusConflict, "Maintenance mode needs async ingest with INGEST_WAL_PATH set")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to set ingest maintenance", "enabled", *req.Enabled, "error", err)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	slog.Info("ingest maintenance mode changed", "enabled", *req.Enabled)
+
+	h.respondMaintenance(w)
+}
+
+func (h *AdminHandler) respondMaintenance(w http.ResponseWriter) {
+	stats := h.ingestSvc.WorkerStats()
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, MaintenanceResponse{Enabled: stats.Maintenance, DeferredJobs: stats.DeferredJobs})
+}
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/service"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+)
+
+func TestAdminOptimize(t *testing.T) {
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.AdminToken = "admin-secret"
+	})
+	adminHeaders := map[string]string{"Authorization": "Bearer admin-secret"}
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		for _, headers := range []map[string]string{nil, {"Authorization": "Bearer wrong"}} {
+			resp := ts.Request("POST", "/api/v1/admin/optimize", nil, headers)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("no-op on sqlite", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/admin/optimize?table=traces", nil, adminHeaders)
+		var result struct {
+			Optimized bool `json:"optimized"`
+			Result    any  `json:"result"`
+		}
+		ParseJSON(t, resp, &result)
+		if result.Optimized || result.Result != nil {
+			t.Errorf("expected nothing optimized, got %+v", result)
+		}
+	})
+
+	t.Run("unmounted without a token", func(t *testing.T) {
+		ts := setupTestServer(t)
+		resp := ts.Request("POST", "/api/v1/admin/optimize", nil, adminHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestAdminIngestMaintenance(t *testing.T) {
+	adminHeaders := map[string]string{"Authorization": "Bearer admin-secret"}
+
+	t.Run("conflict without an async WAL", func(t *testing.T) {
+		ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+			cfg.AdminToken = "admin-secret"
+		})
+		resp := ts.Request("POST", "/api/v1/admin/ingest/maintenance", map[string]bool{"enabled": true}, adminHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected 409, got %d", resp.StatusCode)
+		}
+	})
+
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.AdminToken = "admin-secret"
+		svc := ingest.NewAsyncService(cfg.AnalyticsStore, service.NewPricingCalculator(), 10, 1)
+		t.Cleanup(func() { svc.Stop(5 * time.Second) })
+		if err := svc.EnableWAL(t.TempDir() + "/ingest.wal"); err != nil {
+			t.Fatalf("failed to enable WAL: %v", err)
+		}
+		cfg.IngestSvc = svc
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "maintenance@example.com", "password": "SecurePass123", "name": "Maintenance User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Maintenance Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	setMaintenance := func(t *testing.T, enabled bool) {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/admin/ingest/maintenance", map[string]bool{"enabled": enabled}, adminHeaders)
+		var result struct {
+			Enabled bool `json:"enabled"`
+		}
+		ParseJSON(t, resp, &result)
+		if result.Enabled != enabled {
+			t.Fatalf("expected maintenance %v, got %v", enabled, result.Enabled)
+		}
+	}
+	traceStatus := func() int {
+		resp := ts.Request("GET", "/api/v1/traces/maintenance-trace", nil, apiKeyHeaders)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	setMaintenance(t, true)
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		{"traceId": "maintenance-trace", "spanType": "tool", "name": "search", "status": "success"},
+	}}, apiKeyHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected status 200 during maintenance, got %d", resp.StatusCode)
+	}
+
+	resp = ts.Request("GET", "/api/v1/admin/ingest/maintenance", nil, adminHeaders)
+	var state struct {
+		Enabled      bool `json:"enabled"`
+		DeferredJobs int  `json:"deferredJobs"`
+	}
+	ParseJSON(t, resp, &state)
+	if !state.Enabled || state.DeferredJobs != 1 {
+		t.Errorf("expected one deferred job, got %+v", state)
+	}
+	if status := traceStatus(); status != http.StatusNotFound {
+		t.Errorf("expected the trace not stored during maintenance, got status %d", status)
+	}
+
+	setMaintenance(t, false)
+	deadline := time.Now().Add(5 * time.Second)
+	for traceStatus() != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the deferred trace stored once maintenance ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp = ts.Request("POST", "/api/v1/admin/ingest/maintenance", map[string]any{}, adminHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing enabled: expected 400, got %d", resp.StatusCode)
+	}
+}
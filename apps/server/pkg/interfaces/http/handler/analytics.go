@@ -1,13 +1,15 @@
 package handler
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lelemon/server/pkg/application/analytics"
 	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -26,15 +28,55 @@ func parseTime(value string) (time.Time, error) {
 	return time.Parse(time.RFC3339, value)
 }
 
-// parsePeriodParams extracts and validates from/to/prefix/limit from query params.
+// parsePreset resolves ?preset= (in the ?tz= time zone, UTC by default) to a
+// period. Returns nil when no preset is given; 400 on an unknown preset or
+// zone, or a preset combined with from/to.
+func parsePreset(w http.ResponseWriter, r *http.Request) (*entity.Period, bool) {
+	q := r.URL.Query()
+	preset := q.Get("preset")
+	if preset == "" {
+		return nil, true
+	}
+	if q.Get("from") != "" || q.Get("to") != "" {
+		apierror.Write(w, http.StatusBadRequest, "'preset' cannot be combined with 'from' or 'to'")
+		return nil, false
+	}
+
+	loc := time.UTC
+	if v := q.Get("tz"); v != "" {
+		l, err := time.LoadLocation(v)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'tz'. Use an IANA time zone (e.g. Europe/Madrid)")
+			return nil, false
+		}
+		loc = l
+	}
+
+	period, err := analytics.ResolvePreset(preset, time.Now(), loc)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid 'preset'. Must be one of: "+strings.Join(analytics.PeriodPresets, ", "))
+		return nil, false
+	}
+	return &period, true
+}
+
+// parsePeriodParams extracts and validates from/to (or preset)/prefix/limit from query params.
 // Returns 400 on invalid date format.
 func parsePeriodParams(w http.ResponseWriter, r *http.Request) (*analytics.PeriodRequest, bool) {
 	req := &analytics.PeriodRequest{}
 
+	period, ok := parsePreset(w, r)
+	if !ok {
+		return nil, false
+	}
+	if period != nil {
+		req.From, req.To = &period.From, &period.To
+	}
+
 	if v := r.URL.Query().Get("from"); v != "" {
 		t, err := parseTime(v)
 		if err != nil {
-			http.Error(w, `{"error":"Invalid 'from' date format. Use RFC3339 (e.g. 2026-04-01T00:00:00Z)"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'from' date format. Use RFC3339 (e.g. 2026-04-01T00:00:00Z)")
 			return nil, false
 		}
 		req.From = &t
@@ -42,7 +84,7 @@ func parsePeriodParams(w http.ResponseWriter, r *http.Request) (*analytics.Perio
 	if v := r.URL.Query().Get("to"); v != "" {
 		t, err := parseTime(v)
 		if err != nil {
-			http.Error(w, `{"error":"Invalid 'to' date format. Use RFC3339 (e.g. 2026-04-11T00:00:00Z)"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'to' date format. Use RFC3339 (e.g. 2026-04-11T00:00:00Z)")
 			return nil, false
 		}
 		req.To = &t
@@ -53,11 +95,12 @@ func parsePeriodParams(w http.ResponseWriter, r *http.Request) (*analytics.Perio
 	req.SessionID = r.URL.Query().Get("sessionId")
 	req.UserID = r.URL.Query().Get("userId")
 	req.Name = r.URL.Query().Get("name")
+	req.IncludeTests = includeTests(r)
 
 	if v := r.URL.Query().Get("limit"); v != "" {
 		n, err := strconv.Atoi(v)
 		if err != nil || n < 1 || n > 1000 {
-			http.Error(w, `{"error":"Invalid 'limit'. Must be between 1 and 1000"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'limit'. Must be between 1 and 1000")
 			return nil, false
 		}
 		req.Limit = n
@@ -66,14 +109,54 @@ func parsePeriodParams(w http.ResponseWriter, r *http.Request) (*analytics.Perio
 	return req, true
 }
 
-// parseGranularityParams extracts and validates from/to/granularity from query params.
+// parseLatencyHistogramParams extracts the period params plus the bucket
+// edges (edges=100,500,1000, ascending ms), spanType and model filters
+func parseLatencyHistogramParams(w http.ResponseWriter, r *http.Request) (*analytics.LatencyHistogramRequest, bool) {
+	period, ok := parsePeriodParams(w, r)
+	if !ok {
+		return nil, false
+	}
+	req := &analytics.LatencyHistogramRequest{
+		PeriodRequest: *period,
+		SpanType:      r.URL.Query().Get("spanType"),
+		Model:         r.URL.Query().Get("model"),
+	}
+
+	if v := r.URL.Query().Get("edges"); v != "" {
+		parts := strings.Split(v, ",")
+		if len(parts) > entity.MaxLatencyHistogramEdges {
+			apierror.Write(w, http.StatusBadRequest, fmt.Sprintf("Too many 'edges'. At most %d", entity.MaxLatencyHistogramEdges))
+			return nil, false
+		}
+		for _, part := range parts {
+			edge, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || edge <= 0 || (len(req.Edges) > 0 && edge <= req.Edges[len(req.Edges)-1]) {
+				apierror.Write(w, http.StatusBadRequest, "Invalid 'edges'. Use ascending positive milliseconds (e.g. 100,500,1000)")
+				return nil, false
+			}
+			req.Edges = append(req.Edges, edge)
+		}
+	}
+
+	return req, true
+}
+
+// parseGranularityParams extracts and validates from/to (or preset)/granularity from query params.
 func parseGranularityParams(w http.ResponseWriter, r *http.Request) (*analytics.UsageRequest, bool) {
 	req := &analytics.UsageRequest{}
 
+	period, ok := parsePreset(w, r)
+	if !ok {
+		return nil, false
+	}
+	if period != nil {
+		req.From, req.To = &period.From, &period.To
+	}
+
 	if v := r.URL.Query().Get("from"); v != "" {
 		t, err := parseTime(v)
 		if err != nil {
-			http.Error(w, `{"error":"Invalid 'from' date format. Use RFC3339"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'from' date format. Use RFC3339")
 			return nil, false
 		}
 		req.From = &t
@@ -81,36 +164,57 @@ func parseGranularityParams(w http.ResponseWriter, r *http.Request) (*analytics.
 	if v := r.URL.Query().Get("to"); v != "" {
 		t, err := parseTime(v)
 		if err != nil {
-			http.Error(w, `{"error":"Invalid 'to' date format. Use RFC3339"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'to' date format. Use RFC3339")
 			return nil, false
 		}
 		req.To = &t
 	}
 	if v := r.URL.Query().Get("granularity"); v != "" {
 		if !entity.ValidGranularity(v) {
-			http.Error(w, `{"error":"Invalid 'granularity'. Must be 'hour', 'day', or 'week'"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid 'granularity'. Must be 'hour', 'day', or 'week'")
 			return nil, false
 		}
 		req.Granularity = v
 	}
+	req.IncludeTests = includeTests(r)
 
 	return req, true
 }
 
+// setGranularity reports the granularity a time series was computed at, in
+// the X-Lelemon-Granularity header: coarser than the requested one when the
+// range would exceed the point cap
+func setGranularity(w http.ResponseWriter, granularity string) {
+	w.Header().Set("X-Lelemon-Granularity", granularity)
+}
+
+// includeTests reports whether ?includeTests=true asks to include the traces
+// the project excludes from analytics
+func includeTests(r *http.Request) bool {
+	return r.URL.Query().Get("includeTests") == "true"
+}
+
 func respondJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{"data": data})
+	middleware.WriteJSON(w, map[string]any{"data": data})
 }
 
 // Summary handles GET /api/v1/analytics/summary
 func (h *AnalyticsHandler) Summary(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	req := &analytics.SummaryRequest{}
+	req := &analytics.SummaryRequest{IncludeTests: includeTests(r)}
+	period, ok := parsePreset(w, r)
+	if !ok {
+		return
+	}
+	if period != nil {
+		req.From, req.To = &period.From, &period.To
+	}
 	if v := r.URL.Query().Get("from"); v != "" {
 		if t, err := parseTime(v); err == nil {
 			req.From = &t
@@ -122,21 +226,37 @@ func (h *AnalyticsHandler) Summary(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// ?compare=true adds the previous period's stats and the change from them
+	if r.URL.Query().Get("compare") == "true" && !wantsCSV(r) {
+		comparison, err := h.service.GetSummaryComparison(r.Context(), project.ID, req)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		middleware.WriteJSON(w, comparison)
+		return
+	}
+
 	result, err := h.service.GetSummary(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wantsCSV(r) {
+		respondCSV(w, "summary.csv", result)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // Usage handles GET /api/v1/analytics/usage
 func (h *AnalyticsHandler) Usage(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -147,7 +267,12 @@ func (h *AnalyticsHandler) Usage(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.GetUsage(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	setGranularity(w, h.service.TimeSeriesGranularity(req))
+	if wantsCSV(r) {
+		respondCSV(w, "usage.csv", result)
 		return
 	}
 
@@ -158,7 +283,7 @@ func (h *AnalyticsHandler) Usage(w http.ResponseWriter, r *http.Request) {
 func (h *AnalyticsHandler) Models(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -169,18 +294,23 @@ func (h *AnalyticsHandler) Models(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.GetModelStats(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wantsCSV(r) {
+		respondCSV(w, "models.csv", result)
 		return
 	}
 
 	respondJSON(w, result)
 }
 
-// Tags handles GET /api/v1/analytics/tags
+// Tags handles GET /api/v1/analytics/tags and /api/v1/analytics/cost-by-tag
+// Cost, tokens and traces per trace tag; a trace counts under each of its tags.
 func (h *AnalyticsHandler) Tags(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -191,7 +321,7 @@ func (h *AnalyticsHandler) Tags(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.GetTagStats(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -202,7 +332,7 @@ func (h *AnalyticsHandler) Tags(w http.ResponseWriter, r *http.Request) {
 func (h *AnalyticsHandler) TopUsers(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -213,7 +343,161 @@ func (h *AnalyticsHandler) TopUsers(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.GetTopUsers(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// ToolViolations handles GET /api/v1/analytics/tool-violations
+func (h *AnalyticsHandler) ToolViolations(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetToolViolationStats(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// Evals handles GET /api/v1/analytics/evals
+func (h *AnalyticsHandler) Evals(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetEvalStats(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// CacheEfficiency handles GET /api/v1/analytics/cache-efficiency
+func (h *AnalyticsHandler) CacheEfficiency(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetCacheEfficiency(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// Feedback handles GET /api/v1/analytics/feedback
+func (h *AnalyticsHandler) Feedback(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetFeedbackStats(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// Sessions handles GET /api/v1/analytics/sessions
+func (h *AnalyticsHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetSessionStats(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// UnpricedModels handles GET /api/v1/analytics/unpriced-models
+func (h *AnalyticsHandler) UnpricedModels(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetUnpricedModels(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// Storage handles GET /api/v1/analytics/storage
+func (h *AnalyticsHandler) Storage(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parsePeriodParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetStorageStats(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -224,7 +508,7 @@ func (h *AnalyticsHandler) TopUsers(w http.ResponseWriter, r *http.Request) {
 func (h *AnalyticsHandler) Heatmap(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -235,7 +519,7 @@ func (h *AnalyticsHandler) Heatmap(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.GetHourlyHeatmap(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -246,7 +530,7 @@ func (h *AnalyticsHandler) Heatmap(w http.ResponseWriter, r *http.Request) {
 func (h *AnalyticsHandler) LatencyDistribution(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -257,7 +541,29 @@ func (h *AnalyticsHandler) LatencyDistribution(w http.ResponseWriter, r *http.Re
 
 	result, err := h.service.GetLatencyDistribution(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// LatencyHistogram handles GET /api/v1/analytics/latency-histogram
+func (h *AnalyticsHandler) LatencyHistogram(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, ok := parseLatencyHistogramParams(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetLatencyHistogram(r.Context(), project.ID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -268,7 +574,7 @@ func (h *AnalyticsHandler) LatencyDistribution(w http.ResponseWriter, r *http.Re
 func (h *AnalyticsHandler) LatencyTimeSeries(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -279,9 +585,10 @@ func (h *AnalyticsHandler) LatencyTimeSeries(w http.ResponseWriter, r *http.Requ
 
 	result, err := h.service.GetLatencyTimeSeries(r.Context(), project.ID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
+	setGranularity(w, h.service.TimeSeriesGranularity(req))
 
 	respondJSON(w, result)
 }
@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsDownsampling(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "downsample@example.com", "password": "SecurePass123", "name": "Downsample User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Downsample Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			{"traceId": "downsample", "spanId": "downsample-span", "spanType": "llm", "status": "success", "inputTokens": 10, "durationMs": 100},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	now := time.Now().UTC()
+	query := func(days int, granularity string) string {
+		return "?" + url.Values{
+			"from":        {now.AddDate(0, 0, -days).Format(time.RFC3339)},
+			"to":          {now.Add(time.Hour).Format(time.RFC3339)},
+			"granularity": {granularity},
+		}.Encode()
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	t.Run("90 days hourly downsamples to daily", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/usage"+query(90, "hour"), nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("X-Lelemon-Granularity"); got != "day" {
+			t.Errorf("expected day granularity, got %q", got)
+		}
+		var points struct {
+			Data []struct{ Time time.Time }
+		}
+		ParseJSON(t, resp, &points)
+		if len(points.Data) != 1 || !points.Data[0].Time.Equal(today) {
+			t.Errorf("expected one point at the start of today (%s), got %+v", today, points.Data)
+		}
+	})
+
+	t.Run("7 days hourly keeps the requested granularity", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/usage"+query(7, "hour"), nil, apiKeyHeaders)
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Lelemon-Granularity"); got != "hour" {
+			t.Errorf("expected hour granularity, got %q", got)
+		}
+	})
+
+	t.Run("dashboard usage reports the granularity", func(t *testing.T) {
+		var result struct {
+			Data        []struct{ Time time.Time }
+			Granularity string `json:"granularity"`
+		}
+		ParseJSON(t, ts.Request("GET", "/api/v1/dashboard/projects/"+project.ID+"/usage"+query(90, "hour"), nil, jwtHeaders), &result)
+		if result.Granularity != "day" || len(result.Data) != 1 {
+			t.Errorf("expected one daily point, got %+v", result)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnalyticsExclusion(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "exclusion@example.com", "password": "SecurePass123", "name": "Exclusion User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Exclusion Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	event := func(traceID string, tags []string) map[string]any {
+		return map[string]any{
+			"traceId": traceID, "spanId": traceID + "-span", "spanType": "llm", "status": "success",
+			"tags": tags, "costUsd": 1, "inputTokens": 100,
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			event("exclusion-prod", []string{"feature:checkout"}),
+			event("exclusion-test", []string{"feature:checkout", "test"}),
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	for _, env := range []string{"production", "sandbox"} {
+		resp := ts.Request("POST", "/api/v1/traces", map[string]any{
+			"name": "checkout", "metadata": map[string]any{"environment": env},
+		}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s trace: expected 201, got %d", env, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+
+	summary := func(t *testing.T, path string) StatsResponse {
+		t.Helper()
+		var stats StatsResponse
+		ParseJSON(t, ts.Request("GET", path, nil, apiKeyHeaders), &stats)
+		return stats
+	}
+
+	t.Run("nothing is excluded by default", func(t *testing.T) {
+		if stats := summary(t, "/api/v1/analytics/summary"); stats.TotalTraces != 4 {
+			t.Errorf("expected 4 traces, got %d", stats.TotalTraces)
+		}
+	})
+
+	resp = ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"analyticsExclusion": map[string]any{
+			"tags": []string{"test"}, "environments": []string{"sandbox"},
+		}},
+	}, jwtHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+	}
+
+	t.Run("stats leave out test traces", func(t *testing.T) {
+		stats := summary(t, "/api/v1/analytics/summary")
+		if stats.TotalTraces != 2 {
+			t.Errorf("expected the 2 production traces, got %d", stats.TotalTraces)
+		}
+		if stats.TotalSpans != 1 || stats.TotalCostUSD != 1 {
+			t.Errorf("expected only the production span, got %d spans costing $%v", stats.TotalSpans, stats.TotalCostUSD)
+		}
+
+		var byTag CostByTagResponse
+		ParseJSON(t, ts.Request("GET", "/api/v1/analytics/cost-by-tag", nil, apiKeyHeaders), &byTag)
+		for _, tag := range byTag.Data {
+			if tag.Tag == "test" || tag.Traces != 1 {
+				t.Errorf("expected only the production trace per tag, got %+v", tag)
+			}
+		}
+	})
+
+	t.Run("includeTests overrides the exclusion", func(t *testing.T) {
+		if stats := summary(t, "/api/v1/analytics/summary?includeTests=true"); stats.TotalTraces != 4 {
+			t.Errorf("expected 4 traces, got %d", stats.TotalTraces)
+		}
+	})
+
+	t.Run("trace list still shows test traces", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var page struct {
+			Data []struct {
+				ID string `json:"ID"`
+			} `json:"Data"`
+		}
+		ParseJSON(t, resp, &page)
+		if len(page.Data) != 4 {
+			t.Errorf("expected all 4 traces listed, got %d", len(page.Data))
+		}
+	})
+}
@@ -104,6 +104,65 @@ func TestAnalytics(t *testing.T) {
 			t.Errorf("expected 0 traces for 2020, got %d", stats.TotalTraces)
 		}
 	})
+
+	t.Run("summary compared with the previous period", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/summary?preset=last_24h&compare=true", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var comparison struct {
+			Current  StatsResponse `json:"Current"`
+			Previous StatsResponse `json:"Previous"`
+			Change   struct {
+				TotalSpans *float64 `json:"TotalSpans"`
+			} `json:"Change"`
+		}
+		ParseJSON(t, resp, &comparison)
+
+		if comparison.Current.TotalSpans != 3 || comparison.Previous.TotalSpans != 0 {
+			t.Errorf("expected 3 spans now and 0 the day before, got %d and %d",
+				comparison.Current.TotalSpans, comparison.Previous.TotalSpans)
+		}
+		// Nothing to compare against
+		if comparison.Change.TotalSpans != nil {
+			t.Errorf("expected no change from 0 spans, got %v", *comparison.Change.TotalSpans)
+		}
+	})
+
+	t.Run("analytics with a period preset", func(t *testing.T) {
+		for _, path := range []string{
+			"/api/v1/analytics/summary?preset=last_24h",
+			"/api/v1/analytics/usage?preset=last_7d&tz=America/New_York",
+			"/api/v1/analytics/models?preset=month_to_date&tz=Asia/Kolkata",
+		} {
+			resp := ts.Request("GET", path, nil, apiKeyHeaders)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected status 200, got %d", path, resp.StatusCode)
+			}
+			if path == "/api/v1/analytics/summary?preset=last_24h" {
+				var stats StatsResponse
+				ParseJSON(t, resp, &stats)
+				if stats.TotalSpans != 3 {
+					t.Errorf("expected the spans ingested just now, got %d", stats.TotalSpans)
+				}
+				continue
+			}
+			resp.Body.Close()
+		}
+
+		for _, path := range []string{
+			"/api/v1/analytics/summary?preset=last_year",
+			"/api/v1/analytics/usage?preset=last_7d&from=2020-01-01T00:00:00Z",
+			"/api/v1/analytics/models?preset=last_7d&tz=Mars/Olympus_Mons",
+		} {
+			resp := ts.Request("GET", path, nil, apiKeyHeaders)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", path, resp.StatusCode)
+			}
+		}
+	})
 }
 
 func TestCostCalculation(t *testing.T) {
@@ -195,6 +254,13 @@ func TestHealth(t *testing.T) {
 		if system["go_version"] == "" {
 			t.Error("expected go_version in system info")
 		}
+		ingest, ok := result["ingest"].(map[string]any)
+		if !ok {
+			t.Fatal("expected ingest object in verbose response")
+		}
+		if _, ok := ingest["active_workers"]; !ok {
+			t.Error("expected active_workers in ingest info")
+		}
 	})
 
 	t.Run("liveness probe", func(t *testing.T) {
@@ -213,3 +279,64 @@ func TestHealth(t *testing.T) {
 		}
 	})
 }
+
+func TestAnalyticsDistinctCounts(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "distinct@example.com", "password": "SecurePass123", "name": "Distinct User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Distinct Test Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	llm := func(traceID, userID, model string) map[string]any {
+		return map[string]any{
+			"traceId": traceID, "userId": userID, "spanType": "llm", "provider": "openai",
+			"model": model, "inputTokens": 10, "outputTokens": 5, "status": "success",
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			llm("distinct-1", "alice", "gpt-4o"),
+			llm("distinct-1", "alice", "gpt-4o-mini"),
+			llm("distinct-2", "bob", "gpt-4o"),
+			llm("distinct-3", "alice", "claude-3-5-sonnet-20241022"),
+			// No user, and spans without a model, don't count
+			llm("distinct-4", "", ""),
+			{"traceId": "distinct-4", "spanType": "tool", "name": "search", "status": "success"},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	var stats StatsResponse
+	ParseJSON(t, ts.Request("GET", "/api/v1/analytics/summary", nil, apiKeyHeaders), &stats)
+
+	if stats.TotalTraces != 4 {
+		t.Errorf("expected 4 traces, got %d", stats.TotalTraces)
+	}
+	if stats.DistinctModels != 3 {
+		t.Errorf("expected 3 distinct models, got %d", stats.DistinctModels)
+	}
+	if stats.DistinctUsers != 2 {
+		t.Errorf("expected 2 distinct users, got %d", stats.DistinctUsers)
+	}
+
+	t.Run("empty period counts nothing", func(t *testing.T) {
+		var empty StatsResponse
+		ParseJSON(t, ts.Request("GET", "/api/v1/analytics/summary?from=2020-01-01T00:00:00Z&to=2020-12-31T23:59:59Z", nil, apiKeyHeaders), &empty)
+		if empty.DistinctModels != 0 || empty.DistinctUsers != 0 {
+			t.Errorf("expected zero distinct counts, got %+v", empty)
+		}
+	})
+}
@@ -1,16 +1,15 @@
 package handler
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/mail"
 	"net/url"
 	"strings"
 
 	"github.com/lelemon/server/pkg/application/auth"
-	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -18,6 +17,8 @@ import (
 type AuthHandler struct {
 	service     *auth.Service
 	frontendURL string
+	frontends   FrontendResolver   // nil: OAuth logins land on frontendURL
+	allowlist   *FrontendAllowlist // frontends resolved logins may land on
 }
 
 // NewAuthHandler creates a new auth handler
@@ -28,6 +29,38 @@ func NewAuthHandler(service *auth.Service, frontendURL string) *AuthHandler {
 	}
 }
 
+// SetFrontends lets OAuth logins land on the frontend resolve picks for them
+// (e.g. by organization) when allowlist allows it, instead of on the default
+// frontend. Call it before serving.
+func (h *AuthHandler) SetFrontends(resolve FrontendResolver, allowlist *FrontendAllowlist) {
+	h.frontends = resolve
+	h.allowlist = allowlist
+}
+
+// loginFrontend returns the allowed frontend a login started by r lands on,
+// or "" for the default
+func (h *AuthHandler) loginFrontend(r *http.Request) string {
+	if h.frontends == nil {
+		return ""
+	}
+	frontend := strings.TrimSuffix(h.frontends(r), "/")
+	if frontend == "" || !h.allowlist.Allows(frontend) {
+		return ""
+	}
+	return frontend
+}
+
+// callbackFrontend returns the frontend the login ending with r lands on:
+// the one GoogleAuth kept in the oauth_frontend cookie, checked again since
+// cookies can be tampered with, or the default
+func (h *AuthHandler) callbackFrontend(r *http.Request) string {
+	cookie, err := r.Cookie("oauth_frontend")
+	if err != nil || !h.allowlist.Allows(cookie.Value) {
+		return h.frontendURL
+	}
+	return cookie.Value
+}
+
 // cookieDomain extracts the root domain for cross-subdomain cookies.
 // e.g. "https://lelemon.dev" → ".lelemon.dev" so cookie works on api.lelemon.dev + lelemon.dev
 func cookieDomain(frontendURL string) string {
@@ -74,7 +107,7 @@ func (h *AuthHandler) clearAuthCookie(w http.ResponseWriter) {
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req auth.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
@@ -83,13 +116,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	req.Name = strings.TrimSpace(req.Name)
 
 	if req.Email == "" || req.Password == "" || req.Name == "" {
-		http.Error(w, `{"error":"Email, password and name are required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Email, password and name are required")
 		return
 	}
 
 	// Validate email format
 	if _, err := mail.ParseAddress(req.Email); err != nil {
-		http.Error(w, `{"error":"Invalid email format"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid email format")
 		return
 	}
 
@@ -97,11 +130,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err {
 		case auth.ErrEmailExists:
-			http.Error(w, `{"error":"Email already registered"}`, http.StatusConflict)
+			apierror.Write(w, http.StatusConflict, "Email already registered")
 		case auth.ErrWeakPassword:
-			http.Error(w, `{"error":"Password must be at least 12 characters with uppercase, lowercase, and number"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Password must be at least 12 characters with uppercase, lowercase, and number")
 		default:
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		}
 		return
 	}
@@ -109,14 +142,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	h.setAuthCookie(w, r, result.Token)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // Login handles POST /api/v1/auth/login
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req auth.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
@@ -124,36 +157,40 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 
 	if req.Email == "" || req.Password == "" {
-		http.Error(w, `{"error":"Email and password are required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Email and password are required")
 		return
 	}
 
 	result, err := h.service.Login(r.Context(), &req)
 	if err != nil {
 		if err == auth.ErrInvalidCredentials {
-			http.Error(w, `{"error":"Invalid email or password"}`, http.StatusUnauthorized)
+			apierror.Write(w, http.StatusUnauthorized, "Invalid email or password")
 		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		}
 		return
 	}
 
 	h.setAuthCookie(w, r, result.Token)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GoogleAuth handles GET /api/v1/auth/google
 func (h *AuthHandler) GoogleAuth(w http.ResponseWriter, r *http.Request) {
 	if !h.service.IsOAuthConfigured() {
-		http.Error(w, `{"error":"OAuth not configured"}`, http.StatusNotImplemented)
+		apierror.Write(w, http.StatusNotImplemented, "OAuth not configured")
 		return
 	}
 
-	// Generate state for CSRF protection
-	state := generateState()
+	// Signed, single-use state for CSRF protection, bound to a PKCE verifier
+	authURL, state, err := h.service.BeginGoogleAuth()
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
 
-	// Set state cookie
+	// Set state cookie, so the callback must come from this browser
 	http.SetCookie(w, &http.Cookie{
 		Name:     "oauth_state",
 		Value:    state,
@@ -163,75 +200,89 @@ func (h *AuthHandler) GoogleAuth(w http.ResponseWriter, r *http.Request) {
 		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
 		SameSite: http.SameSiteLaxMode,
 	})
+	if frontend := h.loginFrontend(r); frontend != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "oauth_frontend",
+			Value:    frontend,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
 
 	// Redirect to Google
-	url := h.service.GetGoogleAuthURL(state)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
 // GoogleCallback handles GET /api/v1/auth/google/callback
 func (h *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
+	frontendURL := h.callbackFrontend(r)
+
 	// Verify state
 	state := r.URL.Query().Get("state")
 	cookie, err := r.Cookie("oauth_state")
-	if err != nil || cookie.Value != state {
-		http.Redirect(w, r, h.frontendURL+"/login?error=invalid_state", http.StatusTemporaryRedirect)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Redirect(w, r, frontendURL+"/login?error=invalid_state", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Clear state cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	// Clear state and frontend cookies
+	for _, name := range []string{"oauth_state", "oauth_frontend"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+	}
 
 	// Check for error
 	if errParam := r.URL.Query().Get("error"); errParam != "" {
-		http.Redirect(w, r, h.frontendURL+"/login?error="+url.QueryEscape(errParam), http.StatusTemporaryRedirect)
+		http.Redirect(w, r, frontendURL+"/login?error="+url.QueryEscape(errParam), http.StatusTemporaryRedirect)
 		return
 	}
 
 	// Exchange code
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		http.Redirect(w, r, h.frontendURL+"/login?error=no_code", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, frontendURL+"/login?error=no_code", http.StatusTemporaryRedirect)
 		return
 	}
 
-	result, err := h.service.HandleGoogleCallback(r.Context(), code)
+	result, err := h.service.HandleGoogleCallback(r.Context(), state, code)
+	if errors.Is(err, auth.ErrInvalidOAuthState) {
+		http.Redirect(w, r, frontendURL+"/login?error=invalid_state", http.StatusTemporaryRedirect)
+		return
+	}
 	if err != nil {
-		http.Redirect(w, r, h.frontendURL+"/login?error=auth_failed", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, frontendURL+"/login?error=auth_failed", http.StatusTemporaryRedirect)
 		return
 	}
 
 	// Set session cookie directly and redirect — no intermediate token exchange needed
 	h.setAuthCookie(w, r, result.Token)
-	http.Redirect(w, r, h.frontendURL+"/auth/callback", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, frontendURL+"/auth/callback", http.StatusTemporaryRedirect)
 }
 
 // Me handles GET /api/v1/auth/me
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	result, err := h.service.GetCurrentUser(r.Context(), user.UserID)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"User not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "User not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // ExchangeOAuthToken handles POST /api/v1/auth/oauth/exchange
@@ -239,7 +290,7 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) ExchangeOAuthToken(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("oauth_token")
 	if err != nil || cookie.Value == "" {
-		http.Error(w, `{"error":"No OAuth token found. Please try logging in again."}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "No OAuth token found. Please try logging in again.")
 		return
 	}
 
@@ -253,7 +304,7 @@ func (h *AuthHandler) ExchangeOAuthToken(w http.ResponseWriter, r *http.Request)
 	})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	middleware.WriteJSON(w, map[string]string{
 		"token": cookie.Value,
 	})
 }
@@ -262,30 +313,24 @@ func (h *AuthHandler) ExchangeOAuthToken(w http.ResponseWriter, r *http.Request)
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	h.clearAuthCookie(w)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
-
-func generateState() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+	middleware.WriteJSON(w, map[string]string{"status": "ok"})
 }
 
 // Refresh handles POST /api/v1/auth/refresh
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	result, err := h.service.RefreshToken(r.Context(), user.UserID)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	h.setAuthCookie(w, r, result.Token)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+)
+
+func TestBodySizeLimits(t *testing.T) {
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.MaxBodyBytes = 4 << 10
+		cfg.IngestMaxBodyBytes = 64 << 10
+	})
+	large := strings.Repeat("x", 8<<10) // over the global limit, under the ingest one
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "bodylimit@example.com", "password": "SecurePass123", "name": "Body Limit User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	sessionHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Body Limit Project"}, sessionHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	t.Run("oversized payloads are rejected", func(t *testing.T) {
+		for _, req := range []struct {
+			path    string
+			body    any
+			headers map[string]string
+		}{
+			{"/api/v1/auth/register", map[string]string{"email": "big@example.com", "password": "SecurePass123", "name": large}, nil},
+			{"/api/v1/dashboard/projects", map[string]string{"name": large}, sessionHeaders},
+		} {
+			resp := ts.Request("POST", req.path, req.body, req.headers)
+			var body struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if resp.StatusCode != http.StatusRequestEntityTooLarge {
+				resp.Body.Close()
+				t.Fatalf("%s: expected 413, got %d", req.path, resp.StatusCode)
+			}
+			ParseJSON(t, resp, &body)
+			if body.Error.Code != "validation_failed" {
+				t.Errorf("%s: expected validation_failed, got %q", req.path, body.Error.Code)
+			}
+		}
+	})
+
+	t.Run("ingest takes the higher limit", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{{
+			"spanType": "llm", "name": "big-input", "input": large,
+		}}}, map[string]string{"Authorization": "Bearer " + project.APIKey})
+		var result struct {
+			Success bool `json:"success"`
+		}
+		ParseJSON(t, resp, &result)
+		if !result.Success {
+			t.Errorf("expected the event ingested, got %+v", result)
+		}
+	})
+}
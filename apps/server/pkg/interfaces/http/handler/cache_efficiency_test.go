@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// CacheEfficiencyResponse for parsing the cache efficiency report
+type CacheEfficiencyResponse struct {
+	Data []struct {
+		Model            string  `json:"Model"`
+		Provider         string  `json:"Provider"`
+		Requests         int     `json:"Requests"`
+		InputTokens      int     `json:"InputTokens"`
+		CacheReadTokens  int     `json:"CacheReadTokens"`
+		CacheWriteTokens int     `json:"CacheWriteTokens"`
+		CacheHitRatio    float64 `json:"CacheHitRatio"`
+	} `json:"data"`
+}
+
+func TestCacheEfficiency(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "cache@example.com", "password": "SecurePass123", "name": "Cache User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Cache Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	llmEvent := func(spanID, provider, model string, input, cacheRead, cacheWrite int) map[string]any {
+		return map[string]any{
+			"traceId": "cache-trace", "spanId": spanID, "spanType": "llm",
+			"provider": provider, "model": model, "status": "success",
+			"inputTokens": input, "outputTokens": 100,
+			"cacheReadTokens": cacheRead, "cacheWriteTokens": cacheWrite,
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			// Anthropic reports input excluding cache: prompts of 1000 + 3000 tokens
+			llmEvent("anthropic-1", "anthropic", "claude-3-5-sonnet-20241022", 200, 0, 800),
+			llmEvent("anthropic-2", "anthropic", "claude-3-5-sonnet-20241022", 200, 2800, 0),
+			// OpenAI input already includes cached tokens
+			llmEvent("openai-1", "openai", "gpt-4o", 1000, 250, 0),
+			{"traceId": "cache-trace", "spanId": "tool-1", "spanType": "tool", "name": "search", "status": "success"},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("reports per-model cache hit ratio", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/cache-efficiency", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var report CacheEfficiencyResponse
+		ParseJSON(t, resp, &report)
+
+		if len(report.Data) != 2 {
+			t.Fatalf("expected 2 models, got %+v", report.Data)
+		}
+		anthropic, openai := report.Data[0], report.Data[1]
+		if anthropic.Model != "claude-3-5-sonnet-20241022" || anthropic.Requests != 2 ||
+			anthropic.InputTokens != 4000 || anthropic.CacheReadTokens != 2800 || anthropic.CacheWriteTokens != 800 {
+			t.Errorf("unexpected anthropic entry: %+v", anthropic)
+		}
+		if math.Abs(anthropic.CacheHitRatio-0.7) > 1e-9 {
+			t.Errorf("expected anthropic ratio 0.7, got %v", anthropic.CacheHitRatio)
+		}
+		if openai.Model != "gpt-4o" || openai.Requests != 1 || openai.InputTokens != 1000 || openai.CacheReadTokens != 250 {
+			t.Errorf("unexpected openai entry: %+v", openai)
+		}
+		if math.Abs(openai.CacheHitRatio-0.25) > 1e-9 {
+			t.Errorf("expected openai ratio 0.25, got %v", openai.CacheHitRatio)
+		}
+	})
+
+	t.Run("report requires API key", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/cache-efficiency", nil, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCamelCaseResponses(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "camelcase@example.com", "password": "SecurePass123", "name": "Camel Case User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Camel Case Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	settingsResp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"pricingOverrides": map[string]any{
+			"GPT-4o": map[string]any{"input": 0.001, "output": 0.002},
+		}},
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	settingsResp.Body.Close()
+	if settingsResp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", settingsResp.StatusCode)
+	}
+	v1Headers := map[string]string{"Authorization": "Bearer " + project.APIKey}
+	v2Headers := map[string]string{"Authorization": "Bearer " + project.APIKey, "X-Lelemon-API-Version": "2"}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{{
+		"traceId":      "camel-trace",
+		"spanId":       "camel-span",
+		"spanType":     "llm",
+		"model":        "gpt-4o",
+		"status":       "success",
+		"inputTokens":  10,
+		"outputTokens": 5,
+		"input":        map[string]any{"Prompt": "hi"},
+		"metadata":     map[string]any{"UserID": "u-1", "Nested": map[string]any{"Key": 1}},
+		"modelParams":  map[string]any{"Temperature": 0.2},
+	}}}, v1Headers)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	getTrace := func(t *testing.T, headers map[string]string) map[string]any {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/traces/camel-trace", nil, headers)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var trace map[string]any
+		ParseJSON(t, resp, &trace)
+		return trace
+	}
+	hasKeys := func(t *testing.T, object any, keys ...string) {
+		t.Helper()
+		m, ok := object.(map[string]any)
+		if !ok {
+			t.Fatalf("expected an object, got %v", object)
+		}
+		for _, key := range keys {
+			if _, ok := m[key]; !ok {
+				t.Errorf("expected key %q in %v", key, m)
+			}
+		}
+	}
+
+	t.Run("camelCase keys with version 2", func(t *testing.T) {
+		trace := getTrace(t, v2Headers)
+		hasKeys(t, trace, "id", "spans", "totalSpans", "totalTokens", "totalCostUsd")
+		spans, _ := trace["spans"].([]any)
+		if len(spans) != 1 {
+			t.Fatalf("expected 1 span, got %v", trace["spans"])
+		}
+		hasKeys(t, spans[0], "id", "traceId", "inputTokens", "outputTokens", "durationMs", "metadata", "input")
+
+		// User data keeps its keys
+		span := spans[0].(map[string]any)
+		if want := map[string]any{"Prompt": "hi"}; !reflect.DeepEqual(span["input"], want) {
+			t.Errorf("expected input %v, got %v", want, span["input"])
+		}
+		hasKeys(t, span["metadata"], "UserID", "Nested")
+		hasKeys(t, span["metadata"].(map[string]any)["Nested"], "Key")
+		hasKeys(t, span["modelParams"], "Temperature")
+	})
+
+	t.Run("pricing override model names unchanged", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/projects/me", nil, v2Headers)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var body map[string]any
+		ParseJSON(t, resp, &body)
+		hasKeys(t, body, "settings")
+		hasKeys(t, body["settings"], "pricingOverrides")
+		hasKeys(t, body["settings"].(map[string]any)["pricingOverrides"], "GPT-4o")
+	})
+
+	t.Run("PascalCase keys by default", func(t *testing.T) {
+		trace := getTrace(t, v1Headers)
+		hasKeys(t, trace, "ID", "Spans", "TotalSpans", "TotalCostUSD")
+		if _, ok := trace["spans"]; ok {
+			t.Errorf("expected no camelCase keys without the version header, got %v", trace)
+		}
+	})
+
+	t.Run("bulk delete count camelCased", func(t *testing.T) {
+		resp := ts.Request("DELETE", "/api/v1/dashboard/projects/"+project.ID+"/traces", nil, map[string]string{
+			"Authorization": "Bearer " + auth.Token, "X-Lelemon-API-Version": "2",
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var body map[string]any
+		ParseJSON(t, resp, &body)
+		if body["deleted"] != float64(1) {
+			t.Errorf("expected deleted 1, got %v", body)
+		}
+	})
+
+	t.Run("tagged keys unchanged", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/missing-trace", nil, v2Headers)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", resp.StatusCode)
+		}
+		var body map[string]any
+		ParseJSON(t, resp, &body)
+		hasKeys(t, body, "error")
+		hasKeys(t, body["error"], "code", "message")
+	})
+}
@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// CostByTagResponse for parsing the per-tag rollup
+type CostByTagResponse struct {
+	Data []struct {
+		Tag          string  `json:"Tag"`
+		Traces       int     `json:"Traces"`
+		TotalTokens  int     `json:"TotalTokens"`
+		TotalCostUSD float64 `json:"TotalCostUSD"`
+	} `json:"data"`
+}
+
+func TestCostByTag(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "costbytag@example.com", "password": "SecurePass123", "name": "Cost By Tag User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Cost By Tag Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	event := func(traceID string, tags []string, cost float64, tokens int) map[string]any {
+		return map[string]any{
+			"traceId": traceID, "spanId": traceID + "-span", "spanType": "llm", "status": "success",
+			"tags": tags, "costUsd": cost, "inputTokens": tokens,
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			event("checkout-payments", []string{"feature:checkout", "team:payments"}, 1.5, 300),
+			event("checkout-only", []string{"feature:checkout"}, 0.5, 100),
+			event("untagged", nil, 10, 1000),
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = ts.Request("GET", "/api/v1/analytics/cost-by-tag", nil, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result CostByTagResponse
+	ParseJSON(t, resp, &result)
+
+	if len(result.Data) != 2 {
+		t.Fatalf("expected 2 tags, got %+v", result.Data)
+	}
+	want := map[string]struct {
+		traces, tokens int
+		cost           float64
+	}{
+		"feature:checkout": {2, 400, 2},
+		"team:payments":    {1, 300, 1.5},
+	}
+	for _, got := range result.Data {
+		w, ok := want[got.Tag]
+		if !ok {
+			t.Errorf("unexpected tag %q", got.Tag)
+			continue
+		}
+		if got.Traces != w.traces {
+			t.Errorf("%s: expected %d traces, got %d", got.Tag, w.traces, got.Traces)
+		}
+		if got.TotalTokens != w.tokens {
+			t.Errorf("%s: expected %d tokens, got %d", got.Tag, w.tokens, got.TotalTokens)
+		}
+		if math.Abs(got.TotalCostUSD-w.cost) > 1e-9 {
+			t.Errorf("%s: expected cost %v, got %v", got.Tag, w.cost, got.TotalCostUSD)
+		}
+	}
+	// Sorted by cost, highest first
+	if result.Data[0].Tag != "feature:checkout" {
+		t.Errorf("expected feature:checkout first, got %s", result.Data[0].Tag)
+	}
+}
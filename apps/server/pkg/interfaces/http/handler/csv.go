@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wantsCSV reports whether the client asked for CSV, with ?format=csv or an
+// Accept header listing text/csv
+func wantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// respondCSV writes data, a struct or a slice of structs, as a CSV download:
+// a header row with the field names (the keys of the JSON response), then one
+// row per struct. Fields are formatted as in JSON; nil pointers are empty.
+func respondCSV(w http.ResponseWriter, filename string, data any) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	rows := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		rows = make([]reflect.Value, v.Len())
+		for i := range rows {
+			rows[i] = reflect.Indirect(v.Index(i))
+		}
+	}
+
+	typ := v.Type()
+	if v.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+	}
+	header := make([]string, 0, typ.NumField())
+	for i := range typ.NumField() {
+		if typ.Field(i).IsExported() {
+			header = append(header, typ.Field(i).Name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, name := range header {
+			record[i] = csvValue(row.FieldByName(name))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+// csvValue formats one field for respondCSV
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAnalyticsCSV(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "csv@example.com", "password": "SecurePass123", "name": "CSV User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "CSV Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	headers := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	// A model name that needs quoting in CSV
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		{"traceId": "csv", "spanType": "llm", "provider": "openai", "model": `gpt-4o, "tuned"`, "durationMs": 120,
+			"inputTokens": 100, "outputTokens": 50},
+		{"traceId": "csv", "spanType": "llm", "provider": "openai", "model": "gpt-4o-mini", "durationMs": 80,
+			"inputTokens": 10, "outputTokens": 5},
+	}}, headers)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	// readCSV fetches path as CSV and returns its rows keyed by header
+	readCSV := func(t *testing.T, path string, headers map[string]string) []map[string]string {
+		t.Helper()
+		resp := ts.Request("GET", path, nil, headers)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Fatalf("%s: expected text/csv, got %q", path, ct)
+		}
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: invalid CSV: %v", path, err)
+		}
+		rows := make([]map[string]string, 0, len(records)-1)
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(record))
+			for i, name := range records[0] {
+				row[name] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+	// matchJSON checks that a CSV row holds the same values as a JSON object
+	matchJSON := func(t *testing.T, row map[string]string, object map[string]any) {
+		t.Helper()
+		if len(row) != len(object) {
+			t.Errorf("expected %d columns, got %d: %v", len(object), len(row), row)
+		}
+		for key, value := range object {
+			if number, ok := value.(float64); ok {
+				if got, err := strconv.ParseFloat(row[key], 64); err != nil || got != number {
+					t.Errorf("%s: expected %v, got %q", key, number, row[key])
+				}
+			} else if got, want := row[key], fmt.Sprint(value); got != want {
+				t.Errorf("%s: expected %q, got %q", key, want, got)
+			}
+		}
+	}
+
+	t.Run("models with format=csv", func(t *testing.T) {
+		var models struct{ Data []map[string]any }
+		ParseJSON(t, ts.Request("GET", "/api/v1/analytics/models", nil, headers), &models)
+
+		rows := readCSV(t, "/api/v1/analytics/models?format=csv", headers)
+		if len(rows) != len(models.Data) || len(rows) != 2 {
+			t.Fatalf("expected 2 rows like the JSON, got %d and %d", len(rows), len(models.Data))
+		}
+		for i, row := range rows {
+			matchJSON(t, row, models.Data[i])
+		}
+		if rows[0]["Model"] != `gpt-4o, "tuned"` && rows[1]["Model"] != `gpt-4o, "tuned"` {
+			t.Errorf("expected the quoted model name to round-trip, got %v", rows)
+		}
+	})
+
+	t.Run("summary with Accept header", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/summary", nil, headers)
+		var summary map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+			t.Fatalf("decode summary: %v", err)
+		}
+		resp.Body.Close()
+
+		rows := readCSV(t, "/api/v1/analytics/summary", map[string]string{
+			"Authorization": "Bearer " + project.APIKey, "Accept": "text/csv",
+		})
+		if len(rows) != 1 {
+			t.Fatalf("expected 1 row, got %d", len(rows))
+		}
+		matchJSON(t, rows[0], summary)
+		if rows[0]["TotalSpans"] != "2" {
+			t.Errorf("expected 2 spans, got %q", rows[0]["TotalSpans"])
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var usage struct{ Data []map[string]any }
+		ParseJSON(t, ts.Request("GET", "/api/v1/analytics/usage", nil, headers), &usage)
+
+		rows := readCSV(t, "/api/v1/analytics/usage?format=csv", headers)
+		if len(rows) != len(usage.Data) || len(rows) == 0 {
+			t.Fatalf("expected the JSON's %d rows, got %d", len(usage.Data), len(rows))
+		}
+		last := len(rows) - 1
+		matchJSON(t, rows[last], usage.Data[last])
+	})
+}
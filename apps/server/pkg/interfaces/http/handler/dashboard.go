@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 	"github.com/lelemon/server/pkg/application/project"
 	"github.com/lelemon/server/pkg/application/trace"
 	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -40,13 +42,13 @@ func NewDashboardHandler(
 func (h *DashboardHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -70,124 +72,116 @@ func (h *DashboardHandler) ListProjects(w http.ResponseWriter, r *http.Request)
 			ID:        p.ID,
 			Name:      p.Name,
 			APIKey:    apiKeyPreview,
-			Settings:  p.Settings,
+			Settings:  p.Settings.Redacted(),
 			CreatedAt: p.CreatedAt,
 			UpdatedAt: p.UpdatedAt,
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	middleware.WriteJSON(w, resp)
 }
 
 // CreateProject handles POST /api/v1/dashboard/projects
 func (h *DashboardHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var req project.CreateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, `{"error":"Name is required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Name is required")
 		return
 	}
 
 	result, err := h.projectSvc.Create(r.Context(), user.Email, &req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.FromError(w, err, "Project not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // UpdateProject handles PATCH /api/v1/dashboard/projects/{id}
 func (h *DashboardHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	var req project.UpdateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.projectSvc.Update(r.Context(), projectID, user.Email, &req); err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Project not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	middleware.WriteJSON(w, map[string]bool{"success": true})
 }
 
 // DeleteProject handles DELETE /api/v1/dashboard/projects/{id}
 func (h *DashboardHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	if err := h.projectSvc.Delete(r.Context(), projectID, user.Email); err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Project not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	middleware.WriteJSON(w, map[string]bool{"success": true})
 }
 
 // RotateProjectAPIKey handles POST /api/v1/dashboard/projects/{id}/api-key
 func (h *DashboardHandler) RotateProjectAPIKey(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	// Verify ownership first
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -199,50 +193,50 @@ func (h *DashboardHandler) RotateProjectAPIKey(w http.ResponseWriter, r *http.Re
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
 	result, err := h.projectSvc.RotateAPIKey(r.Context(), projectID)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GetTraces handles GET /api/v1/dashboard/projects/{id}/traces
 func (h *DashboardHandler) GetTraces(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	found := false
-	for _, p := range projects {
-		if p.ID == projectID {
-			found = true
+	var project *entity.Project
+	for i := range projects {
+		if projects[i].ID == projectID {
+			project = &projects[i]
 			break
 		}
 	}
-	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+	if project == nil {
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
@@ -284,22 +278,37 @@ func (h *DashboardHandler) GetTraces(w http.ResponseWriter, r *http.Request) {
 			filter.To = &t
 		}
 	}
+	if v := r.URL.Query().Get("minInactiveMs"); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms >= 0 {
+			filter.MinInactiveMs = &ms
+		}
+	}
+	if v := r.URL.Query().Get("tool"); v != "" {
+		filter.Tool = &v
+	}
+	if v := r.URL.Query().Get("minDepth"); v != "" {
+		if depth, err := strconv.Atoi(v); err == nil && depth >= 1 {
+			depth = min(depth, entity.MaxFilterDepth)
+			filter.MinDepth = &depth
+		}
+	}
 
 	result, err := h.traceSvc.List(r.Context(), projectID, filter)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
+	projectListMetadata(result.Data, project.Settings.ListMetadataKeys)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GetTrace handles GET /api/v1/dashboard/projects/{id}/traces/{traceId}
 func (h *DashboardHandler) GetTrace(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -309,7 +318,7 @@ func (h *DashboardHandler) GetTrace(w http.ResponseWriter, r *http.Request) {
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -321,42 +330,38 @@ func (h *DashboardHandler) GetTrace(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
 	result, err := h.traceSvc.GetDetail(r.Context(), projectID, traceID)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Trace not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Trace not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GetSessions handles GET /api/v1/dashboard/projects/{id}/sessions
 func (h *DashboardHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -368,7 +373,7 @@ func (h *DashboardHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
@@ -388,12 +393,12 @@ func (h *DashboardHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
 	result, err := h.traceSvc.ListSessions(r.Context(), projectID, filter)
 	slog.Info("ListSessions called", "projectID", projectID, "err", err)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 
@@ -401,7 +406,7 @@ func (h *DashboardHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
 func (h *DashboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -410,7 +415,7 @@ func (h *DashboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -422,11 +427,11 @@ func (h *DashboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
-	req := &analytics.SummaryRequest{}
+	req := &analytics.SummaryRequest{IncludeTests: includeTests(r)}
 	if v := r.URL.Query().Get("from"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
 			req.From = &t
@@ -438,21 +443,37 @@ func (h *DashboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// ?compare=true adds the previous period's stats and the change from them
+	if r.URL.Query().Get("compare") == "true" && !wantsCSV(r) {
+		comparison, err := h.analyticsSvc.GetSummaryComparison(r.Context(), projectID, req)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		middleware.WriteJSON(w, comparison)
+		return
+	}
+
 	result, err := h.analyticsSvc.GetSummary(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wantsCSV(r) {
+		respondCSV(w, "summary.csv", result)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GetUsage handles GET /api/v1/dashboard/projects/{id}/usage
 func (h *DashboardHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -461,7 +482,7 @@ func (h *DashboardHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -473,11 +494,11 @@ func (h *DashboardHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
-	req := &analytics.UsageRequest{}
+	req := &analytics.UsageRequest{IncludeTests: includeTests(r)}
 	if v := r.URL.Query().Get("from"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
 			req.From = &t
@@ -494,13 +515,20 @@ func (h *DashboardHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.analyticsSvc.GetUsage(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	granularity := h.analyticsSvc.TimeSeriesGranularity(req)
+	setGranularity(w, granularity)
+	if wantsCSV(r) {
+		respondCSV(w, "usage.csv", result)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"data": result,
+	middleware.WriteJSON(w, map[string]any{
+		"data":        result,
+		"granularity": granularity,
 	})
 }
 
@@ -508,20 +536,20 @@ func (h *DashboardHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 func (h *DashboardHandler) DeleteAllTraces(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, `{"error":"Project ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Project ID required")
 		return
 	}
 
 	// Verify ownership
 	projects, err := h.projectSvc.List(r.Context(), user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -533,39 +561,77 @@ func (h *DashboardHandler) DeleteAllTraces(w http.ResponseWriter, r *http.Reques
 		}
 	}
 	if !found {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return
 	}
 
 	deleted, err := h.traceSvc.DeleteAll(r.Context(), projectID)
 	if err != nil {
 		slog.Error("Failed to delete traces", "projectID", projectID, "error", err)
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	slog.Info("Deleted all traces", "projectID", projectID, "deleted", deleted)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int64{"Deleted": deleted})
+	middleware.WriteJSON(w, deletedResponse{Deleted: deleted})
+}
+
+// RecostSpans handles POST /api/v1/dashboard/projects/{id}/recost
+// Re-prices the project's LLM spans with the current pricing table and the
+// project's pricing overrides. The body ({"from","to"}) is optional; an empty
+// body re-prices the last 30 days.
+func (h *DashboardHandler) RecostSpans(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := h.verifyProjectOwnership(w, r)
+	if !ok {
+		return
+	}
+
+	var req trace.RecostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.traceSvc.Recost(r.Context(), projectID, &req)
+	if err == entity.ErrBadRequest {
+		apierror.Write(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to recost spans", "projectID", projectID, "error", err)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	slog.Info("Recosted spans", "projectID", projectID, "scanned", result.Scanned, "updated", result.Updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, result)
 }
 
 // verifyProjectOwnership checks the user owns the project. Returns projectID or writes error.
 func (h *DashboardHandler) verifyProjectOwnership(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return verifyProjectOwner(w, r, h.projectSvc)
+}
+
+// verifyProjectOwner checks the session user owns the {id} project. Returns projectID or writes error.
+func verifyProjectOwner(w http.ResponseWriter, r *http.Request, projectSvc *project.Service) (string, bool) {
 	user := middleware.GetUser(r.Context())
 	if user == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return "", false
 	}
 
 	projectID := chi.URLParam(r, "id")
-	owned, err := h.projectSvc.IsOwner(r.Context(), projectID, user.Email)
+	owned, err := projectSvc.IsOwner(r.Context(), projectID, user.Email)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return "", false
 	}
 	if !owned {
-		http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
+		apierror.Write(w, http.StatusNotFound, "Project not found")
 		return "", false
 	}
 
@@ -574,7 +640,7 @@ func (h *DashboardHandler) verifyProjectOwnership(w http.ResponseWriter, r *http
 
 func dashboardRespondJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{"data": data})
+	middleware.WriteJSON(w, map[string]any{"data": data})
 }
 
 // GetModelStats handles GET /api/v1/dashboard/projects/{id}/analytics/models
@@ -589,7 +655,11 @@ func (h *DashboardHandler) GetModelStats(w http.ResponseWriter, r *http.Request)
 	}
 	result, err := h.analyticsSvc.GetModelStats(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wantsCSV(r) {
+		respondCSV(w, "models.csv", result)
 		return
 	}
 	dashboardRespondJSON(w, result)
@@ -607,7 +677,7 @@ func (h *DashboardHandler) GetTagStats(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := h.analyticsSvc.GetTagStats(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 	dashboardRespondJSON(w, result)
@@ -625,7 +695,7 @@ func (h *DashboardHandler) GetTopUsers(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := h.analyticsSvc.GetTopUsers(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 	dashboardRespondJSON(w, result)
@@ -643,7 +713,7 @@ func (h *DashboardHandler) GetHeatmap(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := h.analyticsSvc.GetHourlyHeatmap(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 	dashboardRespondJSON(w, result)
@@ -661,7 +731,25 @@ func (h *DashboardHandler) GetLatencyDistribution(w http.ResponseWriter, r *http
 	}
 	result, err := h.analyticsSvc.GetLatencyDistribution(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	dashboardRespondJSON(w, result)
+}
+
+// GetLatencyHistogram handles GET /api/v1/dashboard/projects/{id}/analytics/latency-histogram
+func (h *DashboardHandler) GetLatencyHistogram(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := h.verifyProjectOwnership(w, r)
+	if !ok {
+		return
+	}
+	req, ok := parseLatencyHistogramParams(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.analyticsSvc.GetLatencyHistogram(r.Context(), projectID, req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 	dashboardRespondJSON(w, result)
@@ -674,7 +762,7 @@ func (h *DashboardHandler) GetLatencyTimeSeries(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	req := &analytics.UsageRequest{}
+	req := &analytics.UsageRequest{IncludeTests: includeTests(r)}
 	if v := r.URL.Query().Get("from"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
 			req.From = &t
@@ -687,7 +775,7 @@ func (h *DashboardHandler) GetLatencyTimeSeries(w http.ResponseWriter, r *http.R
 	}
 	if v := r.URL.Query().Get("granularity"); v != "" {
 		if !entity.ValidGranularity(v) {
-			http.Error(w, `{"error":"Invalid granularity"}`, http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, "Invalid granularity")
 			return
 		}
 		req.Granularity = v
@@ -695,8 +783,9 @@ func (h *DashboardHandler) GetLatencyTimeSeries(w http.ResponseWriter, r *http.R
 
 	result, err := h.analyticsSvc.GetLatencyTimeSeries(r.Context(), projectID, req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
+	setGranularity(w, h.analyticsSvc.TimeSeriesGranularity(req))
 	dashboardRespondJSON(w, result)
 }
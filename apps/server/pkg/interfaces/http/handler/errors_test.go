@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "envelope@example.com", "password": "SecurePass123", "name": "Envelope User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Envelope Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	assertEnvelope := func(t *testing.T, resp *http.Response, status int, code apierror.Code) apierror.Envelope {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Errorf("expected %d, got %d", status, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected Content-Type application/json, got %q", ct)
+		}
+		var envelope apierror.Envelope
+		ParseJSON(t, resp, &envelope)
+		if envelope.Error.Code != code {
+			t.Errorf("expected code %q, got %q", code, envelope.Error.Code)
+		}
+		if envelope.Error.Message == "" {
+			t.Error("expected an error message")
+		}
+		return envelope
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/no-such-trace", nil, apiKeyHeaders)
+		envelope := assertEnvelope(t, resp, http.StatusNotFound, apierror.CodeNotFound)
+		if envelope.Error.Message != "Trace not found" {
+			t.Errorf("expected message %q, got %q", "Trace not found", envelope.Error.Message)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/no-such-trace", nil, nil)
+		assertEnvelope(t, resp, http.StatusUnauthorized, apierror.CodeUnauthorized)
+	})
+
+	t.Run("validation failed", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest", "not an object", apiKeyHeaders)
+		assertEnvelope(t, resp, http.StatusBadRequest, apierror.CodeValidationFailed)
+	})
+
+	t.Run("unknown route and method", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/no-such-route", nil, nil)
+		assertEnvelope(t, resp, http.StatusNotFound, apierror.CodeNotFound)
+
+		resp = ts.Request("PATCH", "/api/v1/features", nil, nil)
+		assertEnvelope(t, resp, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		// The auth limiter allows 10 requests per minute per IP
+		var resp *http.Response
+		for i := 0; i < 11; i++ {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			resp = ts.Request("POST", "/api/v1/auth/login", map[string]string{
+				"email": "envelope@example.com", "password": "WrongPass123",
+			}, nil)
+		}
+		retryAfter := resp.Header.Get("Retry-After")
+		if retryAfter == "" {
+			t.Error("expected a Retry-After header")
+		}
+		envelope := assertEnvelope(t, resp, http.StatusTooManyRequests, apierror.CodeRateLimited)
+		// A token refills every 6s, less the time the requests took
+		details, ok := envelope.Error.Details.(map[string]any)
+		seconds, _ := details["retryAfter"].(float64)
+		if !ok || seconds < 1 || seconds > 6 || retryAfter != strconv.Itoa(int(seconds)) {
+			t.Errorf("expected retryAfter of 1-6s matching Retry-After %q in details, got %v", retryAfter, envelope.Error.Details)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestEvalSpans(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "evals@example.com", "password": "SecurePass123", "name": "Evals User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Evals Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			{"traceId": "evals-1", "spanId": "evals-1-answer", "spanType": "llm", "model": "gpt-4o", "status": "success", "output": "Paris"},
+			{"traceId": "evals-1", "spanId": "evals-1-judge", "parentSpanId": "evals-1-answer", "spanType": "eval", "name": "correctness", "status": "success",
+				"output": map[string]any{"score": 1, "label": "pass", "rationale": "matches the reference"}},
+			{"traceId": "evals-2", "spanId": "evals-2-judge", "spanType": "eval", "name": "correctness", "status": "success",
+				"output": `{"score": 0.5, "label": "PASS"}`},
+			{"traceId": "evals-3", "spanId": "evals-3-judge", "spanType": "eval", "name": "correctness", "status": "success",
+				"output": map[string]any{"passed": false, "reasoning": "wrong capital"}},
+			{"traceId": "evals-4", "spanId": "evals-4-judge", "spanType": "eval", "name": "tone", "status": "success",
+				"output": "polite enough"}, // no verdict
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("verdict is extracted into the span", func(t *testing.T) {
+		var trace struct {
+			Spans []struct {
+				ID       string
+				Type     string
+				Metadata map[string]any
+			}
+		}
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/evals-1", nil, apiKeyHeaders), &trace)
+		want := map[string]any{"score": 1.0, "label": "pass", "rationale": "matches the reference"}
+		found := false
+		for _, span := range trace.Spans {
+			if span.ID != "evals-1-judge" {
+				continue
+			}
+			found = true
+			if span.Type != "eval" {
+				t.Errorf("expected eval span type, got %q", span.Type)
+			}
+			if got := span.Metadata["eval_verdict"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected verdict %v, got %v", want, got)
+			}
+		}
+		if !found {
+			t.Fatal("expected the judge span in the trace")
+		}
+	})
+
+	t.Run("verdicts are aggregated by evaluator and label", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/evals", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		type evalStats struct {
+			Name     string
+			Label    string
+			Verdicts int
+			Scored   int
+			AvgScore *float64
+		}
+		var result struct{ Data []evalStats }
+		ParseJSON(t, resp, &result)
+		avg := 0.75
+		want := []evalStats{
+			{Name: "correctness", Label: "pass", Verdicts: 2, Scored: 2, AvgScore: &avg},
+			{Name: "correctness", Label: "fail", Verdicts: 1},
+		}
+		if !reflect.DeepEqual(result.Data, want) {
+			t.Errorf("expected %+v, got %+v", want, result.Data)
+		}
+	})
+}
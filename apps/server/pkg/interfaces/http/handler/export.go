@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/lelemon/server/pkg/application/export"
+	"github.com/lelemon/server/pkg/application/project"
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// ExportHandler handles project exports to object storage (session auth)
+type ExportHandler struct {
+	projectSvc *project.Service
+	exportSvc  *export.Service
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(projectSvc *project.Service, exportSvc *export.Service) *ExportHandler {
+	return &ExportHandler{
+		projectSvc: projectSvc,
+		exportSvc:  exportSvc,
+	}
+}
+
+// Start handles POST /api/v1/projects/{id}/export-to-s3
+func (h *ExportHandler) Start(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := verifyProjectOwner(w, r, h.projectSvc)
+	if !ok {
+		return
+	}
+
+	job, err := h.exportSvc.Start(projectID)
+	if errors.Is(err, entity.ErrConflict) {
+		apierror.Write(w, http.StatusConflict, "An export of this project is already running")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to start export", "projectID", projectID, "error", err)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	middleware.WriteJSON(w, job)
+}
+
+// Get handles GET /api/v1/projects/{id}/exports/{jobId}
+func (h *ExportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := verifyProjectOwner(w, r, h.projectSvc)
+	if !ok {
+		return
+	}
+
+	job, err := h.exportSvc.Get(projectID, chi.URLParam(r, "jobId"))
+	if err != nil {
+		apierror.Write(w, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, job)
+}
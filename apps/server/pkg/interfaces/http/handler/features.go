@@ -1,8 +1,10 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
 // FeaturesConfig defines what features are available in this server instance.
@@ -44,5 +46,17 @@ func DefaultFeaturesConfig() *FeaturesConfig {
 // GET /api/v1/features
 func (h *FeaturesHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.config)
+	middleware.WriteJSON(w, h.config)
+}
+
+// SpanTypesHandler returns the span types ingest recognizes, so SDKs can
+// validate spanType client-side. Unrecognized types are ingested as "llm"
+// unless the project enables strictSpanTypes.
+// GET /api/v1/span-types
+func SpanTypesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, map[string]any{
+		"spanTypes": entity.SpanTypes(),
+		"default":   entity.SpanTypeLLM,
+	})
 }
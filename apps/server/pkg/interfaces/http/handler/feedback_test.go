@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// FeedbackStatsResponse for parsing the feedback analytics
+type FeedbackStatsResponse struct {
+	Data struct {
+		Total        int     `json:"Total"`
+		Positive     int     `json:"Positive"`
+		Neutral      int     `json:"Neutral"`
+		Negative     int     `json:"Negative"`
+		PositiveRate float64 `json:"PositiveRate"`
+	} `json:"data"`
+}
+
+func TestTraceFeedback(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "feedback@example.com", "password": "SecurePass123", "name": "Feedback User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Feedback Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	createTrace := func(t *testing.T) string {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/traces", map[string]any{"name": "chat"}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create trace: expected 201, got %d", resp.StatusCode)
+		}
+		var trace struct {
+			ID string `json:"ID"`
+		}
+		ParseJSON(t, resp, &trace)
+		return trace.ID
+	}
+
+	traceID := createTrace(t)
+
+	t.Run("records feedback", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/"+traceID+"/feedback", map[string]any{
+			"value": 1, "comment": "great answer",
+		}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		var score struct {
+			TraceID string  `json:"TraceID"`
+			Name    string  `json:"Name"`
+			Value   float64 `json:"Value"`
+			Source  string  `json:"Source"`
+			Comment *string `json:"Comment"`
+		}
+		ParseJSON(t, resp, &score)
+		if score.TraceID != traceID || score.Name != "feedback" || score.Value != 1 || score.Source != "user" {
+			t.Errorf("unexpected score: %+v", score)
+		}
+		if score.Comment == nil || *score.Comment != "great answer" {
+			t.Errorf("expected comment to be stored, got %v", score.Comment)
+		}
+	})
+
+	t.Run("rejects values outside -1..1", func(t *testing.T) {
+		for _, body := range []map[string]any{{"value": 5}, {"value": -2}, {"comment": "no value"}} {
+			resp := ts.Request("POST", "/api/v1/traces/"+traceID+"/feedback", body, apiKeyHeaders)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%v: expected 400, got %d", body, resp.StatusCode)
+			}
+			resp.Body.Close()
+		}
+	})
+
+	t.Run("unknown trace returns 404", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/00000000-0000-0000-0000-000000000000/feedback",
+			map[string]any{"value": 1}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("requires an API key", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/"+traceID+"/feedback", map[string]any{"value": 1}, nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("analytics reports the positive rate", func(t *testing.T) {
+		// Together with the first subtest: 2 positive, 1 neutral, 1 negative
+		for _, value := range []int{1, 0, -1} {
+			resp := ts.Request("POST", "/api/v1/traces/"+createTrace(t)+"/feedback",
+				map[string]any{"value": value}, apiKeyHeaders)
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("value %d: expected 201, got %d", value, resp.StatusCode)
+			}
+			resp.Body.Close()
+		}
+
+		resp := ts.Request("GET", "/api/v1/analytics/feedback", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var stats FeedbackStatsResponse
+		ParseJSON(t, resp, &stats)
+
+		if stats.Data.Total != 4 || stats.Data.Positive != 2 || stats.Data.Neutral != 1 || stats.Data.Negative != 1 {
+			t.Errorf("unexpected counts: %+v", stats.Data)
+		}
+		if math.Abs(stats.Data.PositiveRate-50) > 1e-9 {
+			t.Errorf("expected positive rate 50, got %v", stats.Data.PositiveRate)
+		}
+	})
+}
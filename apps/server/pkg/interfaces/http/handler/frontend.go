@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FrontendResolver returns the frontend an OAuth login started by r should
+// land on, e.g. the branded dashboard an organization has set up (enterprise
+// edition); "" lands on the default FrontendURL. The URL is only used when the
+// frontend allowlist allows it, so resolvers may pass request input through.
+type FrontendResolver func(r *http.Request) string
+
+// FrontendAllowlist holds the origins (scheme://host[:port]) of the
+// frontends logins may land on; a "*." host prefix allows any subdomain, e.g.
+// https://*.lelemon.dev
+type FrontendAllowlist struct {
+	origins []*url.URL
+}
+
+// NewFrontendAllowlist parses the allowed frontend origins. Paths are
+// ignored: a URL allows its whole origin.
+func NewFrontendAllowlist(origins ...string) (*FrontendAllowlist, error) {
+	allowlist := &FrontendAllowlist{}
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid frontend origin %q (scheme://host[:port])", origin)
+		}
+		allowlist.origins = append(allowlist.origins, u)
+	}
+	return allowlist, nil
+}
+
+// Allows reports whether frontend, a URL with an optional path prefix (e.g.
+// https://acme.lelemon.dev/app), has an allowed origin. Nil allows none.
+func (a *FrontendAllowlist) Allows(frontend string) bool {
+	if a == nil {
+		return false
+	}
+	u, err := url.Parse(frontend)
+	if err != nil || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	for _, origin := range a.origins {
+		if u.Scheme != origin.Scheme || u.Port() != origin.Port() {
+			continue
+		}
+		host := origin.Hostname()
+		if suffix, ok := strings.CutPrefix(host, "*."); ok {
+			if strings.HasSuffix(u.Hostname(), "."+suffix) {
+				return true
+			}
+		} else if u.Hostname() == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package handler_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	appauth "github.com/lelemon/server/pkg/application/auth"
+	"github.com/lelemon/server/pkg/infrastructure/auth"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/handler"
+)
+
+// stubGoogle is a fake Google token and userinfo endpoint. Codes are
+// single-use and only redeemed with the verifier matching their challenge.
+type stubGoogle struct {
+	*httptest.Server
+	mu         sync.Mutex
+	challenges map[string]string // code -> PKCE challenge
+}
+
+func newStubGoogle(t *testing.T) *stubGoogle {
+	stub := &stubGoogle{challenges: make(map[string]string)}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+			stub.mu.Lock()
+			challenge, ok := stub.challenges[r.PostForm.Get("code")]
+			delete(stub.challenges, r.PostForm.Get("code"))
+			stub.mu.Unlock()
+
+			if !ok || challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"google-token","token_type":"Bearer","expires_in":3600}`)
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer google-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"id":"g-123","email":"google@example.com","verified_email":true,"name":"Google User"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// authorize plays the user consenting at Google: it issues code for the
+// challenge in authURL
+func (s *stubGoogle) authorize(t *testing.T, authURL, code string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("bad auth URL %q: %v", authURL, err)
+	}
+	if u.Query().Get("code_challenge_method") != "S256" || u.Query().Get("code_challenge") == "" {
+		t.Fatalf("expected a PKCE S256 challenge, got %s", authURL)
+	}
+	s.mu.Lock()
+	s.challenges[code] = u.Query().Get("code_challenge")
+	s.mu.Unlock()
+}
+
+func TestGoogleOAuthStateAndPKCE(t *testing.T) {
+	google := newStubGoogle(t)
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		oauth := auth.NewOAuthService("client-id", "client-secret", "http://localhost/api/v1/auth/google/callback")
+		oauth.SetEndpoint(google.URL+"/authorize", google.URL+"/token", google.URL+"/userinfo")
+		cfg.AuthSvc = appauth.NewService(cfg.PrimaryStore, cfg.JWTService, oauth)
+	})
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	get := func(t *testing.T, path string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	// begin starts a login and returns Google's URL and the state cookie
+	begin := func(t *testing.T) (string, *http.Cookie) {
+		t.Helper()
+		resp := get(t, "/api/v1/auth/google", nil)
+		if resp.StatusCode != http.StatusTemporaryRedirect {
+			t.Fatalf("expected a redirect to Google, got %d", resp.StatusCode)
+		}
+		for _, c := range resp.Cookies() {
+			if c.Name == "oauth_state" {
+				return resp.Header.Get("Location"), c
+			}
+		}
+		t.Fatal("expected an oauth_state cookie")
+		return "", nil
+	}
+	callback := func(t *testing.T, state, code string, cookie *http.Cookie) string {
+		t.Helper()
+		resp := get(t, "/api/v1/auth/google/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), cookie)
+		return resp.Header.Get("Location")
+	}
+
+	t.Run("completes a login with PKCE", func(t *testing.T) {
+		authURL, cookie := begin(t)
+		google.authorize(t, authURL, "code-ok")
+		if loc := callback(t, cookie.Value, "code-ok", cookie); loc != "http://localhost:3000/auth/callback" {
+			t.Fatalf("expected a successful login, redirected to %q", loc)
+		}
+	})
+
+	t.Run("rejects a replayed callback", func(t *testing.T) {
+		authURL, cookie := begin(t)
+		google.authorize(t, authURL, "code-replay")
+		if loc := callback(t, cookie.Value, "code-replay", cookie); !strings.HasSuffix(loc, "/auth/callback") {
+			t.Fatalf("expected the first callback to log in, redirected to %q", loc)
+		}
+		if loc := callback(t, cookie.Value, "code-replay", cookie); !strings.Contains(loc, "error=invalid_state") {
+			t.Errorf("expected the replay to fail on its used state, redirected to %q", loc)
+		}
+	})
+
+	t.Run("rejects a tampered state", func(t *testing.T) {
+		authURL, cookie := begin(t)
+		google.authorize(t, authURL, "code-tampered")
+		nonce, _, _ := strings.Cut(cookie.Value, ".")
+		forged := nonce + ".forged-signature"
+		if loc := callback(t, forged, "code-tampered", &http.Cookie{Name: "oauth_state", Value: forged}); !strings.Contains(loc, "error=invalid_state") {
+			t.Errorf("expected a tampered state to fail, redirected to %q", loc)
+		}
+	})
+
+	t.Run("rejects a state the server did not issue", func(t *testing.T) {
+		state := "made-up.state"
+		if loc := callback(t, state, "code-unknown", &http.Cookie{Name: "oauth_state", Value: state}); !strings.Contains(loc, "error=invalid_state") {
+			t.Errorf("expected an unknown state to fail, redirected to %q", loc)
+		}
+	})
+
+	t.Run("rejects a missing state", func(t *testing.T) {
+		if loc := callback(t, "", "code-missing", &http.Cookie{Name: "oauth_state", Value: ""}); !strings.Contains(loc, "error=invalid_state") {
+			t.Errorf("expected a missing state to fail, redirected to %q", loc)
+		}
+	})
+
+	t.Run("rejects a state from another browser", func(t *testing.T) {
+		authURL, cookie := begin(t)
+		google.authorize(t, authURL, "code-csrf")
+		if loc := callback(t, cookie.Value, "code-csrf", nil); !strings.Contains(loc, "error=invalid_state") {
+			t.Errorf("expected a callback without the state cookie to fail, redirected to %q", loc)
+		}
+	})
+
+	// Last: it uses up the IP's login starts
+	t.Run("rate limits login starts by IP", func(t *testing.T) {
+		var status int
+		for i := 0; i < 20 && status != http.StatusTooManyRequests; i++ {
+			status = get(t, "/api/v1/auth/google", nil).StatusCode
+		}
+		if status != http.StatusTooManyRequests {
+			t.Errorf("expected login starts to be rate limited, got %d", status)
+		}
+	})
+}
+
+func TestGoogleOAuthFrontends(t *testing.T) {
+	google := newStubGoogle(t)
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		oauth := auth.NewOAuthService("client-id", "client-secret", "http://localhost/api/v1/auth/google/callback")
+		oauth.SetEndpoint(google.URL+"/authorize", google.URL+"/token", google.URL+"/userinfo")
+		cfg.AuthSvc = appauth.NewService(cfg.PrimaryStore, cfg.JWTService, oauth)
+
+		// rogue resolves to a frontend outside the allowlist
+		allowlist, err := handler.NewFrontendAllowlist("https://*.lelemon.dev")
+		if err != nil {
+			t.Fatalf("failed to build allowlist: %v", err)
+		}
+		frontends := map[string]string{
+			"acme":  "https://acme.lelemon.dev/",
+			"rogue": "https://evil.example",
+		}
+		cfg.FrontendResolver = func(r *http.Request) string {
+			return frontends[r.URL.Query().Get("org")]
+		}
+		cfg.FrontendAllowlist = allowlist
+	})
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	get := func(t *testing.T, path string, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	// login runs a login started with query and returns where it lands
+	login := func(t *testing.T, query, code string, tamper func([]*http.Cookie) []*http.Cookie) string {
+		t.Helper()
+		resp := get(t, "/api/v1/auth/google"+query)
+		if resp.StatusCode != http.StatusTemporaryRedirect {
+			t.Fatalf("expected a redirect to Google, got %d", resp.StatusCode)
+		}
+		google.authorize(t, resp.Header.Get("Location"), code)
+		cookies := resp.Cookies()
+		if tamper != nil {
+			cookies = tamper(cookies)
+		}
+		var state string
+		for _, c := range cookies {
+			if c.Name == "oauth_state" {
+				state = c.Value
+			}
+		}
+		resp = get(t, "/api/v1/auth/google/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), cookies...)
+		return resp.Header.Get("Location")
+	}
+
+	t.Run("lands on an allowed org frontend", func(t *testing.T) {
+		if loc := login(t, "?org=acme", "code-acme", nil); loc != "https://acme.lelemon.dev/auth/callback" {
+			t.Errorf("expected the acme frontend, redirected to %q", loc)
+		}
+	})
+
+	t.Run("lands on the default frontend for a disallowed target", func(t *testing.T) {
+		if loc := login(t, "?org=rogue", "code-rogue", nil); loc != "http://localhost:3000/auth/callback" {
+			t.Errorf("expected the default frontend, redirected to %q", loc)
+		}
+	})
+
+	t.Run("lands on the default frontend without an org", func(t *testing.T) {
+		if loc := login(t, "", "code-default", nil); loc != "http://localhost:3000/auth/callback" {
+			t.Errorf("expected the default frontend, redirected to %q", loc)
+		}
+	})
+
+	t.Run("ignores a tampered frontend cookie", func(t *testing.T) {
+		tamper := func(cookies []*http.Cookie) []*http.Cookie {
+			return append(cookies, &http.Cookie{Name: "oauth_frontend", Value: "https://evil.example"})
+		}
+		if loc := login(t, "", "code-tampered-frontend", tamper); loc != "http://localhost:3000/auth/callback" {
+			t.Errorf("expected the default frontend, redirected to %q", loc)
+		}
+	})
+}
+
+func TestFrontendAllowlist(t *testing.T) {
+	allowlist, err := handler.NewFrontendAllowlist("https://app.lelemon.dev", "https://*.tenants.lelemon.dev", "http://localhost:3000/dashboard")
+	if err != nil {
+		t.Fatalf("failed to build allowlist: %v", err)
+	}
+	tests := []struct {
+		frontend string
+		allowed  bool
+	}{
+		{"https://app.lelemon.dev", true},
+		{"https://app.lelemon.dev/console", true},
+		{"https://acme.tenants.lelemon.dev", true},
+		{"http://localhost:3000", true},
+		{"http://app.lelemon.dev", false},           // scheme
+		{"https://app.lelemon.dev:8443", false},     // port
+		{"https://tenants.lelemon.dev", false},      // the wildcard needs a subdomain
+		{"https://app.lelemon.dev.evil.com", false}, // suffix trick
+		{"https://evil.com@app.lelemon.dev", false}, // userinfo
+		{"https://app.lelemon.dev?next=x", false},   // query
+		{"//app.lelemon.dev", false},                // no scheme
+	}
+	for _, tt := range tests {
+		if got := allowlist.Allows(tt.frontend); got != tt.allowed {
+			t.Errorf("Allows(%q) = %v, want %v", tt.frontend, got, tt.allowed)
+		}
+	}
+
+	if _, err := handler.NewFrontendAllowlist("lelemon.dev"); err == nil {
+		t.Error("expected an origin without a scheme to be rejected")
+	}
+	var none *handler.FrontendAllowlist
+	if none.Allows("https://app.lelemon.dev") {
+		t.Error("expected a nil allowlist to allow nothing")
+	}
+}
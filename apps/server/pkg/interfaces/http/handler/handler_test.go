@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/lelemon/server/pkg/application/analytics"
 	appauth "github.com/lelemon/server/pkg/application/auth"
@@ -18,17 +19,34 @@ import (
 	"github.com/lelemon/server/pkg/infrastructure/auth"
 	"github.com/lelemon/server/pkg/infrastructure/store/sqlite"
 	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
 // TestServer wraps a test HTTP server with helper methods
 type TestServer struct {
 	*httptest.Server
-	t *testing.T
+	t        *testing.T
+	keyUsage *middleware.APIKeyUsageTracker
 }
 
 // setupTestServer creates a new test server with a fresh database
 func setupTestServer(t *testing.T) *TestServer {
 	t.Helper()
+	return setupTestServerWithExtensions(t)
+}
+
+// setupTestServerWithExtensions creates a test server that mounts the given router extensions
+func setupTestServerWithExtensions(t *testing.T, extensions ...apphttp.RouterExtension) *TestServer {
+	t.Helper()
+	return setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.Extensions = extensions
+	})
+}
+
+// setupTestServerWithConfig creates a test server whose router config is
+// adjusted by configure (e.g. to enable optional routes) before it is built
+func setupTestServerWithConfig(t *testing.T, configure func(cfg *apphttp.RouterConfig)) *TestServer {
+	t.Helper()
 
 	// Create temp database
 	tmpDB := t.TempDir() + "/test.db"
@@ -58,7 +76,11 @@ func setupTestServer(t *testing.T) *TestServer {
 	projectSvc := project.NewService(store)
 	authSvc := appauth.NewService(store, jwtService, oauthService)
 
-	router := apphttp.NewRouter(apphttp.RouterConfig{
+	// Long interval: tests flush explicitly via ts.keyUsage.Flush
+	keyUsage := middleware.NewAPIKeyUsageTracker(store, time.Hour)
+	t.Cleanup(func() { keyUsage.Stop(context.Background()) })
+
+	cfg := apphttp.RouterConfig{
 		PrimaryStore:   store,
 		AnalyticsStore: store, // Same store for tests
 		IngestSvc:      ingestSvc,
@@ -68,12 +90,15 @@ func setupTestServer(t *testing.T) *TestServer {
 		AuthSvc:        authSvc,
 		JWTService:     jwtService,
 		FrontendURL:    "http://localhost:3000",
-	})
+		KeyUsage:       keyUsage,
+	}
+	configure(&cfg)
+	router := apphttp.NewRouter(cfg)
 
 	server := httptest.NewServer(router)
 	t.Cleanup(server.Close)
 
-	return &TestServer{Server: server, t: t}
+	return &TestServer{Server: server, t: t, keyUsage: keyUsage}
 }
 
 // Request makes an HTTP request and returns the response
@@ -154,8 +179,10 @@ type TraceData struct {
 
 // StatsResponse for parsing analytics
 type StatsResponse struct {
-	TotalTraces  int     `json:"TotalTraces"`
-	TotalSpans   int     `json:"TotalSpans"`
-	TotalTokens  int     `json:"TotalTokens"`
-	TotalCostUSD float64 `json:"TotalCostUSD"`
+	TotalTraces    int     `json:"TotalTraces"`
+	TotalSpans     int     `json:"TotalSpans"`
+	TotalTokens    int     `json:"TotalTokens"`
+	TotalCostUSD   float64 `json:"TotalCostUSD"`
+	DistinctModels int     `json:"DistinctModels"`
+	DistinctUsers  int     `json:"DistinctUsers"`
 }
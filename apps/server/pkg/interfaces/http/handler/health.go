@@ -1,42 +1,42 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 	"runtime"
 	"time"
 
+	"github.com/lelemon/server/pkg/application/ingest"
 	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/infrastructure/buildinfo"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
-var (
-	// Version is set at build time
-	Version   = "dev"
-	BuildTime = "unknown"
-	startTime = time.Now()
-)
+var startTime = time.Now()
 
 // HealthHandler handles health check requests
 type HealthHandler struct {
 	primaryStore   repository.Store
 	analyticsStore repository.Store
+	ingestSvc      *ingest.Service // optional; reports worker load in verbose mode
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(primaryStore, analyticsStore repository.Store) *HealthHandler {
+func NewHealthHandler(primaryStore, analyticsStore repository.Store, ingestSvc *ingest.Service) *HealthHandler {
 	return &HealthHandler{
 		primaryStore:   primaryStore,
 		analyticsStore: analyticsStore,
+		ingestSvc:      ingestSvc,
 	}
 }
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status   string          `json:"status"`
-	Version  string          `json:"version"`
-	Uptime   string          `json:"uptime"`
-	Checks   HealthChecks    `json:"checks"`
-	System   *SystemInfo     `json:"system,omitempty"`
+	Status  string              `json:"status"`
+	Version string              `json:"version"`
+	Uptime  string              `json:"uptime"`
+	Checks  HealthChecks        `json:"checks"`
+	System  *SystemInfo         `json:"system,omitempty"`
+	Ingest  *ingest.WorkerStats `json:"ingest,omitempty"` // async ingest workers (verbose mode)
 }
 
 // HealthChecks contains individual health check results
@@ -69,7 +69,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	primaryCheck := h.checkStore(r, h.primaryStore)
 	resp := HealthResponse{
 		Status:  "ok",
-		Version: Version,
+		Version: buildinfo.Version,
 		Uptime:  time.Since(startTime).Round(time.Second).String(),
 		Checks: HealthChecks{
 			Primary: primaryCheck,
@@ -101,6 +101,10 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			NumCPU:       runtime.NumCPU(),
 			MemoryMB:     m.Alloc / 1024 / 1024,
 		}
+		if h.ingestSvc != nil {
+			stats := h.ingestSvc.WorkerStats()
+			resp.Ingest = &stats
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -109,7 +113,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusServiceUnavailable)
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	middleware.WriteJSON(w, resp)
 }
 
 // checkStore checks a store's database connection
@@ -135,7 +139,7 @@ func (h *HealthHandler) checkStore(r *http.Request, store repository.Store) Chec
 // LivenessHandler handles GET /health/live (Kubernetes liveness probe)
 func LivenessHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	middleware.WriteJSON(w, map[string]string{"status": "ok"})
 }
 
 // ReadinessHandler handles GET /health/ready (Kubernetes readiness probe)
@@ -144,7 +148,7 @@ func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request)
 	if err := h.primaryStore.Ping(r.Context()); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
+		middleware.WriteJSON(w, map[string]string{
 			"status": "not ready",
 			"error":  "primary: " + err.Error(),
 		})
@@ -156,7 +160,7 @@ func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request)
 		if err := h.analyticsStore.Ping(r.Context()); err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusServiceUnavailable)
-			json.NewEncoder(w).Encode(map[string]string{
+			middleware.WriteJSON(w, map[string]string{
 				"status": "not ready",
 				"error":  "analytics: " + err.Error(),
 			})
@@ -165,5 +169,5 @@ func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+	middleware.WriteJSON(w, map[string]string{"status": "ready"})
 }
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/lelemon/server/pkg/application/traceimport"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// ImportHandler handles trace imports from other tools
+type ImportHandler struct {
+	service *traceimport.Service
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(service *traceimport.Service) *ImportHandler {
+	return &ImportHandler{service: service}
+}
+
+// Import handles POST /api/v1/traces/import. The body is a Langfuse trace
+// export; observations that could not be imported are reported per
+// observation with a 207.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var export traceimport.LangfuseExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(export.Traces) == 0 && len(export.Observations) == 0 {
+		apierror.Write(w, http.StatusBadRequest, "Export has no traces or observations")
+		return
+	}
+
+	resp, err := h.service.ImportLangfuse(r.Context(), project, &export)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Success {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	middleware.WriteJSON(w, resp)
+}
@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTraceImport(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "import@example.com", "password": "SecurePass123", "name": "Import User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Import Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	t.Run("langfuse export", func(t *testing.T) {
+		traceID := "4b6f0d1e-2c3a-4e5f-9a0b-1c2d3e4f5a6b"
+		resp := ts.Request("POST", "/api/v1/traces/import", map[string]any{"traces": []map[string]any{{
+			"id": traceID, "name": "imported-chat", "userId": "user-1",
+			"observations": []map[string]any{
+				{"id": "root", "type": "SPAN", "name": "handler", "startTime": "2025-03-10T09:00:00Z", "endTime": "2025-03-10T09:00:02Z"},
+				{
+					"id": "gen", "parentObservationId": "root", "type": "GENERATION", "name": "completion", "model": "gpt-4o",
+					"startTime": "2025-03-10T09:00:00.5Z", "endTime": "2025-03-10T09:00:01.5Z",
+					"usageDetails": map[string]int{"input": 100, "output": 20},
+				},
+			},
+		}}}, apiKeyHeaders)
+		var result struct {
+			Success bool `json:"success"`
+			Traces  int  `json:"traces"`
+			Spans   int  `json:"spans"`
+		}
+		ParseJSON(t, resp, &result)
+		if !result.Success || result.Traces != 1 || result.Spans != 2 {
+			t.Fatalf("expected 1 trace and 2 spans imported, got %+v", result)
+		}
+
+		resp = ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders)
+		var trace struct {
+			Name  *string `json:"Name"`
+			Spans []struct {
+				Name         string  `json:"Name"`
+				ParentSpanID *string `json:"ParentSpanID"`
+				InputTokens  *int    `json:"InputTokens"`
+			} `json:"Spans"`
+		}
+		ParseJSON(t, resp, &trace)
+		if trace.Name == nil || *trace.Name != "imported-chat" || len(trace.Spans) != 2 {
+			t.Fatalf("expected the imported trace with 2 spans, got %+v", trace)
+		}
+		for _, span := range trace.Spans {
+			if span.Name == "completion" && (span.ParentSpanID == nil || span.InputTokens == nil || *span.InputTokens != 100) {
+				t.Errorf("expected the generation under its parent with its usage, got %+v", span)
+			}
+		}
+	})
+
+	t.Run("empty export rejected", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/import", map[string]any{"traces": []any{}}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+}
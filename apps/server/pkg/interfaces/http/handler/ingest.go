@@ -2,9 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
 
 	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -18,26 +20,32 @@ func NewIngestHandler(service *ingest.Service) *IngestHandler {
 	return &IngestHandler{service: service}
 }
 
-// Handle processes POST /api/v1/ingest requests
+// Handle processes POST /api/v1/ingest requests. An application/x-ndjson
+// body is streamed, one event per line (see stream).
 func (h *IngestHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated project from context
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if isNDJSON(r) {
+		h.stream(w, r)
 		return
 	}
 
 	// Parse request body
 	var req ingest.IngestRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	// Process events
-	resp, err := h.service.Ingest(r.Context(), project.ID, &req)
+	resp, err := h.service.Ingest(r.Context(), project, &req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -46,5 +54,55 @@ func (h *IngestHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	if !resp.Success {
 		w.WriteHeader(http.StatusMultiStatus)
 	}
-	json.NewEncoder(w).Encode(resp)
+	middleware.WriteJSON(w, resp)
+}
+
+// stream ingests an NDJSON body as it is read and returns the accepted and
+// rejected counts
+func (h *IngestHandler) stream(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.service.IngestStream(r.Context(), middleware.GetProject(r.Context()), r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Success {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	middleware.WriteJSON(w, resp)
+}
+
+// isNDJSON reports whether the request body is newline-delimited JSON
+func isNDJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-ndjson"
+}
+
+// DryRun processes POST /api/v1/ingest/dry-run requests: it returns the spans
+// the payload would produce without persisting them
+func (h *IngestHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ingest.IngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	resp, err := h.service.DryRun(r.Context(), project, &req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(resp.Errors) > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	middleware.WriteJSON(w, resp)
 }
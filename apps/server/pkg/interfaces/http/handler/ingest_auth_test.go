@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+func TestIngestAuthSchemes(t *testing.T) {
+	// Agents send with a token or certificate, never this project's API key
+	project := &entity.Project{Name: "Agents", APIKey: "le_agents", APIKeyHash: "agents", OwnerEmail: "agents@example.com"}
+	var store repository.Store
+	var authCfg middleware.IngestAuthConfig
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		store = cfg.PrimaryStore
+		if err := store.CreateProject(context.Background(), project); err != nil {
+			t.Fatalf("failed to create project: %v", err)
+		}
+
+		authCfg = middleware.IngestAuthConfig{
+			Schemes: map[string][]middleware.IngestAuthScheme{
+				"/ingest": {middleware.IngestAuthAPIKey, middleware.IngestAuthBearer, middleware.IngestAuthMTLS},
+			},
+			BearerTokens:   map[string]string{"collector-token": project.ID},
+			ClientSubjects: map[string]string{"otel-collector": project.ID},
+			SubjectHeader:  "X-SSL-Client-S-DN",
+			NoCORS:         []string{"/ingest"},
+		}
+		cfg.IngestAuth = authCfg
+		cfg.AllowedOrigins = []string{"http://localhost:3000"}
+	})
+
+	// A dashboard project supplies a real API key
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "ingestauth@example.com", "password": "SecurePass123", "name": "Ingest Auth User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "API Key Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var keyProject ProjectResponse
+	ParseJSON(t, projResp, &keyProject)
+
+	ingest := func(t *testing.T, path, traceID string, headers map[string]string) int {
+		t.Helper()
+		resp := ts.Request("POST", path, map[string]any{"events": []map[string]any{
+			{"traceId": traceID, "spanType": "tool", "name": "export", "status": "success"},
+		}}, headers)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// ingestedInto reports whether traceID was stored in the agents project
+	ingestedInto := func(t *testing.T, traceID string) bool {
+		t.Helper()
+		_, err := store.GetTrace(context.Background(), project.ID, traceID)
+		return err == nil
+	}
+
+	t.Run("API key", func(t *testing.T) {
+		if code := ingest(t, "/api/v1/ingest", "auth-apikey", map[string]string{"Authorization": "Bearer " + keyProject.APIKey}); code != http.StatusOK {
+			t.Errorf("expected 200, got %d", code)
+		}
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		if code := ingest(t, "/api/v1/ingest", "auth-bearer", map[string]string{"Authorization": "Bearer collector-token"}); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if !ingestedInto(t, "auth-bearer") {
+			t.Error("expected the trace in the token's project")
+		}
+		if code := ingest(t, "/api/v1/ingest", "auth-bearer-bad", map[string]string{"Authorization": "Bearer wrong-token"}); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for an unknown token, got %d", code)
+		}
+	})
+
+	t.Run("client certificate subject from the proxy", func(t *testing.T) {
+		if code := ingest(t, "/api/v1/ingest", "auth-mtls", map[string]string{"X-SSL-Client-S-DN": "CN=otel-collector,O=Acme"}); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if !ingestedInto(t, "auth-mtls") {
+			t.Error("expected the trace in the subject's project")
+		}
+		if code := ingest(t, "/api/v1/ingest", "auth-mtls-bad", map[string]string{"X-SSL-Client-S-DN": "CN=someone-else"}); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for an unknown subject, got %d", code)
+		}
+	})
+
+	t.Run("client certificate from the TLS connection", func(t *testing.T) {
+		var got *entity.Project
+		h := middleware.IngestAuth(store, nil, middleware.IngestAuthConfig{
+			Schemes:        authCfg.Schemes,
+			ClientSubjects: authCfg.ClientSubjects,
+		}, "/ingest")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = middleware.GetProject(r.Context())
+		}))
+
+		for _, tc := range []struct {
+			cn   string
+			want int
+		}{{"otel-collector", http.StatusOK}, {"someone-else", http.StatusUnauthorized}} {
+			got = nil
+			req := httptest.NewRequest("POST", "/api/v1/ingest", nil)
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: tc.cn}}}}}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("CN=%s: expected %d, got %d", tc.cn, tc.want, rec.Code)
+			}
+			if tc.want == http.StatusOK && (got == nil || got.ID != project.ID) {
+				t.Errorf("CN=%s: expected the subject's project in context, got %+v", tc.cn, got)
+			}
+		}
+	})
+
+	t.Run("unlisted routes accept API keys only", func(t *testing.T) {
+		if code := ingest(t, "/api/v1/ingest/dry-run", "auth-dry", map[string]string{"Authorization": "Bearer collector-token"}); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for a bearer token, got %d", code)
+		}
+		if code := ingest(t, "/api/v1/ingest/dry-run", "auth-dry", map[string]string{"X-SSL-Client-S-DN": "CN=otel-collector"}); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for a client subject, got %d", code)
+		}
+		if code := ingest(t, "/api/v1/ingest/dry-run", "auth-dry", map[string]string{"Authorization": "Bearer " + keyProject.APIKey}); code != http.StatusOK {
+			t.Errorf("expected 200 for an API key, got %d", code)
+		}
+	})
+
+	t.Run("server-only routes skip CORS", func(t *testing.T) {
+		preflight := func(path string) *http.Response {
+			req, _ := http.NewRequest("OPTIONS", ts.URL+path, nil)
+			req.Header.Set("Origin", "http://localhost:3000")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("preflight failed: %v", err)
+			}
+			resp.Body.Close()
+			return resp
+		}
+
+		resp := preflight("/api/v1/ingest")
+		if resp.StatusCode == http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("expected no CORS preflight on /ingest, got %d %v", resp.StatusCode, resp.Header)
+		}
+		resp = preflight("/api/v1/ingest/dry-run")
+		if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+			t.Errorf("expected CORS on /ingest/dry-run, got %d %v", resp.StatusCode, resp.Header)
+		}
+
+		resp = ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+			{"traceId": "auth-cors", "spanType": "tool", "name": "export", "status": "success"},
+		}}, map[string]string{"Authorization": "Bearer collector-token", "Origin": "http://localhost:3000"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("expected a plain response without CORS headers, got %d %v", resp.StatusCode, resp.Header)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// DryRunResponse for parsing the ingest dry-run response
+type DryRunResponse struct {
+	Spans  []map[string]any `json:"spans"`
+	Errors []struct {
+		Index   int    `json:"index"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func TestIngestDryRun(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "dryrun@example.com", "password": "SecurePass123", "name": "Dry Run User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Dry Run Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	payload := map[string]any{
+		"events": []map[string]any{
+			{
+				"traceId": "dry-run-trace", "spanId": "dry-anthropic", "spanType": "llm",
+				"provider": "anthropic", "model": "claude-3-5-sonnet-20241022", "status": "success",
+				"rawResponse": map[string]any{
+					"type": "message", "role": "assistant", "stop_reason": "tool_use",
+					"content": []map[string]any{
+						{"type": "text", "text": "Let me check."},
+						{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "Paris"}},
+					},
+					"usage": map[string]any{"input_tokens": 100, "output_tokens": 40, "cache_read_input_tokens": 300},
+				},
+			},
+			{
+				"traceId": "dry-run-trace", "spanId": "dry-openai", "parentSpanId": "dry-anthropic",
+				"spanType": "llm", "provider": "openai", "model": "gpt-4o", "status": "success",
+				"output": "It is sunny.", "inputTokens": 1000, "outputTokens": 200,
+			},
+			{
+				"traceId": "dry-run-trace", "spanId": "dry-tool", "spanType": "tool",
+				"name": "get_weather", "status": "error", "errorMessage": "timeout",
+			},
+		},
+	}
+
+	var dryRun DryRunResponse
+	t.Run("returns spans without persisting", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest/dry-run", payload, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		ParseJSON(t, resp, &dryRun)
+
+		if len(dryRun.Spans) != 3 {
+			t.Fatalf("expected 3 spans, got %d", len(dryRun.Spans))
+		}
+		for i, id := range []string{"dry-anthropic", "dry-openai", "dry-tool"} {
+			if dryRun.Spans[i]["ID"] != id || dryRun.Spans[i]["TraceID"] != "dry-run-trace" {
+				t.Errorf("span %d: expected %s in dry-run-trace, got %v in %v", i, id, dryRun.Spans[i]["ID"], dryRun.Spans[i]["TraceID"])
+			}
+		}
+
+		resp = ts.Request("GET", "/api/v1/traces/dry-run-trace", nil, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected dry run not to create the trace, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("matches what ingest persists", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest", payload, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		var trace map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/dry-run-trace", nil, apiKeyHeaders), &trace)
+		stored := make(map[string]map[string]any)
+		for _, s := range trace["Spans"].([]any) {
+			span := s.(map[string]any)
+			stored[span["ID"].(string)] = span
+		}
+
+		// Timestamps are assigned per call; everything derived from the payload must match
+		fields := []string{
+			"TraceID", "ParentSpanID", "Type", "subType", "Name", "Model", "Provider", "Input", "Output",
+			"InputTokens", "OutputTokens", "CacheReadTokens", "CacheWriteTokens", "ReasoningTokens",
+			"CostUSD", "Status", "ErrorMessage", "StopReason", "toolUses", "Metadata",
+		}
+		for _, dry := range dryRun.Spans {
+			id := dry["ID"].(string)
+			persisted, ok := stored[id]
+			if !ok {
+				t.Errorf("span %s was not persisted", id)
+				continue
+			}
+			for _, f := range fields {
+				if !reflect.DeepEqual(dry[f], persisted[f]) {
+					t.Errorf("span %s %s: dry run %v, persisted %v", id, f, dry[f], persisted[f])
+				}
+			}
+		}
+
+		if cost, _ := dryRun.Spans[0]["CostUSD"].(float64); cost <= 0 {
+			t.Errorf("expected a cost for the priced model, got %v", dryRun.Spans[0]["CostUSD"])
+		}
+		if tools, _ := dryRun.Spans[0]["toolUses"].([]any); len(tools) != 1 {
+			t.Errorf("expected 1 extracted tool use, got %v", dryRun.Spans[0]["toolUses"])
+		}
+	})
+
+	t.Run("reports strict span type rejections", func(t *testing.T) {
+		ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"strictSpanTypes": true},
+		}, map[string]string{"Authorization": "Bearer " + auth.Token}).Body.Close()
+
+		resp := ts.Request("POST", "/api/v1/ingest/dry-run", map[string]any{
+			"events": []map[string]any{
+				{"traceId": "strict-trace", "spanType": "bogus", "name": "x", "status": "success"},
+				{"traceId": "strict-trace", "spanType": "tool", "name": "y", "status": "success"},
+			},
+		}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("expected 207, got %d", resp.StatusCode)
+		}
+		var result DryRunResponse
+		ParseJSON(t, resp, &result)
+		if len(result.Spans) != 1 || result.Spans[0]["Name"] != "y" {
+			t.Errorf("expected only the valid span, got %v", result.Spans)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Index != 0 {
+			t.Errorf("expected event 0 rejected, got %+v", result.Errors)
+		}
+	})
+
+	t.Run("requires API key", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest/dry-run", payload, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
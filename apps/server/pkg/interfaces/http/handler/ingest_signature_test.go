@@ -0,0 +1,148 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+func TestIngestSignature(t *testing.T) {
+	const secret = "signing-secret"
+	newProject := func(name, key string, settings entity.ProjectSettings) *entity.Project {
+		hash := sha256.Sum256([]byte(key))
+		return &entity.Project{Name: name, APIKey: key, APIKeyHash: hex.EncodeToString(hash[:]),
+			OwnerEmail: name + "@example.com", Settings: settings}
+	}
+	signed := newProject("signed", "le_signed", entity.ProjectSettings{IngestSigningSecret: secret})
+	unsigned := newProject("unsigned", "le_unsigned", entity.ProjectSettings{})
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.IngestMaxBodyBytes = 64 << 10
+		for _, project := range []*entity.Project{signed, unsigned} {
+			if err := cfg.PrimaryStore.CreateProject(context.Background(), project); err != nil {
+				t.Fatalf("failed to create project: %v", err)
+			}
+		}
+	})
+
+	body, _ := json.Marshal(map[string]any{"events": []map[string]any{
+		{"traceId": "signed-trace", "spanType": "llm", "name": "call", "status": "success"},
+	}})
+	// ingest posts body with the project's key and the given signature headers
+	ingest := func(t *testing.T, key string, body []byte, timestamp, signature string) int {
+		t.Helper()
+		headers := map[string]string{"Authorization": "Bearer " + key}
+		if timestamp != "" {
+			headers[middleware.IngestTimestampHeader] = timestamp
+		}
+		if signature != "" {
+			headers[middleware.IngestSignatureHeader] = signature
+		}
+		resp := ts.Request("POST", "/api/v1/ingest", json.RawMessage(body), headers)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	t.Run("valid signature", func(t *testing.T) {
+		if code := ingest(t, signed.APIKey, body, now, middleware.SignIngestRequest(secret, now, body)); code != http.StatusOK {
+			t.Errorf("expected 200, got %d", code)
+		}
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		tampered, _ := json.Marshal(map[string]any{"events": []map[string]any{
+			{"traceId": "tampered-trace", "spanType": "llm", "name": "call", "status": "success"},
+		}})
+		if code := ingest(t, signed.APIKey, tampered, now, middleware.SignIngestRequest(secret, now, body)); code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", code)
+		}
+	})
+
+	t.Run("tampered timestamp", func(t *testing.T) {
+		later := strconv.FormatInt(time.Now().Unix()+1, 10)
+		if code := ingest(t, signed.APIKey, body, later, middleware.SignIngestRequest(secret, now, body)); code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", code)
+		}
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		if code := ingest(t, signed.APIKey, body, now, middleware.SignIngestRequest("other-secret", now, body)); code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", code)
+		}
+	})
+
+	t.Run("stale or future timestamp", func(t *testing.T) {
+		for _, offset := range []time.Duration{-10 * time.Minute, 10 * time.Minute} {
+			stale := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+			if code := ingest(t, signed.APIKey, body, stale, middleware.SignIngestRequest(secret, stale, body)); code != http.StatusUnauthorized {
+				t.Errorf("%v: expected 401, got %d", offset, code)
+			}
+		}
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		if code := ingest(t, signed.APIKey, body, "", ""); code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", code)
+		}
+	})
+
+	t.Run("oversized signed stream", func(t *testing.T) {
+		line := `{"traceId":"stream-trace","spanType":"llm","name":"call","status":"success"}` + "\n"
+		stream := []byte(strings.Repeat(line, (128<<10)/len(line)))
+		req, err := http.NewRequest("POST", ts.URL+"/api/v1/ingest", bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Authorization", "Bearer "+signed.APIKey)
+		req.Header.Set(middleware.IngestTimestampHeader, now)
+		req.Header.Set(middleware.IngestSignatureHeader, middleware.SignIngestRequest(secret, now, stream))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("trace writes outside ingest", func(t *testing.T) {
+		create, _ := json.Marshal(map[string]any{"name": "signed trace"})
+		post := func(headers map[string]string) int {
+			headers["Authorization"] = "Bearer " + signed.APIKey
+			resp := ts.Request("POST", "/api/v1/traces", json.RawMessage(create), headers)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if code := post(map[string]string{}); code != http.StatusUnauthorized {
+			t.Errorf("unsigned: expected 401, got %d", code)
+		}
+		if code := post(map[string]string{
+			middleware.IngestTimestampHeader: now,
+			middleware.IngestSignatureHeader: middleware.SignIngestRequest(secret, now, create),
+		}); code != http.StatusCreated {
+			t.Errorf("signed: expected 201, got %d", code)
+		}
+	})
+
+	t.Run("projects without a secret accept unsigned requests", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"events": []map[string]any{
+			{"traceId": "unsigned-trace", "spanType": "llm", "name": "call", "status": "success"},
+		}})
+		if code := ingest(t, unsigned.APIKey, body, "", ""); code != http.StatusOK {
+			t.Errorf("expected 200, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIngestStream(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "ndjson@example.com", "password": "SecurePass123", "name": "NDJSON User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "NDJSON Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	type streamResponse struct {
+		Success  bool `json:"success"`
+		Accepted int  `json:"accepted"`
+		Rejected int  `json:"rejected"`
+		Errors   []struct {
+			Index   int    `json:"index"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	// stream sends the lines written by write as a chunked NDJSON body
+	stream := func(t *testing.T, write func(w io.Writer)) streamResponse {
+		t.Helper()
+		body, pw := io.Pipe()
+		go func() {
+			write(pw)
+			pw.Close()
+		}()
+
+		req, err := http.NewRequest("POST", ts.URL+"/api/v1/ingest", body)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Authorization", "Bearer "+project.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var result streamResponse
+		ParseJSON(t, resp, &result)
+		return result
+	}
+
+	t.Run("10k events", func(t *testing.T) {
+		// ~6MB in all: past the 5MB limit on buffered request bodies
+		padding := strings.Repeat("x", 500)
+		result := stream(t, func(w io.Writer) {
+			enc := json.NewEncoder(w)
+			for i := 0; i < 10000; i++ {
+				enc.Encode(map[string]any{
+					"traceId":  fmt.Sprintf("stream-%d", i%100),
+					"spanId":   fmt.Sprintf("span-%d", i),
+					"spanType": "tool",
+					"name":     "step",
+					"status":   "success",
+					"input":    padding,
+				})
+			}
+		})
+		if !result.Success || result.Accepted != 10000 || result.Rejected != 0 {
+			t.Fatalf("expected all 10000 events accepted, got %+v", result)
+		}
+
+		resp := ts.Request("GET", "/api/v1/traces?limit=1", nil, map[string]string{"Authorization": "Bearer " + project.APIKey})
+		var page PageMeta
+		ParseJSON(t, resp, &page)
+		if page.Total != 100 {
+			t.Errorf("expected 100 traces, got %d", page.Total)
+		}
+	})
+
+	t.Run("malformed and partial lines", func(t *testing.T) {
+		result := stream(t, func(w io.Writer) {
+			io.WriteString(w, `{"traceId":"lines","spanType":"tool","name":"a","status":"success"}`+"\n")
+			io.WriteString(w, "not json\n")
+			io.WriteString(w, "\n")
+			io.WriteString(w, `{"traceId":"lines","spanType":"tool","name":"b","status":"success"}`+"\r\n")
+			io.WriteString(w, `{"traceId":"lines","input":"`+strings.Repeat("x", 2<<20)+`"}`+"\n")
+			io.WriteString(w, `{"traceId":"lines","spanType":"tool","name":"c","status":"success"}`+"\n")
+			io.WriteString(w, `{"traceId":"lines","spanType":"tool","na`) // cut off mid-event
+		})
+		if result.Success || result.Accepted != 3 || result.Rejected != 3 {
+			t.Fatalf("expected 3 accepted and 3 rejected, got %+v", result)
+		}
+		var lines []int
+		for _, e := range result.Errors {
+			lines = append(lines, e.Index)
+		}
+		if fmt.Sprint(lines) != "[1 4 6]" {
+			t.Errorf("expected errors on lines 1, 4 and 6, got %+v", result.Errors)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnalyticsLatencyHistogram(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "histogram@example.com", "password": "SecurePass123", "name": "Histogram User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Histogram Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	headers := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	span := func(spanType, model string, durationMs int) map[string]any {
+		return map[string]any{"traceId": "histogram", "spanType": spanType, "model": model,
+			"name": "step", "status": "success", "durationMs": durationMs}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		span("llm", "gpt-4o", 5), span("llm", "gpt-4o", 10), span("llm", "gpt-4o", 49),
+		span("llm", "gpt-4o", 50), span("llm", "gpt-4o", 200),
+		span("llm", "gpt-4o-mini", 20),
+		span("tool", "", 30),
+	}}, headers)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	type bucket struct {
+		MinMs int
+		MaxMs *int
+		Count int
+	}
+	histogram := func(t *testing.T, query string) []bucket {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/analytics/latency-histogram"+query, nil, headers)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var result struct{ Data []bucket }
+		ParseJSON(t, resp, &result)
+		return result.Data
+	}
+	counts := func(buckets []bucket) []int {
+		counts := make([]int, len(buckets))
+		for i, b := range buckets {
+			counts[i] = b.Count
+		}
+		return counts
+	}
+
+	t.Run("custom edges", func(t *testing.T) {
+		buckets := histogram(t, "?edges=10,50")
+		if got := counts(buckets); len(got) != 3 || got[0] != 1 || got[1] != 4 || got[2] != 2 {
+			t.Fatalf("expected counts [1 4 2], got %v", got)
+		}
+		if buckets[0].MinMs != 0 || *buckets[0].MaxMs != 10 || buckets[1].MinMs != 10 || *buckets[1].MaxMs != 50 ||
+			buckets[2].MinMs != 50 || buckets[2].MaxMs != nil {
+			t.Errorf("expected buckets [0,10) [10,50) [50,+inf), got %+v", buckets)
+		}
+	})
+
+	t.Run("filtered by span type and model", func(t *testing.T) {
+		if got := counts(histogram(t, "?edges=10,50&spanType=llm&model=gpt-4o")); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 2 {
+			t.Errorf("expected counts [1 2 2], got %v", got)
+		}
+		if got := counts(histogram(t, "?edges=10,50&spanType=tool")); len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 0 {
+			t.Errorf("expected counts [0 1 0], got %v", got)
+		}
+	})
+
+	t.Run("default edges", func(t *testing.T) {
+		// 100, 500, 1000, 2000, 5000 and 10000ms
+		got := counts(histogram(t, ""))
+		if len(got) != 7 || got[0] != 6 || got[1] != 1 {
+			t.Errorf("expected 7 buckets with 6 spans under 100ms and 1 under 500ms, got %v", got)
+		}
+	})
+
+	t.Run("invalid edges", func(t *testing.T) {
+		for _, edges := range []string{"50,10", "10,10", "0,10", "fast"} {
+			resp := ts.Request("GET", "/api/v1/analytics/latency-histogram?edges="+edges, nil, headers)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("edges=%s: expected 400, got %d", edges, resp.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListMetadataKeys(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "listmetadata@example.com", "password": "SecurePass123", "name": "List Metadata User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "List Metadata Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/traces", map[string]any{
+		"name":     "checkout",
+		"metadata": map[string]any{"env": "prod", "tenant": "acme", "prompt": "a very long system prompt"},
+	}, apiKeyHeaders)
+	var created struct {
+		ID string `json:"id"`
+	}
+	ParseJSON(t, resp, &created)
+
+	type listPage struct {
+		Data []struct {
+			ID       string         `json:"ID"`
+			Metadata map[string]any `json:"Metadata"`
+		} `json:"Data"`
+	}
+	listMetadata := func(t *testing.T, path string, headers map[string]string) map[string]any {
+		t.Helper()
+		resp := ts.Request("GET", path, nil, headers)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", path, resp.StatusCode)
+		}
+		var page listPage
+		ParseJSON(t, resp, &page)
+		if len(page.Data) != 1 {
+			t.Fatalf("GET %s: expected 1 trace, got %d", path, len(page.Data))
+		}
+		return page.Data[0].Metadata
+	}
+	lists := map[string]map[string]string{
+		"/api/v1/traces": apiKeyHeaders,
+		"/api/v1/dashboard/projects/" + project.ID + "/traces": jwtHeaders,
+	}
+
+	t.Run("all metadata by default", func(t *testing.T) {
+		for path, headers := range lists {
+			if metadata := listMetadata(t, path, headers); len(metadata) != 3 {
+				t.Errorf("GET %s: expected all metadata, got %v", path, metadata)
+			}
+		}
+	})
+
+	resp = ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"listMetadataKeys": []string{"env", "tenant", "missing"}},
+	}, jwtHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+	}
+
+	t.Run("lists only allowlisted keys", func(t *testing.T) {
+		want := map[string]any{"env": "prod", "tenant": "acme"}
+		for path, headers := range lists {
+			if metadata := listMetadata(t, path, headers); !reflect.DeepEqual(metadata, want) {
+				t.Errorf("GET %s: expected %v, got %v", path, want, metadata)
+			}
+		}
+	})
+
+	t.Run("detail keeps full metadata", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/"+created.ID, nil, apiKeyHeaders)
+		var trace struct {
+			Metadata map[string]any `json:"metadata"`
+		}
+		ParseJSON(t, resp, &trace)
+		if len(trace.Metadata) != 3 {
+			t.Errorf("expected full metadata on detail, got %v", trace.Metadata)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -36,7 +37,7 @@ const ConsentAudience = "mcp-consent"
 func (h *MCPConsentHandler) Mint(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r.Context())
 	if user == nil || user.UserID == "" {
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
@@ -44,7 +45,7 @@ func (h *MCPConsentHandler) Mint(w http.ResponseWriter, r *http.Request) {
 		ProjectID string `json:"projectId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProjectID == "" {
-		http.Error(w, `{"error":"projectId is required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "projectId is required")
 		return
 	}
 
@@ -52,11 +53,11 @@ func (h *MCPConsentHandler) Mint(w http.ResponseWriter, r *http.Request) {
 	owns, err := h.store.IsProjectOwner(r.Context(), req.ProjectID, user.Email)
 	if err != nil {
 		slog.Error("consent ownership check failed", "error", err)
-		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 	if !owns {
-		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		apierror.Write(w, http.StatusForbidden, "forbidden")
 		return
 	}
 
@@ -70,12 +71,12 @@ func (h *MCPConsentHandler) Mint(w http.ResponseWriter, r *http.Request) {
 	}).SignedString(h.secret)
 	if err != nil {
 		slog.Error("consent token signing failed", "error", err)
-		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"consentToken": token}); err != nil {
+	if err := middleware.WriteJSON(w, map[string]string{"consentToken": token}); err != nil {
 		slog.Error("consent response encode failed", "error", err)
 	}
 }
@@ -9,6 +9,8 @@ import (
 
 	"github.com/lelemon/server/pkg/domain/entity"
 	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
 // OAuthStoreHandler exposes repository.OAuthStore over a single internal RPC endpoint so the MCP
@@ -125,7 +127,7 @@ type oauthRPCRequest struct {
 func (h *OAuthStoreHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var req oauthRPCRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid request"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "invalid request")
 		return
 	}
 	ctx := r.Context()
@@ -133,14 +135,14 @@ func (h *OAuthStoreHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	result, err := h.dispatch(ctx, &req)
 	if err != nil {
 		slog.Error("oauth store rpc failed", "op", req.Op, "error", err)
-		http.Error(w, `{"error":"store operation failed"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "store operation failed")
 		return
 	}
 	if result == nil {
 		result = map[string]any{}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(result); err != nil {
+	if err := middleware.WriteJSON(w, result); err != nil {
 		slog.Error("oauth store rpc encode failed", "op", req.Op, "error", err)
 	}
 }
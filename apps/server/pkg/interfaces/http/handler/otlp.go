@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/lelemon/server/pkg/application/otlp"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// OTLPHandler handles the OpenTelemetry (OTLP/HTTP) receiver endpoints
+type OTLPHandler struct {
+	service *otlp.Service
+}
+
+// NewOTLPHandler creates a new OTLP handler
+func NewOTLPHandler(service *otlp.Service) *OTLPHandler {
+	return &OTLPHandler{service: service}
+}
+
+// Metrics handles POST /api/v1/otlp/v1/metrics. Only the OTLP/JSON encoding
+// is accepted; data points that are not recorded are reported in the
+// response's partialSuccess.
+func (h *OTLPHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		apierror.Write(w, http.StatusUnsupportedMediaType, "Only OTLP/JSON (application/json) is supported")
+		return
+	}
+
+	var req otlp.MetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	resp, err := h.service.IngestMetrics(r.Context(), project, &req)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, resp)
+}
@@ -0,0 +1,162 @@
+package handler_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOTLPMetrics(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "otlp@example.com", "password": "SecurePass123", "name": "OTLP User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "OTLP Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	post := func(t *testing.T, contentType, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", ts.URL+"/api/v1/otlp/v1/metrics", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+project.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	// A GenAI instrumentation export: delta token usage histograms for two
+	// calls, plus metrics that are not recorded
+	now := time.Now().UnixNano()
+	payload := strings.NewReplacer("NOW", fmt.Sprint(now)).Replace(`{
+	  "resourceMetrics": [{
+	    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "support-agent"}}]},
+	    "scopeMetrics": [{
+	      "scope": {"name": "opentelemetry.instrumentation.openai"},
+	      "metrics": [
+	        {
+	          "name": "gen_ai.client.token.usage",
+	          "unit": "{token}",
+	          "histogram": {
+	            "aggregationTemporality": 1,
+	            "dataPoints": [
+	              {"timeUnixNano": "NOW", "count": "1", "sum": 120, "attributes": [
+	                {"key": "gen_ai.system", "value": {"stringValue": "openai"}},
+	                {"key": "gen_ai.request.model", "value": {"stringValue": "gpt-4o"}},
+	                {"key": "gen_ai.token.type", "value": {"stringValue": "input"}}]},
+	              {"timeUnixNano": "NOW", "count": "1", "sum": 30, "attributes": [
+	                {"key": "gen_ai.system", "value": {"stringValue": "openai"}},
+	                {"key": "gen_ai.request.model", "value": {"stringValue": "gpt-4o"}},
+	                {"key": "gen_ai.token.type", "value": {"stringValue": "output"}}]},
+	              {"timeUnixNano": "NOW", "count": "1", "sum": 7, "attributes": [
+	                {"key": "gen_ai.system", "value": {"stringValue": "openai"}},
+	                {"key": "gen_ai.request.model", "value": {"stringValue": "gpt-4o"}},
+	                {"key": "gen_ai.token.type", "value": {"stringValue": "reasoning"}}]}
+	            ]
+	          }
+	        },
+	        {
+	          "name": "gen_ai.client.token.usage",
+	          "sum": {
+	            "aggregationTemporality": 1,
+	            "isMonotonic": true,
+	            "dataPoints": [
+	              {"timeUnixNano": "NOW", "asInt": "50", "attributes": [
+	                {"key": "gen_ai.provider.name", "value": {"stringValue": "anthropic"}},
+	                {"key": "gen_ai.response.model", "value": {"stringValue": "claude-3-5-haiku"}},
+	                {"key": "gen_ai.token.type", "value": {"stringValue": "input"}}]}
+	            ]
+	          }
+	        },
+	        {
+	          "name": "gen_ai.client.token.usage",
+	          "sum": {"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": [{"timeUnixNano": "NOW", "asInt": "999"}]}
+	        },
+	        {
+	          "name": "gen_ai.client.operation.duration",
+	          "histogram": {"aggregationTemporality": 1, "dataPoints": [{"timeUnixNano": "NOW", "count": "1", "sum": 0.8}]}
+	        },
+	        {
+	          "name": "gen_ai.client.token.usage",
+	          "gauge": {"dataPoints": [{"timeUnixNano": "NOW", "asInt": "5"}]}
+	        }
+	      ]
+	    }]
+	  }]
+	}`)
+
+	t.Run("records token usage and rejects the rest", func(t *testing.T) {
+		resp := post(t, "application/json", payload)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var result struct {
+			PartialSuccess struct {
+				RejectedDataPoints int64  `json:"rejectedDataPoints"`
+				ErrorMessage       string `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		ParseJSON(t, resp, &result)
+		if result.PartialSuccess.RejectedDataPoints != 4 {
+			t.Errorf("expected 4 rejected data points, got %+v", result.PartialSuccess)
+		}
+		for _, reason := range []string{"reasoning", "cumulative", "gen_ai.client.operation.duration", "unsupported metric type"} {
+			if !strings.Contains(result.PartialSuccess.ErrorMessage, reason) {
+				t.Errorf("expected the error message to mention %q, got %q", reason, result.PartialSuccess.ErrorMessage)
+			}
+		}
+
+		resp = ts.Request("GET", "/api/v1/analytics/models", nil, map[string]string{"Authorization": "Bearer " + project.APIKey})
+		var models struct {
+			Data []struct {
+				Model        string
+				Provider     string
+				Requests     int
+				InputTokens  int
+				OutputTokens int
+			}
+		}
+		ParseJSON(t, resp, &models)
+		type usage struct {
+			provider                string
+			requests, input, output int
+		}
+		got := make(map[string]usage)
+		for _, m := range models.Data {
+			got[m.Model] = usage{m.Provider, m.Requests, m.InputTokens, m.OutputTokens}
+		}
+		want := map[string]usage{
+			"gpt-4o":           {"openai", 1, 120, 30},
+			"claude-3-5-haiku": {"anthropic", 1, 50, 0},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d models, got %+v", len(want), models.Data)
+		}
+		for model, w := range want {
+			if got[model] != w {
+				t.Errorf("%s: expected %+v, got %+v", model, w, got[model])
+			}
+		}
+	})
+
+	t.Run("rejects protobuf", func(t *testing.T) {
+		resp := post(t, "application/x-protobuf", "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("expected 415, got %d", resp.StatusCode)
+		}
+	})
+}
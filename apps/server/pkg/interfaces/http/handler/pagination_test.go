@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// PageMeta for parsing the pagination fields shared by list endpoints
+type PageMeta struct {
+	Data       []map[string]any `json:"Data"`
+	Total      int              `json:"Total"`
+	Limit      int              `json:"Limit"`
+	Offset     int              `json:"Offset"`
+	HasMore    bool             `json:"HasMore"`
+	TotalPages int              `json:"TotalPages"`
+}
+
+func TestPaginationMetadata(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "pages@example.com", "password": "SecurePass123", "name": "Pages User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Pages Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	// 5 traces for user-a (one session each), 2 for user-b
+	var events []map[string]any
+	for i := 0; i < 7; i++ {
+		user := "user-a"
+		if i >= 5 {
+			user = "user-b"
+		}
+		events = append(events, map[string]any{
+			"traceId":    fmt.Sprintf("page-trace-%d", i),
+			"spanId":     fmt.Sprintf("page-span-%d", i),
+			"spanType":   "tool",
+			"name":       "lookup",
+			"durationMs": 10,
+			"status":     "success",
+			"userId":     user,
+			"sessionId":  fmt.Sprintf("page-session-%d", i),
+		})
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected status 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	list := func(method, path string, body any) PageMeta {
+		t.Helper()
+		resp := ts.Request(method, path, body, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d", method, path, resp.StatusCode)
+		}
+		var page PageMeta
+		ParseJSON(t, resp, &page)
+		return page
+	}
+	assertPage := func(t *testing.T, page PageMeta, total, size int, hasMore bool, totalPages int) {
+		t.Helper()
+		if page.Total != total || len(page.Data) != size || page.HasMore != hasMore || page.TotalPages != totalPages {
+			t.Errorf("expected total=%d size=%d hasMore=%v totalPages=%d, got total=%d size=%d hasMore=%v totalPages=%d",
+				total, size, hasMore, totalPages, page.Total, len(page.Data), page.HasMore, page.TotalPages)
+		}
+		if page.HasMore != (page.Offset+len(page.Data) < page.Total) {
+			t.Errorf("HasMore=%v inconsistent with offset=%d len=%d total=%d", page.HasMore, page.Offset, len(page.Data), page.Total)
+		}
+	}
+
+	t.Run("traces", func(t *testing.T) {
+		assertPage(t, list("GET", "/api/v1/traces?limit=3&offset=0", nil), 7, 3, true, 3)
+		assertPage(t, list("GET", "/api/v1/traces?limit=3&offset=3", nil), 7, 3, true, 3)
+		assertPage(t, list("GET", "/api/v1/traces?limit=3&offset=6", nil), 7, 1, false, 3)
+		assertPage(t, list("GET", "/api/v1/traces?limit=10", nil), 7, 7, false, 1)
+	})
+
+	t.Run("traces total follows filters", func(t *testing.T) {
+		assertPage(t, list("GET", "/api/v1/traces?userId=user-b&limit=1", nil), 2, 1, true, 2)
+		assertPage(t, list("GET", "/api/v1/traces?userId=user-b&limit=1&offset=1", nil), 2, 1, false, 2)
+		assertPage(t, list("GET", "/api/v1/traces?userId=nobody", nil), 0, 0, false, 0)
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		assertPage(t, list("GET", "/api/v1/sessions?limit=4", nil), 7, 4, true, 2)
+		assertPage(t, list("GET", "/api/v1/sessions?userId=user-a&limit=4&offset=4", nil), 5, 1, false, 2)
+	})
+
+	t.Run("span search", func(t *testing.T) {
+		assertPage(t, list("POST", "/api/v1/spans/search", map[string]any{"name": "lookup", "limit": 5}), 7, 5, true, 2)
+		assertPage(t, list("POST", "/api/v1/spans/search", map[string]any{"name": "lookup", "limit": 5, "offset": 5}), 7, 2, false, 2)
+	})
+}
@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIngestPartialSpanFailure(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "partialspans@example.com", "password": "SecurePass123", "name": "Partial Spans User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Partial Spans Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			{"traceId": "partial-first", "spanId": "partial-dup", "spanType": "tool", "name": "lookup", "status": "success"},
+		},
+	}, apiKeyHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("first ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	// The second batch reuses a stored span ID, which only that span's
+	// insert rejects
+	resp = ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			{"traceId": "partial-second", "spanId": "partial-a", "spanType": "tool", "name": "lookup", "status": "success"},
+			{"traceId": "partial-second", "spanId": "partial-dup", "spanType": "tool", "name": "lookup", "status": "success"},
+			{"traceId": "partial-second", "spanId": "partial-b", "spanType": "tool", "name": "lookup", "status": "success"},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("second ingest: expected 207, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Success     bool `json:"success"`
+		Processed   int  `json:"processed"`
+		FailedSpans []struct {
+			TraceID string `json:"traceId"`
+			SpanID  string `json:"spanId"`
+			Message string `json:"message"`
+		} `json:"failedSpans"`
+	}
+	ParseJSON(t, resp, &result)
+	if result.Success || result.Processed != 2 {
+		t.Errorf("expected an unsuccessful result with 2 processed events, got %+v", result)
+	}
+	if len(result.FailedSpans) != 1 || result.FailedSpans[0].SpanID != "partial-dup" ||
+		result.FailedSpans[0].TraceID != "partial-second" || result.FailedSpans[0].Message == "" {
+		t.Fatalf("expected only partial-dup to fail, got %+v", result.FailedSpans)
+	}
+
+	var trace struct {
+		Spans []struct {
+			ID string
+		}
+	}
+	ParseJSON(t, ts.Request("GET", "/api/v1/traces/partial-second", nil, apiKeyHeaders), &trace)
+	ids := map[string]bool{}
+	for _, span := range trace.Spans {
+		ids[span.ID] = true
+	}
+	if len(trace.Spans) != 2 || !ids["partial-a"] || !ids["partial-b"] {
+		t.Errorf("expected the two valid spans to be stored, got %+v", trace.Spans)
+	}
+}
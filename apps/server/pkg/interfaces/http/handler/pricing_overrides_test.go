@@ -0,0 +1,98 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPricingOverrides(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "overrides@example.com", "password": "SecurePass123", "name": "Overrides User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	createProject := func(name string) ProjectResponse {
+		var project ProjectResponse
+		ParseJSON(t, ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": name}, jwtHeaders), &project)
+		return project
+	}
+	listPrice := createProject("List Price Project")
+	discounted := createProject("Discounted Project")
+
+	resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+discounted.ID, map[string]any{
+		"settings": map[string]any{"pricingOverrides": map[string]any{
+			"gpt-4o": map[string]any{"input": 0.00125, "output": 0.005}, // 50% off
+		}},
+	}, jwtHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+	}
+
+	// ingest sends the same gpt-4o call to project and returns its span's cost
+	ingest := func(t *testing.T, project ProjectResponse, traceID string) float64 {
+		t.Helper()
+		apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+			"events": []map[string]any{
+				{"traceId": traceID, "spanId": traceID + "-span", "spanType": "llm", "provider": "openai", "model": "gpt-4o",
+					"status": "success", "inputTokens": 1000, "outputTokens": 1000},
+			},
+		}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+		}
+		return spanCost(t, ts, traceID, apiKeyHeaders)
+	}
+
+	t.Run("override changes the computed cost", func(t *testing.T) {
+		if cost := ingest(t, listPrice, "overrides-list"); cost != 0.0125 {
+			t.Errorf("list price: expected $0.0125, got $%v", cost)
+		}
+		if cost := ingest(t, discounted, "overrides-discounted"); cost != 0.00625 {
+			t.Errorf("discounted: expected $0.00625, got $%v", cost)
+		}
+	})
+
+	t.Run("recost keeps the override", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/dashboard/projects/"+discounted.ID+"/recost", nil, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("recost: expected 200, got %d", resp.StatusCode)
+		}
+		headers := map[string]string{"Authorization": "Bearer " + discounted.APIKey}
+		if cost := spanCost(t, ts, "overrides-discounted", headers); cost != 0.00625 {
+			t.Errorf("expected $0.00625 after recost, got $%v", cost)
+		}
+	})
+
+	t.Run("negative rates are rejected", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+discounted.ID, map[string]any{
+			"settings": map[string]any{"pricingOverrides": map[string]any{
+				"gpt-4o": map[string]any{"input": -1, "output": 0.005},
+			}},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// spanCost returns the cost of the only span of a trace
+func spanCost(t *testing.T, ts *TestServer, traceID string, headers map[string]string) float64 {
+	t.Helper()
+	var trace struct {
+		Spans []struct{ CostUSD *float64 }
+	}
+	ParseJSON(t, ts.Request("GET", "/api/v1/traces/"+traceID, nil, headers), &trace)
+	if len(trace.Spans) != 1 || trace.Spans[0].CostUSD == nil {
+		t.Fatalf("expected one priced span, got %+v", trace.Spans)
+	}
+	return *trace.Spans[0].CostUSD
+}
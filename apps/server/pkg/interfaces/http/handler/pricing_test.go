@@ -571,3 +571,71 @@ func TestPricingUnknownModel(t *testing.T) {
 		}
 	})
 }
+
+// TestRecostEndpoint verifies the dashboard recost endpoint is project-scoped and idempotent
+func TestRecostEndpoint(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "recost@example.com", "password": "SecurePass123", "name": "Recost User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Recost Test Project",
+	}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{{
+			"spanType": "llm", "provider": "openai", "model": "gpt-4o",
+			"inputTokens": 1000, "outputTokens": 500, "status": "success",
+		}},
+	}, map[string]string{"Authorization": "Bearer " + project.APIKey})
+
+	t.Run("costs already current", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/dashboard/projects/"+project.ID+"/recost", nil, jwtHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var result struct {
+			Scanned int `json:"scanned"`
+			Updated int `json:"updated"`
+		}
+		ParseJSON(t, resp, &result)
+
+		if result.Scanned != 1 {
+			t.Errorf("expected 1 scanned span, got %d", result.Scanned)
+		}
+		if result.Updated != 0 {
+			t.Errorf("expected 0 updated spans, got %d", result.Updated)
+		}
+	})
+
+	t.Run("inverted range", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/dashboard/projects/"+project.ID+"/recost", map[string]string{
+			"from": "2025-02-01T00:00:00Z", "to": "2025-01-01T00:00:00Z",
+		}, jwtHeaders)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("other user's project", func(t *testing.T) {
+		reg := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+			"email": "recost-other@example.com", "password": "SecurePass123", "name": "Other User",
+		}, nil)
+		var other AuthResponse
+		ParseJSON(t, reg, &other)
+
+		resp := ts.Request("POST", "/api/v1/dashboard/projects/"+project.ID+"/recost", nil,
+			map[string]string{"Authorization": "Bearer " + other.Token})
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -5,7 +5,7 @@ import (
 	"net/http"
 
 	"github.com/lelemon/server/pkg/application/project"
-	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
@@ -23,57 +23,57 @@ func NewProjectHandler(service *project.Service) *ProjectHandler {
 func (h *ProjectHandler) GetCurrent(w http.ResponseWriter, r *http.Request) {
 	proj := middleware.GetProject(r.Context())
 	if proj == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	result := h.service.GetCurrent(r.Context(), proj)
+	result, err := h.service.GetCurrent(r.Context(), proj)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // UpdateCurrent handles PATCH /api/v1/projects/me
 func (h *ProjectHandler) UpdateCurrent(w http.ResponseWriter, r *http.Request) {
 	proj := middleware.GetProject(r.Context())
 	if proj == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var req project.UpdateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.service.UpdateCurrent(r.Context(), proj.ID, &req); err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.FromError(w, err, "Project not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	middleware.WriteJSON(w, map[string]bool{"success": true})
 }
 
 // RotateAPIKey handles POST /api/v1/projects/api-key
 func (h *ProjectHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
 	proj := middleware.GetProject(r.Context())
 	if proj == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	result, err := h.service.RotateAPIKey(r.Context(), proj.ID)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Project not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Project not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
@@ -1,8 +1,16 @@
 package handler_test
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
 func TestProjectCRUD(t *testing.T) {
@@ -160,3 +168,360 @@ func TestProjectIsolation(t *testing.T) {
 		}
 	})
 }
+
+func TestAPIKeyUsageTracking(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "keyusage@example.com", "password": "SecurePass123", "name": "Key Usage User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Key Usage Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	type usageResponse struct {
+		APIKeyLastUsedAt   *time.Time `json:"apiKeyLastUsedAt"`
+		APIKeyRequestCount int64      `json:"apiKeyRequestCount"`
+	}
+	getUsage := func(t *testing.T) usageResponse {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/projects/me", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		var usage usageResponse
+		ParseJSON(t, resp, &usage)
+		return usage
+	}
+	flush := func(t *testing.T) {
+		t.Helper()
+		if err := ts.keyUsage.Flush(context.Background()); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+	}
+
+	var firstSeen time.Time
+
+	t.Run("unused key has no last_used_at", func(t *testing.T) {
+		usage := getUsage(t)
+		if usage.APIKeyLastUsedAt != nil {
+			t.Errorf("expected no lastUsedAt before first flush, got %v", usage.APIKeyLastUsedAt)
+		}
+		if usage.APIKeyRequestCount != 0 {
+			t.Errorf("expected 0 requests, got %d", usage.APIKeyRequestCount)
+		}
+	})
+
+	t.Run("usage recorded after flush", func(t *testing.T) {
+		flush(t)
+		usage := getUsage(t)
+		if usage.APIKeyLastUsedAt == nil {
+			t.Fatal("expected lastUsedAt to be set")
+		}
+		if usage.APIKeyRequestCount != 1 {
+			t.Errorf("expected 1 request, got %d", usage.APIKeyRequestCount)
+		}
+		firstSeen = *usage.APIKeyLastUsedAt
+	})
+
+	t.Run("last_used_at advances", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		ts.Request("POST", "/api/v1/ingest", map[string]any{
+			"events": []map[string]any{{"spanType": "llm", "model": "gpt-4o", "status": "success"}},
+		}, apiKeyHeaders)
+		flush(t)
+
+		usage := getUsage(t)
+		if usage.APIKeyLastUsedAt == nil || !usage.APIKeyLastUsedAt.After(firstSeen) {
+			t.Errorf("expected lastUsedAt after %v, got %v", firstSeen, usage.APIKeyLastUsedAt)
+		}
+		// GET /projects/me (x2) + ingest
+		if usage.APIKeyRequestCount != 3 {
+			t.Errorf("expected 3 requests, got %d", usage.APIKeyRequestCount)
+		}
+	})
+
+	t.Run("rotating the key resets usage", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/projects/api-key", nil, apiKeyHeaders)
+		var rotated map[string]string
+		ParseJSON(t, resp, &rotated)
+		flush(t)
+
+		apiKeyHeaders = map[string]string{"Authorization": "Bearer " + rotated["apiKey"]}
+		usage := getUsage(t)
+		if usage.APIKeyLastUsedAt != nil || usage.APIKeyRequestCount != 0 {
+			t.Errorf("expected fresh usage for new key, got %+v", usage)
+		}
+	})
+}
+
+func TestAPIKeyEnvironments(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "keyenv@example.com", "password": "SecurePass123", "name": "Key Env User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	createResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Prod Project", "environment": "prod",
+	}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, createResp, &project)
+
+	status := func(apiKey string) int {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/projects/me", nil, map[string]string{"Authorization": "Bearer " + apiKey})
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	rotate := func(t *testing.T) string {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/dashboard/projects/"+project.ID+"/api-key", nil, jwtHeaders)
+		var result map[string]string
+		ParseJSON(t, resp, &result)
+		return result["apiKey"]
+	}
+	setEnvironment := func(t *testing.T, env string) {
+		t.Helper()
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"environment": env},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("set environment %q: expected 200, got %d", env, resp.StatusCode)
+		}
+	}
+
+	t.Run("keys carry the environment prefix", func(t *testing.T) {
+		if !strings.HasPrefix(project.APIKey, "le_prod_") {
+			t.Errorf("expected a le_prod_ key, got %s", project.APIKey)
+		}
+		if code := status(project.APIKey); code != http.StatusOK {
+			t.Errorf("expected the key accepted, got %d", code)
+		}
+	})
+
+	t.Run("rotation preserves the prefix", func(t *testing.T) {
+		project.APIKey = rotate(t)
+		if !strings.HasPrefix(project.APIKey, "le_prod_") {
+			t.Errorf("expected a le_prod_ key, got %s", project.APIKey)
+		}
+	})
+
+	t.Run("other settings keep the environment", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"strictSpanTypes": true},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if code := status(project.APIKey); code != http.StatusOK {
+			t.Errorf("expected the key still accepted, got %d", code)
+		}
+	})
+
+	t.Run("keys of another environment are rejected", func(t *testing.T) {
+		setEnvironment(t, "test")
+		if code := status(project.APIKey); code != http.StatusUnauthorized {
+			t.Errorf("expected the prod key rejected, got %d", code)
+		}
+
+		testKey := rotate(t)
+		if !strings.HasPrefix(testKey, "le_test_") {
+			t.Errorf("expected a le_test_ key, got %s", testKey)
+		}
+		if code := status(testKey); code != http.StatusOK {
+			t.Errorf("expected the test key accepted, got %d", code)
+		}
+	})
+
+	t.Run("invalid environments", func(t *testing.T) {
+		for _, env := range []string{"Prod", "prod_eu", "has space"} {
+			resp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+				"name": "Bad Env", "environment": env,
+			}, jwtHeaders)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%q: expected 400, got %d", env, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestProjectFootprint(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "footprint@example.com", "password": "SecurePass123", "name": "Footprint User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Footprint Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	type footprint struct {
+		Traces     int64 `json:"traces"`
+		Spans      int64 `json:"spans"`
+		TraceBytes int64 `json:"traceBytes"`
+		SpanBytes  int64 `json:"spanBytes"`
+	}
+	getFootprint := func(t *testing.T) footprint {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/projects/me", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		var result struct {
+			Footprint footprint `json:"footprint"`
+		}
+		ParseJSON(t, resp, &result)
+		return result.Footprint
+	}
+
+	t.Run("empty before ingest", func(t *testing.T) {
+		if fp := getFootprint(t); fp != (footprint{}) {
+			t.Errorf("expected an empty footprint, got %+v", fp)
+		}
+	})
+
+	t.Run("counts ingested traces and spans", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+			{"traceId": "footprint-1", "spanId": "fp-1a", "spanType": "llm", "status": "success",
+				"input": strings.Repeat("prompt ", 100), "output": strings.Repeat("answer ", 100)},
+			{"traceId": "footprint-1", "spanId": "fp-1b", "spanType": "tool", "status": "success"},
+			{"traceId": "footprint-2", "spanId": "fp-2a", "spanType": "llm", "status": "success"},
+		}}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		fp := getFootprint(t)
+		if fp.Traces != 2 || fp.Spans != 3 {
+			t.Errorf("expected 2 traces and 3 spans, got %+v", fp)
+		}
+		if fp.TraceBytes <= 0 {
+			t.Errorf("expected trace bytes, got %d", fp.TraceBytes)
+		}
+		// The payloads alone are 1400 bytes
+		if fp.SpanBytes < 1400 {
+			t.Errorf("expected at least 1400 span bytes, got %d", fp.SpanBytes)
+		}
+	})
+}
+
+func TestProjectSecrets(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "secrets@example.com", "password": "SecurePass123", "name": "Secrets User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Secrets Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	patch := func(t *testing.T, path string, body map[string]any, headers map[string]string, want int) {
+		t.Helper()
+		resp := ts.Request("PATCH", path, body, headers)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("PATCH %s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+	// unsignedIngest reports the status of an unsigned ingest request
+	unsignedIngest := func() int {
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+			"events": []map[string]any{{"traceId": "secrets-trace", "spanType": "tool", "name": "lookup", "status": "success"}},
+		}, apiKeyHeaders)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	patch(t, "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"webhookSecret": "whsec-value", "ingestSigningSecret": "ingsec-value"},
+	}, jwtHeaders, http.StatusOK)
+
+	t.Run("responses omit the secrets", func(t *testing.T) {
+		for _, req := range []struct {
+			path    string
+			headers map[string]string
+		}{
+			{"/api/v1/projects/me", apiKeyHeaders},
+			{"/api/v1/dashboard/projects", jwtHeaders},
+		} {
+			resp := ts.Request("GET", req.path, nil, req.headers)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s: expected 200, got %d", req.path, resp.StatusCode)
+			}
+			if strings.Contains(string(body), "whsec-value") || strings.Contains(string(body), "ingsec-value") {
+				t.Errorf("GET %s: expected no secrets, got %s", req.path, body)
+			}
+		}
+	})
+
+	t.Run("the API key can't change them", func(t *testing.T) {
+		// signed returns the API key headers signing body with the ingest secret
+		signed := func(body map[string]any) map[string]string {
+			raw, _ := json.Marshal(body)
+			now := strconv.FormatInt(time.Now().Unix(), 10)
+			return map[string]string{
+				"Authorization":                  apiKeyHeaders["Authorization"],
+				middleware.IngestTimestampHeader: now,
+				middleware.IngestSignatureHeader: middleware.SignIngestRequest("ingsec-value", now, raw),
+			}
+		}
+		change := map[string]any{"settings": map[string]any{"ingestSigningSecret": "attacker"}}
+		patch(t, "/api/v1/projects/me", change, apiKeyHeaders, http.StatusUnauthorized)
+		patch(t, "/api/v1/projects/me", change, signed(change), http.StatusForbidden)
+		remove := map[string]any{"clearSecrets": []string{"ingestSigningSecret"}}
+		patch(t, "/api/v1/projects/me", remove, signed(remove), http.StatusForbidden)
+
+		// Settings without the secrets keep them
+		keep := map[string]any{"settings": map[string]any{}}
+		patch(t, "/api/v1/projects/me", keep, signed(keep), http.StatusOK)
+		if code := unsignedIngest(); code != http.StatusUnauthorized {
+			t.Errorf("expected signing still required, got %d", code)
+		}
+	})
+
+	t.Run("the dashboard keeps omitted secrets and clears them explicitly", func(t *testing.T) {
+		patch(t, "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"modelAliases": map[string]string{"gpt-4o": "GPT"}},
+		}, jwtHeaders, http.StatusOK)
+		if code := unsignedIngest(); code != http.StatusUnauthorized {
+			t.Errorf("expected signing still required, got %d", code)
+		}
+
+		patch(t, "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"clearSecrets": []string{"ingestSigningSecret"},
+		}, jwtHeaders, http.StatusOK)
+		if code := unsignedIngest(); code != http.StatusOK {
+			t.Errorf("expected unsigned ingest accepted once cleared, got %d", code)
+		}
+
+		patch(t, "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"clearSecrets": []string{"apiKey"},
+		}, jwtHeaders, http.StatusBadRequest)
+	})
+}
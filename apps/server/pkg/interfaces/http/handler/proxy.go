@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lelemon/server/pkg/application/proxy"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// ProxyHandler handles the OpenAI-compatible proxy endpoints
+type ProxyHandler struct {
+	service *proxy.Service
+}
+
+// NewProxyHandler creates a new proxy handler
+func NewProxyHandler(service *proxy.Service) *ProxyHandler {
+	return &ProxyHandler{service: service}
+}
+
+// ChatCompletions handles POST /api/v1/proxy/openai/v1/chat/completions.
+// The upstream response (streamed or not) is returned verbatim; the call is
+// recorded as an llm span once it completes.
+func (h *ProxyHandler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	start := time.Now()
+	resp, err := h.service.Forward(r.Context(), r.Header, body)
+	if err != nil {
+		slog.Warn("proxy upstream request failed", "project_id", project.ID, "error", err)
+		apierror.Write(w, http.StatusBadGateway, "Upstream request failed")
+		return
+	}
+	defer resp.Body.Close()
+
+	call := &proxy.ChatCompletion{
+		Request:    body,
+		StatusCode: resp.StatusCode,
+		Streaming:  strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+		TraceID:    r.Header.Get(proxy.TraceIDHeader),
+		SessionID:  r.Header.Get(proxy.SessionIDHeader),
+		UserID:     r.Header.Get(proxy.UserIDHeader),
+	}
+
+	h.service.CopyResponseHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+
+	// Relay the body as it arrives (flushing each read so streamed chunks
+	// reach the client immediately) while keeping a copy to record
+	var recorded bytes.Buffer
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if call.FirstTokenMs == nil && call.Streaming {
+				ms := int(time.Since(start).Milliseconds())
+				call.FirstTokenMs = &ms
+			}
+			recorded.Write(buf[:n])
+			if _, err := w.Write(buf[:n]); err != nil {
+				break // client went away; still record what upstream sent so far
+			}
+			if call.Streaming {
+				rc.Flush()
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	call.Response = recorded.Bytes()
+	call.DurationMs = int(time.Since(start).Milliseconds())
+
+	// The client may disconnect once it has the response; record regardless
+	if err := h.service.Record(context.WithoutCancel(r.Context()), project, call); err != nil {
+		slog.Warn("failed to record proxied call", "project_id", project.ID, "error", err)
+	}
+}
@@ -0,0 +1,328 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lelemon/server/pkg/application/proxy"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+)
+
+const stubCompletion = `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"gpt-4o-2024-08-06",` +
+	`"choices":[{"index":0,"message":{"role":"assistant","content":"Hi there!"},"finish_reason":"stop"}],` +
+	`"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`
+
+var stubStream = []string{
+	`{"id":"chatcmpl-2","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-2","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-2","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"content":", world"},"finish_reason":null}]}`,
+	`{"id":"chatcmpl-2","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	`{"id":"chatcmpl-2","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":20,"completion_tokens":4,"total_tokens":24}}`,
+}
+
+// stubOpenAI is a fake OpenAI chat completions API that records the headers
+// of the requests it receives
+type stubOpenAI struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func newStubOpenAI(t *testing.T) *stubOpenAI {
+	stub := &stubOpenAI{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		stub.headers = append(stub.headers, r.Header.Clone())
+		stub.mu.Unlock()
+
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`)
+			return
+		}
+
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-Id", "req_upstream")
+			w.Header().Set("X-Ratelimit-Remaining-Requests", "499")
+			w.Header().Set("Anthropic-Ratelimit-Requests-Remaining", "49")
+			w.Header().Set("X-Upstream-Debug", "pod-7")
+			w.Header().Set("Set-Cookie", "upstream_session=1")
+			io.WriteString(w, stubCompletion)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range stubStream {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *stubOpenAI) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[len(s.headers)-1]
+}
+
+func TestOpenAIProxy(t *testing.T) {
+	upstream := newStubOpenAI(t)
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.ProxySvc = proxy.NewService(cfg.IngestSvc, upstream.URL)
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "proxy@example.com", "password": "SecurePass123", "name": "Proxy User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Proxy Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+	messages := []map[string]string{{"role": "user", "content": "Say hi"}}
+
+	proxyHeaders := func(traceID string) map[string]string {
+		return map[string]string{
+			"Authorization":            "Bearer " + project.APIKey,
+			"X-Upstream-Authorization": "Bearer sk-test",
+			"X-Lelemon-Trace-Id":       traceID,
+		}
+	}
+	recordedSpan := func(t *testing.T, traceID string) map[string]any {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("get trace: expected 200, got %d", resp.StatusCode)
+		}
+		var trace struct {
+			Spans []map[string]any `json:"Spans"`
+		}
+		ParseJSON(t, resp, &trace)
+		if len(trace.Spans) != 1 {
+			t.Fatalf("expected 1 recorded span, got %d", len(trace.Spans))
+		}
+		return trace.Spans[0]
+	}
+
+	t.Run("forwards and records a completion", func(t *testing.T) {
+		traceID := "8d1a4f3e-0000-4000-8000-000000000001"
+		resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{
+			"model": "gpt-4o", "messages": messages,
+		}, proxyHeaders(traceID))
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+		}
+		if string(body) != stubCompletion {
+			t.Errorf("expected the upstream body verbatim, got %s", body)
+		}
+		if resp.Header.Get("X-Request-Id") != "req_upstream" {
+			t.Errorf("expected upstream headers to be relayed, got %v", resp.Header)
+		}
+
+		// The project key and proxy headers stay with lelemon
+		sent := upstream.lastHeader()
+		if sent.Get("X-Upstream-Authorization") != "" || sent.Get("X-Lelemon-Trace-Id") != "" {
+			t.Errorf("proxy headers leaked upstream: %v", sent)
+		}
+
+		span := recordedSpan(t, traceID)
+		if span["Type"] != "llm" || span["Provider"] != "openai" || span["Model"] != "gpt-4o" || span["Status"] != "success" {
+			t.Errorf("unexpected span: %v", span)
+		}
+		if span["InputTokens"] != float64(12) || span["OutputTokens"] != float64(3) || span["Output"] != "Hi there!" {
+			t.Errorf("expected tokens and output from the response, got %v", span)
+		}
+		if span["CostUSD"] == nil || span["CostUSD"].(float64) <= 0 {
+			t.Errorf("expected the call to be priced, got %v", span["CostUSD"])
+		}
+	})
+
+	t.Run("relays and records a stream", func(t *testing.T) {
+		traceID := "8d1a4f3e-0000-4000-8000-000000000002"
+		resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{
+			"model": "gpt-4o", "messages": messages, "stream": true,
+			"stream_options": map[string]any{"include_usage": true},
+		}, proxyHeaders(traceID))
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+		}
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			t.Errorf("expected an event stream, got %q", resp.Header.Get("Content-Type"))
+		}
+		if strings.Count(string(body), "data: ") != len(stubStream)+1 || !strings.HasSuffix(string(body), "data: [DONE]\n\n") {
+			t.Errorf("expected every upstream chunk relayed, got %s", body)
+		}
+
+		span := recordedSpan(t, traceID)
+		if span["Output"] != "Hello, world" || span["StopReason"] != "stop" {
+			t.Errorf("expected the assembled output, got %v", span)
+		}
+		if span["InputTokens"] != float64(20) || span["OutputTokens"] != float64(4) {
+			t.Errorf("expected usage from the final chunk, got %v / %v", span["InputTokens"], span["OutputTokens"])
+		}
+		if span["FirstTokenMs"] == nil {
+			t.Error("expected time to first token for a stream")
+		}
+	})
+
+	t.Run("returns upstream errors verbatim and records them", func(t *testing.T) {
+		traceID := "8d1a4f3e-0000-4000-8000-000000000003"
+		headers := proxyHeaders(traceID)
+		headers["X-Upstream-Authorization"] = "Bearer sk-wrong"
+		resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{
+			"model": "gpt-4o", "messages": messages,
+		}, headers)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(string(body), "Incorrect API key") {
+			t.Fatalf("expected the upstream 401, got %d: %s", resp.StatusCode, body)
+		}
+
+		span := recordedSpan(t, traceID)
+		if span["Status"] != "error" || span["ErrorMessage"] != "Incorrect API key provided" {
+			t.Errorf("expected an error span, got %v", span)
+		}
+	})
+
+	t.Run("requires a project API key", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{
+			"model": "gpt-4o", "messages": messages,
+		}, map[string]string{"Authorization": "Bearer sk-test"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestOpenAIProxyDisabledByDefault(t *testing.T) {
+	ts := setupTestServer(t)
+
+	resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{"model": "gpt-4o"}, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 when the proxy is not enabled, got %d", resp.StatusCode)
+	}
+}
+
+func TestOpenAIProxyHeaderAllowlists(t *testing.T) {
+	upstream := newStubOpenAI(t)
+	var svc *proxy.Service
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		svc = proxy.NewService(cfg.IngestSvc, upstream.URL)
+		cfg.ProxySvc = svc
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "proxyheaders@example.com", "password": "SecurePass123", "name": "Proxy Headers User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Proxy Headers Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	// call sends a completion with provider-specific and unrelated headers
+	// and returns the upstream response
+	call := func(t *testing.T) *http.Response {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/proxy/openai/v1/chat/completions", map[string]any{
+			"model": "gpt-4o", "messages": []map[string]string{{"role": "user", "content": "Say hi"}},
+		}, map[string]string{
+			"Authorization":            "Bearer " + project.APIKey,
+			"X-Upstream-Authorization": "Bearer sk-test",
+			"OpenAI-Organization":      "org-123",
+			"Anthropic-Beta":           "prompt-caching-2024-07-31",
+			"X-Internal-User":          "alice",
+			"Cookie":                   "session=secret",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		return resp
+	}
+	expect := func(t *testing.T, header http.Header, name string, passed bool) {
+		t.Helper()
+		if got := header.Get(name); (got != "") != passed {
+			if passed {
+				t.Errorf("expected %s to pass through", name)
+			} else {
+				t.Errorf("expected %s to be stripped, got %q", name, got)
+			}
+		}
+	}
+
+	t.Run("default allowlists", func(t *testing.T) {
+		resp := call(t)
+		sent := upstream.lastHeader()
+		expect(t, sent, "OpenAI-Organization", true)
+		expect(t, sent, "Content-Type", true)
+		expect(t, sent, "Anthropic-Beta", false)
+		expect(t, sent, "X-Internal-User", false)
+		expect(t, sent, "Cookie", false)
+
+		if resp.Header.Get("X-Request-Id") != "req_upstream" {
+			t.Errorf("expected the upstream X-Request-Id, got %q", resp.Header.Get("X-Request-Id"))
+		}
+		expect(t, resp.Header, "X-Ratelimit-Remaining-Requests", true)
+		expect(t, resp.Header, "Anthropic-Ratelimit-Requests-Remaining", false)
+		expect(t, resp.Header, "X-Upstream-Debug", false)
+		expect(t, resp.Header, "Set-Cookie", false)
+	})
+
+	t.Run("configured allowlists", func(t *testing.T) {
+		svc.SetHeaderAllowlists(
+			[]string{"content-type", "anthropic-beta", "X-Lelemon-Trace-Id", "Cookie"},
+			[]string{"Content-Type", "anthropic-ratelimit-*"},
+		)
+		resp := call(t)
+		sent := upstream.lastHeader()
+		expect(t, sent, "Anthropic-Beta", true)
+		expect(t, sent, "Content-Type", true)
+		expect(t, sent, "OpenAI-Organization", false)
+		expect(t, sent, "X-Internal-User", false)
+		// The proxy's own headers are never forwarded, even when listed
+		expect(t, sent, "Cookie", false)
+		if sent.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("expected the upstream credentials as Authorization, got %q", sent.Get("Authorization"))
+		}
+
+		expect(t, resp.Header, "Anthropic-Ratelimit-Requests-Remaining", true)
+		if resp.Header.Get("X-Request-Id") == "req_upstream" {
+			t.Error("expected the upstream X-Request-Id to be stripped")
+		}
+		expect(t, resp.Header, "X-Ratelimit-Remaining-Requests", false)
+	})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/lelemon/server/pkg/application/analytics"
+	"github.com/lelemon/server/pkg/application/project"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// PublicHandler serves unauthenticated, aggregate-only project data
+type PublicHandler struct {
+	projectSvc   *project.Service
+	analyticsSvc *analytics.Service
+}
+
+// NewPublicHandler creates a new public handler
+func NewPublicHandler(projectSvc *project.Service, analyticsSvc *analytics.Service) *PublicHandler {
+	return &PublicHandler{
+		projectSvc:   projectSvc,
+		analyticsSvc: analyticsSvc,
+	}
+}
+
+// Metrics handles GET /api/v1/public/projects/{id}/metrics
+// Request volume, error rate and p95 latency over ?preset= (default last_24h),
+// for status pages. Projects that haven't enabled publicMetricsEnabled 404
+// exactly like unknown ones, so IDs can't be probed.
+func (h *PublicHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(projectID); err != nil {
+		apierror.Write(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	enabled, err := h.projectSvc.HasPublicMetrics(r.Context(), projectID)
+	if err != nil {
+		apierror.FromError(w, err, "Not found")
+		return
+	}
+	if !enabled {
+		apierror.Write(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	period, ok := parsePreset(w, r)
+	if !ok {
+		return
+	}
+	if period == nil {
+		last24h, _ := analytics.ResolvePreset(analytics.PresetLast24h, time.Now(), time.UTC)
+		period = &last24h
+	}
+
+	metrics, err := h.analyticsSvc.GetPublicMetrics(r.Context(), projectID, *period)
+	if err != nil {
+		apierror.FromError(w, err, "Not found")
+		return
+	}
+
+	// Embeddable from any status page; the body is public and cacheable
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Del("Access-Control-Allow-Credentials")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, metrics)
+}
@@ -0,0 +1,98 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPublicMetrics(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "publicmetrics@example.com", "password": "SecurePass123", "name": "Status Page User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Public Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		{"traceId": "public-ok", "spanType": "llm", "name": "chat", "status": "success", "durationMs": 100,
+			"input": "my secret prompt", "userId": "user-secret-42"},
+		{"traceId": "public-err", "spanType": "llm", "name": "chat", "status": "error", "durationMs": 300,
+			"errorMessage": "upstream failed"},
+	}}, map[string]string{"Authorization": "Bearer " + project.APIKey})
+	resp.Body.Close()
+
+	metricsPath := "/api/v1/public/projects/" + project.ID + "/metrics"
+	setEnabled := func(t *testing.T, enabled bool) {
+		t.Helper()
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"publicMetricsEnabled": enabled},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		resp := ts.Request("GET", metricsPath, nil, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("enabled serves aggregates without auth", func(t *testing.T) {
+		setEnabled(t, true)
+
+		resp := ts.Request("GET", metricsPath, nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		for _, secret := range []string{"my secret prompt", "user-secret-42", "upstream failed", "chat"} {
+			if strings.Contains(string(body), secret) {
+				t.Errorf("public metrics leak %q: %s", secret, body)
+			}
+		}
+
+		var metrics struct {
+			Requests     int     `json:"requests"`
+			ErrorRate    float64 `json:"errorRate"`
+			P95LatencyMs int     `json:"p95LatencyMs"`
+		}
+		resp = ts.Request("GET", metricsPath, nil, nil)
+		ParseJSON(t, resp, &metrics)
+		if metrics.Requests != 2 || metrics.ErrorRate != 50 || metrics.P95LatencyMs != 300 {
+			t.Errorf("unexpected metrics: %+v", metrics)
+		}
+	})
+
+	t.Run("disabled again", func(t *testing.T) {
+		setEnabled(t, false)
+
+		resp := ts.Request("GET", metricsPath, nil, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		for _, id := range []string{"00000000-0000-0000-0000-000000000000", "not-a-uuid"} {
+			resp := ts.Request("GET", "/api/v1/public/projects/"+id+"/metrics", nil, nil)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", id, resp.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package handler_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+func TestRateLimitPolicies(t *testing.T) {
+	policies, err := middleware.ParseRateLimitPolicies([]string{
+		"analytics /api/v1/analytics/* 2/1m key=project",
+		"traces /api/v1/traces,/api/v1/traces/{id} 4/1m key=project",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse policies: %v", err)
+	}
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.RateLimits = policies
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "ratelimit@example.com", "password": "SecurePass123", "name": "Rate Limit User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	createProject := func(name string) map[string]string {
+		resp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": name}, jwtHeaders)
+		var project ProjectResponse
+		ParseJSON(t, resp, &project)
+		return map[string]string{"Authorization": "Bearer " + project.APIKey}
+	}
+	first := createProject("Rate Limited")
+	second := createProject("Rate Limited Too")
+
+	// get requests path n times and returns the last response's status and Retry-After
+	get := func(path string, headers map[string]string, n int) (int, string) {
+		var resp *http.Response
+		for i := 0; i < n; i++ {
+			resp = ts.Request("GET", path, nil, headers)
+			resp.Body.Close()
+		}
+		return resp.StatusCode, resp.Header.Get("Retry-After")
+	}
+	// retryAfterWithin checks Retry-After is the wait for the next token
+	retryAfterWithin := func(t *testing.T, retryAfter string, max time.Duration) {
+		t.Helper()
+		seconds, err := strconv.Atoi(retryAfter)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > max {
+			t.Errorf("expected Retry-After of 1s to %v, got %q", max, retryAfter)
+		}
+	}
+
+	t.Run("analytics allows 2 per minute", func(t *testing.T) {
+		if status, _ := get("/api/v1/analytics/summary", first, 2); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d", status)
+		}
+		// Another analytics route shares the bucket
+		resp := ts.Request("GET", "/api/v1/analytics/models", nil, first)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", resp.StatusCode)
+		}
+		retryAfterWithin(t, resp.Header.Get("Retry-After"), 30*time.Second)
+		var envelope apierror.Envelope
+		ParseJSON(t, resp, &envelope)
+		if envelope.Error.Code != apierror.CodeRateLimited {
+			t.Errorf("expected %s, got %s", apierror.CodeRateLimited, envelope.Error.Code)
+		}
+	})
+
+	t.Run("traces allow 4 per minute", func(t *testing.T) {
+		if status, _ := get("/api/v1/traces", first, 4); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d", status)
+		}
+		status, retryAfter := get("/api/v1/traces", first, 1)
+		if status != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", status)
+		}
+		retryAfterWithin(t, retryAfter, 15*time.Second)
+	})
+
+	t.Run("counted per project", func(t *testing.T) {
+		if status, _ := get("/api/v1/analytics/summary", second, 2); status != http.StatusOK {
+			t.Errorf("expected another project's requests to pass, got %d", status)
+		}
+	})
+
+	t.Run("routes without a policy of their own keep the default", func(t *testing.T) {
+		if status, _ := get("/api/v1/sessions", first, 5); status != http.StatusOK {
+			t.Errorf("expected the default api limit to allow 5 requests, got %d", status)
+		}
+	})
+
+	t.Run("dashboard sessions are not limited per project", func(t *testing.T) {
+		if status, _ := get("/api/v1/dashboard/projects", jwtHeaders, 5); status != http.StatusOK {
+			t.Errorf("expected 200, got %d", status)
+		}
+	})
+}
+
+func TestParseRateLimitPolicies(t *testing.T) {
+	policies, err := middleware.ParseRateLimitPolicies([]string{
+		"ingest /api/v1/ingest,/api/v1/otlp/* 5000/1m burst=1000 key=project",
+		"auth /api/v1/auth/login 5/10s",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	want := []middleware.RateLimitPolicy{
+		{Name: "ingest", Routes: []string{"/api/v1/ingest", "/api/v1/otlp/*"}, Limit: 5000, Period: time.Minute, Burst: 1000, Key: middleware.RateLimitKeyProject},
+		{Name: "auth", Routes: []string{"/api/v1/auth/login"}, Limit: 5, Period: 10 * time.Second, Key: middleware.RateLimitKeyIP},
+	}
+	for i, p := range policies {
+		w := want[i]
+		if p.Name != w.Name || len(p.Routes) != len(w.Routes) || p.Limit != w.Limit ||
+			p.Period != w.Period || p.Burst != w.Burst || p.Key != w.Key {
+			t.Errorf("policy %d: expected %+v, got %+v", i, w, p)
+		}
+	}
+
+	for _, spec := range []string{
+		"missing-limit /api/v1/traces",
+		"bad-limit /api/v1/traces many/1m",
+		"bad-period /api/v1/traces 10/soon",
+		"bad-key /api/v1/traces 10/1m key=session",
+		"bad-option /api/v1/traces 10/1m window=1m",
+	} {
+		if _, err := middleware.ParseRateLimitPolicies([]string{spec}); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+
+	merged := middleware.MergeRateLimitPolicies(policies, middleware.DefaultRateLimitPolicies())
+	names := make(map[string]int)
+	for _, p := range merged {
+		names[p.Name]++
+	}
+	if names["ingest"] != 1 || names["auth"] != 1 || names["public"] != 1 || names["api"] != 1 {
+		t.Errorf("expected configured policies to replace the defaults of the same name, got %v", names)
+	}
+	if merged[0].Name != "ingest" || merged[0].Limit != 5000 {
+		t.Errorf("expected the configured ingest policy first, got %+v", merged[0])
+	}
+}
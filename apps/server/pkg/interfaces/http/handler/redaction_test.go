@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPIIRedaction(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "redact@example.com", "password": "SecurePass123", "name": "Redact User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	authHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Redact Project",
+	}, authHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	messages := func() []map[string]any {
+		return []map[string]any{
+			{"role": "system", "content": "You are a support agent."},
+			{"role": "user", "content": "I'm jane@example.com, customer ACCT-123456. Why was I charged twice?"},
+		}
+	}
+	ingestTrace := func(traceID string) map[string]any {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+			"events": []map[string]any{{
+				"traceId": traceID, "spanId": traceID + "-llm", "spanType": "llm",
+				"provider": "openai", "model": "gpt-4o", "status": "success",
+				"input":  messages(),
+				"output": "I've emailed jane@example.com a refund confirmation.",
+			}},
+		}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		var trace map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders), &trace)
+		return trace
+	}
+
+	t.Run("stores data as sent by default", func(t *testing.T) {
+		span := ingestTrace("redact-off")["Spans"].([]any)[0].(map[string]any)
+		if span["Output"] != "I've emailed jane@example.com a refund confirmation." {
+			t.Errorf("expected output unchanged without redaction, got %v", span["Output"])
+		}
+	})
+
+	resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"redaction": map[string]any{
+			"enabled": true, "patterns": []string{`ACCT-\d{6}`},
+		}},
+	}, authHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("enable redaction: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("email in a prompt is redacted and structure preserved", func(t *testing.T) {
+		trace := ingestTrace("redact-on")
+		span := trace["Spans"].([]any)[0].(map[string]any)
+
+		want := []any{
+			map[string]any{"role": "system", "content": "You are a support agent."},
+			map[string]any{"role": "user", "content": "I'm [REDACTED], customer [REDACTED]. Why was I charged twice?"},
+		}
+		if !reflect.DeepEqual(span["Input"], want) {
+			t.Errorf("unexpected span input:\n got %v\nwant %v", span["Input"], want)
+		}
+		if span["Output"] != "I've emailed [REDACTED] a refund confirmation." {
+			t.Errorf("expected output redacted, got %v", span["Output"])
+		}
+
+		// The trace keeps a copy of its first event's input
+		md, _ := trace["Metadata"].(map[string]any)
+		if !reflect.DeepEqual(md["input"], want) {
+			t.Errorf("expected trace input redacted, got %v", md["input"])
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequireSessionID(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "sessionrequired@example.com", "password": "SecurePass123", "name": "Session Required User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Session Required Project"}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	setRequirement := func(t *testing.T, requirement string) {
+		t.Helper()
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"requireSessionId": requirement},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	type ingestError struct {
+		Index   int    `json:"index"`
+		Message string `json:"message"`
+	}
+	type ingestResult struct {
+		Processed int           `json:"processed"`
+		Errors    []ingestError `json:"errors"`
+		Warnings  []ingestError `json:"warnings"`
+	}
+	// ingest sends one event with a sessionId and one without, plus a
+	// trace.end without one, which is exempt
+	ingest := func(t *testing.T, prefix string, wantStatus int) ingestResult {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+			"events": []map[string]any{
+				{"traceId": prefix + "-with", "spanId": prefix + "-with-span", "sessionId": "conv-1", "spanType": "tool", "name": "lookup", "status": "success"},
+				{"traceId": prefix + "-without", "spanId": prefix + "-without-span", "spanType": "tool", "name": "lookup", "status": "success"},
+				{"type": "trace.end", "traceId": prefix + "-with"},
+			},
+		}, apiKeyHeaders)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("ingest: expected %d, got %d", wantStatus, resp.StatusCode)
+		}
+		var result ingestResult
+		ParseJSON(t, resp, &result)
+		return result
+	}
+	traceStatus := func(traceID string) int {
+		resp := ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("off accepts events without a session", func(t *testing.T) {
+		result := ingest(t, "session-off", http.StatusOK)
+		if result.Processed != 3 || len(result.Errors) != 0 || len(result.Warnings) != 0 {
+			t.Errorf("expected all events accepted without warnings, got %+v", result)
+		}
+	})
+
+	t.Run("warn ingests them with a warning", func(t *testing.T) {
+		setRequirement(t, "warn")
+		result := ingest(t, "session-warn", http.StatusOK)
+		if result.Processed != 3 || len(result.Errors) != 0 {
+			t.Errorf("expected all events accepted, got %+v", result)
+		}
+		if len(result.Warnings) != 1 || result.Warnings[0].Index != 1 || result.Warnings[0].Message != "missing sessionId" {
+			t.Errorf("expected a warning for event 1, got %+v", result.Warnings)
+		}
+		if status := traceStatus("session-warn-without"); status != http.StatusOK {
+			t.Errorf("expected the sessionless trace stored, got %d", status)
+		}
+	})
+
+	t.Run("reject rejects them per event", func(t *testing.T) {
+		setRequirement(t, "reject")
+		result := ingest(t, "session-reject", http.StatusMultiStatus)
+		if result.Processed != 2 || len(result.Warnings) != 0 {
+			t.Errorf("expected the other events accepted, got %+v", result)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Index != 1 || result.Errors[0].Message != "missing sessionId" {
+			t.Errorf("expected event 1 rejected, got %+v", result.Errors)
+		}
+		if status := traceStatus("session-reject-without"); status != http.StatusNotFound {
+			t.Errorf("expected the sessionless trace not stored, got %d", status)
+		}
+		if status := traceStatus("session-reject-with"); status != http.StatusOK {
+			t.Errorf("expected the session's trace stored, got %d", status)
+		}
+	})
+
+	t.Run("unknown requirement is rejected", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+			"settings": map[string]any{"requireSessionId": "always"},
+		}, jwtHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// SessionStatsResponse for parsing the session analytics
+type SessionStatsResponse struct {
+	Data struct {
+		Sessions            int     `json:"Sessions"`
+		AvgTracesPerSession float64 `json:"AvgTracesPerSession"`
+		AvgCostUSD          float64 `json:"AvgCostUSD"`
+		AvgDurationMs       int     `json:"AvgDurationMs"`
+		NewSessions         []struct {
+			Time        string `json:"Time"`
+			NewSessions int    `json:"NewSessions"`
+		} `json:"NewSessions"`
+		Currency string `json:"Currency"`
+	} `json:"data"`
+}
+
+func TestSessionStats(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "sessions@example.com", "password": "SecurePass123", "name": "Sessions User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Sessions Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	event := func(traceID, sessionID string, cost float64) map[string]any {
+		return map[string]any{
+			"traceId": traceID, "spanId": traceID + "-span", "sessionId": sessionID,
+			"spanType": "llm", "status": "success", "costUsd": cost,
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			event("sess-a-1", "sess-a", 0.5),
+			event("sess-a-2", "sess-a", 0.25),
+			event("sess-a-3", "sess-a", 0.75),
+			event("sess-b-1", "sess-b", 1),
+			event("sess-b-2", "sess-b", 2),
+			event("sess-c-1", "sess-c", 0.5),
+			// Not part of a session
+			map[string]any{"traceId": "no-session", "spanType": "llm", "status": "success", "costUsd": 10},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = ts.Request("GET", "/api/v1/analytics/sessions", nil, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var stats SessionStatsResponse
+	ParseJSON(t, resp, &stats)
+
+	if stats.Data.Sessions != 3 {
+		t.Errorf("expected 3 sessions, got %d", stats.Data.Sessions)
+	}
+	if math.Abs(stats.Data.AvgTracesPerSession-2) > 1e-9 {
+		t.Errorf("expected 2 traces per session, got %v", stats.Data.AvgTracesPerSession)
+	}
+	if math.Abs(stats.Data.AvgCostUSD-5.0/3) > 1e-9 {
+		t.Errorf("expected avg cost %v, got %v", 5.0/3, stats.Data.AvgCostUSD)
+	}
+	if stats.Data.AvgDurationMs < 0 {
+		t.Errorf("expected a non-negative duration, got %d", stats.Data.AvgDurationMs)
+	}
+	if stats.Data.Currency != "USD" {
+		t.Errorf("expected USD, got %q", stats.Data.Currency)
+	}
+	newSessions := 0
+	for _, dp := range stats.Data.NewSessions {
+		newSessions += dp.NewSessions
+	}
+	if newSessions != 3 {
+		t.Errorf("expected 3 new sessions, got %+v", stats.Data.NewSessions)
+	}
+
+	t.Run("requires an API key", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/sessions", nil, nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	})
+}
@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestSpanAttributeSearch(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "attributes@example.com", "password": "SecurePass123", "name": "Attribute User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Attribute Project",
+	}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{"indexedAttributes": []string{"customerId", "tier", "beta", "context"}},
+	}, jwtHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	customers := []string{"acme", "globex", "initech"}
+	var events []map[string]any
+	for i := 0; i < 9; i++ {
+		events = append(events, map[string]any{
+			"traceId":  fmt.Sprintf("attr-trace-%d", i%3),
+			"spanId":   fmt.Sprintf("attr-span-%d", i),
+			"spanType": "tool",
+			"name":     fmt.Sprintf("step-%d", i%2),
+			"status":   "success",
+			"metadata": map[string]any{
+				"customerId": customers[i%3],
+				"tier":       i % 2,
+				"beta":       i%4 == 0,
+				"context":    map[string]any{"nested": "value"}, // not a scalar: never indexed
+				"region":     "eu",                              // not in the allowlist
+			},
+		})
+	}
+	resp = ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	search := func(t *testing.T, body map[string]any) []string {
+		t.Helper()
+		body["limit"] = 100
+		resp := ts.Request("POST", "/api/v1/spans/search", body, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("search %v: expected 200, got %d", body, resp.StatusCode)
+		}
+		var page SpanSearchResponse
+		ParseJSON(t, resp, &page)
+		if page.Total != len(page.Data) {
+			t.Errorf("search %v: total %d but %d spans returned", body, page.Total, len(page.Data))
+		}
+		ids := make([]string, 0, len(page.Data))
+		for _, sp := range page.Data {
+			ids = append(ids, sp.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	t.Run("attribute index matches the metadata JSON path", func(t *testing.T) {
+		cases := []struct {
+			key, value string
+			want       int
+		}{
+			{"customerId", "acme", 3},
+			{"customerId", "unknown", 0},
+			{"tier", "1", 4},
+			{"beta", "true", 3},
+			{"beta", "false", 6},
+		}
+		for _, tc := range cases {
+			indexed := search(t, map[string]any{"attribute": map[string]string{"key": tc.key, "value": tc.value}})
+			extracted := search(t, map[string]any{"metadata": map[string]string{tc.key: tc.value}})
+			if !slices.Equal(indexed, extracted) {
+				t.Errorf("%s=%s: indexed %v, extracted %v", tc.key, tc.value, indexed, extracted)
+			}
+			if len(indexed) != tc.want {
+				t.Errorf("%s=%s: expected %d spans, got %d", tc.key, tc.value, tc.want, len(indexed))
+			}
+		}
+	})
+
+	t.Run("attribute combines with the other filters", func(t *testing.T) {
+		indexed := search(t, map[string]any{"name": "step-0", "attribute": map[string]string{"key": "customerId", "value": "globex"}})
+		extracted := search(t, map[string]any{"name": "step-0", "metadata": map[string]string{"customerId": "globex"}})
+		if len(indexed) == 0 || !slices.Equal(indexed, extracted) {
+			t.Errorf("indexed %v, extracted %v", indexed, extracted)
+		}
+	})
+
+	t.Run("only allowlisted scalar keys are indexed", func(t *testing.T) {
+		if ids := search(t, map[string]any{"attribute": map[string]string{"key": "region", "value": "eu"}}); len(ids) != 0 {
+			t.Errorf("expected no spans for a key outside the allowlist, got %v", ids)
+		}
+		if ids := search(t, map[string]any{"metadata": map[string]string{"region": "eu"}}); len(ids) != 9 {
+			t.Errorf("expected the JSON path to still find every span, got %d", len(ids))
+		}
+		if ids := search(t, map[string]any{"attribute": map[string]string{"key": "context", "value": `{"nested":"value"}`}}); len(ids) != 0 {
+			t.Errorf("expected object values not to be indexed, got %v", ids)
+		}
+	})
+
+	t.Run("keeps the full metadata", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/attr-trace-0", nil, apiKeyHeaders)
+		var trace struct {
+			Spans []struct {
+				Metadata map[string]any `json:"Metadata"`
+			} `json:"Spans"`
+		}
+		ParseJSON(t, resp, &trace)
+		if len(trace.Spans) == 0 || trace.Spans[0].Metadata["region"] != "eu" || trace.Spans[0].Metadata["context"] == nil {
+			t.Errorf("expected the metadata to be stored as sent, got %+v", trace.Spans)
+		}
+	})
+
+	t.Run("rejects an attribute without a key", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"attribute": map[string]string{"value": "acme"}}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+}
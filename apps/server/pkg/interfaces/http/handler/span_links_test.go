@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSpanLinks(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "links@example.com", "password": "SecurePass123", "name": "Links User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Links Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	type link struct {
+		TraceID    string         `json:"traceId"`
+		SpanID     string         `json:"spanId"`
+		Attributes map[string]any `json:"attributes"`
+	}
+	want := []link{
+		{TraceID: "links-producer", SpanID: "links-enqueue", Attributes: map[string]any{"messaging.message.id": "m-1"}},
+		{TraceID: "links-batch", SpanID: "links-previous-run"}, // no traceId: points into the span's own trace
+	}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			{"traceId": "links-batch", "spanId": "links-previous-run", "spanType": "tool", "name": "previous-run", "status": "success"},
+			{
+				"traceId": "links-batch", "spanId": "links-consume", "spanType": "tool", "name": "consume", "status": "success",
+				"links": []map[string]any{
+					{"traceId": "links-producer", "spanId": "links-enqueue", "attributes": map[string]any{"messaging.message.id": "m-1"}},
+					{"spanId": "links-previous-run"},
+					{"traceId": "links-producer"}, // no spanId: dropped
+				},
+			},
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("trace returns the span's links", func(t *testing.T) {
+		var trace struct {
+			Spans []struct {
+				ID    string
+				Links []link `json:"links"`
+			}
+		}
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/links-batch", nil, apiKeyHeaders), &trace)
+		if len(trace.Spans) != 2 {
+			t.Fatalf("expected 2 spans, got %d", len(trace.Spans))
+		}
+		for _, span := range trace.Spans {
+			switch span.ID {
+			case "links-consume":
+				if !reflect.DeepEqual(span.Links, want) {
+					t.Errorf("expected links %+v, got %+v", want, span.Links)
+				}
+			case "links-previous-run":
+				if len(span.Links) != 0 {
+					t.Errorf("expected no links, got %+v", span.Links)
+				}
+			}
+		}
+	})
+
+	t.Run("span detail returns the span's links", func(t *testing.T) {
+		var detail struct {
+			SpanTree []struct {
+				Span struct {
+					ID    string `json:"id"`
+					Links []link `json:"links"`
+				} `json:"span"`
+			} `json:"spanTree"`
+		}
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/links-batch/detail", nil, apiKeyHeaders), &detail)
+		found := false
+		for _, node := range detail.SpanTree {
+			if node.Span.ID == "links-consume" {
+				found = true
+				if !reflect.DeepEqual(node.Span.Links, want) {
+					t.Errorf("expected links %+v, got %+v", want, node.Span.Links)
+				}
+			}
+		}
+		if !found {
+			t.Fatal("expected the consume span in the span tree")
+		}
+	})
+
+	t.Run("added spans take links", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/links-batch/spans", map[string]any{
+			"type": "tool", "name": "retry", "status": "success",
+			"links": []map[string]any{{"spanId": "links-consume"}, {"traceId": "links-producer", "spanId": "links-enqueue"}},
+		}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		var span struct {
+			Links []link `json:"links"`
+		}
+		ParseJSON(t, resp, &span)
+		added := []link{{TraceID: "links-batch", SpanID: "links-consume"}, {TraceID: "links-producer", SpanID: "links-enqueue"}}
+		if !reflect.DeepEqual(span.Links, added) {
+			t.Errorf("expected links %+v, got %+v", added, span.Links)
+		}
+	})
+
+	t.Run("OTLP exemplars become links", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", ts.URL+"/api/v1/otlp/v1/metrics", bytes.NewBufferString(`{
+		  "resourceMetrics": [{"scopeMetrics": [{"metrics": [{
+		    "name": "gen_ai.client.token.usage",
+		    "sum": {"aggregationTemporality": 1, "dataPoints": [
+		      {"timeUnixNano": "1700000000000000000", "asInt": "40", "attributes": [
+		        {"key": "gen_ai.request.model", "value": {"stringValue": "links-model"}},
+		        {"key": "gen_ai.token.type", "value": {"stringValue": "input"}}],
+		       "exemplars": [
+		        {"traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b174", "asInt": "40",
+		         "filteredAttributes": [{"key": "server.address", "value": {"stringValue": "api.openai.com"}}]}]},
+		      {"timeUnixNano": "1700000000000000000", "asInt": "10", "attributes": [
+		        {"key": "gen_ai.request.model", "value": {"stringValue": "links-model"}},
+		        {"key": "gen_ai.token.type", "value": {"stringValue": "output"}}],
+		       "exemplars": [
+		        {"traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b174", "asInt": "10"},
+		        {"traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b175", "asInt": "2"}]}
+		    ]}
+		  }]}]}]
+		}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+project.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+
+		var page struct {
+			Data []struct {
+				Links []link `json:"links"`
+			}
+		}
+		ParseJSON(t, ts.Request("POST", "/api/v1/spans/search", map[string]any{"model": "links-model"}, apiKeyHeaders), &page)
+		if len(page.Data) != 1 {
+			t.Fatalf("expected 1 usage span, got %d", len(page.Data))
+		}
+		linked := []link{
+			{TraceID: "5b8efff798038103d269b633813fc60c", SpanID: "eee19b7ec3c1b174", Attributes: map[string]any{"server.address": "api.openai.com"}},
+			{TraceID: "5b8efff798038103d269b633813fc60c", SpanID: "eee19b7ec3c1b175"},
+		}
+		if !reflect.DeepEqual(page.Data[0].Links, linked) {
+			t.Errorf("expected links %+v, got %+v", linked, page.Data[0].Links)
+		}
+	})
+}
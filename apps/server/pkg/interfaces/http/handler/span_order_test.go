@@ -0,0 +1,90 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSpanOrder_IdenticalTimestamps(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "spanorder@example.com", "password": "SecurePass123", "name": "Span Order User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Span Order Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+	timestamp := time.Now().Add(-time.Hour).Format(time.RFC3339Nano)
+
+	getSpanIDs := func(t *testing.T, traceID string) []string {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("get trace: expected 200, got %d", resp.StatusCode)
+		}
+		var trace struct {
+			Spans []struct {
+				ID string `json:"ID"`
+			} `json:"Spans"`
+		}
+		ParseJSON(t, resp, &trace)
+		ids := make([]string, len(trace.Spans))
+		for i, sp := range trace.Spans {
+			ids[i] = sp.ID
+		}
+		return ids
+	}
+
+	t.Run("batch order", func(t *testing.T) {
+		// IDs sort in the reverse of emission order, so ordering by ID
+		// alone would reverse the trace
+		const spanCount = 20
+		var events []map[string]any
+		var want []string
+		for i := 0; i < spanCount; i++ {
+			id := fmt.Sprintf("order-span-%02d", spanCount-i)
+			want = append(want, id)
+			events = append(events, map[string]any{
+				"traceId": "order-trace", "spanId": id,
+				"spanType": "tool", "name": "step", "status": "success",
+				"timestamp": timestamp,
+			})
+		}
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+		}
+
+		for attempt := 0; attempt < 3; attempt++ {
+			got := getSpanIDs(t, "order-trace")
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("expected emission order %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("explicit sequence", func(t *testing.T) {
+		events := []map[string]any{
+			{"traceId": "sequence-trace", "spanId": "seq-a", "sequence": 2, "spanType": "tool", "name": "step", "status": "success", "timestamp": timestamp},
+			{"traceId": "sequence-trace", "spanId": "seq-b", "sequence": 0, "spanType": "tool", "name": "step", "status": "success", "timestamp": timestamp},
+			{"traceId": "sequence-trace", "spanId": "seq-c", "sequence": 1, "spanType": "tool", "name": "step", "status": "success", "timestamp": timestamp},
+		}
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+		resp.Body.Close()
+
+		got := getSpanIDs(t, "sequence-trace")
+		if want := []string{"seq-b", "seq-c", "seq-a"}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected sequence order %v, got %v", want, got)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// SpanSearchResponse for parsing span search results
+type SpanSearchResponse struct {
+	Data []struct {
+		ID      string `json:"ID"`
+		TraceID string `json:"TraceID"`
+		Name    string `json:"Name"`
+		Status  string `json:"Status"`
+	} `json:"Data"`
+	Total  int `json:"Total"`
+	Limit  int `json:"Limit"`
+	Offset int `json:"Offset"`
+}
+
+func TestSpanSearch(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "search@example.com", "password": "SecurePass123", "name": "Search User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Search Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	// 3 failed "fetch-docs" spans in 3 different traces, plus noise that must not match
+	var events []map[string]any
+	for i := 0; i < 3; i++ {
+		events = append(events, map[string]any{
+			"traceId":      fmt.Sprintf("search-trace-%d", i),
+			"spanId":       fmt.Sprintf("search-error-%d", i),
+			"spanType":     "tool",
+			"name":         "fetch-docs",
+			"durationMs":   100,
+			"status":       "error",
+			"errorMessage": "upstream timeout",
+		})
+	}
+	events = append(events,
+		map[string]any{
+			"traceId":    "search-trace-0",
+			"spanId":     "search-ok-0",
+			"spanType":   "tool",
+			"name":       "fetch-docs",
+			"durationMs": 100,
+			"status":     "success",
+		},
+		map[string]any{
+			"traceId":      "search-trace-1",
+			"spanId":       "search-other-0",
+			"spanType":     "tool",
+			"name":         "send-email",
+			"durationMs":   100,
+			"status":       "error",
+			"errorMessage": "smtp refused",
+		},
+	)
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected status 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("filters by name and status with pagination", func(t *testing.T) {
+		seen := map[string]bool{}
+		for offset := 0; offset < 3; offset += 2 {
+			resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{
+				"name": "fetch-docs", "status": "error", "limit": 2, "offset": offset,
+			}, apiKeyHeaders)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode)
+			}
+			var page SpanSearchResponse
+			ParseJSON(t, resp, &page)
+
+			if page.Total != 3 {
+				t.Errorf("expected total 3, got %d", page.Total)
+			}
+			if page.Limit != 2 || page.Offset != offset {
+				t.Errorf("expected limit 2 / offset %d, got %d / %d", offset, page.Limit, page.Offset)
+			}
+			for _, sp := range page.Data {
+				if sp.Name != "fetch-docs" || sp.Status != "error" {
+					t.Errorf("unexpected span %s: name=%s status=%s", sp.ID, sp.Name, sp.Status)
+				}
+				if sp.TraceID == "" {
+					t.Errorf("span %s has no trace ID", sp.ID)
+				}
+				if seen[sp.ID] {
+					t.Errorf("span %s returned on more than one page", sp.ID)
+				}
+				seen[sp.ID] = true
+			}
+		}
+		if len(seen) != 3 {
+			t.Errorf("expected 3 distinct spans across pages, got %d", len(seen))
+		}
+	})
+
+	t.Run("text matches error message", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"text": "SMTP"}, apiKeyHeaders)
+		var page SpanSearchResponse
+		ParseJSON(t, resp, &page)
+
+		if page.Total != 1 || len(page.Data) != 1 || page.Data[0].Name != "send-email" {
+			t.Errorf("expected only the send-email span, got %+v", page)
+		}
+	})
+
+	t.Run("text wildcards match literally", func(t *testing.T) {
+		for _, text := range []string{"%", "_", `\`} {
+			resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"text": text}, apiKeyHeaders)
+			var page SpanSearchResponse
+			ParseJSON(t, resp, &page)
+
+			if page.Total != 0 {
+				t.Errorf("text %q: expected no matches, got %d", text, page.Total)
+			}
+		}
+	})
+
+	t.Run("rejects inverted range", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{
+			"from": "2026-02-01T00:00:00Z", "to": "2026-01-01T00:00:00Z",
+		}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("requires API key", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{}, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", resp.StatusCode)
+		}
+	})
+}
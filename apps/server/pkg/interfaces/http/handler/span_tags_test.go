@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSpanTags(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "spantags@example.com", "password": "SecurePass123", "name": "Span Tags User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Span Tags Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		{"traceId": "span-tags-trace", "spanId": "span-tags-agent", "spanType": "agent", "name": "agent",
+			"status": "success", "tags": []string{"nightly"}},
+		{"traceId": "span-tags-trace", "spanId": "span-tags-slow", "parentSpanId": "span-tags-agent", "spanType": "tool",
+			"name": "fetch", "status": "success", "spanTags": []string{"slow", "regression-test"}},
+		{"traceId": "span-tags-trace", "spanId": "span-tags-fast", "parentSpanId": "span-tags-agent", "spanType": "tool",
+			"name": "lookup", "status": "success", "spanTags": []string{"regression-test"}},
+	}}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected status 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	search := func(t *testing.T, tags ...string) []string {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"tags": tags}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("search: expected status 200, got %d", resp.StatusCode)
+		}
+		var result SpanSearchResponse
+		ParseJSON(t, resp, &result)
+		ids := make([]string, 0, len(result.Data))
+		for _, sp := range result.Data {
+			ids = append(ids, sp.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("search by span tag", func(t *testing.T) {
+		if got, want := search(t, "slow"), []string{"span-tags-slow"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if got, want := search(t, "regression-test"), []string{"span-tags-fast", "span-tags-slow"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		// Trace tags are not span tags
+		if got := search(t, "nightly"); len(got) != 0 {
+			t.Errorf("expected no span tagged nightly, got %v", got)
+		}
+	})
+
+	t.Run("tags are returned with the trace", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/span-tags-trace", nil, apiKeyHeaders)
+		var trace struct {
+			Tags  []string
+			Spans []struct {
+				ID   string
+				Tags []string `json:"tags"`
+			}
+		}
+		ParseJSON(t, resp, &trace)
+		if !reflect.DeepEqual(trace.Tags, []string{"nightly"}) {
+			t.Errorf("expected trace tags [nightly], got %v", trace.Tags)
+		}
+		for _, sp := range trace.Spans {
+			if sp.ID == "span-tags-slow" && !reflect.DeepEqual(sp.Tags, []string{"slow", "regression-test"}) {
+				t.Errorf("expected the slow span's tags, got %v", sp.Tags)
+			}
+		}
+	})
+
+	t.Run("patch replaces the span's tags", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/traces/span-tags-trace/spans/span-tags-fast",
+			map[string]any{"tags": []string{"slow"}}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("patch: expected status 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		if got, want := search(t, "slow"), []string{"span-tags-fast", "span-tags-slow"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if got, want := search(t, "regression-test"), []string{"span-tags-slow"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("patch with an empty list clears the tags", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/traces/span-tags-trace/spans/span-tags-slow",
+			map[string]any{"tags": []string{}}, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("patch: expected status 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		if got, want := search(t, "slow", "regression-test"), []string{"span-tags-fast"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("patch errors", func(t *testing.T) {
+		resp := ts.Request("PATCH", "/api/v1/traces/span-tags-trace/spans/missing",
+			map[string]any{"tags": []string{"slow"}}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("unknown span: expected status 404, got %d", resp.StatusCode)
+		}
+
+		resp = ts.Request("PATCH", "/api/v1/traces/other-trace/spans/span-tags-fast",
+			map[string]any{"tags": []string{"slow"}}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("span of another trace: expected status 404, got %d", resp.StatusCode)
+		}
+
+		resp = ts.Request("PATCH", "/api/v1/traces/span-tags-trace/spans/span-tags-fast",
+			map[string]any{}, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("missing tags: expected status 400, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/domain/entity"
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+)
+
+// transformExtension registers custom ingest stages the way an extension would
+type transformExtension struct {
+	transforms []ingest.SpanTransform
+}
+
+func (e *transformExtension) MountRoutes(_ chi.Router, deps *apphttp.RouterDeps) {
+	deps.IngestSvc.AddSpanTransforms(e.transforms...)
+}
+
+// redactEmail replaces the "email" field of map inputs
+func redactEmail(span *entity.Span, _ ingest.IngestEvent) {
+	if input, ok := span.Input.(map[string]any); ok {
+		if _, ok := input["email"]; ok {
+			input["email"] = "[REDACTED]"
+		}
+	}
+}
+
+// flatRateCost prices a self-hosted model the pricing table doesn't know
+func flatRateCost(span *entity.Span, event ingest.IngestEvent) {
+	if event.Model != "in-house-7b" || span.InputTokens == nil || span.OutputTokens == nil {
+		return
+	}
+	cost := float64(*span.InputTokens+*span.OutputTokens) * 0.00001
+	span.CostUSD = &cost
+	delete(span.Metadata, ingest.MetadataUnpricedModel)
+}
+
+func TestCustomSpanTransforms(t *testing.T) {
+	ts := setupTestServerWithExtensions(t, &transformExtension{
+		transforms: []ingest.SpanTransform{redactEmail, flatRateCost},
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "transform@example.com", "password": "SecurePass123", "name": "Transform User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Transform Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	events := []map[string]any{
+		{
+			"traceId": "transform-trace", "spanId": "transform-llm", "spanType": "llm",
+			"provider": "openai", "model": "in-house-7b", "status": "success",
+			"input":       map[string]any{"email": "jane@example.com", "question": "What is my plan?"},
+			"output":      "The Pro plan.",
+			"inputTokens": 600, "outputTokens": 400,
+		},
+		{
+			"traceId": "transform-trace", "spanId": "transform-tool", "spanType": "tool",
+			"name": "lookup", "status": "success", "input": map[string]any{"query": "plans"},
+		},
+	}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	var trace map[string]any
+	ParseJSON(t, ts.Request("GET", "/api/v1/traces/transform-trace", nil, apiKeyHeaders), &trace)
+	spans := make(map[string]map[string]any)
+	for _, s := range trace["Spans"].([]any) {
+		span := s.(map[string]any)
+		spans[span["ID"].(string)] = span
+	}
+
+	t.Run("redaction is applied before storage", func(t *testing.T) {
+		input := spans["transform-llm"]["Input"].(map[string]any)
+		if input["email"] != "[REDACTED]" {
+			t.Errorf("expected email redacted, got %v", input["email"])
+		}
+		if input["question"] != "What is my plan?" {
+			t.Errorf("expected other input fields untouched, got %v", input["question"])
+		}
+	})
+
+	t.Run("custom stages run after built-in pricing", func(t *testing.T) {
+		llm := spans["transform-llm"]
+		if cost, _ := llm["CostUSD"].(float64); cost != 0.01 {
+			t.Errorf("expected flat-rate cost 0.01, got %v", llm["CostUSD"])
+		}
+		if md, _ := llm["Metadata"].(map[string]any); md[ingest.MetadataUnpricedModel] != nil {
+			t.Errorf("expected unpriced flag cleared by the custom rule, got %v", md)
+		}
+		if output := llm["Output"]; output != "The Pro plan." {
+			t.Errorf("expected built-in extraction to still run, got output %v", output)
+		}
+	})
+
+	t.Run("transforms apply to dry runs", func(t *testing.T) {
+		var result DryRunResponse
+		ParseJSON(t, ts.Request("POST", "/api/v1/ingest/dry-run", map[string]any{"events": events}, apiKeyHeaders), &result)
+		if len(result.Spans) == 0 {
+			t.Fatal("expected dry-run spans")
+		}
+		if input := result.Spans[0]["Input"].(map[string]any); input["email"] != "[REDACTED]" {
+			t.Errorf("expected email redacted in dry run, got %v", input["email"])
+		}
+	})
+}
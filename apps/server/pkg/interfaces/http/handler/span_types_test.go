@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"net/http"
 	"testing"
 )
 
@@ -697,3 +698,122 @@ func TestSpanTypeDefaultsToLLM(t *testing.T) {
 		}
 	})
 }
+
+// TestStrictSpanTypes verifies projects with strictSpanTypes reject unknown
+// types per event while lenient projects keep coercing them to llm
+func TestStrictSpanTypes(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "stricttype@example.com", "password": "SecurePass123", "name": "Strict User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+	createProject := func(name string, strict bool) map[string]string {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": name}, jwtHeaders)
+		var project ProjectResponse
+		ParseJSON(t, resp, &project)
+		if strict {
+			resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+				"settings": map[string]any{"strictSpanTypes": true},
+			}, jwtHeaders)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+			}
+		}
+		return map[string]string{"Authorization": "Bearer " + project.APIKey}
+	}
+
+	events := func(prefix string) map[string]any {
+		return map[string]any{"events": []map[string]any{
+			{"traceId": prefix + "-trace", "spanId": prefix + "-tool", "spanType": "tool", "name": "search", "status": "success"},
+			{"traceId": prefix + "-trace", "spanId": prefix + "-typo", "spanType": "retreival", "name": "docs", "status": "success"},
+			{"traceId": prefix + "-trace", "spanId": prefix + "-default", "name": "chat", "status": "success"},
+		}}
+	}
+	spanTypes := func(headers map[string]string, traceID string) map[string]string {
+		t.Helper()
+		var trace map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/"+traceID, nil, headers), &trace)
+		types := map[string]string{}
+		for _, s := range trace["Spans"].([]any) {
+			span := s.(map[string]any)
+			types[span["ID"].(string)] = span["Type"].(string)
+		}
+		return types
+	}
+
+	t.Run("lenient mode coerces unknown types to llm", func(t *testing.T) {
+		headers := createProject("Lenient Types", false)
+		resp := ts.Request("POST", "/api/v1/ingest", events("lenient"), headers)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		types := spanTypes(headers, "lenient-trace")
+		if len(types) != 3 || types["lenient-tool"] != "tool" || types["lenient-typo"] != "llm" || types["lenient-default"] != "llm" {
+			t.Errorf("unexpected span types: %v", types)
+		}
+	})
+
+	t.Run("strict mode rejects unknown types per event", func(t *testing.T) {
+		headers := createProject("Strict Types", true)
+		resp := ts.Request("POST", "/api/v1/ingest", events("strict"), headers)
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("expected 207, got %d", resp.StatusCode)
+		}
+		var result struct {
+			Success   bool
+			Processed int
+			Errors    []struct {
+				Index   int
+				Message string
+			}
+		}
+		ParseJSON(t, resp, &result)
+		if result.Success || result.Processed != 2 {
+			t.Errorf("expected 2 processed events and success=false, got %+v", result)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Index != 1 || result.Errors[0].Message != `unknown spanType "retreival"` {
+			t.Errorf("expected one error for event 1, got %+v", result.Errors)
+		}
+
+		types := spanTypes(headers, "strict-trace")
+		if len(types) != 2 || types["strict-tool"] != "tool" || types["strict-default"] != "llm" {
+			t.Errorf("expected only the valid events to be ingested, got %v", types)
+		}
+	})
+}
+
+// TestSpanTypesEndpoint verifies the canonical span type list is exposed
+func TestSpanTypesEndpoint(t *testing.T) {
+	ts := setupTestServer(t)
+
+	resp := ts.Request("GET", "/api/v1/span-types", nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result struct {
+		SpanTypes []string `json:"spanTypes"`
+		Default   string   `json:"default"`
+	}
+	ParseJSON(t, resp, &result)
+
+	want := []string{"llm", "agent", "tool", "retrieval", "embedding", "guardrail", "rerank", "eval", "custom"}
+	if len(result.SpanTypes) != len(want) {
+		t.Fatalf("expected %v, got %v", want, result.SpanTypes)
+	}
+	for i, typ := range want {
+		if result.SpanTypes[i] != typ {
+			t.Errorf("type %d: expected %s, got %s", i, typ, result.SpanTypes[i])
+		}
+	}
+	if result.Default != "llm" {
+		t.Errorf("expected default llm, got %s", result.Default)
+	}
+}
@@ -0,0 +1,62 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnalyticsStorage(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "storage@example.com", "password": "SecurePass123", "name": "Storage User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Storage Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	headers := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		// input `"hello"` is 7 bytes, output `{"answer":42}` 13
+		{"traceId": "storage", "spanType": "llm", "name": "chat", "status": "success",
+			"input": "hello", "output": map[string]any{"answer": 42}},
+		// input `[1,2,3]` is 7 bytes, no output
+		{"traceId": "storage", "spanType": "tool", "name": "sum", "status": "success",
+			"input": []int{1, 2, 3}},
+		{"traceId": "storage", "spanType": "tool", "name": "noop", "status": "success"},
+	}}, headers)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	resp = ts.Request("GET", "/api/v1/analytics/storage", nil, headers)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var report struct {
+		Data []struct {
+			Type        string
+			Spans       int
+			InputBytes  int64
+			OutputBytes int64
+		}
+	}
+	ParseJSON(t, resp, &report)
+
+	type sizes struct{ spans, input, output int64 }
+	got := make(map[string]sizes)
+	for _, st := range report.Data {
+		got[st.Type] = sizes{int64(st.Spans), st.InputBytes, st.OutputBytes}
+	}
+	want := map[string]sizes{"llm": {1, 7, 13}, "tool": {2, 7, 0}}
+	for spanType, w := range want {
+		if got[spanType] != w {
+			t.Errorf("%s: expected %+v, got %+v", spanType, w, got[spanType])
+		}
+	}
+}
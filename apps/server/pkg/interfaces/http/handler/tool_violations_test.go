@@ -0,0 +1,144 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// ToolViolationsResponse for parsing the tool violations summary
+type ToolViolationsResponse struct {
+	Data []struct {
+		Tool       string `json:"Tool"`
+		Violations int    `json:"Violations"`
+		Spans      int    `json:"Spans"`
+	} `json:"data"`
+}
+
+func TestToolArgViolations(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "schemas@example.com", "password": "SecurePass123", "name": "Schema User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	jwtHeaders := map[string]string{"Authorization": "Bearer " + auth.Token}
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Schema Project",
+	}, jwtHeaders)
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("PATCH", "/api/v1/dashboard/projects/"+project.ID, map[string]any{
+		"settings": map[string]any{
+			"toolSchemas": map[string]any{
+				"get_weather": map[string]any{
+					"type":                 "object",
+					"required":             []string{"city"},
+					"properties":           map[string]any{"city": map[string]any{"type": "string"}, "days": map[string]any{"type": "integer", "minimum": 1}},
+					"additionalProperties": false,
+				},
+			},
+		},
+	}, jwtHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	toolCall := func(id, name string, input any) map[string]any {
+		return map[string]any{"type": "tool_use", "id": id, "name": name, "input": input}
+	}
+	llmEvent := func(traceID, spanID string, output ...map[string]any) map[string]any {
+		return map[string]any{
+			"traceId": traceID, "spanId": spanID, "spanType": "llm",
+			"provider": "anthropic", "model": "claude-sonnet-4", "status": "success",
+			"output": output,
+		}
+	}
+
+	resp = ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			llmEvent("schema-trace-1", "valid-span",
+				toolCall("call-1", "get_weather", map[string]any{"city": "Lima", "days": 3}),
+				toolCall("call-2", "unregistered_tool", map[string]any{"anything": true}),
+			),
+			llmEvent("schema-trace-1", "invalid-span",
+				toolCall("call-3", "get_weather", map[string]any{"days": 0}),
+				toolCall("call-4", "get_weather", map[string]any{"city": 42}),
+			),
+			llmEvent("schema-trace-2", "invalid-span-2",
+				toolCall("call-5", "get_weather", map[string]any{"city": "Quito", "units": "metric"}),
+			),
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	spanMetadata := func(traceID string) map[string]map[string]any {
+		t.Helper()
+		var trace map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/"+traceID, nil, apiKeyHeaders), &trace)
+		result := map[string]map[string]any{}
+		for _, s := range trace["Spans"].([]any) {
+			span := s.(map[string]any)
+			md, _ := span["Metadata"].(map[string]any)
+			result[span["ID"].(string)] = md
+		}
+		return result
+	}
+
+	t.Run("valid arguments are not flagged", func(t *testing.T) {
+		md := spanMetadata("schema-trace-1")["valid-span"]
+		if _, ok := md["tool_arg_violations"]; ok {
+			t.Errorf("expected no violations, got %v", md["tool_arg_violations"])
+		}
+	})
+
+	t.Run("invalid arguments are flagged in span metadata", func(t *testing.T) {
+		md := spanMetadata("schema-trace-1")["invalid-span"]
+		violations, ok := md["tool_arg_violations"].([]any)
+		if !ok || len(violations) != 2 {
+			t.Fatalf("expected 2 violations, got %v", md["tool_arg_violations"])
+		}
+		for i, want := range []string{"call-3", "call-4"} {
+			v := violations[i].(map[string]any)
+			if v["toolUseId"] != want || v["tool"] != "get_weather" {
+				t.Errorf("unexpected violation %d: %v", i, v)
+			}
+			if errs, _ := v["errors"].([]any); len(errs) == 0 {
+				t.Errorf("expected error messages for %s", want)
+			}
+		}
+	})
+
+	t.Run("summary counts violations per tool", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/tool-violations", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var summary ToolViolationsResponse
+		ParseJSON(t, resp, &summary)
+
+		if len(summary.Data) != 1 {
+			t.Fatalf("expected 1 tool, got %+v", summary.Data)
+		}
+		got := summary.Data[0]
+		if got.Tool != "get_weather" || got.Violations != 3 || got.Spans != 2 {
+			t.Errorf("unexpected summary: %+v", got)
+		}
+	})
+
+	t.Run("summary requires API key", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/tool-violations", nil, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
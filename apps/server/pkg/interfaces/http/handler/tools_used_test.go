@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestToolsUsed(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "toolsused@example.com", "password": "SecurePass123", "name": "Tools Used User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": "Tools Used Project"},
+		map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		// The llm asks for search and calculator; search then runs twice as a tool span
+		{
+			"traceId": "tools-trace", "spanId": "tools-llm", "spanType": "llm", "model": "claude-sonnet-4-6", "status": "success",
+			"output": []map[string]any{
+				{"type": "tool_use", "id": "toolu_1", "name": "search", "input": map[string]any{"q": "weather"}},
+				{"type": "tool_use", "id": "toolu_2", "name": "calculator", "input": map[string]any{"expr": "1+1"}},
+			},
+		},
+		{"traceId": "tools-trace", "spanId": "tools-search-1", "parentSpanId": "tools-llm", "spanType": "tool", "name": "search", "status": "success"},
+		{"traceId": "tools-trace", "spanId": "tools-search-2", "parentSpanId": "tools-llm", "spanType": "tool", "name": "search", "status": "success"},
+		{"traceId": "plain-trace", "spanId": "plain-llm", "spanType": "llm", "model": "gpt-4o", "status": "success", "output": "hello"},
+	}}, apiKeyHeaders)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+
+	t.Run("distinct tools on the trace", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/tools-trace", nil, apiKeyHeaders)
+		var trace struct {
+			ToolsUsed []string `json:"ToolsUsed"`
+		}
+		ParseJSON(t, resp, &trace)
+		if want := []string{"calculator", "search"}; !reflect.DeepEqual(trace.ToolsUsed, want) {
+			t.Errorf("expected tools %v, got %v", want, trace.ToolsUsed)
+		}
+
+		resp = ts.Request("GET", "/api/v1/traces/plain-trace", nil, apiKeyHeaders)
+		ParseJSON(t, resp, &trace)
+		if len(trace.ToolsUsed) != 0 {
+			t.Errorf("expected no tools, got %v", trace.ToolsUsed)
+		}
+	})
+
+	t.Run("filter traces by tool", func(t *testing.T) {
+		for tool, want := range map[string]int{"search": 1, "calculator": 1, "missing": 0} {
+			resp := ts.Request("GET", "/api/v1/traces?tool="+tool, nil, apiKeyHeaders)
+			var page struct {
+				Data []struct {
+					ID string `json:"ID"`
+				} `json:"Data"`
+			}
+			ParseJSON(t, resp, &page)
+			if len(page.Data) != want {
+				t.Errorf("tool %s: expected %d traces, got %+v", tool, want, page.Data)
+			}
+			if want == 1 && page.Data[0].ID != "tools-trace" {
+				t.Errorf("tool %s: expected tools-trace, got %s", tool, page.Data[0].ID)
+			}
+		}
+	})
+}
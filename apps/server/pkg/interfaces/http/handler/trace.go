@@ -2,17 +2,30 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/lelemon/server/pkg/application/trace"
 	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
+// deletedResponse reports how many traces a bulk delete removed. A struct
+// rather than a map, so WriteJSON camelCases its key for API version 2.
+type deletedResponse struct {
+	Deleted int64
+}
+
 // TraceHandler handles trace requests
 type TraceHandler struct {
 	service *trace.Service
@@ -27,53 +40,131 @@ func NewTraceHandler(service *trace.Service) *TraceHandler {
 func (h *TraceHandler) Create(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var req trace.CreateTraceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	result, err := h.service.Create(r.Context(), project.ID, &req)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // Get handles GET /api/v1/traces/{id}
 func (h *TraceHandler) Get(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	traceID := chi.URLParam(r, "id")
 	if traceID == "" {
-		http.Error(w, `{"error":"Trace ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
 		return
 	}
 
 	result, err := h.service.Get(r.Context(), project.ID, traceID)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Trace not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.FromError(w, err, "Trace not found")
+		return
+	}
+
+	// Point large traces at the paginated span listing for the rest
+	if result.SpansTruncated {
+		w.Header().Set("Link", fmt.Sprintf(`</api/v1/traces/%s/spans?offset=%d>; rel="next"`, url.PathEscape(traceID), len(result.Spans)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, result)
+}
+
+// ListSpans handles GET /api/v1/traces/{id}/spans
+// Pages through the spans of a trace, earliest first, for traces too large
+// to return whole from Get.
+func (h *TraceHandler) ListSpans(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	traceID := chi.URLParam(r, "id")
+	if traceID == "" {
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
+		return
+	}
+
+	limit, offset := 100, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			offset = n
+		}
+	}
+
+	result, err := h.service.ListSpans(r.Context(), project.ID, traceID, limit, offset)
+	if err != nil {
+		apierror.FromError(w, err, "Trace not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, result)
+}
+
+// Children handles GET /api/v1/traces/{id}/children
+// Lists the traces linked to this one with parentTraceId at ingest, e.g. the
+// sub-agent traces an orchestrator delegated to, newest first.
+func (h *TraceHandler) Children(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	traceID := chi.URLParam(r, "id")
+	if traceID == "" {
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
+		return
+	}
+
+	limit, offset := 50, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			offset = n
 		}
+	}
+
+	result, err := h.service.ListChildren(r.Context(), project.ID, traceID, limit, offset)
+	if err != nil {
+		apierror.FromError(w, err, "Trace not found")
 		return
 	}
+	projectListMetadata(result.Data, project.Settings.ListMetadataKeys)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // GetDetail handles GET /api/v1/traces/{id}/detail
@@ -83,35 +174,31 @@ func (h *TraceHandler) Get(w http.ResponseWriter, r *http.Request) {
 func (h *TraceHandler) GetDetail(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	traceID := chi.URLParam(r, "id")
 	if traceID == "" {
-		http.Error(w, `{"error":"Trace ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
 		return
 	}
 
 	result, err := h.service.GetDetail(r.Context(), project.ID, traceID)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Trace not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Trace not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // List handles GET /api/v1/traces
 func (h *TraceHandler) List(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -137,6 +224,9 @@ func (h *TraceHandler) List(w http.ResponseWriter, r *http.Request) {
 	if v := r.URL.Query().Get("userId"); v != "" {
 		filter.UserID = &v
 	}
+	if v := r.URL.Query().Get("parentTraceId"); v != "" {
+		filter.ParentTraceID = &v
+	}
 	if v := r.URL.Query().Get("status"); v != "" {
 		status := entity.TraceStatus(v)
 		filter.Status = &status
@@ -151,90 +241,299 @@ func (h *TraceHandler) List(w http.ResponseWriter, r *http.Request) {
 			filter.To = &t
 		}
 	}
+	if v := r.URL.Query().Get("minInactiveMs"); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms >= 0 {
+			filter.MinInactiveMs = &ms
+		}
+	}
+	if v := r.URL.Query().Get("tool"); v != "" {
+		filter.Tool = &v
+	}
+	if v := r.URL.Query().Get("minDepth"); v != "" {
+		if depth, err := strconv.Atoi(v); err == nil && depth >= 1 {
+			depth = min(depth, entity.MaxFilterDepth)
+			filter.MinDepth = &depth
+		}
+	}
 
 	result, err := h.service.List(r.Context(), project.ID, filter)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	projectListMetadata(result.Data, project.Settings.ListMetadataKeys)
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, result)
+}
+
+// projectListMetadata trims each trace's metadata to the project's
+// listMetadataKeys, keeping list responses light and free of metadata the
+// project didn't choose to surface there. No keys leaves metadata untouched.
+func projectListMetadata(traces []entity.TraceWithMetrics, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	for i := range traces {
+		projected := make(map[string]any, len(keys))
+		for _, key := range keys {
+			if v, ok := traces[i].Metadata[key]; ok {
+				projected[key] = v
+			}
+		}
+		traces[i].Metadata = projected
+	}
+}
+
+// confirmDeleteHeader must be set to "true" on bulk deletes
+const confirmDeleteHeader = "X-Confirm-Delete"
+
+// DeleteByFilter handles DELETE /api/v1/traces
+// Deletes every trace matching the query filter (sessionId, userId, status,
+// name, tags, from, to); e.g. ?status=error&to=<7 days ago> purges old errors.
+// Requires the X-Confirm-Delete: true header, and at least one filter so a
+// bare request cannot wipe the project.
+func (h *TraceHandler) DeleteByFilter(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if r.Header.Get(confirmDeleteHeader) != "true" {
+		apierror.Write(w, http.StatusBadRequest, "X-Confirm-Delete: true header is required")
+		return
+	}
+
+	// Unlike List, malformed values are rejected rather than ignored: dropping
+	// a bound would widen the delete.
+	var filter entity.TraceFilter
+	q := r.URL.Query()
+	if v := q.Get("sessionId"); v != "" {
+		filter.SessionID = &v
+	}
+	if v := q.Get("userId"); v != "" {
+		filter.UserID = &v
+	}
+	if v := q.Get("status"); v != "" {
+		status := entity.TraceStatus(v)
+		filter.Status = &status
+	}
+	if v := q.Get("name"); v != "" {
+		filter.Name = &v
+	}
+	if v := q.Get("tags"); v != "" {
+		filter.Tags = strings.Split(v, ",")
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, "from must be an RFC3339 timestamp")
+			return
+		}
+		filter.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, "to must be an RFC3339 timestamp")
+			return
+		}
+		filter.To = &t
+	}
+
+	if filter.SessionID == nil && filter.UserID == nil && filter.Status == nil && filter.Name == nil &&
+		len(filter.Tags) == 0 && filter.From == nil && filter.To == nil {
+		apierror.Write(w, http.StatusBadRequest, "At least one filter is required")
+		return
+	}
+
+	deleted, err := h.service.DeleteByFilter(r.Context(), project.ID, filter)
+	if err != nil {
+		slog.Error("Failed to delete traces", "projectID", project.ID, "error", err)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
+	slog.Info("Deleted traces by filter", "projectID", project.ID, "deleted", deleted)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, deletedResponse{Deleted: deleted})
 }
 
 // Update handles PATCH /api/v1/traces/{id}
 func (h *TraceHandler) Update(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	traceID := chi.URLParam(r, "id")
 	if traceID == "" {
-		http.Error(w, `{"error":"Trace ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
 		return
 	}
 
 	var req trace.UpdateTraceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.service.Update(r.Context(), project.ID, traceID, &req); err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Trace not found"}`, http.StatusNotFound)
-		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-		}
+		apierror.FromError(w, err, "Trace not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	middleware.WriteJSON(w, map[string]bool{"success": true})
+}
+
+// UpdateSpan handles PATCH /api/v1/traces/{id}/spans/{spanId}
+func (h *TraceHandler) UpdateSpan(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	traceID := chi.URLParam(r, "id")
+	spanID := chi.URLParam(r, "spanId")
+	if traceID == "" || spanID == "" {
+		apierror.Write(w, http.StatusBadRequest, "Trace ID and span ID required")
+		return
+	}
+
+	var req trace.UpdateSpanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.UpdateSpan(r.Context(), project.ID, traceID, spanID, &req); err != nil {
+		apierror.FromError(w, err, "Span not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, map[string]bool{"success": true})
 }
 
 // AddSpan handles POST /api/v1/traces/{id}/spans
 func (h *TraceHandler) AddSpan(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	traceID := chi.URLParam(r, "id")
 	if traceID == "" {
-		http.Error(w, `{"error":"Trace ID required"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
 		return
 	}
 
 	var req trace.CreateSpanRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	result, err := h.service.AddSpan(r.Context(), project.ID, traceID, &req)
 	if err != nil {
-		if err == entity.ErrNotFound {
-			http.Error(w, `{"error":"Trace not found"}`, http.StatusNotFound)
+		apierror.FromError(w, err, "Trace not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	middleware.WriteJSON(w, result)
+}
+
+// Feedback handles POST /api/v1/traces/{id}/feedback
+func (h *TraceHandler) Feedback(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	traceID := chi.URLParam(r, "id")
+	if traceID == "" {
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
+		return
+	}
+
+	var req trace.FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.service.AddFeedback(r.Context(), project.ID, traceID, &req)
+	if err != nil {
+		switch err {
+		case trace.ErrInvalidFeedback:
+			apierror.Write(w, http.StatusBadRequest, "Feedback value must be -1, 0 or 1")
+		case entity.ErrNotFound:
+			apierror.Write(w, http.StatusNotFound, "Trace not found")
+		default:
+			apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	middleware.WriteJSON(w, result)
+}
+
+// Copy handles POST /api/v1/traces/{id}/copy: it copies the trace, with fresh
+// IDs, into another project of the same owner
+func (h *TraceHandler) Copy(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	traceID := chi.URLParam(r, "id")
+	if traceID == "" {
+		apierror.Write(w, http.StatusBadRequest, "Trace ID required")
+		return
+	}
+
+	var req trace.CopyTraceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TargetProjectID == "" {
+		apierror.Write(w, http.StatusBadRequest, "targetProjectId is required")
+		return
+	}
+
+	result, err := h.service.Copy(r.Context(), project.ID, traceID, req.TargetProjectID)
+	if err != nil {
+		if errors.Is(err, trace.ErrTargetProjectNotFound) {
+			apierror.Write(w, http.StatusNotFound, "Target project not found")
 		} else {
-			http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+			apierror.FromError(w, err, "Trace not found")
 		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
 
 // ListSessions handles GET /api/v1/sessions
 func (h *TraceHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	project := middleware.GetProject(r.Context())
 	if project == nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -269,10 +568,44 @@ func (h *TraceHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.service.ListSessions(r.Context(), project.ID, filter)
 	if err != nil {
-		http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, result)
+}
+
+// SearchSpans handles POST /api/v1/spans/search
+// Returns matching spans directly (each with its traceId), paged like List.
+func (h *TraceHandler) SearchSpans(w http.ResponseWriter, r *http.Request) {
+	project := middleware.GetProject(r.Context())
+	if project == nil {
+		apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req trace.SearchSpansRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Attribute != nil && req.Attribute.Key == "" {
+		apierror.Write(w, http.StatusBadRequest, "attribute.key is required")
+		return
+	}
+
+	result, err := h.service.SearchSpans(r.Context(), project.ID, &req)
+	if err == entity.ErrBadRequest {
+		apierror.Write(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, result)
 }
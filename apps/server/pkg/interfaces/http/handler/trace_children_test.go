@@ -0,0 +1,130 @@
+package handler_test
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+)
+
+// TraceChildrenResponse for parsing a page of linked traces
+type TraceChildrenResponse struct {
+	Data []struct {
+		ID            string  `json:"ID"`
+		ParentTraceID *string `json:"ParentTraceID"`
+	} `json:"Data"`
+	Total int `json:"Total"`
+}
+
+func TestTraceChildren(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "children@example.com", "password": "SecurePass123", "name": "Children User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Children Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	event := func(traceID, parentTraceID string) map[string]any {
+		e := map[string]any{
+			"traceId": traceID, "spanId": traceID + "-span", "spanType": "agent", "status": "success",
+		}
+		if parentTraceID != "" {
+			e["parentTraceId"] = parentTraceID
+		}
+		return e
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			event("orchestrator", ""),
+			event("researcher", "orchestrator"),
+			event("writer", "orchestrator"),
+			event("unrelated", ""),
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	childIDs := func(page TraceChildrenResponse) []string {
+		var ids []string
+		for _, tr := range page.Data {
+			if tr.ParentTraceID == nil || *tr.ParentTraceID != "orchestrator" {
+				t.Errorf("trace %s: expected parent orchestrator, got %v", tr.ID, tr.ParentTraceID)
+			}
+			ids = append(ids, tr.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("children of the orchestrator", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/orchestrator/children", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var page TraceChildrenResponse
+		ParseJSON(t, resp, &page)
+
+		if page.Total != 2 {
+			t.Errorf("expected 2 children, got %d", page.Total)
+		}
+		if ids := childIDs(page); len(ids) != 2 || ids[0] != "researcher" || ids[1] != "writer" {
+			t.Errorf("expected researcher and writer, got %v", ids)
+		}
+	})
+
+	t.Run("trace without children", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/unrelated/children", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var page TraceChildrenResponse
+		ParseJSON(t, resp, &page)
+		if page.Total != 0 || len(page.Data) != 0 {
+			t.Errorf("expected no children, got %d", page.Total)
+		}
+	})
+
+	t.Run("unknown trace", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/missing/children", nil, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("list filtered by parent", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces?parentTraceId=orchestrator", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var page TraceChildrenResponse
+		ParseJSON(t, resp, &page)
+		if ids := childIDs(page); len(ids) != 2 {
+			t.Errorf("expected 2 children, got %v", ids)
+		}
+	})
+
+	t.Run("child links back to its parent", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/writer/detail", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var detail struct {
+			ParentTraceID *string `json:"parentTraceId"`
+		}
+		ParseJSON(t, resp, &detail)
+		if detail.ParentTraceID == nil || *detail.ParentTraceID != "orchestrator" {
+			t.Errorf("expected parentTraceId orchestrator, got %v", detail.ParentTraceID)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTraceCopy(t *testing.T) {
+	ts := setupTestServer(t)
+
+	register := func(email string) string {
+		resp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+			"email": email, "password": "SecurePass123", "name": "Copy User",
+		}, nil)
+		var auth AuthResponse
+		ParseJSON(t, resp, &auth)
+		return auth.Token
+	}
+	createProject := func(token, name string) ProjectResponse {
+		resp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{"name": name},
+			map[string]string{"Authorization": "Bearer " + token})
+		var project ProjectResponse
+		ParseJSON(t, resp, &project)
+		return project
+	}
+
+	token := register("copy@example.com")
+	prod := createProject(token, "Production")
+	sandbox := createProject(token, "Sandbox")
+	foreign := createProject(register("copy-other@example.com"), "Someone Else's")
+
+	prodHeaders := map[string]string{"Authorization": "Bearer " + prod.APIKey}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+		{"traceId": "copy-trace", "spanId": "copy-agent", "spanType": "agent", "name": "agent", "status": "success"},
+		{"traceId": "copy-trace", "spanId": "copy-llm", "parentSpanId": "copy-agent", "spanType": "llm", "name": "chat", "status": "success"},
+		{"traceId": "copy-trace", "spanId": "copy-tool", "parentSpanId": "copy-llm", "spanType": "tool", "name": "search", "status": "success"},
+	}}, prodHeaders)
+	resp.Body.Close()
+
+	t.Run("copies into a project of the same owner", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/copy-trace/copy", map[string]string{"targetProjectId": sandbox.ID}, prodHeaders)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		var result struct {
+			TraceID   string            `json:"traceId"`
+			ProjectID string            `json:"projectId"`
+			SpanIDs   map[string]string `json:"spanIds"`
+		}
+		ParseJSON(t, resp, &result)
+		if result.ProjectID != sandbox.ID || len(result.SpanIDs) != 3 {
+			t.Fatalf("unexpected copy result: %+v", result)
+		}
+
+		resp = ts.Request("GET", "/api/v1/traces/"+result.TraceID, nil, map[string]string{"Authorization": "Bearer " + sandbox.APIKey})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("get copy: expected 200, got %d", resp.StatusCode)
+		}
+		var copied struct {
+			Spans []struct {
+				ID           string  `json:"ID"`
+				ParentSpanID *string `json:"ParentSpanID"`
+				Name         string  `json:"Name"`
+			} `json:"Spans"`
+		}
+		ParseJSON(t, resp, &copied)
+
+		wantParent := map[string]string{
+			result.SpanIDs["copy-llm"]:  result.SpanIDs["copy-agent"],
+			result.SpanIDs["copy-tool"]: result.SpanIDs["copy-llm"],
+		}
+		if len(copied.Spans) != 3 {
+			t.Fatalf("expected 3 spans, got %d", len(copied.Spans))
+		}
+		for _, sp := range copied.Spans {
+			parent := ""
+			if sp.ParentSpanID != nil {
+				parent = *sp.ParentSpanID
+			}
+			if parent != wantParent[sp.ID] {
+				t.Errorf("span %s (%s): expected parent %q, got %q", sp.ID, sp.Name, wantParent[sp.ID], parent)
+			}
+		}
+
+		// The original is untouched
+		resp = ts.Request("GET", "/api/v1/traces/copy-trace", nil, prodHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected the original trace to remain, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("rejects a project of another owner", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/copy-trace/copy", map[string]string{"targetProjectId": foreign.ID}, prodHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("requires a target", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/traces/copy-trace/copy", map[string]string{}, prodHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDeleteTracesByFilter(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "bulkdelete@example.com", "password": "SecurePass123", "name": "Bulk Delete User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	createProject := func(name string) map[string]string {
+		t.Helper()
+		resp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+			"name": name,
+		}, map[string]string{"Authorization": "Bearer " + auth.Token})
+		var project ProjectResponse
+		ParseJSON(t, resp, &project)
+		return map[string]string{"Authorization": "Bearer " + project.APIKey}
+	}
+	apiKeyHeaders := createProject("Bulk Delete Project")
+	otherHeaders := createProject("Bulk Delete Other")
+
+	ingest := func(headers map[string]string, prefix string, statuses ...string) {
+		t.Helper()
+		var events []map[string]any
+		for i, status := range statuses {
+			events = append(events, map[string]any{
+				"traceId":    fmt.Sprintf("%s-trace-%d", prefix, i),
+				"spanId":     fmt.Sprintf("%s-span-%d", prefix, i),
+				"spanType":   "llm",
+				"provider":   "openai",
+				"model":      "gpt-4o-mini",
+				"name":       "chat",
+				"durationMs": 10,
+				"status":     status,
+			})
+		}
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, headers)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ingest: expected status 200, got %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+	ingest(apiKeyHeaders, "del", "error", "success", "error", "success", "error")
+	ingest(otherHeaders, "other", "error")
+
+	confirmed := func(headers map[string]string) map[string]string {
+		h := map[string]string{"X-Confirm-Delete": "true"}
+		for k, v := range headers {
+			h[k] = v
+		}
+		return h
+	}
+	total := func(headers map[string]string, query string) int {
+		t.Helper()
+		resp := ts.Request("GET", "/api/v1/traces"+query, nil, headers)
+		var page PageMeta
+		ParseJSON(t, resp, &page)
+		return page.Total
+	}
+
+	t.Run("rejected requests", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			query   string
+			headers map[string]string
+		}{
+			{"missing confirmation", "?status=error", apiKeyHeaders},
+			{"no filter", "", confirmed(apiKeyHeaders)},
+			{"malformed to", "?status=error&to=last-week", confirmed(apiKeyHeaders)},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := ts.Request("DELETE", "/api/v1/traces"+tt.query, nil, tt.headers)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("expected status 400, got %d", resp.StatusCode)
+				}
+			})
+		}
+		if n := total(apiKeyHeaders, ""); n != 5 {
+			t.Errorf("expected no traces deleted, got %d remaining", n)
+		}
+	})
+
+	t.Run("time bound excludes newer traces", func(t *testing.T) {
+		to := time.Now().Add(-7 * 24 * time.Hour).UTC().Format(time.RFC3339)
+		resp := ts.Request("DELETE", "/api/v1/traces?status=error&to="+to, nil, confirmed(apiKeyHeaders))
+		var result struct{ Deleted int64 }
+		ParseJSON(t, resp, &result)
+		if result.Deleted != 0 {
+			t.Errorf("expected 0 traces older than 7 days, deleted %d", result.Deleted)
+		}
+	})
+
+	t.Run("deletes matching traces and their spans", func(t *testing.T) {
+		resp := ts.Request("DELETE", "/api/v1/traces?status=error", nil, confirmed(apiKeyHeaders))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		var result struct{ Deleted int64 }
+		ParseJSON(t, resp, &result)
+		if result.Deleted != 3 {
+			t.Errorf("expected 3 deleted traces, got %d", result.Deleted)
+		}
+
+		if n := total(apiKeyHeaders, ""); n != 2 {
+			t.Errorf("expected 2 remaining traces, got %d", n)
+		}
+		if n := total(apiKeyHeaders, "?status=error"); n != 0 {
+			t.Errorf("expected no remaining error traces, got %d", n)
+		}
+
+		resp = ts.Request("GET", "/api/v1/traces/del-trace-0", nil, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected deleted trace to return 404, got %d", resp.StatusCode)
+		}
+
+		searchResp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"status": "error"}, apiKeyHeaders)
+		var spans PageMeta
+		ParseJSON(t, searchResp, &spans)
+		if spans.Total != 0 {
+			t.Errorf("expected spans of deleted traces to be removed, found %d", spans.Total)
+		}
+	})
+
+	t.Run("other projects are untouched", func(t *testing.T) {
+		if n := total(otherHeaders, "?status=error"); n != 1 {
+			t.Errorf("expected other project's error trace to survive, got %d", n)
+		}
+		resp := ts.Request("POST", "/api/v1/spans/search", map[string]any{"status": "error"}, otherHeaders)
+		var spans PageMeta
+		ParseJSON(t, resp, &spans)
+		if spans.Total != 1 {
+			t.Errorf("expected other project's span to survive, found %d", spans.Total)
+		}
+	})
+}
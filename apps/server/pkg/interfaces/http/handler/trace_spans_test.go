@@ -0,0 +1,153 @@
+package handler_test
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+)
+
+func TestTraceSpanCap(t *testing.T) {
+	ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+		cfg.TraceSvc.SetMaxSpans(10)
+	})
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "spancap@example.com", "password": "SecurePass123", "name": "Span Cap User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Span Cap Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	const spanCount = 25
+	start := time.Now().Add(-time.Hour)
+	var events []map[string]any
+	for i := 0; i < spanCount; i++ {
+		events = append(events, map[string]any{
+			"traceId":    "cap-trace",
+			"spanId":     fmt.Sprintf("cap-span-%02d", i),
+			"spanType":   "tool",
+			"name":       "step",
+			"status":     "success",
+			"durationMs": 100,
+			"costUsd":    0.01,
+			"timestamp":  start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano),
+		})
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": events}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("returns the first spans and totals for all of them", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/cap-trace", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		link := resp.Header.Get("Link")
+		var trace struct {
+			Spans []struct {
+				ID string `json:"ID"`
+			} `json:"Spans"`
+			TotalSpans      int     `json:"TotalSpans"`
+			TotalCostUSD    float64 `json:"TotalCostUSD"`
+			TotalDurationMs int     `json:"TotalDurationMs"`
+			SpansTruncated  bool    `json:"SpansTruncated"`
+		}
+		ParseJSON(t, resp, &trace)
+
+		if len(trace.Spans) != 10 || !trace.SpansTruncated {
+			t.Fatalf("expected 10 spans marked truncated, got %d (truncated %v)", len(trace.Spans), trace.SpansTruncated)
+		}
+		if trace.Spans[0].ID != "cap-span-00" || trace.Spans[9].ID != "cap-span-09" {
+			t.Errorf("expected the earliest spans, got %s..%s", trace.Spans[0].ID, trace.Spans[9].ID)
+		}
+		if trace.TotalSpans != spanCount || trace.TotalDurationMs != spanCount*100 {
+			t.Errorf("expected totals over %d spans, got %d spans / %dms", spanCount, trace.TotalSpans, trace.TotalDurationMs)
+		}
+		if math.Abs(trace.TotalCostUSD-0.25) > 1e-9 {
+			t.Errorf("expected the cost of every span, got %v", trace.TotalCostUSD)
+		}
+		if !strings.Contains(link, "/api/v1/traces/cap-trace/spans?offset=10") {
+			t.Errorf("expected a Link to the rest of the spans, got %q", link)
+		}
+	})
+
+	t.Run("detail uses totals for all spans", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/cap-trace/detail", nil, apiKeyHeaders)
+		var detail struct {
+			TotalSpans     int  `json:"totalSpans"`
+			SpansTruncated bool `json:"spansTruncated"`
+		}
+		ParseJSON(t, resp, &detail)
+		if detail.TotalSpans != spanCount || !detail.SpansTruncated {
+			t.Errorf("expected %d total spans marked truncated, got %+v", spanCount, detail)
+		}
+	})
+
+	t.Run("pages cover the remaining spans", func(t *testing.T) {
+		var ids []string
+		for offset := 0; offset < spanCount; offset += 10 {
+			resp := ts.Request("GET", fmt.Sprintf("/api/v1/traces/cap-trace/spans?limit=10&offset=%d", offset), nil, apiKeyHeaders)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("list spans: expected 200, got %d", resp.StatusCode)
+			}
+			var page SpanSearchResponse
+			ParseJSON(t, resp, &page)
+			if page.Total != spanCount {
+				t.Errorf("expected total %d, got %d", spanCount, page.Total)
+			}
+			for _, sp := range page.Data {
+				ids = append(ids, sp.ID)
+			}
+		}
+		if len(ids) != spanCount {
+			t.Fatalf("expected %d spans across pages, got %d", spanCount, len(ids))
+		}
+		for i, id := range ids {
+			if want := fmt.Sprintf("cap-span-%02d", i); id != want {
+				t.Errorf("span %d: expected %s, got %s", i, want, id)
+			}
+		}
+	})
+
+	t.Run("unknown trace", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/traces/missing-trace/spans", nil, apiKeyHeaders)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("traces under the cap are not truncated", func(t *testing.T) {
+		resp := ts.Request("POST", "/api/v1/ingest", map[string]any{"events": []map[string]any{
+			{"traceId": "small-trace", "spanType": "tool", "name": "step", "status": "success"},
+		}}, apiKeyHeaders)
+		resp.Body.Close()
+
+		resp = ts.Request("GET", "/api/v1/traces/small-trace", nil, apiKeyHeaders)
+		if resp.Header.Get("Link") != "" {
+			t.Errorf("expected no Link header, got %q", resp.Header.Get("Link"))
+		}
+		var trace struct {
+			Spans          []any `json:"Spans"`
+			SpansTruncated bool  `json:"SpansTruncated"`
+		}
+		ParseJSON(t, resp, &trace)
+		if len(trace.Spans) != 1 || trace.SpansTruncated {
+			t.Errorf("expected the whole trace, got %d spans (truncated %v)", len(trace.Spans), trace.SpansTruncated)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// UnpricedModelsResponse for parsing the unpriced models report
+type UnpricedModelsResponse struct {
+	Data []struct {
+		Provider string `json:"Provider"`
+		Model    string `json:"Model"`
+		Spans    int    `json:"Spans"`
+	} `json:"data"`
+}
+
+func TestUnpricedModels(t *testing.T) {
+	ts := setupTestServer(t)
+
+	regResp := ts.Request("POST", "/api/v1/auth/register", map[string]string{
+		"email": "unpriced@example.com", "password": "SecurePass123", "name": "Unpriced User",
+	}, nil)
+	var auth AuthResponse
+	ParseJSON(t, regResp, &auth)
+
+	projResp := ts.Request("POST", "/api/v1/dashboard/projects", map[string]string{
+		"name": "Unpriced Project",
+	}, map[string]string{"Authorization": "Bearer " + auth.Token})
+	var project ProjectResponse
+	ParseJSON(t, projResp, &project)
+
+	apiKeyHeaders := map[string]string{"Authorization": "Bearer " + project.APIKey}
+
+	llmEvent := func(spanID, provider, model string) map[string]any {
+		return map[string]any{
+			"traceId": "unpriced-trace", "spanId": spanID, "spanType": "llm",
+			"provider": provider, "model": model, "status": "success",
+			"inputTokens": 1000, "outputTokens": 500,
+		}
+	}
+	resp := ts.Request("POST", "/api/v1/ingest", map[string]any{
+		"events": []map[string]any{
+			llmEvent("priced-span", "openai", "gpt-4o"),
+			llmEvent("unknown-span-1", "acme", "acme-llm-9000"),
+			llmEvent("unknown-span-2", "acme", "acme-llm-9000"),
+			llmEvent("unknown-span-3", "openai", "gpt-from-the-future"),
+		},
+	}, apiKeyHeaders)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	t.Run("unknown models are flagged in span metadata", func(t *testing.T) {
+		var trace map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/traces/unpriced-trace", nil, apiKeyHeaders), &trace)
+		for _, s := range trace["Spans"].([]any) {
+			span := s.(map[string]any)
+			md, _ := span["Metadata"].(map[string]any)
+			flagged := md["unpriced_model"] == true
+			if want := span["ID"] != "priced-span"; flagged != want {
+				t.Errorf("span %s: expected unpriced_model=%v, got %v", span["ID"], want, md["unpriced_model"])
+			}
+		}
+	})
+
+	t.Run("report lists unknown models with span counts", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/unpriced-models", nil, apiKeyHeaders)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var report UnpricedModelsResponse
+		ParseJSON(t, resp, &report)
+
+		if len(report.Data) != 2 {
+			t.Fatalf("expected 2 unpriced models, got %+v", report.Data)
+		}
+		if got := report.Data[0]; got.Provider != "acme" || got.Model != "acme-llm-9000" || got.Spans != 2 {
+			t.Errorf("unexpected first entry: %+v", got)
+		}
+		if got := report.Data[1]; got.Provider != "openai" || got.Model != "gpt-from-the-future" || got.Spans != 1 {
+			t.Errorf("unexpected second entry: %+v", got)
+		}
+	})
+
+	t.Run("report requires API key", func(t *testing.T) {
+		resp := ts.Request("GET", "/api/v1/analytics/unpriced-models", nil, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/lelemon/server/pkg/infrastructure/buildinfo"
+	"github.com/lelemon/server/pkg/interfaces/http/middleware"
+)
+
+// StoreBackends lists the database backends in use ("sqlite", "postgres",
+// "clickhouse")
+type StoreBackends struct {
+	Primary   string   `json:"primary"`
+	Analytics []string `json:"analytics"` // more than one with shards or regions on different backends
+}
+
+// VersionResponse is the response of GET /api/v1/version
+type VersionResponse struct {
+	buildinfo.Info
+	Edition    string        `json:"edition"` // "community" or "enterprise"
+	Enterprise bool          `json:"enterprise"`
+	Stores     StoreBackends `json:"stores"`
+}
+
+// VersionHandler reports the running version, build and deployment shape,
+// for support to diagnose deployments
+type VersionHandler struct {
+	response VersionResponse
+}
+
+// NewVersionHandler creates a new version handler. A nil features config means
+// the community edition.
+func NewVersionHandler(features *FeaturesConfig, stores StoreBackends) *VersionHandler {
+	if features == nil {
+		features = DefaultFeaturesConfig()
+	}
+	return &VersionHandler{response: VersionResponse{
+		Info:       buildinfo.Get(),
+		Edition:    features.Edition,
+		Enterprise: features.Edition == "enterprise",
+		Stores:     stores,
+	}}
+}
+
+// Handle returns the version information.
+// GET /api/v1/version
+func (h *VersionHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	middleware.WriteJSON(w, h.response)
+}
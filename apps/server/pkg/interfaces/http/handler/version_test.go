@@ -0,0 +1,61 @@
+package handler_test
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+
+	apphttp "github.com/lelemon/server/pkg/interfaces/http"
+	"github.com/lelemon/server/pkg/interfaces/http/handler"
+)
+
+func TestVersion(t *testing.T) {
+	t.Run("reports build and deployment info without auth", func(t *testing.T) {
+		ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+			cfg.StoreBackends = handler.StoreBackends{Primary: "postgres", Analytics: []string{"clickhouse"}}
+		})
+
+		resp := ts.Request("GET", "/api/v1/version", nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var version map[string]any
+		ParseJSON(t, resp, &version)
+
+		for _, field := range []string{"version", "commit", "buildTime", "goVersion", "edition", "enterprise", "stores"} {
+			if _, ok := version[field]; !ok {
+				t.Errorf("expected field %q, got %v", field, version)
+			}
+		}
+		for _, field := range []string{"version", "commit", "buildTime"} {
+			if s, _ := version[field].(string); s == "" {
+				t.Errorf("expected %s to be set, got %v", field, version[field])
+			}
+		}
+		if version["goVersion"] != runtime.Version() {
+			t.Errorf("expected goVersion %s, got %v", runtime.Version(), version["goVersion"])
+		}
+		if version["edition"] != "community" || version["enterprise"] != false {
+			t.Errorf("expected the community edition, got %v / %v", version["edition"], version["enterprise"])
+		}
+		stores, _ := version["stores"].(map[string]any)
+		if stores["primary"] != "postgres" {
+			t.Errorf("expected primary store postgres, got %v", stores)
+		}
+		if analytics, _ := stores["analytics"].([]any); len(analytics) != 1 || analytics[0] != "clickhouse" {
+			t.Errorf("expected analytics stores [clickhouse], got %v", stores)
+		}
+	})
+
+	t.Run("enterprise edition", func(t *testing.T) {
+		ts := setupTestServerWithConfig(t, func(cfg *apphttp.RouterConfig) {
+			cfg.FeaturesConfig = apphttp.EnterpriseFeaturesConfig()
+		})
+
+		var version map[string]any
+		ParseJSON(t, ts.Request("GET", "/api/v1/version", nil, nil), &version)
+		if version["edition"] != "enterprise" || version["enterprise"] != true {
+			t.Errorf("expected the enterprise edition, got %v / %v", version["edition"], version["enterprise"])
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/lelemon/server/pkg/domain/entity"
 	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 // contextKey is a custom type for context keys
@@ -20,27 +21,28 @@ const (
 	ProjectContextKey contextKey = "project"
 )
 
-// APIKeyAuth creates middleware that authenticates requests via API key
-func APIKeyAuth(store repository.Store) func(http.Handler) http.Handler {
+// APIKeyAuth creates middleware that authenticates requests via API key.
+// When usage is non-nil, each authenticated request is recorded against the key.
+func APIKeyAuth(store repository.Store, usage *APIKeyUsageTracker) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract API key from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
 			// Parse Bearer token
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, `{"error":"Invalid authorization header"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "Invalid authorization header")
 				return
 			}
 
 			apiKey := parts[1]
-			if apiKey == "" || !strings.HasPrefix(apiKey, "le_") {
-				http.Error(w, `{"error":"Invalid API key format"}`, http.StatusUnauthorized)
+			if apiKey == "" || !strings.HasPrefix(apiKey, entity.APIKeyPrefix) {
+				apierror.Write(w, http.StatusUnauthorized, "Invalid API key format")
 				return
 			}
 
@@ -52,13 +54,23 @@ func APIKeyAuth(store repository.Store) func(http.Handler) http.Handler {
 			project, err := store.GetProjectByAPIKeyHash(r.Context(), hashStr)
 			if err != nil {
 				if err == entity.ErrNotFound {
-					http.Error(w, `{"error":"Invalid API key"}`, http.StatusUnauthorized)
+					apierror.Write(w, http.StatusUnauthorized, "Invalid API key")
 				} else {
-					http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+					apierror.Write(w, http.StatusInternalServerError, "Internal server error")
 				}
 				return
 			}
 
+			// A key kept from before the project's environment changed
+			if entity.APIKeyEnvironment(apiKey) != project.Settings.Environment {
+				apierror.Write(w, http.StatusUnauthorized, "API key is for another environment")
+				return
+			}
+
+			if usage != nil {
+				usage.Record(project.ID, hashStr)
+			}
+
 			// Add project to context
 			ctx := context.WithValue(r.Context(), ProjectContextKey, project)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -72,8 +84,8 @@ func APIKeyAuth(store repository.Store) func(http.Handler) http.Handler {
 // it presents the shared `MCP_STORE_SECRET` as the Bearer token plus an `X-Project-Id` header,
 // and we load that project into context exactly like the API-key path. All downstream handlers
 // (traces, analytics, /projects/me) are unchanged — they read the project from context.
-func ProjectAuth(store repository.Store, serviceSecret string) func(http.Handler) http.Handler {
-	apiKeyAuth := APIKeyAuth(store)
+func ProjectAuth(store repository.Store, serviceSecret string, usage *APIKeyUsageTracker) func(http.Handler) http.Handler {
+	apiKeyAuth := APIKeyAuth(store, usage)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
@@ -89,16 +101,12 @@ func ProjectAuth(store repository.Store, serviceSecret string) func(http.Handler
 				subtle.ConstantTimeCompare([]byte(token), []byte(serviceSecret)) == 1 {
 				projectID := r.Header.Get("X-Project-Id")
 				if projectID == "" {
-					http.Error(w, `{"error":"X-Project-Id is required"}`, http.StatusBadRequest)
+					apierror.Write(w, http.StatusBadRequest, "X-Project-Id is required")
 					return
 				}
 				project, err := store.GetProjectByID(r.Context(), projectID)
 				if err != nil {
-					if err == entity.ErrNotFound {
-						http.Error(w, `{"error":"unknown project"}`, http.StatusNotFound)
-					} else {
-						http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
-					}
+					apierror.FromError(w, err, "unknown project")
 					return
 				}
 				ctx := context.WithValue(r.Context(), ProjectContextKey, project)
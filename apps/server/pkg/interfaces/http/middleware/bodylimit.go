@@ -1,16 +1,78 @@
 package middleware
 
-import "net/http"
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
 
-// MaxBodySize limits the request body size to prevent DoS attacks.
-// Returns 413 Request Entity Too Large if the body exceeds the limit.
-func MaxBodySize(maxBytes int64) func(http.Handler) http.Handler {
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+)
+
+// Default request body limits
+const (
+	DefaultMaxBodyBytes       int64 = 1 << 20 // 1MB
+	DefaultIngestMaxBodyBytes int64 = 5 << 20 // 5MB
+)
+
+// BodyLimitConfig sets the request body limits. Zero limits take the defaults.
+type BodyLimitConfig struct {
+	MaxBytes       int64    // Every route outside IngestPaths
+	IngestMaxBytes int64    // Routes under IngestPaths, which take event batches
+	IngestPaths    []string // Path prefixes, e.g. "/api/v1/ingest"
+	StreamPaths    []string // Exact paths whose NDJSON bodies are not limited
+}
+
+// MaxBodySize limits the request body size to prevent DoS attacks. Requests
+// declaring a larger Content-Length are rejected up front with 413 Request
+// Entity Too Large; other bodies are cut off at the limit, failing the
+// handler's decode. application/x-ndjson bodies sent to StreamPaths are not
+// limited: their handler reads them line by line, bounding each line instead.
+func MaxBodySize(cfg BodyLimitConfig) func(http.Handler) http.Handler {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxBodyBytes
+	}
+	if cfg.IngestMaxBytes <= 0 {
+		cfg.IngestMaxBytes = DefaultIngestMaxBodyBytes
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Body != nil {
-				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			if r.Body == nil || isStream(r, cfg.StreamPaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			maxBytes := cfg.MaxBytes
+			if hasPathPrefix(r.URL.Path, cfg.IngestPaths) {
+				maxBytes = cfg.IngestMaxBytes
 			}
+			if r.ContentLength > maxBytes {
+				apierror.Write(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body exceeds %d bytes", maxBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// isStream reports whether r sends an NDJSON body to one of streamPaths
+func isStream(r *http.Request, streamPaths []string) bool {
+	for _, path := range streamPaths {
+		if r.URL.Path == path {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			return mediaType == "application/x-ndjson"
+		}
+	}
+	return false
+}
+
+// hasPathPrefix reports whether path is one of prefixes or below one
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
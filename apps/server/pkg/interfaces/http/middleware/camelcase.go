@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// APIVersionHeader selects the response format. Most responses serialize
+// domain structs as they are, so their keys are Go field names (TraceID,
+// InputTokens); clients sending "2" get them in camelCase (traceId,
+// inputTokens) instead. Without it responses are unchanged.
+const APIVersionHeader = "X-Lelemon-API-Version"
+
+// CamelCaseResponses makes WriteJSON camelCase the struct field names of the
+// responses to requests with APIVersionHeader set to "2". Responses written
+// any other way pass through untouched.
+func CamelCaseResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(APIVersionHeader) != "2" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", APIVersionHeader)
+		next.ServeHTTP(&camelCaseWriter{ResponseWriter: w}, r)
+	})
+}
+
+// camelCaseWriter marks a response WriteJSON writes in camelCase
+type camelCaseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush passes through for streamed responses
+func (w *camelCaseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (w *camelCaseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteJSON writes v as the JSON body of the response, as json.Encoder does.
+// For clients that opted in with APIVersionHeader, the names of struct fields
+// without a json tag are camelCased (see camelCaseKey). User data is written
+// as it is: map keys (metadata, pricing overrides and other maps keyed by
+// names the client chose) and values that marshal themselves (json.Marshaler,
+// e.g. json.RawMessage) are never renamed, so a payload is kept opaque by its
+// type, whatever field it is under.
+func WriteJSON(w http.ResponseWriter, v any) error {
+	if wantsCamelCase(w) {
+		v = camelCaseValue(reflect.ValueOf(v))
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// wantsCamelCase reports whether w, or a writer it wraps, is a camelCaseWriter
+func wantsCamelCase(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *camelCaseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// camelCaseValue returns v with every struct in it turned into a jsonObject
+// with camelCased field names, following encoding/json's field rules (json
+// tags, omitempty, "-", embedded structs). Maps keep their keys, and values
+// that marshal themselves are returned as they are.
+func camelCaseValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+	if v.CanAddr() && (reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return camelCaseValue(v.Elem())
+	case reflect.Struct:
+		// Copied so pointer-receiver marshalers of its fields are found
+		addressable := reflect.New(t).Elem()
+		addressable.Set(v)
+		return structFields(addressable)
+	case reflect.Map:
+		if v.IsNil() || t.Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = camelCaseValue(iter.Value())
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface() // []byte: base64
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = camelCaseValue(v.Index(i))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// structFields returns the JSON fields of struct v, those promoted from its
+// untagged embedded structs included. As with encoding/json, a field hides
+// deeper ones of the same name, and fields of the same name at the same
+// depth hide each other.
+func structFields(v reflect.Value) jsonObject {
+	var fields []jsonField
+	collectFields(&fields, v, 0)
+
+	shallowest := make(map[string]int)
+	count := make(map[string]int)
+	for _, field := range fields {
+		depth, seen := shallowest[field.name]
+		switch {
+		case !seen || field.depth < depth:
+			shallowest[field.name] = field.depth
+			count[field.name] = 1
+		case field.depth == depth:
+			count[field.name]++
+		}
+	}
+	object := make(jsonObject, 0, len(fields))
+	for _, field := range fields {
+		if field.depth == shallowest[field.name] && count[field.name] == 1 {
+			object = append(object, field)
+		}
+	}
+	return object
+}
+
+func collectFields(fields *[]jsonField, v reflect.Value, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		value := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				collectFields(fields, value, depth+1)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		opts = "," + opts + ","
+		if (strings.Contains(opts, ",omitempty,") && isEmptyValue(value)) ||
+			(strings.Contains(opts, ",omitzero,") && value.IsZero()) {
+			continue
+		}
+		if name == "" {
+			name = camelCaseKey(field.Name)
+		}
+		*fields = append(*fields, jsonField{name: name, value: camelCaseValue(value), depth: depth})
+	}
+}
+
+// isEmptyValue reports whether v is empty for omitempty, as encoding/json does
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// jsonObject is a JSON object keeping its fields in struct order
+type jsonObject []jsonField
+
+type jsonField struct {
+	name  string
+	value any
+	depth int // of the embedded struct it is promoted from; 0 for its own
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// camelCaseKey turns a Go field name into camelCase, acronyms included:
+// TraceID -> traceId, APIKey -> apiKey, TotalCostUSD -> totalCostUsd.
+// Keys not starting with an uppercase letter are returned as they are.
+func camelCaseKey(key string) string {
+	runes := []rune(key)
+	if len(runes) == 0 || !unicode.IsUpper(runes[0]) {
+		return key
+	}
+
+	// Split into words: a word starts at an uppercase letter following a
+	// lowercase letter or digit, or at the last capital of an acronym
+	// followed by a lowercase letter (the K of APIKey)
+	var b strings.Builder
+	wordStart := 0
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if !unicode.IsUpper(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				wordStart = i
+			}
+		}
+		if i == wordStart && i > 0 {
+			b.WriteRune(unicode.ToUpper(r))
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type camelBase struct {
+	TraceID   string
+	Name      string
+	CreatedAt time.Time
+}
+
+type camelResponse struct {
+	camelBase
+	Name         string // hides camelBase.Name
+	TotalCostUSD float64
+	Tagged       string `json:"Tagged_Key"`
+	Skipped      string `json:"-"`
+	Empty        string `json:",omitempty"`
+	Raw          json.RawMessage
+	Metadata     map[string]any
+	Children     []camelBase
+}
+
+func TestWriteJSON(t *testing.T) {
+	value := camelResponse{
+		camelBase:    camelBase{TraceID: "t-1", Name: "base", CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Name:         "outer",
+		TotalCostUSD: 0.5,
+		Tagged:       "kept",
+		Skipped:      "hidden",
+		Raw:          json.RawMessage(`{"UserID":"u-1"}`),
+		Metadata:     map[string]any{"SessionID": map[string]any{"Key": 1}},
+		Children:     []camelBase{{TraceID: "t-2"}},
+	}
+	write := func(version string) string {
+		handler := CamelCaseResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := WriteJSON(w, value); err != nil {
+				t.Fatalf("WriteJSON: %v", err)
+			}
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if version != "" {
+			req.Header.Set(APIVersionHeader, version)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	t.Run("camelCase with version 2", func(t *testing.T) {
+		want := `{"traceId":"t-1","createdAt":"2026-01-02T03:04:05Z","name":"outer","totalCostUsd":0.5,` +
+			`"Tagged_Key":"kept","raw":{"UserID":"u-1"},"metadata":{"SessionID":{"Key":1}},` +
+			`"children":[{"traceId":"t-2","name":"","createdAt":"0001-01-01T00:00:00Z"}]}` + "\n"
+		if got := write("2"); got != want {
+			t.Errorf("got  %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("unchanged without the header", func(t *testing.T) {
+		want, _ := json.Marshal(value)
+		if got := write(""); got != string(want)+"\n" {
+			t.Errorf("got  %s\nwant %s", got, want)
+		}
+	})
+}
+
+func TestCamelCaseKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"TraceID":      "traceId",
+		"APIKey":       "apiKey",
+		"TotalCostUSD": "totalCostUsd",
+		"InputTokens":  "inputTokens",
+		"ID":           "id",
+		"already":      "already",
+	} {
+		if got := camelCaseKey(key); got != want {
+			t.Errorf("camelCaseKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
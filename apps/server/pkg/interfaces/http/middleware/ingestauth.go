@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/domain/repository"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+)
+
+// IngestAuthScheme is a way a telemetry sender may authenticate to an ingest route
+type IngestAuthScheme string
+
+const (
+	IngestAuthAPIKey IngestAuthScheme = "apikey" // project API key (le_...) as the Bearer token
+	IngestAuthBearer IngestAuthScheme = "bearer" // operator-issued Bearer token mapped to a project
+	IngestAuthMTLS   IngestAuthScheme = "mtls"   // verified client certificate subject mapped to a project
+)
+
+// IngestAuthConfig selects how each ingest route authenticates, for agents
+// (OTel collector, Datadog agent) that can't carry a project API key. The zero
+// value accepts API keys only, on every route, with CORS.
+type IngestAuthConfig struct {
+	// Schemes lists the accepted schemes by route, relative to /api/v1
+	// (e.g. "/ingest"). Routes not listed accept API keys only.
+	Schemes map[string][]IngestAuthScheme
+
+	// BearerTokens maps operator-issued tokens to project IDs
+	BearerTokens map[string]string
+
+	// ClientSubjects maps client certificate subject common names to project IDs
+	ClientSubjects map[string]string
+
+	// SubjectHeader is the header a TLS-terminating proxy sets to the verified
+	// client certificate subject. Only set it when every request reaches the
+	// server through that proxy; empty trusts in-process TLS only.
+	SubjectHeader string
+
+	// NoCORS lists the routes, relative to /api/v1, that only servers call:
+	// they get no CORS headers and no preflight responses.
+	NoCORS []string
+}
+
+// SchemesFor returns the schemes accepted on route
+func (c IngestAuthConfig) SchemesFor(route string) []IngestAuthScheme {
+	if schemes := c.Schemes[route]; len(schemes) > 0 {
+		return schemes
+	}
+	return []IngestAuthScheme{IngestAuthAPIKey}
+}
+
+// IngestAuth authenticates an ingest route with the schemes cfg allows for it.
+// A request may present any one of them; the project it maps to is loaded into
+// context as with APIKeyAuth. Chain RequireIngestSignature after it so
+// projects with an ingest signing secret must also sign the request.
+func IngestAuth(store repository.Store, usage *APIKeyUsageTracker, cfg IngestAuthConfig, route string) func(http.Handler) http.Handler {
+	var allowAPIKey, allowBearer, allowMTLS bool
+	for _, scheme := range cfg.SchemesFor(route) {
+		switch scheme {
+		case IngestAuthAPIKey:
+			allowAPIKey = true
+		case IngestAuthBearer:
+			allowBearer = true
+		case IngestAuthMTLS:
+			allowMTLS = true
+		}
+	}
+
+	// Tokens are looked up by hash, like API keys
+	bearerProjects := make(map[string]string, len(cfg.BearerTokens))
+	for token, projectID := range cfg.BearerTokens {
+		hash := sha256.Sum256([]byte(token))
+		bearerProjects[hex.EncodeToString(hash[:])] = projectID
+	}
+
+	apiKeyAuth := APIKeyAuth(store, usage)
+	return func(next http.Handler) http.Handler {
+		withProject := func(w http.ResponseWriter, r *http.Request, projectID string) {
+			project, err := store.GetProjectByID(r.Context(), projectID)
+			if err != nil {
+				if err == entity.ErrNotFound {
+					apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+				} else {
+					apierror.Write(w, http.StatusInternalServerError, "Internal server error")
+				}
+				return
+			}
+			ctx := context.WithValue(r.Context(), ProjectContextKey, project)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+			token := ""
+			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+				token = parts[1]
+			}
+
+			if token != "" {
+				if allowAPIKey && strings.HasPrefix(token, entity.APIKeyPrefix) {
+					apiKeyAuth(next).ServeHTTP(w, r)
+					return
+				}
+				if allowBearer {
+					hash := sha256.Sum256([]byte(token))
+					if projectID, ok := bearerProjects[hex.EncodeToString(hash[:])]; ok {
+						withProject(w, r, projectID)
+						return
+					}
+				}
+			}
+
+			if allowMTLS {
+				if projectID, ok := cfg.ClientSubjects[clientSubject(r, cfg.SubjectHeader)]; ok {
+					withProject(w, r, projectID)
+					return
+				}
+			}
+
+			apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
+		})
+	}
+}
+
+// clientSubject returns the common name of the request's verified client
+// certificate, from the TLS connection or else from the proxy's subject header
+func clientSubject(r *http.Request, header string) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	if header == "" {
+		return ""
+	}
+	subject := r.Header.Get(header)
+	// Proxies send the distinguished name, e.g. "CN=collector,O=Acme"
+	for _, rdn := range strings.Split(subject, ",") {
+		if cn, ok := strings.CutPrefix(strings.TrimSpace(rdn), "CN="); ok {
+			return cn
+		}
+	}
+	return subject
+}
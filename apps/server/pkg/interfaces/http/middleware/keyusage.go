@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lelemon/server/pkg/domain/repository"
+)
+
+// DefaultKeyUsageFlushInterval bounds how often each key's usage is written:
+// at most once per interval, regardless of request volume.
+const DefaultKeyUsageFlushInterval = time.Minute
+
+// APIKeyUsageTracker records API key usage (last-seen + request count) without
+// touching the database on the request path. Record only bumps an in-memory
+// counter; a background loop flushes the accumulated deltas every interval.
+type APIKeyUsageTracker struct {
+	store    repository.ProjectStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*keyUsage // key hash -> unflushed usage
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type keyUsage struct {
+	projectID  string
+	lastUsedAt time.Time
+	requests   int64
+}
+
+// NewAPIKeyUsageTracker creates a tracker and starts its flush loop.
+// Call Stop on shutdown to flush what is still buffered.
+func NewAPIKeyUsageTracker(store repository.ProjectStore, interval time.Duration) *APIKeyUsageTracker {
+	if interval <= 0 {
+		interval = DefaultKeyUsageFlushInterval
+	}
+
+	t := &APIKeyUsageTracker{
+		store:    store,
+		interval: interval,
+		pending:  make(map[string]*keyUsage),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go t.flushLoop()
+
+	return t
+}
+
+// Record notes one authenticated request for the given key. Safe for concurrent use.
+func (t *APIKeyUsageTracker) Record(projectID, keyHash string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.pending[keyHash]
+	if !ok {
+		u = &keyUsage{projectID: projectID}
+		t.pending[keyHash] = u
+	}
+	u.lastUsedAt = now
+	u.requests++
+}
+
+// Flush writes all buffered usage to the store. Entries that fail to write are
+// merged back so they are retried on the next flush.
+func (t *APIKeyUsageTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = make(map[string]*keyUsage)
+	t.mu.Unlock()
+
+	var firstErr error
+	for keyHash, u := range batch {
+		if err := t.store.RecordAPIKeyUsage(ctx, u.projectID, keyHash, u.lastUsedAt, u.requests); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			t.requeue(keyHash, u)
+		}
+	}
+
+	return firstErr
+}
+
+// Stop halts the flush loop and writes any remaining usage.
+func (t *APIKeyUsageTracker) Stop(ctx context.Context) error {
+	t.once.Do(func() { close(t.stop) })
+	<-t.done
+	return t.Flush(ctx)
+}
+
+func (t *APIKeyUsageTracker) requeue(keyHash string, u *keyUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing, ok := t.pending[keyHash]
+	if !ok {
+		t.pending[keyHash] = u
+		return
+	}
+	existing.requests += u.requests
+	if u.lastUsedAt.After(existing.lastUsedAt) {
+		existing.lastUsedAt = u.lastUsedAt
+	}
+}
+
+func (t *APIKeyUsageTracker) flushLoop() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := t.Flush(ctx); err != nil {
+				slog.Warn("api key usage flush failed", "error", err)
+			}
+			cancel()
+		}
+	}
+}
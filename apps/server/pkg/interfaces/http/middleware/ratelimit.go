@@ -1,99 +1,188 @@
 package middleware
 
 import (
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
-)
 
-// RateLimiter implements a simple in-memory rate limiter
-type RateLimiter struct {
-	mu       sync.Mutex
-	clients  map[string]*clientWindow
-	limit    int
-	window   time.Duration
-	cleanup  time.Duration
-}
+	"github.com/go-chi/chi/v5"
 
-type clientWindow struct {
-	count    int
-	windowStart time.Time
-}
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+)
 
-// NewRateLimiter creates a new rate limiter
-// limit: max requests per window
-// window: time window duration
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		clients: make(map[string]*clientWindow),
-		limit:   limit,
-		window:  window,
-		cleanup: window * 2,
-	}
+// RateLimitKey names what a rate limit policy counts requests by
+type RateLimitKey string
 
-	// Start cleanup goroutine
-	go rl.cleanupLoop()
+const (
+	RateLimitKeyIP      RateLimitKey = "ip"      // client IP address
+	RateLimitKeyProject RateLimitKey = "project" // project of the API key
+	RateLimitKeyUser    RateLimitKey = "user"    // dashboard session user
+)
 
-	return rl
+// RateLimitPolicy limits the requests to a set of routes. Routes are chi
+// route patterns, e.g. /api/v1/traces/{id}; a trailing /* matches every
+// route under the prefix. Requests are counted per Key in a token bucket
+// holding Burst tokens (Limit when 0) and refilling Limit per Period, so
+// Limit per Period is the sustained rate. Policies sharing a Name share
+// their buckets; a Limit of 0 leaves the routes unlimited.
+type RateLimitPolicy struct {
+	Name   string
+	Routes []string
+	Limit  int
+	Period time.Duration
+	Burst  int
+	Key    RateLimitKey
 }
 
-func (rl *RateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(rl.cleanup)
-	defer ticker.Stop()
+// DefaultRateLimitPolicies are the policies in effect unless overridden by
+// name
+func DefaultRateLimitPolicies() []RateLimitPolicy {
+	return []RateLimitPolicy{
+		// Unlimited: SDKs already batch
+		{Name: "ingest", Routes: []string{"/api/v1/ingest", "/api/v1/ingest/*", "/api/v1/otlp/*", "/api/v1/proxy/*"}, Key: RateLimitKeyProject},
+		// Brute force protection
+		{Name: "auth", Routes: []string{"/api/v1/auth/login", "/api/v1/auth/register"}, Limit: 10, Period: time.Minute, Key: RateLimitKeyIP},
+		// Each login start holds a pending OAuth state until the callback
+		{Name: "oauth", Routes: []string{"/api/v1/auth/google"}, Limit: 20, Period: time.Minute, Key: RateLimitKeyIP},
+		{Name: "public", Routes: []string{"/api/v1/public/*"}, Limit: 60, Period: time.Minute, Key: RateLimitKeyIP},
+		// API key routes (traces, analytics, ...); dashboard sessions carry no project
+		{Name: "api", Routes: []string{"/api/v1/*"}, Limit: 100, Period: time.Minute, Key: RateLimitKeyProject},
+	}
+}
 
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for key, client := range rl.clients {
-			if now.Sub(client.windowStart) > rl.window*2 {
-				delete(rl.clients, key)
+// MergeRateLimitPolicies returns policies followed by the defaults not
+// overridden by name. The first policy matching a route applies.
+func MergeRateLimitPolicies(policies, defaults []RateLimitPolicy) []RateLimitPolicy {
+	merged := append([]RateLimitPolicy(nil), policies...)
+	for _, d := range defaults {
+		overridden := false
+		for _, p := range policies {
+			if p.Name == d.Name {
+				overridden = true
+				break
 			}
 		}
-		rl.mu.Unlock()
+		if !overridden {
+			merged = append(merged, d)
+		}
 	}
+	return merged
 }
 
-// Allow checks if a request is allowed for the given key
-func (rl *RateLimiter) Allow(key string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// ParseRateLimitPolicies parses policies written as
+// "<name> <route>[,<route>...] <limit>/<period> [burst=<n>] [key=ip|project|user]",
+// e.g. "analytics /api/v1/analytics/* 30/1m burst=10 key=project".
+// The key defaults to ip.
+func ParseRateLimitPolicies(specs []string) ([]RateLimitPolicy, error) {
+	policies := make([]RateLimitPolicy, 0, len(specs))
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("rate limit %q: expected a name, routes and limit/period", spec)
+		}
+		p := RateLimitPolicy{Name: fields[0], Routes: strings.Split(fields[1], ","), Key: RateLimitKeyIP}
 
-	now := time.Now()
-	client, exists := rl.clients[key]
+		limit, period, ok := strings.Cut(fields[2], "/")
+		var err error
+		if p.Limit, err = strconv.Atoi(limit); !ok || err != nil || p.Limit < 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid limit %q", spec, fields[2])
+		}
+		if p.Period, err = time.ParseDuration(period); err != nil || p.Period <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid period %q", spec, period)
+		}
 
-	if !exists || now.Sub(client.windowStart) > rl.window {
-		// New window
-		rl.clients[key] = &clientWindow{
-			count:       1,
-			windowStart: now,
+		for _, option := range fields[3:] {
+			name, value, _ := strings.Cut(option, "=")
+			switch name {
+			case "burst":
+				if p.Burst, err = strconv.Atoi(value); err != nil || p.Burst < 0 {
+					return nil, fmt.Errorf("rate limit %q: invalid burst %q", spec, value)
+				}
+			case "key":
+				switch key := RateLimitKey(value); key {
+				case RateLimitKeyIP, RateLimitKeyProject, RateLimitKeyUser:
+					p.Key = key
+				default:
+					return nil, fmt.Errorf("rate limit %q: unknown key %q", spec, value)
+				}
+			default:
+				return nil, fmt.Errorf("rate limit %q: unknown option %q", spec, option)
+			}
 		}
-		return true
+		policies = append(policies, p)
 	}
+	return policies, nil
+}
 
-	if client.count >= rl.limit {
-		return false
+// matches reports whether the policy covers the chi route pattern
+func (p *RateLimitPolicy) matches(route string) bool {
+	for _, pattern := range p.Routes {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(route, prefix+"/") {
+				return true
+			}
+		} else if pattern == route {
+			return true
+		}
 	}
+	return false
+}
 
-	client.count++
-	return true
+// RateLimitStore holds the token buckets of rate-limited clients.
+// MemoryRateLimitStore keeps them per process; a shared store (e.g. Redis)
+// lets several replicas enforce one limit.
+type RateLimitStore interface {
+	// Take takes a token from key's bucket, which holds up to burst tokens
+	// and refills at rate tokens per second. When the bucket is empty it
+	// reports false and how long until a token is available.
+	Take(key string, rate float64, burst int) (allowed bool, retryAfter time.Duration)
 }
 
-// RateLimit creates middleware that limits requests per project
-func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
+// RateLimit creates middleware that applies the first of policies matching
+// the request's route, answering 429 with Retry-After once the client's
+// bucket is empty. It must run after the auth middleware of the routes it
+// covers: a policy keyed by project or user applies only to requests
+// authenticated as one.
+func RateLimit(store RateLimitStore, policies []RateLimitPolicy) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Use project ID as rate limit key
-			project := GetProject(r.Context())
-			key := "unknown"
-			if project != nil {
-				key = project.ID
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			var policy *RateLimitPolicy
+			for i := range policies {
+				if policies[i].matches(route) {
+					policy = &policies[i]
+					break
+				}
+			}
+			if policy == nil || policy.Limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key, ok := rateLimitKey(r, policy.Key)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			if !limiter.Allow(key) {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Retry-After", "60")
-				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"error":"Rate limit exceeded","retryAfter":60}`))
+			burst := policy.Burst
+			if burst <= 0 {
+				burst = policy.Limit
+			}
+			rate := float64(policy.Limit) / policy.Period.Seconds()
+			if allowed, retryAfter := store.Take(policy.Name+":"+key, rate, burst); !allowed {
+				seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				apierror.WriteCode(w, http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests. Please try again later.",
+					map[string]any{"retryAfter": seconds})
 				return
 			}
 
@@ -102,29 +191,100 @@ func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
 	}
 }
 
-// RateLimitByIP creates middleware that limits requests per IP address
-// Useful for auth endpoints to prevent brute force attacks
-func RateLimitByIP(limiter *RateLimiter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Use client IP as rate limit key
-			ip := r.Header.Get("X-Real-IP")
-			if ip == "" {
-				ip = r.Header.Get("X-Forwarded-For")
-			}
-			if ip == "" {
-				ip = r.RemoteAddr
-			}
+// rateLimitKey returns what the request is counted by, or false when the
+// request has no such identity (e.g. no project on a dashboard route)
+func rateLimitKey(r *http.Request, key RateLimitKey) (string, bool) {
+	switch key {
+	case RateLimitKeyProject:
+		if project := GetProject(r.Context()); project != nil {
+			return project.ID, true
+		}
+		return "", false
+	case RateLimitKeyUser:
+		if user := GetUser(r.Context()); user != nil {
+			return user.UserID, true
+		}
+		return "", false
+	default:
+		return clientIP(r), true
+	}
+}
 
-			if !limiter.Allow(ip) {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Retry-After", "60")
-				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"error":"Too many requests. Please try again later.","retryAfter":60}`))
-				return
-			}
+// clientIP returns the address the request came from, as reported by a
+// reverse proxy when there is one
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
 
-			next.ServeHTTP(w, r)
-		})
+// MemoryRateLimitStore is an in-process RateLimitStore
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	rate   float64
+	burst  float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// Take implements RateLimitStore
+func (m *MemoryRateLimitStore) Take(key string, rate float64, burst int) (bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		m.buckets[key] = b
+	}
+	b.rate, b.burst = rate, float64(burst)
+	b.tokens = b.refilled(now)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
 	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// sweep drops, at most once a minute, the buckets that have refilled: a
+// full bucket behaves as a missing one
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+	m.swept = now
+	for key, b := range m.buckets {
+		if b.refilled(now) >= b.burst {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// refilled returns the bucket's tokens at now
+func (b *tokenBucket) refilled(now time.Time) float64 {
+	return math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
 }
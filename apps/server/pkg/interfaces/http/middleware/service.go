@@ -4,6 +4,8 @@ import (
 	"crypto/subtle"
 	"net/http"
 	"strings"
+
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 // ServiceAuth authenticates trusted service-to-service callers (the MCP authorization server
@@ -13,13 +15,13 @@ func ServiceAuth(secret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if secret == "" {
-				http.Error(w, `{"error":"service auth not configured"}`, http.StatusServiceUnavailable)
+				apierror.Write(w, http.StatusServiceUnavailable, "service auth not configured")
 				return
 			}
 			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" ||
 				subtle.ConstantTimeCompare([]byte(parts[1]), []byte(secret)) != 1 {
-				http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 			next.ServeHTTP(w, r)
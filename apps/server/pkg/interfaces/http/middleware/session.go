@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/lelemon/server/pkg/infrastructure/auth"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 const (
@@ -26,16 +27,16 @@ func SessionAuth(jwtService *auth.JWTService) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			token := extractSessionToken(r)
 			if token == "" {
-				http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
 			claims, err := jwtService.ValidateToken(token)
 			if err != nil {
 				if err == auth.ErrExpiredToken {
-					http.Error(w, `{"error":"Token expired"}`, http.StatusUnauthorized)
+					apierror.Write(w, http.StatusUnauthorized, "Token expired")
 				} else {
-					http.Error(w, `{"error":"Invalid token"}`, http.StatusUnauthorized)
+					apierror.Write(w, http.StatusUnauthorized, "Invalid token")
 				}
 				return
 			}
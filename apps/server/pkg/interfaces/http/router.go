@@ -3,18 +3,22 @@ package http
 import (
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 
 	"github.com/lelemon/server/pkg/application/analytics"
 	appauth "github.com/lelemon/server/pkg/application/auth"
+	"github.com/lelemon/server/pkg/application/export"
 	"github.com/lelemon/server/pkg/application/ingest"
+	"github.com/lelemon/server/pkg/application/otlp"
 	"github.com/lelemon/server/pkg/application/project"
+	"github.com/lelemon/server/pkg/application/proxy"
 	"github.com/lelemon/server/pkg/application/trace"
+	"github.com/lelemon/server/pkg/application/traceimport"
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/server/pkg/infrastructure/auth"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 	"github.com/lelemon/server/pkg/interfaces/http/handler"
 	"github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
@@ -31,9 +35,28 @@ type RouterConfig struct {
 	JWTService     *auth.JWTService
 	FrontendURL    string
 
+	// FrontendResolver picks the frontend each OAuth login lands on, e.g. by
+	// organization, among the origins of FrontendAllowlist. Optional; nil
+	// lands every login on FrontendURL.
+	FrontendResolver  handler.FrontendResolver
+	FrontendAllowlist *handler.FrontendAllowlist
+
 	// Security
 	AllowedOrigins []string // CORS allowed origins
 
+	// IngestAuth selects the auth schemes and CORS of the ingest routes. The
+	// zero value accepts project API keys only.
+	IngestAuth middleware.IngestAuthConfig
+
+	// KeyUsage records API key last-seen/request counts. Optional; nil disables tracking.
+	KeyUsage *middleware.APIKeyUsageTracker
+
+	// ExportSvc exports project traces to object storage. Optional; nil leaves the routes unmounted.
+	ExportSvc *export.Service
+
+	// ProxySvc forwards OpenAI requests and records them. Optional; nil leaves the routes unmounted.
+	ProxySvc *proxy.Service
+
 	// Extensions allow adding routes without modifying core code.
 	// Used by enterprise edition to add organization, billing, etc.
 	Extensions []RouterExtension
@@ -41,6 +64,24 @@ type RouterConfig struct {
 	// FeaturesConfig defines what features are available.
 	// If nil, defaults to community edition features.
 	FeaturesConfig *handler.FeaturesConfig
+
+	// StoreBackends are the database backends in use, reported by GET /version
+	StoreBackends handler.StoreBackends
+
+	// AdminToken authenticates operator maintenance routes (/admin/...) as a
+	// Bearer token. Optional; empty leaves the routes unmounted.
+	AdminToken string
+
+	// MaxBodyBytes limits request bodies; IngestMaxBodyBytes applies instead
+	// to the ingest routes (ingest, OTLP, proxy, trace import). Zero takes the
+	// defaults (1MB and 5MB).
+	MaxBodyBytes       int64
+	IngestMaxBodyBytes int64
+
+	// RateLimits override middleware.DefaultRateLimitPolicies by name.
+	// RateLimitStore holds the clients' counts; nil keeps them in memory.
+	RateLimits     []middleware.RateLimitPolicy
+	RateLimitStore middleware.RateLimitStore
 }
 
 // NewRouter creates a new HTTP router with all routes configured
@@ -52,18 +93,37 @@ func NewRouter(cfg RouterConfig) http.Handler {
 	r.Use(middleware.Logging)
 	r.Use(chimiddleware.Recoverer)
 	r.Use(middleware.SecurityHeaders)
-	r.Use(middleware.MaxBodySize(5 << 20)) // 5MB max request body
-	r.Use(corsMiddleware(cfg.AllowedOrigins))
+	r.Use(middleware.MaxBodySize(middleware.BodyLimitConfig{
+		MaxBytes:       cfg.MaxBodyBytes,
+		IngestMaxBytes: cfg.IngestMaxBodyBytes,
+		IngestPaths:    []string{"/api/v1/ingest", "/api/v1/otlp", "/api/v1/proxy", "/api/v1/traces/import"},
+		StreamPaths:    []string{"/api/v1/ingest"}, // NDJSON ingest streams
+	}))
+	r.Use(corsMiddleware(cfg.AllowedOrigins, cfg.IngestAuth.NoCORS))
+	r.Use(middleware.CamelCaseResponses) // camelCase JSON keys for clients opting in with X-Lelemon-API-Version: 2
+
+	// Unmatched routes get the standard error envelope too
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, http.StatusNotFound, "Not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, http.StatusMethodNotAllowed, "Method not allowed")
+	})
 
 	// Health checks (no auth required)
-	healthHandler := handler.NewHealthHandler(cfg.PrimaryStore, cfg.AnalyticsStore)
+	healthHandler := handler.NewHealthHandler(cfg.PrimaryStore, cfg.AnalyticsStore, cfg.IngestSvc)
 	r.Get("/health", healthHandler.Handle)
 	r.Get("/health/live", handler.LivenessHandler)
 	r.Get("/health/ready", healthHandler.ReadinessHandler)
 
-	// Rate limiters
-	rateLimiter := middleware.NewRateLimiter(100, time.Minute)         // 100 req/min per project
-	authRateLimiter := middleware.NewRateLimiter(10, time.Minute)      // 10 req/min per IP for auth
+	// Rate limits, one middleware applying the policy matching each route. It
+	// runs after each group's auth so policies can count per project or user.
+	rateLimitStore := cfg.RateLimitStore
+	if rateLimitStore == nil {
+		rateLimitStore = middleware.NewMemoryRateLimitStore()
+	}
+	rateLimit := middleware.RateLimit(rateLimitStore,
+		middleware.MergeRateLimitPolicies(cfg.RateLimits, middleware.DefaultRateLimitPolicies()))
 
 	// API v1 routes
 	r.Route("/api/v1", func(r chi.Router) {
@@ -71,15 +131,24 @@ func NewRouter(cfg RouterConfig) http.Handler {
 		featuresHandler := handler.NewFeaturesHandler(cfg.FeaturesConfig)
 		r.Get("/features", featuresHandler.Handle)
 
+		// Version and build info (no auth - support uses it to diagnose deployments)
+		versionHandler := handler.NewVersionHandler(cfg.FeaturesConfig, cfg.StoreBackends)
+		r.Get("/version", versionHandler.Handle)
+
+		// Recognized span types (no auth - SDKs validate spanType client-side)
+		r.Get("/span-types", handler.SpanTypesHandler)
+
 		// Auth routes (rate limited by IP to prevent brute force)
 		authHandler := handler.NewAuthHandler(cfg.AuthSvc, cfg.FrontendURL)
+		authHandler.SetFrontends(cfg.FrontendResolver, cfg.FrontendAllowlist)
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.RateLimitByIP(authRateLimiter))
+			r.Use(rateLimit)
 			r.Post("/auth/register", authHandler.Register)
 			r.Post("/auth/login", authHandler.Login)
 		})
-		// OAuth routes (no rate limit - redirect-based)
-		r.Get("/auth/google", authHandler.GoogleAuth)
+		// OAuth routes (redirect-based). Starting a login is rate limited by
+		// IP, as each start holds a pending state until its callback.
+		r.With(rateLimit).Get("/auth/google", authHandler.GoogleAuth)
 		r.Get("/auth/google/callback", authHandler.GoogleCallback)
 		r.Post("/auth/oauth/exchange", authHandler.ExchangeOAuthToken)
 		r.Post("/auth/logout", authHandler.Logout)
@@ -87,32 +156,69 @@ func NewRouter(cfg RouterConfig) http.Handler {
 		// Auth routes (session auth required)
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.SessionAuth(cfg.JWTService))
+			r.Use(rateLimit)
 			r.Get("/auth/me", authHandler.Me)
 			r.Post("/auth/refresh", authHandler.Refresh)
 		})
 
-		// Ingest endpoint (no rate limit - SDK already batches)
+		// Public status-page metrics (no auth; projects opt in with publicMetricsEnabled)
+		publicHandler := handler.NewPublicHandler(cfg.ProjectSvc, cfg.AnalyticsSvc)
+		r.With(rateLimit).Get("/public/projects/{id}/metrics", publicHandler.Metrics)
+
+		// Projects with an ingest signing secret must sign every write request,
+		// whichever route or auth scheme it comes through
+		requireSignature := middleware.RequireIngestSignature(cfg.IngestMaxBodyBytes)
+
+		// Ingest endpoints (unlimited by default - SDK already batches). Each
+		// route accepts the auth schemes configured for it (API key by default).
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.APIKeyAuth(cfg.PrimaryStore))
+			ingestAuth := func(route string) func(http.Handler) http.Handler {
+				return chi.Chain(middleware.IngestAuth(cfg.PrimaryStore, cfg.KeyUsage, cfg.IngestAuth, route), requireSignature, rateLimit).Handler
+			}
 
 			ingestHandler := handler.NewIngestHandler(cfg.IngestSvc)
-			r.Post("/ingest", ingestHandler.Handle)
+			r.With(ingestAuth("/ingest")).Post("/ingest", ingestHandler.Handle)
+			r.With(ingestAuth("/ingest/dry-run")).Post("/ingest/dry-run", ingestHandler.DryRun)
+
+			// OTLP/HTTP metrics receiver: gen_ai token usage is recorded as llm spans
+			otlpHandler := handler.NewOTLPHandler(otlp.NewService(cfg.IngestSvc))
+			r.With(ingestAuth("/otlp/v1/metrics")).Post("/otlp/v1/metrics", otlpHandler.Metrics)
+
+			// OpenAI-compatible proxy: the project API key authenticates, the
+			// caller's OpenAI key travels in X-Upstream-Authorization
+			if cfg.ProxySvc != nil {
+				proxyHandler := handler.NewProxyHandler(cfg.ProxySvc)
+				r.With(ingestAuth("/proxy/openai/v1/chat/completions")).Post("/proxy/openai/v1/chat/completions", proxyHandler.ChatCompletions)
+			}
 		})
 
 		// API Key authenticated routes (rate limited). Also reachable by the MCP
 		// authorization server acting for a project via the service path (see ProjectAuth).
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.ProjectAuth(cfg.PrimaryStore, os.Getenv("MCP_STORE_SECRET")))
-			r.Use(middleware.RateLimit(rateLimiter))
+			r.Use(middleware.ProjectAuth(cfg.PrimaryStore, os.Getenv("MCP_STORE_SECRET"), cfg.KeyUsage))
+			r.Use(rateLimit)
 
 			// Traces
 			traceHandler := handler.NewTraceHandler(cfg.TraceSvc)
-			r.Post("/traces", traceHandler.Create)
+			r.With(requireSignature).Post("/traces", traceHandler.Create)
 			r.Get("/traces", traceHandler.List)
+			r.With(requireSignature).Delete("/traces", traceHandler.DeleteByFilter)
 			r.Get("/traces/{id}", traceHandler.Get)
 			r.Get("/traces/{id}/detail", traceHandler.GetDetail)
-			r.Patch("/traces/{id}", traceHandler.Update)
-			r.Post("/traces/{id}/spans", traceHandler.AddSpan)
+			r.Get("/traces/{id}/spans", traceHandler.ListSpans)
+			r.Get("/traces/{id}/children", traceHandler.Children)
+			r.With(requireSignature).Patch("/traces/{id}", traceHandler.Update)
+			r.With(requireSignature).Post("/traces/{id}/spans", traceHandler.AddSpan)
+			r.With(requireSignature).Patch("/traces/{id}/spans/{spanId}", traceHandler.UpdateSpan)
+			r.With(requireSignature).Post("/traces/{id}/feedback", traceHandler.Feedback)
+			r.With(requireSignature).Post("/traces/{id}/copy", traceHandler.Copy)
+
+			// Trace import (Langfuse exports), recorded through ingest
+			importHandler := handler.NewImportHandler(traceimport.NewService(cfg.IngestSvc))
+			r.With(requireSignature).Post("/traces/import", importHandler.Import)
+
+			// Spans
+			r.Post("/spans/search", traceHandler.SearchSpans)
 
 			// Sessions
 			r.Get("/sessions", traceHandler.ListSessions)
@@ -122,22 +228,32 @@ func NewRouter(cfg RouterConfig) http.Handler {
 			r.Get("/analytics/summary", analyticsHandler.Summary)
 			r.Get("/analytics/usage", analyticsHandler.Usage)
 			r.Get("/analytics/models", analyticsHandler.Models)
+			r.Get("/analytics/cache-efficiency", analyticsHandler.CacheEfficiency)
+			r.Get("/analytics/feedback", analyticsHandler.Feedback)
+			r.Get("/analytics/sessions", analyticsHandler.Sessions)
 			r.Get("/analytics/tags", analyticsHandler.Tags)
+			r.Get("/analytics/cost-by-tag", analyticsHandler.Tags)
 			r.Get("/analytics/top-users", analyticsHandler.TopUsers)
+			r.Get("/analytics/tool-violations", analyticsHandler.ToolViolations)
+			r.Get("/analytics/evals", analyticsHandler.Evals)
+			r.Get("/analytics/unpriced-models", analyticsHandler.UnpricedModels)
+			r.Get("/analytics/storage", analyticsHandler.Storage)
 			r.Get("/analytics/heatmap", analyticsHandler.Heatmap)
 			r.Get("/analytics/latency/distribution", analyticsHandler.LatencyDistribution)
+			r.Get("/analytics/latency-histogram", analyticsHandler.LatencyHistogram)
 			r.Get("/analytics/latency/timeseries", analyticsHandler.LatencyTimeSeries)
 
 			// Project (current - via API key)
 			projectHandler := handler.NewProjectHandler(cfg.ProjectSvc)
 			r.Get("/projects/me", projectHandler.GetCurrent)
-			r.Patch("/projects/me", projectHandler.UpdateCurrent)
-			r.Post("/projects/api-key", projectHandler.RotateAPIKey)
+			r.With(requireSignature).Patch("/projects/me", projectHandler.UpdateCurrent)
+			r.With(requireSignature).Post("/projects/api-key", projectHandler.RotateAPIKey)
 		})
 
 		// Dashboard routes (session auth)
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.SessionAuth(cfg.JWTService))
+			r.Use(rateLimit)
 
 			dashboardHandler := handler.NewDashboardHandler(cfg.ProjectSvc, cfg.TraceSvc, cfg.AnalyticsSvc)
 
@@ -151,6 +267,7 @@ func NewRouter(cfg RouterConfig) http.Handler {
 			// Project data
 			r.Get("/dashboard/projects/{id}/traces", dashboardHandler.GetTraces)
 			r.Delete("/dashboard/projects/{id}/traces", dashboardHandler.DeleteAllTraces)
+			r.Post("/dashboard/projects/{id}/recost", dashboardHandler.RecostSpans)
 			r.Get("/dashboard/projects/{id}/traces/{traceId}", dashboardHandler.GetTrace)
 			r.Get("/dashboard/projects/{id}/sessions", dashboardHandler.GetSessions)
 			r.Get("/dashboard/projects/{id}/stats", dashboardHandler.GetStats)
@@ -162,9 +279,32 @@ func NewRouter(cfg RouterConfig) http.Handler {
 			r.Get("/dashboard/projects/{id}/analytics/top-users", dashboardHandler.GetTopUsers)
 			r.Get("/dashboard/projects/{id}/analytics/heatmap", dashboardHandler.GetHeatmap)
 			r.Get("/dashboard/projects/{id}/analytics/latency/distribution", dashboardHandler.GetLatencyDistribution)
+			r.Get("/dashboard/projects/{id}/analytics/latency-histogram", dashboardHandler.GetLatencyHistogram)
 			r.Get("/dashboard/projects/{id}/analytics/latency/timeseries", dashboardHandler.GetLatencyTimeSeries)
 		})
 
+		// Project exports (session auth), mounted only when an export bucket is configured
+		if cfg.ExportSvc != nil {
+			exportHandler := handler.NewExportHandler(cfg.ProjectSvc, cfg.ExportSvc)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.SessionAuth(cfg.JWTService))
+				r.Use(rateLimit)
+				r.Post("/projects/{id}/export-to-s3", exportHandler.Start)
+				r.Get("/projects/{id}/exports/{jobId}", exportHandler.Get)
+			})
+		}
+
+		// Operator maintenance (shared-secret auth), mounted only when an admin token is configured
+		if cfg.AdminToken != "" {
+			adminHandler := handler.NewAdminHandler(cfg.AnalyticsStore, cfg.IngestSvc)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.ServiceAuth(cfg.AdminToken))
+				r.Post("/admin/optimize", adminHandler.Optimize)
+				r.Get("/admin/ingest/maintenance", adminHandler.GetIngestMaintenance)
+				r.Post("/admin/ingest/maintenance", adminHandler.SetIngestMaintenance)
+			})
+		}
+
 		// MCP OAuth 2.1 authorization server support. The MCP (mcify, out-of-process) is the
 		// authorization server; the backend only persists its state and bridges the dashboard
 		// session. Mounted only when the primary store can persist OAuth data and the secrets are
@@ -194,6 +334,7 @@ func NewRouter(cfg RouterConfig) http.Handler {
 		deps := &RouterDeps{
 			PrimaryStore:   cfg.PrimaryStore,
 			AnalyticsStore: cfg.AnalyticsStore,
+			IngestSvc:      cfg.IngestSvc,
 			JWTService:     cfg.JWTService,
 			GetUserID: func(req *http.Request) string {
 				user := middleware.GetUser(req.Context())
@@ -220,15 +361,25 @@ func NewRouter(cfg RouterConfig) http.Handler {
 }
 
 // corsMiddleware handles CORS headers with origin allowlist
-func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+func corsMiddleware(allowedOrigins, exemptRoutes []string) func(http.Handler) http.Handler {
 	// Build a map for O(1) lookups
 	originMap := make(map[string]bool, len(allowedOrigins))
 	for _, origin := range allowedOrigins {
 		originMap[origin] = true
 	}
+	// Server-to-server routes (relative to /api/v1) browsers never call
+	exempt := make(map[string]bool, len(exemptRoutes))
+	for _, route := range exemptRoutes {
+		exempt["/api/v1"+route] = true
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			origin := r.Header.Get("Origin")
 
 			// Check if origin is allowed
@@ -238,7 +389,7 @@ func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 			}
 
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+middleware.APIVersionHeader)
 			w.Header().Set("Access-Control-Max-Age", "86400")
 			w.Header().Set("Vary", "Origin")
 
@@ -29,18 +29,21 @@ func AssertJSON(t *testing.T, rr *httptest.ResponseRecorder, v interface{}) {
 	}
 }
 
-// AssertError checks that the response contains an error message.
+// AssertError checks that the response is an error envelope with a code and message.
 func AssertError(t *testing.T, rr *httptest.ResponseRecorder, expectedStatus int) {
 	t.Helper()
 	AssertStatus(t, rr, expectedStatus)
 
 	var resp struct {
-		Error string `json:"error"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
 	}
 	AssertJSON(t, rr, &resp)
 
-	if resp.Error == "" {
-		t.Error("expected error message in response")
+	if resp.Error.Code == "" || resp.Error.Message == "" {
+		t.Errorf("expected error code and message in response, got %s", rr.Body.String())
 	}
 }
 
@@ -28,7 +28,7 @@ export interface Trace {
   updatedAt: string;
 }
 
-export type SpanType = 'llm' | 'agent' | 'tool' | 'retrieval' | 'embedding' | 'guardrail' | 'rerank' | 'custom';
+export type SpanType = 'llm' | 'agent' | 'tool' | 'retrieval' | 'embedding' | 'guardrail' | 'rerank' | 'eval' | 'custom';
 
 export interface Span {
   id: string;
@@ -305,10 +305,12 @@ export interface AnalyticsParams {
 
 class APIError extends Error {
   status: number;
+  code?: string;
 
-  constructor(message: string, status: number) {
+  constructor(message: string, status: number, code?: string) {
     super(message);
     this.status = status;
+    this.code = code;
     this.name = 'APIError';
   }
 }
@@ -463,13 +465,16 @@ async function request<T>(
 
   if (!response.ok) {
     let errorMessage = `HTTP ${response.status}`;
+    let errorCode: string | undefined;
     try {
-      const error = await response.json();
-      errorMessage = error.error || error.message || errorMessage;
+      // Error envelope: { error: { code, message, details } }
+      const { error } = await response.json();
+      errorMessage = error?.message || errorMessage;
+      errorCode = error?.code;
     } catch {
       // ignore JSON parse error
     }
-    throw new APIError(errorMessage, response.status);
+    throw new APIError(errorMessage, response.status, errorCode);
   }
 
   const text = await response.text();
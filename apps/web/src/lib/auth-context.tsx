@@ -91,7 +91,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
 
     if (!response.ok) {
       const error = await response.json();
-      throw new Error(error.error || 'Login failed');
+      throw new Error(error.error?.message || 'Login failed');
     }
 
     const data = await response.json();
@@ -123,7 +123,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
 
     if (!response.ok) {
       const error = await response.json();
-      throw new Error(error.error || 'Registration failed');
+      throw new Error(error.error?.message || 'Registration failed');
     }
 
     const data = await response.json();
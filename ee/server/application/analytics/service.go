@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coreAnalytics "github.com/lelemon/server/pkg/application/analytics"
+	coreEntity "github.com/lelemon/server/pkg/domain/entity"
+
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/domain/repository"
+)
+
+// defaultPeriod matches the core analytics summary default (last 7 days)
+const defaultPeriod = 7 * 24 * time.Hour
+
+// StatsProvider computes a single project's stats.
+// The core analytics service implements it.
+type StatsProvider interface {
+	GetSummary(ctx context.Context, projectID string, req *coreAnalytics.SummaryRequest) (*coreEntity.Stats, error)
+}
+
+// Service rolls up analytics across an organization's projects
+type Service struct {
+	projects repository.OrganizationProjectStore
+	stats    StatsProvider
+}
+
+// NewService creates a new organization analytics service
+func NewService(projects repository.OrganizationProjectStore, stats StatsProvider) *Service {
+	return &Service{
+		projects: projects,
+		stats:    stats,
+	}
+}
+
+// GetOrgStats aggregates the stats of every project in the organization over
+// [from, to] (default: the last 7 days) and returns a per-project breakdown.
+func (s *Service) GetOrgStats(ctx context.Context, orgID string, from, to *time.Time) (*entity.OrgStats, error) {
+	end := time.Now()
+	if to != nil {
+		end = *to
+	}
+	start := end.Add(-defaultPeriod)
+	if from != nil {
+		start = *from
+	}
+	if start.After(end) {
+		return nil, entity.ErrInvalidInput
+	}
+
+	projects, err := s.projects.ListOrganizationProjects(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &entity.OrgStats{
+		Projects: make([]entity.ProjectStats, 0, len(projects)),
+		From:     start,
+		To:       end,
+	}
+
+	var errorTraces, durationWeight float64
+	for _, p := range projects {
+		stats, err := s.stats.GetSummary(ctx, p.ID, &coreAnalytics.SummaryRequest{From: &start, To: &end})
+		if err != nil {
+			return nil, fmt.Errorf("project %s stats: %w", p.ID, err)
+		}
+
+		result.Projects = append(result.Projects, entity.ProjectStats{
+			ProjectID:     p.ID,
+			ProjectName:   p.Name,
+			TotalTraces:   stats.TotalTraces,
+			TotalSpans:    stats.TotalSpans,
+			TotalTokens:   stats.TotalTokens,
+			TotalCostUSD:  stats.TotalCostUSD,
+			AvgDurationMs: stats.AvgDurationMs,
+			ErrorRate:     stats.ErrorRate,
+		})
+
+		result.TotalTraces += stats.TotalTraces
+		result.TotalSpans += stats.TotalSpans
+		result.TotalTokens += stats.TotalTokens
+		result.TotalCostUSD += stats.TotalCostUSD
+		errorTraces += stats.ErrorRate / 100 * float64(stats.TotalTraces)
+		durationWeight += float64(stats.AvgDurationMs) * float64(stats.TotalSpans)
+	}
+
+	if result.TotalTraces > 0 {
+		result.ErrorRate = errorTraces / float64(result.TotalTraces) * 100
+	}
+	if result.TotalSpans > 0 {
+		result.AvgDurationMs = int(durationWeight / float64(result.TotalSpans))
+	}
+
+	return result, nil
+}
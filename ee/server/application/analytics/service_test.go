@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	coreAnalytics "github.com/lelemon/server/pkg/application/analytics"
+	coreEntity "github.com/lelemon/server/pkg/domain/entity"
+
+	"github.com/lelemon/ee/server/domain/entity"
+)
+
+// Mock implementations
+
+type mockProjectStore struct {
+	projects map[string][]entity.OrgProject // orgID -> projects
+}
+
+func (m *mockProjectStore) ListOrganizationProjects(ctx context.Context, orgID string) ([]entity.OrgProject, error) {
+	return m.projects[orgID], nil
+}
+
+type mockStatsProvider struct {
+	stats    map[string]*coreEntity.Stats // projectID -> stats
+	requests []*coreAnalytics.SummaryRequest
+}
+
+func (m *mockStatsProvider) GetSummary(ctx context.Context, projectID string, req *coreAnalytics.SummaryRequest) (*coreEntity.Stats, error) {
+	m.requests = append(m.requests, req)
+	stats, ok := m.stats[projectID]
+	if !ok {
+		return nil, errors.New("stats unavailable")
+	}
+	return stats, nil
+}
+
+func TestGetOrgStats(t *testing.T) {
+	ctx := context.Background()
+
+	projects := &mockProjectStore{projects: map[string][]entity.OrgProject{
+		"org-1": {{ID: "proj-a", Name: "Support Bot"}, {ID: "proj-b", Name: "Sales Agent"}},
+		"org-2": {{ID: "proj-c", Name: "Other Org"}},
+	}}
+	provider := &mockStatsProvider{stats: map[string]*coreEntity.Stats{
+		"proj-a": {TotalTraces: 30, TotalSpans: 100, TotalTokens: 5000, TotalCostUSD: 1.25, AvgDurationMs: 200, ErrorRate: 10},
+		"proj-b": {TotalTraces: 10, TotalSpans: 300, TotalTokens: 1000, TotalCostUSD: 0.75, AvgDurationMs: 600, ErrorRate: 50},
+		"proj-c": {TotalTraces: 999, TotalSpans: 999, TotalTokens: 999, TotalCostUSD: 999},
+	}}
+	svc := NewService(projects, provider)
+
+	t.Run("aggregates across the org's projects", func(t *testing.T) {
+		result, err := svc.GetOrgStats(ctx, "org-1", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result.TotalTraces != 40 || result.TotalSpans != 400 || result.TotalTokens != 6000 {
+			t.Errorf("unexpected totals: traces=%d spans=%d tokens=%d", result.TotalTraces, result.TotalSpans, result.TotalTokens)
+		}
+		if math.Abs(result.TotalCostUSD-2.0) > 1e-9 {
+			t.Errorf("expected total cost 2.0, got %f", result.TotalCostUSD)
+		}
+		// (3 + 5) errored traces out of 40
+		if math.Abs(result.ErrorRate-20) > 1e-9 {
+			t.Errorf("expected trace-weighted error rate 20, got %f", result.ErrorRate)
+		}
+		// (200*100 + 600*300) / 400 spans
+		if result.AvgDurationMs != 500 {
+			t.Errorf("expected span-weighted avg duration 500, got %d", result.AvgDurationMs)
+		}
+	})
+
+	t.Run("returns a per-project breakdown", func(t *testing.T) {
+		result, err := svc.GetOrgStats(ctx, "org-1", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(result.Projects) != 2 {
+			t.Fatalf("expected 2 projects, got %d", len(result.Projects))
+		}
+		a, b := result.Projects[0], result.Projects[1]
+		if a.ProjectID != "proj-a" || a.ProjectName != "Support Bot" || a.TotalTraces != 30 || a.ErrorRate != 10 {
+			t.Errorf("unexpected breakdown for proj-a: %+v", a)
+		}
+		if b.ProjectID != "proj-b" || b.ProjectName != "Sales Agent" || b.TotalTraces != 10 || b.ErrorRate != 50 {
+			t.Errorf("unexpected breakdown for proj-b: %+v", b)
+		}
+	})
+
+	t.Run("queries every project over the same period", func(t *testing.T) {
+		provider.requests = nil
+		from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+		result, err := svc.GetOrgStats(ctx, "org-1", &from, &to)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !result.From.Equal(from) || !result.To.Equal(to) {
+			t.Errorf("expected period %v - %v, got %v - %v", from, to, result.From, result.To)
+		}
+		if len(provider.requests) != 2 {
+			t.Fatalf("expected 2 stats requests, got %d", len(provider.requests))
+		}
+		for _, req := range provider.requests {
+			if !req.From.Equal(from) || !req.To.Equal(to) {
+				t.Errorf("project queried with period %v - %v", *req.From, *req.To)
+			}
+		}
+	})
+
+	t.Run("defaults to the last 7 days", func(t *testing.T) {
+		result, err := svc.GetOrgStats(ctx, "org-1", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := result.To.Sub(result.From); got != 7*24*time.Hour {
+			t.Errorf("expected a 7 day period, got %v", got)
+		}
+	})
+
+	t.Run("org without projects", func(t *testing.T) {
+		result, err := svc.GetOrgStats(ctx, "org-empty", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.TotalTraces != 0 || result.ErrorRate != 0 || len(result.Projects) != 0 {
+			t.Errorf("expected empty stats, got %+v", result)
+		}
+	})
+
+	t.Run("rejects inverted period", func(t *testing.T) {
+		from, to := time.Now(), time.Now().Add(-time.Hour)
+		if _, err := svc.GetOrgStats(ctx, "org-1", &from, &to); !errors.Is(err, entity.ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput, got %v", err)
+		}
+	})
+
+	t.Run("propagates project failures", func(t *testing.T) {
+		projects.projects["org-broken"] = []entity.OrgProject{{ID: "proj-missing"}}
+		if _, err := svc.GetOrgStats(ctx, "org-broken", nil, nil); err == nil {
+			t.Error("expected error when a project's stats fail")
+		}
+	})
+}
@@ -23,9 +23,18 @@ type UserRepository interface {
 	GetUserByID(ctx context.Context, id string) (interface{ GetEmail() string }, error)
 }
 
-// Service handles billing operations
+// Authorizer checks a user's permissions in an organization (implemented by
+// rbac.Service)
+type Authorizer interface {
+	RequirePermission(ctx context.Context, userID, orgID string, perm entity.Permission) error
+}
+
+// Service handles billing operations. Every operation on an organization's
+// billing is authorized against the calling user's role in that organization:
+// billing:read to view, billing:manage to change it.
 type Service struct {
 	repo     repository.BillingRepository // Interface, not concrete
+	authz    Authorizer
 	lsClient *lemonsqueezy.Client
 	config   *Config
 }
@@ -45,19 +54,24 @@ func LoadConfigFromEnv() *Config {
 }
 
 // NewService creates a new billing service
-func NewService(repo repository.BillingRepository, lsClient *lemonsqueezy.Client, config *Config) *Service {
+func NewService(repo repository.BillingRepository, authz Authorizer, lsClient *lemonsqueezy.Client, config *Config) *Service {
 	if config == nil {
 		config = LoadConfigFromEnv()
 	}
 	return &Service{
 		repo:     repo,
+		authz:    authz,
 		lsClient: lsClient,
 		config:   config,
 	}
 }
 
 // GetBilling returns the current billing info for an organization
-func (s *Service) GetBilling(ctx context.Context, orgID string) (*BillingInfo, error) {
+func (s *Service) GetBilling(ctx context.Context, userID, orgID string) (*BillingInfo, error) {
+	if err := s.authz.RequirePermission(ctx, userID, orgID, entity.PermBillingRead); err != nil {
+		return nil, err
+	}
+
 	org, err := s.repo.GetOrganizationByID(ctx, orgID)
 	if err != nil {
 		return nil, entity.ErrNotFound
@@ -82,8 +96,25 @@ func (s *Service) GetBilling(ctx context.Context, orgID string) (*BillingInfo, e
 	}, nil
 }
 
+// GetSubscription returns an organization's subscription
+func (s *Service) GetSubscription(ctx context.Context, userID, orgID string) (*entity.Subscription, error) {
+	if err := s.authz.RequirePermission(ctx, userID, orgID, entity.PermBillingRead); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.repo.GetSubscriptionByOrgID(ctx, orgID)
+	if err != nil {
+		return nil, entity.ErrNotFound
+	}
+	return sub, nil
+}
+
 // CreateCheckout generates a checkout URL for upgrading to a plan
-func (s *Service) CreateCheckout(ctx context.Context, orgID string, plan entity.BillingPlan, ownerEmail string) (string, error) {
+func (s *Service) CreateCheckout(ctx context.Context, userID, orgID string, plan entity.BillingPlan, ownerEmail string) (string, error) {
+	if err := s.authz.RequirePermission(ctx, userID, orgID, entity.PermBillingManage); err != nil {
+		return "", err
+	}
+
 	org, err := s.repo.GetOrganizationByID(ctx, orgID)
 	if err != nil {
 		return "", entity.ErrNotFound
@@ -109,8 +140,13 @@ func (s *Service) CreateCheckout(ctx context.Context, orgID string, plan entity.
 	return checkoutURL, nil
 }
 
-// GetCustomerPortal returns the Lemon Squeezy customer portal URL
-func (s *Service) GetCustomerPortal(ctx context.Context, orgID string) (string, error) {
+// GetCustomerPortal returns the Lemon Squeezy customer portal URL. The portal
+// can change payment details and cancel, so it requires billing:manage.
+func (s *Service) GetCustomerPortal(ctx context.Context, userID, orgID string) (string, error) {
+	if err := s.authz.RequirePermission(ctx, userID, orgID, entity.PermBillingManage); err != nil {
+		return "", err
+	}
+
 	sub, err := s.repo.GetSubscriptionByOrgID(ctx, orgID)
 	if err != nil {
 		return "", entity.ErrNotFound
@@ -120,7 +156,11 @@ func (s *Service) GetCustomerPortal(ctx context.Context, orgID string) (string,
 }
 
 // CancelSubscription cancels the current subscription
-func (s *Service) CancelSubscription(ctx context.Context, orgID string) error {
+func (s *Service) CancelSubscription(ctx context.Context, userID, orgID string) error {
+	if err := s.authz.RequirePermission(ctx, userID, orgID, entity.PermBillingManage); err != nil {
+		return err
+	}
+
 	sub, err := s.repo.GetSubscriptionByOrgID(ctx, orgID)
 	if err != nil {
 		return entity.ErrNotFound
@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
+)
+
+// mockBillingRepo serves organizations and subscriptions from maps
+type mockBillingRepo struct {
+	*mockUsageStore
+	orgs map[string]*entity.Organization
+	subs map[string]*entity.Subscription // orgID -> subscription
+}
+
+func (m *mockBillingRepo) GetOrganizationByID(ctx context.Context, id string) (*entity.Organization, error) {
+	if org, ok := m.orgs[id]; ok {
+		return org, nil
+	}
+	return nil, entity.ErrNotFound
+}
+
+func (m *mockBillingRepo) UpdateOrganization(ctx context.Context, id string, updates *entity.OrganizationUpdate) error {
+	return nil
+}
+
+func (m *mockBillingRepo) CreateSubscription(ctx context.Context, sub *entity.Subscription) error {
+	m.subs[sub.OrganizationID] = sub
+	return nil
+}
+
+func (m *mockBillingRepo) GetSubscriptionByOrgID(ctx context.Context, orgID string) (*entity.Subscription, error) {
+	if sub, ok := m.subs[orgID]; ok {
+		return sub, nil
+	}
+	return nil, entity.ErrNotFound
+}
+
+func (m *mockBillingRepo) GetSubscriptionByLemonSqueezyID(ctx context.Context, lsID string) (*entity.Subscription, error) {
+	return nil, entity.ErrNotFound
+}
+
+func (m *mockBillingRepo) UpdateSubscription(ctx context.Context, id string, updates *entity.SubscriptionUpdate) error {
+	return nil
+}
+
+// mockAuthorizer grants permissions by the user's role in each organization
+type mockAuthorizer struct {
+	roles map[string]map[string]entity.Role // orgID -> userID -> role
+}
+
+func (m *mockAuthorizer) RequirePermission(ctx context.Context, userID, orgID string, perm entity.Permission) error {
+	role, ok := m.roles[orgID][userID]
+	if !ok || !entity.HasPermission(role, perm) {
+		return entity.ErrPermissionDenied
+	}
+	return nil
+}
+
+func TestService_Authorization(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mockBillingRepo{
+		mockUsageStore: &mockUsageStore{},
+		orgs: map[string]*entity.Organization{
+			"org-a": {ID: "org-a", Plan: entity.PlanPro},
+			"org-b": {ID: "org-b", Plan: entity.PlanPro},
+		},
+		subs: map[string]*entity.Subscription{
+			"org-a": {ID: "sub-a", OrganizationID: "org-a", Plan: entity.PlanPro},
+			"org-b": {ID: "sub-b", OrganizationID: "org-b", Plan: entity.PlanPro},
+		},
+	}
+	authz := &mockAuthorizer{roles: map[string]map[string]entity.Role{
+		"org-a": {"owner-a": entity.RoleOwner, "admin-a": entity.RoleAdmin, "member-a": entity.RoleMember},
+		"org-b": {"owner-b": entity.RoleOwner},
+	}}
+	svc := NewService(repo, authz, lemonsqueezy.NewClient("", "", ""), &Config{})
+
+	t.Run("owners and admins read their own subscription", func(t *testing.T) {
+		for _, userID := range []string{"owner-a", "admin-a"} {
+			sub, err := svc.GetSubscription(ctx, userID, "org-a")
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", userID, err)
+			}
+			if sub.ID != "sub-a" {
+				t.Errorf("%s: expected sub-a, got %s", userID, sub.ID)
+			}
+		}
+	})
+
+	t.Run("another organization's subscription is denied", func(t *testing.T) {
+		if _, err := svc.GetSubscription(ctx, "owner-a", "org-b"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := svc.GetBilling(ctx, "owner-a", "org-b"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("GetBilling: expected ErrPermissionDenied, got %v", err)
+		}
+		if err := svc.CancelSubscription(ctx, "owner-a", "org-b"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("CancelSubscription: expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("plain members are denied", func(t *testing.T) {
+		if _, err := svc.GetSubscription(ctx, "member-a", "org-a"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := svc.CreateCheckout(ctx, "member-a", "org-a", entity.PlanEnterprise, "member@test.com"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("CreateCheckout: expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := svc.GetCustomerPortal(ctx, "member-a", "org-a"); !errors.Is(err, entity.ErrPermissionDenied) {
+			t.Errorf("GetCustomerPortal: expected ErrPermissionDenied, got %v", err)
+		}
+	})
+}
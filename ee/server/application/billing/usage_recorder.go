@@ -0,0 +1,108 @@
+package billing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/domain/repository"
+)
+
+// DefaultUsageFlushInterval bounds how often ingest usage is written
+const DefaultUsageFlushInterval = 10 * time.Second
+
+// UsageRecorder meters ingest into the monthly usage table. It implements the
+// core ingest.UsageRecorder: batches only bump in-memory per-project counters,
+// and a background loop writes them every interval with one UPSERT per
+// organization, instead of a write per ingested event.
+type UsageRecorder struct {
+	store    repository.UsageStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]entity.UsageDelta // project ID -> unflushed usage
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewUsageRecorder creates a recorder and starts its flush loop.
+// Call Stop on shutdown to flush what is still buffered.
+func NewUsageRecorder(store repository.UsageStore, interval time.Duration) *UsageRecorder {
+	if interval <= 0 {
+		interval = DefaultUsageFlushInterval
+	}
+
+	r := &UsageRecorder{
+		store:    store,
+		interval: interval,
+		pending:  make(map[string]entity.UsageDelta),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go r.flushLoop()
+
+	return r
+}
+
+// RecordUsage adds a processed batch's traces and spans. Safe for concurrent use.
+func (r *UsageRecorder) RecordUsage(projectID string, traces, spans int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.pending[projectID]
+	d.Traces += traces
+	d.Spans += spans
+	r.pending[projectID] = d
+}
+
+// Flush writes all buffered usage to the store. If the write fails the batch
+// is merged back so it is retried on the next flush.
+func (r *UsageRecorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = make(map[string]entity.UsageDelta)
+	r.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := r.store.IncrementByProject(ctx, batch); err != nil {
+		for projectID, d := range batch {
+			r.RecordUsage(projectID, d.Traces, d.Spans)
+		}
+		return err
+	}
+	return nil
+}
+
+// Stop halts the flush loop and writes any remaining usage.
+func (r *UsageRecorder) Stop(ctx context.Context) error {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+	return r.Flush(ctx)
+}
+
+func (r *UsageRecorder) flushLoop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := r.Flush(ctx); err != nil {
+				slog.Warn("usage flush failed", "error", err)
+			}
+			cancel()
+		}
+	}
+}
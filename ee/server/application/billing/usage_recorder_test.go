@@ -0,0 +1,103 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lelemon/ee/server/domain/entity"
+)
+
+// mockUsageStore sums the batches it receives
+type mockUsageStore struct {
+	mu      sync.Mutex
+	totals  map[string]entity.UsageDelta
+	batches int
+	fail    error
+}
+
+func (m *mockUsageStore) Increment(ctx context.Context, orgID string, traces, spans int) error {
+	return nil
+}
+
+func (m *mockUsageStore) IncrementByProject(ctx context.Context, usage map[string]entity.UsageDelta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	m.batches++
+	for projectID, d := range usage {
+		t := m.totals[projectID]
+		t.Traces += d.Traces
+		t.Spans += d.Spans
+		m.totals[projectID] = t
+	}
+	return nil
+}
+
+func (m *mockUsageStore) GetCurrentMonth(ctx context.Context, orgID string) (*entity.Usage, error) {
+	return nil, nil
+}
+
+func (m *mockUsageStore) GetByMonth(ctx context.Context, orgID, month string) (*entity.Usage, error) {
+	return nil, nil
+}
+
+func TestUsageRecorder_ConcurrentRecordsSum(t *testing.T) {
+	store := &mockUsageStore{totals: map[string]entity.UsageDelta{}}
+	recorder := NewUsageRecorder(store, time.Hour)
+
+	const goroutines, perGoroutine = 16, 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			project := "p1"
+			if g%2 == 1 {
+				project = "p2"
+			}
+			for i := 0; i < perGoroutine; i++ {
+				recorder.RecordUsage(project, 1, 4)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := recorder.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	want := entity.UsageDelta{Traces: goroutines / 2 * perGoroutine, Spans: goroutines / 2 * perGoroutine * 4}
+	for _, project := range []string{"p1", "p2"} {
+		if got := store.totals[project]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", project, want, got)
+		}
+	}
+	if store.batches != 1 {
+		t.Errorf("expected all records to be written in 1 batch, got %d", store.batches)
+	}
+}
+
+func TestUsageRecorder_RetriesFailedFlush(t *testing.T) {
+	store := &mockUsageStore{totals: map[string]entity.UsageDelta{}, fail: errors.New("database is locked")}
+	recorder := NewUsageRecorder(store, time.Hour)
+	ctx := context.Background()
+
+	recorder.RecordUsage("p1", 1, 2)
+	if err := recorder.Flush(ctx); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+
+	store.fail = nil
+	recorder.RecordUsage("p1", 1, 1)
+	if err := recorder.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if got, want := store.totals["p1"], (entity.UsageDelta{Traces: 2, Spans: 3}); got != want {
+		t.Errorf("expected the failed batch to be retried: want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,50 @@
+package features
+
+import (
+	"github.com/lelemon/ee/server/domain/entity"
+)
+
+// Resolver decides which features an organization may use: those its plan
+// includes (see entity.PlanFeatures) that the deployment also enables
+type Resolver struct {
+	deployment map[string]bool
+	plans      map[entity.BillingPlan][]entity.Feature
+}
+
+// NewResolver creates a resolver over the deployment's feature toggles (the
+// Features of the server's FeaturesConfig) and the features of each plan. A
+// feature missing from deployment is enabled there; plans missing from plans
+// get their entity.PlanFeatures defaults.
+func NewResolver(deployment map[string]bool, plans map[entity.BillingPlan][]entity.Feature) *Resolver {
+	merged := make(map[entity.BillingPlan][]entity.Feature, len(entity.PlanFeatures))
+	for plan, features := range entity.PlanFeatures {
+		merged[plan] = features
+	}
+	for plan, features := range plans {
+		merged[plan] = features
+	}
+	return &Resolver{deployment: deployment, plans: merged}
+}
+
+// Enabled reports whether an organization on plan may use feature
+func (r *Resolver) Enabled(plan entity.BillingPlan, feature entity.Feature) bool {
+	if enabled, ok := r.deployment[string(feature)]; ok && !enabled {
+		return false
+	}
+	for _, f := range r.plans[plan] {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns every known feature and whether an organization on plan
+// may use it
+func (r *Resolver) Resolve(plan entity.BillingPlan) map[entity.Feature]bool {
+	resolved := make(map[entity.Feature]bool, len(entity.Features))
+	for _, feature := range entity.Features {
+		resolved[feature] = r.Enabled(plan, feature)
+	}
+	return resolved
+}
@@ -140,6 +140,27 @@ func (s *Service) GetBySlug(ctx context.Context, slug string) (*entity.Organizat
 	return s.repo.GetOrganizationBySlug(ctx, slug)
 }
 
+// SetFrontendURL sets the frontend the organization's OAuth logins land on
+// (see entity.OrganizationSettings.FrontendURL); "" restores the default
+func (s *Service) SetFrontendURL(ctx context.Context, orgID, frontendURL string) (*entity.Organization, error) {
+	frontendURL = strings.TrimSuffix(strings.TrimSpace(frontendURL), "/")
+	if err := entity.ValidateFrontendURL(frontendURL); err != nil {
+		return nil, err
+	}
+
+	org, err := s.repo.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	settings := org.Settings
+	settings.FrontendURL = frontendURL
+	if err := s.repo.UpdateOrganization(ctx, orgID, &entity.OrganizationUpdate{Settings: &settings}); err != nil {
+		return nil, err
+	}
+	org.Settings = settings
+	return org, nil
+}
+
 // ListByUser returns all organizations the user is a member of
 func (s *Service) ListByUser(ctx context.Context, userID string) ([]entity.Organization, error) {
 	return s.repo.ListOrganizationsByUser(ctx, userID)
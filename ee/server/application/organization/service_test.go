@@ -48,6 +48,9 @@ func (m *mockOrgRepo) GetOrganizationBySlug(ctx context.Context, slug string) (*
 }
 
 func (m *mockOrgRepo) UpdateOrganization(ctx context.Context, id string, updates *entity.OrganizationUpdate) error {
+	if org, ok := m.orgs[id]; ok && updates.Settings != nil {
+		org.Settings = *updates.Settings
+	}
 	return nil
 }
 
@@ -564,3 +567,45 @@ func TestService_ListByUser(t *testing.T) {
 
 // Test helper to avoid unused import
 var _ = time.Now
+
+func TestService_SetFrontendURL(t *testing.T) {
+	ctx := context.Background()
+	repo := newMockOrgRepo()
+	userStore := newMockUserStore()
+	userStore.AddUser("owner-1", "owner@example.com")
+	svc := NewService(repo, userStore)
+	org, err := svc.Create(ctx, "owner-1", &CreateRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("failed to create org: %v", err)
+	}
+
+	t.Run("sets the frontend, keeping the other settings", func(t *testing.T) {
+		if _, err := svc.SetFrontendURL(ctx, org.ID, " https://acme.lelemon.dev/app/ "); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := svc.GetBySlug(ctx, org.Slug)
+		if got.Settings.FrontendURL != "https://acme.lelemon.dev/app" {
+			t.Errorf("expected the frontend set, got %q", got.Settings.FrontendURL)
+		}
+		if got.Settings.MaxMembers != entity.PlanPro.GetDefaultSettings().MaxMembers {
+			t.Errorf("expected the plan limits kept, got %+v", got.Settings)
+		}
+	})
+
+	t.Run("rejects invalid URLs", func(t *testing.T) {
+		for _, frontend := range []string{"acme.lelemon.dev", "javascript:alert(1)", "https://acme.lelemon.dev?next=x", "https://user@acme.lelemon.dev"} {
+			if _, err := svc.SetFrontendURL(ctx, org.ID, frontend); !errors.Is(err, entity.ErrInvalidFrontendURL) {
+				t.Errorf("SetFrontendURL(%q): expected ErrInvalidFrontendURL, got %v", frontend, err)
+			}
+		}
+	})
+
+	t.Run("clears the frontend", func(t *testing.T) {
+		if _, err := svc.SetFrontendURL(ctx, org.ID, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, _ := svc.GetByID(ctx, org.ID); got.Settings.FrontendURL != "" {
+			t.Errorf("expected the frontend cleared, got %q", got.Settings.FrontendURL)
+		}
+	})
+}
@@ -6,30 +6,29 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	// Core imports
-	"github.com/lelemon/server/pkg/application/analytics"
-	appauth "github.com/lelemon/server/pkg/application/auth"
-	"github.com/lelemon/server/pkg/application/ingest"
-	"github.com/lelemon/server/pkg/application/project"
-	"github.com/lelemon/server/pkg/application/trace"
+	"github.com/lelemon/server/pkg/bootstrap"
 	"github.com/lelemon/server/pkg/domain/repository"
-	"github.com/lelemon/server/pkg/domain/service"
-	"github.com/lelemon/server/pkg/infrastructure/auth"
+	"github.com/lelemon/server/pkg/infrastructure/buildinfo"
 	"github.com/lelemon/server/pkg/infrastructure/config"
 	"github.com/lelemon/server/pkg/infrastructure/logger"
-	"github.com/lelemon/server/pkg/infrastructure/store"
 	coreHttp "github.com/lelemon/server/pkg/interfaces/http"
 
 	// Enterprise imports
+	entAnalytics "github.com/lelemon/ee/server/application/analytics"
 	"github.com/lelemon/ee/server/application/billing"
+	"github.com/lelemon/ee/server/application/features"
 	"github.com/lelemon/ee/server/application/organization"
 	"github.com/lelemon/ee/server/application/rbac"
+	"github.com/lelemon/ee/server/domain/entity"
 	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
 	entStore "github.com/lelemon/ee/server/infrastructure/store"
 	entHttp "github.com/lelemon/ee/server/interfaces/http"
+	entHandler "github.com/lelemon/ee/server/interfaces/http/handler"
 )
 
 // userStoreAdapter adapts repository.Store to organization.UserStore interface
@@ -60,7 +59,8 @@ func main() {
 
 	log := slog.Default()
 	log.Info("starting lelemon enterprise server",
-		"version", "1.0.0-enterprise",
+		"version", buildinfo.Version+"-enterprise",
+		"commit", buildinfo.Get().Commit,
 		"port", cfg.Port,
 		"log_level", cfg.LogLevel,
 		"allowed_origins", cfg.AllowedOrigins,
@@ -70,49 +70,14 @@ func main() {
 	// CORE: Initialize stores and services
 	// ============================================
 
-	// Initialize primary store (users, projects)
-	primaryStore, err := store.New(cfg.DatabaseURL)
+	// Stores, services and background workers, shared with the core server
+	ctx := context.Background()
+	core, err := bootstrap.New(ctx, cfg, log)
 	if err != nil {
-		log.Error("failed to initialize primary store", "error", err)
+		log.Error("failed to initialize core server", "error", err)
 		os.Exit(1)
 	}
-
-	// Initialize analytics store (traces, spans) - defaults to primary
-	analyticsStore := primaryStore
-	if cfg.AnalyticsDatabaseURL != "" {
-		analyticsStore, err = store.New(cfg.AnalyticsDatabaseURL)
-		if err != nil {
-			log.Error("failed to initialize analytics store", "error", err)
-			os.Exit(1)
-		}
-		log.Info("using separate analytics store")
-	}
-
-	// Run core migrations
-	ctx := context.Background()
-	if err := primaryStore.Migrate(ctx); err != nil {
-		log.Error("failed to run primary migrations", "error", err)
-		os.Exit(1)
-	}
-	if analyticsStore != primaryStore {
-		if err := analyticsStore.Migrate(ctx); err != nil {
-			log.Error("failed to run analytics migrations", "error", err)
-			os.Exit(1)
-		}
-	}
-	log.Info("core database migrations completed")
-
-	// Initialize core auth services
-	jwtService := auth.NewJWTService(cfg.JWTSecret, cfg.JWTExpiration)
-	oauthService := auth.NewOAuthService(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL)
-
-	// Initialize core application services
-	pricing := service.NewPricingCalculator()
-	ingestSvc := ingest.NewAsyncService(analyticsStore, pricing, 1000, 4)
-	traceSvc := trace.NewService(analyticsStore, pricing)
-	analyticsSvc := analytics.NewService(analyticsStore)
-	projectSvc := project.NewService(primaryStore)
-	authSvc := appauth.NewService(primaryStore, jwtService, oauthService)
+	primaryStore := core.PrimaryStore
 
 	// ============================================
 	// ENTERPRISE: Initialize stores and services
@@ -135,6 +100,10 @@ func main() {
 	}
 	log.Info("enterprise database migrations completed")
 
+	// Meter ingest into monthly usage (buffered; one write per organization per flush)
+	usageRecorder := billing.NewUsageRecorder(enterpriseStore, billing.DefaultUsageFlushInterval)
+	core.IngestSvc.SetUsageRecorder(usageRecorder)
+
 	// Initialize Lemon Squeezy client
 	lsClient := lemonsqueezy.NewClient(
 		entCfg.LemonSqueezyAPIKey,
@@ -150,7 +119,8 @@ func main() {
 		ProVariantID:        entCfg.ProVariantID,
 		EnterpriseVariantID: entCfg.EnterpriseVariantID,
 	}
-	billingSvc := billing.NewService(enterpriseStore, lsClient, billingConfig)
+	billingSvc := billing.NewService(enterpriseStore, rbacSvc, lsClient, billingConfig)
+	orgAnalyticsSvc := entAnalytics.NewService(enterpriseStore, core.AnalyticsSvc)
 
 	// ============================================
 	// ROUTER: Create core router with enterprise extension
@@ -163,24 +133,37 @@ func main() {
 		billingSvc,
 		lsClient,
 		enterpriseStore,
+		orgAnalyticsSvc,
 	)
 
+	// Plan feature gating (PLAN_FEATURES_<PLAN> overrides a plan's defaults)
+	for plan, planFeatures := range entCfg.PlanFeatures {
+		for _, feature := range planFeatures {
+			if !entity.IsKnownFeature(feature) {
+				log.Error("unknown feature in plan features", "plan", plan, "feature", feature)
+				os.Exit(1)
+			}
+		}
+	}
+	enterpriseExtension.SetFeatures(features.NewResolver(coreHttp.EnterpriseFeaturesConfig().Features, entCfg.PlanFeatures))
+
+	// Core background workers (alerts, retention, webhooks, reaper, ...)
+	if err := core.Start(ctx); err != nil {
+		log.Error("failed to start background workers", "error", err)
+		os.Exit(1)
+	}
+
 	// Create router with enterprise features enabled
-	router := coreHttp.NewRouter(coreHttp.RouterConfig{
-		PrimaryStore:   primaryStore,
-		AnalyticsStore: analyticsStore,
-		IngestSvc:      ingestSvc,
-		TraceSvc:       traceSvc,
-		AnalyticsSvc:   analyticsSvc,
-		ProjectSvc:     projectSvc,
-		AuthSvc:        authSvc,
-		JWTService:     jwtService,
-		FrontendURL:    cfg.FrontendURL,
-		AllowedOrigins: cfg.AllowedOrigins,
-		// Enterprise features
-		Extensions:     []coreHttp.RouterExtension{enterpriseExtension},
-		FeaturesConfig: coreHttp.EnterpriseFeaturesConfig(),
-	})
+	routerCfg, err := core.RouterConfig()
+	if err != nil {
+		log.Error("invalid router config", "error", err)
+		os.Exit(1)
+	}
+	routerCfg.Extensions = []coreHttp.RouterExtension{enterpriseExtension}
+	routerCfg.FeaturesConfig = coreHttp.EnterpriseFeaturesConfig()
+	// OAuth logins started with ?org=<slug> land on the organization's frontend setting
+	routerCfg.FrontendResolver = entHandler.OrgFrontends(orgSvc)
+	router := coreHttp.NewRouter(routerCfg)
 
 	// Create server
 	server := coreHttp.NewServer(router, cfg.Port)
@@ -208,17 +191,17 @@ func main() {
 		log.Error("server shutdown error", "error", err)
 	}
 
-	// Stop ingest worker (drain pending jobs)
-	ingestSvc.Stop(10 * time.Second)
+	// Stop background workers and drain pending ingest jobs
+	core.Stop()
 
-	// Close database connections
-	if err := primaryStore.Close(); err != nil {
-		log.Error("primary store close error", "error", err)
+	// Flush buffered ingest usage (after the ingest worker has drained)
+	if err := usageRecorder.Stop(shutdownCtx); err != nil {
+		log.Error("usage flush error", "error", err)
 	}
-	if analyticsStore != primaryStore {
-		if err := analyticsStore.Close(); err != nil {
-			log.Error("analytics store close error", "error", err)
-		}
+
+	// Flush buffered API key usage and close database connections
+	if err := core.Close(shutdownCtx); err != nil {
+		log.Error("shutdown error", "error", err)
 	}
 	if err := db.Close(); err != nil {
 		log.Error("enterprise db close error", "error", err)
@@ -234,6 +217,7 @@ type EnterpriseConfig struct {
 	LemonSqueezyStoreID       string
 	ProVariantID              string
 	EnterpriseVariantID       string
+	PlanFeatures              map[entity.BillingPlan][]entity.Feature // Per-plan overrides of entity.PlanFeatures
 }
 
 // loadEnterpriseConfig loads enterprise configuration from environment
@@ -244,7 +228,29 @@ func loadEnterpriseConfig() *EnterpriseConfig {
 		LemonSqueezyStoreID:       getEnv("LEMONSQUEEZY_STORE_ID", ""),
 		ProVariantID:              getEnv("LEMONSQUEEZY_PRO_VARIANT_ID", ""),
 		EnterpriseVariantID:       getEnv("LEMONSQUEEZY_ENTERPRISE_VARIANT_ID", ""),
+		PlanFeatures:              loadPlanFeatures(),
+	}
+}
+
+// loadPlanFeatures reads PLAN_FEATURES_FREE, PLAN_FEATURES_PRO and
+// PLAN_FEATURES_ENTERPRISE: comma-separated features replacing the plan's
+// defaults ("none" for no feature). Unset plans keep their defaults.
+func loadPlanFeatures() map[entity.BillingPlan][]entity.Feature {
+	plans := make(map[entity.BillingPlan][]entity.Feature)
+	for _, plan := range []entity.BillingPlan{entity.PlanFree, entity.PlanPro, entity.PlanEnterprise} {
+		value := getEnv("PLAN_FEATURES_"+strings.ToUpper(string(plan)), "")
+		if value == "" {
+			continue
+		}
+		planFeatures := []entity.Feature{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "none" {
+				planFeatures = append(planFeatures, entity.Feature(name))
+			}
+		}
+		plans[plan] = planFeatures
 	}
+	return plans
 }
 
 // getEnv gets an environment variable with a default value
@@ -81,3 +81,38 @@ func NewErrorFilter() ErrorFilter {
 		TopLimit: 10,
 	}
 }
+
+// ============================================
+// ORGANIZATION ANALYTICS
+// ============================================
+
+// OrgProject is a project owned by an organization
+type OrgProject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProjectStats is one project's stats within an organization rollup
+type ProjectStats struct {
+	ProjectID     string  `json:"projectId"`
+	ProjectName   string  `json:"projectName"`
+	TotalTraces   int     `json:"totalTraces"`
+	TotalSpans    int     `json:"totalSpans"`
+	TotalTokens   int     `json:"totalTokens"`
+	TotalCostUSD  float64 `json:"totalCostUsd"`
+	AvgDurationMs int     `json:"avgDurationMs"`
+	ErrorRate     float64 `json:"errorRate"` // Percentage (0-100)
+}
+
+// OrgStats aggregates stats across all projects of an organization
+type OrgStats struct {
+	TotalTraces   int            `json:"totalTraces"`
+	TotalSpans    int            `json:"totalSpans"`
+	TotalTokens   int            `json:"totalTokens"`
+	TotalCostUSD  float64        `json:"totalCostUsd"`
+	AvgDurationMs int            `json:"avgDurationMs"` // Span-weighted across projects
+	ErrorRate     float64        `json:"errorRate"`     // Trace-weighted across projects (0-100)
+	Projects      []ProjectStats `json:"projects"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+}
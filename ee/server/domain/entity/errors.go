@@ -19,6 +19,8 @@ var (
 	ErrInvalidRole   = errors.New("invalid role")
 	ErrMissingOrgID  = errors.New("organization ID required")
 	ErrMissingUserID = errors.New("user ID required")
+
+	ErrInvalidFrontendURL = errors.New("frontend URL must be an http(s) URL without query or fragment")
 )
 
 // Business rule errors
@@ -0,0 +1,33 @@
+package entity
+
+// Feature is an enterprise capability an organization's plan may include
+type Feature string
+
+const (
+	FeatureOrganizations Feature = "organizations"
+	FeatureRBAC          Feature = "rbac"
+	FeatureBilling       Feature = "billing"
+	FeatureSSO           Feature = "sso"
+	FeatureOrgAnalytics  Feature = "org_analytics" // analytics rolled up across the organization's projects
+)
+
+// Features lists every plan-gated feature
+var Features = []Feature{FeatureOrganizations, FeatureRBAC, FeatureBilling, FeatureSSO, FeatureOrgAnalytics}
+
+// PlanFeatures defines the features included per plan (the default; the
+// server may override it per plan)
+var PlanFeatures = map[BillingPlan][]Feature{
+	PlanFree:       {FeatureOrganizations, FeatureBilling},
+	PlanPro:        {FeatureOrganizations, FeatureBilling, FeatureRBAC},
+	PlanEnterprise: {FeatureOrganizations, FeatureBilling, FeatureRBAC, FeatureSSO, FeatureOrgAnalytics},
+}
+
+// IsKnownFeature reports whether f is one of Features
+func IsKnownFeature(f Feature) bool {
+	for _, known := range Features {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
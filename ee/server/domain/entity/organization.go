@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -25,6 +26,11 @@ type OrganizationSettings struct {
 	MaxProjects   int `json:"maxProjects"`
 	MaxMembers    int `json:"maxMembers"`
 	RetentionDays int `json:"retentionDays"`
+
+	// FrontendURL is the branded dashboard OAuth logins started for the
+	// organization (?org=<slug>) land on, when FRONTEND_ALLOWLIST allows it;
+	// empty lands them on the default FRONTEND_URL
+	FrontendURL string `json:"frontendUrl,omitempty"`
 }
 
 // OrganizationUpdate holds fields that can be updated
@@ -87,3 +93,17 @@ func (p BillingPlan) IsValid() bool {
 	}
 	return false
 }
+
+// ValidateFrontendURL checks a FrontendURL setting: an http(s) URL with an
+// optional path, e.g. https://acme.lelemon.dev/app. Empty is valid.
+func ValidateFrontendURL(frontendURL string) error {
+	if frontendURL == "" {
+		return nil
+	}
+	u, err := url.Parse(frontendURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return ErrInvalidFrontendURL
+	}
+	return nil
+}
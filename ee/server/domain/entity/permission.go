@@ -20,8 +20,8 @@ const (
 	PermTeamManage Permission = "team:manage"
 
 	// Billing
-	PermBillingRead  Permission = "billing:read"
-	PermBillingWrite Permission = "billing:write"
+	PermBillingRead   Permission = "billing:read"
+	PermBillingManage Permission = "billing:manage"
 
 	// API Keys
 	PermAPIKeyCreate Permission = "apikey:create"
@@ -39,7 +39,7 @@ var RolePermissions = map[Role][]Permission{
 		PermProjectCreate, PermProjectRead, PermProjectUpdate, PermProjectDelete,
 		PermTraceRead, PermTraceDelete,
 		PermTeamRead, PermTeamInvite, PermTeamManage,
-		PermBillingRead, PermBillingWrite,
+		PermBillingRead, PermBillingManage,
 		PermAPIKeyCreate, PermAPIKeyRotate,
 		PermOrgRead, PermOrgUpdate, PermOrgDelete,
 	},
@@ -47,6 +47,7 @@ var RolePermissions = map[Role][]Permission{
 		PermProjectCreate, PermProjectRead, PermProjectUpdate, PermProjectDelete,
 		PermTraceRead, PermTraceDelete,
 		PermTeamRead, PermTeamInvite,
+		PermBillingRead, PermBillingManage,
 		PermAPIKeyCreate, PermAPIKeyRotate,
 		PermOrgRead, PermOrgUpdate,
 	},
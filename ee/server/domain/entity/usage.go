@@ -13,6 +13,12 @@ type Usage struct {
 	UpdatedAt      time.Time
 }
 
+// UsageDelta is usage accumulated since the last write
+type UsageDelta struct {
+	Traces int
+	Spans  int
+}
+
 // UsageReport provides a summary of usage for display
 type UsageReport struct {
 	CurrentMonth   *Usage
@@ -16,6 +16,11 @@ type OrganizationStore interface {
 	ListOrganizationsByUser(ctx context.Context, userID string) ([]entity.Organization, error)
 }
 
+// OrganizationProjectStore resolves the projects owned by an organization
+type OrganizationProjectStore interface {
+	ListOrganizationProjects(ctx context.Context, orgID string) ([]entity.OrgProject, error)
+}
+
 // TeamStore manages team members
 type TeamStore interface {
 	AddMember(ctx context.Context, member *entity.TeamMember) error
@@ -38,6 +43,8 @@ type SubscriptionStore interface {
 // UsageStore manages monthly usage tracking
 type UsageStore interface {
 	Increment(ctx context.Context, orgID string, traces, spans int) error
+	// IncrementByProject adds per-project usage to the owning organizations in one batch
+	IncrementByProject(ctx context.Context, usage map[string]entity.UsageDelta) error
 	GetCurrentMonth(ctx context.Context, orgID string) (*entity.Usage, error)
 	GetByMonth(ctx context.Context, orgID, month string) (*entity.Usage, error)
 }
@@ -51,6 +58,7 @@ type AnalyticsStore interface {
 // EnterpriseStore combines all enterprise interfaces
 type EnterpriseStore interface {
 	OrganizationStore
+	OrganizationProjectStore
 	TeamStore
 	SubscriptionStore
 	UsageStore
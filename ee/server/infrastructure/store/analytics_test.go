@@ -629,3 +629,51 @@ func TestGetErrorMetrics(t *testing.T) {
 		}
 	})
 }
+
+func TestListOrganizationProjects(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE projects (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			organization_id TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create projects table: %v", err)
+	}
+
+	now := time.Now()
+	for _, p := range []struct {
+		id, name string
+		orgID    any
+		created  time.Time
+	}{
+		{"p1", "Support Bot", "org-1", now.Add(-2 * time.Hour)},
+		{"p2", "Sales Agent", "org-1", now.Add(-time.Hour)},
+		{"p3", "Other", "org-2", now},
+		{"p4", "Personal", nil, now},
+	} {
+		if _, err := db.Exec(`INSERT INTO projects (id, name, organization_id, created_at) VALUES (?, ?, ?, ?)`,
+			p.id, p.name, p.orgID, p.created); err != nil {
+			t.Fatalf("failed to insert project: %v", err)
+		}
+	}
+
+	store := &Store{db: db}
+	projects, err := store.ListOrganizationProjects(ctx, "org-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(projects) != 2 {
+		t.Fatalf("expected 2 projects, got %d", len(projects))
+	}
+	if projects[0].ID != "p1" || projects[0].Name != "Support Bot" || projects[1].ID != "p2" {
+		t.Errorf("unexpected projects: %+v", projects)
+	}
+}
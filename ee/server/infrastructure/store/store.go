@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver used for Postgres URLs
 	"github.com/lelemon/server/pkg/domain/repository"
 	"github.com/lelemon/ee/server/domain/entity"
 )
@@ -16,18 +17,51 @@ import (
 // Store implements enterprise repository interfaces
 // It wraps the core store and adds enterprise-specific methods
 type Store struct {
-	db       *sql.DB
+	db        *sql.DB
 	coreStore repository.Store
+	dialect   dialect
 }
 
+// dialect is the SQL flavour of db. The enterprise tables live in the primary
+// database (SQLite or Postgres); ClickHouse is only ever the analytics store.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
 // New creates a new enterprise store wrapping the core store
 func New(coreStore repository.Store, db *sql.DB) *Store {
+	d := dialectSQLite
+	if _, ok := db.Driver().(*stdlib.Driver); ok {
+		d = dialectPostgres
+	}
 	return &Store{
 		db:        db,
 		coreStore: coreStore,
+		dialect:   d,
 	}
 }
 
+// rebind rewrites ? placeholders as $1, $2, ... on Postgres
+func (s *Store) rebind(query string) string {
+	if s.dialect != dialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // CoreStore returns the underlying core store
 func (s *Store) CoreStore() repository.Store {
 	return s.coreStore
@@ -76,7 +110,8 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// MigrateEnterprise runs enterprise-specific migrations
+// MigrateEnterprise runs enterprise-specific migrations. The DDL is valid on
+// both SQLite and Postgres (TIMESTAMP has the same affinity as DATETIME in SQLite).
 func (s *Store) MigrateEnterprise(ctx context.Context) error {
 	migrations := []string{
 		// Organizations table
@@ -87,8 +122,8 @@ func (s *Store) MigrateEnterprise(ctx context.Context) error {
 			owner_user_id TEXT NOT NULL,
 			plan TEXT DEFAULT 'free',
 			settings TEXT DEFAULT '{}',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
 
 		// Team Members table
@@ -98,9 +133,9 @@ func (s *Store) MigrateEnterprise(ctx context.Context) error {
 			user_id TEXT NOT NULL,
 			role TEXT NOT NULL CHECK(role IN ('owner','admin','member','viewer')),
 			invited_by TEXT,
-			invited_at DATETIME,
-			joined_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			invited_at TIMESTAMP,
+			joined_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(organization_id, user_id)
 		)`,
 
@@ -112,11 +147,11 @@ func (s *Store) MigrateEnterprise(ctx context.Context) error {
 			status TEXT DEFAULT 'active',
 			lemonsqueezy_id TEXT,
 			customer_id TEXT,
-			current_period_start DATETIME,
-			current_period_end DATETIME,
-			cancelled_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			current_period_start TIMESTAMP,
+			current_period_end TIMESTAMP,
+			cancelled_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
 
 		// Usage tracking table
@@ -126,8 +161,8 @@ func (s *Store) MigrateEnterprise(ctx context.Context) error {
 			month TEXT NOT NULL,
 			traces_used INTEGER DEFAULT 0,
 			spans_used INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(organization_id, month)
 		)`,
 
@@ -146,8 +181,8 @@ func (s *Store) MigrateEnterprise(ctx context.Context) error {
 
 	for _, m := range migrations {
 		if _, err := s.db.ExecContext(ctx, m); err != nil {
-			// Ignore "duplicate column name" errors for ALTER TABLE
-			if strings.Contains(m, "ALTER TABLE") && strings.Contains(err.Error(), "duplicate column name") {
+			// Ignore "duplicate column name" (SQLite) / "already exists" (Postgres) errors for ALTER TABLE
+			if strings.Contains(m, "ALTER TABLE") && (strings.Contains(err.Error(), "duplicate column name") || strings.Contains(err.Error(), "already exists")) {
 				continue
 			}
 			// Ignore "already exists" errors for CREATE INDEX
@@ -300,6 +335,31 @@ func (s *Store) ListOrganizationsByUser(ctx context.Context, userID string) ([]e
 	return orgs, rows.Err()
 }
 
+// listOrganizationProjectsSQL lists an organization's projects, oldest first
+const listOrganizationProjectsSQL = `
+	SELECT id, name FROM projects WHERE organization_id = ? ORDER BY created_at
+`
+
+// ListOrganizationProjects returns the organization's projects (idx_projects_org)
+func (s *Store) ListOrganizationProjects(ctx context.Context, orgID string) ([]entity.OrgProject, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(listOrganizationProjectsSQL), orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []entity.OrgProject
+	for rows.Next() {
+		var p entity.OrgProject
+		if err := rows.Scan(&p.ID, &p.Name); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+
+	return projects, rows.Err()
+}
+
 // ============================================
 // TEAM MEMBER OPERATIONS
 // ============================================
@@ -560,24 +620,81 @@ func (s *Store) UpdateSubscription(ctx context.Context, id string, updates *enti
 // USAGE OPERATIONS
 // ============================================
 
+// incrementUsageSQL upserts a month's usage row. Columns of the existing row are
+// qualified with the table name, which both SQLite and Postgres require to tell
+// them apart from excluded.*.
+const incrementUsageSQL = `
+	INSERT INTO usage (id, organization_id, month, traces_used, spans_used, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(organization_id, month) DO UPDATE SET
+		traces_used = usage.traces_used + excluded.traces_used,
+		spans_used = usage.spans_used + excluded.spans_used,
+		updated_at = excluded.updated_at
+`
+
 func (s *Store) Increment(ctx context.Context, orgID string, traces, spans int) error {
 	month := time.Now().Format("2006-01")
 	now := time.Now()
 	db := s.getExecutor(ctx)
 
-	// SQLite UPSERT - atomic operation, no race condition
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO usage (id, organization_id, month, traces_used, spans_used, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(organization_id, month) DO UPDATE SET
-			traces_used = traces_used + excluded.traces_used,
-			spans_used = spans_used + excluded.spans_used,
-			updated_at = excluded.updated_at
-	`, uuid.New().String(), orgID, month, traces, spans, now, now)
+	// UPSERT - atomic operation, no race condition
+	_, err := db.ExecContext(ctx, s.rebind(incrementUsageSQL), uuid.New().String(), orgID, month, traces, spans, now, now)
 
 	return err
 }
 
+// IncrementByProject adds each project's usage to its organization's current
+// month: one UPSERT per organization, all in a single transaction. Projects
+// that don't belong to an organization are skipped.
+func (s *Store) IncrementByProject(ctx context.Context, usage map[string]entity.UsageDelta) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	// Resolve organizations before the transaction, so it only writes and
+	// SQLite's busy timeout can serialize concurrent batches
+	projectIDs := make([]any, 0, len(usage))
+	for id := range usage {
+		projectIDs = append(projectIDs, id)
+	}
+	query := fmt.Sprintf(`
+		SELECT id, organization_id FROM projects
+		WHERE organization_id IS NOT NULL AND id IN (%s)
+	`, strings.TrimSuffix(strings.Repeat("?,", len(projectIDs)), ","))
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), projectIDs...)
+	if err != nil {
+		return fmt.Errorf("resolve project organizations: %w", err)
+	}
+	byOrg := make(map[string]entity.UsageDelta)
+	for rows.Next() {
+		var projectID, orgID string
+		if err := rows.Scan(&projectID, &orgID); err != nil {
+			rows.Close()
+			return err
+		}
+		d := byOrg[orgID]
+		d.Traces += usage[projectID].Traces
+		d.Spans += usage[projectID].Spans
+		byOrg[orgID] = d
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(byOrg) == 0 {
+		return nil
+	}
+
+	return s.WithTransaction(ctx, func(ctx context.Context) error {
+		for orgID, d := range byOrg {
+			if err := s.Increment(ctx, orgID, d.Traces, d.Spans); err != nil {
+				return fmt.Errorf("increment usage for %s: %w", orgID, err)
+			}
+		}
+		return nil
+	})
+}
+
 func (s *Store) GetCurrentMonth(ctx context.Context, orgID string) (*entity.Usage, error) {
 	month := time.Now().Format("2006-01")
 	return s.GetByMonth(ctx, orgID, month)
@@ -586,10 +703,10 @@ func (s *Store) GetCurrentMonth(ctx context.Context, orgID string) (*entity.Usag
 func (s *Store) GetByMonth(ctx context.Context, orgID, month string) (*entity.Usage, error) {
 	var u entity.Usage
 
-	err := s.db.QueryRowContext(ctx, `
+	err := s.db.QueryRowContext(ctx, s.rebind(`
 		SELECT id, organization_id, month, traces_used, spans_used, created_at, updated_at
 		FROM usage WHERE organization_id = ? AND month = ?
-	`, orgID, month).Scan(&u.ID, &u.OrganizationID, &u.Month, &u.TracesUsed, &u.SpansUsed, &u.CreatedAt, &u.UpdatedAt)
+	`), orgID, month).Scan(&u.ID, &u.OrganizationID, &u.Month, &u.TracesUsed, &u.SpansUsed, &u.CreatedAt, &u.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		// Return empty usage if not found
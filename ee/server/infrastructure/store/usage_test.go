@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/lelemon/ee/server/domain/entity"
+)
+
+// setupUsageDB opens a file-backed DB (so connections share it) with the
+// projects table the enterprise migrations extend
+func setupUsageDB(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite", t.TempDir()+"/usage.db?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE projects (id TEXT PRIMARY KEY, name TEXT, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("failed to create projects table: %v", err)
+	}
+	store := New(nil, db)
+	if err := store.MigrateEnterprise(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return store
+}
+
+func TestIncrement_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store := setupUsageDB(t)
+
+	const goroutines, perGoroutine = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*perGoroutine)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				if err := store.Increment(ctx, "org-1", 1, 3); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Increment failed: %v", err)
+	}
+
+	usage, err := store.GetCurrentMonth(ctx, "org-1")
+	if err != nil {
+		t.Fatalf("GetCurrentMonth failed: %v", err)
+	}
+	if want := goroutines * perGoroutine; usage.TracesUsed != want || usage.SpansUsed != 3*want {
+		t.Errorf("expected %d traces and %d spans, got %d and %d", want, 3*want, usage.TracesUsed, usage.SpansUsed)
+	}
+}
+
+func TestIncrementByProject(t *testing.T) {
+	ctx := context.Background()
+	store := setupUsageDB(t)
+
+	// org-a owns two projects, org-b one; "solo" has no organization
+	for id, org := range map[string]any{"p1": "org-a", "p2": "org-a", "p3": "org-b", "solo": nil} {
+		if _, err := store.db.Exec(`INSERT INTO projects (id, name, organization_id) VALUES (?, ?, ?)`, id, id, org); err != nil {
+			t.Fatalf("failed to insert project: %v", err)
+		}
+	}
+
+	const batches = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, batches)
+	for i := 0; i < batches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.IncrementByProject(ctx, map[string]entity.UsageDelta{
+				"p1":      {Traces: 1, Spans: 2},
+				"p2":      {Traces: 2, Spans: 5},
+				"p3":      {Traces: 1, Spans: 1},
+				"solo":    {Traces: 9, Spans: 9},
+				"missing": {Traces: 9, Spans: 9},
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("IncrementByProject failed: %v", err)
+		}
+	}
+
+	for org, want := range map[string]entity.UsageDelta{
+		"org-a": {Traces: 3 * batches, Spans: 7 * batches},
+		"org-b": {Traces: batches, Spans: batches},
+	} {
+		usage, err := store.GetCurrentMonth(ctx, org)
+		if err != nil {
+			t.Fatalf("GetCurrentMonth failed: %v", err)
+		}
+		if usage.TracesUsed != want.Traces || usage.SpansUsed != want.Spans {
+			t.Errorf("%s: expected %+v, got traces=%d spans=%d", org, want, usage.TracesUsed, usage.SpansUsed)
+		}
+	}
+
+	var rows int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM usage`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("expected usage rows only for org-a and org-b, got %d", rows)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	sqlite := &Store{dialect: dialectSQLite}
+	postgres := &Store{dialect: dialectPostgres}
+	query := "SELECT * FROM usage WHERE organization_id = ? AND month = ?"
+
+	if got := sqlite.rebind(query); got != query {
+		t.Errorf("expected SQLite query unchanged, got %q", got)
+	}
+	if got, want := postgres.rebind(query), "SELECT * FROM usage WHERE organization_id = $1 AND month = $2"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// The store's own queries carry no ? left on Postgres
+	for name, query := range map[string]string{
+		"incrementUsage":           incrementUsageSQL,
+		"listOrganizationProjects": listOrganizationProjectsSQL,
+	} {
+		if got := postgres.rebind(query); strings.Contains(got, "?") || !strings.Contains(got, "$1") {
+			t.Errorf("%s: expected $n placeholders on Postgres, got %q", name, got)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	coreHttp "github.com/lelemon/server/pkg/interfaces/http"
 	coreMiddleware "github.com/lelemon/server/pkg/interfaces/http/middleware"
 
+	"github.com/lelemon/ee/server/application/analytics"
 	"github.com/lelemon/ee/server/application/billing"
+	"github.com/lelemon/ee/server/application/features"
 	"github.com/lelemon/ee/server/application/organization"
 	"github.com/lelemon/ee/server/application/rbac"
 	"github.com/lelemon/ee/server/domain/entity"
@@ -24,6 +26,8 @@ type EnterpriseExtension struct {
 	billingSvc     *billing.Service
 	lsClient       *lemonsqueezy.Client
 	analyticsStore repository.AnalyticsStore
+	orgAnalytics   *analytics.Service
+	features       *features.Resolver
 }
 
 // NewEnterpriseExtension creates a new enterprise extension.
@@ -33,6 +37,7 @@ func NewEnterpriseExtension(
 	billingSvc *billing.Service,
 	lsClient *lemonsqueezy.Client,
 	analyticsStore repository.AnalyticsStore,
+	orgAnalytics *analytics.Service,
 ) *EnterpriseExtension {
 	return &EnterpriseExtension{
 		orgSvc:         orgSvc,
@@ -40,15 +45,26 @@ func NewEnterpriseExtension(
 		billingSvc:     billingSvc,
 		lsClient:       lsClient,
 		analyticsStore: analyticsStore,
+		orgAnalytics:   orgAnalytics,
+		features:       features.NewResolver(coreHttp.EnterpriseFeaturesConfig().Features, nil),
 	}
 }
 
+// SetFeatures replaces the resolver gating features by plan (by default the
+// enterprise features with the entity.PlanFeatures defaults).
+// Call it before MountRoutes.
+func (e *EnterpriseExtension) SetFeatures(resolver *features.Resolver) {
+	e.features = resolver
+}
+
 // MountRoutes adds enterprise routes to the router.
 func (e *EnterpriseExtension) MountRoutes(r chi.Router, deps *coreHttp.RouterDeps) {
 	// Create handlers
 	orgHandler := handler.NewOrganizationHandler(e.orgSvc, deps.GetUserID)
-	billingHandler := handler.NewBillingHandler(e.billingSvc, e.lsClient, deps.GetUserEmail)
+	billingHandler := handler.NewBillingHandler(e.billingSvc, e.lsClient, deps.GetUserID, deps.GetUserEmail)
 	analyticsHandler := handler.NewAnalyticsHandler(e.analyticsStore)
+	orgAnalyticsHandler := handler.NewOrgAnalyticsHandler(e.orgAnalytics)
+	orgFeaturesHandler := handler.NewOrgFeaturesHandler(e.features)
 
 	// Enterprise API routes
 	r.Route("/api/v1", func(r chi.Router) {
@@ -72,6 +88,13 @@ func (e *EnterpriseExtension) MountRoutes(r chi.Router, deps *coreHttp.RouterDep
 
 				r.Get("/", orgHandler.Get)
 
+				// Branded frontend its OAuth logins land on (?org=<slug>)
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermOrgUpdate, deps.GetUserID)).
+					Put("/frontend", orgHandler.SetFrontend)
+
+				// Features the organization's plan includes
+				r.Get("/features", orgFeaturesHandler.Get)
+
 				// Team management
 				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermTeamRead, deps.GetUserID)).
 					Get("/members", orgHandler.ListMembers)
@@ -82,17 +105,24 @@ func (e *EnterpriseExtension) MountRoutes(r chi.Router, deps *coreHttp.RouterDep
 				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermTeamManage, deps.GetUserID)).
 					Delete("/members/{userId}", orgHandler.RemoveMember)
 
-				// Billing routes
+				// Billing routes (owners and admins; the billing service checks again)
 				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingRead, deps.GetUserID)).
 					Get("/billing", billingHandler.GetBilling)
-				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingWrite, deps.GetUserID)).
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingManage, deps.GetUserID)).
 					Post("/billing/checkout", billingHandler.CreateCheckout)
-				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingRead, deps.GetUserID)).
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingManage, deps.GetUserID)).
 					Get("/billing/portal", billingHandler.GetCustomerPortal)
-				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingWrite, deps.GetUserID)).
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingRead, deps.GetUserID)).
+					Get("/billing/subscription", billingHandler.GetSubscription)
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingManage, deps.GetUserID)).
 					Delete("/billing/subscription", billingHandler.CancelSubscription)
 				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermBillingRead, deps.GetUserID)).
 					Get("/billing/usage", billingHandler.GetUsage)
+
+				// Analytics rolled up across the organization's projects (enterprise plan)
+				r.With(middleware.RequirePermission(e.rbacSvc, entity.PermProjectRead, deps.GetUserID),
+					middleware.RequireFeature(e.features, entity.FeatureOrgAnalytics)).
+					Get("/analytics/stats", orgAnalyticsHandler.GetStats)
 			})
 
 			// Dashboard - Enterprise analytics routes (project-scoped, requires project:read)
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "modernc.org/sqlite"
+
+	"github.com/lelemon/server/pkg/infrastructure/auth"
+	coreHttp "github.com/lelemon/server/pkg/interfaces/http"
+	coreMiddleware "github.com/lelemon/server/pkg/interfaces/http/middleware"
+
+	"github.com/lelemon/ee/server/application/billing"
+	"github.com/lelemon/ee/server/application/organization"
+	"github.com/lelemon/ee/server/application/rbac"
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
+	"github.com/lelemon/ee/server/infrastructure/store"
+)
+
+func TestBillingRoutes_Authorization(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", t.TempDir()+"/billing.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE projects (id TEXT PRIMARY KEY, name TEXT, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("failed to create projects table: %v", err)
+	}
+	st := store.New(nil, db)
+	if err := st.MigrateEnterprise(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// org-a: owner, admin and a plain member; org-b: its own owner
+	joined := time.Now()
+	for _, org := range []*entity.Organization{
+		{ID: "org-a", Name: "Org A", Slug: "org-a", OwnerUserID: "owner-a", Plan: entity.PlanPro},
+		{ID: "org-b", Name: "Org B", Slug: "org-b", OwnerUserID: "owner-b", Plan: entity.PlanPro},
+	} {
+		if err := st.CreateOrganization(ctx, org); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+	for _, m := range []*entity.TeamMember{
+		{OrganizationID: "org-a", UserID: "owner-a", Role: entity.RoleOwner, JoinedAt: &joined},
+		{OrganizationID: "org-a", UserID: "admin-a", Role: entity.RoleAdmin, JoinedAt: &joined},
+		{OrganizationID: "org-a", UserID: "member-a", Role: entity.RoleMember, JoinedAt: &joined},
+		{OrganizationID: "org-b", UserID: "owner-b", Role: entity.RoleOwner, JoinedAt: &joined},
+	} {
+		if err := st.AddMember(ctx, m); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+	for _, sub := range []*entity.Subscription{
+		{ID: "sub-a", OrganizationID: "org-a", Plan: entity.PlanPro, Status: entity.SubStatusActive, LemonSqueezyID: "ls-a"},
+		{ID: "sub-b", OrganizationID: "org-b", Plan: entity.PlanPro, Status: entity.SubStatusActive, LemonSqueezyID: "ls-b"},
+	} {
+		if err := st.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("failed to create subscription: %v", err)
+		}
+	}
+
+	lsClient := lemonsqueezy.NewClient("", "", "")
+	rbacSvc := rbac.NewService(st)
+	ext := NewEnterpriseExtension(
+		organization.NewService(st, nil),
+		rbacSvc,
+		billing.NewService(st, rbacSvc, lsClient, &billing.Config{}),
+		lsClient,
+		st,
+		nil,
+	)
+
+	jwtService := auth.NewJWTService("test-secret-key-for-billing-authorization", time.Hour)
+	router := chi.NewRouter()
+	ext.MountRoutes(router, &coreHttp.RouterDeps{
+		JWTService: jwtService,
+		GetUserID: func(r *http.Request) string {
+			if user := coreMiddleware.GetUser(r.Context()); user != nil {
+				return user.UserID
+			}
+			return ""
+		},
+		GetUserEmail: func(r *http.Request) string {
+			if user := coreMiddleware.GetUser(r.Context()); user != nil {
+				return user.Email
+			}
+			return ""
+		},
+	})
+
+	request := func(userID, method, path string) int {
+		t.Helper()
+		token, err := jwtService.GenerateToken(userID, userID+"@test.com")
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	billingRoutes := []struct{ method, path string }{
+		{http.MethodGet, "/billing"},
+		{http.MethodGet, "/billing/usage"},
+		{http.MethodGet, "/billing/subscription"},
+		{http.MethodGet, "/billing/portal"},
+		{http.MethodPost, "/billing/checkout"},
+		{http.MethodDelete, "/billing/subscription"},
+	}
+
+	t.Run("non-members are forbidden", func(t *testing.T) {
+		for _, route := range billingRoutes {
+			if code := request("owner-b", route.method, "/api/v1/organizations/org-a"+route.path); code != http.StatusForbidden {
+				t.Errorf("%s %s: expected 403, got %d", route.method, route.path, code)
+			}
+		}
+	})
+
+	t.Run("members without billing permissions are forbidden", func(t *testing.T) {
+		for _, route := range billingRoutes {
+			if code := request("member-a", route.method, "/api/v1/organizations/org-a"+route.path); code != http.StatusForbidden {
+				t.Errorf("%s %s: expected 403, got %d", route.method, route.path, code)
+			}
+		}
+	})
+
+	t.Run("owners and admins read billing", func(t *testing.T) {
+		for _, userID := range []string{"owner-a", "admin-a"} {
+			for _, path := range []string{"/billing", "/billing/subscription"} {
+				if code := request(userID, http.MethodGet, "/api/v1/organizations/org-a"+path); code != http.StatusOK {
+					t.Errorf("%s GET %s: expected 200, got %d", userID, path, code)
+				}
+			}
+		}
+	})
+}
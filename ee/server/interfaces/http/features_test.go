@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "modernc.org/sqlite"
+
+	coreAnalytics "github.com/lelemon/server/pkg/application/analytics"
+	coreEntity "github.com/lelemon/server/pkg/domain/entity"
+	"github.com/lelemon/server/pkg/infrastructure/auth"
+	coreHttp "github.com/lelemon/server/pkg/interfaces/http"
+	coreMiddleware "github.com/lelemon/server/pkg/interfaces/http/middleware"
+
+	"github.com/lelemon/ee/server/application/analytics"
+	"github.com/lelemon/ee/server/application/billing"
+	"github.com/lelemon/ee/server/application/features"
+	"github.com/lelemon/ee/server/application/organization"
+	"github.com/lelemon/ee/server/application/rbac"
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
+	"github.com/lelemon/ee/server/infrastructure/store"
+)
+
+type emptyStats struct{}
+
+func (emptyStats) GetSummary(ctx context.Context, projectID string, req *coreAnalytics.SummaryRequest) (*coreEntity.Stats, error) {
+	return &coreEntity.Stats{}, nil
+}
+
+func TestPlanFeatureGating(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", t.TempDir()+"/features.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE projects (id TEXT PRIMARY KEY, name TEXT, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("failed to create projects table: %v", err)
+	}
+	st := store.New(nil, db)
+	if err := st.MigrateEnterprise(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	joined := time.Now()
+	for _, org := range []*entity.Organization{
+		{ID: "org-pro", Name: "Pro Org", Slug: "org-pro", OwnerUserID: "owner-pro", Plan: entity.PlanPro},
+		{ID: "org-ent", Name: "Enterprise Org", Slug: "org-ent", OwnerUserID: "owner-ent", Plan: entity.PlanEnterprise},
+	} {
+		if err := st.CreateOrganization(ctx, org); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+		if err := st.AddMember(ctx, &entity.TeamMember{OrganizationID: org.ID, UserID: org.OwnerUserID, Role: entity.RoleOwner, JoinedAt: &joined}); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+
+	jwtService := auth.NewJWTService("test-secret-key-for-plan-feature-gating", time.Hour)
+	lsClient := lemonsqueezy.NewClient("", "", "")
+	rbacSvc := rbac.NewService(st)
+	newRouter := func(resolver *features.Resolver) http.Handler {
+		ext := NewEnterpriseExtension(
+			organization.NewService(st, nil),
+			rbacSvc,
+			billing.NewService(st, rbacSvc, lsClient, &billing.Config{}),
+			lsClient,
+			st,
+			analytics.NewService(st, emptyStats{}),
+		)
+		if resolver != nil {
+			ext.SetFeatures(resolver)
+		}
+		router := chi.NewRouter()
+		ext.MountRoutes(router, &coreHttp.RouterDeps{
+			JWTService: jwtService,
+			GetUserID: func(r *http.Request) string {
+				if user := coreMiddleware.GetUser(r.Context()); user != nil {
+					return user.UserID
+				}
+				return ""
+			},
+		})
+		return router
+	}
+	request := func(router http.Handler, userID, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		token, err := jwtService.GenerateToken(userID, userID+"@test.com")
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	router := newRouter(nil)
+
+	t.Run("pro org denied an enterprise-only feature", func(t *testing.T) {
+		rec := request(router, "owner-pro", "/api/v1/organizations/org-pro/analytics/stats")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", rec.Code)
+		}
+		var body struct {
+			Error struct {
+				Code    string            `json:"code"`
+				Details map[string]string `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		if body.Error.Code != "feature_not_in_plan" || body.Error.Details["feature"] != "org_analytics" {
+			t.Errorf("expected feature_not_in_plan for org_analytics, got %+v", body.Error)
+		}
+	})
+
+	t.Run("enterprise org allowed", func(t *testing.T) {
+		if rec := request(router, "owner-ent", "/api/v1/organizations/org-ent/analytics/stats"); rec.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("features reported per plan", func(t *testing.T) {
+		for orgID, want := range map[string]map[string]bool{
+			"org-pro": {"organizations": true, "billing": true, "rbac": true, "sso": false, "org_analytics": false},
+			"org-ent": {"organizations": true, "billing": true, "rbac": true, "sso": true, "org_analytics": true},
+		} {
+			rec := request(router, "owner-"+orgID[len("org-"):], "/api/v1/organizations/"+orgID+"/features")
+			var body struct {
+				Features map[string]bool `json:"features"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			for feature, enabled := range want {
+				if body.Features[feature] != enabled {
+					t.Errorf("%s: expected %s=%v, got %v", orgID, feature, enabled, body.Features)
+				}
+			}
+		}
+	})
+
+	t.Run("plan overrides and deployment toggles", func(t *testing.T) {
+		router := newRouter(features.NewResolver(
+			map[string]bool{"sso": false},
+			map[entity.BillingPlan][]entity.Feature{entity.PlanPro: {entity.FeatureOrgAnalytics}},
+		))
+		if rec := request(router, "owner-pro", "/api/v1/organizations/org-pro/analytics/stats"); rec.Code != http.StatusOK {
+			t.Errorf("expected pro org allowed once its plan includes org_analytics, got %d", rec.Code)
+		}
+		rec := request(router, "owner-ent", "/api/v1/organizations/org-ent/features")
+		var body struct {
+			Features map[string]bool `json:"features"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		if body.Features["sso"] {
+			t.Errorf("expected sso off when the deployment disables it, got %v", body.Features)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "modernc.org/sqlite"
+
+	"github.com/lelemon/server/pkg/infrastructure/auth"
+	coreHttp "github.com/lelemon/server/pkg/interfaces/http"
+	coreMiddleware "github.com/lelemon/server/pkg/interfaces/http/middleware"
+
+	"github.com/lelemon/ee/server/application/billing"
+	"github.com/lelemon/ee/server/application/organization"
+	"github.com/lelemon/ee/server/application/rbac"
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
+	"github.com/lelemon/ee/server/infrastructure/store"
+	"github.com/lelemon/ee/server/interfaces/http/handler"
+)
+
+func TestOrgFrontends(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", t.TempDir()+"/frontend.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE projects (id TEXT PRIMARY KEY, name TEXT, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("failed to create projects table: %v", err)
+	}
+	st := store.New(nil, db)
+	if err := st.MigrateEnterprise(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := st.CreateOrganization(ctx, &entity.Organization{ID: "org-a", Name: "Acme", Slug: "acme", OwnerUserID: "owner-a", Plan: entity.PlanPro}); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	joined := time.Now()
+	for _, m := range []*entity.TeamMember{
+		{OrganizationID: "org-a", UserID: "owner-a", Role: entity.RoleOwner, JoinedAt: &joined},
+		{OrganizationID: "org-a", UserID: "member-a", Role: entity.RoleMember, JoinedAt: &joined},
+	} {
+		if err := st.AddMember(ctx, m); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+
+	orgSvc := organization.NewService(st, nil)
+	lsClient := lemonsqueezy.NewClient("", "", "")
+	rbacSvc := rbac.NewService(st)
+	ext := NewEnterpriseExtension(orgSvc, rbacSvc, billing.NewService(st, rbacSvc, lsClient, &billing.Config{}), lsClient, st, nil)
+
+	jwtService := auth.NewJWTService("test-secret-key-for-org-frontends", time.Hour)
+	router := chi.NewRouter()
+	ext.MountRoutes(router, &coreHttp.RouterDeps{
+		JWTService: jwtService,
+		GetUserID: func(r *http.Request) string {
+			if user := coreMiddleware.GetUser(r.Context()); user != nil {
+				return user.UserID
+			}
+			return ""
+		},
+	})
+	setFrontend := func(userID, frontend string) int {
+		t.Helper()
+		token, err := jwtService.GenerateToken(userID, userID+"@test.com")
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/organizations/org-a/frontend", strings.NewReader(`{"frontendUrl":"`+frontend+`"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	resolve := handler.OrgFrontends(orgSvc)
+	login := func(query string) string {
+		return resolve(httptest.NewRequest(http.MethodGet, "/api/v1/auth/google"+query, nil))
+	}
+
+	if got := login("?org=acme"); got != "" {
+		t.Errorf("expected no frontend before it is set, got %q", got)
+	}
+	if code := setFrontend("member-a", "https://acme.lelemon.dev"); code != http.StatusForbidden {
+		t.Errorf("expected members to be forbidden, got %d", code)
+	}
+	if code := setFrontend("owner-a", "ftp://acme.lelemon.dev"); code != http.StatusBadRequest {
+		t.Errorf("expected an invalid frontend to be rejected, got %d", code)
+	}
+	if code := setFrontend("owner-a", "https://acme.lelemon.dev/"); code != http.StatusOK {
+		t.Fatalf("expected the owner to set the frontend, got %d", code)
+	}
+
+	if got := login("?org=acme"); got != "https://acme.lelemon.dev" {
+		t.Errorf("expected the organization's frontend, got %q", got)
+	}
+	if got := login("?org=unknown"); got != "" {
+		t.Errorf("expected no frontend for an unknown organization, got %q", got)
+	}
+	if got := login(""); got != "" {
+		t.Errorf("expected no frontend without an organization, got %q", got)
+	}
+}
@@ -9,20 +9,23 @@ import (
 	"github.com/lelemon/ee/server/application/billing"
 	"github.com/lelemon/ee/server/domain/entity"
 	"github.com/lelemon/ee/server/infrastructure/lemonsqueezy"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 // BillingHandler handles billing-related HTTP requests
 type BillingHandler struct {
 	svc          *billing.Service
 	lsClient     *lemonsqueezy.Client
+	getUserID    func(r *http.Request) string
 	getUserEmail func(r *http.Request) string
 }
 
 // NewBillingHandler creates a new billing handler
-func NewBillingHandler(svc *billing.Service, lsClient *lemonsqueezy.Client, getUserEmail func(r *http.Request) string) *BillingHandler {
+func NewBillingHandler(svc *billing.Service, lsClient *lemonsqueezy.Client, getUserID, getUserEmail func(r *http.Request) string) *BillingHandler {
 	return &BillingHandler{
 		svc:          svc,
 		lsClient:     lsClient,
+		getUserID:    getUserID,
 		getUserEmail: getUserEmail,
 	}
 }
@@ -31,7 +34,7 @@ func NewBillingHandler(svc *billing.Service, lsClient *lemonsqueezy.Client, getU
 func (h *BillingHandler) GetBilling(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
 
-	info, err := h.svc.GetBilling(r.Context(), orgID)
+	info, err := h.svc.GetBilling(r.Context(), h.getUserID(r), orgID)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -40,6 +43,19 @@ func (h *BillingHandler) GetBilling(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, info)
 }
 
+// GetSubscription handles GET /organizations/{orgId}/billing/subscription
+func (h *BillingHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	sub, err := h.svc.GetSubscription(r.Context(), h.getUserID(r), orgID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, sub)
+}
+
 // CreateCheckout handles POST /organizations/{orgId}/billing/checkout
 func (h *BillingHandler) CreateCheckout(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
@@ -63,7 +79,7 @@ func (h *BillingHandler) CreateCheckout(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	url, err := h.svc.CreateCheckout(r.Context(), orgID, plan, userEmail)
+	url, err := h.svc.CreateCheckout(r.Context(), h.getUserID(r), orgID, plan, userEmail)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -76,7 +92,7 @@ func (h *BillingHandler) CreateCheckout(w http.ResponseWriter, r *http.Request)
 func (h *BillingHandler) GetCustomerPortal(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
 
-	url, err := h.svc.GetCustomerPortal(r.Context(), orgID)
+	url, err := h.svc.GetCustomerPortal(r.Context(), h.getUserID(r), orgID)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -89,7 +105,7 @@ func (h *BillingHandler) GetCustomerPortal(w http.ResponseWriter, r *http.Reques
 func (h *BillingHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
 
-	err := h.svc.CancelSubscription(r.Context(), orgID)
+	err := h.svc.CancelSubscription(r.Context(), h.getUserID(r), orgID)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -102,7 +118,7 @@ func (h *BillingHandler) CancelSubscription(w http.ResponseWriter, r *http.Reque
 func (h *BillingHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
 
-	info, err := h.svc.GetBilling(r.Context(), orgID)
+	info, err := h.svc.GetBilling(r.Context(), h.getUserID(r), orgID)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -120,21 +136,21 @@ func (h *BillingHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	// Read body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "failed to read body")
 		return
 	}
 
 	// Verify signature
 	signature := r.Header.Get("X-Signature")
 	if !h.lsClient.VerifyWebhookSignature(body, signature) {
-		http.Error(w, `{"error":"invalid signature"}`, http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, "invalid signature")
 		return
 	}
 
 	// Parse event
 	event, err := h.lsClient.ParseWebhookEvent(body)
 	if err != nil {
-		http.Error(w, `{"error":"failed to parse event"}`, http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "failed to parse event")
 		return
 	}
 
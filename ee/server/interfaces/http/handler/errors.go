@@ -1,80 +1,77 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/lelemon/ee/server/application/organization"
 	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+	coreMiddleware "github.com/lelemon/server/pkg/interfaces/http/middleware"
 )
 
-// APIError represents a structured API error response
-type APIError struct {
-	Error string `json:"error"`
-	Code  string `json:"code,omitempty"`
-}
-
-// WriteError writes a JSON error response with appropriate status code
+// WriteError writes the standard error envelope with appropriate status code
 func WriteError(w http.ResponseWriter, err error) {
 	status, apiErr := mapError(err)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(apiErr)
+	apierror.WriteCode(w, status, apiErr.Code, apiErr.Message, nil)
 }
 
 // mapError converts domain/application errors to HTTP status and safe messages
-func mapError(err error) (int, APIError) {
+func mapError(err error) (int, apierror.Error) {
 	switch {
 	// Domain errors - safe to expose
 	case errors.Is(err, entity.ErrNotFound):
-		return http.StatusNotFound, APIError{Error: "Resource not found", Code: "NOT_FOUND"}
+		return http.StatusNotFound, apierror.Error{Code: apierror.CodeNotFound, Message: "Resource not found"}
 	case errors.Is(err, entity.ErrPermissionDenied):
-		return http.StatusForbidden, APIError{Error: "Permission denied", Code: "FORBIDDEN"}
+		return http.StatusForbidden, apierror.Error{Code: apierror.CodeForbidden, Message: "Permission denied"}
 	case errors.Is(err, entity.ErrLimitExceeded):
-		return http.StatusPaymentRequired, APIError{Error: "Plan limit exceeded", Code: "LIMIT_EXCEEDED"}
+		return http.StatusPaymentRequired, apierror.Error{Code: apierror.CodeQuotaExceeded, Message: "Plan limit exceeded"}
 	case errors.Is(err, entity.ErrAlreadyExists):
-		return http.StatusConflict, APIError{Error: "Resource already exists", Code: "ALREADY_EXISTS"}
+		return http.StatusConflict, apierror.Error{Code: apierror.CodeConflict, Message: "Resource already exists"}
 
 	// Validation errors - safe to expose
+	case errors.Is(err, entity.ErrInvalidInput):
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: "Invalid input"}
 	case errors.Is(err, entity.ErrInvalidName):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 	case errors.Is(err, entity.ErrInvalidSlug):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 	case errors.Is(err, entity.ErrInvalidEmail):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 	case errors.Is(err, entity.ErrInvalidRole):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 	case errors.Is(err, entity.ErrMissingOrgID):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 	case errors.Is(err, entity.ErrMissingUserID):
-		return http.StatusBadRequest, APIError{Error: err.Error(), Code: "VALIDATION_ERROR"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
+	case errors.Is(err, entity.ErrInvalidFrontendURL):
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: err.Error()}
 
 	// Business rule errors - safe to expose
 	case errors.Is(err, entity.ErrCannotInviteAsOwner):
-		return http.StatusBadRequest, APIError{Error: "Cannot invite as owner", Code: "BUSINESS_RULE"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: "Cannot invite as owner"}
 	case errors.Is(err, entity.ErrCannotRemoveOwner):
-		return http.StatusBadRequest, APIError{Error: "Cannot remove organization owner", Code: "BUSINESS_RULE"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: "Cannot remove organization owner"}
 	case errors.Is(err, entity.ErrCannotDemoteOwner):
-		return http.StatusBadRequest, APIError{Error: "Cannot demote organization owner", Code: "BUSINESS_RULE"}
+		return http.StatusBadRequest, apierror.Error{Code: apierror.CodeValidationFailed, Message: "Cannot demote organization owner"}
 	case errors.Is(err, entity.ErrInsufficientPrivilege):
-		return http.StatusForbidden, APIError{Error: "Insufficient privilege", Code: "FORBIDDEN"}
+		return http.StatusForbidden, apierror.Error{Code: apierror.CodeForbidden, Message: "Insufficient privilege"}
 
 	// Application errors - safe to expose
 	case errors.Is(err, organization.ErrNotMember):
-		return http.StatusForbidden, APIError{Error: "Not a member of this organization", Code: "NOT_MEMBER"}
+		return http.StatusForbidden, apierror.Error{Code: apierror.CodeForbidden, Message: "Not a member of this organization"}
 	case errors.Is(err, organization.ErrCannotInviteHigherRole):
-		return http.StatusForbidden, APIError{Error: "Cannot invite user with higher role", Code: "FORBIDDEN"}
+		return http.StatusForbidden, apierror.Error{Code: apierror.CodeForbidden, Message: "Cannot invite user with higher role"}
 	case errors.Is(err, organization.ErrAlreadyMember):
-		return http.StatusConflict, APIError{Error: "User is already a member", Code: "ALREADY_EXISTS"}
+		return http.StatusConflict, apierror.Error{Code: apierror.CodeConflict, Message: "User is already a member"}
 	case errors.Is(err, organization.ErrUserNotFound):
-		return http.StatusNotFound, APIError{Error: "User not found - they must sign up first", Code: "USER_NOT_FOUND"}
+		return http.StatusNotFound, apierror.Error{Code: apierror.CodeNotFound, Message: "User not found - they must sign up first"}
 
 	// Default: log internal error, return generic message
 	default:
 		slog.Error("internal error", "error", err)
-		return http.StatusInternalServerError, APIError{Error: "Internal server error", Code: "INTERNAL_ERROR"}
+		return http.StatusInternalServerError, apierror.Error{Code: apierror.CodeInternal, Message: "Internal server error"}
 	}
 }
 
@@ -82,5 +79,5 @@ func mapError(err error) (int, APIError) {
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	coreMiddleware.WriteJSON(w, data)
 }
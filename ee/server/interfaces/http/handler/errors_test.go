@@ -9,6 +9,7 @@ import (
 
 	"github.com/lelemon/ee/server/application/organization"
 	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 func TestWriteError(t *testing.T) {
@@ -16,126 +17,132 @@ func TestWriteError(t *testing.T) {
 		name           string
 		err            error
 		expectedStatus int
-		expectedCode   string
+		expectedCode   apierror.Code
 	}{
 		// Domain errors
 		{
 			name:           "not found",
 			err:            entity.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
-			expectedCode:   "NOT_FOUND",
+			expectedCode:   apierror.CodeNotFound,
 		},
 		{
 			name:           "permission denied",
 			err:            entity.ErrPermissionDenied,
 			expectedStatus: http.StatusForbidden,
-			expectedCode:   "FORBIDDEN",
+			expectedCode:   apierror.CodeForbidden,
 		},
 		{
 			name:           "limit exceeded",
 			err:            entity.ErrLimitExceeded,
 			expectedStatus: http.StatusPaymentRequired,
-			expectedCode:   "LIMIT_EXCEEDED",
+			expectedCode:   apierror.CodeQuotaExceeded,
 		},
 		{
 			name:           "already exists",
 			err:            entity.ErrAlreadyExists,
 			expectedStatus: http.StatusConflict,
-			expectedCode:   "ALREADY_EXISTS",
+			expectedCode:   apierror.CodeConflict,
 		},
 		// Validation errors
+		{
+			name:           "invalid input",
+			err:            entity.ErrInvalidInput,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   apierror.CodeValidationFailed,
+		},
 		{
 			name:           "invalid name",
 			err:            entity.ErrInvalidName,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "invalid slug",
 			err:            entity.ErrInvalidSlug,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "invalid email",
 			err:            entity.ErrInvalidEmail,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "invalid role",
 			err:            entity.ErrInvalidRole,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "missing org ID",
 			err:            entity.ErrMissingOrgID,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "missing user ID",
 			err:            entity.ErrMissingUserID,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "VALIDATION_ERROR",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		// Business rule errors
 		{
 			name:           "cannot invite as owner",
 			err:            entity.ErrCannotInviteAsOwner,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "BUSINESS_RULE",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "cannot remove owner",
 			err:            entity.ErrCannotRemoveOwner,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "BUSINESS_RULE",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "cannot demote owner",
 			err:            entity.ErrCannotDemoteOwner,
 			expectedStatus: http.StatusBadRequest,
-			expectedCode:   "BUSINESS_RULE",
+			expectedCode:   apierror.CodeValidationFailed,
 		},
 		{
 			name:           "insufficient privilege",
 			err:            entity.ErrInsufficientPrivilege,
 			expectedStatus: http.StatusForbidden,
-			expectedCode:   "FORBIDDEN",
+			expectedCode:   apierror.CodeForbidden,
 		},
 		// Application errors
 		{
 			name:           "not member",
 			err:            organization.ErrNotMember,
 			expectedStatus: http.StatusForbidden,
-			expectedCode:   "NOT_MEMBER",
+			expectedCode:   apierror.CodeForbidden,
 		},
 		{
 			name:           "cannot invite higher role",
 			err:            organization.ErrCannotInviteHigherRole,
 			expectedStatus: http.StatusForbidden,
-			expectedCode:   "FORBIDDEN",
+			expectedCode:   apierror.CodeForbidden,
 		},
 		{
 			name:           "already member",
 			err:            organization.ErrAlreadyMember,
 			expectedStatus: http.StatusConflict,
-			expectedCode:   "ALREADY_EXISTS",
+			expectedCode:   apierror.CodeConflict,
 		},
 		{
 			name:           "user not found",
 			err:            organization.ErrUserNotFound,
 			expectedStatus: http.StatusNotFound,
-			expectedCode:   "USER_NOT_FOUND",
+			expectedCode:   apierror.CodeNotFound,
 		},
 		// Unknown error - should return 500
 		{
 			name:           "unknown error",
 			err:            errors.New("database connection failed"),
 			expectedStatus: http.StatusInternalServerError,
-			expectedCode:   "INTERNAL_ERROR",
+			expectedCode:   apierror.CodeInternal,
 		},
 	}
 
@@ -152,16 +159,16 @@ func TestWriteError(t *testing.T) {
 				t.Errorf("WriteError() Content-Type = %s, want application/json", w.Header().Get("Content-Type"))
 			}
 
-			var apiErr APIError
-			if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+			var envelope apierror.Envelope
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
 				t.Fatalf("Failed to unmarshal response: %v", err)
 			}
 
-			if apiErr.Code != tt.expectedCode {
-				t.Errorf("WriteError() code = %s, want %s", apiErr.Code, tt.expectedCode)
+			if envelope.Error.Code != tt.expectedCode {
+				t.Errorf("WriteError() code = %s, want %s", envelope.Error.Code, tt.expectedCode)
 			}
 
-			if apiErr.Error == "" {
+			if envelope.Error.Message == "" {
 				t.Error("WriteError() error message should not be empty")
 			}
 		})
@@ -175,14 +182,14 @@ func TestWriteError_DoesNotLeakInternalDetails(t *testing.T) {
 	w := httptest.NewRecorder()
 	WriteError(w, internalErr)
 
-	var apiErr APIError
-	if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+	var envelope apierror.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
 	// Should return generic message, not the actual error
-	if apiErr.Error != "Internal server error" {
-		t.Errorf("WriteError() should not leak internal error details, got: %s", apiErr.Error)
+	if envelope.Error.Message != "Internal server error" {
+		t.Errorf("WriteError() should not leak internal error details, got: %s", envelope.Error.Message)
 	}
 
 	// Body should not contain sensitive info
@@ -249,8 +256,8 @@ func TestMapError_WrappedErrors(t *testing.T) {
 		t.Errorf("mapError() status = %d, want %d", status, http.StatusNotFound)
 	}
 
-	if apiErr.Code != "NOT_FOUND" {
-		t.Errorf("mapError() code = %s, want NOT_FOUND", apiErr.Code)
+	if apiErr.Code != apierror.CodeNotFound {
+		t.Errorf("mapError() code = %s, want %s", apiErr.Code, apierror.CodeNotFound)
 	}
 }
 
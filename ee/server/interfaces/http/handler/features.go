@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/lelemon/ee/server/application/features"
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/ee/server/interfaces/http/middleware"
+)
+
+// OrgFeaturesHandler reports the features an organization's plan includes
+type OrgFeaturesHandler struct {
+	resolver *features.Resolver
+}
+
+// NewOrgFeaturesHandler creates a new organization features handler
+func NewOrgFeaturesHandler(resolver *features.Resolver) *OrgFeaturesHandler {
+	return &OrgFeaturesHandler{resolver: resolver}
+}
+
+// OrgFeaturesResponse lists the plan-gated features and whether the
+// organization may use each
+type OrgFeaturesResponse struct {
+	Plan     entity.BillingPlan      `json:"plan"`
+	Features map[entity.Feature]bool `json:"features"`
+}
+
+// Get handles GET /organizations/{orgId}/features
+func (h *OrgFeaturesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	org := middleware.GetOrganization(r.Context())
+	if org == nil {
+		WriteError(w, entity.ErrMissingOrgID)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, OrgFeaturesResponse{
+		Plan:     org.Plan,
+		Features: h.resolver.Resolve(org.Plan),
+	})
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/lelemon/ee/server/application/organization"
+	coreHandler "github.com/lelemon/server/pkg/interfaces/http/handler"
+)
+
+// OrgFrontends lands OAuth logins started with ?org=<slug> on that
+// organization's FrontendURL setting. Unknown organizations, and ones
+// without the setting, land on the default frontend; the core auth handler
+// still only uses a URL the frontend allowlist allows.
+func OrgFrontends(svc *organization.Service) coreHandler.FrontendResolver {
+	return func(r *http.Request) string {
+		slug := r.URL.Query().Get("org")
+		if slug == "" {
+			return ""
+		}
+		org, err := svc.GetBySlug(r.Context(), slug)
+		if err != nil {
+			slog.Debug("no frontend for login organization", "org", slug, "error", err)
+			return ""
+		}
+		return org.Settings.FrontendURL
+	}
+}
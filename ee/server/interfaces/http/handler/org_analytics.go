@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lelemon/ee/server/application/analytics"
+	"github.com/lelemon/ee/server/domain/entity"
+)
+
+// OrgAnalyticsHandler handles organization-wide analytics requests
+type OrgAnalyticsHandler struct {
+	svc *analytics.Service
+}
+
+// NewOrgAnalyticsHandler creates a new organization analytics handler
+func NewOrgAnalyticsHandler(svc *analytics.Service) *OrgAnalyticsHandler {
+	return &OrgAnalyticsHandler{svc: svc}
+}
+
+// GetStats handles GET /organizations/{orgId}/analytics/stats
+// Query params:
+//   - from: start date (RFC3339 format, default: 7 days before to)
+//   - to: end date (RFC3339 format, default: now)
+func (h *OrgAnalyticsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+	if orgID == "" {
+		WriteError(w, entity.ErrMissingOrgID)
+		return
+	}
+
+	var from, to *time.Time
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			WriteError(w, entity.ErrInvalidInput)
+			return
+		}
+		from = &t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			WriteError(w, entity.ErrInvalidInput)
+			return
+		}
+		to = &t
+	}
+
+	result, err := h.svc.GetOrgStats(r.Context(), orgID, from, to)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, result)
+}
@@ -82,6 +82,27 @@ func (h *OrganizationHandler) Get(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, org)
 }
 
+// SetFrontend handles PUT /organizations/{orgId}/frontend
+func (h *OrganizationHandler) SetFrontend(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	var req struct {
+		FrontendURL string `json:"frontendUrl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, entity.ErrInvalidInput)
+		return
+	}
+
+	org, err := h.svc.SetFrontendURL(r.Context(), orgID, req.FrontendURL)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, org)
+}
+
 // ListMembers handles GET /organizations/{orgId}/members
 func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
 	orgID := chi.URLParam(r, "orgId")
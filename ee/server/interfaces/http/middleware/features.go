@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/lelemon/ee/server/application/features"
+	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
+)
+
+// RequireFeature creates a middleware that rejects requests for organizations
+// whose plan does not include feature, with 403 feature_not_in_plan.
+// The organization must be in context (see InjectOrganization).
+func RequireFeature(resolver *features.Resolver, feature entity.Feature) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			org := GetOrganization(r.Context())
+			if org == nil {
+				apierror.Write(w, http.StatusBadRequest, "organization required")
+				return
+			}
+
+			if !resolver.Enabled(org.Plan, feature) {
+				apierror.WriteCode(w, http.StatusForbidden, apierror.CodeFeatureNotInPlan,
+					"feature not included in the organization's plan",
+					map[string]string{"feature": string(feature), "plan": string(org.Plan)})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
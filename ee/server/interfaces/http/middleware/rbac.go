@@ -8,6 +8,7 @@ import (
 	"github.com/lelemon/ee/server/application/organization"
 	"github.com/lelemon/ee/server/application/rbac"
 	"github.com/lelemon/ee/server/domain/entity"
+	"github.com/lelemon/server/pkg/interfaces/http/apierror"
 )
 
 type contextKey string
@@ -47,7 +48,7 @@ func RequirePermission(rbacSvc *rbac.Service, perm entity.Permission, getUserID
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID := getUserID(r)
 			if userID == "" {
-				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
@@ -58,19 +59,19 @@ func RequirePermission(rbacSvc *rbac.Service, perm entity.Permission, getUserID
 			}
 
 			if orgID == "" {
-				http.Error(w, `{"error":"organization required"}`, http.StatusBadRequest)
+				apierror.Write(w, http.StatusBadRequest, "organization required")
 				return
 			}
 
 			// Check permission
 			allowed, err := rbacSvc.CheckPermission(r.Context(), userID, orgID, perm)
 			if err != nil {
-				http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+				apierror.Write(w, http.StatusInternalServerError, "internal error")
 				return
 			}
 
 			if !allowed {
-				http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+				apierror.Write(w, http.StatusForbidden, "forbidden")
 				return
 			}
 
@@ -91,7 +92,7 @@ func InjectOrganization(orgSvc *organization.Service) func(http.Handler) http.Ha
 
 			org, err := orgSvc.GetByID(r.Context(), orgID)
 			if err != nil {
-				http.Error(w, `{"error":"organization not found"}`, http.StatusNotFound)
+				apierror.Write(w, http.StatusNotFound, "organization not found")
 				return
 			}
 
@@ -107,7 +108,7 @@ func RequireOrgMember(rbacSvc *rbac.Service, getUserID func(r *http.Request) str
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID := getUserID(r)
 			if userID == "" {
-				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
@@ -117,13 +118,13 @@ func RequireOrgMember(rbacSvc *rbac.Service, getUserID func(r *http.Request) str
 			}
 
 			if orgID == "" {
-				http.Error(w, `{"error":"organization required"}`, http.StatusBadRequest)
+				apierror.Write(w, http.StatusBadRequest, "organization required")
 				return
 			}
 
 			isMember, err := rbacSvc.IsMember(r.Context(), userID, orgID)
 			if err != nil || !isMember {
-				http.Error(w, `{"error":"not a member of this organization"}`, http.StatusForbidden)
+				apierror.Write(w, http.StatusForbidden, "not a member of this organization")
 				return
 			}
 